@@ -26,7 +26,7 @@ require (
 	github.com/opencontainers/runtime-spec v0.1.2-0.20190305201733-197975d695ce // indirect
 	github.com/pborman/uuid v1.2.0 // indirect
 	github.com/pkg/errors v0.8.1
-	github.com/prometheus/client_golang v0.9.2 // indirect
+	github.com/prometheus/client_golang v0.9.2
 	github.com/sirupsen/logrus v1.3.0
 	github.com/stretchr/testify v1.2.2
 	golang.org/x/net v0.0.0-20190301231341-16b79f2e4e95 // indirect
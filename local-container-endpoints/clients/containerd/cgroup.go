@@ -0,0 +1,344 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containerd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+)
+
+const (
+	// clockTicksPerSecond is the unit of the CPU times in /proc/stat, which is 100 on Linux
+	clockTicksPerSecond = 100
+	// defaultCPUWeight is the cgroup v2 CPU weight of containers which are run without --cpu-shares
+	defaultCPUWeight = 100
+)
+
+// getCgroupPath returns the cgroup v2 directory of a process; cgroup v1 is not supported
+func (c *NerdctlClient) getCgroupPath(pid int) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(c.procDir, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read the cgroup of process %d", pid)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return filepath.Join(c.cgroupDir, strings.TrimPrefix(line, "0::")), nil
+		}
+	}
+	return "", fmt.Errorf("Process %d has no cgroup v2; container stats require cgroup v2", pid)
+}
+
+// readLimits returns the CPU and memory limits of a cgroup, as they are set by nerdctl run --cpus, --cpu-shares,
+// --memory and --memory-reservation
+func readLimits(path string) (container.Resources, error) {
+	var resources container.Resources
+	if data, err := ioutil.ReadFile(filepath.Join(path, "cpu.max")); err == nil {
+		// the quota and period of cpu.max are in microseconds; the quota is max if it is not limited
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return resources, errors.Wrapf(err, "Invalid cpu.max in %s", path)
+			}
+			period, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil || period == 0 {
+				return resources, fmt.Errorf("Invalid cpu.max in %s: %s", path, strings.TrimSpace(string(data)))
+			}
+			resources.NanoCPUs = quota * 1e9 / period
+		}
+	}
+	if weight, ok, err := readValue(filepath.Join(path, "cpu.weight")); err != nil {
+		return resources, err
+	} else if ok && weight != defaultCPUWeight {
+		// the inverse of the conversion of CPU shares to a weight by the OCI runtime
+		resources.CPUShares = int64(2 + ((weight-1)*262142)/9999)
+	}
+	if memory, ok, err := readValue(filepath.Join(path, "memory.max")); err != nil {
+		return resources, err
+	} else if ok {
+		resources.Memory = int64(memory)
+	}
+	if reservation, ok, err := readValue(filepath.Join(path, "memory.low")); err != nil {
+		return resources, err
+	} else if ok && reservation > 0 {
+		resources.MemoryReservation = int64(reservation)
+	}
+	return resources, nil
+}
+
+// readStats returns a stats sample of a container in the format of the Docker API for cgroup v2; the CPU
+// usage of previous is included as the previous sample, if it is set
+func (c *NerdctlClient) readStats(nc *nerdctlContainer, path string, previous *types.StatsJSON) (*types.StatsJSON, error) {
+	stats := &types.StatsJSON{
+		Name: getName(nc),
+		ID:   nc.ID,
+	}
+	stats.Read = time.Now()
+	if previous != nil {
+		stats.PreRead = previous.Read
+		stats.PreCPUStats = previous.CPUStats
+	}
+
+	cpu, err := readKeyValues(filepath.Join(path, "cpu.stat"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+	// cgroup v2 CPU times are in microseconds, and Docker's are in nanoseconds
+	stats.CPUStats.CPUUsage = types.CPUUsage{
+		TotalUsage:        cpu["usage_usec"] * 1000,
+		UsageInUsermode:   cpu["user_usec"] * 1000,
+		UsageInKernelmode: cpu["system_usec"] * 1000,
+	}
+	stats.CPUStats.ThrottlingData = types.ThrottlingData{
+		Periods:          cpu["nr_periods"],
+		ThrottledPeriods: cpu["nr_throttled"],
+		ThrottledTime:    cpu["throttled_usec"] * 1000,
+	}
+	stats.CPUStats.OnlineCPUs = uint32(runtime.NumCPU())
+	if stats.CPUStats.SystemUsage, err = c.readSystemUsage(); err != nil {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+
+	usage, _, err := readValue(filepath.Join(path, "memory.current"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+	stats.MemoryStats.Usage = usage
+	if stats.MemoryStats.Stats, err = readKeyValues(filepath.Join(path, "memory.stat")); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+	limit, ok, err := readValue(filepath.Join(path, "memory.max"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+	if !ok {
+		// as with Docker, the limit of a container without a memory limit is the memory of the host
+		if limit, err = c.readHostMemory(); err != nil {
+			return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+		}
+	}
+	stats.MemoryStats.Limit = limit
+
+	// the pids and io controllers may not be delegated to the cgroups of rootless containers
+	if current, _, err := readValue(filepath.Join(path, "pids.current")); err == nil {
+		stats.PidsStats.Current = current
+		if limit, ok, err := readValue(filepath.Join(path, "pids.max")); err == nil && ok {
+			stats.PidsStats.Limit = limit
+		}
+	}
+	if err := readIOStats(filepath.Join(path, "io.stat"), &stats.BlkioStats); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+	if stats.Networks, err = readNetworkStats(filepath.Join(c.procDir, strconv.Itoa(nc.State.Pid), "net", "dev")); err != nil {
+		return nil, errors.Wrapf(err, "Failed to get stats for %s", nc.ID)
+	}
+	return stats, nil
+}
+
+// readSystemUsage returns the CPU time of the host in nanoseconds, from the cpu line of /proc/stat, as with Docker
+func (c *NerdctlClient) readSystemUsage() (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(c.procDir, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 || fields[0] != "cpu" {
+			continue
+		}
+		var ticks uint64
+		// user, nice, system, idle, iowait, irq and softirq
+		for _, field := range fields[1:8] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid cpu line in %s: %s", filepath.Join(c.procDir, "stat"), line)
+			}
+			ticks += value
+		}
+		return ticks * 1e9 / clockTicksPerSecond, nil
+	}
+	return 0, fmt.Errorf("No cpu line in %s", filepath.Join(c.procDir, "stat"))
+}
+
+// readHostMemory returns the memory of the host in bytes, from /proc/meminfo
+func (c *NerdctlClient) readHostMemory() (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(c.procDir, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "MemTotal:" && fields[2] == "kB" {
+			kibibytes, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid MemTotal in %s: %s", filepath.Join(c.procDir, "meminfo"), line)
+			}
+			return kibibytes * 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("No MemTotal in %s", filepath.Join(c.procDir, "meminfo"))
+}
+
+// readValue reads a cgroup file with a single number, such as memory.current; ok is false if the value is max
+func readValue(path string) (value uint64, ok bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "max" {
+		return 0, false, nil
+	}
+	value, err = strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("Invalid value in %s: %s", path, text)
+	}
+	return value, true, nil
+}
+
+// readKeyValues reads a cgroup file with a key and a number on each line, such as cpu.stat
+func readKeyValues(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value of %s in %s: %s", fields[0], path, fields[1])
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+// readIOStats reads io.stat, which has a line for each device such as '8:0 rbytes=1024 wbytes=0 rios=1 wios=0',
+// into the read and write entries which Docker returns for cgroup v2
+func readIOStats(path string, blkio *types.BlkioStats) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		device := strings.SplitN(fields[0], ":", 2)
+		if len(device) != 2 {
+			return fmt.Errorf("Invalid device in %s: %s", path, fields[0])
+		}
+		major, err := strconv.ParseUint(device[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid device in %s: %s", path, fields[0])
+		}
+		minor, err := strconv.ParseUint(device[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid device in %s: %s", path, fields[0])
+		}
+		for _, field := range fields[1:] {
+			split := strings.SplitN(field, "=", 2)
+			if len(split) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(split[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("Invalid value of %s in %s: %s", split[0], path, split[1])
+			}
+			entry := types.BlkioStatEntry{Major: major, Minor: minor, Value: value}
+			switch split[0] {
+			case "rbytes":
+				entry.Op = "read"
+				blkio.IoServiceBytesRecursive = append(blkio.IoServiceBytesRecursive, entry)
+			case "wbytes":
+				entry.Op = "write"
+				blkio.IoServiceBytesRecursive = append(blkio.IoServiceBytesRecursive, entry)
+			case "rios":
+				entry.Op = "read"
+				blkio.IoServicedRecursive = append(blkio.IoServicedRecursive, entry)
+			case "wios":
+				entry.Op = "write"
+				blkio.IoServicedRecursive = append(blkio.IoServicedRecursive, entry)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// readNetworkStats reads the interfaces of a process's network namespace from /proc/<pid>/net/dev; the loopback
+// interface is left out, as with Docker
+func readNetworkStats(path string) (map[string]types.NetworkStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	networks := make(map[string]types.NetworkStats)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		split := strings.SplitN(scanner.Text(), ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(split[0])
+		fields := strings.Fields(split[1])
+		if name == "lo" || len(fields) < 12 {
+			continue
+		}
+		// the receive bytes, packets, errors and drops are the first fields, and the transmit ones are from the ninth
+		values := make([]uint64, 12)
+		for i := range values {
+			if values[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+				return nil, fmt.Errorf("Invalid stats of interface %s in %s", name, path)
+			}
+		}
+		networks[name] = types.NetworkStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+	return networks, scanner.Err()
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containerd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCgroupFiles(t *testing.T, files map[string]string) (string, func()) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		assert.NoError(t, err, "Unexpected error writing file")
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReadLimits(t *testing.T) {
+	dir, cleanup := writeCgroupFiles(t, map[string]string{
+		"cpu.max":    "150000 100000\n",
+		"cpu.weight": "10000\n",
+		"memory.max": "536870912\n",
+		"memory.low": "134217728\n",
+	})
+	defer cleanup()
+
+	resources, err := readLimits(dir)
+	assert.NoError(t, err, "Unexpected error reading limits")
+	assert.Equal(t, int64(1500000000), resources.NanoCPUs, "Expected 1.5 CPUs")
+	assert.Equal(t, int64(262144), resources.CPUShares, "Expected the most CPU shares for the highest weight")
+	assert.Equal(t, int64(512*1024*1024), resources.Memory, "Expected memory limit to match")
+	assert.Equal(t, int64(128*1024*1024), resources.MemoryReservation, "Expected memory reservation to match")
+}
+
+func TestReadLimitsUnlimited(t *testing.T) {
+	dir, cleanup := writeCgroupFiles(t, map[string]string{
+		"cpu.max":    "max 100000\n",
+		"cpu.weight": "100\n",
+		"memory.max": "max\n",
+		"memory.low": "0\n",
+	})
+	defer cleanup()
+
+	resources, err := readLimits(dir)
+	assert.NoError(t, err, "Unexpected error reading limits")
+	assert.Equal(t, int64(0), resources.NanoCPUs, "Expected no CPU limit")
+	assert.Equal(t, int64(0), resources.CPUShares, "Expected no CPU shares")
+	assert.Equal(t, int64(0), resources.Memory, "Expected no memory limit")
+	assert.Equal(t, int64(0), resources.MemoryReservation, "Expected no memory reservation")
+
+	dir, cleanup = writeCgroupFiles(t, map[string]string{"memory.max": "lots\n"})
+	defer cleanup()
+	_, err = readLimits(dir)
+	assert.Error(t, err, "Expected error for an invalid memory.max")
+}
+
+func TestReadIOStats(t *testing.T) {
+	dir, cleanup := writeCgroupFiles(t, map[string]string{
+		"io.stat": "8:0 rbytes=1024 wbytes=2048 rios=3 wios=4 dbytes=0 dios=0\n259:1 rbytes=10 wbytes=20 rios=1 wios=2\n",
+	})
+	defer cleanup()
+
+	var blkio types.BlkioStats
+	err := readIOStats(filepath.Join(dir, "io.stat"), &blkio)
+	assert.NoError(t, err, "Unexpected error reading io.stat")
+	assert.Equal(t, []types.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "read", Value: 1024},
+		{Major: 8, Minor: 0, Op: "write", Value: 2048},
+		{Major: 259, Minor: 1, Op: "read", Value: 10},
+		{Major: 259, Minor: 1, Op: "write", Value: 20},
+	}, blkio.IoServiceBytesRecursive, "Expected IO bytes to match")
+	assert.Equal(t, []types.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "read", Value: 3},
+		{Major: 8, Minor: 0, Op: "write", Value: 4},
+		{Major: 259, Minor: 1, Op: "read", Value: 1},
+		{Major: 259, Minor: 1, Op: "write", Value: 2},
+	}, blkio.IoServicedRecursive, "Expected IO operations to match")
+
+	err = ioutil.WriteFile(filepath.Join(dir, "io.stat"), []byte("sda rbytes=1\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing file")
+	err = readIOStats(filepath.Join(dir, "io.stat"), &types.BlkioStats{})
+	assert.Error(t, err, "Expected error for an invalid device")
+}
+
+func TestReadKeyValues(t *testing.T) {
+	dir, cleanup := writeCgroupFiles(t, map[string]string{
+		"cpu.stat":    "usage_usec 10\nuser_usec 7\n",
+		"memory.stat": "anon 1\nfile x\n",
+	})
+	defer cleanup()
+
+	values, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	assert.NoError(t, err, "Unexpected error reading cpu.stat")
+	assert.Equal(t, map[string]uint64{"usage_usec": 10, "user_usec": 7}, values, "Expected values to match")
+
+	_, err = readKeyValues(filepath.Join(dir, "memory.stat"))
+	assert.Error(t, err, "Expected error for an invalid value")
+
+	_, err = readKeyValues(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err), "Expected a not exist error for a missing file")
+}
@@ -0,0 +1,310 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package containerd includes a Docker Client for containerd, which lists and inspects containers with nerdctl
+// and reads their stats from cgroup v2, for hosts such as Lima which have no Docker compatible API
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	procDir   = "/proc"
+	cgroupDir = "/sys/fs/cgroup"
+	// statsInterval is how often stats are sampled, as with the Docker API
+	statsInterval = time.Second
+)
+
+// IsEnabled is whether the containers are taken from containerd, since the containerd namespace is set
+func IsEnabled() bool {
+	return os.Getenv(config.ContainerdNamespaceVar) != ""
+}
+
+// NerdctlClient is a Docker Client for the containers in a containerd namespace, which uses the nerdctl CLI,
+// since containerd has no Docker compatible API. It doesn't watch containerd events, so each request lists the
+// containers.
+type NerdctlClient struct {
+	namespace string
+	// run runs nerdctl with the arguments and returns its output
+	run           func(ctx context.Context, args ...string) ([]byte, error)
+	procDir       string
+	cgroupDir     string
+	statsInterval time.Duration
+}
+
+// NewNerdctlClient creates a Docker Client for the containers in the containerd namespace from the environment
+func NewNerdctlClient() (*NerdctlClient, error) {
+	namespace := os.Getenv(config.ContainerdNamespaceVar)
+	if namespace == "" {
+		return nil, fmt.Errorf("Invalid %s: the containerd namespace is not set", config.ContainerdNamespaceVar)
+	}
+	path, err := exec.LookPath(utils.GetValue(config.DefaultNerdctlPath, config.NerdctlPathVar))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid %s: nerdctl was not found; install nerdctl, or set its path", config.NerdctlPathVar)
+	}
+	logrus.Infof("Using the containers in containerd namespace %s with %s", namespace, path)
+
+	return &NerdctlClient{
+		namespace: namespace,
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			return runNerdctl(ctx, path, namespace, args)
+		},
+		procDir:       procDir,
+		cgroupDir:     cgroupDir,
+		statsInterval: statsInterval,
+	}, nil
+}
+
+func runNerdctl(ctx context.Context, path, namespace string, args []string) ([]byte, error) {
+	args = append([]string{"--namespace", namespace}, args...)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to run nerdctl %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// nerdctlContainer is the part of the Docker compatible output of nerdctl inspect which metadata is synthesized from
+type nerdctlContainer struct {
+	ID      string `json:"Id"`
+	Created string
+	Name    string
+	Image   string
+	State   *types.ContainerState
+	Mounts  []types.MountPoint
+	Config  *struct {
+		Labels map[string]string
+	}
+	NetworkSettings *struct {
+		Networks map[string]*network.EndpointSettings
+	}
+}
+
+// ContainerList returns the running containers of the namespace
+func (c *NerdctlClient) ContainerList(ctx context.Context) ([]types.Container, error) {
+	output, err := c.run(ctx, "ps", "--quiet", "--no-trunc")
+	if err != nil {
+		return nil, err
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	inspected, err := c.inspect(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []types.Container
+	for i := range inspected {
+		nc := &inspected[i]
+		// a container can stop between the two commands
+		if nc.State == nil || !nc.State.Running {
+			continue
+		}
+		var created int64
+		if createdAt, err := time.Parse(time.RFC3339Nano, nc.Created); err == nil {
+			created = createdAt.Unix()
+		}
+		containers = append(containers, types.Container{
+			ID:      nc.ID,
+			Names:   []string{getName(nc)},
+			Image:   nc.Image,
+			Created: created,
+			Labels:  getLabels(nc),
+			State:   nc.State.Status,
+			Status:  "Up",
+			Mounts:  nc.Mounts,
+			NetworkSettings: &types.SummaryNetworkSettings{
+				Networks: getNetworks(nc),
+			},
+		})
+	}
+	return containers, nil
+}
+
+// ContainerInspect returns the state, network, and CPU and memory limits of a container; the limits are read
+// from its cgroup
+func (c *NerdctlClient) ContainerInspect(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	nc, err := c.inspectOne(ctx, longContainerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources container.Resources
+	if nc.State != nil && nc.State.Running {
+		if path, err := c.getCgroupPath(nc.State.Pid); err != nil {
+			logrus.Debugf("Failed to read the limits of container %s: %s", longContainerID, err)
+		} else if resources, err = readLimits(path); err != nil {
+			logrus.Debugf("Failed to read the limits of container %s: %s", longContainerID, err)
+		}
+	}
+	return &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:      nc.ID,
+			Created: nc.Created,
+			Name:    getName(nc),
+			Image:   nc.Image,
+			State:   nc.State,
+			HostConfig: &container.HostConfig{
+				Resources: resources,
+			},
+		},
+		Mounts: nc.Mounts,
+		Config: &container.Config{
+			Image:  nc.Image,
+			Labels: getLabels(nc),
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: getNetworks(nc),
+		},
+	}, nil
+}
+
+// ContainerInspectWithSize returns the same as ContainerInspect, since nerdctl does not report the size of the
+// writable layer
+func (c *NerdctlClient) ContainerInspectWithSize(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	return c.ContainerInspect(ctx, longContainerID)
+}
+
+// ContainerStats returns a stats sample of a container from its cgroup, with the CPU usage of the previous
+// sample, which is taken one interval earlier, as with docker stats
+func (c *NerdctlClient) ContainerStats(ctx context.Context, longContainerID string) (*types.StatsJSON, error) {
+	nc, path, err := c.getStatsTarget(ctx, longContainerID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := c.readStats(nc, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-time.After(c.statsInterval):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return c.readStats(nc, path, previous)
+}
+
+// ContainerStatsStream sends a stats sample of the container on statsChan each interval.
+// It blocks until the container stops or the context is cancelled.
+func (c *NerdctlClient) ContainerStatsStream(ctx context.Context, longContainerID string, statsChan chan<- *types.StatsJSON) error {
+	nc, path, err := c.getStatsTarget(ctx, longContainerID)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(c.statsInterval)
+	defer ticker.Stop()
+
+	var previous *types.StatsJSON
+	for {
+		stats, err := c.readStats(nc, path, previous)
+		if err != nil {
+			// the cgroup is removed when the container stops, which ends the stream
+			if os.IsNotExist(errors.Cause(err)) {
+				return nil
+			}
+			return err
+		}
+		select {
+		case statsChan <- stats:
+		case <-ctx.Done():
+			return nil
+		}
+		previous = stats
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// getStatsTarget inspects a running container for the name and cgroup of its stats
+func (c *NerdctlClient) getStatsTarget(ctx context.Context, longContainerID string) (*nerdctlContainer, string, error) {
+	nc, err := c.inspectOne(ctx, longContainerID)
+	if err != nil {
+		return nil, "", err
+	}
+	if nc.State == nil || !nc.State.Running || nc.State.Pid == 0 {
+		return nil, "", fmt.Errorf("Failed to get stats for %s: the container is not running", longContainerID)
+	}
+	path, err := c.getCgroupPath(nc.State.Pid)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to get stats for %s", longContainerID)
+	}
+	return nc, path, nil
+}
+
+func (c *NerdctlClient) inspectOne(ctx context.Context, longContainerID string) (*nerdctlContainer, error) {
+	inspected, err := c.inspect(ctx, longContainerID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to inspect container %s", longContainerID)
+	}
+	if len(inspected) != 1 {
+		return nil, fmt.Errorf("No container with ID %s in containerd namespace %s", longContainerID, c.namespace)
+	}
+	return &inspected[0], nil
+}
+
+func (c *NerdctlClient) inspect(ctx context.Context, ids ...string) ([]nerdctlContainer, error) {
+	args := append([]string{"container", "inspect", "--mode", "dockercompat"}, ids...)
+	output, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var inspected []nerdctlContainer
+	if err := json.Unmarshal(output, &inspected); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse the output of nerdctl inspect")
+	}
+	return inspected, nil
+}
+
+// getName returns the name of a container with a leading slash, as with the Docker API
+func getName(nc *nerdctlContainer) string {
+	return "/" + strings.TrimPrefix(nc.Name, "/")
+}
+
+// getLabels returns the labels of a container; nerdctl compose sets the same Compose labels as docker compose
+func getLabels(nc *nerdctlContainer) map[string]string {
+	if nc.Config == nil {
+		return nil
+	}
+	return nc.Config.Labels
+}
+
+func getNetworks(nc *nerdctlContainer) map[string]*network.EndpointSettings {
+	if nc.NetworkSettings == nil {
+		return nil
+	}
+	return nc.NetworkSettings.Networks
+}
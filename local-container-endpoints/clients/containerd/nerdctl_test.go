@@ -0,0 +1,278 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	appID     = "4b9d1a3c0e7f8a2b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b"
+	stoppedID = "9f8e7d6c5b4a39281706f5e4d3c2b1a0f9e8d7c6b5a493827160f5e4d3c2b1a0"
+	appCgroup = "/user.slice/user-501.slice/user@501.service/nerdctl-4b9d1a3c0e7f.scope"
+	appJSON   = `{
+  "Id": "4b9d1a3c0e7f8a2b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b",
+  "Created": "2019-03-01T12:00:00.123456789Z",
+  "Name": "project-app-1",
+  "Image": "docker.io/library/my-app:latest",
+  "Platform": "linux",
+  "State": {"Status": "running", "Running": true, "Pid": 4242, "StartedAt": "2019-03-01T12:00:01Z"},
+  "Mounts": [{"Type": "bind", "Source": "/home/me/data", "Destination": "/data", "RW": true}],
+  "Config": {"Hostname": "4b9d1a3c0e7f", "Labels": {"com.docker.compose.project": "project", "com.docker.compose.service": "app"}},
+  "NetworkSettings": {"Networks": {"project_default": {"IPAddress": "10.4.0.2", "IPPrefixLen": 24, "MacAddress": "02:42:0a:04:00:02"}}},
+  "HostConfig": {"CpuQuota": 50000, "CpuPeriod": 100000}
+}`
+	stoppedJSON = `{
+  "Id": "9f8e7d6c5b4a39281706f5e4d3c2b1a0f9e8d7c6b5a493827160f5e4d3c2b1a0",
+  "Created": "2019-03-01T12:00:00Z",
+  "Name": "project-init-1",
+  "State": {"Status": "exited", "Running": false, "ExitCode": 0}
+}`
+	netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    2048      16    1    2    0     0          0         0     1024       8    3    4    0     0       0          0
+`
+)
+
+// newNerdctlClientInTest creates a client whose nerdctl returns the containers, and whose proc and cgroup
+// directories have the app container's process and cgroup v2
+func newNerdctlClientInTest(t *testing.T, containers map[string]string) (*NerdctlClient, string, func()) {
+	dir, err := ioutil.TempDir("", "containerd")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	files := map[string]string{
+		"proc/stat":                              "cpu  100 0 50 850 0 0 0 0 0 0\ncpu0 100 0 50 850 0 0 0 0 0 0\n",
+		"proc/meminfo":                           "MemTotal:        8048836 kB\nMemFree:         1048576 kB\n",
+		"proc/4242/cgroup":                       "0::" + appCgroup + "\n",
+		"proc/4242/net/dev":                      netDev,
+		"cgroup" + appCgroup + "/cpu.stat":       "usage_usec 2000\nuser_usec 1500\nsystem_usec 500\nnr_periods 10\nnr_throttled 2\nthrottled_usec 300\n",
+		"cgroup" + appCgroup + "/cpu.max":        "50000 100000\n",
+		"cgroup" + appCgroup + "/cpu.weight":     "100\n",
+		"cgroup" + appCgroup + "/memory.current": "4194304\n",
+		"cgroup" + appCgroup + "/memory.max":     "max\n",
+		"cgroup" + appCgroup + "/memory.stat":    "anon 3145728\nfile 1048576\n",
+		"cgroup" + appCgroup + "/pids.current":   "3\n",
+		"cgroup" + appCgroup + "/pids.max":       "max\n",
+		"cgroup" + appCgroup + "/io.stat":        "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		assert.NoError(t, err, "Unexpected error creating directory")
+		err = ioutil.WriteFile(path, []byte(content), 0600)
+		assert.NoError(t, err, "Unexpected error writing file")
+	}
+
+	client := &NerdctlClient{
+		namespace: "default",
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			if args[0] == "ps" {
+				assert.Equal(t, []string{"ps", "--quiet", "--no-trunc"}, args, "Expected full container IDs")
+				var ids []string
+				for id := range containers {
+					ids = append(ids, id)
+				}
+				return []byte(strings.Join(ids, "\n") + "\n"), nil
+			}
+			assert.Equal(t, []string{"container", "inspect", "--mode", "dockercompat"}, args[:4], "Expected Docker compatible output")
+			var inspected []string
+			for _, id := range args[4:] {
+				if container, ok := containers[id]; ok {
+					inspected = append(inspected, container)
+				}
+			}
+			if len(inspected) == 0 {
+				return nil, fmt.Errorf("Failed to run nerdctl: no such container")
+			}
+			return []byte("[" + strings.Join(inspected, ",") + "]"), nil
+		},
+		procDir:       filepath.Join(dir, "proc"),
+		cgroupDir:     filepath.Join(dir, "cgroup"),
+		statsInterval: 10 * time.Millisecond,
+	}
+	return client, dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNerdctlClientContainerList(t *testing.T) {
+	client, _, cleanup := newNerdctlClientInTest(t, map[string]string{appID: appJSON, stoppedID: stoppedJSON})
+	defer cleanup()
+
+	containers, err := client.ContainerList(context.Background())
+	assert.NoError(t, err, "Unexpected error listing containers")
+	assert.Len(t, containers, 1, "Expected only the running app container")
+	app := containers[0]
+	assert.Equal(t, appID, app.ID, "Expected ID to match")
+	assert.Equal(t, []string{"/project-app-1"}, app.Names, "Expected the name with a leading slash")
+	assert.Equal(t, "docker.io/library/my-app:latest", app.Image, "Expected image to match")
+	assert.Equal(t, time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC).Unix(), app.Created, "Expected creation time to match")
+	assert.Equal(t, "running", app.State, "Expected state to match")
+	assert.Equal(t, "project", app.Labels["com.docker.compose.project"], "Expected the Compose labels")
+	assert.Equal(t, "10.4.0.2", app.NetworkSettings.Networks["project_default"].IPAddress, "Expected the container IP")
+	assert.Len(t, app.Mounts, 1, "Expected the bind mount")
+	assert.Equal(t, "/data", app.Mounts[0].Destination, "Expected mount to match")
+}
+
+func TestNerdctlClientContainerListEmpty(t *testing.T) {
+	client, _, cleanup := newNerdctlClientInTest(t, map[string]string{})
+	defer cleanup()
+
+	containers, err := client.ContainerList(context.Background())
+	assert.NoError(t, err, "Unexpected error listing containers")
+	assert.Empty(t, containers, "Expected no containers")
+}
+
+func TestNerdctlClientContainerInspect(t *testing.T) {
+	client, dir, cleanup := newNerdctlClientInTest(t, map[string]string{appID: appJSON, stoppedID: stoppedJSON})
+	defer cleanup()
+	err := ioutil.WriteFile(filepath.Join(dir, "cgroup", appCgroup, "memory.max"), []byte("268435456\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing file")
+
+	details, err := client.ContainerInspect(context.Background(), appID)
+	assert.NoError(t, err, "Unexpected error inspecting container")
+	assert.Equal(t, "/project-app-1", details.Name, "Expected name to match")
+	assert.True(t, details.State.Running, "Expected container to be running")
+	assert.Equal(t, "2019-03-01T12:00:01Z", details.State.StartedAt, "Expected start time to match")
+	assert.Equal(t, "docker.io/library/my-app:latest", details.Config.Image, "Expected image to match")
+	assert.Equal(t, int64(500000000), details.HostConfig.NanoCPUs, "Expected the CPU limit from cpu.max")
+	assert.Equal(t, int64(0), details.HostConfig.CPUShares, "Expected no CPU shares for the default weight")
+	assert.Equal(t, int64(256*1024*1024), details.HostConfig.Memory, "Expected the memory limit from memory.max")
+	assert.Equal(t, "10.4.0.2", details.NetworkSettings.Networks["project_default"].IPAddress, "Expected the container IP")
+
+	details, err = client.ContainerInspect(context.Background(), stoppedID)
+	assert.NoError(t, err, "Unexpected error inspecting a stopped container")
+	assert.Equal(t, "exited", details.State.Status, "Expected state to match")
+
+	_, err = client.ContainerInspect(context.Background(), "unknown")
+	assert.Error(t, err, "Expected error for an unknown container ID")
+}
+
+func TestNerdctlClientContainerStats(t *testing.T) {
+	client, _, cleanup := newNerdctlClientInTest(t, map[string]string{appID: appJSON, stoppedID: stoppedJSON})
+	defer cleanup()
+
+	stats, err := client.ContainerStats(context.Background(), appID)
+	assert.NoError(t, err, "Unexpected error getting stats")
+	assert.Equal(t, "/project-app-1", stats.Name, "Expected name to match")
+	assert.Equal(t, appID, stats.ID, "Expected ID to match")
+	assert.False(t, stats.PreRead.IsZero(), "Expected a previous sample")
+	assert.True(t, stats.PreRead.Before(stats.Read), "Expected the previous sample to be earlier")
+	assert.Equal(t, uint64(2000000), stats.PreCPUStats.CPUUsage.TotalUsage, "Expected the CPU usage of the previous sample")
+
+	assert.Equal(t, types.CPUUsage{TotalUsage: 2000000, UsageInUsermode: 1500000, UsageInKernelmode: 500000}, stats.CPUStats.CPUUsage, "Expected the CPU usage in nanoseconds")
+	assert.Equal(t, types.ThrottlingData{Periods: 10, ThrottledPeriods: 2, ThrottledTime: 300000}, stats.CPUStats.ThrottlingData, "Expected throttling to match")
+	assert.Equal(t, uint64(10000000000), stats.CPUStats.SystemUsage, "Expected the host CPU time in nanoseconds")
+	assert.Equal(t, uint64(4194304), stats.MemoryStats.Usage, "Expected memory usage to match")
+	assert.Equal(t, uint64(8048836*1024), stats.MemoryStats.Limit, "Expected the memory of the host without a memory limit")
+	assert.Equal(t, uint64(3145728), stats.MemoryStats.Stats["anon"], "Expected memory.stat to match")
+	assert.Equal(t, types.PidsStats{Current: 3}, stats.PidsStats, "Expected pids to match")
+	assert.Equal(t, []types.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "read", Value: 4096},
+		{Major: 8, Minor: 0, Op: "write", Value: 8192},
+	}, stats.BlkioStats.IoServiceBytesRecursive, "Expected IO bytes to match")
+	assert.Equal(t, map[string]types.NetworkStats{
+		"eth0": {RxBytes: 2048, RxPackets: 16, RxErrors: 1, RxDropped: 2, TxBytes: 1024, TxPackets: 8, TxErrors: 3, TxDropped: 4},
+	}, stats.Networks, "Expected the interfaces other than loopback")
+
+	_, err = client.ContainerStats(context.Background(), stoppedID)
+	assert.Error(t, err, "Expected error for a stopped container")
+}
+
+func TestNerdctlClientContainerStatsCgroupV1(t *testing.T) {
+	client, dir, cleanup := newNerdctlClientInTest(t, map[string]string{appID: appJSON})
+	defer cleanup()
+	err := ioutil.WriteFile(filepath.Join(dir, "proc", "4242", "cgroup"), []byte("12:memory:/docker/4b9d\n11:cpu,cpuacct:/docker/4b9d\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing file")
+
+	_, err = client.ContainerStats(context.Background(), appID)
+	assert.Error(t, err, "Expected error without cgroup v2")
+	assert.Contains(t, err.Error(), "cgroup v2", "Expected the message to name cgroup v2")
+}
+
+func TestNerdctlClientContainerStatsStream(t *testing.T) {
+	client, dir, cleanup := newNerdctlClientInTest(t, map[string]string{appID: appJSON})
+	defer cleanup()
+
+	statsChan := make(chan *types.StatsJSON)
+	done := make(chan error)
+	go func() {
+		done <- client.ContainerStatsStream(context.Background(), appID, statsChan)
+	}()
+	first := <-statsChan
+	assert.True(t, first.PreRead.IsZero(), "Expected no previous sample for the first one")
+	second := <-statsChan
+	assert.Equal(t, first.Read, second.PreRead, "Expected the previous sample to be the first one")
+
+	// the stream ends once the container stops and its cgroup is removed
+	err := os.RemoveAll(filepath.Join(dir, "cgroup", appCgroup))
+	assert.NoError(t, err, "Unexpected error removing cgroup")
+	for {
+		select {
+		case <-statsChan:
+			continue
+		case err := <-done:
+			assert.NoError(t, err, "Expected the stream to end without an error")
+		}
+		break
+	}
+}
+
+func TestNerdctlClientError(t *testing.T) {
+	client := &NerdctlClient{
+		namespace: "default",
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			return nil, fmt.Errorf("Failed to run nerdctl ps: cannot access containerd socket")
+		},
+	}
+
+	_, err := client.ContainerList(context.Background())
+	assert.Error(t, err, "Expected error when nerdctl fails")
+	assert.Contains(t, err.Error(), "containerd socket", "Expected the message of nerdctl")
+}
+
+func TestNewNerdctlClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nerdctl")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nerdctl")
+	err = ioutil.WriteFile(path, []byte("#!/bin/sh\necho \"$@\"\n"), 0700)
+	assert.NoError(t, err, "Unexpected error writing file")
+
+	os.Setenv("ECS_LOCAL_CONTAINERD_NAMESPACE", "k8s.io")
+	os.Setenv("ECS_LOCAL_NERDCTL_PATH", path)
+	defer os.Unsetenv("ECS_LOCAL_CONTAINERD_NAMESPACE")
+	defer os.Unsetenv("ECS_LOCAL_NERDCTL_PATH")
+
+	assert.True(t, IsEnabled(), "Expected containerd to be enabled by the namespace")
+	client, err := NewNerdctlClient()
+	assert.NoError(t, err, "Unexpected error creating client")
+	output, err := client.run(context.Background(), "ps", "--quiet")
+	assert.NoError(t, err, "Unexpected error running nerdctl")
+	assert.Equal(t, "--namespace k8s.io ps --quiet\n", string(output), "Expected the namespace to be passed to nerdctl")
+
+	os.Setenv("ECS_LOCAL_NERDCTL_PATH", filepath.Join(dir, "missing"))
+	_, err = NewNerdctlClient()
+	assert.Error(t, err, "Expected error when nerdctl is not found")
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package credentialprocess runs the credential_process of a shared config profile
+package credentialprocess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProviderName is the name of the credential process provider
+	ProviderName = "CredentialProcessProvider"
+
+	defaultTimeout = time.Minute
+)
+
+// Provider runs the credential process to obtain credentials.
+// Unlike the AWS SDK, which always runs the command with 'sh -c', the command is run
+// directly when no shell is available, as is the case in the Local Endpoints image.
+type Provider struct {
+	credentials.Expiry
+
+	command string
+	timeout time.Duration
+	// static is set if the process returned credentials without an expiration
+	static bool
+}
+
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      *time.Time
+}
+
+// NewProvider returns a provider which runs the command
+func NewProvider(command string) *Provider {
+	return &Provider{
+		command: command,
+		timeout: defaultTimeout,
+	}
+}
+
+// Retrieve satisfies the credentials.Provider interface
+func (p *Provider) Retrieve() (credentials.Value, error) {
+	args, err := p.commandArgs()
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrapf(err, "failed to run credential_process '%s'", p.command)
+	}
+
+	output := &credentialProcessOutput{}
+	if err := json.Unmarshal(stdout.Bytes(), output); err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrap(err, "failed to parse the output of credential_process")
+	}
+	if output.Version != 1 {
+		return credentials.Value{ProviderName: ProviderName}, fmt.Errorf("credential_process returned unsupported version %d; expected 1", output.Version)
+	}
+	if output.AccessKeyID == "" || output.SecretAccessKey == "" {
+		return credentials.Value{ProviderName: ProviderName}, fmt.Errorf("credential_process did not return an AccessKeyId and SecretAccessKey")
+	}
+
+	p.static = output.Expiration == nil
+	if output.Expiration != nil {
+		p.SetExpiration(*output.Expiration, 0)
+	}
+
+	return credentials.Value{
+		AccessKeyID:     output.AccessKeyID,
+		SecretAccessKey: output.SecretAccessKey,
+		SessionToken:    output.SessionToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
+
+// IsExpired satisfies the credentials.Provider interface
+func (p *Provider) IsExpired() bool {
+	if p.static {
+		return false
+	}
+	return p.Expiry.IsExpired()
+}
+
+func (p *Provider) commandArgs() ([]string, error) {
+	if strings.TrimSpace(p.command) == "" {
+		return nil, fmt.Errorf("credential_process is empty")
+	}
+	if shell, err := exec.LookPath("sh"); err == nil {
+		return []string{shell, "-c", p.command}, nil
+	}
+	return splitCommand(p.command)
+}
+
+// splitCommand splits the command line into arguments the way a shell would,
+// handling single quotes, double quotes, and backslash escapes.
+// Other shell features, such as pipes and variable expansion, are not supported.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, char := range command {
+		switch {
+		case escaped:
+			current.WriteRune(char)
+			escaped = false
+		case char == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			} else {
+				current.WriteRune(char)
+			}
+		case char == '\'' || char == '"':
+			quote = char
+			inArg = true
+		case char == ' ' || char == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(char)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("credential_process '%s' has an unterminated quote or escape", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
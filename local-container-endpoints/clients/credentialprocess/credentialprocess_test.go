@@ -0,0 +1,105 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package credentialprocess
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommand(t *testing.T) {
+	var testCases = []struct {
+		command  string
+		expected []string
+		isErr    bool
+	}{
+		{"/bin/creds", []string{"/bin/creds"}, false},
+		{"/bin/creds --profile  dev", []string{"/bin/creds", "--profile", "dev"}, false},
+		{`/bin/creds --name "my role" --tag 'a "b"'`, []string{"/bin/creds", "--name", "my role", "--tag", `a "b"`}, false},
+		{`/bin/creds my\ role ""`, []string{"/bin/creds", "my role", ""}, false},
+		{`/bin/creds "unterminated`, nil, true},
+		{`/bin/creds \`, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := splitCommand(testCase.command)
+		if testCase.isErr {
+			assert.Error(t, err, "Expected error for %s", testCase.command)
+		} else {
+			assert.NoError(t, err, "Unexpected error for %s", testCase.command)
+			assert.Equal(t, testCase.expected, actual, "Expected arguments to match for %s", testCase.command)
+		}
+	}
+}
+
+func writeScript(t *testing.T, output string) (string, string) {
+	dir, err := ioutil.TempDir("", "credentialprocess")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	script := filepath.Join(dir, "creds.sh")
+	err = ioutil.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%s\nEOF\n", output)), 0700)
+	assert.NoError(t, err, "Unexpected error writing script")
+	return dir, script
+}
+
+func TestProviderRetrieve(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	dir, script := writeScript(t, fmt.Sprintf(`{"Version": 1, "AccessKeyId": "AKID", "SecretAccessKey": "SKID", "SessionToken": "token", "Expiration": "%s"}`,
+		expiration.Format(time.RFC3339)))
+	defer os.RemoveAll(dir)
+
+	provider := NewProvider(script)
+	creds, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error retrieving credentials")
+	assert.Equal(t, "AKID", creds.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, "SKID", creds.SecretAccessKey, "Expected secret key to match")
+	assert.Equal(t, "token", creds.SessionToken, "Expected session token to match")
+	assert.True(t, expiration.Equal(provider.ExpiresAt()), "Expected expiration to match")
+	assert.False(t, provider.IsExpired(), "Expected credentials to not be expired")
+}
+
+func TestProviderRetrieveStaticCredentials(t *testing.T) {
+	dir, script := writeScript(t, `{"Version": 1, "AccessKeyId": "AKID", "SecretAccessKey": "SKID"}`)
+	defer os.RemoveAll(dir)
+
+	provider := NewProvider(script)
+	_, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error retrieving credentials")
+	assert.False(t, provider.IsExpired(), "Expected credentials without an expiration to never expire")
+}
+
+func TestProviderRetrieveInvalidOutput(t *testing.T) {
+	var testCases = []string{
+		`not json`,
+		`{"Version": 2, "AccessKeyId": "AKID", "SecretAccessKey": "SKID"}`,
+		`{"Version": 1, "AccessKeyId": "AKID"}`,
+	}
+
+	for _, output := range testCases {
+		dir, script := writeScript(t, output)
+		_, err := NewProvider(script).Retrieve()
+		assert.Error(t, err, "Expected error for output %s", output)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestProviderRetrieveCommandFails(t *testing.T) {
+	_, err := NewProvider("/does/not/exist").Retrieve()
+	assert.Error(t, err, "Expected error for a command which does not exist")
+}
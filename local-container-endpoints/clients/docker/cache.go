@@ -0,0 +1,157 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// fetchTimeout is how long requests to the Docker API for the cache can take, like the requests of the metadata handlers
+const fetchTimeout = 5 * time.Second
+
+// CachedClient is a Client which caches the results of docker inspect and docker stats for each container, so that
+// many containers polling the metadata endpoints make at most one request per container to the Docker API in each TTL.
+// Concurrent requests for the same container wait for the one request in progress. Errors are not cached.
+type CachedClient struct {
+	Client
+	ttl time.Duration
+	// timeout limits each request to the Docker API, which is independent of the requests which wait for it
+	timeout time.Duration
+	now     func() time.Time
+
+	lock    sync.Mutex
+	stats   map[string]*cacheEntry
+	inspect map[string]*cacheEntry
+	// inspectWithSize is cached separately, since the size is only requested for task metadata
+	inspectWithSize map[string]*cacheEntry
+}
+
+// cacheEntry is a result of the Docker API; done is closed once value and err are set
+type cacheEntry struct {
+	done      chan struct{}
+	value     interface{}
+	err       error
+	expiresAt time.Time
+}
+
+// NewCachedClient creates a Client which caches the stats and inspect results of client for the TTL
+func NewCachedClient(client Client, ttl time.Duration) *CachedClient {
+	return &CachedClient{
+		Client:          client,
+		ttl:             ttl,
+		timeout:         fetchTimeout,
+		now:             time.Now,
+		stats:           make(map[string]*cacheEntry),
+		inspect:         make(map[string]*cacheEntry),
+		inspectWithSize: make(map[string]*cacheEntry),
+	}
+}
+
+// ContainerStats returns the last stats sample of the container, or else gets a new sample from the Docker API
+func (c *CachedClient) ContainerStats(ctx context.Context, longContainerID string) (*types.StatsJSON, error) {
+	value, err := c.get(ctx, c.stats, longContainerID, func(fetchCtx context.Context) (interface{}, error) {
+		return c.Client.ContainerStats(fetchCtx, longContainerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*types.StatsJSON), nil
+}
+
+// ContainerInspect returns the last docker inspect output of the container, or else inspects it with the Docker API
+func (c *CachedClient) ContainerInspect(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	value, err := c.get(ctx, c.inspect, longContainerID, func(fetchCtx context.Context) (interface{}, error) {
+		return c.Client.ContainerInspect(fetchCtx, longContainerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*types.ContainerJSON), nil
+}
+
+// ContainerInspectWithSize returns the last docker inspect output of the container with the size of its writable
+// layer, or else inspects it with the Docker API
+func (c *CachedClient) ContainerInspectWithSize(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	value, err := c.get(ctx, c.inspectWithSize, longContainerID, func(fetchCtx context.Context) (interface{}, error) {
+		return c.Client.ContainerInspectWithSize(fetchCtx, longContainerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*types.ContainerJSON), nil
+}
+
+// get returns the cached result for the container, or else starts a request to the Docker API, which every request
+// for the container waits for. The request is not cancelled with the context of any caller, so that one caller
+// which goes away does not fail the others; each caller stops waiting once its own context is done.
+func (c *CachedClient) get(ctx context.Context, entries map[string]*cacheEntry, containerID string, fetch func(context.Context) (interface{}, error)) (interface{}, error) {
+	c.lock.Lock()
+	entry, ok := entries[containerID]
+	if ok && !inProgress(entry) && !c.now().Before(entry.expiresAt) {
+		ok = false
+	}
+	if !ok {
+		entry = &cacheEntry{done: make(chan struct{})}
+		entries[containerID] = entry
+		go c.fetch(entries, containerID, entry, fetch)
+	}
+	c.lock.Unlock()
+
+	select {
+	case <-entry.done:
+		return entry.value, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch sets the result of the entry, with a timeout of its own; errors are not cached
+func (c *CachedClient) fetch(entries map[string]*cacheEntry, containerID string, entry *cacheEntry, fetch func(context.Context) (interface{}, error)) {
+	fetchCtx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	value, err := fetch(fetchCtx)
+
+	c.lock.Lock()
+	entry.value, entry.err = value, err
+	entry.expiresAt = c.now().Add(c.ttl)
+	if entry.err != nil && entries[containerID] == entry {
+		delete(entries, containerID)
+	}
+	c.removeExpired(entries)
+	c.lock.Unlock()
+	close(entry.done)
+}
+
+// removeExpired removes the entries of containers which are no longer requested, such as stopped containers
+func (c *CachedClient) removeExpired(entries map[string]*cacheEntry) {
+	now := c.now()
+	for containerID, entry := range entries {
+		if !inProgress(entry) && now.After(entry.expiresAt.Add(c.ttl)) {
+			delete(entries, containerID)
+		}
+	}
+}
+
+func inProgress(entry *cacheEntry) bool {
+	select {
+	case <-entry.done:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const cachedContainerID = "2b7ac55d4470a0b9ec1e6afd5f503e5989d82d6c3a0c8e1d5a1f74ea5e1c3c6f"
+
+func newTestCachedClient(ctrl *gomock.Controller) (*CachedClient, *mock_docker.MockClient, *time.Time) {
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	client := NewCachedClient(dockerMock, time.Second)
+	now := time.Now()
+	client.now = func() time.Time {
+		return now
+	}
+	return client, dockerMock, &now
+}
+
+func TestCachedClientStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, now := newTestCachedClient(ctrl)
+
+	first := &types.StatsJSON{Stats: types.Stats{Read: *now}}
+	second := &types.StatsJSON{Stats: types.Stats{Read: now.Add(time.Second)}}
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerStats(gomock.Any(), cachedContainerID).Return(first, nil),
+		dockerMock.EXPECT().ContainerStats(gomock.Any(), cachedContainerID).Return(second, nil),
+	)
+
+	stats, err := client.ContainerStats(context.Background(), cachedContainerID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, first, stats, "Expected the stats from Docker")
+
+	*now = now.Add(500 * time.Millisecond)
+	stats, err = client.ContainerStats(context.Background(), cachedContainerID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, first, stats, "Expected the cached stats within the TTL")
+
+	*now = now.Add(time.Second)
+	stats, err = client.ContainerStats(context.Background(), cachedContainerID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, second, stats, "Expected new stats after the TTL")
+}
+
+func TestCachedClientInspect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, _ := newTestCachedClient(ctrl)
+
+	otherID := "5f1c6fe0d4a88c1c2d163c4f9f6d0a0b3e8de3482ab3a6a2f00c1b8e6a5a2d1b"
+	details := &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: cachedContainerID}}
+	otherDetails := &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: otherID}}
+	dockerMock.EXPECT().ContainerInspect(gomock.Any(), cachedContainerID).Return(details, nil).Times(1)
+	dockerMock.EXPECT().ContainerInspect(gomock.Any(), otherID).Return(otherDetails, nil).Times(1)
+
+	for i := 0; i < 3; i++ {
+		inspected, err := client.ContainerInspect(context.Background(), cachedContainerID)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, details, inspected, "Expected the docker inspect output of the container")
+		inspected, err = client.ContainerInspect(context.Background(), otherID)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, otherDetails, inspected, "Expected each container to be cached separately")
+	}
+}
+
+func TestCachedClientInspectWithSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, _ := newTestCachedClient(ctrl)
+
+	size := int64(1024)
+	details := &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: cachedContainerID}}
+	detailsWithSize := &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: cachedContainerID, SizeRw: &size}}
+	dockerMock.EXPECT().ContainerInspect(gomock.Any(), cachedContainerID).Return(details, nil).Times(1)
+	dockerMock.EXPECT().ContainerInspectWithSize(gomock.Any(), cachedContainerID).Return(detailsWithSize, nil).Times(1)
+
+	for i := 0; i < 3; i++ {
+		inspected, err := client.ContainerInspect(context.Background(), cachedContainerID)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, details, inspected, "Expected the docker inspect output without the size")
+		inspected, err = client.ContainerInspectWithSize(context.Background(), cachedContainerID)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, detailsWithSize, inspected, "Expected the docker inspect output with the size to be cached separately")
+	}
+}
+
+func TestCachedClientErrorsNotCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, _ := newTestCachedClient(ctrl)
+
+	details := &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: cachedContainerID}}
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerInspect(gomock.Any(), cachedContainerID).Return(nil, errors.New("Docker is down")),
+		dockerMock.EXPECT().ContainerInspect(gomock.Any(), cachedContainerID).Return(details, nil),
+	)
+
+	_, err := client.ContainerInspect(context.Background(), cachedContainerID)
+	assert.Error(t, err, "Expected the error from Docker")
+	inspected, err := client.ContainerInspect(context.Background(), cachedContainerID)
+	assert.NoError(t, err, "Expected Docker to be called again after an error")
+	assert.Equal(t, details, inspected)
+}
+
+func TestCachedClientConcurrentRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, now := newTestCachedClient(ctrl)
+
+	release := make(chan struct{})
+	stats := &types.StatsJSON{Stats: types.Stats{Read: *now}}
+	// docker stats takes a while, since Docker waits for a second sample to compute the CPU usage
+	dockerMock.EXPECT().ContainerStats(gomock.Any(), cachedContainerID).DoAndReturn(func(ctx context.Context, id string) (*types.StatsJSON, error) {
+		<-release
+		return stats, nil
+	}).Times(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.ContainerStats(context.Background(), cachedContainerID)
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, stats, response, "Expected every request to get the same stats")
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestCachedClientCancelledRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, now := newTestCachedClient(ctrl)
+
+	release := make(chan struct{})
+	stats := &types.StatsJSON{Stats: types.Stats{Read: *now}}
+	dockerMock.EXPECT().ContainerStats(gomock.Any(), cachedContainerID).DoAndReturn(func(ctx context.Context, id string) (*types.StatsJSON, error) {
+		<-release
+		// the request has its own context, which the first caller does not cancel
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return stats, nil
+	}).Times(1)
+
+	// the first caller goes away while the request is in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := client.ContainerStats(ctx, cachedContainerID)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	responses := make(chan *types.StatsJSON)
+	go func() {
+		response, err := client.ContainerStats(context.Background(), cachedContainerID)
+		assert.NoError(t, err, "Unexpected error for the request which is still waiting")
+		responses <- response
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errs, "Expected the cancelled caller to stop waiting")
+	close(release)
+	assert.Equal(t, stats, <-responses, "Expected the other caller to get the stats")
+
+	// the stats are cached for the next request
+	response, err := client.ContainerStats(context.Background(), cachedContainerID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, stats, response, "Expected the cached stats")
+}
+
+func TestCachedClientRemovesExpiredEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client, dockerMock, now := newTestCachedClient(ctrl)
+
+	otherID := "5f1c6fe0d4a88c1c2d163c4f9f6d0a0b3e8de3482ab3a6a2f00c1b8e6a5a2d1b"
+	dockerMock.EXPECT().ContainerStats(gomock.Any(), gomock.Any()).Return(&types.StatsJSON{}, nil).Times(2)
+
+	_, err := client.ContainerStats(context.Background(), cachedContainerID)
+	assert.NoError(t, err, "Unexpected error")
+	*now = now.Add(5 * time.Second)
+	_, err = client.ContainerStats(context.Background(), otherID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, client.stats, 1, "Expected the entry of the container which is no longer requested to be removed")
+}
@@ -17,8 +17,14 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"net/http"
 	"os"
+	"sync"
 
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/proxy"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metrics"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/pkg/errors"
@@ -33,25 +39,46 @@ const (
 // Client is a wrapper for Docker SDK Client
 type Client interface {
 	ContainerList(context.Context) ([]types.Container, error)
-	ContainerStats(ctx context.Context, longContainerID string) (*types.Stats, error)
+	ContainerStats(ctx context.Context, longContainerID string) (*types.StatsJSON, error)
+	ContainerStatsStream(ctx context.Context, longContainerID string, statsChan chan<- *types.StatsJSON) error
+	ContainerInspect(ctx context.Context, longContainerID string) (*types.ContainerJSON, error)
+	// ContainerInspectWithSize also returns the size of the container's writable layer, which Docker has to compute
+	ContainerInspectWithSize(ctx context.Context, longContainerID string) (*types.ContainerJSON, error)
 }
 
+var setDockerHostOnce sync.Once
+
 type dockerClient struct {
 	sdkClient *client.Client
 }
 
 // NewDockerClient creates a new wrapper of the Docker Go Client
 func NewDockerClient() (Client, error) {
+	return newDockerClient()
+}
+
+func newDockerClient() (*dockerClient, error) {
 	// Using NewEnvClient allows customers to configure Docker via env vars
 	// However, if DOCKER_API_VERSION is not set, the SDK can pick a version
 	// which is too new for the local Docker.
 	if os.Getenv("DOCKER_API_VERSION") == "" {
 		os.Setenv("DOCKER_API_VERSION", minDockerAPIVersion)
 	}
+	// the credentials and metadata services each create a client, and the engine endpoint is only chosen once
+	setDockerHostOnce.Do(setDockerHost)
+	setDockerCertPath()
 	sdkClient, err := client.NewEnvClient()
 	if err != nil {
 		return nil, err
 	}
+	// a remote engine over tcp is reached through the outbound proxy, as with the Docker CLI; unix sockets have no proxy
+	proxySettings, err := proxy.FromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	if transport, ok := sdkClient.HTTPClient().Transport.(*http.Transport); ok && transport.Proxy != nil {
+		proxySettings.Apply(transport)
+	}
 	return &dockerClient{
 		sdkClient: sdkClient,
 	}, nil
@@ -59,21 +86,91 @@ func NewDockerClient() (Client, error) {
 
 // ContainerList lists all containers running on the host
 func (c *dockerClient) ContainerList(ctx context.Context) ([]types.Container, error) {
-	return c.sdkClient.ContainerList(ctx, types.ContainerListOptions{})
+	ctx, span := startSpan(ctx, "ContainerList")
+	containers, err := c.sdkClient.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		metrics.DockerAPIError("ContainerList")
+	}
+	span.End(err)
+	return containers, err
 }
 
-func (c *dockerClient) ContainerStats(ctx context.Context, longContainerID string) (*types.Stats, error) {
+func (c *dockerClient) ContainerStats(ctx context.Context, longContainerID string) (_ *types.StatsJSON, err error) {
+	ctx, span := startSpan(ctx, "ContainerStats")
+	defer func() {
+		span.End(err)
+	}()
 	resp, err := c.sdkClient.ContainerStats(ctx, longContainerID, false)
 	if err != nil {
+		metrics.DockerAPIError("ContainerStats")
 		return nil, errors.Wrapf(err, "failed to get docker stats for %s", longContainerID)
 	}
 
 	decoder := json.NewDecoder(resp.Body)
-	data := new(types.Stats)
+	data := new(types.StatsJSON)
 	err = decoder.Decode(data)
 	defer resp.Body.Close()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get docker stats for %s", longContainerID)
 	}
+	convertWindowsStats(data)
 	return data, nil
 }
+
+// ContainerStatsStream sends each stats sample produced by Docker for the container on statsChan.
+// It blocks until the stream ends or the context is cancelled.
+func (c *dockerClient) ContainerStatsStream(ctx context.Context, longContainerID string, statsChan chan<- *types.StatsJSON) (err error) {
+	ctx, span := startSpan(ctx, "ContainerStatsStream")
+	defer func() {
+		span.End(err)
+	}()
+	resp, err := c.sdkClient.ContainerStats(ctx, longContainerID, true)
+	if err != nil {
+		metrics.DockerAPIError("ContainerStats")
+		return errors.Wrapf(err, "failed to stream docker stats for %s", longContainerID)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		data := new(types.StatsJSON)
+		if err := decoder.Decode(data); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to stream docker stats for %s", longContainerID)
+		}
+		convertWindowsStats(data)
+		select {
+		case statsChan <- data:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// ContainerInspect returns the low-level information on a container
+func (c *dockerClient) ContainerInspect(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	return c.containerInspect(ctx, "ContainerInspect", longContainerID, false)
+}
+
+// ContainerInspectWithSize returns the low-level information on a container, including the size of its writable
+// layer; Docker walks the layer to compute it, so it is only requested when it is used
+func (c *dockerClient) ContainerInspectWithSize(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	return c.containerInspect(ctx, "ContainerInspectWithSize", longContainerID, true)
+}
+
+func (c *dockerClient) containerInspect(ctx context.Context, method string, longContainerID string, getSize bool) (*types.ContainerJSON, error) {
+	ctx, span := startSpan(ctx, method)
+	resp, _, err := c.sdkClient.ContainerInspectWithRaw(ctx, longContainerID, getSize)
+	span.End(err)
+	if err != nil {
+		metrics.DockerAPIError(method)
+		return nil, errors.Wrapf(err, "failed to inspect container %s", longContainerID)
+	}
+	return &resp, nil
+}
+
+func startSpan(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	return tracing.StartRPC(ctx, "docker", "Docker", method)
+}
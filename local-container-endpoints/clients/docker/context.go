@@ -0,0 +1,132 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// defaultContextName is the context of the Docker CLI which uses DOCKER_HOST, or the default socket
+const defaultContextName = "default"
+
+// dockerContext is the engine endpoint of a Docker CLI context
+type dockerContext struct {
+	Name          string
+	Host          string
+	SkipTLSVerify bool
+	// CertPath is the directory of the context's TLS certificates, which is empty if it has none
+	CertPath string
+}
+
+type contextMetadata struct {
+	Name      string
+	Endpoints map[string]struct {
+		Host          string
+		SkipTLSVerify bool
+	}
+}
+
+// getDockerConfigDir returns the directory of the Docker CLI config
+func getDockerConfigDir() (string, error) {
+	if configDir := os.Getenv("DOCKER_CONFIG"); configDir != "" {
+		return configDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker"), nil
+}
+
+// getCurrentContextName returns the name of the active Docker context, from DOCKER_CONTEXT or the currentContext
+// of the Docker CLI config file
+func getCurrentContextName(configDir string) (string, error) {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(configDir, "config.json"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var cliConfig struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if err := json.Unmarshal(data, &cliConfig); err != nil {
+		return "", fmt.Errorf("Failed to parse %s: %s", filepath.Join(configDir, "config.json"), err)
+	}
+	return cliConfig.CurrentContext, nil
+}
+
+// getCurrentContext returns the active Docker context, or nil if the default context is used
+func getCurrentContext() (*dockerContext, error) {
+	configDir, err := getDockerConfigDir()
+	if err != nil {
+		// without a home directory, there is no Docker CLI config to read the current context from
+		if os.Getenv("DOCKER_CONTEXT") == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	name, err := getCurrentContextName(configDir)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || name == defaultContextName {
+		return nil, nil
+	}
+	return readContext(configDir, name)
+}
+
+// readContext reads the docker endpoint of a context; the Docker CLI stores each context in a directory named
+// with the SHA-256 digest of its name
+func readContext(configDir, name string) (*dockerContext, error) {
+	digest := sha256.Sum256([]byte(name))
+	contextDir := hex.EncodeToString(digest[:])
+	metaFile := filepath.Join(configDir, "contexts", "meta", contextDir, "meta.json")
+	data, err := ioutil.ReadFile(metaFile)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Docker context %s does not exist", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var metadata contextMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %s", metaFile, err)
+	}
+	endpoint, ok := metadata.Endpoints["docker"]
+	if !ok || endpoint.Host == "" {
+		return nil, fmt.Errorf("Docker context %s does not have a docker endpoint", name)
+	}
+
+	current := &dockerContext{
+		Name:          name,
+		Host:          endpoint.Host,
+		SkipTLSVerify: endpoint.SkipTLSVerify,
+	}
+	certPath := filepath.Join(configDir, "contexts", "tls", contextDir, "docker")
+	if info, err := os.Stat(certPath); err == nil && info.IsDir() {
+		current.CertPath = certPath
+	}
+	return current, nil
+}
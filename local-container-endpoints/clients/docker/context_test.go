@@ -0,0 +1,111 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// colimaContextDir is the SHA-256 digest of "colima"
+const colimaContextDir = "f24fd3749c1368328e2b149bec149cb6795619f244c5b584e844961215dadd16"
+
+func writeTestFile(t *testing.T, path, contents string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	assert.NoError(t, err, "Unexpected error creating directory")
+	err = ioutil.WriteFile(path, []byte(contents), 0644)
+	assert.NoError(t, err, "Unexpected error writing file")
+}
+
+func newTestDockerConfig(t *testing.T) string {
+	configDir, err := ioutil.TempDir("", "docker-config")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	writeTestFile(t, filepath.Join(configDir, "config.json"), `{"auths":{},"currentContext":"colima"}`)
+	writeTestFile(t, filepath.Join(configDir, "contexts", "meta", colimaContextDir, "meta.json"),
+		`{"Name":"colima","Metadata":{"Description":"colima"},"Endpoints":{"docker":{"Host":"unix:///Users/me/.colima/default/docker.sock","SkipTLSVerify":false}}}`)
+	return configDir
+}
+
+func TestGetCurrentContext(t *testing.T) {
+	configDir := newTestDockerConfig(t)
+	defer os.RemoveAll(configDir)
+	os.Setenv("DOCKER_CONFIG", configDir)
+	defer os.Unsetenv("DOCKER_CONFIG")
+
+	current, err := getCurrentContext()
+	assert.NoError(t, err, "Unexpected error reading context")
+	assert.Equal(t, &dockerContext{
+		Name: "colima",
+		Host: "unix:///Users/me/.colima/default/docker.sock",
+	}, current, "Expected the current context from the config file")
+
+	writeTestFile(t, filepath.Join(configDir, "contexts", "tls", colimaContextDir, "docker", "ca.pem"), "")
+	current, err = getCurrentContext()
+	assert.NoError(t, err, "Unexpected error reading context")
+	assert.Equal(t, filepath.Join(configDir, "contexts", "tls", colimaContextDir, "docker"), current.CertPath, "Expected the context's TLS directory")
+}
+
+func TestGetCurrentContextFromEnv(t *testing.T) {
+	configDir := newTestDockerConfig(t)
+	defer os.RemoveAll(configDir)
+	os.Setenv("DOCKER_CONFIG", configDir)
+	defer os.Unsetenv("DOCKER_CONFIG")
+	defer os.Unsetenv("DOCKER_CONTEXT")
+
+	os.Setenv("DOCKER_CONTEXT", "default")
+	current, err := getCurrentContext()
+	assert.NoError(t, err, "Unexpected error reading context")
+	assert.Nil(t, current, "Expected no context for the default context")
+
+	os.Setenv("DOCKER_CONTEXT", "missing")
+	_, err = getCurrentContext()
+	assert.Error(t, err, "Expected error for a context which does not exist")
+}
+
+func TestGetCurrentContextWithoutConfig(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "docker-config")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(configDir)
+	os.Setenv("DOCKER_CONFIG", configDir)
+	defer os.Unsetenv("DOCKER_CONFIG")
+
+	current, err := getCurrentContext()
+	assert.NoError(t, err, "Unexpected error without a config file")
+	assert.Nil(t, current, "Expected no context without a config file")
+}
+
+func TestSetDockerHostFromContext(t *testing.T) {
+	configDir := newTestDockerConfig(t)
+	defer os.RemoveAll(configDir)
+	writeTestFile(t, filepath.Join(configDir, "contexts", "tls", colimaContextDir, "docker", "ca.pem"), "")
+	os.Setenv("DOCKER_CONFIG", configDir)
+	defer os.Unsetenv("DOCKER_CONFIG")
+	defer os.Unsetenv("DOCKER_HOST")
+	defer os.Unsetenv("DOCKER_CERT_PATH")
+	defer os.Unsetenv("DOCKER_TLS_VERIFY")
+
+	os.Setenv("DOCKER_HOST", "tcp://localhost:2375")
+	setDockerHost()
+	assert.Equal(t, "tcp://localhost:2375", os.Getenv("DOCKER_HOST"), "Expected DOCKER_HOST to take precedence")
+
+	os.Unsetenv("DOCKER_HOST")
+	setDockerHost()
+	assert.Equal(t, "unix:///Users/me/.colima/default/docker.sock", os.Getenv("DOCKER_HOST"), "Expected the host of the context")
+	assert.Equal(t, filepath.Join(configDir, "contexts", "tls", colimaContextDir, "docker"), os.Getenv("DOCKER_CERT_PATH"), "Expected the context's TLS directory")
+	assert.Equal(t, "1", os.Getenv("DOCKER_TLS_VERIFY"), "Expected TLS to be verified")
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dockerSocketPath = "/var/run/docker.sock"
+	// podmanSocketPath is the Docker compatible API socket of rootful Podman
+	podmanSocketPath = "/run/podman/podman.sock"
+)
+
+// setDockerHost sets DOCKER_HOST to the endpoint of the active Docker context, or else to the engine socket which
+// is found on this machine if the Docker socket does not exist, so that the Docker SDK can use rootless Docker or
+// Podman instead. DOCKER_HOST is not changed if it is set. The endpoint which is chosen is logged.
+func setDockerHost() {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		logrus.Infof("Using the container engine at %s from DOCKER_HOST", host)
+		return
+	}
+	current, err := getCurrentContext()
+	if err != nil {
+		logrus.Warnf("Failed to read the active Docker context: %s", err)
+	} else if current != nil {
+		logrus.Infof("Using the engine of Docker context %s at %s", current.Name, current.Host)
+		os.Setenv("DOCKER_HOST", current.Host)
+		if current.CertPath != "" && os.Getenv("DOCKER_CERT_PATH") == "" {
+			os.Setenv("DOCKER_CERT_PATH", current.CertPath)
+			if !current.SkipTLSVerify {
+				os.Setenv("DOCKER_TLS_VERIFY", "1")
+			}
+		}
+		return
+	}
+
+	// the Docker SDK uses the named pipe of the Docker engine on Windows
+	if runtime.GOOS == "windows" {
+		logrus.Infof("Using the container engine at %s", client.DefaultDockerHost)
+		return
+	}
+	socketPaths := getSocketPaths()
+	socketPath := findSocket(socketPaths)
+	if socketPath == "" {
+		logrus.Warnf("No container engine socket was found at %s; trying %s", strings.Join(socketPaths, ", "), client.DefaultDockerHost)
+		return
+	}
+	logrus.Infof("Using the container engine socket at %s", socketPath)
+	if socketPath != dockerSocketPath {
+		os.Setenv("DOCKER_HOST", "unix://"+socketPath)
+	}
+}
+
+// setDockerCertPath sets DOCKER_CERT_PATH to the default directory of the Docker CLI when DOCKER_TLS_VERIFY is set,
+// since the Docker SDK only uses TLS when DOCKER_CERT_PATH is set
+func setDockerCertPath() {
+	if os.Getenv("DOCKER_CERT_PATH") != "" {
+		return
+	}
+	if certPath := getCertPath(); certPath != "" {
+		os.Setenv("DOCKER_CERT_PATH", certPath)
+	}
+}
+
+// getCertPath returns the directory of the TLS certificates used to connect to the engine, or an empty string
+// if TLS is not used
+func getCertPath() string {
+	if certPath := os.Getenv("DOCKER_CERT_PATH"); certPath != "" {
+		return certPath
+	}
+	if os.Getenv("DOCKER_TLS_VERIFY") == "" {
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// ValidateDockerHost checks that DOCKER_HOST is a valid engine address, or else that the active Docker context
+// can be read, and that the certificates in the TLS certificate directory exist
+func ValidateDockerHost() error {
+	if os.Getenv("DOCKER_HOST") == "" {
+		if _, err := getCurrentContext(); err != nil {
+			return fmt.Errorf("Invalid Docker context: %s", err)
+		}
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		if _, err := client.ParseHostURL(host); err != nil {
+			return fmt.Errorf("Invalid DOCKER_HOST: %s", err)
+		}
+	}
+	certPath := getCertPath()
+	if certPath == "" {
+		return nil
+	}
+	for _, file := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		if _, err := os.Stat(filepath.Join(certPath, file)); err != nil {
+			return fmt.Errorf("Invalid DOCKER_CERT_PATH: %s", err)
+		}
+	}
+	return nil
+}
+
+// getSocketPaths returns the paths where the engine socket is looked for, in order of preference
+func getSocketPaths() []string {
+	paths := []string{dockerSocketPath}
+	// rootless Docker and rootless Podman put their sockets in the user's runtime directory
+	runtimeDir := getRuntimeDir()
+	if runtimeDir != "" {
+		paths = append(paths, filepath.Join(runtimeDir, "docker.sock"))
+	}
+	paths = append(paths, podmanSocketPath)
+	if runtimeDir != "" {
+		paths = append(paths, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	return paths
+}
+
+// getRuntimeDir returns the user's runtime directory, which is /run/user/<uid> if XDG_RUNTIME_DIR is not set;
+// it is empty for root, which doesn't run rootless engines
+func getRuntimeDir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return runtimeDir
+	}
+	if uid := os.Getuid(); uid > 0 {
+		return filepath.Join("/run/user", strconv.Itoa(uid))
+	}
+	return ""
+}
+
+// findSocket returns the first path which is a unix socket, or an empty string if there is none
+func findSocket(paths []string) string {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path
+		}
+	}
+	return ""
+}
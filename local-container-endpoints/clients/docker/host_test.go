@@ -0,0 +1,106 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSocketPaths(t *testing.T) {
+	defer os.Unsetenv("XDG_RUNTIME_DIR")
+
+	os.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	assert.Equal(t, []string{
+		"/var/run/docker.sock",
+		"/run/user/1000/docker.sock",
+		"/run/podman/podman.sock",
+		"/run/user/1000/podman/podman.sock",
+	}, getSocketPaths(), "Expected the rootless sockets after the rootful sockets of each engine")
+
+	os.Unsetenv("XDG_RUNTIME_DIR")
+	if os.Getuid() == 0 {
+		assert.Equal(t, []string{"/var/run/docker.sock", "/run/podman/podman.sock"}, getSocketPaths(), "Expected only the rootful sockets for root")
+	} else {
+		assert.Contains(t, getSocketPaths(), fmt.Sprintf("/run/user/%d/docker.sock", os.Getuid()), "Expected the default runtime directory")
+	}
+}
+
+func TestFindSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sockets")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "docker.sock")
+	notSocket := filepath.Join(dir, "file.sock")
+	socket := filepath.Join(dir, "podman.sock")
+	err = ioutil.WriteFile(notSocket, []byte{}, 0644)
+	assert.NoError(t, err, "Unexpected error writing file")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err, "Unexpected error creating socket")
+	defer listener.Close()
+
+	assert.Equal(t, socket, findSocket([]string{missing, notSocket, socket}), "Expected the first path which is a socket")
+	assert.Equal(t, "", findSocket([]string{missing, notSocket}), "Expected no socket")
+}
+
+func TestGetCertPath(t *testing.T) {
+	defer os.Unsetenv("DOCKER_CERT_PATH")
+	defer os.Unsetenv("DOCKER_TLS_VERIFY")
+
+	os.Unsetenv("DOCKER_CERT_PATH")
+	os.Unsetenv("DOCKER_TLS_VERIFY")
+	assert.Equal(t, "", getCertPath(), "Expected no certificates without TLS")
+
+	os.Setenv("DOCKER_TLS_VERIFY", "1")
+	home, err := os.UserHomeDir()
+	assert.NoError(t, err, "Unexpected error getting home directory")
+	assert.Equal(t, filepath.Join(home, ".docker"), getCertPath(), "Expected the default directory of the Docker CLI")
+
+	os.Setenv("DOCKER_CERT_PATH", "/certs")
+	assert.Equal(t, "/certs", getCertPath(), "Expected DOCKER_CERT_PATH")
+}
+
+func TestValidateDockerHost(t *testing.T) {
+	defer os.Unsetenv("DOCKER_HOST")
+	defer os.Unsetenv("DOCKER_CERT_PATH")
+	defer os.Unsetenv("DOCKER_TLS_VERIFY")
+
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	os.Setenv("DOCKER_HOST", "tcp://192.168.99.100:2376")
+	assert.NoError(t, ValidateDockerHost(), "Unexpected error without TLS")
+
+	os.Setenv("DOCKER_HOST", "192.168.99.100:2376")
+	assert.Error(t, ValidateDockerHost(), "Expected error for a host without a protocol")
+
+	os.Setenv("DOCKER_HOST", "tcp://192.168.99.100:2376")
+	os.Setenv("DOCKER_TLS_VERIFY", "1")
+	os.Setenv("DOCKER_CERT_PATH", dir)
+	assert.Error(t, ValidateDockerHost(), "Expected error when the certificates are missing")
+
+	for _, file := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		err = ioutil.WriteFile(filepath.Join(dir, file), []byte{}, 0600)
+		assert.NoError(t, err, "Unexpected error writing file")
+	}
+	assert.NoError(t, ValidateDockerHost(), "Unexpected error with the certificates")
+}
@@ -48,6 +48,32 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// ContainerInspect mocks base method
+func (m *MockClient) ContainerInspect(arg0 context.Context, arg1 string) (*types.ContainerJSON, error) {
+	ret := m.ctrl.Call(m, "ContainerInspect", arg0, arg1)
+	ret0, _ := ret[0].(*types.ContainerJSON)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerInspect indicates an expected call of ContainerInspect
+func (mr *MockClientMockRecorder) ContainerInspect(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerInspect", reflect.TypeOf((*MockClient)(nil).ContainerInspect), arg0, arg1)
+}
+
+// ContainerInspectWithSize mocks base method
+func (m *MockClient) ContainerInspectWithSize(arg0 context.Context, arg1 string) (*types.ContainerJSON, error) {
+	ret := m.ctrl.Call(m, "ContainerInspectWithSize", arg0, arg1)
+	ret0, _ := ret[0].(*types.ContainerJSON)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerInspectWithSize indicates an expected call of ContainerInspectWithSize
+func (mr *MockClientMockRecorder) ContainerInspectWithSize(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerInspectWithSize", reflect.TypeOf((*MockClient)(nil).ContainerInspectWithSize), arg0, arg1)
+}
+
 // ContainerList mocks base method
 func (m *MockClient) ContainerList(arg0 context.Context) ([]types.Container, error) {
 	ret := m.ctrl.Call(m, "ContainerList", arg0)
@@ -62,9 +88,9 @@ func (mr *MockClientMockRecorder) ContainerList(arg0 interface{}) *gomock.Call {
 }
 
 // ContainerStats mocks base method
-func (m *MockClient) ContainerStats(arg0 context.Context, arg1 string) (*types.Stats, error) {
+func (m *MockClient) ContainerStats(arg0 context.Context, arg1 string) (*types.StatsJSON, error) {
 	ret := m.ctrl.Call(m, "ContainerStats", arg0, arg1)
-	ret0, _ := ret[0].(*types.Stats)
+	ret0, _ := ret[0].(*types.StatsJSON)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -73,3 +99,15 @@ func (m *MockClient) ContainerStats(arg0 context.Context, arg1 string) (*types.S
 func (mr *MockClientMockRecorder) ContainerStats(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerStats", reflect.TypeOf((*MockClient)(nil).ContainerStats), arg0, arg1)
 }
+
+// ContainerStatsStream mocks base method
+func (m *MockClient) ContainerStatsStream(arg0 context.Context, arg1 string, arg2 chan<- *types.StatsJSON) error {
+	ret := m.ctrl.Call(m, "ContainerStatsStream", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ContainerStatsStream indicates an expected call of ContainerStatsStream
+func (mr *MockClientMockRecorder) ContainerStatsStream(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerStatsStream", reflect.TypeOf((*MockClient)(nil).ContainerStatsStream), arg0, arg1, arg2)
+}
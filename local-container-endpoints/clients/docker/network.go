@@ -0,0 +1,151 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// the mounts of a container include its hostname file, which is in the container's directory
+var containerDirPattern = regexp.MustCompile(`/containers/([0-9a-f]{64})/`)
+
+// networkAPI is the part of the Docker SDK Client used to set up the credentials network
+type networkAPI interface {
+	NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error)
+	NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error)
+	NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error)
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+}
+
+// SetupNetwork finds the network with the credentials subnet, or creates a bridge network with the given name if there
+// is none, and connects the container to it at the credentials address. It returns the name of the network.
+func SetupNetwork(ctx context.Context, name, containerID string) (string, error) {
+	client, err := newDockerClient()
+	if err != nil {
+		return "", err
+	}
+	return setupNetwork(ctx, client.sdkClient, name, containerID)
+}
+
+func setupNetwork(ctx context.Context, api networkAPI, name, containerID string) (string, error) {
+	networks, err := api.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to list networks")
+	}
+	networkID := ""
+	for _, resource := range networks {
+		if hasSubnet(resource, config.NetworkSubnet) {
+			networkID = resource.ID
+			name = resource.Name
+			break
+		}
+	}
+
+	if networkID == "" {
+		response, err := api.NetworkCreate(ctx, name, types.NetworkCreate{
+			CheckDuplicate: true,
+			Driver:         "bridge",
+			IPAM: &network.IPAM{
+				Config: []network.IPAMConfig{
+					{
+						Subnet:  config.NetworkSubnet,
+						Gateway: config.NetworkGateway,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "Failed to create network %s with subnet %s", name, config.NetworkSubnet)
+		}
+		networkID = response.ID
+		logrus.Infof("Created network %s with subnet %s", name, config.NetworkSubnet)
+	}
+
+	// the containers of a network are only listed when it is inspected
+	resource, err := api.NetworkInspect(ctx, networkID, types.NetworkInspectOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to inspect network %s", name)
+	}
+	for id, endpoint := range resource.Containers {
+		ip := strings.Split(endpoint.IPv4Address, "/")[0]
+		isContainer := strings.HasPrefix(id, containerID) || endpoint.Name == strings.TrimPrefix(containerID, "/")
+		switch {
+		case isContainer && ip == config.EndpointsIP:
+			logrus.Infof("Container %s is already connected to network %s at %s", containerID, name, config.EndpointsIP)
+			return name, nil
+		case isContainer:
+			return "", fmt.Errorf("Container %s is connected to network %s at %s instead of %s", containerID, name, ip, config.EndpointsIP)
+		case ip == config.EndpointsIP:
+			return "", fmt.Errorf("Container %s already has the address %s in network %s", endpoint.Name, config.EndpointsIP, name)
+		}
+	}
+
+	err = api.NetworkConnect(ctx, networkID, containerID, &network.EndpointSettings{
+		IPAMConfig: &network.EndpointIPAMConfig{
+			IPv4Address: config.EndpointsIP,
+		},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to connect container %s to network %s", containerID, name)
+	}
+	logrus.Infof("Connected container %s to network %s at %s", containerID, name, config.EndpointsIP)
+	return name, nil
+}
+
+func hasSubnet(resource types.NetworkResource, subnet string) bool {
+	for _, ipamConfig := range resource.IPAM.Config {
+		if ipamConfig.Subnet == subnet {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentContainerID returns the ID of the container which Local Endpoints runs in, from the mounts of its hostname
+// and hosts files; otherwise the hostname is used, which is the short container ID unless it is set with --hostname
+func CurrentContainerID() (string, error) {
+	if file, err := os.Open("/proc/self/mountinfo"); err == nil {
+		defer file.Close()
+		if id := findContainerID(file); id != "" {
+			return id, nil
+		}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to find the ID of this container")
+	}
+	return hostname, nil
+}
+
+func findContainerID(mountInfo io.Reader) string {
+	scanner := bufio.NewScanner(mountInfo)
+	for scanner.Scan() {
+		if match := containerDirPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	endpointsContainerID = "4f2a1cd00e1b94b3ab0e2cbd0a4f5a0f0c3f053ba8b3b3e51e8d4ab3b95e6e2c"
+	credentialsNetworkID = "0b6a7e3e4d5c"
+)
+
+// fakeNetworkAPI records the networks which are created and the containers which are connected
+type fakeNetworkAPI struct {
+	networks  []types.NetworkResource
+	created   []types.NetworkCreate
+	connected []*network.EndpointSettings
+}
+
+func (api *fakeNetworkAPI) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	return api.networks, nil
+}
+
+func (api *fakeNetworkAPI) NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error) {
+	for _, resource := range api.networks {
+		if resource.ID == networkID {
+			return resource, nil
+		}
+	}
+	return types.NetworkResource{ID: networkID}, nil
+}
+
+func (api *fakeNetworkAPI) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	api.created = append(api.created, options)
+	return types.NetworkCreateResponse{ID: credentialsNetworkID}, nil
+}
+
+func (api *fakeNetworkAPI) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	api.connected = append(api.connected, config)
+	return nil
+}
+
+func newCredentialsNetwork(containers map[string]types.EndpointResource) types.NetworkResource {
+	return types.NetworkResource{
+		Name: "my_network",
+		ID:   credentialsNetworkID,
+		IPAM: network.IPAM{
+			Config: []network.IPAMConfig{
+				{Subnet: "169.254.170.0/24"},
+			},
+		},
+		Containers: containers,
+	}
+}
+
+func TestSetupNetworkCreatesNetwork(t *testing.T) {
+	api := &fakeNetworkAPI{
+		networks: []types.NetworkResource{
+			{Name: "bridge", ID: "1a2b3c", IPAM: network.IPAM{Config: []network.IPAMConfig{{Subnet: "172.17.0.0/16"}}}},
+		},
+	}
+	name, err := setupNetwork(context.Background(), api, "credentials_network", endpointsContainerID)
+	assert.NoError(t, err, "Unexpected error setting up network")
+	assert.Equal(t, "credentials_network", name, "Expected the network to be created with the given name")
+	assert.Len(t, api.created, 1, "Expected the network to be created")
+	assert.Equal(t, "bridge", api.created[0].Driver, "Expected a bridge network")
+	assert.Equal(t, []network.IPAMConfig{{Subnet: "169.254.170.0/24", Gateway: "169.254.170.1"}}, api.created[0].IPAM.Config, "Expected the credentials subnet")
+	assert.Len(t, api.connected, 1, "Expected the container to be connected")
+	assert.Equal(t, "169.254.170.2", api.connected[0].IPAMConfig.IPv4Address, "Expected the credentials address")
+}
+
+func TestSetupNetworkUsesExistingNetwork(t *testing.T) {
+	api := &fakeNetworkAPI{
+		networks: []types.NetworkResource{
+			newCredentialsNetwork(map[string]types.EndpointResource{
+				"9e8d7c6b5a4f": {Name: "app", IPv4Address: "169.254.170.3/24"},
+			}),
+		},
+	}
+	name, err := setupNetwork(context.Background(), api, "credentials_network", endpointsContainerID)
+	assert.NoError(t, err, "Unexpected error setting up network")
+	assert.Equal(t, "my_network", name, "Expected the network with the credentials subnet")
+	assert.Len(t, api.created, 0, "Expected no network to be created")
+	assert.Len(t, api.connected, 1, "Expected the container to be connected")
+}
+
+func TestSetupNetworkAlreadyConnected(t *testing.T) {
+	for _, containerID := range []string{endpointsContainerID[:12], "ecs-local-endpoints"} {
+		api := &fakeNetworkAPI{
+			networks: []types.NetworkResource{
+				newCredentialsNetwork(map[string]types.EndpointResource{
+					endpointsContainerID: {Name: "ecs-local-endpoints", IPv4Address: "169.254.170.2/24"},
+				}),
+			},
+		}
+		_, err := setupNetwork(context.Background(), api, "credentials_network", containerID)
+		assert.NoError(t, err, "Unexpected error setting up network for %s", containerID)
+		assert.Len(t, api.connected, 0, "Expected %s not to be connected again", containerID)
+	}
+}
+
+func TestSetupNetworkAddressConflicts(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		containers map[string]types.EndpointResource
+		message    string
+	}{
+		{
+			name: "Address in use",
+			containers: map[string]types.EndpointResource{
+				"9e8d7c6b5a4f": {Name: "app", IPv4Address: "169.254.170.2/24"},
+			},
+			message: "Container app already has the address 169.254.170.2",
+		},
+		{
+			name: "Connected at another address",
+			containers: map[string]types.EndpointResource{
+				endpointsContainerID: {Name: "ecs-local-endpoints", IPv4Address: "169.254.170.5/24"},
+			},
+			message: "is connected to network my_network at 169.254.170.5",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			api := &fakeNetworkAPI{
+				networks: []types.NetworkResource{newCredentialsNetwork(test.containers)},
+			}
+			_, err := setupNetwork(context.Background(), api, "credentials_network", endpointsContainerID)
+			assert.Error(t, err, "Expected error setting up network")
+			assert.Contains(t, err.Error(), test.message, "Expected error message to match")
+			assert.Len(t, api.connected, 0, "Expected the container not to be connected")
+		})
+	}
+}
+
+func TestFindContainerID(t *testing.T) {
+	mountInfo := strings.Join([]string{
+		"1201 1192 0:262 / / rw,relatime master:512 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/ABC",
+		"1215 1201 254:1 /docker/volumes/config/_data /config rw,relatime - ext4 /dev/vda1 rw",
+		"1216 1201 254:1 /docker/containers/" + endpointsContainerID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw",
+	}, "\n")
+	assert.Equal(t, endpointsContainerID, findContainerID(strings.NewReader(mountInfo)), "Expected the ID from the hostname mount")
+	assert.Equal(t, "", findContainerID(strings.NewReader("1201 1192 0:262 / / rw - overlay overlay rw")), "Expected no ID outside of a container")
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const reconnectDelay = 5 * time.Second
+
+// the container events which change what ContainerList returns for a container
+var containerChangeActions = map[string]bool{
+	"start":   true,
+	"die":     true,
+	"destroy": true,
+	"pause":   true,
+	"unpause": true,
+	"rename":  true,
+	"update":  true,
+}
+
+// eventsAPI is the part of the Docker SDK Client used to watch containers
+type eventsAPI interface {
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+}
+
+// WatchedClient is a Client which lists containers from an in-memory model of the running containers,
+// which is kept up to date with the Docker events stream, instead of calling the Docker API for every request.
+// Until Watch has listed the containers, or while the events stream is disconnected, the Docker API is called as usual.
+type WatchedClient struct {
+	Client
+	api eventsAPI
+
+	lock       sync.RWMutex
+	containers map[string]types.Container
+	// synced is whether containers is up to date with the events stream
+	synced bool
+}
+
+// NewWatchedClient creates a Docker Client whose model of the containers is kept up to date once Watch is called
+func NewWatchedClient() (*WatchedClient, error) {
+	client, err := newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	return newWatchedClient(client, client.sdkClient), nil
+}
+
+func newWatchedClient(client Client, api eventsAPI) *WatchedClient {
+	return &WatchedClient{
+		Client: client,
+		api:    api,
+	}
+}
+
+// ContainerList lists the running containers from the model, or from the Docker API if the model isn't up to date
+func (c *WatchedClient) ContainerList(ctx context.Context) ([]types.Container, error) {
+	c.lock.RLock()
+	if !c.synced {
+		c.lock.RUnlock()
+		return c.Client.ContainerList(ctx)
+	}
+	containers := make([]types.Container, 0, len(c.containers))
+	for _, container := range c.containers {
+		containers = append(containers, container)
+	}
+	c.lock.RUnlock()
+
+	// newest first, like the Docker API
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].Created != containers[j].Created {
+			return containers[i].Created > containers[j].Created
+		}
+		return containers[i].ID < containers[j].ID
+	})
+	return containers, nil
+}
+
+// Watch keeps the model up to date with the Docker events stream until ctx is done, reconnecting if the stream ends
+func (c *WatchedClient) Watch(ctx context.Context) {
+	warned := false
+	for {
+		err := c.watch(ctx)
+		wasSynced := c.unsync()
+		if ctx.Err() != nil {
+			return
+		}
+		// while Docker is unavailable, only the first attempt is logged as a warning
+		if wasSynced || !warned {
+			logrus.Warnf("Stopped watching Docker events, containers will be listed for each request until it reconnects: %s", err)
+			warned = true
+		} else {
+			logrus.Debugf("Failed to watch Docker events: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (c *WatchedClient) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	eventFilters := filters.NewArgs()
+	eventFilters.Add("type", events.ContainerEventType)
+	eventFilters.Add("type", events.NetworkEventType)
+	messages, errs := c.api.Events(ctx, types.EventsOptions{
+		Filters: eventFilters,
+	})
+
+	// the containers are listed after subscribing, so that no changes are missed in between
+	containers, err := c.api.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list running containers")
+	}
+	c.lock.Lock()
+	c.containers = make(map[string]types.Container, len(containers))
+	for _, container := range containers {
+		c.containers[container.ID] = container
+	}
+	c.synced = true
+	c.lock.Unlock()
+	logrus.Debugf("Watching Docker events, found %d running containers", len(containers))
+
+	for {
+		select {
+		case message := <-messages:
+			if containerID := getChangedContainer(message); containerID != "" {
+				if err := c.refresh(ctx, containerID); err != nil {
+					return err
+				}
+			}
+		case err := <-errs:
+			if err == nil {
+				err = errors.New("the events stream ended")
+			}
+			return err
+		}
+	}
+}
+
+// getChangedContainer returns the ID of the container whose ContainerList entry may have been changed by the event, if any
+func getChangedContainer(message events.Message) string {
+	switch message.Type {
+	case events.ContainerEventType:
+		// health checks change the status; other events, such as those of execs, don't change the container
+		if containerChangeActions[message.Action] || strings.HasPrefix(message.Action, "health_status") {
+			return message.Actor.ID
+		}
+	case events.NetworkEventType:
+		if message.Action == "connect" || message.Action == "disconnect" {
+			return message.Actor.Attributes["container"]
+		}
+	}
+	return ""
+}
+
+// refresh replaces the container in the model with its current state, or removes it if it is no longer running
+func (c *WatchedClient) refresh(ctx context.Context, containerID string) error {
+	containers, err := c.api.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("id", containerID)),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list container %s", containerID)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.containers, containerID)
+	for _, container := range containers {
+		// the id filter also matches by prefix
+		if container.ID == containerID {
+			c.containers[containerID] = container
+		}
+	}
+	return nil
+}
+
+// unsync marks the model as out of date, and returns whether it was up to date
+func (c *WatchedClient) unsync() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wasSynced := c.synced
+	c.synced = false
+	return wasSynced
+}
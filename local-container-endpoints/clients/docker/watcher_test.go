@@ -0,0 +1,192 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEventsAPI lists its containers, and sends the events and errors written to its channels
+type fakeEventsAPI struct {
+	lock       sync.Mutex
+	containers []types.Container
+	listCalls  int
+	messages   chan events.Message
+	errs       chan error
+}
+
+func newFakeEventsAPI(containers ...types.Container) *fakeEventsAPI {
+	return &fakeEventsAPI{
+		containers: containers,
+		messages:   make(chan events.Message),
+		errs:       make(chan error, 1),
+	}
+}
+
+func (api *fakeEventsAPI) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	api.listCalls++
+	ids := options.Filters.Get("id")
+	var containers []types.Container
+	for _, container := range api.containers {
+		if len(ids) == 0 || strings.HasPrefix(container.ID, ids[0]) {
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
+
+func (api *fakeEventsAPI) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	return api.messages, api.errs
+}
+
+func (api *fakeEventsAPI) setContainers(containers ...types.Container) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	api.containers = containers
+}
+
+func (api *fakeEventsAPI) getListCalls() int {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	return api.listCalls
+}
+
+func getIDs(t *testing.T, client Client) []string {
+	containers, err := client.ContainerList(context.Background())
+	assert.NoError(t, err, "Unexpected error listing containers")
+	var ids []string
+	for _, container := range containers {
+		ids = append(ids, container.ID)
+	}
+	return ids
+}
+
+// waitUntil waits for the events to be handled by the watcher, up to a second
+func waitUntil(condition func() bool) {
+	for start := time.Now(); !condition() && time.Since(start) < time.Second; {
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForIDs waits for the client to list the containers with the IDs
+func waitForIDs(t *testing.T, client Client, expected ...string) {
+	waitUntil(func() bool {
+		return assert.ObjectsAreEqual(expected, getIDs(t, client))
+	})
+	assert.Equal(t, expected, getIDs(t, client), "Expected the containers to match")
+}
+
+// waitForSynced waits until the client's model is up to date with the events stream, or until it isn't
+func waitForSynced(t *testing.T, client *WatchedClient, expected bool) {
+	isSynced := func() bool {
+		client.lock.RLock()
+		defer client.lock.RUnlock()
+		return client.synced
+	}
+	waitUntil(func() bool {
+		return isSynced() == expected
+	})
+	assert.Equal(t, expected, isSynced(), "Expected synced to match")
+}
+
+func TestWatchedClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	first := types.Container{ID: "aaa", Created: 1}
+	second := types.Container{ID: "bbb", Created: 2}
+	api := newFakeEventsAPI(first)
+	client := newWatchedClient(dockerMock, api)
+
+	// the Docker API is used until the containers have been listed
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{first}, nil)
+	assert.Equal(t, []string{"aaa"}, getIDs(t, client), "Expected the containers from the Docker API")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.Watch(ctx)
+		close(done)
+	}()
+	waitForSynced(t, client, true)
+	assert.Equal(t, []string{"aaa"}, getIDs(t, client), "Expected the containers from the model")
+
+	api.setContainers(first, second)
+	api.messages <- events.Message{Type: events.ContainerEventType, Action: "start", Actor: events.Actor{ID: "bbb"}}
+	waitForIDs(t, client, "bbb", "aaa")
+
+	api.setContainers(second)
+	api.messages <- events.Message{Type: events.ContainerEventType, Action: "die", Actor: events.Actor{ID: "aaa"}}
+	waitForIDs(t, client, "bbb")
+
+	listCalls := api.getListCalls()
+	api.messages <- events.Message{Type: events.ContainerEventType, Action: "exec_start: sh", Actor: events.Actor{ID: "bbb"}}
+	api.messages <- events.Message{Type: events.ImageEventType, Action: "pull", Actor: events.Actor{ID: "alpine"}}
+	assert.Equal(t, listCalls, api.getListCalls(), "Expected events which don't change containers to be ignored")
+
+	renamed := types.Container{ID: "bbb", Created: 2, Names: []string{"/renamed"}}
+	api.setContainers(renamed)
+	api.messages <- events.Message{Type: events.NetworkEventType, Action: "connect", Actor: events.Actor{ID: "network", Attributes: map[string]string{"container": "bbb"}}}
+	waitUntil(func() bool {
+		containers, _ := client.ContainerList(context.Background())
+		return assert.ObjectsAreEqual([]types.Container{renamed}, containers)
+	})
+	containers, err := client.ContainerList(context.Background())
+	assert.NoError(t, err, "Unexpected error listing containers")
+	assert.Equal(t, []types.Container{renamed}, containers, "Expected the container to be refreshed after a network event")
+
+	// once the stream ends, the Docker API is used again until it reconnects
+	api.errs <- errors.New("connection reset")
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{second}, nil).MinTimes(1)
+	waitForSynced(t, client, false)
+	assert.Equal(t, []string{"bbb"}, getIDs(t, client), "Expected the containers from the Docker API")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected Watch to return once the context is done")
+	}
+}
+
+func TestGetChangedContainer(t *testing.T) {
+	testCases := []struct {
+		message  events.Message
+		expected string
+	}{
+		{events.Message{Type: events.ContainerEventType, Action: "destroy", Actor: events.Actor{ID: "aaa"}}, "aaa"},
+		{events.Message{Type: events.ContainerEventType, Action: "health_status: healthy", Actor: events.Actor{ID: "aaa"}}, "aaa"},
+		{events.Message{Type: events.ContainerEventType, Action: "exec_die", Actor: events.Actor{ID: "aaa"}}, ""},
+		{events.Message{Type: events.NetworkEventType, Action: "disconnect", Actor: events.Actor{ID: "net", Attributes: map[string]string{"container": "aaa"}}}, "aaa"},
+		{events.Message{Type: events.NetworkEventType, Action: "create", Actor: events.Actor{ID: "net"}}, ""},
+		{events.Message{Type: events.VolumeEventType, Action: "mount", Actor: events.Actor{ID: "vol"}}, ""},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, getChangedContainer(testCase.message), "Unexpected container for %s %s", testCase.message.Type, testCase.message.Action)
+	}
+}
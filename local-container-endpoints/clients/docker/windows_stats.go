@@ -0,0 +1,51 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"github.com/docker/docker/api/types"
+)
+
+// Windows reports CPU usage in 100ns intervals, instead of nanoseconds
+const windowsCPUUsageInterval = 100
+
+// convertWindowsStats fills in the Linux fields of stats from a Windows container, so that the CPU and memory
+// utilization can be computed in the same way for every container. Stats from Linux containers are not changed.
+func convertWindowsStats(stats *types.StatsJSON) {
+	// num_procs is only reported by Windows
+	if stats.NumProcs == 0 {
+		return
+	}
+	convertWindowsCPUStats(&stats.CPUStats, stats.NumProcs)
+	convertWindowsCPUStats(&stats.PreCPUStats, stats.NumProcs)
+
+	// Windows doesn't report a system CPU usage, so it is the CPU time of every processor since the previous sample;
+	// the usage of precpu_stats is zero, so that the difference is the time which was available to the container
+	if !stats.PreRead.IsZero() && stats.Read.After(stats.PreRead) {
+		elapsed := uint64(stats.Read.Sub(stats.PreRead).Nanoseconds())
+		stats.CPUStats.SystemUsage = elapsed * uint64(stats.NumProcs)
+	}
+	stats.PreCPUStats.SystemUsage = 0
+
+	if stats.MemoryStats.Usage == 0 {
+		stats.MemoryStats.Usage = stats.MemoryStats.PrivateWorkingSet
+	}
+}
+
+func convertWindowsCPUStats(cpuStats *types.CPUStats, numProcs uint32) {
+	cpuStats.CPUUsage.TotalUsage *= windowsCPUUsageInterval
+	cpuStats.CPUUsage.UsageInKernelmode *= windowsCPUUsageInterval
+	cpuStats.CPUUsage.UsageInUsermode *= windowsCPUUsageInterval
+	cpuStats.OnlineCPUs = numProcs
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertWindowsStats(t *testing.T) {
+	read := time.Date(2019, 5, 1, 12, 0, 1, 0, time.UTC)
+	stats := &types.StatsJSON{
+		Stats: types.Stats{
+			Read:     read,
+			PreRead:  read.Add(-time.Second),
+			NumProcs: 2,
+			CPUStats: types.CPUStats{
+				CPUUsage: types.CPUUsage{
+					TotalUsage:        15000000,
+					UsageInKernelmode: 5000000,
+					UsageInUsermode:   10000000,
+				},
+			},
+			PreCPUStats: types.CPUStats{
+				CPUUsage: types.CPUUsage{
+					TotalUsage: 10000000,
+				},
+			},
+			MemoryStats: types.MemoryStats{
+				Commit:            200 * 1024 * 1024,
+				PrivateWorkingSet: 100 * 1024 * 1024,
+			},
+		},
+	}
+
+	convertWindowsStats(stats)
+	assert.Equal(t, uint64(1500000000), stats.CPUStats.CPUUsage.TotalUsage, "Expected CPU usage in nanoseconds")
+	assert.Equal(t, uint64(500000000), stats.CPUStats.CPUUsage.UsageInKernelmode, "Expected kernel mode CPU usage in nanoseconds")
+	assert.Equal(t, uint64(1000000000), stats.CPUStats.CPUUsage.UsageInUsermode, "Expected user mode CPU usage in nanoseconds")
+	assert.Equal(t, uint64(1000000000), stats.PreCPUStats.CPUUsage.TotalUsage, "Expected previous CPU usage in nanoseconds")
+	assert.Equal(t, uint32(2), stats.CPUStats.OnlineCPUs, "Expected online CPUs to be the number of processors")
+	assert.Equal(t, uint64(2000000000), stats.CPUStats.SystemUsage, "Expected system CPU usage of both processors for one second")
+	assert.Equal(t, uint64(0), stats.PreCPUStats.SystemUsage, "Expected no previous system CPU usage")
+	assert.Equal(t, uint64(100*1024*1024), stats.MemoryStats.Usage, "Expected memory usage to be the private working set")
+
+	// the CPU utilization is computed as it is for Linux containers, where 100% is one CPU
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
+	assert.Equal(t, 50.0, cpuDelta/systemDelta*float64(stats.CPUStats.OnlineCPUs)*100, "Expected half of a CPU to be used")
+}
+
+func TestConvertWindowsStatsFirstSample(t *testing.T) {
+	stats := &types.StatsJSON{
+		Stats: types.Stats{
+			Read:     time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC),
+			NumProcs: 1,
+			CPUStats: types.CPUStats{
+				CPUUsage: types.CPUUsage{
+					TotalUsage: 10,
+				},
+			},
+		},
+	}
+
+	convertWindowsStats(stats)
+	assert.Equal(t, uint64(1000), stats.CPUStats.CPUUsage.TotalUsage, "Expected CPU usage in nanoseconds")
+	assert.Equal(t, uint64(0), stats.CPUStats.SystemUsage, "Expected no system CPU usage without a previous sample")
+}
+
+func TestConvertWindowsStatsLinux(t *testing.T) {
+	stats := &types.StatsJSON{
+		Stats: types.Stats{
+			Read: time.Date(2019, 5, 1, 12, 0, 1, 0, time.UTC),
+			CPUStats: types.CPUStats{
+				CPUUsage: types.CPUUsage{
+					TotalUsage: 1500000000,
+				},
+				SystemUsage: 9000000000,
+				OnlineCPUs:  4,
+			},
+			MemoryStats: types.MemoryStats{
+				Usage: 1024,
+			},
+		},
+	}
+	expected := *stats
+
+	convertWindowsStats(stats)
+	assert.Equal(t, expected, *stats, "Expected Linux stats to be unchanged")
+}
@@ -0,0 +1,369 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kubernetes synthesizes the containers of the pod which Local Endpoints runs in as a sidecar, so that
+// containers in a local kind or minikube cluster get credentials and task metadata without a Docker engine
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/pkg/errors"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// the containers of a pod share its network namespace, so they are all in one network
+	podNetworkName = "pod"
+	// annotations with this prefix are set as labels of every container, such as ecs-local.task-role
+	localEndpointsPrefix = "ecs-local."
+)
+
+// IsSidecar returns true if Local Endpoints runs as a sidecar in a pod, which is given by ECS_LOCAL_POD_NAME
+func IsSidecar() bool {
+	return os.Getenv(config.PodNameVar) != ""
+}
+
+// PodClient implements the container list and inspect APIs of the Docker client with the pod spec from the
+// Kubernetes API, and the metadata of the pod from the downward API. Stats are not available.
+type PodClient struct {
+	httpClient    *http.Client
+	apiURL        string
+	tokenFile     string
+	name          string
+	namespace     string
+	ip            string
+	infoDir       string
+	containerName string
+}
+
+// NewPodClient creates a client for the pod given by ECS_LOCAL_POD_NAME, which uses the service account of the pod
+func NewPodClient() (*PodClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("Invalid %s: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set, so Local Endpoints is not running in a pod", config.PodNameVar)
+	}
+	caCert, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the CA certificate of the service account")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("Invalid CA certificate in %s", filepath.Join(serviceAccountDir, "ca.crt"))
+	}
+
+	namespace := os.Getenv(config.PodNamespaceVar)
+	if namespace == "" {
+		data, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read the namespace of the service account; set %s", config.PodNamespaceVar)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	infoDir := os.Getenv(config.PodInfoDirVar)
+	if infoDir == "" {
+		infoDir = config.DefaultPodInfoDir
+	}
+	containerName := os.Getenv(config.PodContainerNameVar)
+	if containerName == "" {
+		containerName = config.DefaultPodContainerName
+	}
+
+	return &PodClient{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+		apiURL:        "https://" + net.JoinHostPort(host, port),
+		tokenFile:     filepath.Join(serviceAccountDir, "token"),
+		name:          os.Getenv(config.PodNameVar),
+		namespace:     namespace,
+		ip:            os.Getenv(config.PodIPVar),
+		infoDir:       infoDir,
+		containerName: containerName,
+	}, nil
+}
+
+// podResource is the part of a Kubernetes pod which task metadata is synthesized from
+type podResource struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []podContainer `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		PodIP             string               `json:"podIP"`
+		ContainerStatuses []podContainerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type podContainer struct {
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	Resources struct {
+		Limits map[string]string `json:"limits"`
+	} `json:"resources"`
+}
+
+type podContainerStatus struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+	ImageID     string `json:"imageID"`
+	State       struct {
+		Running *struct {
+			StartedAt time.Time `json:"startedAt"`
+		} `json:"running"`
+	} `json:"state"`
+}
+
+// ContainerList returns the running containers of the pod, except for Local Endpoints, which stands in for the
+// ECS agent and so is not part of the task
+func (c *PodClient) ContainerList(ctx context.Context) ([]types.Container, error) {
+	pod, err := c.getPod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var containers []types.Container
+	for _, spec := range pod.Spec.Containers {
+		if spec.Name == c.containerName {
+			continue
+		}
+		status := getContainerStatus(pod, spec.Name)
+		if status == nil || status.State.Running == nil {
+			continue
+		}
+		containers = append(containers, types.Container{
+			ID:      getContainerID(status.ContainerID),
+			Names:   []string{"/" + spec.Name},
+			Image:   spec.Image,
+			ImageID: status.ImageID,
+			Created: status.State.Running.StartedAt.Unix(),
+			Labels:  c.getLabels(pod, spec.Name),
+			State:   "running",
+			Status:  "Up",
+			NetworkSettings: &types.SummaryNetworkSettings{
+				Networks: c.getNetworks(pod),
+			},
+		})
+	}
+	return containers, nil
+}
+
+// ContainerInspect returns the state, limits and network of a container in the pod
+func (c *PodClient) ContainerInspect(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	pod, err := c.getPod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range pod.Spec.Containers {
+		status := getContainerStatus(pod, spec.Name)
+		if status == nil || status.State.Running == nil || getContainerID(status.ContainerID) != longContainerID {
+			continue
+		}
+		resources, err := getResources(spec.Resources.Limits)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid resource limits of container %s", spec.Name)
+		}
+		startedAt := status.State.Running.StartedAt.Format(time.RFC3339Nano)
+		return &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:      longContainerID,
+				Created: startedAt,
+				Name:    "/" + spec.Name,
+				Image:   status.ImageID,
+				State: &types.ContainerState{
+					Status:    "running",
+					Running:   true,
+					StartedAt: startedAt,
+				},
+				HostConfig: &container.HostConfig{
+					Resources: resources,
+				},
+			},
+			Config: &container.Config{
+				Image:  spec.Image,
+				Labels: c.getLabels(pod, spec.Name),
+			},
+			NetworkSettings: &types.NetworkSettings{
+				Networks: c.getNetworks(pod),
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("No running container with ID %s in pod %s", longContainerID, c.name)
+}
+
+// ContainerInspectWithSize returns the same as ContainerInspect, since the size of the writable layer is not in the pod
+func (c *PodClient) ContainerInspectWithSize(ctx context.Context, longContainerID string) (*types.ContainerJSON, error) {
+	return c.ContainerInspect(ctx, longContainerID)
+}
+
+// ContainerStats is not supported, since the kubelet has the stats of the containers
+func (c *PodClient) ContainerStats(ctx context.Context, longContainerID string) (*types.StatsJSON, error) {
+	return nil, fmt.Errorf("Container stats are not available when Local Endpoints runs in a pod")
+}
+
+// ContainerStatsStream is not supported, since the kubelet has the stats of the containers
+func (c *PodClient) ContainerStatsStream(ctx context.Context, longContainerID string, statsChan chan<- *types.StatsJSON) error {
+	return fmt.Errorf("Container stats are not available when Local Endpoints runs in a pod")
+}
+
+// getPod gets the pod from the Kubernetes API, which requires the service account to be allowed to get pods
+func (c *PodClient) getPod(ctx context.Context) (*podResource, error) {
+	// service account tokens are rotated, so the file is read for each request
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the service account token")
+	}
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s", c.apiURL, c.namespace, c.name)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get pod %s", c.name)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read pod %s", c.name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &status)
+		return nil, fmt.Errorf("Failed to get pod %s in namespace %s: %s %s; the service account of the pod must be allowed to get pods", c.name, c.namespace, resp.Status, status.Message)
+	}
+
+	result := &podResource{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse pod %s", c.name)
+	}
+	return result, nil
+}
+
+// getLabels returns the labels of a container: the labels of the pod, the annotations for Local Endpoints, and the
+// Compose labels which make the pod a task. The downward API files take precedence over the pod from the API.
+func (c *PodClient) getLabels(pod *podResource, containerName string) map[string]string {
+	podLabels := pod.Metadata.Labels
+	if downward, err := readDownwardAPIFile(filepath.Join(c.infoDir, "labels")); err == nil {
+		podLabels = downward
+	}
+	annotations := pod.Metadata.Annotations
+	if downward, err := readDownwardAPIFile(filepath.Join(c.infoDir, "annotations")); err == nil {
+		annotations = downward
+	}
+
+	labels := make(map[string]string)
+	for key, value := range podLabels {
+		labels[key] = value
+	}
+	for key, value := range annotations {
+		if strings.HasPrefix(key, localEndpointsPrefix) {
+			labels[key] = value
+		}
+	}
+	labels[metadata.ComposeProjectLabel] = c.name
+	labels[metadata.ComposeServiceLabel] = containerName
+	return labels
+}
+
+func (c *PodClient) getNetworks(pod *podResource) map[string]*network.EndpointSettings {
+	ip := c.ip
+	if ip == "" {
+		ip = pod.Status.PodIP
+	}
+	settings := &network.EndpointSettings{}
+	if strings.Contains(ip, ":") {
+		settings.GlobalIPv6Address = ip
+	} else {
+		settings.IPAddress = ip
+	}
+	return map[string]*network.EndpointSettings{
+		podNetworkName: settings,
+	}
+}
+
+func getContainerStatus(pod *podResource, containerName string) *podContainerStatus {
+	for i, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// container IDs in the pod status have the runtime as a prefix: containerd://<ID>
+func getContainerID(statusID string) string {
+	if i := strings.Index(statusID, "://"); i >= 0 {
+		return statusID[i+3:]
+	}
+	return statusID
+}
+
+// readDownwardAPIFile reads the labels or annotations file of a downward API volume, which has a line with
+// key="value" for each one; the values are quoted as Go strings
+func readDownwardAPIFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("Invalid line in %s: %s", path, line)
+		}
+		value, err := strconv.Unquote(split[1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value of %s in %s: %s", split[0], path, split[1])
+		}
+		values[split[0]] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	podName     = "my-app-7d4b9c"
+	namespace   = "dev"
+	podIP       = "10.244.0.12"
+	appID       = "4b9d1a3c0e7f8a2b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b"
+	endpointsID = "9f8e7d6c5b4a39281706f5e4d3c2b1a0f9e8d7c6b5a493827160f5e4d3c2b1a0"
+	podJSON     = `{
+  "metadata": {
+    "name": "my-app-7d4b9c",
+    "labels": {"app": "my-app"},
+    "annotations": {"ecs-local.task-role": "my-role", "kubectl.kubernetes.io/restartedAt": "now"}
+  },
+  "spec": {
+    "containers": [
+      {"name": "app", "image": "my-app:latest", "resources": {"limits": {"cpu": "500m", "memory": "256Mi"}}},
+      {"name": "ecs-local-endpoints", "image": "amazon/amazon-ecs-local-container-endpoints"},
+      {"name": "init-db", "image": "my-db-init"}
+    ]
+  },
+  "status": {
+    "podIP": "10.244.0.12",
+    "containerStatuses": [
+      {"name": "app", "containerID": "containerd://4b9d1a3c0e7f8a2b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b", "imageID": "sha256:app", "state": {"running": {"startedAt": "2019-03-01T12:00:00Z"}}},
+      {"name": "ecs-local-endpoints", "containerID": "containerd://9f8e7d6c5b4a39281706f5e4d3c2b1a0f9e8d7c6b5a493827160f5e4d3c2b1a0", "state": {"running": {"startedAt": "2019-03-01T12:00:00Z"}}},
+      {"name": "init-db", "containerID": "containerd://0123", "state": {"terminated": {"exitCode": 0}}}
+    ]
+  }
+}`
+)
+
+func newPodClientInTest(t *testing.T, handler http.HandlerFunc) (*PodClient, func()) {
+	server := httptest.NewServer(handler)
+	dir, err := ioutil.TempDir("", "pod")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	tokenFile := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(tokenFile, []byte("service-account-token\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing token file")
+
+	client := &PodClient{
+		httpClient:    server.Client(),
+		apiURL:        server.URL,
+		tokenFile:     tokenFile,
+		name:          podName,
+		namespace:     namespace,
+		infoDir:       dir,
+		containerName: "ecs-local-endpoints",
+	}
+	return client, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func servePod(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/dev/pods/my-app-7d4b9c", r.URL.Path, "Expected path to match")
+		assert.Equal(t, "Bearer service-account-token", r.Header.Get("Authorization"), "Expected the service account token")
+		w.Write([]byte(podJSON))
+	}
+}
+
+func TestPodClientContainerList(t *testing.T) {
+	client, cleanup := newPodClientInTest(t, servePod(t))
+	defer cleanup()
+
+	containers, err := client.ContainerList(context.Background())
+	assert.NoError(t, err, "Unexpected error listing containers")
+	assert.Len(t, containers, 1, "Expected only the running app container")
+	app := containers[0]
+	assert.Equal(t, appID, app.ID, "Expected the ID without the runtime")
+	assert.Equal(t, []string{"/app"}, app.Names, "Expected name to match")
+	assert.Equal(t, "my-app:latest", app.Image, "Expected image to match")
+	assert.Equal(t, "sha256:app", app.ImageID, "Expected image ID to match")
+	assert.Equal(t, map[string]string{
+		"app":                        "my-app",
+		"ecs-local.task-role":        "my-role",
+		"com.docker.compose.project": podName,
+		"com.docker.compose.service": "app",
+	}, app.Labels, "Expected labels to match")
+	assert.Equal(t, podIP, app.NetworkSettings.Networks["pod"].IPAddress, "Expected the pod IP")
+}
+
+func TestPodClientDownwardAPI(t *testing.T) {
+	client, cleanup := newPodClientInTest(t, servePod(t))
+	defer cleanup()
+	client.ip = "fd00::12"
+	err := ioutil.WriteFile(filepath.Join(client.infoDir, "labels"), []byte("app=\"my-app\"\nversion=\"v2\"\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing labels file")
+	err = ioutil.WriteFile(filepath.Join(client.infoDir, "annotations"), []byte("ecs-local.task-role=\"arn:aws:iam::111111111111:role/other\"\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing annotations file")
+
+	containers, err := client.ContainerList(context.Background())
+	assert.NoError(t, err, "Unexpected error listing containers")
+	assert.Len(t, containers, 1, "Expected only the running app container")
+	assert.Equal(t, "v2", containers[0].Labels["version"], "Expected the labels file to be used")
+	assert.Equal(t, "arn:aws:iam::111111111111:role/other", containers[0].Labels["ecs-local.task-role"], "Expected the annotations file to be used")
+	assert.Equal(t, "fd00::12", containers[0].NetworkSettings.Networks["pod"].GlobalIPv6Address, "Expected the IP from the downward API")
+}
+
+func TestPodClientContainerInspect(t *testing.T) {
+	client, cleanup := newPodClientInTest(t, servePod(t))
+	defer cleanup()
+
+	details, err := client.ContainerInspect(context.Background(), appID)
+	assert.NoError(t, err, "Unexpected error inspecting container")
+	assert.Equal(t, "/app", details.Name, "Expected name to match")
+	assert.True(t, details.State.Running, "Expected container to be running")
+	assert.Equal(t, "2019-03-01T12:00:00Z", details.State.StartedAt, "Expected start time to match")
+	assert.Equal(t, int64(500000000), details.HostConfig.NanoCPUs, "Expected CPU limit to match")
+	assert.Equal(t, int64(256*1024*1024), details.HostConfig.Memory, "Expected memory limit to match")
+
+	_, err = client.ContainerInspect(context.Background(), endpointsID[:12])
+	assert.Error(t, err, "Expected error for an unknown container ID")
+}
+
+func TestPodClientForbidden(t *testing.T) {
+	client, cleanup := newPodClientInTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"kind":"Status","message":"pods \"my-app-7d4b9c\" is forbidden"}`))
+	})
+	defer cleanup()
+
+	_, err := client.ContainerList(context.Background())
+	assert.Error(t, err, "Expected error when the service account can't get pods")
+	assert.Contains(t, err.Error(), "is forbidden", "Expected the message of the API")
+}
+
+func TestReadDownwardAPIFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "podinfo")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "annotations")
+
+	err = ioutil.WriteFile(path, []byte("a=\"1\"\nb=\"say \\\"hi\\\"\"\n\nc=\"x=y\"\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing file")
+	values, err := readDownwardAPIFile(path)
+	assert.NoError(t, err, "Unexpected error reading file")
+	assert.Equal(t, map[string]string{"a": "1", "b": "say \"hi\"", "c": "x=y"}, values, "Expected values to match")
+
+	err = ioutil.WriteFile(path, []byte("a=1\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing file")
+	_, err = readDownwardAPIFile(path)
+	assert.Error(t, err, "Expected error for an unquoted value")
+
+	_, err = readDownwardAPIFile(filepath.Join(dir, "missing"))
+	assert.Error(t, err, "Expected error for a missing file")
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// quantitySuffixes are the multipliers of the suffixes of Kubernetes resource quantities, longest first
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"Pi", 1 << 50},
+	{"Ei", 1 << 60},
+	{"m", 1e-3},
+	{"k", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+	{"P", 1e15},
+	{"E", 1e18},
+}
+
+// parseQuantity parses a Kubernetes resource quantity, such as 500m CPU or 512Mi of memory
+func parseQuantity(quantity string) (float64, error) {
+	number, multiplier := quantity, 1.0
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(quantity, s.suffix) {
+			number, multiplier = strings.TrimSuffix(quantity, s.suffix), s.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid quantity %s", quantity)
+	}
+	return value * multiplier, nil
+}
+
+// getResources converts the CPU and memory limits of a container to the Docker settings with the same effect
+func getResources(limits map[string]string) (container.Resources, error) {
+	var resources container.Resources
+	if cpu, ok := limits["cpu"]; ok {
+		cpus, err := parseQuantity(cpu)
+		if err != nil {
+			return resources, err
+		}
+		resources.NanoCPUs = int64(cpus * 1e9)
+	}
+	if memory, ok := limits["memory"]; ok {
+		bytes, err := parseQuantity(memory)
+		if err != nil {
+			return resources, err
+		}
+		resources.Memory = int64(bytes)
+	}
+	return resources, nil
+}
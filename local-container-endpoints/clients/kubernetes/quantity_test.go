@@ -0,0 +1,60 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuantity(t *testing.T) {
+	var testCases = []struct {
+		quantity string
+		expected float64
+	}{
+		{"2", 2},
+		{"0.5", 0.5},
+		{"250m", 0.25},
+		{"128Mi", 128 * 1024 * 1024},
+		{"1Gi", 1024 * 1024 * 1024},
+		{"1G", 1e9},
+		{"64k", 64000},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := parseQuantity(testCase.quantity)
+		assert.NoError(t, err, "Unexpected error parsing %s", testCase.quantity)
+		assert.Equal(t, testCase.expected, actual, "Expected value of %s to match", testCase.quantity)
+	}
+
+	for _, quantity := range []string{"", "Mi", "lots", "-1"} {
+		_, err := parseQuantity(quantity)
+		assert.Error(t, err, "Expected error for %s", quantity)
+	}
+}
+
+func TestGetResources(t *testing.T) {
+	resources, err := getResources(map[string]string{"cpu": "1500m", "memory": "512Mi"})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int64(1500000000), resources.NanoCPUs, "Expected CPU to match")
+	assert.Equal(t, int64(512*1024*1024), resources.Memory, "Expected memory to match")
+
+	resources, err = getResources(nil)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int64(0), resources.NanoCPUs, "Expected no CPU limit")
+
+	_, err = getResources(map[string]string{"memory": "a lot"})
+	assert.Error(t, err, "Expected error for an invalid limit")
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package proxy configures the outbound proxy of the clients for AWS and Docker
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+)
+
+// Settings are the proxies for outbound HTTP and HTTPS requests, and the hosts which are reached directly
+type Settings struct {
+	HTTPProxy  *url.URL
+	HTTPSProxy *url.URL
+	NoProxy    []string
+}
+
+// FromEnvironment returns the proxy settings from ECS_LOCAL_PROXY and ECS_LOCAL_NO_PROXY, or else from the standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables; the proxy username and password are added to both proxy URLs
+func FromEnvironment() (*Settings, error) {
+	settings := &Settings{}
+	var err error
+	if value := os.Getenv(config.ProxyVar); value != "" {
+		if settings.HTTPProxy, err = parseProxyURL(config.ProxyVar, value); err != nil {
+			return nil, err
+		}
+		settings.HTTPSProxy = settings.HTTPProxy
+	} else {
+		if settings.HTTPProxy, err = parseProxyURL("HTTP_PROXY", getEnvAnyCase("HTTP_PROXY")); err != nil {
+			return nil, err
+		}
+		if settings.HTTPSProxy, err = parseProxyURL("HTTPS_PROXY", getEnvAnyCase("HTTPS_PROXY")); err != nil {
+			return nil, err
+		}
+	}
+
+	noProxy, ok := os.LookupEnv(config.NoProxyVar)
+	if !ok {
+		noProxy = getEnvAnyCase("NO_PROXY")
+	}
+	for _, entry := range strings.Split(noProxy, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			settings.NoProxy = append(settings.NoProxy, entry)
+		}
+	}
+
+	username := os.Getenv(config.ProxyUsernameVar)
+	password := os.Getenv(config.ProxyPasswordVar)
+	if username == "" && password != "" {
+		return nil, fmt.Errorf("Invalid %s: %s must also be set", config.ProxyPasswordVar, config.ProxyUsernameVar)
+	}
+	if username != "" {
+		if settings.HTTPProxy == nil && settings.HTTPSProxy == nil {
+			return nil, fmt.Errorf("Invalid %s: a proxy must be set with %s, HTTPS_PROXY or HTTP_PROXY", config.ProxyUsernameVar, config.ProxyVar)
+		}
+		settings.HTTPProxy = withUser(settings.HTTPProxy, username, password)
+		settings.HTTPSProxy = withUser(settings.HTTPSProxy, username, password)
+	}
+	return settings, nil
+}
+
+// Enabled returns true if either proxy is set
+func (s *Settings) Enabled() bool {
+	return s.HTTPProxy != nil || s.HTTPSProxy != nil
+}
+
+// Func returns the proxy for a request, for use as the Proxy of an http.Transport; requests to loopback
+// addresses and to the hosts in NoProxy are not proxied. The transport sends the credentials in the proxy URL
+// with Basic authentication.
+func (s *Settings) Func() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL := s.HTTPProxy
+		if req.URL.Scheme == "https" {
+			proxyURL = s.HTTPSProxy
+		}
+		if proxyURL == nil || !s.useProxy(req.URL.Host) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// Apply sets the proxy of the transport, which is left alone if no proxy is set so that a transport
+// configured from the environment keeps working
+func (s *Settings) Apply(transport *http.Transport) {
+	if s.Enabled() {
+		transport.Proxy = s.Func()
+	}
+}
+
+// String describes the settings for the log, without the proxy password
+func (s *Settings) String() string {
+	if !s.Enabled() {
+		return "no proxy"
+	}
+	description := fmt.Sprintf("HTTP proxy %s, HTTPS proxy %s", redact(s.HTTPProxy), redact(s.HTTPSProxy))
+	if len(s.NoProxy) > 0 {
+		description += fmt.Sprintf(", except for %s", strings.Join(s.NoProxy, ","))
+	}
+	return description
+}
+
+// useProxy returns false if the host, which may have a port, matches an entry of NoProxy: '*', an IP address,
+// a CIDR block, or a domain name which also matches its subdomains, each with an optional port
+func (s *Settings) useProxy(hostPort string) bool {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	for _, entry := range s.NoProxy {
+		if entry == "*" {
+			return false
+		}
+		if _, block, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && block.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+		entryHost = strings.Trim(entryHost, "[]")
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return false
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseProxyURL(envVar, value string) (*url.URL, error) {
+	if value == "" {
+		return nil, nil
+	}
+	// a proxy without a scheme, such as proxy.example.com:3128, is reached over HTTP
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("Invalid %s: %s is not a URL, such as http://proxy.example.com:3128", envVar, value)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("Invalid %s: the scheme of %s must be http, https or socks5", envVar, value)
+	}
+	return proxyURL, nil
+}
+
+func withUser(proxyURL *url.URL, username, password string) *url.URL {
+	if proxyURL == nil {
+		return nil
+	}
+	withUser := *proxyURL
+	withUser.User = url.UserPassword(username, password)
+	return &withUser
+}
+
+func redact(proxyURL *url.URL) string {
+	if proxyURL == nil {
+		return "none"
+	}
+	return proxyURL.Redacted()
+}
+
+func getEnvAnyCase(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToLower(name))
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package retry retries STS and IAM requests which are throttled, with exponential backoff,
+// so that bursts of requests from many local containers don't fail.
+package retry
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxRetries is the number of times throttled requests are retried by default
+	DefaultMaxRetries = 5
+
+	baseDelay = 200 * time.Millisecond
+	maxDelay  = 5 * time.Second
+)
+
+// Retryer retries requests which fail with the throttling error codes of the AWS SDK
+type Retryer struct {
+	maxRetries int
+	sleep      func(ctx aws.Context, delay time.Duration) error
+}
+
+// NewRetryer returns a Retryer which retries throttled requests up to maxRetries times
+func NewRetryer(maxRetries int) *Retryer {
+	return &Retryer{
+		maxRetries: maxRetries,
+		sleep:      aws.SleepWithContext,
+	}
+}
+
+// Do calls fn until it succeeds, fails with an error which is not a throttling error, or the retries are used up
+func (retryer *Retryer) Do(ctx aws.Context, operation string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retryer.maxRetries || !request.IsErrorThrottle(err) {
+			return err
+		}
+		delay := getDelay(attempt)
+		entry := logrus.NewEntry(logrus.StandardLogger())
+		if requestID := tracing.RequestID(ctx); requestID != "" {
+			entry = entry.WithField("request_id", requestID)
+		}
+		entry.Debugf("%s was throttled, retrying in %s: %s", operation, delay, err)
+		if err := retryer.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// The delay doubles with each attempt, up to the max delay, and up to half of it is random
+// so that the requests of concurrent callers are spread out
+func getDelay(attempt int) time.Duration {
+	delay := maxDelay
+	if attempt < 8 {
+		if exponential := baseDelay << uint(attempt); exponential < maxDelay {
+			delay = exponential
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+type stsClient struct {
+	stsiface.STSAPI
+	retryer *Retryer
+}
+
+// NewSTSClient returns an STS client which retries throttled sts:AssumeRole and sts:GetSessionToken requests
+func NewSTSClient(client stsiface.STSAPI, retryer *Retryer) stsiface.STSAPI {
+	return &stsClient{
+		STSAPI:  client,
+		retryer: retryer,
+	}
+}
+
+func (client *stsClient) AssumeRole(input *sts.AssumeRoleInput) (output *sts.AssumeRoleOutput, err error) {
+	err = client.retryer.Do(aws.BackgroundContext(), "sts:AssumeRole", func() error {
+		output, err = client.STSAPI.AssumeRole(input)
+		return err
+	})
+	return output, err
+}
+
+func (client *stsClient) AssumeRoleWithContext(ctx aws.Context, input *sts.AssumeRoleInput, opts ...request.Option) (output *sts.AssumeRoleOutput, err error) {
+	err = client.retryer.Do(ctx, "sts:AssumeRole", func() error {
+		output, err = client.STSAPI.AssumeRoleWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (client *stsClient) GetSessionToken(input *sts.GetSessionTokenInput) (output *sts.GetSessionTokenOutput, err error) {
+	err = client.retryer.Do(aws.BackgroundContext(), "sts:GetSessionToken", func() error {
+		output, err = client.STSAPI.GetSessionToken(input)
+		return err
+	})
+	return output, err
+}
+
+func (client *stsClient) GetSessionTokenWithContext(ctx aws.Context, input *sts.GetSessionTokenInput, opts ...request.Option) (output *sts.GetSessionTokenOutput, err error) {
+	err = client.retryer.Do(ctx, "sts:GetSessionToken", func() error {
+		output, err = client.STSAPI.GetSessionTokenWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+type iamClient struct {
+	iamiface.IAMAPI
+	retryer *Retryer
+}
+
+// NewIAMClient returns an IAM client which retries throttled iam:GetRole requests
+func NewIAMClient(client iamiface.IAMAPI, retryer *Retryer) iamiface.IAMAPI {
+	return &iamClient{
+		IAMAPI:  client,
+		retryer: retryer,
+	}
+}
+
+func (client *iamClient) GetRole(input *iam.GetRoleInput) (output *iam.GetRoleOutput, err error) {
+	err = client.retryer.Do(aws.BackgroundContext(), "iam:GetRole", func() error {
+		output, err = client.IAMAPI.GetRole(input)
+		return err
+	})
+	return output, err
+}
+
+func (client *iamClient) GetRoleWithContext(ctx aws.Context, input *iam.GetRoleInput, opts ...request.Option) (output *iam.GetRoleOutput, err error) {
+	err = client.retryer.Do(ctx, "iam:GetRole", func() error {
+		output, err = client.IAMAPI.GetRoleWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package retry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/iam/mock_iamiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRetryer(maxRetries int, delays *[]time.Duration) *Retryer {
+	retryer := NewRetryer(maxRetries)
+	retryer.sleep = func(ctx aws.Context, delay time.Duration) error {
+		*delays = append(*delays, delay)
+		return nil
+	}
+	return retryer
+}
+
+func TestDo(t *testing.T) {
+	var delays []time.Duration
+	retryer := newTestRetryer(3, &delays)
+
+	attempts := 0
+	err := retryer.Do(aws.BackgroundContext(), "test", func() error {
+		attempts++
+		if attempts < 3 {
+			return awserr.New("Throttling", "Rate exceeded", nil)
+		}
+		return nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3, attempts, "Expected throttled requests to be retried")
+	assert.Len(t, delays, 2, "Expected a delay before each retry")
+}
+
+func TestDoRetriesUsedUp(t *testing.T) {
+	var delays []time.Duration
+	retryer := newTestRetryer(2, &delays)
+
+	attempts := 0
+	err := retryer.Do(aws.BackgroundContext(), "test", func() error {
+		attempts++
+		return awserr.New("ThrottlingException", "Rate exceeded", nil)
+	})
+	assert.Error(t, err, "Expected error once the retries are used up")
+	assert.Equal(t, 3, attempts, "Expected the first attempt and 2 retries")
+}
+
+func TestDoOtherErrors(t *testing.T) {
+	var delays []time.Duration
+	retryer := newTestRetryer(3, &delays)
+
+	attempts := 0
+	err := retryer.Do(aws.BackgroundContext(), "test", func() error {
+		attempts++
+		return awserr.New("AccessDenied", "Not authorized", nil)
+	})
+	assert.Error(t, err, "Expected error")
+	assert.Equal(t, 1, attempts, "Expected errors which are not throttling errors to not be retried")
+
+	attempts = 0
+	retryer.Do(aws.BackgroundContext(), "test", func() error {
+		attempts++
+		return fmt.Errorf("Some error")
+	})
+	assert.Equal(t, 1, attempts, "Expected errors which are not AWS errors to not be retried")
+}
+
+func TestGetDelay(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		expected := maxDelay
+		if attempt < 5 {
+			expected = baseDelay << uint(attempt)
+		}
+		delay := getDelay(attempt)
+		assert.True(t, delay >= expected/2 && delay <= expected, "Expected delay %s for attempt %d to be between %s and %s", delay, attempt, expected/2, expected)
+	}
+}
+
+func TestSTSClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	stsMock := mock_stsiface.NewMockSTSAPI(ctrl)
+	var delays []time.Duration
+	client := NewSTSClient(stsMock, newTestRetryer(3, &delays))
+
+	output := &sts.AssumeRoleOutput{}
+	gomock.InOrder(
+		stsMock.EXPECT().AssumeRole(gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil)),
+		stsMock.EXPECT().AssumeRole(gomock.Any()).Return(output, nil),
+		stsMock.EXPECT().GetSessionToken(gomock.Any()).Return(nil, awserr.New("RequestLimitExceeded", "Request limit exceeded", nil)),
+		stsMock.EXPECT().GetSessionToken(gomock.Any()).Return(&sts.GetSessionTokenOutput{}, nil),
+		stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil)),
+		stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetSessionTokenOutput{}, nil),
+		stsMock.EXPECT().GetCallerIdentity(gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil)),
+	)
+
+	result, err := client.AssumeRole(&sts.AssumeRoleInput{})
+	assert.NoError(t, err, "Unexpected error calling AssumeRole")
+	assert.Equal(t, output, result, "Expected output to match")
+	_, err = client.GetSessionToken(&sts.GetSessionTokenInput{})
+	assert.NoError(t, err, "Unexpected error calling GetSessionToken")
+	_, err = client.GetSessionTokenWithContext(aws.BackgroundContext(), &sts.GetSessionTokenInput{})
+	assert.NoError(t, err, "Unexpected error calling GetSessionTokenWithContext")
+	// other operations are not retried
+	_, err = client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	assert.Error(t, err, "Expected error calling GetCallerIdentity")
+}
+
+func TestIAMClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	iamMock := mock_iamiface.NewMockIAMAPI(ctrl)
+	var delays []time.Duration
+	client := NewIAMClient(iamMock, newTestRetryer(3, &delays))
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil)),
+		iamMock.EXPECT().GetRole(gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String("arn:aws:iam::111111111111:role/my-role"),
+			},
+		}, nil),
+	)
+
+	output, err := client.GetRole(&iam.GetRoleInput{})
+	assert.NoError(t, err, "Unexpected error calling GetRole")
+	assert.Equal(t, "arn:aws:iam::111111111111:role/my-role", aws.StringValue(output.Role.Arn), "Expected role ARN to match")
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil)),
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{}, nil),
+	)
+	_, err = client.GetRoleWithContext(aws.BackgroundContext(), &iam.GetRoleInput{})
+	assert.NoError(t, err, "Unexpected error calling GetRoleWithContext")
+	assert.Len(t, delays, 2, "Expected a delay before each retry")
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sessiontags adds session tags to sts:AssumeRole requests.
+// The version of the AWS SDK used by Local Endpoints predates session tags, so
+// they are added to the request parameters after the SDK builds the request.
+package sessiontags
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pkg/errors"
+)
+
+// WithSessionTags returns a request option which adds the tags to an sts:AssumeRole request
+func WithSessionTags(tags map[string]string) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBackNamed(request.NamedHandler{
+			Name: "ECSLocalEndpointsSessionTagsHandler",
+			Fn: func(r *request.Request) {
+				addSessionTags(r, tags)
+			},
+		})
+	}
+}
+
+// addSessionTags adds the tags using the query protocol format of the STS API:
+// Tags.member.1.Key=key&Tags.member.1.Value=value
+func addSessionTags(r *request.Request, tags map[string]string) {
+	if r.Error != nil || len(tags) == 0 {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.GetBody())
+	if err != nil {
+		r.Error = errors.Wrap(err, "failed to add session tags to the request")
+		return
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		r.Error = errors.Wrap(err, "failed to add session tags to the request")
+		return
+	}
+
+	// sorted so that the request is deterministic
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		params.Set(fmt.Sprintf("Tags.member.%d.Key", i+1), key)
+		params.Set(fmt.Sprintf("Tags.member.%d.Value", i+1), tags[key])
+	}
+
+	r.SetBufferBody([]byte(params.Encode()))
+}
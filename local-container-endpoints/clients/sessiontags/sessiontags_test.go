@@ -0,0 +1,77 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sessiontags
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSessionTags(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("AKID", "SKID", ""),
+		Region:      aws.String("us-west-2"),
+	})
+	assert.NoError(t, err, "Unexpected error creating session")
+
+	req, _ := sts.New(sess).AssumeRoleRequest(&sts.AssumeRoleInput{
+		RoleArn:         aws.String("arn:aws:iam::111111111111:role/clyde"),
+		RoleSessionName: aws.String("ecs-local-clyde"),
+	})
+	req.ApplyOptions(WithSessionTags(map[string]string{
+		"team":    "containers",
+		"project": "local",
+	}))
+	err = req.Build()
+	assert.NoError(t, err, "Unexpected error building request")
+
+	body, err := ioutil.ReadAll(req.GetBody())
+	assert.NoError(t, err, "Unexpected error reading request body")
+	params, err := url.ParseQuery(string(body))
+	assert.NoError(t, err, "Unexpected error parsing request body")
+
+	assert.Equal(t, "AssumeRole", params.Get("Action"), "Expected the original parameters to be kept")
+	assert.Equal(t, "arn:aws:iam::111111111111:role/clyde", params.Get("RoleArn"), "Expected the original parameters to be kept")
+	assert.Equal(t, "project", params.Get("Tags.member.1.Key"), "Expected tag key to match")
+	assert.Equal(t, "local", params.Get("Tags.member.1.Value"), "Expected tag value to match")
+	assert.Equal(t, "team", params.Get("Tags.member.2.Key"), "Expected tag key to match")
+	assert.Equal(t, "containers", params.Get("Tags.member.2.Value"), "Expected tag value to match")
+}
+
+func TestWithSessionTagsEmpty(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("AKID", "SKID", ""),
+		Region:      aws.String("us-west-2"),
+	})
+	assert.NoError(t, err, "Unexpected error creating session")
+
+	req, _ := sts.New(sess).AssumeRoleRequest(&sts.AssumeRoleInput{
+		RoleArn:         aws.String("arn:aws:iam::111111111111:role/clyde"),
+		RoleSessionName: aws.String("ecs-local-clyde"),
+	})
+	req.ApplyOptions(WithSessionTags(nil))
+	err = req.Build()
+	assert.NoError(t, err, "Unexpected error building request")
+
+	body, err := ioutil.ReadAll(req.GetBody())
+	assert.NoError(t, err, "Unexpected error reading request body")
+	assert.NotContains(t, string(body), "Tags.member", "Expected no tags")
+}
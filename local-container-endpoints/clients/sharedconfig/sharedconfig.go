@@ -0,0 +1,170 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sharedconfig reads profiles from the AWS shared config and credentials files
+package sharedconfig
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultProfile is the name of the profile used when none is configured
+	DefaultProfile = "default"
+
+	profileSectionPrefix    = "profile "
+	ssoSessionSectionPrefix = "sso-session "
+
+	configFileVar      = "AWS_CONFIG_FILE"
+	credentialsFileVar = "AWS_SHARED_CREDENTIALS_FILE"
+	profileVar         = "AWS_PROFILE"
+	defaultProfileVar  = "AWS_DEFAULT_PROFILE"
+)
+
+// Section holds the keys and values of a section of a shared config file
+type Section map[string]string
+
+// Config holds the profiles of the shared config and credentials files
+type Config struct {
+	profiles    map[string]Section
+	ssoSessions map[string]Section
+}
+
+// CurrentProfileName returns the name of the profile that the AWS SDK will use
+func CurrentProfileName() string {
+	if profile := os.Getenv(profileVar); profile != "" {
+		return profile
+	}
+	if profile := os.Getenv(defaultProfileVar); profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+// Load reads the shared config and credentials files from the locations used by the AWS SDK
+func Load() (*Config, error) {
+	files, err := Files()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFiles(files...)
+}
+
+// Files returns the paths of the shared config and credentials files used by the AWS SDK
+func Files() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	configFile := os.Getenv(configFileVar)
+	if configFile == "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+	credentialsFile := os.Getenv(credentialsFileVar)
+	if credentialsFile == "" {
+		credentialsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	return []string{configFile, credentialsFile}, nil
+}
+
+// LoadFiles reads the given files; files which do not exist are ignored.
+// Values in later files take precedence, as the credentials file does in the AWS SDK.
+func LoadFiles(files ...string) (*Config, error) {
+	config := &Config{
+		profiles:    make(map[string]Section),
+		ssoSessions: make(map[string]Section),
+	}
+	for _, file := range files {
+		sections, err := parseFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for name, section := range sections {
+			if strings.HasPrefix(name, ssoSessionSectionPrefix) {
+				config.ssoSessions[strings.TrimPrefix(name, ssoSessionSectionPrefix)] = section
+				continue
+			}
+			// the SDK accepts both '[name]' and '[profile name]' in either file
+			profileName := strings.TrimPrefix(name, profileSectionPrefix)
+			merged, ok := config.profiles[profileName]
+			if !ok {
+				merged = make(Section)
+				config.profiles[profileName] = merged
+			}
+			for key, value := range section {
+				merged[key] = value
+			}
+		}
+	}
+	return config, nil
+}
+
+// Profile returns the settings of the profile
+func (config *Config) Profile(name string) (Section, bool) {
+	profile, ok := config.profiles[name]
+	return profile, ok
+}
+
+// ProfileNames returns the names of the profiles, in sorted order
+func (config *Config) ProfileNames() []string {
+	var names []string
+	for name := range config.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SSOSession returns the settings of an sso-session section
+func (config *Config) SSOSession(name string) (Section, bool) {
+	session, ok := config.ssoSessions[name]
+	return session, ok
+}
+
+// parseFile reads an INI file into a map of section names to their keys and values
+func parseFile(file string) (map[string]Section, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(map[string]Section)
+	var current Section
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			current = make(Section)
+			sections[name] = current
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if current == nil || len(split) != 2 {
+			continue
+		}
+		current[strings.TrimSpace(split[0])] = strings.TrimSpace(split[1])
+	}
+	return sections, scanner.Err()
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sharedconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testConfigFile = `
+# comments are ignored
+[default]
+region = us-west-2
+
+[profile dev]
+region = us-east-1
+credential_process = /bin/creds --profile dev
+
+[sso-session my-sso]
+sso_start_url = https://session.awsapps.com/start
+`
+	testCredentialsFile = `
+[default]
+aws_access_key_id = AKID
+aws_secret_access_key = SKID
+
+[dev]
+region = eu-west-1
+`
+)
+
+func TestLoadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharedconfig")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte(testConfigFile), 0600), "Unexpected error writing config file")
+	assert.NoError(t, ioutil.WriteFile(credentialsFile, []byte(testCredentialsFile), 0600), "Unexpected error writing credentials file")
+
+	config, err := LoadFiles(configFile, credentialsFile, filepath.Join(dir, "missing"))
+	assert.NoError(t, err, "Unexpected error loading files")
+
+	profile, ok := config.Profile("default")
+	assert.True(t, ok, "Expected the default profile to exist")
+	assert.Equal(t, Section{
+		"region":                "us-west-2",
+		"aws_access_key_id":     "AKID",
+		"aws_secret_access_key": "SKID",
+	}, profile, "Expected default profile to match")
+
+	profile, ok = config.Profile("dev")
+	assert.True(t, ok, "Expected the dev profile to exist")
+	assert.Equal(t, "eu-west-1", profile["region"], "Expected the credentials file to take precedence")
+	assert.Equal(t, "/bin/creds --profile dev", profile["credential_process"], "Expected credential process to match")
+
+	ssoSession, ok := config.SSOSession("my-sso")
+	assert.True(t, ok, "Expected the sso-session to exist")
+	assert.Equal(t, "https://session.awsapps.com/start", ssoSession["sso_start_url"], "Expected start URL to match")
+
+	_, ok = config.Profile("my-sso")
+	assert.False(t, ok, "Expected sso-session sections to not be profiles")
+	assert.Equal(t, []string{"default", "dev"}, config.ProfileNames(), "Expected profile names to match")
+}
+
+func TestCurrentProfileName(t *testing.T) {
+	defer os.Unsetenv(profileVar)
+	defer os.Unsetenv(defaultProfileVar)
+
+	os.Unsetenv(profileVar)
+	os.Unsetenv(defaultProfileVar)
+	assert.Equal(t, DefaultProfile, CurrentProfileName(), "Expected the default profile")
+
+	os.Setenv(defaultProfileVar, "legacy")
+	assert.Equal(t, "legacy", CurrentProfileName(), "Expected AWS_DEFAULT_PROFILE to be used")
+
+	os.Setenv(profileVar, "dev")
+	assert.Equal(t, "dev", CurrentProfileName(), "Expected AWS_PROFILE to take precedence")
+}
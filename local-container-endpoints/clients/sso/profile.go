@@ -0,0 +1,80 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sso
+
+import (
+	"fmt"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+)
+
+const (
+	startURLKey   = "sso_start_url"
+	regionKey     = "sso_region"
+	accountIDKey  = "sso_account_id"
+	roleNameKey   = "sso_role_name"
+	ssoSessionKey = "sso_session"
+)
+
+// Profile holds the SSO settings of a shared config profile
+type Profile struct {
+	Name      string
+	StartURL  string
+	Region    string
+	AccountID string
+	RoleName  string
+	// SessionName is set if the profile references an sso-session section
+	SessionName string
+}
+
+// cacheKey is the value which is hashed to find the profile's cached access token
+func (p *Profile) cacheKey() string {
+	if p.SessionName != "" {
+		return p.SessionName
+	}
+	return p.StartURL
+}
+
+// LoadProfile returns the SSO settings of the profile, or nil if the profile does not exist or does not use SSO
+func LoadProfile(config *sharedconfig.Config, profileName string) (*Profile, error) {
+	section, ok := config.Profile(profileName)
+	if !ok {
+		return nil, nil
+	}
+
+	profile := &Profile{
+		Name:        profileName,
+		StartURL:    section[startURLKey],
+		Region:      section[regionKey],
+		AccountID:   section[accountIDKey],
+		RoleName:    section[roleNameKey],
+		SessionName: section[ssoSessionKey],
+	}
+	if profile.SessionName != "" {
+		ssoSession, ok := config.SSOSession(profile.SessionName)
+		if !ok {
+			return nil, fmt.Errorf("profile %s references sso-session %s, which does not exist", profileName, profile.SessionName)
+		}
+		profile.StartURL = ssoSession[startURLKey]
+		profile.Region = ssoSession[regionKey]
+	}
+
+	if profile.StartURL == "" {
+		return nil, nil
+	}
+	if profile.Region == "" || profile.AccountID == "" || profile.RoleName == "" {
+		return nil, fmt.Errorf("profile %s is missing one of the required SSO settings: %s, %s, %s", profileName, regionKey, accountIDKey, roleNameKey)
+	}
+	return profile, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sso resolves credentials for shared config profiles that use AWS IAM Identity Center (SSO)
+package sso
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProviderName is the name of the SSO credentials provider
+	ProviderName = "SSOProvider"
+
+	bearerTokenHeader = "x-amz-sso_bearer_token"
+	// Older versions of the AWS CLI wrote the expiration with a UTC suffix instead of a time zone offset
+	legacyExpirationFormat = "2006-01-02T15:04:05UTC"
+)
+
+// Provider exchanges the access token cached by 'aws sso login' for role credentials
+type Provider struct {
+	credentials.Expiry
+
+	profile  *Profile
+	cacheDir string
+	client   *http.Client
+	// endpoint is the SSO portal URL; it can be replaced in tests
+	endpoint string
+}
+
+type cachedToken struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+type roleCredentialsOutput struct {
+	RoleCredentials struct {
+		AccessKeyID     string `json:"accessKeyId"`
+		SecretAccessKey string `json:"secretAccessKey"`
+		SessionToken    string `json:"sessionToken"`
+		// Expiration is in milliseconds since the epoch
+		Expiration int64 `json:"expiration"`
+	} `json:"roleCredentials"`
+}
+
+// CacheDir returns the directory where the AWS CLI caches SSO access tokens
+func CacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "sso", "cache"), nil
+}
+
+// NewProvider returns a provider which reads access tokens from the default SSO cache directory
+// The credentials are requested with the client, or with a client with a 30 second timeout if it is nil
+func NewProvider(profile *Profile, client *http.Client) (*Provider, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Provider{
+		profile:  profile,
+		cacheDir: cacheDir,
+		client:   client,
+		endpoint: fmt.Sprintf("https://portal.sso.%s.amazonaws.com", profile.Region),
+	}, nil
+}
+
+// Retrieve satisfies the credentials.Provider interface
+func (p *Provider) Retrieve() (credentials.Value, error) {
+	token, err := p.loadToken()
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+
+	query := url.Values{}
+	query.Set("account_id", p.profile.AccountID)
+	query.Set("role_name", p.profile.RoleName)
+	req, err := http.NewRequest(http.MethodGet, p.endpoint+"/federation/credentials?"+query.Encode(), nil)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+	req.Header.Set(bearerTokenHeader, token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrap(err, "failed to get SSO role credentials")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrap(err, "failed to get SSO role credentials")
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return credentials.Value{ProviderName: ProviderName}, p.loginError("the cached SSO access token was rejected")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return credentials.Value{ProviderName: ProviderName}, fmt.Errorf("failed to get SSO role credentials: HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	output := &roleCredentialsOutput{}
+	if err := json.Unmarshal(body, output); err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrap(err, "failed to parse SSO role credentials")
+	}
+
+	p.SetExpiration(time.Unix(0, output.RoleCredentials.Expiration*int64(time.Millisecond)), 0)
+	return credentials.Value{
+		AccessKeyID:     output.RoleCredentials.AccessKeyID,
+		SecretAccessKey: output.RoleCredentials.SecretAccessKey,
+		SessionToken:    output.RoleCredentials.SessionToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
+
+// loadToken returns the unexpired access token for the profile from the SSO cache
+func (p *Provider) loadToken() (string, error) {
+	hash := sha1.Sum([]byte(p.profile.cacheKey()))
+	cacheFile := filepath.Join(p.cacheDir, hex.EncodeToString(hash[:])+".json")
+
+	data, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", p.loginError(fmt.Sprintf("no cached SSO access token was found at %s", cacheFile))
+		}
+		return "", errors.Wrapf(err, "failed to read the cached SSO access token at %s", cacheFile)
+	}
+
+	token := &cachedToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return "", errors.Wrapf(err, "failed to parse the cached SSO access token at %s", cacheFile)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil {
+		expiresAt, err = time.Parse(legacyExpirationFormat, token.ExpiresAt)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse the expiration of the cached SSO access token at %s", cacheFile)
+		}
+	}
+	if token.AccessToken == "" || !expiresAt.After(time.Now()) {
+		return "", p.loginError("the cached SSO access token has expired")
+	}
+	return token.AccessToken, nil
+}
+
+func (p *Provider) loginError(reason string) error {
+	return fmt.Errorf("%s; run 'aws sso login --profile %s' on your machine, and make sure that the '.aws' directory is mounted into the Local Endpoints container", reason, p.profile.Name)
+}
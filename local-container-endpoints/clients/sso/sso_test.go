@@ -0,0 +1,203 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sso
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+[default]
+region = us-west-2
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111111111111
+sso_role_name = Developer
+
+[profile session]
+sso_session = my-sso
+sso_account_id = 222222222222
+sso_role_name = Admin
+
+[sso-session my-sso]
+sso_start_url = https://session.awsapps.com/start
+sso_region = eu-west-1
+
+[profile incomplete]
+sso_start_url = https://legacy.awsapps.com/start
+
+[profile missing-session]
+sso_session = nope
+`
+
+func loadTestConfig(t *testing.T) *sharedconfig.Config {
+	dir, err := ioutil.TempDir("", "sso")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(path, []byte(testConfig), 0600)
+	assert.NoError(t, err, "Unexpected error writing config file")
+	config, err := sharedconfig.LoadFiles(path)
+	assert.NoError(t, err, "Unexpected error loading config file")
+	return config
+}
+
+func TestLoadProfile(t *testing.T) {
+	config := loadTestConfig(t)
+
+	profile, err := LoadProfile(config, "legacy")
+	assert.NoError(t, err, "Unexpected error loading profile")
+	assert.Equal(t, &Profile{
+		Name:      "legacy",
+		StartURL:  "https://legacy.awsapps.com/start",
+		Region:    "us-east-1",
+		AccountID: "111111111111",
+		RoleName:  "Developer",
+	}, profile, "Expected profile to match")
+	assert.Equal(t, "https://legacy.awsapps.com/start", profile.cacheKey(), "Expected the start URL to be the cache key")
+
+	profile, err = LoadProfile(config, "session")
+	assert.NoError(t, err, "Unexpected error loading profile")
+	assert.Equal(t, &Profile{
+		Name:        "session",
+		StartURL:    "https://session.awsapps.com/start",
+		Region:      "eu-west-1",
+		AccountID:   "222222222222",
+		RoleName:    "Admin",
+		SessionName: "my-sso",
+	}, profile, "Expected profile to match")
+	assert.Equal(t, "my-sso", profile.cacheKey(), "Expected the session name to be the cache key")
+}
+
+func TestLoadProfileWithoutSSO(t *testing.T) {
+	config := loadTestConfig(t)
+
+	profile, err := LoadProfile(config, "default")
+	assert.NoError(t, err, "Unexpected error loading profile")
+	assert.Nil(t, profile, "Expected no SSO settings for the default profile")
+
+	profile, err = LoadProfile(config, "cats")
+	assert.NoError(t, err, "Unexpected error loading profile")
+	assert.Nil(t, profile, "Expected no SSO settings for a profile which does not exist")
+}
+
+func TestLoadProfileInvalid(t *testing.T) {
+	config := loadTestConfig(t)
+
+	_, err := LoadProfile(config, "incomplete")
+	assert.Error(t, err, "Expected error for a profile missing SSO settings")
+
+	_, err = LoadProfile(config, "missing-session")
+	assert.Error(t, err, "Expected error for a profile referencing a missing sso-session")
+}
+
+func newProviderInTest(t *testing.T, endpoint string, expiresAt string) (*Provider, string) {
+	cacheDir, err := ioutil.TempDir("", "sso-cache")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+
+	profile := &Profile{
+		Name:        "session",
+		StartURL:    "https://session.awsapps.com/start",
+		Region:      "eu-west-1",
+		AccountID:   "222222222222",
+		RoleName:    "Admin",
+		SessionName: "my-sso",
+	}
+	hash := sha1.Sum([]byte("my-sso"))
+	token := fmt.Sprintf(`{"accessToken": "token", "expiresAt": "%s"}`, expiresAt)
+	err = ioutil.WriteFile(filepath.Join(cacheDir, hex.EncodeToString(hash[:])+".json"), []byte(token), 0600)
+	assert.NoError(t, err, "Unexpected error writing cached token")
+
+	return &Provider{
+		profile:  profile,
+		cacheDir: cacheDir,
+		client:   http.DefaultClient,
+		endpoint: endpoint,
+	}, cacheDir
+}
+
+func TestProviderRetrieve(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/federation/credentials", r.URL.Path, "Expected path to match")
+		assert.Equal(t, "222222222222", r.URL.Query().Get("account_id"), "Expected account ID to match")
+		assert.Equal(t, "Admin", r.URL.Query().Get("role_name"), "Expected role name to match")
+		assert.Equal(t, "token", r.Header.Get(bearerTokenHeader), "Expected access token to match")
+		fmt.Fprintf(w, `{"roleCredentials": {"accessKeyId": "AKID", "secretAccessKey": "SKID", "sessionToken": "session", "expiration": %d}}`,
+			expiration.UnixNano()/int64(time.Millisecond))
+	}))
+	defer ts.Close()
+
+	provider, cacheDir := newProviderInTest(t, ts.URL, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	defer os.RemoveAll(cacheDir)
+
+	creds, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error retrieving credentials")
+	assert.Equal(t, "AKID", creds.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, "SKID", creds.SecretAccessKey, "Expected secret key to match")
+	assert.Equal(t, "session", creds.SessionToken, "Expected session token to match")
+	assert.True(t, expiration.Equal(provider.ExpiresAt()), "Expected expiration to match")
+	assert.False(t, provider.IsExpired(), "Expected credentials to not be expired")
+}
+
+func TestProviderRetrieveLegacyTokenFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"roleCredentials": {"accessKeyId": "AKID", "secretAccessKey": "SKID", "sessionToken": "session", "expiration": 0}}`)
+	}))
+	defer ts.Close()
+
+	provider, cacheDir := newProviderInTest(t, ts.URL, time.Now().Add(time.Hour).UTC().Format(legacyExpirationFormat))
+	defer os.RemoveAll(cacheDir)
+
+	_, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error retrieving credentials")
+}
+
+func TestProviderRetrieveExpiredToken(t *testing.T) {
+	provider, cacheDir := newProviderInTest(t, "http://localhost:0", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	defer os.RemoveAll(cacheDir)
+
+	_, err := provider.Retrieve()
+	assert.Error(t, err, "Expected error for an expired token")
+	assert.Contains(t, err.Error(), "aws sso login --profile session", "Expected the error to explain how to log in")
+}
+
+func TestProviderRetrieveTokenRejected(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	provider, cacheDir := newProviderInTest(t, ts.URL, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	defer os.RemoveAll(cacheDir)
+
+	_, err := provider.Retrieve()
+	assert.Error(t, err, "Expected error for a rejected token")
+	assert.Contains(t, err.Error(), "aws sso login", "Expected the error to explain how to log in")
+}
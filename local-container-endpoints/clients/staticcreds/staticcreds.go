@@ -0,0 +1,267 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package staticcreds implements the IAM and STS APIs used by Local Endpoints with fixed or generated credentials,
+// so that credentials can be vended without making any requests to AWS.
+package staticcreds
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProviderName is the name of the static credentials
+	ProviderName = "StaticCredentials"
+
+	// UserName is the name of the IAM user which the static credentials appear to belong to
+	UserName = "ecs-local-static"
+
+	// MockUserName is the name of the IAM user which the generated credentials appear to belong to
+	MockUserName = "ecs-local-mock"
+
+	defaultDurationInS = 3600
+)
+
+// credentialsFile is the format of the credentials file, which is the same as the credentials responses
+// of Local Endpoints; SessionToken, as in credential_process output, is also accepted
+type credentialsFile struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	SessionToken    string
+}
+
+// LoadFile reads static credentials from a JSON file
+func LoadFile(path string) (credentials.Value, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return credentials.Value{}, errors.Wrapf(err, "failed to read the static credentials file %s", path)
+	}
+	var file credentialsFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return credentials.Value{}, errors.Wrapf(err, "failed to parse the static credentials file %s", path)
+	}
+	if file.AccessKeyID == "" || file.SecretAccessKey == "" {
+		return credentials.Value{}, fmt.Errorf("the static credentials file %s must contain an AccessKeyId and a SecretAccessKey", path)
+	}
+	token := file.Token
+	if token == "" {
+		token = file.SessionToken
+	}
+	return credentials.Value{
+		AccessKeyID:     file.AccessKeyID,
+		SecretAccessKey: file.SecretAccessKey,
+		SessionToken:    token,
+		ProviderName:    ProviderName,
+	}, nil
+}
+
+// STSClient returns static or generated credentials for every role and session token request.
+// The expiration is set from the requested duration each time, so that callers refresh the credentials as usual.
+// Methods which Local Endpoints does not use are not implemented.
+type STSClient struct {
+	stsiface.STSAPI
+
+	// newValue returns the credentials for the principal, which is the ARN of an assumed role or of the user
+	newValue func(principal string, expiration time.Time) credentials.Value
+	// partition and accountID are those of the user, and of roles which are not given by ARN
+	partition string
+	accountID string
+	userName  string
+	// duration overrides the requested duration if it is set
+	duration time.Duration
+	now      func() time.Time
+}
+
+// NewSTSClient returns an STS client which returns the static credentials
+func NewSTSClient(value credentials.Value, partition, accountID string) *STSClient {
+	return &STSClient{
+		newValue: func(principal string, expiration time.Time) credentials.Value {
+			return value
+		},
+		partition: partition,
+		accountID: accountID,
+		userName:  UserName,
+		now:       time.Now,
+	}
+}
+
+// NewMockSTSClient returns an STS client which generates fake credentials in the same format as real ones.
+// The credentials are derived from the account, the principal, and the expiration, so the same request at the same time
+// always returns the same credentials, and new credentials are returned once they are refreshed.
+// If duration is not zero, the credentials expire after it instead of the requested duration.
+func NewMockSTSClient(partition, accountID string, duration time.Duration) *STSClient {
+	return &STSClient{
+		newValue: func(principal string, expiration time.Time) credentials.Value {
+			return newMockValue(accountID, principal, expiration)
+		},
+		partition: partition,
+		accountID: accountID,
+		userName:  MockUserName,
+		duration:  duration,
+		now:       time.Now,
+	}
+}
+
+// AssumeRole returns the static credentials for the role
+func (client *STSClient) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	roleARN := aws.StringValue(input.RoleArn)
+	resource := strings.Split(roleARN, "/")
+	roleName := resource[len(resource)-1]
+	split := strings.SplitN(roleARN, ":", 6)
+	partition, accountID := client.partition, client.accountID
+	if len(split) == 6 {
+		partition, accountID = split[1], split[4]
+	}
+	sessionName := aws.StringValue(input.RoleSessionName)
+	assumedRoleARN := fmt.Sprintf("arn:%s:sts::%s:assumed-role/%s/%s", partition, accountID, roleName, sessionName)
+
+	return &sts.AssumeRoleOutput{
+		AssumedRoleUser: &sts.AssumedRoleUser{
+			Arn:           aws.String(assumedRoleARN),
+			AssumedRoleId: aws.String(fmt.Sprintf("%s:%s", newUniqueID("AROA", roleARN), sessionName)),
+		},
+		Credentials: client.credentials(assumedRoleARN, input.DurationSeconds),
+	}, nil
+}
+
+// AssumeRoleWithContext is AssumeRole; the request options, such as session tags, are ignored
+func (client *STSClient) AssumeRoleWithContext(ctx aws.Context, input *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error) {
+	return client.AssumeRole(input)
+}
+
+// GetSessionToken returns the static credentials
+func (client *STSClient) GetSessionToken(input *sts.GetSessionTokenInput) (*sts.GetSessionTokenOutput, error) {
+	return &sts.GetSessionTokenOutput{
+		Credentials: client.credentials(client.userARN(), input.DurationSeconds),
+	}, nil
+}
+
+// GetSessionTokenWithContext is GetSessionToken
+func (client *STSClient) GetSessionTokenWithContext(ctx aws.Context, input *sts.GetSessionTokenInput, opts ...request.Option) (*sts.GetSessionTokenOutput, error) {
+	return client.GetSessionToken(input)
+}
+
+// GetCallerIdentity returns the identity of the IAM user which the credentials appear to belong to
+func (client *STSClient) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(client.accountID),
+		Arn:     aws.String(client.userARN()),
+		UserId:  aws.String(newUniqueID("AIDA", client.userARN())),
+	}, nil
+}
+
+// GetCallerIdentityWithContext is GetCallerIdentity
+func (client *STSClient) GetCallerIdentityWithContext(ctx aws.Context, input *sts.GetCallerIdentityInput, opts ...request.Option) (*sts.GetCallerIdentityOutput, error) {
+	return client.GetCallerIdentity(input)
+}
+
+func (client *STSClient) userARN() string {
+	return fmt.Sprintf("arn:%s:iam::%s:user/%s", client.partition, client.accountID, client.userName)
+}
+
+func (client *STSClient) credentials(principal string, durationSeconds *int64) *sts.Credentials {
+	duration := time.Duration(aws.Int64Value(durationSeconds)) * time.Second
+	if duration == 0 {
+		duration = defaultDurationInS * time.Second
+	}
+	if client.duration != 0 {
+		duration = client.duration
+	}
+	// whole seconds, since that is the precision of the credentials responses
+	expiration := client.now().Add(duration).Truncate(time.Second)
+
+	value := client.newValue(principal, expiration)
+	creds := &sts.Credentials{
+		AccessKeyId:     aws.String(value.AccessKeyID),
+		SecretAccessKey: aws.String(value.SecretAccessKey),
+		Expiration:      aws.Time(expiration),
+	}
+	if value.SessionToken != "" {
+		creds.SessionToken = aws.String(value.SessionToken)
+	}
+	return creds
+}
+
+// newMockValue generates temporary credentials with the same format as those from STS:
+// a 20 character access key ID starting with ASIA, a 40 character secret key, and a base64 session token
+func newMockValue(accountID, principal string, expiration time.Time) credentials.Value {
+	seed := fmt.Sprintf("%s|%s|%d", accountID, principal, expiration.Unix())
+	secret := sha256.Sum256([]byte(seed + "|secret"))
+	var token []byte
+	for i := 0; i < 4; i++ {
+		block := sha256.Sum256([]byte(fmt.Sprintf("%s|token|%d", seed, i)))
+		token = append(token, block[:]...)
+	}
+	return credentials.Value{
+		AccessKeyID:     newUniqueID("ASIA", seed),
+		SecretAccessKey: base64.StdEncoding.EncodeToString(secret[:30]),
+		SessionToken:    base64.StdEncoding.EncodeToString(token),
+		ProviderName:    ProviderName,
+	}
+}
+
+// newUniqueID returns a 20 character ID with the prefix, in the format of IAM unique IDs and access key IDs
+func newUniqueID(prefix, seed string) string {
+	hash := sha256.Sum256([]byte(seed))
+	return prefix + base32.StdEncoding.EncodeToString(hash[:10])[:16]
+}
+
+// IAMClient returns a role in the account for every role name.
+// Methods which Local Endpoints does not use are not implemented.
+type IAMClient struct {
+	iamiface.IAMAPI
+
+	partition string
+	accountID string
+}
+
+// NewIAMClient returns an IAM client for roles in the account
+func NewIAMClient(partition, accountID string) *IAMClient {
+	return &IAMClient{
+		partition: partition,
+		accountID: accountID,
+	}
+}
+
+// GetRole returns the role with the name in the account
+func (client *IAMClient) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	roleName := aws.StringValue(input.RoleName)
+	return &iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:      aws.String(fmt.Sprintf("arn:%s:iam::%s:role/%s", client.partition, client.accountID, roleName)),
+			RoleName: aws.String(roleName),
+		},
+	}, nil
+}
+
+// GetRoleWithContext is GetRole
+func (client *IAMClient) GetRoleWithContext(ctx aws.Context, input *iam.GetRoleInput, opts ...request.Option) (*iam.GetRoleOutput, error) {
+	return client.GetRole(input)
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package staticcreds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+const accountID = "111111111111"
+
+var testValue = credentials.Value{
+	AccessKeyID:     "AKID",
+	SecretAccessKey: "SKID",
+	SessionToken:    "token",
+	ProviderName:    ProviderName,
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "staticcreds")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	var testCases = []struct {
+		name     string
+		contents string
+		isError  bool
+	}{
+		{"credentials response", `{"AccessKeyId": "AKID", "SecretAccessKey": "SKID", "Token": "token", "Expiration": "2009-11-10T23:00:00Z"}`, false},
+		{"credential process output", `{"Version": 1, "AccessKeyId": "AKID", "SecretAccessKey": "SKID", "SessionToken": "token"}`, false},
+		{"missing secret key", `{"AccessKeyId": "AKID"}`, true},
+		{"invalid JSON", `AccessKeyId=AKID`, true},
+	}
+
+	for _, testCase := range testCases {
+		path := filepath.Join(dir, "creds.json")
+		err = ioutil.WriteFile(path, []byte(testCase.contents), 0600)
+		assert.NoError(t, err, "Unexpected error writing credentials file")
+
+		value, err := LoadFile(path)
+		if testCase.isError {
+			assert.Error(t, err, "Expected error for %s", testCase.name)
+		} else {
+			assert.NoError(t, err, "Unexpected error for %s", testCase.name)
+			assert.Equal(t, testValue, value, "Expected credentials to match for %s", testCase.name)
+		}
+	}
+
+	_, err = LoadFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err, "Expected error for a missing file")
+}
+
+func TestSTSClient(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := NewSTSClient(testValue, "aws", accountID)
+	client.now = func() time.Time {
+		return now
+	}
+
+	output, err := client.AssumeRole(&sts.AssumeRoleInput{
+		RoleArn:         aws.String("arn:aws:iam::222222222222:role/path/app"),
+		RoleSessionName: aws.String("ecs-local-app"),
+		DurationSeconds: aws.Int64(900),
+	})
+	assert.NoError(t, err, "Unexpected error assuming role")
+	assert.Equal(t, "AKID", aws.StringValue(output.Credentials.AccessKeyId), "Expected access key to match")
+	assert.Equal(t, "SKID", aws.StringValue(output.Credentials.SecretAccessKey), "Expected secret key to match")
+	assert.Equal(t, "token", aws.StringValue(output.Credentials.SessionToken), "Expected session token to match")
+	assert.Equal(t, now.Add(15*time.Minute), aws.TimeValue(output.Credentials.Expiration), "Expected expiration to match the duration")
+	assert.Equal(t, "arn:aws:sts::222222222222:assumed-role/app/ecs-local-app", aws.StringValue(output.AssumedRoleUser.Arn), "Expected assumed role ARN to match")
+
+	// the expiration rolls forward with time
+	now = now.Add(time.Hour)
+	sessionOutput, err := client.GetSessionToken(&sts.GetSessionTokenInput{})
+	assert.NoError(t, err, "Unexpected error getting session token")
+	assert.Equal(t, now.Add(time.Hour), aws.TimeValue(sessionOutput.Credentials.Expiration), "Expected the default duration")
+
+	identity, err := client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	assert.NoError(t, err, "Unexpected error getting caller identity")
+	assert.Equal(t, accountID, aws.StringValue(identity.Account), "Expected account to match")
+	assert.Equal(t, "arn:aws:iam::111111111111:user/"+UserName, aws.StringValue(identity.Arn), "Expected ARN to match")
+}
+
+func TestSTSClientWithoutSessionToken(t *testing.T) {
+	client := NewSTSClient(credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "SKID"}, "aws", accountID)
+
+	output, err := client.GetSessionToken(&sts.GetSessionTokenInput{})
+	assert.NoError(t, err, "Unexpected error getting session token")
+	assert.Nil(t, output.Credentials.SessionToken, "Expected no session token")
+}
+
+func TestIAMClient(t *testing.T) {
+	client := NewIAMClient("aws", accountID)
+
+	output, err := client.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String("app"),
+	})
+	assert.NoError(t, err, "Unexpected error getting role")
+	assert.Equal(t, "arn:aws:iam::111111111111:role/app", aws.StringValue(output.Role.Arn), "Expected role ARN to match")
+}
+
+func TestClientsInPartition(t *testing.T) {
+	iamClient := NewIAMClient("aws-us-gov", accountID)
+	output, err := iamClient.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String("app"),
+	})
+	assert.NoError(t, err, "Unexpected error getting role")
+	assert.Equal(t, "arn:aws-us-gov:iam::111111111111:role/app", aws.StringValue(output.Role.Arn), "Expected role ARN in the partition")
+
+	stsClient := NewMockSTSClient("aws-us-gov", accountID, 0)
+	identity, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	assert.NoError(t, err, "Unexpected error getting caller identity")
+	assert.Equal(t, "arn:aws-us-gov:iam::111111111111:user/"+MockUserName, aws.StringValue(identity.Arn), "Expected user ARN in the partition")
+
+	assumed, err := stsClient.AssumeRole(&sts.AssumeRoleInput{
+		RoleArn:         aws.String("arn:aws-cn:iam::222222222222:role/app"),
+		RoleSessionName: aws.String("session"),
+	})
+	assert.NoError(t, err, "Unexpected error assuming role")
+	assert.Equal(t, "arn:aws-cn:sts::222222222222:assumed-role/app/session", aws.StringValue(assumed.AssumedRoleUser.Arn), "Expected the partition of the role ARN")
+}
+
+func TestMockSTSClient(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := NewMockSTSClient("aws", accountID, 0)
+	client.now = func() time.Time {
+		return now
+	}
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String("arn:aws:iam::111111111111:role/app"),
+		RoleSessionName: aws.String("ecs-local-app"),
+		DurationSeconds: aws.Int64(900),
+	}
+
+	first, err := client.AssumeRole(input)
+	assert.NoError(t, err, "Unexpected error assuming role")
+	creds := first.Credentials
+	assert.Regexp(t, regexp.MustCompile(`^ASIA[A-Z2-7]{16}$`), aws.StringValue(creds.AccessKeyId), "Expected an access key ID in the STS format")
+	assert.Len(t, aws.StringValue(creds.SecretAccessKey), 40, "Expected a 40 character secret key")
+	assert.NotEmpty(t, aws.StringValue(creds.SessionToken), "Expected a session token")
+	assert.Equal(t, now.Add(15*time.Minute), aws.TimeValue(creds.Expiration), "Expected expiration to match the duration")
+	assert.Regexp(t, regexp.MustCompile(`^AROA[A-Z2-7]{16}:ecs-local-app$`), aws.StringValue(first.AssumedRoleUser.AssumedRoleId), "Expected a role ID in the IAM format")
+
+	second, err := client.AssumeRole(input)
+	assert.NoError(t, err, "Unexpected error assuming role")
+	assert.Equal(t, first, second, "Expected the same credentials for the same request at the same time")
+
+	input.RoleArn = aws.String("arn:aws:iam::111111111111:role/worker")
+	other, err := client.AssumeRole(input)
+	assert.NoError(t, err, "Unexpected error assuming role")
+	assert.NotEqual(t, aws.StringValue(creds.AccessKeyId), aws.StringValue(other.Credentials.AccessKeyId), "Expected different credentials for another role")
+
+	now = now.Add(10 * time.Minute)
+	session, err := client.GetSessionToken(&sts.GetSessionTokenInput{})
+	assert.NoError(t, err, "Unexpected error getting session token")
+	assert.NotEqual(t, aws.StringValue(creds.AccessKeyId), aws.StringValue(session.Credentials.AccessKeyId), "Expected new credentials when they are refreshed")
+
+	identity, err := client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	assert.NoError(t, err, "Unexpected error getting caller identity")
+	assert.Equal(t, "arn:aws:iam::111111111111:user/"+MockUserName, aws.StringValue(identity.Arn), "Expected ARN to match")
+	assert.Regexp(t, regexp.MustCompile(`^AIDA[A-Z2-7]{16}$`), aws.StringValue(identity.UserId), "Expected a user ID in the IAM format")
+}
+
+func TestMockSTSClientDuration(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := NewMockSTSClient("aws", accountID, time.Minute)
+	client.now = func() time.Time {
+		return now
+	}
+
+	output, err := client.GetSessionToken(&sts.GetSessionTokenInput{
+		DurationSeconds: aws.Int64(3600),
+	})
+	assert.NoError(t, err, "Unexpected error getting session token")
+	assert.Equal(t, now.Add(time.Minute), aws.TimeValue(output.Credentials.Expiration), "Expected the mock duration to override the requested duration")
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package vault obtains credentials from the AWS secrets engine of HashiCorp Vault
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProviderName is the name of the Vault provider
+	ProviderName = "VaultProvider"
+	// DefaultMount is the path which the AWS secrets engine is enabled at by default
+	DefaultMount = "aws"
+
+	tokenHeader     = "X-Vault-Token"
+	namespaceHeader = "X-Vault-Namespace"
+	// the credentials are refreshed this long before the end of their lease
+	expiryWindow = time.Minute
+)
+
+// Options are the settings of the request to Vault
+type Options struct {
+	// Address is the URL of the Vault server, as in VAULT_ADDR
+	Address string
+	// Token is the Vault token; if it is empty, it is read from TokenFile for each request, as the Vault CLI does
+	Token     string
+	TokenFile string
+	// Namespace is the Vault Enterprise namespace, which is optional
+	Namespace string
+	// Mount is the path of the AWS secrets engine; it defaults to DefaultMount
+	Mount string
+	// Role is the role of the secrets engine
+	Role string
+	// RoleARN selects one of the ARNs of a role with more than one, and TTL sets the lifetime of STS credentials; both are optional
+	RoleARN string
+	TTL     string
+	// HTTPClient is the client which requests are made with
+	HTTPClient *http.Client
+}
+
+// Provider reads credentials for a role of the AWS secrets engine. Roles with the assumed_role or
+// federation_token credential type return temporary credentials, and iam_user roles return the keys of a new IAM user.
+type Provider struct {
+	credentials.Expiry
+
+	options Options
+	// static is set if Vault returned credentials without a lease
+	static bool
+}
+
+type secretResponse struct {
+	LeaseDuration int64 `json:"lease_duration"`
+	Data          struct {
+		AccessKey     string `json:"access_key"`
+		SecretKey     string `json:"secret_key"`
+		SecurityToken string `json:"security_token"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// NewProvider returns a provider which reads credentials from Vault
+func NewProvider(options Options) *Provider {
+	if options.Mount == "" {
+		options.Mount = DefaultMount
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	return &Provider{
+		options: options,
+	}
+}
+
+// Retrieve satisfies the credentials.Provider interface
+func (p *Provider) Retrieve() (credentials.Value, error) {
+	token, err := p.getToken()
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+
+	query := url.Values{}
+	if p.options.RoleARN != "" {
+		query.Set("role_arn", p.options.RoleARN)
+	}
+	if p.options.TTL != "" {
+		query.Set("ttl", p.options.TTL)
+	}
+	requestURL := fmt.Sprintf("%s/v1/%s/creds/%s", strings.TrimSuffix(p.options.Address, "/"), strings.Trim(p.options.Mount, "/"), url.PathEscape(p.options.Role))
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+	req.Header.Set(tokenHeader, token)
+	if p.options.Namespace != "" {
+		req.Header.Set(namespaceHeader, p.options.Namespace)
+	}
+
+	resp, err := p.options.HTTPClient.Do(req)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrapf(err, "failed to read credentials for Vault role %s", p.options.Role)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrapf(err, "failed to read credentials for Vault role %s", p.options.Role)
+	}
+
+	output := &secretResponse{}
+	if err := json.Unmarshal(body, output); err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrapf(err, "failed to parse the response of Vault, with status %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return credentials.Value{ProviderName: ProviderName}, fmt.Errorf("failed to read credentials for Vault role %s: %s %s", p.options.Role, resp.Status, strings.Join(output.Errors, "; "))
+	}
+	if output.Data.AccessKey == "" || output.Data.SecretKey == "" {
+		return credentials.Value{ProviderName: ProviderName}, fmt.Errorf("Vault did not return an access_key and secret_key for role %s", p.options.Role)
+	}
+
+	p.static = output.LeaseDuration <= 0
+	if !p.static {
+		p.SetExpiration(time.Now().Add(time.Duration(output.LeaseDuration)*time.Second), expiryWindow)
+	}
+
+	return credentials.Value{
+		AccessKeyID:     output.Data.AccessKey,
+		SecretAccessKey: output.Data.SecretKey,
+		SessionToken:    output.Data.SecurityToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
+
+// IsExpired satisfies the credentials.Provider interface
+func (p *Provider) IsExpired() bool {
+	if p.static {
+		return false
+	}
+	return p.Expiry.IsExpired()
+}
+
+// getToken returns the Vault token; the token file is read each time, so that a new 'vault login' is picked up
+func (p *Provider) getToken() (string, error) {
+	if p.options.Token != "" {
+		return p.options.Token, nil
+	}
+	if p.options.TokenFile == "" {
+		return "", fmt.Errorf("no Vault token is set")
+	}
+	data, err := ioutil.ReadFile(p.options.TokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the Vault token; set VAULT_TOKEN or run 'vault login'")
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("the Vault token file %s is empty", p.options.TokenFile)
+	}
+	return token, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package vault
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrieve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/aws-dev/creds/developer", r.URL.Path, "Expected path to match")
+		assert.Equal(t, "arn:aws:iam::111111111111:role/developer", r.URL.Query().Get("role_arn"), "Expected role ARN to match")
+		assert.Equal(t, "1h", r.URL.Query().Get("ttl"), "Expected TTL to match")
+		assert.Equal(t, "s.token", r.Header.Get(tokenHeader), "Expected the Vault token")
+		assert.Equal(t, "team", r.Header.Get(namespaceHeader), "Expected the namespace")
+		w.Write([]byte(`{"lease_id":"aws-dev/creds/developer/abc","lease_duration":3600,"data":{"access_key":"ASIA","secret_key":"SECRET","security_token":"TOKEN"}}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(Options{
+		Address:   server.URL,
+		Token:     "s.token",
+		Namespace: "team",
+		Mount:     "aws-dev",
+		Role:      "developer",
+		RoleARN:   "arn:aws:iam::111111111111:role/developer",
+		TTL:       "1h",
+	})
+	value, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "ASIA", value.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, "SECRET", value.SecretAccessKey, "Expected secret key to match")
+	assert.Equal(t, "TOKEN", value.SessionToken, "Expected session token to match")
+	assert.Equal(t, ProviderName, value.ProviderName, "Expected provider name to match")
+	assert.False(t, provider.IsExpired(), "Expected credentials to be valid")
+	assert.WithinDuration(t, time.Now().Add(59*time.Minute), provider.ExpiresAt(), 5*time.Second, "Expected expiration before the end of the lease")
+}
+
+func TestRetrieveTokenFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/aws/creds/developer", r.URL.Path, "Expected the default mount")
+		assert.Equal(t, "s.file-token", r.Header.Get(tokenHeader), "Expected the token from the file")
+		w.Write([]byte(`{"data":{"access_key":"AKIA","secret_key":"SECRET","security_token":null}}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, ".vault-token")
+	err = ioutil.WriteFile(tokenFile, []byte("s.file-token\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing token file")
+
+	provider := NewProvider(Options{
+		Address:   server.URL + "/",
+		TokenFile: tokenFile,
+		Role:      "developer",
+	})
+	value, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "AKIA", value.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, "", value.SessionToken, "Expected no session token")
+	assert.False(t, provider.IsExpired(), "Expected credentials without a lease not to expire")
+
+	os.Remove(tokenFile)
+	_, err = provider.Retrieve()
+	assert.Error(t, err, "Expected error without a token")
+}
+
+func TestRetrieveError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(Options{
+		Address: server.URL,
+		Token:   "s.token",
+		Role:    "developer",
+	})
+	_, err := provider.Retrieve()
+	assert.Error(t, err, "Expected error when Vault denies the request")
+	assert.Contains(t, err.Error(), "permission denied", "Expected the errors of Vault")
+}
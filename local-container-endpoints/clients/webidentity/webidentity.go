@@ -0,0 +1,84 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package webidentity obtains credentials with sts:AssumeRoleWithWebIdentity using an OIDC token file
+package webidentity
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProviderName is the name of the web identity provider
+	ProviderName = "WebIdentityProvider"
+
+	// DefaultRoleSessionName is used when no role session name is configured
+	DefaultRoleSessionName = "ecs-local-endpoints"
+)
+
+// Provider exchanges the token in the token file for credentials of the role
+type Provider struct {
+	credentials.Expiry
+
+	client          stsiface.STSAPI
+	roleARN         string
+	roleSessionName string
+	tokenFile       string
+}
+
+// NewProvider returns a provider which assumes the role with the token in the token file.
+// The client does not need credentials, since AssumeRoleWithWebIdentity requests are not signed.
+func NewProvider(client stsiface.STSAPI, roleARN string, roleSessionName string, tokenFile string) *Provider {
+	if roleSessionName == "" {
+		roleSessionName = DefaultRoleSessionName
+	}
+	return &Provider{
+		client:          client,
+		roleARN:         roleARN,
+		roleSessionName: roleSessionName,
+		tokenFile:       tokenFile,
+	}
+}
+
+// Retrieve satisfies the credentials.Provider interface
+func (p *Provider) Retrieve() (credentials.Value, error) {
+	// the token is read every time, since it is usually rotated by whatever writes the file
+	token, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrapf(err, "failed to read the web identity token file %s", p.tokenFile)
+	}
+
+	output, err := p.client.AssumeRoleWithWebIdentity(&sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.roleARN),
+		RoleSessionName:  aws.String(p.roleSessionName),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	})
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, errors.Wrapf(err, "failed to assume role %s with web identity", p.roleARN)
+	}
+
+	p.SetExpiration(aws.TimeValue(output.Credentials.Expiration), 0)
+	return credentials.Value{
+		AccessKeyID:     aws.StringValue(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(output.Credentials.SessionToken),
+		ProviderName:    ProviderName,
+	}, nil
+}
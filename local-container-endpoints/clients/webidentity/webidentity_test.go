@@ -0,0 +1,89 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package webidentity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const roleARN = "arn:aws:iam::111111111111:role/ci"
+
+func writeTokenFile(t *testing.T) string {
+	file, err := ioutil.TempFile("", "token")
+	assert.NoError(t, err, "Unexpected error creating token file")
+	_, err = file.WriteString("oidc-token\n")
+	assert.NoError(t, err, "Unexpected error writing token file")
+	file.Close()
+	return file.Name()
+}
+
+func TestProviderRetrieve(t *testing.T) {
+	tokenFile := writeTokenFile(t)
+	defer os.Remove(tokenFile)
+
+	ctrl := gomock.NewController(t)
+	stsMock := mock_stsiface.NewMockSTSAPI(ctrl)
+	expiration := time.Now().Add(time.Hour)
+
+	stsMock.EXPECT().AssumeRoleWithWebIdentity(gomock.Any()).Do(func(input *sts.AssumeRoleWithWebIdentityInput) {
+		assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+		assert.Equal(t, DefaultRoleSessionName, aws.StringValue(input.RoleSessionName), "Expected the default role session name")
+		assert.Equal(t, "oidc-token", aws.StringValue(input.WebIdentityToken), "Expected token to match")
+	}).Return(&sts.AssumeRoleWithWebIdentityOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("AKID"),
+			SecretAccessKey: aws.String("SKID"),
+			SessionToken:    aws.String("token"),
+			Expiration:      &expiration,
+		},
+	}, nil)
+
+	provider := NewProvider(stsMock, roleARN, "", tokenFile)
+	creds, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error retrieving credentials")
+	assert.Equal(t, "AKID", creds.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, "SKID", creds.SecretAccessKey, "Expected secret key to match")
+	assert.Equal(t, "token", creds.SessionToken, "Expected session token to match")
+	assert.True(t, expiration.Equal(provider.ExpiresAt()), "Expected expiration to match")
+}
+
+func TestProviderRetrieveMissingTokenFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	stsMock := mock_stsiface.NewMockSTSAPI(ctrl)
+
+	_, err := NewProvider(stsMock, roleARN, "", "/does/not/exist").Retrieve()
+	assert.Error(t, err, "Expected error for a missing token file")
+}
+
+func TestProviderRetrieveSTSError(t *testing.T) {
+	tokenFile := writeTokenFile(t)
+	defer os.Remove(tokenFile)
+
+	ctrl := gomock.NewController(t)
+	stsMock := mock_stsiface.NewMockSTSAPI(ctrl)
+	stsMock.EXPECT().AssumeRoleWithWebIdentity(gomock.Any()).Return(nil, fmt.Errorf("Some API Error"))
+
+	_, err := NewProvider(stsMock, roleARN, "ci-session", tokenFile).Retrieve()
+	assert.Error(t, err, "Expected error when STS fails")
+}
@@ -0,0 +1,373 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package composefile reads the services of a Docker Compose project from its Compose files
+package composefile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/pkg/errors"
+)
+
+// projectNameVar overrides the project name, as it does for Docker Compose
+const projectNameVar = "COMPOSE_PROJECT_NAME"
+
+// defaultFileNames are the names of the Compose files which Docker Compose looks for, in order
+var defaultFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// invalidProjectNameChars are removed from the name of the directory which is used as the project name
+var invalidProjectNameChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// Project is the Compose project defined by one or more Compose files
+type Project struct {
+	Name string
+	// Services are ordered so that each service follows the services it depends on
+	Services []*Service
+}
+
+// Service is a service of a Compose project
+type Service struct {
+	Name          string
+	Image         string
+	ContainerName string
+	NetworkMode   string
+	DependsOn     []string
+	Ports         []Port
+}
+
+// Port is a container port which a service publishes
+type Port struct {
+	Target    uint16
+	Published uint16
+	Protocol  string
+}
+
+// Load reads the project from its Compose files; when a service is in several files, the later files override its
+// image, container name and network mode, and add to its dependencies and ports, as they do for Docker Compose
+func Load(paths []string) (*Project, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No Compose files were given")
+	}
+	project := &Project{}
+	services := make(map[string]*Service)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read Compose file %s", path)
+		}
+		if err := project.parse(data, services); err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse Compose file %s", path)
+		}
+	}
+
+	if name := os.Getenv(projectNameVar); name != "" {
+		project.Name = name
+	} else if project.Name == "" {
+		absPath, err := filepath.Abs(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		project.Name = normalizeProjectName(filepath.Base(filepath.Dir(absPath)))
+	}
+
+	var err error
+	project.Services, err = orderServices(services)
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// FindFile returns the path of the Compose file in the directory, which Docker Compose uses when no file is given
+func FindFile(dir string) (string, error) {
+	for _, name := range defaultFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("No Compose file was found in %s; expected one of %s", dir, strings.Join(defaultFileNames, ", "))
+}
+
+// Service returns the service with the given name, or nil if it is not in the project
+func (project *Project) Service(name string) *Service {
+	for _, service := range project.Services {
+		if service.Name == name {
+			return service
+		}
+	}
+	return nil
+}
+
+func (project *Project) parse(data []byte, services map[string]*Service) error {
+	document, err := configfile.ParseDocument(data)
+	if err != nil {
+		return err
+	}
+	if name, ok := document["name"]; ok {
+		project.Name, _ = stringValue(name)
+		project.Name = interpolate(project.Name)
+	}
+	serviceValues, ok := document["services"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected 'services' to be a map")
+	}
+	for name, value := range serviceValues {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected service '%s' to be a map", name)
+		}
+		service, ok := services[name]
+		if !ok {
+			service = &Service{Name: name}
+			services[name] = service
+		}
+		if err := service.parse(values); err != nil {
+			return errors.Wrapf(err, "invalid service '%s'", name)
+		}
+	}
+	return nil
+}
+
+func (service *Service) parse(values map[string]interface{}) error {
+	if image, ok := stringValue(values["image"]); ok {
+		service.Image = interpolate(image)
+	}
+	if containerName, ok := stringValue(values["container_name"]); ok {
+		service.ContainerName = interpolate(containerName)
+	}
+	if networkMode, ok := stringValue(values["network_mode"]); ok {
+		service.NetworkMode = interpolate(networkMode)
+	}
+
+	switch dependsOn := values["depends_on"].(type) {
+	case nil:
+	case []interface{}:
+		for _, value := range dependsOn {
+			dependency, ok := stringValue(value)
+			if !ok {
+				return fmt.Errorf("expected 'depends_on' to be a list of services")
+			}
+			service.addDependency(dependency)
+		}
+	case map[string]interface{}:
+		for dependency := range dependsOn {
+			service.addDependency(dependency)
+		}
+	default:
+		return fmt.Errorf("expected 'depends_on' to be a list or a map")
+	}
+
+	if values["ports"] == nil {
+		return nil
+	}
+	ports, ok := values["ports"].([]interface{})
+	if !ok {
+		return fmt.Errorf("expected 'ports' to be a list")
+	}
+	for _, value := range ports {
+		servicePorts, err := parsePort(value)
+		if err != nil {
+			return err
+		}
+		for _, port := range servicePorts {
+			service.addPort(port)
+		}
+	}
+	return nil
+}
+
+func (service *Service) addDependency(dependency string) {
+	for _, existing := range service.DependsOn {
+		if existing == dependency {
+			return
+		}
+	}
+	service.DependsOn = append(service.DependsOn, dependency)
+}
+
+func (service *Service) addPort(port Port) {
+	for _, existing := range service.Ports {
+		if existing == port {
+			return
+		}
+	}
+	service.Ports = append(service.Ports, port)
+}
+
+// parsePort reads a port in the short syntax, such as 127.0.0.1:8080:80/udp, or in the long syntax, as a map
+// with the target, published, and protocol; a range of ports is read as each of its ports
+func parsePort(value interface{}) ([]Port, error) {
+	if values, ok := value.(map[string]interface{}); ok {
+		target, _ := stringValue(values["target"])
+		published, _ := stringValue(values["published"])
+		protocol, _ := stringValue(values["protocol"])
+		return newPorts(interpolate(target), interpolate(published), protocol)
+	}
+	port, ok := stringValue(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid port %v", value)
+	}
+	port = interpolate(port)
+	protocol := ""
+	if index := strings.LastIndex(port, "/"); index != -1 {
+		port, protocol = port[:index], port[index+1:]
+	}
+	// the host IP is not used, and can be an IPv6 address in brackets, so the ports are split from the end
+	parts := strings.Split(port, ":")
+	target := parts[len(parts)-1]
+	published := ""
+	if len(parts) > 1 {
+		published = parts[len(parts)-2]
+	}
+	return newPorts(target, published, protocol)
+}
+
+func newPorts(target, published, protocol string) ([]Port, error) {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	targetStart, targetEnd, err := parsePortRange(target)
+	if err != nil {
+		return nil, err
+	}
+	var publishedStart, publishedEnd uint16
+	if published != "" {
+		publishedStart, publishedEnd, err = parsePortRange(published)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// a single published port can be used for a range of target ports, in which case the published port is not known
+	publishedRange := publishedEnd-publishedStart == targetEnd-targetStart
+
+	var ports []Port
+	for offset := uint16(0); targetStart+offset <= targetEnd && targetStart+offset >= targetStart; offset++ {
+		port := Port{
+			Target:   targetStart + offset,
+			Protocol: protocol,
+		}
+		if publishedRange && publishedStart != 0 {
+			port.Published = publishedStart + offset
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func parsePortRange(value string) (uint16, uint16, error) {
+	parts := strings.SplitN(value, "-", 2)
+	start, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %s", value)
+	}
+	end := start
+	if len(parts) == 2 {
+		end, err = strconv.ParseUint(parts[1], 10, 16)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid port range %s", value)
+		}
+	}
+	return uint16(start), uint16(end), nil
+}
+
+// orderServices sorts the services by name, and then moves each service after the services it depends on
+func orderServices(services map[string]*Service) ([]*Service, error) {
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ordered []*Service
+	visited := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("Services have a dependency cycle which includes %s", name)
+		}
+		service, ok := services[name]
+		if !ok {
+			return fmt.Errorf("Services depend on %s, which is not a service", name)
+		}
+		visiting[name] = true
+		dependencies := append([]string{}, service.DependsOn...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		ordered = append(ordered, service)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// normalizeProjectName returns the project name Docker Compose uses for a directory
+func normalizeProjectName(name string) string {
+	return invalidProjectNameChars.ReplaceAllString(strings.ToLower(name), "")
+}
+
+// interpolate replaces variables such as ${TAG} and ${TAG:-latest} with the values in the environment; $$ is a literal $
+func interpolate(value string) string {
+	return os.Expand(value, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		for _, separator := range []string{":-", "-"} {
+			if index := strings.Index(name, separator); index != -1 {
+				variable, defaultValue := name[:index], name[index+len(separator):]
+				value, ok := os.LookupEnv(variable)
+				if !ok || (value == "" && separator == ":-") {
+					return defaultValue
+				}
+				return value
+			}
+		}
+		return os.Getenv(name)
+	})
+}
+
+// stringValue returns the string form of a scalar from either YAML or JSON
+func stringValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package composefile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testComposeFile = `# the application and its dependencies
+services:
+  web:
+    image: "my-web:${TAG:-latest}"
+    depends_on:
+      - api
+    ports:
+      - "8080:80"
+      - 127.0.0.1:8443:443/tcp
+  api:
+    image: my-api
+    container_name: api
+    depends_on:
+      db:
+        condition: service_healthy
+      cache:
+        condition: service_started
+    ports:
+      - target: 3000
+        published: 3000
+        protocol: tcp
+      - "9000-9001"
+  db:
+    image: postgres
+    healthcheck:
+      test: ["CMD", "pg_isready"]
+  cache:
+    image: redis
+`
+
+const testOverrideFile = `services:
+  web:
+    image: my-web:dev
+    ports:
+      - "5353:53/udp"
+`
+
+func writeComposeFile(t *testing.T, dir, name, contents string) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte(contents), 0644)
+	assert.NoError(t, err, "Unexpected error writing file")
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	projectDir := filepath.Join(dir, "My_App.Dev")
+	err = os.Mkdir(projectDir, 0755)
+	assert.NoError(t, err, "Unexpected error creating project dir")
+	path := writeComposeFile(t, projectDir, "docker-compose.yml", testComposeFile)
+
+	os.Unsetenv(projectNameVar)
+	os.Setenv("TAG", "1.2")
+	defer os.Unsetenv("TAG")
+
+	project, err := Load([]string{path})
+	assert.NoError(t, err, "Unexpected error loading project")
+	assert.Equal(t, "my_appdev", project.Name, "Expected the project name to be the normalized directory name")
+
+	var names []string
+	for _, service := range project.Services {
+		names = append(names, service.Name)
+	}
+	assert.Equal(t, []string{"cache", "db", "api", "web"}, names, "Expected each service after its dependencies")
+
+	assert.Equal(t, &Service{
+		Name:      "web",
+		Image:     "my-web:1.2",
+		DependsOn: []string{"api"},
+		Ports: []Port{
+			{Target: 80, Published: 8080, Protocol: "tcp"},
+			{Target: 443, Published: 8443, Protocol: "tcp"},
+		},
+	}, project.Service("web"), "Expected web service to match")
+
+	api := project.Service("api")
+	assert.Equal(t, "api", api.ContainerName, "Expected container name to match")
+	assert.ElementsMatch(t, []string{"db", "cache"}, api.DependsOn, "Expected dependencies from the depends_on map")
+	assert.Equal(t, []Port{
+		{Target: 3000, Published: 3000, Protocol: "tcp"},
+		{Target: 9000, Protocol: "tcp"},
+		{Target: 9001, Protocol: "tcp"},
+	}, api.Ports, "Expected the long syntax port and each port of the range")
+
+	assert.Nil(t, project.Service("missing"), "Expected no service")
+}
+
+func TestLoadOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := writeComposeFile(t, dir, "docker-compose.yml", "name: shop\n"+testComposeFile)
+	overridePath := writeComposeFile(t, dir, "docker-compose.override.yml", testOverrideFile)
+
+	os.Unsetenv(projectNameVar)
+	project, err := Load([]string{path, overridePath})
+	assert.NoError(t, err, "Unexpected error loading project")
+	assert.Equal(t, "shop", project.Name, "Expected the name in the Compose file")
+
+	web := project.Service("web")
+	assert.Equal(t, "my-web:dev", web.Image, "Expected the image of the override file")
+	assert.Equal(t, []Port{
+		{Target: 80, Published: 8080, Protocol: "tcp"},
+		{Target: 443, Published: 8443, Protocol: "tcp"},
+		{Target: 53, Published: 5353, Protocol: "udp"},
+	}, web.Ports, "Expected the ports of both files")
+
+	os.Setenv(projectNameVar, "override")
+	defer os.Unsetenv(projectNameVar)
+	project, err = Load([]string{path})
+	assert.NoError(t, err, "Unexpected error loading project")
+	assert.Equal(t, "override", project.Name, "Expected COMPOSE_PROJECT_NAME to take precedence")
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	var testCases = []struct {
+		name    string
+		compose string
+	}{
+		{"no services", "version: '3'\n"},
+		{"dependency cycle", "services:\n  a:\n    depends_on: [b]\n  b:\n    depends_on: [a]\n"},
+		{"unknown dependency", "services:\n  a:\n    depends_on: [b]\n"},
+		{"invalid port", "services:\n  a:\n    ports:\n      - http\n"},
+		{"invalid port range", "services:\n  a:\n    ports:\n      - 90-80\n"},
+	}
+	for _, testCase := range testCases {
+		path := writeComposeFile(t, dir, "docker-compose.yml", testCase.compose)
+		_, err := Load([]string{path})
+		assert.Error(t, err, "Expected error for %s", testCase.name)
+	}
+
+	_, err = Load([]string{filepath.Join(dir, "missing.yml")})
+	assert.Error(t, err, "Expected error for a file which does not exist")
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("SET", "value")
+	os.Setenv("EMPTY", "")
+	defer os.Unsetenv("SET")
+	defer os.Unsetenv("EMPTY")
+
+	assert.Equal(t, "value", interpolate("${SET}"), "Expected the value of the variable")
+	assert.Equal(t, "value", interpolate("$SET"), "Expected the value of the variable")
+	assert.Equal(t, "default", interpolate("${EMPTY:-default}"), "Expected the default for an empty variable")
+	assert.Equal(t, "", interpolate("${EMPTY-default}"), "Expected the empty value of a variable which is set")
+	assert.Equal(t, "default", interpolate("${UNSET-default}"), "Expected the default for an unset variable")
+	assert.Equal(t, "$SET", interpolate("$$SET"), "Expected $$ to be a literal $")
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package composefile
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+)
+
+const (
+	// EndpointsServiceName is the name of the Local Endpoints service in a generated override file
+	EndpointsServiceName = "ecs-local-endpoints"
+	// XRayDaemonServiceName is the name of the X-Ray daemon service, which is added to the override file if it is enabled
+	XRayDaemonServiceName = "xray-daemon"
+
+	endpointsImage   = "amazon/amazon-ecs-local-container-endpoints"
+	endpointsNetwork = config.DefaultNetworkName
+	endpointsIP      = config.EndpointsIP
+	networkSubnet    = config.NetworkSubnet
+	networkGateway   = config.NetworkGateway
+
+	xrayDaemonImage = "amazon/aws-xray-daemon"
+	// the daemon listens on 127.0.0.1 by default, which other containers can't reach
+	xrayDaemonBindAddress = "0.0.0.0:2000"
+)
+
+// OverrideOptions are the settings of the override file for a project
+type OverrideOptions struct {
+	// Profile is the AWS profile which Local Endpoints sources credentials from
+	Profile string
+	// Region is set as the AWS_DEFAULT_REGION of each service
+	Region string
+	// Role is the name or ARN of the role which each service gets credentials for; if it is empty, services get
+	// the temporary credentials of the profile
+	Role string
+	// XRayDaemon adds an X-Ray daemon service, which gets credentials from Local Endpoints
+	XRayDaemon bool
+	// XRayDaemonAddress is set as the AWS_XRAY_DAEMON_ADDRESS of each service; it defaults to the X-Ray daemon service
+	XRayDaemonAddress string
+}
+
+// Override returns a Compose override file, such as docker-compose.override.yml, which adds the Local Endpoints
+// service and the network it is reachable in to the project, and sets up each service to use it
+func (project *Project) Override(options OverrideOptions) ([]byte, error) {
+	reserved := []string{EndpointsServiceName}
+	if options.XRayDaemon {
+		reserved = append(reserved, XRayDaemonServiceName)
+	}
+	for _, name := range reserved {
+		if project.Service(name) != nil {
+			return nil, fmt.Errorf("The project already has a service named %s", name)
+		}
+	}
+	credentialsPath := config.TempCredentialsPath
+	if options.Role != "" {
+		credentialsPath = "/role/" + options.Role
+	}
+	xrayDaemonAddress := options.XRayDaemonAddress
+	if xrayDaemonAddress == "" && options.XRayDaemon {
+		xrayDaemonAddress = XRayDaemonServiceName + ":2000"
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, "# Generated by local-container-endpoints init")
+	fmt.Fprintln(out, "networks:")
+	fmt.Fprintln(out, "  # Local Endpoints is reachable at the IP address which ECS uses in this network")
+	fmt.Fprintf(out, "  %s:\n", endpointsNetwork)
+	fmt.Fprintln(out, "    driver: bridge")
+	fmt.Fprintln(out, "    ipam:")
+	fmt.Fprintln(out, "      config:")
+	fmt.Fprintf(out, "        - subnet: %s\n", quote(networkSubnet))
+	fmt.Fprintf(out, "          gateway: %s\n", quote(networkGateway))
+	fmt.Fprintln(out, "services:")
+	fmt.Fprintf(out, "  %s:\n", EndpointsServiceName)
+	fmt.Fprintf(out, "    image: %s\n", endpointsImage)
+	fmt.Fprintln(out, "    volumes:")
+	fmt.Fprintln(out, "      - /var/run:/var/run")
+	fmt.Fprintln(out, "      - $HOME/.aws/:/home/.aws/")
+	fmt.Fprintln(out, "    environment:")
+	fmt.Fprintf(out, "      AWS_PROFILE: %s\n", quote(options.Profile))
+	fmt.Fprintln(out, "    networks:")
+	fmt.Fprintf(out, "      %s:\n", endpointsNetwork)
+	fmt.Fprintf(out, "        ipv4_address: %s\n", quote(endpointsIP))
+
+	ip := net.ParseIP(endpointsIP).To4()
+	nextIP := func() (string, error) {
+		ip[3]++
+		if ip[3] == 0 || ip[3] == 255 {
+			return "", fmt.Errorf("The project has too many services for the addresses in %s", networkSubnet)
+		}
+		return ip.String(), nil
+	}
+
+	if options.XRayDaemon {
+		serviceIP, err := nextIP()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "  # The X-Ray daemon sends the segments of the services to X-Ray, with credentials from Local Endpoints")
+		fmt.Fprintf(out, "  %s:\n", XRayDaemonServiceName)
+		fmt.Fprintf(out, "    image: %s\n", xrayDaemonImage)
+		fmt.Fprintf(out, "    command: [\"--local-mode\", \"--bind\", %s, \"--bind-tcp\", %s]\n", quote(xrayDaemonBindAddress), quote(xrayDaemonBindAddress))
+		fmt.Fprintln(out, "    depends_on:")
+		fmt.Fprintf(out, "      - %s\n", EndpointsServiceName)
+		fmt.Fprintln(out, "    networks:")
+		fmt.Fprintf(out, "      %s:\n", endpointsNetwork)
+		fmt.Fprintf(out, "        ipv4_address: %s\n", quote(serviceIP))
+		fmt.Fprintln(out, "    environment:")
+		fmt.Fprintf(out, "      AWS_REGION: %s\n", quote(options.Region))
+		fmt.Fprintf(out, "      AWS_CONTAINER_CREDENTIALS_RELATIVE_URI: %s\n", quote(credentialsPath))
+	}
+
+	for _, service := range project.Services {
+		fmt.Fprintln(out)
+		if service.NetworkMode != "" {
+			fmt.Fprintf(out, "  # %s is not set up, since its network_mode of %s can not join %s\n", service.Name, service.NetworkMode, endpointsNetwork)
+			continue
+		}
+		serviceIP, err := nextIP()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "  %s:\n", service.Name)
+		fmt.Fprintln(out, "    depends_on:")
+		fmt.Fprintf(out, "      - %s\n", EndpointsServiceName)
+		if options.XRayDaemon {
+			fmt.Fprintf(out, "      - %s\n", XRayDaemonServiceName)
+		}
+		fmt.Fprintln(out, "    networks:")
+		fmt.Fprintf(out, "      %s:\n", endpointsNetwork)
+		fmt.Fprintf(out, "        ipv4_address: %s\n", quote(serviceIP))
+		fmt.Fprintln(out, "    environment:")
+		fmt.Fprintf(out, "      AWS_DEFAULT_REGION: %s\n", quote(options.Region))
+		fmt.Fprintf(out, "      AWS_CONTAINER_CREDENTIALS_RELATIVE_URI: %s\n", quote(credentialsPath))
+		// these also make the X-Ray SDKs record the origin of segments as AWS::ECS::Container, with the metadata of the container
+		fmt.Fprintf(out, "      ECS_CONTAINER_METADATA_URI: %s\n", quote("http://"+endpointsIP+"/v3"))
+		fmt.Fprintf(out, "      ECS_CONTAINER_METADATA_URI_V4: %s\n", quote("http://"+endpointsIP+"/v4"))
+		if xrayDaemonAddress != "" {
+			fmt.Fprintf(out, "      AWS_XRAY_DAEMON_ADDRESS: %s\n", quote(xrayDaemonAddress))
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// quote returns a double quoted YAML string, in which $ is escaped so that Compose does not interpolate it
+func quote(value string) string {
+	return strconv.Quote(strings.Replace(value, "$", "$$", -1))
+}
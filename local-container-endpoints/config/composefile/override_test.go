@@ -0,0 +1,131 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package composefile
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := writeComposeFile(t, dir, "docker-compose.yml", "name: shop\n"+testComposeFile+`  tool:
+    image: busybox
+    network_mode: host
+`)
+
+	project, err := Load([]string{path})
+	assert.NoError(t, err, "Unexpected error loading project")
+	override, err := project.Override(OverrideOptions{
+		Profile: "dev",
+		Region:  "us-west-2",
+		Role:    "my-role",
+	})
+	assert.NoError(t, err, "Unexpected error generating override file")
+	assert.Contains(t, string(override), `ipv4_address: "169.254.170.2"`, "Expected the address of Local Endpoints")
+	assert.Contains(t, string(override), `AWS_PROFILE: "dev"`, "Expected the AWS profile")
+	assert.Equal(t, 4, strings.Count(string(override), `AWS_CONTAINER_CREDENTIALS_RELATIVE_URI: "/role/my-role"`), "Expected each service to get credentials for the role")
+	assert.Contains(t, string(override), `ipv4_address: "169.254.170.6"`, "Expected an address for each service")
+	assert.NotContains(t, string(override), "  tool:", "Expected the service with a network mode to be skipped")
+
+	// the override file adds the endpoints to the project
+	overridePath := writeComposeFile(t, dir, "docker-compose.override.yml", string(override))
+	project, err = Load([]string{path, overridePath})
+	assert.NoError(t, err, "Unexpected error loading project with the override file")
+	assert.Equal(t, EndpointsServiceName, project.Services[0].Name, "Expected the services to depend on Local Endpoints")
+	assert.Contains(t, project.Service("web").DependsOn, EndpointsServiceName, "Expected web to depend on Local Endpoints")
+
+	_, err = project.Override(OverrideOptions{})
+	assert.Error(t, err, "Expected error for a project which already has Local Endpoints")
+}
+
+func TestOverrideDefaultCredentials(t *testing.T) {
+	project := &Project{
+		Name: "shop",
+		Services: []*Service{
+			{Name: "web"},
+		},
+	}
+	override, err := project.Override(OverrideOptions{
+		Profile: "default",
+		Region:  "us-east-1",
+	})
+	assert.NoError(t, err, "Unexpected error generating override file")
+	assert.Contains(t, string(override), `AWS_CONTAINER_CREDENTIALS_RELATIVE_URI: "/creds"`, "Expected the temporary credentials path")
+}
+
+func TestFindFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	_, err = FindFile(dir)
+	assert.Error(t, err, "Expected error for a directory without a Compose file")
+
+	writeComposeFile(t, dir, "docker-compose.yml", testComposeFile)
+	path := writeComposeFile(t, dir, "compose.yaml", testComposeFile)
+	found, err := FindFile(dir)
+	assert.NoError(t, err, "Unexpected error finding the Compose file")
+	assert.Equal(t, path, found, "Expected the file which Compose prefers")
+}
+
+func TestOverrideXRayDaemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := writeComposeFile(t, dir, "docker-compose.yml", "name: shop\n"+testComposeFile)
+
+	project, err := Load([]string{path})
+	assert.NoError(t, err, "Unexpected error loading project")
+	override, err := project.Override(OverrideOptions{
+		Profile:    "default",
+		Region:     "us-west-2",
+		XRayDaemon: true,
+	})
+	assert.NoError(t, err, "Unexpected error generating override file")
+	assert.Contains(t, string(override), "image: amazon/aws-xray-daemon", "Expected the X-Ray daemon service")
+	assert.Equal(t, 4, strings.Count(string(override), `AWS_XRAY_DAEMON_ADDRESS: "xray-daemon:2000"`), "Expected each service to send segments to the daemon")
+
+	overridePath := writeComposeFile(t, dir, "docker-compose.override.yml", string(override))
+	project, err = Load([]string{path, overridePath})
+	assert.NoError(t, err, "Unexpected error loading project with the override file")
+	assert.Contains(t, project.Service("web").DependsOn, XRayDaemonServiceName, "Expected web to depend on the X-Ray daemon")
+	assert.Equal(t, []string{EndpointsServiceName}, project.Service(XRayDaemonServiceName).DependsOn, "Expected the X-Ray daemon to depend on Local Endpoints")
+
+	_, err = project.Override(OverrideOptions{XRayDaemon: true})
+	assert.Error(t, err, "Expected error for a project which already has an X-Ray daemon")
+}
+
+func TestOverrideXRayDaemonAddress(t *testing.T) {
+	project := &Project{
+		Name: "shop",
+		Services: []*Service{
+			{Name: "web"},
+		},
+	}
+	override, err := project.Override(OverrideOptions{
+		Profile:           "default",
+		Region:            "us-east-1",
+		XRayDaemonAddress: "host.docker.internal:2000",
+	})
+	assert.NoError(t, err, "Unexpected error generating override file")
+	assert.Contains(t, string(override), `AWS_XRAY_DAEMON_ADDRESS: "host.docker.internal:2000"`, "Expected the address of the daemon")
+	assert.NotContains(t, string(override), XRayDaemonServiceName+":", "Expected no X-Ray daemon service")
+}
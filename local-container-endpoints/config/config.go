@@ -19,6 +19,48 @@ const (
 	// PortEnvVar defines the port that metadata and credentials listen at
 	PortVar = "ECS_LOCAL_METADATA_PORT"
 
+	// BindAddressVar is a comma separated list of IP addresses or host names of the interfaces to listen at;
+	// by default, all interfaces are used
+	BindAddressVar = "ECS_LOCAL_BIND_ADDRESS"
+	// CredentialsPortVar serves the credentials API at its own port, instead of the port of the metadata API;
+	// CredentialsBindAddressVar overrides the bind address for it
+	CredentialsPortVar        = "ECS_LOCAL_CREDENTIALS_PORT"
+	CredentialsBindAddressVar = "ECS_LOCAL_CREDENTIALS_BIND_ADDRESS"
+	// DisableCredentialsVar and DisableMetadataVar turn off the credentials API or the metadata API, if set to true
+	DisableCredentialsVar = "ECS_LOCAL_DISABLE_CREDENTIALS"
+	DisableMetadataVar    = "ECS_LOCAL_DISABLE_METADATA"
+	// DisableDockerEventsVar makes every request list the containers with the Docker API, instead of using the model kept up to date by Docker events, if set to true
+	DisableDockerEventsVar = "ECS_LOCAL_DISABLE_DOCKER_EVENTS"
+	// IMDSIPv6Var adds the IPv6 address of the EC2 instance metadata service to the bind addresses, if set to true
+	IMDSIPv6Var = "ECS_LOCAL_IMDS_IPV6"
+
+	// SocketPathVar is the path of a unix socket to listen at; the port is then only used if it is set
+	SocketPathVar = "ECS_LOCAL_SOCKET_PATH"
+
+	// The sockets passed by systemd socket activation, which are used instead of the ports and the unix socket
+	ListenPIDVar     = "LISTEN_PID"
+	ListenFDsVar     = "LISTEN_FDS"
+	ListenFDNamesVar = "LISTEN_FDNAMES"
+
+	// TLSCertFileVar and TLSKeyFileVar are the paths of a certificate and its private key; if they are set, the port serves HTTPS
+	TLSCertFileVar = "ECS_LOCAL_TLS_CERT_FILE"
+	TLSKeyFileVar  = "ECS_LOCAL_TLS_KEY_FILE"
+
+	// ReadTimeoutVar, WriteTimeoutVar and IdleTimeoutVar are the timeouts of the HTTP server, such as 30s; 0 disables them
+	ReadTimeoutVar  = "ECS_LOCAL_READ_TIMEOUT"
+	WriteTimeoutVar = "ECS_LOCAL_WRITE_TIMEOUT"
+	IdleTimeoutVar  = "ECS_LOCAL_IDLE_TIMEOUT"
+	// AWSTimeoutVar is the timeout of each HTTP request to AWS, such as 10s; 0 disables it
+	AWSTimeoutVar = "ECS_LOCAL_AWS_TIMEOUT"
+
+	// DebugPortVar is the port of the pprof debug endpoints, which are only served if it is set
+	DebugPortVar = "ECS_LOCAL_DEBUG_PORT"
+	// IntrospectionPortVar is the port of the ECS Agent introspection API, such as 51678, which is only served if it is set
+	IntrospectionPortVar = "ECS_LOCAL_INTROSPECTION_PORT"
+
+	// ConfigFileVar is the path to the optional config file, which can be used instead of the other environment variables
+	ConfigFileVar = "ECS_LOCAL_CONFIG_FILE"
+
 	// Metadata related
 	ClusterARNVar            = "CLUSTER_ARN"
 	TaskARNVar               = "TASK_ARN"
@@ -26,12 +68,118 @@ const (
 	TDRevisionVar            = "TASK_DEFINITION_REVISION"
 	ContainerInstanceTagsVar = "CONTAINER_INSTANCE_TAGS"
 	TaskTagsVar              = "TASK_TAGS_VAR"
+	// ServiceNameMetadataVar is the name of the ECS service in V4 Task Metadata, which is omitted if it is not set
+	ServiceNameMetadataVar = "SERVICE_NAME"
+	// TaskDefinitionVar is the path of a task definition JSON file, or a task definition in ECS, which shapes the metadata
+	TaskDefinitionVar = "ECS_LOCAL_TASK_DEFINITION"
+	// ComposeFilesVar is a comma separated list of the Compose files of the project, whose services are included in task metadata
+	ComposeFilesVar = "ECS_LOCAL_COMPOSE_FILES"
+	// UnknownCallerAllContainersVar makes all running containers the local 'task' of callers which are not a container,
+	// such as the host, as in earlier versions; by default, their task metadata requests are rejected
+	UnknownCallerAllContainersVar = "ECS_LOCAL_UNKNOWN_CALLER_ALL_CONTAINERS"
+	// PodNameVar is the name of the pod which Local Endpoints runs in as a sidecar, from the downward API; the caller
+	// containers and task metadata are then taken from the pod instead of the Docker API, if it is set
+	PodNameVar = "ECS_LOCAL_POD_NAME"
+	// PodNamespaceVar is the namespace of the pod; the default is the namespace of its service account
+	PodNamespaceVar = "ECS_LOCAL_POD_NAMESPACE"
+	// PodIPVar is the IP address of the pod, from the downward API; the default is the address in the pod status
+	PodIPVar = "ECS_LOCAL_POD_IP"
+	// PodInfoDirVar is the directory of a downward API volume with the labels and annotations of the pod
+	PodInfoDirVar = "ECS_LOCAL_POD_INFO_DIR"
+	// PodContainerNameVar is the name of the Local Endpoints container in the pod, which is left out of task metadata
+	PodContainerNameVar = "ECS_LOCAL_POD_CONTAINER_NAME"
+	// ContainerdNamespaceVar is the containerd namespace of the containers, such as default; the caller containers and
+	// task metadata are then taken from containerd with nerdctl instead of the Docker API, if it is set
+	ContainerdNamespaceVar = "ECS_LOCAL_CONTAINERD_NAMESPACE"
+	// NerdctlPathVar is the path of the nerdctl binary which is used with containerd; the default is nerdctl on the PATH
+	NerdctlPathVar = "ECS_LOCAL_NERDCTL_PATH"
+	// CreateNetworkVar creates the credentials network if it doesn't exist, and connects Local Endpoints to it at EndpointsIP on startup, if set to true
+	CreateNetworkVar = "ECS_LOCAL_CREATE_NETWORK"
+	// NetworkNameVar is the name of the credentials network, if it is created
+	NetworkNameVar = "ECS_LOCAL_NETWORK_NAME"
+	// DockerCacheTTLVar is how long the results of docker stats and inspect are cached for metadata requests, such as 1s; 0 disables the cache
+	DockerCacheTTLVar = "ECS_LOCAL_DOCKER_CACHE_TTL"
+	// EphemeralStorageSizeVar is the size in GiB of the task's ephemeral storage, which is reported as reserved in V4 Task Metadata
+	EphemeralStorageSizeVar = "EPHEMERAL_STORAGE_SIZE"
+
+	// Credentials related
+	MFASerialVar           = "ECS_LOCAL_MFA_SERIAL"
+	CredentialsDurationVar = "ECS_LOCAL_CREDENTIALS_DURATION"
+	SessionTagsVar         = "ECS_LOCAL_SESSION_TAGS"
+	SessionPolicyVar       = "ECS_LOCAL_SESSION_POLICY"
+	// AllowedRolesVar and DeniedRolesVar are comma separated lists of patterns for the roles which can be assumed
+	AllowedRolesVar = "ECS_LOCAL_ALLOWED_ROLES"
+	DeniedRolesVar  = "ECS_LOCAL_DENIED_ROLES"
+	// RequireTokenVar requires credentials requests to present a session token, as in IMDSv2
+	RequireTokenVar = "ECS_LOCAL_REQUIRE_TOKEN"
+	// AuthorizationTokenVar is a shared secret which callers must present in the Authorization header, as with AWS_CONTAINER_AUTHORIZATION_TOKEN;
+	// AuthorizationTokenFileVar is the path of a file which contains it
+	AuthorizationTokenVar     = "ECS_LOCAL_AUTHORIZATION_TOKEN"
+	AuthorizationTokenFileVar = "ECS_LOCAL_AUTHORIZATION_TOKEN_FILE"
+	// IMDSTokensVar is 'required' to require session tokens on the instance metadata paths only, like HttpTokens on EC2
+	IMDSTokensVar = "ECS_LOCAL_IMDS_TOKENS"
+	// Static credentials are vended without making requests to AWS; they are read from the file, or else from the other variables
+	StaticCredentialsFileVar = "ECS_LOCAL_STATIC_CREDENTIALS_FILE"
+	StaticAccessKeyIDVar     = "ECS_LOCAL_STATIC_ACCESS_KEY_ID"
+	StaticSecretAccessKeyVar = "ECS_LOCAL_STATIC_SECRET_ACCESS_KEY"
+	StaticSessionTokenVar    = "ECS_LOCAL_STATIC_SESSION_TOKEN"
+	// VaultRoleVar is a role of the AWS secrets engine of HashiCorp Vault, which the base credentials are read from;
+	// VaultMountVar is the path of the secrets engine, 'aws' by default, and VaultRoleARNVar and VaultTTLVar are the role_arn and ttl of the request.
+	// Vault is reached with VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE and VAULT_CACERT, as with the Vault CLI.
+	VaultRoleVar    = "ECS_LOCAL_VAULT_ROLE"
+	VaultMountVar   = "ECS_LOCAL_VAULT_MOUNT"
+	VaultRoleARNVar = "ECS_LOCAL_VAULT_ROLE_ARN"
+	VaultTTLVar     = "ECS_LOCAL_VAULT_TTL"
+	// OIDCIssuerVar is the issuer URL of the built-in OIDC provider, which is enabled if it is set; OIDCKeyFileVar is the
+	// path of its PEM encoded RSA signing key, which is generated on startup if it is not set
+	OIDCIssuerVar  = "ECS_LOCAL_OIDC_ISSUER"
+	OIDCKeyFileVar = "ECS_LOCAL_OIDC_KEY_FILE"
+	// ProxyVar is the proxy for requests to AWS and to a remote Docker engine, which overrides HTTPS_PROXY and HTTP_PROXY;
+	// NoProxyVar overrides NO_PROXY, and ProxyUsernameVar and ProxyPasswordVar are sent to the proxy with Basic authentication
+	ProxyVar         = "ECS_LOCAL_PROXY"
+	NoProxyVar       = "ECS_LOCAL_NO_PROXY"
+	ProxyUsernameVar = "ECS_LOCAL_PROXY_USERNAME"
+	ProxyPasswordVar = "ECS_LOCAL_PROXY_PASSWORD"
+	// MockCredentialsVar enables mock credentials, which are generated without making requests to AWS; MockDurationVar overrides their lifetime
+	MockCredentialsVar = "ECS_LOCAL_MOCK_CREDENTIALS"
+	MockDurationVar    = "ECS_LOCAL_MOCK_DURATION"
+	// RotationIntervalVar enables rotation mode; credentials are rotated often, and expire after at most this duration, such as 3m
+	RotationIntervalVar = "ECS_LOCAL_ROTATION_INTERVAL"
+	// SessionNameVar is the template for role session names, such as ecs-local-{container}-{role}-{user}
+	SessionNameVar = "ECS_LOCAL_SESSION_NAME"
+	// MaxRetriesVar is the number of times throttled requests to STS and IAM are retried; 0 disables retries
+	MaxRetriesVar = "ECS_LOCAL_MAX_RETRIES"
+	// RoleCacheTTLVar is how long roles requested by name are cached, such as 30m; 0 disables the cache
+	RoleCacheTTLVar = "ECS_LOCAL_ROLE_CACHE_TTL"
+	// RateLimitVar is the number of credentials requests per second allowed from each client; RateLimitBurstVar is how many can be made at once
+	RateLimitVar      = "ECS_LOCAL_RATE_LIMIT"
+	RateLimitBurstVar = "ECS_LOCAL_RATE_LIMIT_BURST"
+	// AccountIDVar is the account of roles and identities which are not obtained from AWS, and of the task ARNs in metadata;
+	// the default is the account in TASK_ARN
+	AccountIDVar = "ECS_LOCAL_ACCOUNT_ID"
+	// AuditLogVar is the path of a file for the credentials audit log; by default, it is written to the standard log
+	AuditLogVar = "ECS_LOCAL_AUDIT_LOG"
+
+	// LogFormatVar is the format of the log, 'text' or 'json'
+	LogFormatVar = "LOG_FORMAT"
+	// LogLevelVar is the level of the log, such as 'debug'; the default is 'info'
+	LogLevelVar = "LOG_LEVEL"
+
+	// Tracing is enabled when an OTLP endpoint is set; spans are exported with OTLP over HTTP
+	OTLPEndpointVar       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	OTLPTracesEndpointVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	// OTLPHeadersVar is a comma separated list of key=value headers sent with each export
+	OTLPHeadersVar = "OTEL_EXPORTER_OTLP_HEADERS"
+	// ServiceNameVar is the service name of the spans; the default is 'ecs-local-container-endpoints'
+	ServiceNameVar = "OTEL_SERVICE_NAME"
 )
 
 // Defaults
 const (
 	// DefaultPort is the default port the server listens at
 	DefaultPort = "80"
+	// IMDSIPv6Address is the IPv6 address of the EC2 instance metadata service
+	IMDSIPv6Address = "fd00:ec2::254"
 
 	// Metadata related
 	DefaultContainerType = "NORMAL"
@@ -39,11 +187,35 @@ const (
 	DefaultTaskARN       = "arn:aws:ecs:us-west-2:111111111111:task/ecs-local-cluster/37e873f6-37b4-42a7-af47-eac7275c6152"
 	DefaultTDFamily      = "esc-local-task-definition"
 	DefaultTDRevision    = "1"
+	// DefaultEphemeralStorageSize is the size in GiB of the ephemeral storage of Fargate tasks
+	DefaultEphemeralStorageSize = 20
+	DefaultPodInfoDir           = "/etc/podinfo"
+	DefaultPodContainerName     = "ecs-local-endpoints"
+	DefaultNerdctlPath          = "nerdctl"
+	DefaultNetworkName          = "credentials_network"
 )
 
 // Settings
 const (
 	HTTPTimeoutDuration = "5s"
+	// SharedConfigPollDuration is how often the AWS shared config files are checked for changes
+	SharedConfigPollDuration = "5s"
+	// ShutdownTimeoutDuration is how long in-flight requests are given to complete when the container is stopped;
+	// it is less than the 10 second grace period of docker stop, after which the container is killed
+	ShutdownTimeoutDuration = "8s"
+	// DefaultReadTimeoutDuration and DefaultIdleTimeoutDuration are the default timeouts of the HTTP server;
+	// there is no default write timeout, since container stats can be streamed
+	DefaultReadTimeoutDuration = "30s"
+	DefaultIdleTimeoutDuration = "2m"
+	// DefaultAWSTimeoutDuration is the default timeout of each HTTP request to AWS
+	DefaultAWSTimeoutDuration = "30s"
+	// DefaultDockerCacheTTLDuration is how long docker stats and inspect results are cached by default
+	DefaultDockerCacheTTLDuration = "1s"
+	// EndpointsIP is the address of Local Endpoints on the credentials network, where the SDKs request credentials
+	EndpointsIP = "169.254.170.2"
+	// NetworkSubnet and NetworkGateway are the settings of the credentials network, which EndpointsIP is in
+	NetworkSubnet  = "169.254.170.0/24"
+	NetworkGateway = "169.254.170.1"
 )
 
 // URL Paths
@@ -55,10 +227,122 @@ const (
 	// RoleCredentialsPathWithSlash adds a trailing slash
 	RoleCredentialsPathWithSlash = RoleCredentialsPath + "/"
 
+	// ContainerRoleCredentialsPath is the path for obtaining credentials from the role in the calling container's task role label
+	ContainerRoleCredentialsPath = "/role"
+	// ContainerRoleCredentialsPathWithSlash adds a trailing slash
+	ContainerRoleCredentialsPathWithSlash = ContainerRoleCredentialsPath + "/"
+
+	// RolePathCredentialsPath is the path for obtaining credentials from a role by its name with its IAM path, such as
+	// /role/service/my-role or /role/aws-service-role/ecs.amazonaws.com/AWSServiceRoleForECS
+	RolePathCredentialsPath = "/role/{role:(?:[^/]+/)+[^/]+}"
+	// RolePathCredentialsPathWithSlash adds a trailing slash
+	RolePathCredentialsPathWithSlash = RolePathCredentialsPath + "/"
+
+	// RoleARNCredentialsPath is the path for obtaining credentials from a role by its full ARN, which can be in another account
+	RoleARNCredentialsPath = "/role/{roleARN:arn:[^/]+(?:/[^/]+)+}"
+	// RoleARNCredentialsPathWithSlash adds a trailing slash
+	RoleARNCredentialsPathWithSlash = RoleARNCredentialsPath + "/"
+
+	// V2CredentialsPath returns the credentials ID for the role of the calling container
+	V2CredentialsPath = "/v2/credentials"
+	// V2CredentialsPathWithSlash adds a trailing slash
+	V2CredentialsPathWithSlash = V2CredentialsPath + "/"
+	// V2CredentialsIDPath is the path for obtaining credentials by an opaque ID, in the format used by the ECS Agent
+	V2CredentialsIDPath = "/v2/credentials/{id}"
+	// V2CredentialsIDPathWithSlash adds a trailing slash
+	V2CredentialsIDPathWithSlash = V2CredentialsIDPath + "/"
+
 	// TempCredentialsPath is the path for obtaining temp creds from sts:GetSessionsToken
 	TempCredentialsPath = "/creds"
 	// TempCredentialsPathWithSlash adds a trailing slash
 	TempCredentialsPathWithSlash = TempCredentialsPath + "/"
+
+	// PodIdentityCredentialsPath is the path for obtaining credentials from the calling container's role in the format of the EKS Pod Identity Agent
+	PodIdentityCredentialsPath = "/v1/credentials"
+	// PodIdentityCredentialsPathWithSlash adds a trailing slash
+	PodIdentityCredentialsPathWithSlash = PodIdentityCredentialsPath + "/"
+
+	// IMDSCredentialsPath lists the role of the calling container, in the same way as the EC2 instance metadata service
+	IMDSCredentialsPath = "/latest/meta-data/iam/security-credentials"
+	// IMDSCredentialsPathWithSlash adds a trailing slash
+	IMDSCredentialsPathWithSlash = IMDSCredentialsPath + "/"
+	// IMDSRoleCredentialsPath is the path for obtaining credentials from a role in the format of the EC2 instance metadata service
+	IMDSRoleCredentialsPath = "/latest/meta-data/iam/security-credentials/{role}"
+	// IMDSRoleCredentialsPathWithSlash adds a trailing slash
+	IMDSRoleCredentialsPathWithSlash = IMDSRoleCredentialsPath + "/"
+
+	// OIDCDiscoveryPath is the path of the OpenID Connect discovery document of the built-in OIDC provider
+	OIDCDiscoveryPath = "/.well-known/openid-configuration"
+	// OIDCKeysPath is the path of the JSON Web Key Set with the signing key of the OIDC provider
+	OIDCKeysPath = "/oidc/keys"
+	// OIDCTokenPath is the path for obtaining identity tokens from the OIDC provider, for sts:AssumeRoleWithWebIdentity
+	OIDCTokenPath = "/oidc/token"
+	// OIDCTokenPathWithSlash adds a trailing slash
+	OIDCTokenPathWithSlash = OIDCTokenPath + "/"
+
+	// TokenPath is the path for obtaining session tokens with PUT, as in IMDSv2
+	TokenPath = "/latest/api/token"
+
+	// RolesPath is the path for listing the roles and profiles which credentials can be vended for
+	RolesPath = "/roles"
+	// RolesPathWithSlash adds a trailing slash
+	RolesPathWithSlash = RolesPath + "/"
+	// WhoAmIPath is the path for checking the identity of the credentials with sts:GetCallerIdentity
+	WhoAmIPath = "/whoami"
+	// WhoAmIPathWithSlash adds a trailing slash
+	WhoAmIPathWithSlash = WhoAmIPath + "/"
+)
+
+// Health
+const (
+	// HealthPath is the path for checking that Local Endpoints is running
+	HealthPath = "/healthz"
+	// HealthPathWithSlash adds a trailing slash
+	HealthPathWithSlash = HealthPath + "/"
+	// PingPath is an alias for HealthPath
+	PingPath = "/ping"
+	// PingPathWithSlash adds a trailing slash
+	PingPathWithSlash = PingPath + "/"
+	// VersionPath is the path for the version of Local Endpoints
+	VersionPath = "/version"
+	// VersionPathWithSlash adds a trailing slash
+	VersionPathWithSlash = VersionPath + "/"
+	// MetricsPath is the path for Prometheus metrics
+	MetricsPath = "/metrics"
+	// MetricsPathWithSlash adds a trailing slash
+	MetricsPathWithSlash = MetricsPath + "/"
+)
+
+// ECS task scale-in protection
+const (
+	// TaskProtectionPath is the path for the scale-in protection of the caller's task
+	TaskProtectionPath = "/task-protection/v1/state"
+	// TaskProtectionPathWithSlash adds a trailing slash
+	TaskProtectionPathWithSlash = TaskProtectionPath + "/"
+	// AgentAPITaskProtectionPath is TaskProtectionPath under the ECS_AGENT_URI of a container, which is /api/<ID> in ECS
+	AgentAPITaskProtectionPath = "/api/{id}" + TaskProtectionPath
+	// AgentAPITaskProtectionPathWithSlash adds a trailing slash
+	AgentAPITaskProtectionPathWithSlash = AgentAPITaskProtectionPath + "/"
+)
+
+// Secrets
+const (
+	// SecretsPath is the path for the values of the secrets in the caller's container definition
+	SecretsPath = "/secrets"
+	// SecretsPathWithSlash adds a trailing slash
+	SecretsPathWithSlash = SecretsPath + "/"
+)
+
+// ECS Agent introspection API
+const (
+	// IntrospectionMetadataPath is the path for the metadata of the container instance
+	IntrospectionMetadataPath = "/v1/metadata"
+	// IntrospectionMetadataPathWithSlash adds a trailing slash
+	IntrospectionMetadataPathWithSlash = IntrospectionMetadataPath + "/"
+	// IntrospectionTasksPath is the path for the tasks on the container instance
+	IntrospectionTasksPath = "/v1/tasks"
+	// IntrospectionTasksPathWithSlash adds a trailing slash
+	IntrospectionTasksPathWithSlash = IntrospectionTasksPath + "/"
 )
 
 // V3
@@ -98,6 +382,94 @@ const (
 	V3TaskStatsPathWithIdentifier = "/v3/containers/{identifier}/task/stats"
 	// V3TaskStatsPathWithIdentifierAndSlash adds a trailing slash
 	V3TaskStatsPathWithIdentifierAndSlash = V3TaskStatsPathWithIdentifier + "/"
+
+	// V3ContainerIDMetadataPath is the V3 container metadata path in the format used by ECS,
+	// where the container is identified by its short or long Docker ID
+	V3ContainerIDMetadataPath = "/v3/{identifier:[0-9a-f]{12,}}"
+	// V3ContainerIDMetadataPathWithSlash adds a trailing slash
+	V3ContainerIDMetadataPathWithSlash = V3ContainerIDMetadataPath + "/"
+	// V3ContainerIDStatsPath is the V3 container stats path in the format used by ECS
+	V3ContainerIDStatsPath = V3ContainerIDMetadataPath + "/stats"
+	// V3ContainerIDStatsPathWithSlash adds a trailing slash
+	V3ContainerIDStatsPathWithSlash = V3ContainerIDStatsPath + "/"
+	// V3ContainerIDTaskMetadataPath is the V3 task metadata path in the format used by ECS
+	V3ContainerIDTaskMetadataPath = V3ContainerIDMetadataPath + "/task"
+	// V3ContainerIDTaskMetadataPathWithSlash adds a trailing slash
+	V3ContainerIDTaskMetadataPathWithSlash = V3ContainerIDTaskMetadataPath + "/"
+	// V3ContainerIDTaskStatsPath is the V3 task stats path in the format used by ECS
+	V3ContainerIDTaskStatsPath = V3ContainerIDTaskMetadataPath + "/stats"
+	// V3ContainerIDTaskStatsPathWithSlash adds a trailing slash
+	V3ContainerIDTaskStatsPathWithSlash = V3ContainerIDTaskStatsPath + "/"
+)
+
+// V4
+const (
+	// V4ContainerMetadataPath is the path for V4 container metadata
+	V4ContainerMetadataPath = "/v4"
+	// V4ContainerMetadataPathWithSlash adds a trailing slash
+	V4ContainerMetadataPathWithSlash = V4ContainerMetadataPath + "/"
+	// V4ContainerMetadataPathWithIdentifier is the V4 container metadata path with an identifer specified
+	V4ContainerMetadataPathWithIdentifier = "/v4/containers/{identifier}"
+	// V4ContainerMetadataPathWithIdentifierAndSlash adds a trailing slash
+	V4ContainerMetadataPathWithIdentifierAndSlash = V4ContainerMetadataPathWithIdentifier + "/"
+
+	// V4ContainerStatsPath is the path for V4 container stats
+	V4ContainerStatsPath = "/v4/stats"
+	// V4ContainerStatsPathWithSlash adds a trailing slash
+	V4ContainerStatsPathWithSlash = V4ContainerStatsPath + "/"
+	// V4ContainerStatsPathWithIdentifier is the V4 container stats path with an identifier
+	V4ContainerStatsPathWithIdentifier = "/v4/containers/{identifier}/stats"
+	// V4ContainerStatsPathWithIdentifierAndSlash adds a trailing slash
+	V4ContainerStatsPathWithIdentifierAndSlash = V4ContainerStatsPathWithIdentifier + "/"
+
+	// V4TaskMetadataPath is the path for V4 task metadata
+	V4TaskMetadataPath = "/v4/task"
+	// V4TaskMetadataPathWithSlash adds a trailing slash
+	V4TaskMetadataPathWithSlash = V4TaskMetadataPath + "/"
+	// V4TaskMetadataPathWithIdentifier is the V4 task metadata path with an identifier
+	V4TaskMetadataPathWithIdentifier = "/v4/containers/{identifier}/task"
+	// V4TaskMetadataPathWithIdentifierWithSlash adds a trailing slash
+	V4TaskMetadataPathWithIdentifierWithSlash = V4TaskMetadataPathWithIdentifier + "/"
+
+	// V4TaskWithTagsPath is the path for V4 task metadata with the task and container instance tags
+	V4TaskWithTagsPath = "/v4/taskWithTags"
+	// V4TaskWithTagsPathWithSlash adds a trailing slash
+	V4TaskWithTagsPathWithSlash = V4TaskWithTagsPath + "/"
+	// V4TaskWithTagsPathWithIdentifier is the V4 task metadata with tags path with an identifier
+	V4TaskWithTagsPathWithIdentifier = "/v4/containers/{identifier}/taskWithTags"
+	// V4TaskWithTagsPathWithIdentifierWithSlash adds a trailing slash
+	V4TaskWithTagsPathWithIdentifierWithSlash = V4TaskWithTagsPathWithIdentifier + "/"
+
+	// V4TaskStatsPath is the path for V4 task stats
+	V4TaskStatsPath = "/v4/task/stats"
+	// V4TaskStatsPathWithSlash adds a trailing slash
+	V4TaskStatsPathWithSlash = V4TaskStatsPath + "/"
+	// V4TaskStatsPathWithIdentifier is the V4 task stats path with an identifier
+	V4TaskStatsPathWithIdentifier = "/v4/containers/{identifier}/task/stats"
+	// V4TaskStatsPathWithIdentifierAndSlash adds a trailing slash
+	V4TaskStatsPathWithIdentifierAndSlash = V4TaskStatsPathWithIdentifier + "/"
+
+	// V4ContainerIDMetadataPath is the V4 container metadata path in the format used by ECS,
+	// where the container is identified by its short or long Docker ID
+	V4ContainerIDMetadataPath = "/v4/{identifier:[0-9a-f]{12,}}"
+	// V4ContainerIDMetadataPathWithSlash adds a trailing slash
+	V4ContainerIDMetadataPathWithSlash = V4ContainerIDMetadataPath + "/"
+	// V4ContainerIDStatsPath is the V4 container stats path in the format used by ECS
+	V4ContainerIDStatsPath = V4ContainerIDMetadataPath + "/stats"
+	// V4ContainerIDStatsPathWithSlash adds a trailing slash
+	V4ContainerIDStatsPathWithSlash = V4ContainerIDStatsPath + "/"
+	// V4ContainerIDTaskMetadataPath is the V4 task metadata path in the format used by ECS
+	V4ContainerIDTaskMetadataPath = V4ContainerIDMetadataPath + "/task"
+	// V4ContainerIDTaskMetadataPathWithSlash adds a trailing slash
+	V4ContainerIDTaskMetadataPathWithSlash = V4ContainerIDTaskMetadataPath + "/"
+	// V4ContainerIDTaskWithTagsPath is the V4 task metadata with tags path in the format used by ECS
+	V4ContainerIDTaskWithTagsPath = V4ContainerIDMetadataPath + "/taskWithTags"
+	// V4ContainerIDTaskWithTagsPathWithSlash adds a trailing slash
+	V4ContainerIDTaskWithTagsPathWithSlash = V4ContainerIDTaskWithTagsPath + "/"
+	// V4ContainerIDTaskStatsPath is the V4 task stats path in the format used by ECS
+	V4ContainerIDTaskStatsPath = V4ContainerIDTaskMetadataPath + "/stats"
+	// V4ContainerIDTaskStatsPathWithSlash adds a trailing slash
+	V4ContainerIDTaskStatsPathWithSlash = V4ContainerIDTaskStatsPath + "/"
 )
 
 // V2
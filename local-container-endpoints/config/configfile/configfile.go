@@ -0,0 +1,290 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package configfile reads the optional Local Endpoints config file
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	metadataSection    = "metadata"
+	credentialsSection = "credentials"
+	servicesSection    = "services"
+
+	roleKey       = "role"
+	durationKey   = "duration"
+	externalIDKey = "external_id"
+	regionKey     = "region"
+	sessionTagKey = "session_tags"
+
+	composeFilesKey = "compose_files"
+)
+
+// tagKeys are the settings which can be written as a map of tags, instead of key1=value1,key2=value2
+var tagKeys = map[string]bool{
+	sessionTagKey:             true,
+	"task_tags":               true,
+	"container_instance_tags": true,
+}
+
+// settings maps the keys of the global sections to the environment variables they replace
+var settings = map[string]map[string]string{
+	metadataSection: {
+		"cluster":                 config.ClusterARNVar,
+		"task_arn":                config.TaskARNVar,
+		"family":                  config.TDFamilyVar,
+		"revision":                config.TDRevisionVar,
+		"service_name":            config.ServiceNameMetadataVar,
+		"account_id":              config.AccountIDVar,
+		"task_tags":               config.TaskTagsVar,
+		"container_instance_tags": config.ContainerInstanceTagsVar,
+		"ephemeral_storage_size":  config.EphemeralStorageSizeVar,
+		"task_definition":         config.TaskDefinitionVar,
+		"docker_cache_ttl":        config.DockerCacheTTLVar,
+		composeFilesKey:           config.ComposeFilesVar,
+	},
+	credentialsSection: {
+		durationKey:         config.CredentialsDurationVar,
+		"mfa_serial":        config.MFASerialVar,
+		sessionTagKey:       config.SessionTagsVar,
+		"session_policy":    config.SessionPolicyVar,
+		"allowed_roles":     config.AllowedRolesVar,
+		"denied_roles":      config.DeniedRolesVar,
+		"require_token":     config.RequireTokenVar,
+		"imds_tokens":       config.IMDSTokensVar,
+		"rotation_interval": config.RotationIntervalVar,
+		"role_cache_ttl":    config.RoleCacheTTLVar,
+		"session_name":      config.SessionNameVar,
+		"max_retries":       config.MaxRetriesVar,
+		"rate_limit":        config.RateLimitVar,
+		"rate_limit_burst":  config.RateLimitBurstVar,
+	},
+}
+
+// Service holds the settings for the containers of a Docker Compose service
+type Service struct {
+	// Role is the name or ARN of the role for the service's containers
+	Role       string
+	ExternalID string
+	// Duration is the lifetime of the service's credentials in seconds; 0 means the default
+	Duration int64
+	// Region is the region of the STS endpoint used for the service's credentials
+	Region string
+}
+
+// Config is the contents of the config file
+type Config struct {
+	// Environment holds the values of the global settings, keyed by the environment variable they replace
+	Environment map[string]string
+	Services    map[string]Service
+	// applied holds the environment variables which were set from the file
+	applied map[string]bool
+}
+
+// Load reads the config file given by the ECS_LOCAL_CONFIG_FILE environment variable;
+// if the variable is not set, an empty config is returned.
+func Load() (*Config, error) {
+	path := os.Getenv(config.ConfigFileVar)
+	if path == "" {
+		return &Config{}, nil
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads a config file
+func LoadFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read config file")
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse config file %s", path)
+	}
+	return cfg, nil
+}
+
+// Parse reads a config file in either JSON or YAML
+func Parse(data []byte) (*Config, error) {
+	document, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		Environment: make(map[string]string),
+		Services:    make(map[string]Service),
+	}
+	for section, value := range document {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected '%s' to be a map", section)
+		}
+		if section == servicesSection {
+			if err := cfg.parseServices(values); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		envVars, ok := settings[section]
+		if !ok {
+			return nil, fmt.Errorf("unknown section '%s'", section)
+		}
+		for key, value := range values {
+			envVar, ok := envVars[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown setting '%s' in '%s'", key, section)
+			}
+			setting, err := settingValue(section, key, value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Environment[envVar] = setting
+		}
+	}
+	return cfg, nil
+}
+
+// SetEnvironment sets the environment variables replaced by the config file;
+// variables which are already set take precedence over the file.
+func (cfg *Config) SetEnvironment() error {
+	return cfg.ReplaceEnvironment(&Config{})
+}
+
+// ReplaceEnvironment sets the environment variables replaced by the config file in place of those set from the previous file,
+// which are unset if they are no longer in the file; variables which were not set from the previous file take precedence.
+func (cfg *Config) ReplaceEnvironment(previous *Config) error {
+	for envVar := range previous.applied {
+		if _, ok := cfg.Environment[envVar]; !ok {
+			if err := os.Unsetenv(envVar); err != nil {
+				return err
+			}
+		}
+	}
+	cfg.applied = make(map[string]bool)
+	for envVar, value := range cfg.Environment {
+		if os.Getenv(envVar) != "" && !previous.applied[envVar] {
+			continue
+		}
+		if err := os.Setenv(envVar, value); err != nil {
+			return err
+		}
+		cfg.applied[envVar] = true
+	}
+	return nil
+}
+
+func (cfg *Config) parseServices(services map[string]interface{}) error {
+	for name, value := range services {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected service '%s' to be a map", name)
+		}
+		service := Service{}
+		for key, value := range values {
+			setting, ok := scalar(value)
+			if !ok {
+				return fmt.Errorf("expected '%s' of service '%s' to be a single value", key, name)
+			}
+			switch key {
+			case roleKey:
+				service.Role = setting
+			case externalIDKey:
+				service.ExternalID = setting
+			case regionKey:
+				service.Region = setting
+			case durationKey:
+				duration, err := strconv.ParseInt(setting, 10, 64)
+				if err != nil || duration < 0 {
+					return fmt.Errorf("expected '%s' of service '%s' to be a number of seconds, got %s", key, name, setting)
+				}
+				service.Duration = duration
+			default:
+				return fmt.Errorf("unknown setting '%s' in service '%s'", key, name)
+			}
+		}
+		cfg.Services[name] = service
+	}
+	return nil
+}
+
+func settingValue(section, key string, value interface{}) (string, error) {
+	if setting, ok := scalar(value); ok {
+		return setting, nil
+	}
+	// lists of paths can be written as a list, instead of path1,path2
+	if paths, ok := value.([]interface{}); ok && key == composeFilesKey {
+		var settings []string
+		for _, path := range paths {
+			setting, ok := scalar(path)
+			if !ok {
+				return "", fmt.Errorf("expected each of '%s' in '%s' to be a single value", key, section)
+			}
+			settings = append(settings, setting)
+		}
+		return strings.Join(settings, ","), nil
+	}
+	// tags can also be written as a map, instead of key1=value1,key2=value2
+	tags, ok := value.(map[string]interface{})
+	if !ok || !tagKeys[key] {
+		return "", fmt.Errorf("expected '%s' in '%s' to be a single value", key, section)
+	}
+	var pairs []string
+	for tagKey, tagValue := range tags {
+		tag, ok := scalar(tagValue)
+		if !ok {
+			return "", fmt.Errorf("expected tag '%s' in '%s' to be a single value", tagKey, section)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", tagKey, tag))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ","), nil
+}
+
+// ParseDocument reads a map from either JSON or the subset of YAML which is supported by Local Endpoints;
+// maps are read as map[string]interface{}, and lists as []interface{}
+func ParseDocument(data []byte) (map[string]interface{}, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var document map[string]interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+		return document, nil
+	}
+	return parseYAML(data)
+}
+
+// scalar returns the string form of a single value from either YAML or JSON
+func scalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
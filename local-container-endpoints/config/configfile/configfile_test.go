@@ -0,0 +1,258 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testYAMLConfig = `
+# Local Endpoints settings
+metadata:
+  cluster: my-cluster
+  task_arn: "arn:aws:ecs:us-west-2:111111111111:task/my-cluster/1234"
+  service_name: my-service
+  task_tags:
+    team: containers
+  ephemeral_storage_size: 50
+  compose_files:
+    - docker-compose.yml
+    - docker-compose.override.yml
+credentials:
+  duration: 1800
+  denied_roles: "*admin*"
+  session_tags:
+    team: containers
+    project: local
+services:
+  app:
+    role: my-app-role # the role for the app
+    duration: 900
+  worker:
+    role: arn:aws:iam::222222222222:role/worker
+    external_id: 'cat''s id'
+    region: eu-west-1
+`
+
+const testJSONConfig = `{
+  "metadata": {"family": "my-task", "revision": 3},
+  "services": {
+    "app": {"role": "my-app-role", "duration": 900}
+  }
+}`
+
+func TestParseYAML(t *testing.T) {
+	cfg, err := Parse([]byte(testYAMLConfig))
+	assert.NoError(t, err, "Unexpected error parsing config")
+
+	expectedEnvironment := map[string]string{
+		config.ClusterARNVar:           "my-cluster",
+		config.TaskARNVar:              "arn:aws:ecs:us-west-2:111111111111:task/my-cluster/1234",
+		config.ServiceNameMetadataVar:  "my-service",
+		config.TaskTagsVar:             "team=containers",
+		config.EphemeralStorageSizeVar: "50",
+		config.ComposeFilesVar:         "docker-compose.yml,docker-compose.override.yml",
+		config.CredentialsDurationVar:  "1800",
+		config.DeniedRolesVar:          "*admin*",
+		config.SessionTagsVar:          "project=local,team=containers",
+	}
+	expectedServices := map[string]Service{
+		"app": Service{
+			Role:     "my-app-role",
+			Duration: 900,
+		},
+		"worker": Service{
+			Role:       "arn:aws:iam::222222222222:role/worker",
+			ExternalID: "cat's id",
+			Region:     "eu-west-1",
+		},
+	}
+	assert.Equal(t, expectedEnvironment, cfg.Environment, "Expected environment to match")
+	assert.Equal(t, expectedServices, cfg.Services, "Expected services to match")
+}
+
+func TestParseJSON(t *testing.T) {
+	cfg, err := Parse([]byte(testJSONConfig))
+	assert.NoError(t, err, "Unexpected error parsing config")
+
+	expectedEnvironment := map[string]string{
+		config.TDFamilyVar:   "my-task",
+		config.TDRevisionVar: "3",
+	}
+	expectedServices := map[string]Service{
+		"app": Service{
+			Role:     "my-app-role",
+			Duration: 900,
+		},
+	}
+	assert.Equal(t, expectedEnvironment, cfg.Environment, "Expected environment to match")
+	assert.Equal(t, expectedServices, cfg.Services, "Expected services to match")
+}
+
+func TestParseDocumentLists(t *testing.T) {
+	document, err := ParseDocument([]byte(`services:
+  app:
+    ports:
+      - "8080:80"
+      - 443
+    depends_on: [db, "cache"]
+    environment:
+    - NAME=app
+    healthcheck:
+      test:
+        - CMD
+        - curl
+    command: |
+      echo hello # not a comment
+      echo world
+  db:
+    volumes:
+      - type: volume
+        source: data
+        target: /var/lib/data
+`))
+	assert.NoError(t, err, "Unexpected error parsing document")
+
+	expected := map[string]interface{}{
+		"services": map[string]interface{}{
+			"app": map[string]interface{}{
+				"ports":       []interface{}{"8080:80", "443"},
+				"depends_on":  []interface{}{"db", "cache"},
+				"environment": []interface{}{"NAME=app"},
+				"healthcheck": map[string]interface{}{
+					"test": []interface{}{"CMD", "curl"},
+				},
+				"command": "echo hello # not a comment\necho world\n",
+			},
+			"db": map[string]interface{}{
+				"volumes": []interface{}{
+					map[string]interface{}{
+						"type":   "volume",
+						"source": "data",
+						"target": "/var/lib/data",
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, document, "Expected document to match")
+}
+
+func TestParseErrors(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		config string
+	}{
+		{"unknown section", "cats:\n  name: clyde\n"},
+		{"unknown setting", "metadata:\n  owner: clyde\n"},
+		{"unknown service setting", "services:\n  app:\n    policy: none\n"},
+		{"invalid duration", "services:\n  app:\n    duration: 15m\n"},
+		{"section is not a map", "metadata: clyde\n"},
+		{"list", "services:\n  - app\n"},
+		{"bad indentation", "metadata:\n    cluster: a\n  family: b\n"},
+		{"duplicate key", "metadata:\n  cluster: a\n  cluster: b\n"},
+		{"missing colon", "metadata:\n  cluster\n"},
+		{"tab indentation", "metadata:\n\tcluster: a\n"},
+		{"flow style", "metadata: {cluster: a}\n"},
+		{"invalid json", `{"metadata": `},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Parse([]byte(testCase.config))
+		assert.Error(t, err, "Expected error for %s", testCase.name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configfile")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	err = ioutil.WriteFile(path, []byte(testYAMLConfig), 0644)
+	assert.NoError(t, err, "Unexpected error writing config file")
+
+	os.Setenv(config.ConfigFileVar, path)
+	defer os.Unsetenv(config.ConfigFileVar)
+	cfg, err := Load()
+	assert.NoError(t, err, "Unexpected error loading config file")
+	assert.Len(t, cfg.Services, 2, "Expected services from the config file")
+
+	os.Setenv(config.ConfigFileVar, filepath.Join(dir, "missing.yml"))
+	_, err = Load()
+	assert.Error(t, err, "Expected error for a missing config file")
+
+	os.Unsetenv(config.ConfigFileVar)
+	cfg, err = Load()
+	assert.NoError(t, err, "Unexpected error when no config file is set")
+	assert.Empty(t, cfg.Services, "Expected no services without a config file")
+}
+
+func TestSetEnvironment(t *testing.T) {
+	os.Setenv(config.ClusterARNVar, "env-cluster")
+	defer os.Unsetenv(config.ClusterARNVar)
+	defer os.Unsetenv(config.TDFamilyVar)
+
+	cfg := &Config{
+		Environment: map[string]string{
+			config.ClusterARNVar: "file-cluster",
+			config.TDFamilyVar:   "file-family",
+		},
+	}
+	err := cfg.SetEnvironment()
+	assert.NoError(t, err, "Unexpected error setting environment")
+	assert.Equal(t, "env-cluster", os.Getenv(config.ClusterARNVar), "Expected environment variable to take precedence")
+	assert.Equal(t, "file-family", os.Getenv(config.TDFamilyVar), "Expected value from the config file")
+}
+
+func TestReplaceEnvironment(t *testing.T) {
+	os.Setenv(config.ClusterARNVar, "env-cluster")
+	defer os.Unsetenv(config.ClusterARNVar)
+	defer os.Unsetenv(config.TDFamilyVar)
+	defer os.Unsetenv(config.TDRevisionVar)
+
+	previous := &Config{
+		Environment: map[string]string{
+			config.ClusterARNVar: "file-cluster",
+			config.TDFamilyVar:   "file-family",
+			config.TDRevisionVar: "3",
+		},
+	}
+	err := previous.SetEnvironment()
+	assert.NoError(t, err, "Unexpected error setting environment")
+
+	cfg := &Config{
+		Environment: map[string]string{
+			config.ClusterARNVar: "new-cluster",
+			config.TDFamilyVar:   "new-family",
+		},
+	}
+	err = cfg.ReplaceEnvironment(previous)
+	assert.NoError(t, err, "Unexpected error replacing environment")
+	assert.Equal(t, "env-cluster", os.Getenv(config.ClusterARNVar), "Expected environment variable to take precedence")
+	assert.Equal(t, "new-family", os.Getenv(config.TDFamilyVar), "Expected value from the new config file")
+	assert.Equal(t, "", os.Getenv(config.TDRevisionVar), "Expected the removed setting to be unset")
+
+	// the previous file can be restored
+	err = previous.ReplaceEnvironment(cfg)
+	assert.NoError(t, err, "Unexpected error replacing environment")
+	assert.Equal(t, "file-family", os.Getenv(config.TDFamilyVar), "Expected value from the previous config file")
+	assert.Equal(t, "3", os.Getenv(config.TDRevisionVar), "Expected value from the previous config file")
+}
@@ -0,0 +1,329 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlParser reads a YAML document line by line; lists of maps are read by replacing the '- ' of each item
+// with spaces, so that the item is read as a map which is indented further
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// parseYAML reads the subset of YAML used by the config file and Compose files: nested maps and lists of
+// scalar values, lists of scalars in flow style, and block scalars, with comments.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	parser := &yamlParser{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parser.lines = append(parser.lines, strings.TrimRight(scanner.Text(), " \t\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	indent, content, ok, err := parser.peek()
+	if err != nil || !ok {
+		return map[string]interface{}{}, err
+	}
+	if isListItem(content) {
+		return nil, fmt.Errorf("line %d: expected a map", parser.pos+1)
+	}
+	document, err := parser.parseMap(indent)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok, err := parser.peek(); err != nil || ok {
+		if err == nil {
+			err = fmt.Errorf("line %d: unexpected indentation", parser.pos+1)
+		}
+		return nil, err
+	}
+	return document, nil
+}
+
+// peek returns the indentation and content of the next line which is not blank or a comment, without consuming it
+func (parser *yamlParser) peek() (int, string, bool, error) {
+	for ; parser.pos < len(parser.lines); parser.pos++ {
+		line := stripComment(parser.lines[parser.pos])
+		content := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(content) == "" || content == "---" {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return 0, "", false, fmt.Errorf("line %d: tabs can not be used for indentation", parser.pos+1)
+		}
+		return len(line) - len(content), content, true, nil
+	}
+	return 0, "", false, nil
+}
+
+func isListItem(content string) bool {
+	return strings.HasPrefix(content, "- ") || content == "-"
+}
+
+// parseMap reads the keys of a map which are at the given indentation
+func (parser *yamlParser) parseMap(indent int) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for {
+		lineIndent, content, ok, err := parser.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || lineIndent < indent {
+			return values, nil
+		}
+		lineNumber := parser.pos + 1
+		if lineIndent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineNumber)
+		}
+		if isListItem(content) {
+			return nil, fmt.Errorf("line %d: expected 'key: value', got a list item", lineNumber)
+		}
+		key, value, err := splitKeyValue(content)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", lineNumber, key)
+		}
+		parser.pos++
+		values[key], err = parser.parseValue(indent, value, lineNumber)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseList reads the items of a list which are at the given indentation
+func (parser *yamlParser) parseList(indent int) ([]interface{}, error) {
+	var items []interface{}
+	for {
+		lineIndent, content, ok, err := parser.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || lineIndent < indent || (lineIndent == indent && !isListItem(content)) {
+			return items, nil
+		}
+		lineNumber := parser.pos + 1
+		if lineIndent > indent || !isListItem(content) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineNumber)
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(content, "-"))
+		var item interface{}
+		if _, _, err := splitKeyValue(value); err == nil && !isQuoted(value) && !strings.HasPrefix(value, "[") {
+			// a map which starts on the line of the item
+			itemIndent := indent + len(content) - len(strings.TrimLeft(strings.TrimPrefix(content, "-"), " "))
+			parser.lines[parser.pos] = strings.Repeat(" ", itemIndent) + value
+			item, err = parser.parseMap(itemIndent)
+		} else {
+			parser.pos++
+			item, err = parser.parseValue(indent, value, lineNumber)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// parseValue reads the value of a key or list item at the given indentation, which is either on the same line,
+// or is a map, list, or block scalar on the following lines
+func (parser *yamlParser) parseValue(indent int, value string, lineNumber int) (interface{}, error) {
+	if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+		return parser.parseBlockScalar(indent, value), nil
+	}
+	if value != "" {
+		scalarValue, err := parseScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		return scalarValue, nil
+	}
+	childIndent, content, ok, err := parser.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ok && childIndent > indent && isListItem(content):
+		return parser.parseList(childIndent)
+	case ok && childIndent > indent:
+		return parser.parseMap(childIndent)
+	case ok && childIndent == indent && isListItem(content):
+		// the items of a list can be at the same indentation as its key
+		return parser.parseList(childIndent)
+	}
+	return map[string]interface{}{}, nil
+}
+
+// parseBlockScalar reads the lines of a literal (|) or folded (>) block scalar which are indented further than its key
+func (parser *yamlParser) parseBlockScalar(indent int, indicator string) string {
+	var lines []string
+	blockIndent := -1
+	for ; parser.pos < len(parser.lines); parser.pos++ {
+		line := parser.lines[parser.pos]
+		content := strings.TrimLeft(line, " ")
+		if content == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lineIndent := len(line) - len(content)
+		if lineIndent <= indent {
+			break
+		}
+		if blockIndent == -1 {
+			blockIndent = lineIndent
+		}
+		if lineIndent < blockIndent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+	// trailing blank lines are not part of the value
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	separator := "\n"
+	if strings.HasPrefix(indicator, ">") {
+		separator = " "
+	}
+	value := strings.Join(lines, separator)
+	if !strings.Contains(indicator, "-") && value != "" {
+		value += "\n"
+	}
+	return value
+}
+
+// parseScalar reads a value on the line of its key, which is a scalar or a list of scalars in flow style
+func parseScalar(value string) (interface{}, error) {
+	if !strings.HasPrefix(value, "[") {
+		return unquote(value)
+	}
+	if !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("unterminated flow style list %s", value)
+	}
+	items := []interface{}{}
+	for _, item := range splitFlowList(value[1 : len(value)-1]) {
+		if strings.HasPrefix(item, "[") {
+			return nil, fmt.Errorf("nested flow style lists are not supported")
+		}
+		unquoted, err := unquote(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, unquoted)
+	}
+	return items, nil
+}
+
+// splitFlowList splits the items of a flow style list at the commas which are outside of quotes
+func splitFlowList(list string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, c := range list {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(list[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items
+}
+
+func isQuoted(value string) bool {
+	return strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'")
+}
+
+// stripComment removes a comment which starts with '#' outside of quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || line[i-1] == ' '):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return line
+}
+
+func splitKeyValue(content string) (string, string, error) {
+	var key, rest string
+	if strings.HasPrefix(content, `"`) || strings.HasPrefix(content, "'") {
+		end := strings.IndexRune(content[1:], rune(content[0]))
+		if end == -1 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		key, rest = content[1:end+1], content[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expected ':' after key %s", key)
+		}
+		rest = rest[1:]
+	} else {
+		index := strings.Index(content, ": ")
+		if index == -1 {
+			if !strings.HasSuffix(content, ":") {
+				return "", "", fmt.Errorf("expected 'key: value', got %s", content)
+			}
+			index = len(content) - 1
+		}
+		key, rest = content[:index], content[index+1:]
+	}
+	if rest != "" && !strings.HasPrefix(rest, " ") {
+		return "", "", fmt.Errorf("expected a space after the ':' of key %s", key)
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(rest), nil
+}
+
+func unquote(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1), nil
+	case strings.HasPrefix(value, "{"):
+		return "", fmt.Errorf("flow style maps are not supported")
+	}
+	return value, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/composefile"
+	"github.com/sirupsen/logrus"
+)
+
+// loadComposeProject reads the Compose project from the comma separated list of Compose files,
+// or returns nil if none are set
+func loadComposeProject() (*composefile.Project, error) {
+	value := os.Getenv(config.ComposeFilesVar)
+	if value == "" {
+		return nil, nil
+	}
+	var paths []string
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	project, err := composefile.Load(paths)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Using the %d services of Compose project %s in metadata", len(project.Services), project.Name)
+	return project, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+)
+
+const accountIDQueryParameter = "accountId"
+
+// getAccountIDParameter returns the account in the request's accountId query parameter
+func getAccountIDParameter(r *http.Request) (string, error) {
+	accountID := r.URL.Query().Get(accountIDQueryParameter)
+	if accountID != "" && !accountIDPattern.MatchString(accountID) {
+		return "", HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Invalid '%s' query parameter: %s is not a 12 digit account ID", accountIDQueryParameter, accountID),
+		}
+	}
+	return accountID, nil
+}
+
+// getRoleARNInAccount returns the ARN of a role in another account, given by its name and optional IAM path, since
+// iam:GetRole can only find roles in the account of the credentials. The partition is that of the STS region.
+func (service *CredentialService) getRoleARNInAccount(rolePath, accountID, region string) string {
+	path, roleName := splitRolePath(rolePath)
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role%s%s", service.getSTSPartition(region), accountID, path, roleName)
+}
+
+// checkAccountID returns an error if a role requested by ARN is not in the account of the accountId query parameter
+func checkAccountID(roleARN, accountID string) error {
+	if accountID == "" {
+		return nil
+	}
+	if roleAccountID := strings.SplitN(roleARN, ":", 6)[4]; roleAccountID != accountID {
+		return HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Role %s is in account %s, not in account %s of the '%s' query parameter", roleARN, roleAccountID, accountID, accountIDQueryParameter),
+		}
+	}
+	return nil
+}
+
+// getSTSPartition returns the partition of the region of the STS endpoint which assumes roles; region is the region
+// of the request, and if it is empty, the session's region is used. Without a session, as for mock and static
+// credentials, it is the partition of their roles.
+func (service *CredentialService) getSTSPartition(region string) string {
+	if region == "" && service.currentSession != nil {
+		region = aws.StringValue(service.currentSession.Config.Region)
+	}
+	if region == "" {
+		return getPartition()
+	}
+	return utils.GetPartition(region)
+}
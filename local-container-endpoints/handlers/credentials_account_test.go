@@ -0,0 +1,127 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAccountIDParameter(t *testing.T) {
+	accountID, err := getAccountIDParameter(httptest.NewRequest("GET", "/role/clyde?accountId=222222222222", nil))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "222222222222", accountID, "Expected account ID to match")
+
+	accountID, err = getAccountIDParameter(httptest.NewRequest("GET", "/role/clyde", nil))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Empty(t, accountID, "Expected no account ID")
+
+	for _, value := range []string{"2222", "22222222222a", "arn:aws:iam::222222222222:root"} {
+		_, err = getAccountIDParameter(httptest.NewRequest("GET", "/role/clyde?accountId="+value, nil))
+		assert.Error(t, err, "Expected error for %s", value)
+	}
+}
+
+func TestGetRoleARNInAccount(t *testing.T) {
+	credsService := &CredentialService{
+		currentSession: session.Must(session.NewSession(&aws.Config{Region: aws.String("us-west-2")})),
+	}
+	assert.Equal(t, "arn:aws:iam::222222222222:role/clyde", credsService.getRoleARNInAccount("clyde", "222222222222", ""))
+	assert.Equal(t, "arn:aws:iam::222222222222:role/service/clyde", credsService.getRoleARNInAccount("/service/clyde", "222222222222", ""))
+	assert.Equal(t, "arn:aws-us-gov:iam::222222222222:role/clyde", credsService.getRoleARNInAccount("clyde", "222222222222", "us-gov-west-1"),
+		"Expected the partition of the region in the request")
+	assert.Equal(t, "arn:aws-cn:iam::222222222222:role/clyde", (&CredentialService{
+		currentSession: session.Must(session.NewSession(&aws.Config{Region: aws.String("cn-north-1")})),
+	}).getRoleARNInAccount("clyde", "222222222222", ""), "Expected the partition of the session's region")
+}
+
+func TestGetRoleCredentialsInAccount(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	expiration := time.Now().Add(time.Hour)
+	// roles in another account are assumed without iam:GetRole
+	gomock.InOrder(
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, "arn:aws:iam::222222222222:role/"+roleName, aws.StringValue(input.RoleArn), "Expected the role in the account")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, "arn:aws:iam::333333333333:role/service/"+roleName, aws.StringValue(input.RoleArn), "Expected the role with its path in the account")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	var testCases = []struct {
+		path        string
+		expectedARN string
+	}{
+		{"/role/" + roleName + "?accountId=222222222222", "arn:aws:iam::222222222222:role/" + roleName},
+		{"/role/service/" + roleName + "?accountId=333333333333", "arn:aws:iam::333333333333:role/service/" + roleName},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", testCase.path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, "Expected status OK for %s", testCase.path)
+		response := &CredentialResponse{}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response), "Unexpected error unmarshalling response for %s", testCase.path)
+		assert.Equal(t, testCase.expectedARN, response.RoleArn, "Expected role ARN to match for %s", testCase.path)
+	}
+}
+
+func TestGetRoleCredentialsInAccountErrors(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	for _, path := range []string{
+		"/role/" + roleName + "?accountId=2222",
+		"/role/" + crossAccountRoleARN + "?accountId=333333333333",
+		"/creds?accountId=222222222222",
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Expected bad request for %s", path)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metrics"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// newAuditLog returns the logger for the credentials audit log; if the audit log file is set, JSON entries are appended to it,
+// otherwise they are written to the standard log.
+func newAuditLog() (*logrus.Logger, error) {
+	path := os.Getenv(config.AuditLogVar)
+	if path == "" {
+		return logrus.StandardLogger(), nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open the audit log %s", path)
+	}
+	auditLog := logrus.New()
+	auditLog.Out = file
+	auditLog.Formatter = &logrus.JSONFormatter{}
+	logrus.Infof("Writing the credentials audit log to %s", path)
+	return auditLog, nil
+}
+
+// auditCredentials records that credentials were vended for the request, along with the container which made it
+func (service *CredentialService) auditCredentials(r *http.Request, response *CredentialResponse) {
+	service.auditContainerCredentials(r, response, nil)
+}
+
+// auditContainerCredentials is auditCredentials for a request from a known container; if container is nil, it is looked up
+func (service *CredentialService) auditContainerCredentials(r *http.Request, response *CredentialResponse, container *types.Container) {
+	callerIP := getCallerIP(r)
+
+	fields := logrus.Fields{
+		"caller_ip":     callerIP,
+		"path":          r.URL.Path,
+		"role_arn":      response.RoleArn,
+		"access_key_id": response.AccessKeyID,
+		"expiration":    response.Expiration,
+	}
+	if profile := r.URL.Query().Get(profileQueryParameter); profile != "" {
+		fields["profile"] = profile
+	}
+	if container == nil {
+		container = service.findCallerContainer(r.Context(), callerIP)
+	}
+	requestFields := logrus.Fields{
+		"role_arn": response.RoleArn,
+	}
+	if container != nil {
+		fields["container_id"] = container.ID
+		fields["container_name"] = getContainerName(container)
+		requestFields["container_name"] = fields["container_name"]
+	}
+	setRequestLogFields(r, requestFields)
+	metrics.CredentialsVended(response.RoleArn)
+
+	auditLog := service.auditLog
+	if auditLog == nil {
+		auditLog = logrus.StandardLogger()
+	}
+	auditLog.WithFields(fields).Info("Vended credentials")
+}
+
+// findCallerContainer returns the container with the caller's IP address, or nil if it can't be found,
+// for example because the request came from the host
+func (service *CredentialService) findCallerContainer(ctx context.Context, callerIP string) *types.Container {
+	if service.dockerClient == nil || callerIP == "" {
+		return nil
+	}
+
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		logrus.Debugf("Failed to list running containers to find the caller: %s", err)
+		return nil
+	}
+	container, err := findContainerByCallerIP(containers, callerIP)
+	if err != nil {
+		logrus.Debug(err)
+		return nil
+	}
+	return container
+}
+
+// getContainerName returns the name of the container without the leading slash added by Docker
+func getContainerName(container *types.Container) string {
+	if len(container.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(container.Names[0], "/")
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCredentialResponse = &CredentialResponse{
+	AccessKeyID:     accessKey,
+	Expiration:      expirationTimeString,
+	RoleArn:         roleARN,
+	SecretAccessKey: secretKey,
+	Token:           sessionToken,
+}
+
+func newAuditLogInTest() (*logrus.Logger, *bytes.Buffer) {
+	buffer := &bytes.Buffer{}
+	auditLog := logrus.New()
+	auditLog.Out = buffer
+	auditLog.Formatter = &logrus.JSONFormatter{}
+	return auditLog, buffer
+}
+
+func TestAuditCredentials(t *testing.T) {
+	app := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "172.17.0.2").Get()
+	db := testingutils.BaseDockerContainer("db", "c3a7").WithNetwork("bridge", "172.17.0.4").Get()
+
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{app, db}, nil)
+
+	auditLog, buffer := newAuditLogInTest()
+	credsService := &CredentialService{
+		dockerClient: dockerMock,
+		auditLog:     auditLog,
+	}
+
+	request := httptest.NewRequest("GET", "/role/"+roleName+"?profile=dev", nil)
+	request.RemoteAddr = "172.17.0.2:49152"
+	credsService.auditCredentials(request, testCredentialResponse)
+
+	entry := make(map[string]string)
+	err := json.Unmarshal(buffer.Bytes(), &entry)
+	assert.NoError(t, err, "Unexpected error unmarshalling audit log entry")
+	assert.Equal(t, "Vended credentials", entry["msg"], "Expected message to match")
+	assert.Equal(t, "172.17.0.2", entry["caller_ip"], "Expected caller IP to match")
+	assert.Equal(t, "c1a7", entry["container_id"], "Expected container ID to match")
+	assert.Equal(t, "app", entry["container_name"], "Expected container name to match")
+	assert.Equal(t, roleARN, entry["role_arn"], "Expected role ARN to match")
+	assert.Equal(t, accessKey, entry["access_key_id"], "Expected access key ID to match")
+	assert.Equal(t, expirationTimeString, entry["expiration"], "Expected expiration to match")
+	assert.Equal(t, "dev", entry["profile"], "Expected profile to match")
+	assert.NotContains(t, buffer.String(), secretKey, "Expected the secret key to not be logged")
+	assert.NotContains(t, buffer.String(), sessionToken, "Expected the session token to not be logged")
+}
+
+func TestAuditCredentialsUnknownCaller(t *testing.T) {
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return(nil, errors.New("docker is not running"))
+
+	auditLog, buffer := newAuditLogInTest()
+	credsService := &CredentialService{
+		dockerClient: dockerMock,
+		auditLog:     auditLog,
+	}
+
+	request := httptest.NewRequest("GET", "/creds", nil)
+	request.RemoteAddr = "172.17.0.1:49152"
+	credsService.auditCredentials(request, testCredentialResponse)
+
+	entry := make(map[string]string)
+	err := json.Unmarshal(buffer.Bytes(), &entry)
+	assert.NoError(t, err, "Unexpected error unmarshalling audit log entry")
+	assert.Equal(t, "172.17.0.1", entry["caller_ip"], "Expected caller IP to match")
+	_, ok := entry["container_id"]
+	assert.False(t, ok, "Expected no container for an unknown caller")
+}
+
+func TestNewAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials-audit")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	os.Setenv(config.AuditLogVar, path)
+	defer os.Unsetenv(config.AuditLogVar)
+
+	auditLog, err := newAuditLog()
+	assert.NoError(t, err, "Unexpected error creating audit log")
+	auditLog.Info("first")
+	auditLog.Info("second")
+
+	contents, err := ioutil.ReadFile(path)
+	assert.NoError(t, err, "Unexpected error reading audit log")
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	assert.Len(t, lines, 2, "Expected one JSON entry per line")
+
+	os.Unsetenv(config.AuditLogVar)
+	auditLog, err = newAuditLog()
+	assert.NoError(t, err, "Unexpected error creating audit log")
+	assert.Equal(t, logrus.StandardLogger(), auditLog, "Expected the standard log by default")
+}
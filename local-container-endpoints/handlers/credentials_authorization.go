@@ -0,0 +1,69 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+)
+
+const authorizationHeader = "Authorization"
+
+// getAuthorizationToken returns the token which credentials requests must present in the Authorization header,
+// or an empty string if it isn't required; the token file takes precedence
+func getAuthorizationToken() (string, error) {
+	path := os.Getenv(config.AuthorizationTokenFileVar)
+	if path == "" {
+		return os.Getenv(config.AuthorizationTokenVar), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read %s", config.AuthorizationTokenFileVar)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("Invalid %s: the file %s is empty", config.AuthorizationTokenFileVar, path)
+	}
+	return token, nil
+}
+
+// withAuthorization wraps a credentials handler so that requests without the authorization token are rejected, if it is required
+func (service *CredentialService) withAuthorization(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return requireAuthorization(service.authorizationToken, handler)
+}
+
+// requireAuthorization wraps a handler so that requests without the token in the Authorization header are rejected,
+// unless the token is empty
+func requireAuthorization(token string, handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if token == "" {
+			return handler(w, r)
+		}
+		presented := r.Header.Get(authorizationHeader)
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return HTTPError{
+				Code: http.StatusUnauthorized,
+				Err:  fmt.Errorf("Missing or invalid '%s' header; set AWS_CONTAINER_AUTHORIZATION_TOKEN in the container to the value of %s", authorizationHeader, config.AuthorizationTokenVar),
+			}
+		}
+		return handler(w, r)
+	}
+}
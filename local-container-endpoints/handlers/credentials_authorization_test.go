@@ -0,0 +1,112 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAuthorizationToken(t *testing.T) {
+	defer os.Unsetenv(config.AuthorizationTokenVar)
+	defer os.Unsetenv(config.AuthorizationTokenFileVar)
+
+	token, err := getAuthorizationToken()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "", token, "Expected no authorization token by default")
+
+	os.Setenv(config.AuthorizationTokenVar, "env-secret")
+	token, err = getAuthorizationToken()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "env-secret", token, "Expected token to match")
+
+	dir, err := ioutil.TempDir("", "authorization-token")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(path, []byte("file-secret\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing token file")
+	os.Setenv(config.AuthorizationTokenFileVar, path)
+
+	token, err = getAuthorizationToken()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "file-secret", token, "Expected the file to take precedence")
+
+	err = ioutil.WriteFile(path, []byte("\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing token file")
+	_, err = getAuthorizationToken()
+	assert.Error(t, err, "Expected error for an empty token file")
+
+	os.Setenv(config.AuthorizationTokenFileVar, filepath.Join(dir, "missing"))
+	_, err = getAuthorizationToken()
+	assert.Error(t, err, "Expected error for a missing token file")
+}
+
+func TestWithAuthorization(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("ok"))
+		return nil
+	}
+
+	var testCases = []struct {
+		name         string
+		token        string
+		header       string
+		expectedCode int
+	}{
+		{
+			name:         "NotRequired",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Valid",
+			token:        "secret",
+			header:       "secret",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Missing",
+			token:        "secret",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Invalid",
+			token:        "secret",
+			header:       "Bearer secret",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			service := &CredentialService{
+				authorizationToken: test.token,
+			}
+			request := httptest.NewRequest(http.MethodGet, config.TempCredentialsPath, nil)
+			if test.header != "" {
+				request.Header.Set(authorizationHeader, test.header)
+			}
+			recorder := httptest.NewRecorder()
+			ServeHTTP(service.withAuthorization(handler))(recorder, request)
+			assert.Equal(t, test.expectedCode, recorder.Code, "Expected status code to match")
+		})
+	}
+}
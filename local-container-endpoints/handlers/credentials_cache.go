@@ -0,0 +1,263 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// credentials are refreshed when less than this much of their lifetime remains,
+	// so that the SDKs in containers never receive credentials that they consider to be expiring
+	maxCredentialsRefreshWindow = 15 * time.Minute
+
+	defaultRoleCacheTTL = time.Hour
+
+	// maxCredentialsCacheEntries limits the number of cached credentials, since the keys come from requests
+	maxCredentialsCacheEntries = 1000
+)
+
+// credentialsCache stores the credentials vended for each role, so that STS
+// is only called again when the credentials are about to expire. Failed requests and expired credentials are
+// removed, and once the cache is full, the credentials which are refreshed soonest are removed.
+type credentialsCache struct {
+	lock    sync.Mutex
+	entries map[string]*credentialsCacheEntry
+	// maxLifetime is the longest lifetime of the credentials returned from the cache, if it is set
+	maxLifetime time.Duration
+}
+
+type credentialsCacheEntry struct {
+	response  *CredentialResponse
+	refreshAt time.Time
+	expiresAt time.Time
+	// fetch is the request for new credentials in progress, if any
+	fetch *credentialsFetch
+}
+
+// credentialsFetch is a request for credentials which concurrent requests for the same key wait for,
+// so that many containers starting at once make a single STS call; done is closed once response and err are set
+type credentialsFetch struct {
+	done     chan struct{}
+	response *CredentialResponse
+	err      error
+}
+
+func newCredentialsCache() *credentialsCache {
+	return &credentialsCache{
+		entries: make(map[string]*credentialsCacheEntry),
+	}
+}
+
+// get returns the cached credentials for the key, or calls fetch if there are none or they need to be refreshed.
+// Requests made while fetch is in progress get its result, including its error.
+// Errors are not cached, and neither are credentials without an expiration.
+func (cache *credentialsCache) get(key string, fetch func() (*CredentialResponse, error)) (*CredentialResponse, error) {
+	cache.lock.Lock()
+	entry, ok := cache.entries[key]
+	if !ok {
+		entry = &credentialsCacheEntry{}
+		cache.entries[key] = entry
+	}
+	now := time.Now()
+	hit := entry.response != nil && now.Before(entry.refreshAt)
+	if hit {
+		response := entry.response
+		cache.lock.Unlock()
+		metrics.CacheLookup(metrics.CredentialsCache, true)
+		return response, nil
+	}
+	if call := entry.fetch; call != nil {
+		cache.lock.Unlock()
+		metrics.CacheSharedFetch(metrics.CredentialsCache)
+		<-call.done
+		return call.response, call.err
+	}
+	call := &credentialsFetch{
+		done: make(chan struct{}),
+	}
+	entry.fetch = call
+	cache.lock.Unlock()
+	metrics.CacheLookup(metrics.CredentialsCache, false)
+
+	cache.runFetch(key, entry, call, fetch, now)
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.response, nil
+}
+
+// runFetch sets the result of the call, caches the credentials, and then releases the requests waiting for the call.
+// A panic in fetch becomes the error of the call, so that later requests for the key do not wait for it forever.
+func (cache *credentialsCache) runFetch(key string, entry *credentialsCacheEntry, call *credentialsFetch, fetch func() (*CredentialResponse, error), now time.Time) {
+	defer close(call.done)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logrus.Errorf("Recovered from a panic while fetching credentials: %v\n%s", recovered, debug.Stack())
+			call.response = nil
+			call.err = errors.New("Internal error while fetching credentials")
+		}
+
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		entry.fetch = nil
+		entry.response = nil
+		if call.err == nil {
+			expiration, err := time.Parse(CredentialExpirationTimeFormat, call.response.Expiration)
+			if err == nil && expiration.After(now) {
+				entry.response = call.response
+				entry.refreshAt = expiration.Add(-getRefreshWindow(expiration.Sub(now)))
+				entry.expiresAt = expiration
+			}
+		}
+		if entry.response == nil && cache.entries[key] == entry {
+			delete(cache.entries, key)
+		}
+		cache.removeExpired(time.Now())
+	}()
+
+	call.response, call.err = fetch()
+	if call.err == nil {
+		call.response = cache.limitLifetime(call.response, now)
+	}
+}
+
+// removeExpired removes the credentials which have expired, and then the credentials which are refreshed soonest
+// until the cache is within its size; entries with a request in progress are kept, since requests wait for them
+func (cache *credentialsCache) removeExpired(now time.Time) {
+	for key, entry := range cache.entries {
+		if entry.fetch == nil && !now.Before(entry.expiresAt) {
+			delete(cache.entries, key)
+		}
+	}
+	for len(cache.entries) > maxCredentialsCacheEntries {
+		var oldestKey string
+		var oldest *credentialsCacheEntry
+		for key, entry := range cache.entries {
+			if entry.fetch == nil && (oldest == nil || entry.refreshAt.Before(oldest.refreshAt)) {
+				oldestKey, oldest = key, entry
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(cache.entries, oldestKey)
+	}
+}
+
+// limitLifetime shortens the expiration of the credentials to the max lifetime of the cache.
+// Since the cached credentials are refreshed before then, new credentials are fetched,
+// and containers must refresh their credentials at least as often.
+func (cache *credentialsCache) limitLifetime(response *CredentialResponse, now time.Time) *CredentialResponse {
+	if cache == nil || cache.maxLifetime <= 0 {
+		return response
+	}
+	limit := now.Add(cache.maxLifetime)
+	expiration, err := time.Parse(CredentialExpirationTimeFormat, response.Expiration)
+	if err == nil && expiration.Before(limit) {
+		return response
+	}
+	limited := *response
+	limited.Expiration = limit.Format(CredentialExpirationTimeFormat)
+	return &limited
+}
+
+// Short lived credentials are refreshed once half of their lifetime has passed
+func getRefreshWindow(lifetime time.Duration) time.Duration {
+	if lifetime/2 < maxCredentialsRefreshWindow {
+		return lifetime / 2
+	}
+	return maxCredentialsRefreshWindow
+}
+
+// roleCache stores the roles obtained from iam:GetRole for each role name
+type roleCache struct {
+	lock    sync.Mutex
+	entries map[string]roleCacheEntry
+	// ttl is how long the roles are cached; they are not cached if it is 0
+	ttl time.Duration
+	now func() time.Time
+}
+
+type roleCacheEntry struct {
+	role      *iam.Role
+	expiresAt time.Time
+}
+
+func newRoleCache() *roleCache {
+	return &roleCache{
+		entries: make(map[string]roleCacheEntry),
+		ttl:     defaultRoleCacheTTL,
+		now:     time.Now,
+	}
+}
+
+// get returns the cached role, or calls lookup if there is none or it has expired.
+// If lookup fails, an expired role is returned instead, so that credentials can still be refreshed while IAM throttles requests.
+func (cache *roleCache) get(roleName string, lookup func() (*iam.Role, error)) (*iam.Role, error) {
+	if cache == nil {
+		return lookup()
+	}
+
+	cache.lock.Lock()
+	entry, ok := cache.entries[roleName]
+	cache.lock.Unlock()
+	hit := ok && cache.now().Before(entry.expiresAt)
+	metrics.CacheLookup(metrics.RoleCache, hit)
+	if hit {
+		return entry.role, nil
+	}
+
+	role, err := lookup()
+	if err != nil {
+		if ok {
+			logrus.Warnf("Using the expired cached role %s: %v", roleName, err)
+			return entry.role, nil
+		}
+		return nil, err
+	}
+
+	if cache.ttl > 0 {
+		cache.lock.Lock()
+		cache.entries[roleName] = roleCacheEntry{
+			role:      role,
+			expiresAt: cache.now().Add(cache.ttl),
+		}
+		cache.lock.Unlock()
+	}
+	return role, nil
+}
+
+// getRoleCacheTTL returns how long roles are cached; 0 disables the cache
+func getRoleCacheTTL() (time.Duration, error) {
+	value := os.Getenv(config.RoleCacheTTLVar)
+	if value == "" {
+		return defaultRoleCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("Invalid %s: %s is not a duration, such as 30m, or 0 to disable the cache", config.RoleCacheTTLVar, value)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleCache(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newRoleCache()
+	cache.now = func() time.Time {
+		return now
+	}
+
+	lookups := 0
+	lookup := func() (*iam.Role, error) {
+		lookups++
+		return &iam.Role{Arn: aws.String(roleARN)}, nil
+	}
+
+	role, err := cache.get(roleName, lookup)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, roleARN, aws.StringValue(role.Arn), "Expected role ARN to match")
+	role, err = cache.get(roleName, lookup)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, roleARN, aws.StringValue(role.Arn), "Expected role ARN to match")
+	assert.Equal(t, 1, lookups, "Expected the role to be cached")
+
+	now = now.Add(defaultRoleCacheTTL)
+	_, err = cache.get(roleName, lookup)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2, lookups, "Expected the role to be looked up again once it expires")
+
+	// an expired role is used if the lookup fails
+	now = now.Add(defaultRoleCacheTTL)
+	role, err = cache.get(roleName, func() (*iam.Role, error) {
+		return nil, fmt.Errorf("Throttling: Rate exceeded")
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, roleARN, aws.StringValue(role.Arn), "Expected the expired role")
+
+	_, err = cache.get("other-role", func() (*iam.Role, error) {
+		return nil, fmt.Errorf("Throttling: Rate exceeded")
+	})
+	assert.Error(t, err, "Expected error for a role which is not cached")
+}
+
+func TestRoleCacheDisabled(t *testing.T) {
+	cache := newRoleCache()
+	cache.ttl = 0
+
+	lookups := 0
+	lookup := func() (*iam.Role, error) {
+		lookups++
+		return &iam.Role{Arn: aws.String(roleARN)}, nil
+	}
+
+	cache.get(roleName, lookup)
+	cache.get(roleName, lookup)
+	assert.Equal(t, 2, lookups, "Expected the role not to be cached")
+}
+
+func TestGetRoleCacheTTL(t *testing.T) {
+	defer os.Unsetenv(config.RoleCacheTTLVar)
+
+	os.Unsetenv(config.RoleCacheTTLVar)
+	ttl, err := getRoleCacheTTL()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, defaultRoleCacheTTL, ttl, "Expected the default TTL")
+
+	os.Setenv(config.RoleCacheTTLVar, "30m")
+	ttl, err = getRoleCacheTTL()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 30*time.Minute, ttl, "Expected TTL to match")
+
+	os.Setenv(config.RoleCacheTTLVar, "0")
+	ttl, err = getRoleCacheTTL()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, time.Duration(0), ttl, "Expected the cache to be disabled")
+
+	os.Setenv(config.RoleCacheTTLVar, "-1m")
+	_, err = getRoleCacheTTL()
+	assert.Error(t, err, "Expected error for a negative TTL")
+}
+
+func TestGetRoleCredentialsRoleCached(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.roleCache = newRoleCache()
+
+	expiration := time.Now().Add(time.Hour)
+
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn: aws.String(roleARN),
+		},
+	}, nil).Times(1)
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+		input := x.(*sts.AssumeRoleInput)
+		assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+	}).Return(&sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil).Times(2)
+
+	// credentials with different durations are cached separately, but the role is shared
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{durationSeconds: 900})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	_, err = credsService.getRoleCredentials(roleName, &assumeRoleOptions{durationSeconds: 3600})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+}
+
+func TestGetRoleCredentialsConcurrentRequests(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration := time.Now().Add(time.Hour)
+	release := make(chan struct{})
+
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn: aws.String(roleARN),
+		},
+	}, nil).Times(1)
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+		<-release
+	}).Return(&sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil).Times(1)
+
+	// containers starting at once request the same role while the first request is in progress
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
+			assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+			assert.Equal(t, accessKey, response.AccessKeyID, "Expected access key to match")
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestCredentialsCacheConcurrentErrors(t *testing.T) {
+	cache := newCredentialsCache()
+
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func() (*CredentialResponse, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return nil, fmt.Errorf("Throttling: Rate exceeded")
+	}
+
+	var started, wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		started.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			_, err := cache.get("role", fetch)
+			assert.Error(t, err, "Expected the error of the request in progress")
+		}()
+	}
+	started.Wait()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "Expected a single request for concurrent requests")
+
+	// errors are not cached
+	cache.get("role", fetch)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "Expected the credentials to be requested again after an error")
+}
+
+func TestCredentialsCachePanic(t *testing.T) {
+	cache := newCredentialsCache()
+
+	release := make(chan struct{})
+	errs := make(chan error, 2)
+	go func() {
+		_, err := cache.get("role", func() (*CredentialResponse, error) {
+			<-release
+			panic("assignment to entry in nil map")
+		})
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		_, err := cache.get("role", func() (*CredentialResponse, error) {
+			return nil, fmt.Errorf("Expected to wait for the request in progress")
+		})
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		err := <-errs
+		assert.Error(t, err, "Expected the panic to be returned as an error")
+		assert.Contains(t, err.Error(), "Internal error while fetching credentials", "Expected the error of the panic")
+	}
+
+	// the failed request is no longer in progress
+	response, err := cache.get("role", func() (*CredentialResponse, error) {
+		return &CredentialResponse{AccessKeyID: accessKey}, nil
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, accessKey, response.AccessKeyID, "Expected access key to match")
+}
+
+func TestCredentialsCacheEviction(t *testing.T) {
+	cache := newCredentialsCache()
+	// each fetch returns credentials which expire a minute after the previous ones
+	var fetches int
+	fetchCredentials := func() (*CredentialResponse, error) {
+		fetches++
+		return &CredentialResponse{
+			AccessKeyID: accessKey,
+			Expiration:  time.Now().Add(time.Hour + time.Duration(fetches)*time.Minute).Format(CredentialExpirationTimeFormat),
+		}, nil
+	}
+
+	// failed requests are not kept, so that requests for roles which don't exist don't grow the cache
+	cache.get("missing-role", func() (*CredentialResponse, error) {
+		return nil, fmt.Errorf("AccessDenied")
+	})
+	assert.Empty(t, cache.entries, "Expected no entry for a failed request")
+
+	_, err := cache.get("role", fetchCredentials)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, cache.entries, 1, "Expected the credentials to be cached")
+
+	// expired credentials are removed once other credentials are fetched
+	cache.entries["role"].expiresAt = time.Now().Add(-time.Minute)
+	_, err = cache.get("other-role", fetchCredentials)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, cache.entries, 1, "Expected the expired credentials to be removed")
+	assert.NotNil(t, cache.entries["other-role"], "Expected the new credentials to be cached")
+
+	// once the cache is full, the credentials which are refreshed soonest are removed
+	for i := 0; i < maxCredentialsCacheEntries+10; i++ {
+		_, err := cache.get(fmt.Sprintf("role-%d", i), fetchCredentials)
+		assert.NoError(t, err, "Unexpected error")
+	}
+	assert.Len(t, cache.entries, maxCredentialsCacheEntries, "Expected the cache to be limited")
+	assert.NotNil(t, cache.entries[fmt.Sprintf("role-%d", maxCredentialsCacheEntries+9)], "Expected the latest credentials to be kept")
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	validationErrorCode   = "ValidationError"
+	accessDeniedErrorCode = "AccessDenied"
+	// serviceLinkedRolePath is the IAM path of service-linked roles, in their ARNs
+	serviceLinkedRolePath = ":role/aws-service-role/"
+)
+
+// limitDuration returns options with the duration limited to the MaxSessionDuration of the role, since sts:AssumeRole fails otherwise
+func limitDuration(role *iam.Role, opts *assumeRoleOptions) *assumeRoleOptions {
+	maxDuration := aws.Int64Value(role.MaxSessionDuration)
+	duration := credentialsDurationOrDefault(opts.durationSeconds)
+	if maxDuration == 0 || duration <= maxDuration {
+		return opts
+	}
+	logrus.Warnf("The requested duration of %d seconds exceeds the MaxSessionDuration of %s; credentials are vended for %d seconds instead", duration, aws.StringValue(role.RoleName), maxDuration)
+	limited := *opts
+	limited.durationSeconds = maxDuration
+	return &limited
+}
+
+// getAssumeRoleError explains the sts:AssumeRole errors for durations which are too long for the role,
+// which can happen for roles requested by ARN, since their MaxSessionDuration is not known, and for service-linked
+// roles, which only trust their AWS service.
+func getAssumeRoleError(err error, roleARN string, opts *assumeRoleOptions) error {
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == accessDeniedErrorCode && strings.Contains(roleARN, serviceLinkedRolePath) {
+		return HTTPError{
+			Code: http.StatusForbidden,
+			Err:  fmt.Errorf("Credentials for %s can't be vended: %s. It is a service-linked role, which can only be assumed by its AWS service", roleARN, awsErr.Message()),
+		}
+	}
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == validationErrorCode && strings.Contains(awsErr.Message(), "DurationSeconds exceeds") {
+		return HTTPError{
+			Code: http.StatusBadRequest,
+			Err: fmt.Errorf("Credentials for %s can't be vended for %d seconds: %s. Request a shorter duration with the '%s' query parameter or %s, or increase the MaxSessionDuration of the role",
+				roleARN, credentialsDurationOrDefault(opts.durationSeconds), awsErr.Message(), durationQueryParameter, config.CredentialsDurationVar),
+		}
+	}
+	return err
+}
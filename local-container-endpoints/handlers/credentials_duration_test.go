@@ -0,0 +1,99 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitDuration(t *testing.T) {
+	role := &iam.Role{
+		RoleName:           aws.String(roleName),
+		MaxSessionDuration: aws.Int64(7200),
+	}
+
+	opts := &assumeRoleOptions{durationSeconds: 3600}
+	assert.Equal(t, opts, limitDuration(role, opts), "Expected a shorter duration to be unchanged")
+
+	opts = &assumeRoleOptions{durationSeconds: 43200}
+	limited := limitDuration(role, opts)
+	assert.Equal(t, int64(7200), limited.durationSeconds, "Expected the duration to be limited")
+	assert.Equal(t, int64(43200), opts.durationSeconds, "Expected the original options to be unchanged")
+
+	opts = &assumeRoleOptions{durationSeconds: 43200}
+	assert.Equal(t, opts, limitDuration(&iam.Role{}, opts), "Expected no limit without a MaxSessionDuration")
+}
+
+func TestGetAssumeRoleError(t *testing.T) {
+	err := getAssumeRoleError(awserr.New(validationErrorCode, "The requested DurationSeconds exceeds the MaxSessionDuration set for this role.", nil), roleARN, &assumeRoleOptions{durationSeconds: 43200})
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTP error")
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code, "Expected bad request")
+	assert.Contains(t, httpErr.Error(), "43200 seconds", "Expected the error to include the duration")
+
+	other := awserr.New("AccessDenied", "Not authorized to perform sts:AssumeRole", nil)
+	assert.Equal(t, other, getAssumeRoleError(other, roleARN, &assumeRoleOptions{}), "Expected other errors to be unchanged")
+
+	serviceLinkedRoleARN := "arn:aws:iam::111111111111:role/aws-service-role/ecs.amazonaws.com/AWSServiceRoleForECS"
+	err = getAssumeRoleError(other, serviceLinkedRoleARN, &assumeRoleOptions{})
+	httpErr, ok = err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTP error")
+	assert.Equal(t, http.StatusForbidden, httpErr.Code, "Expected forbidden")
+	assert.Contains(t, httpErr.Error(), "service-linked role", "Expected the error to explain service-linked roles")
+
+	plain := fmt.Errorf("Some API Error")
+	assert.Equal(t, plain, getAssumeRoleError(plain, roleARN, &assumeRoleOptions{}), "Expected other errors to be unchanged")
+}
+
+func TestGetRoleCredentialsDurationLimited(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration := time.Now().Add(time.Hour)
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn:                aws.String(roleARN),
+				RoleName:           aws.String(roleName),
+				MaxSessionDuration: aws.Int64(3600),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, int64(3600), aws.Int64Value(input.DurationSeconds), "Expected the duration to be limited")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{durationSeconds: 43200})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+}
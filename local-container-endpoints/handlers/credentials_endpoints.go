@@ -0,0 +1,67 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// Custom endpoints, for example to use LocalStack or moto instead of AWS; the names match the AWS SDKs and CLI
+const (
+	endpointURLVar    = "AWS_ENDPOINT_URL"
+	iamEndpointURLVar = "AWS_ENDPOINT_URL_IAM"
+	stsEndpointURLVar = "AWS_ENDPOINT_URL_STS"
+	ecsEndpointURLVar = "AWS_ENDPOINT_URL_ECS"
+	ssmEndpointURLVar = "AWS_ENDPOINT_URL_SSM"
+	// secretsManagerEndpointURLVar is named after the service ID of Secrets Manager, as the AWS SDKs expect
+	secretsManagerEndpointURLVar = "AWS_ENDPOINT_URL_SECRETS_MANAGER"
+)
+
+// getEndpointURL returns the custom endpoint for the service whose environment variable is given;
+// the service specific variable takes precedence over AWS_ENDPOINT_URL. An empty string means the AWS endpoint is used.
+func getEndpointURL(serviceVar string) string {
+	if endpoint := os.Getenv(serviceVar); endpoint != "" {
+		return endpoint
+	}
+	return os.Getenv(endpointURLVar)
+}
+
+// getSTSEndpoint returns the endpoint for STS requests in the region; a custom endpoint takes precedence over
+// the regional endpoint. An empty string means the endpoint is resolved by the SDK.
+func getSTSEndpoint(region string, regional bool) string {
+	if endpoint := getEndpointURL(stsEndpointURLVar); endpoint != "" {
+		return endpoint
+	}
+	if regional && region != "" {
+		return getRegionalSTSEndpoint(region)
+	}
+	return ""
+}
+
+// validateEndpointURLs returns an error if any of the custom endpoints is not an http or https URL
+func validateEndpointURLs() error {
+	for _, envVar := range []string{endpointURLVar, iamEndpointURLVar, stsEndpointURLVar, ecsEndpointURLVar, ssmEndpointURLVar, secretsManagerEndpointURLVar} {
+		value := os.Getenv(envVar)
+		if value == "" {
+			continue
+		}
+		endpoint, err := url.Parse(value)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("Invalid %s: %s is not an http or https URL", envVar, value)
+		}
+	}
+	return nil
+}
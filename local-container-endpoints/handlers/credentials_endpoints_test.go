@@ -0,0 +1,70 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func unsetEndpointURLs() {
+	os.Unsetenv(endpointURLVar)
+	os.Unsetenv(iamEndpointURLVar)
+	os.Unsetenv(stsEndpointURLVar)
+}
+
+func TestGetEndpointURL(t *testing.T) {
+	unsetEndpointURLs()
+	defer unsetEndpointURLs()
+
+	assert.Equal(t, "", getEndpointURL(iamEndpointURLVar), "Expected no custom endpoint by default")
+
+	os.Setenv(endpointURLVar, "http://localstack:4566")
+	assert.Equal(t, "http://localstack:4566", getEndpointURL(iamEndpointURLVar), "Expected the global custom endpoint")
+
+	os.Setenv(iamEndpointURLVar, "http://moto:5000")
+	assert.Equal(t, "http://moto:5000", getEndpointURL(iamEndpointURLVar), "Expected the service endpoint to take precedence")
+	assert.Equal(t, "http://localstack:4566", getEndpointURL(stsEndpointURLVar), "Expected the global custom endpoint for STS")
+}
+
+func TestGetSTSEndpoint(t *testing.T) {
+	unsetEndpointURLs()
+	defer unsetEndpointURLs()
+
+	assert.Equal(t, "", getSTSEndpoint("us-west-2", false), "Expected the SDK to resolve the endpoint")
+	assert.Equal(t, "https://sts.us-west-2.amazonaws.com", getSTSEndpoint("us-west-2", true), "Expected the regional endpoint")
+	assert.Equal(t, "", getSTSEndpoint("", true), "Expected the SDK to resolve the endpoint without a region")
+
+	os.Setenv(stsEndpointURLVar, "http://localstack:4566")
+	assert.Equal(t, "http://localstack:4566", getSTSEndpoint("us-west-2", false), "Expected the custom endpoint")
+	assert.Equal(t, "http://localstack:4566", getSTSEndpoint("us-west-2", true), "Expected the custom endpoint to take precedence over the regional endpoint")
+}
+
+func TestValidateEndpointURLs(t *testing.T) {
+	unsetEndpointURLs()
+	defer unsetEndpointURLs()
+
+	assert.NoError(t, validateEndpointURLs(), "Unexpected error without custom endpoints")
+
+	os.Setenv(endpointURLVar, "http://localhost:4566")
+	os.Setenv(stsEndpointURLVar, "https://sts.example.com")
+	assert.NoError(t, validateEndpointURLs(), "Unexpected error for valid endpoints")
+
+	for _, value := range []string{"localhost:4566", "ftp://localhost", "http://", "://bad"} {
+		os.Setenv(iamEndpointURLVar, value)
+		assert.Error(t, validateEndpointURLs(), "Expected error for %s", value)
+	}
+}
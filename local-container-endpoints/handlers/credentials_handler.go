@@ -14,19 +14,39 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/retry"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sessiontags"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/useragent"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metrics"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/docker/docker/api/types"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -34,7 +54,16 @@ import (
 
 const (
 	temporaryCredentialsDurationInS = 3600
+	minCredentialsDurationInS       = 900
 	roleSessionNameLength           = 64
+	externalIDQueryParameter        = "externalId"
+	mfaSerialQueryParameter         = "mfaSerial"
+	mfaTokenQueryParameter          = "mfaToken"
+	durationQueryParameter          = "duration"
+	sessionTagsQueryParameter       = "tags"
+	sessionPolicyQueryParameter     = "policy"
+	profileQueryParameter           = "profile"
+	taskRoleLabel                   = "ecs-local.task-role"
 )
 
 const (
@@ -42,44 +71,425 @@ const (
 	CredentialExpirationTimeFormat = time.RFC3339
 )
 
+// assumeRoleOptions are the optional sts:AssumeRole parameters that can be set for each request
+type assumeRoleOptions struct {
+	externalID      string
+	mfaSerial       string
+	mfaToken        string
+	durationSeconds int64
+	sessionTags     map[string]string
+	sessionPolicy   string
+	// region is the region of the STS endpoint used to assume the role; empty means the session's region
+	region string
+	// accountID is the account of roles requested by name, which are then assumed without iam:GetRole; empty means
+	// the account of the credentials
+	accountID string
+	// container is the name of the container which made the request; it is only set if the session name template uses it
+	container string
+	// ctx is the context of the request, which the spans of the AWS calls made for it are children of
+	ctx context.Context
+}
+
+// cacheKey identifies the credentials for the role with these options.
+// The MFA token is left out, since it can only be used once; cached credentials are reused instead.
+func (opts *assumeRoleOptions) cacheKey(role string) string {
+	var tags []string
+	for key, value := range opts.sessionTags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+	return fmt.Sprintf("%s|%s|%s|%d|%s|%s|%s|%s", role, opts.externalID, opts.mfaSerial, credentialsDurationOrDefault(opts.durationSeconds), strings.Join(tags, ","), opts.sessionPolicy, opts.region, opts.container)
+}
+
 // CredentialService vends credentials to containers
 type CredentialService struct {
 	iamClient      iamiface.IAMAPI
 	stsClient      stsiface.STSAPI
+	dockerClient   docker.Client
 	currentSession *session.Session
+	cache          *credentialsCache
+	roleCache      *roleCache
+	// sessionNameTemplate is the template for role session names; the user in it is looked up once
+	sessionNameTemplate string
+	sessionUser         string
+	sessionUserLock     sync.Mutex
+	// services holds the settings from the config file for each Docker Compose service
+	services map[string]configfile.Service
+
+	// profileServices vend credentials sourced from the profiles requested with the profile query parameter
+	profileServices   map[string]*CredentialService
+	profileLock       sync.Mutex
+	newProfileClients func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error)
+	// newRoleSTSClient creates STS clients with the credentials of roles, for the whoami path
+	newRoleSTSClient func(sess *session.Session, creds *credentials.Credentials, region string) stsiface.STSAPI
+	// roles restricts which roles can be assumed
+	roles *roleFilter
+	// settingsLock guards services and roles, which are replaced when the config is reloaded
+	settingsLock sync.RWMutex
+	// tokens are the session tokens issued by the token path; they must be presented on credentials requests if requireToken is set
+	tokens       *tokenStore
+	requireToken bool
+	// requireIMDSToken requires session tokens on the instance metadata paths only, as in IMDSv2
+	requireIMDSToken bool
+	// authorizationToken must be presented in the Authorization header of credentials requests; it is empty if it isn't required
+	authorizationToken string
+	// auditLog records each request for which credentials were vended
+	auditLog *logrus.Logger
+	// rateLimiter limits the credentials requests of each client; it is nil if there is no limit
+	rateLimiter *rateLimiter
+	// tokenRateLimiter limits the session tokens which each client requests from the token path
+	tokenRateLimiter *rateLimiter
+	// oidcIssuer signs identity tokens for web identity testing; it is nil unless the OIDC provider is enabled
+	oidcIssuer *oidcIssuer
+	// reloaded replaces this service's clients once the AWS shared config files change
+	reloaded *CredentialService
+
+	// stsClients are used for requests which override the region
+	stsClients     map[string]stsiface.STSAPI
+	stsClientsLock sync.Mutex
+	// maxRetries is the number of times throttled requests of the region's STS clients are retried
+	maxRetries int
+}
+
+// Clients replace the clients which the services create from the environment; any of them can be nil.
+// IAM and STS must be given together, and are used for every profile.
+type Clients struct {
+	IAM    iamiface.IAMAPI
+	STS    stsiface.STSAPI
+	Docker docker.Client
 }
 
 // NewCredentialService returns a struct that handles credentials requests
-func NewCredentialService() (*CredentialService, error) {
-	sess, err := session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	})
+// services are the settings for each Docker Compose service from the config file
+func NewCredentialService(services map[string]configfile.Service) (*CredentialService, error) {
+	return NewCustomCredentialService(services, Clients{})
+}
+
+// NewCustomCredentialService returns a struct that handles credentials requests with the given clients,
+// and the settings from the environment
+func NewCustomCredentialService(services map[string]configfile.Service, clients Clients) (*CredentialService, error) {
+	if (clients.IAM == nil) != (clients.STS == nil) {
+		return nil, fmt.Errorf("The IAM and STS clients must be given together")
+	}
+	if err := validateServices(services); err != nil {
+		return nil, err
+	}
+	if err := validateEndpointURLs(); err != nil {
+		return nil, err
+	}
+
+	// the same STS client is used for every profile and role when it is given, or for mock and static credentials
+	profileClients := newProfileClients
+	sharedSTSClient := clients.STS != nil
+	if sharedSTSClient {
+		profileClients = func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+			return clients.IAM, clients.STS, nil, nil
+		}
+	} else {
+		offlineSTSClient, accountID, err := newOfflineSTSClient()
+		if err != nil {
+			return nil, err
+		}
+		if offlineSTSClient != nil {
+			profileClients = newOfflineProfileClients(offlineSTSClient, accountID)
+			sharedSTSClient = true
+		}
+	}
+
+	iamClient, stsClient, sess, err := profileClients("")
 	if err != nil {
 		return nil, err
 	}
-	iamClient := iam.New(sess)
-	iamClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
-	stsClient := sts.New(sess)
-	stsClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
-	return NewCredentialServiceWithClients(iamClient, stsClient, sess), nil
+	dockerClient := clients.Docker
+	if dockerClient == nil {
+		dockerClient, err = docker.NewDockerClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+	roles, err := newRoleFilter()
+	if err != nil {
+		return nil, err
+	}
+	auditLog, err := newAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	requireToken, err := isTokenRequired()
+	if err != nil {
+		return nil, err
+	}
+	requireIMDSToken, err := isIMDSTokenRequired()
+	if err != nil {
+		return nil, err
+	}
+	authorizationToken, err := getAuthorizationToken()
+	if err != nil {
+		return nil, err
+	}
+	rotationInterval, err := getRotationInterval()
+	if err != nil {
+		return nil, err
+	}
+	roleCacheTTL, err := getRoleCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+	sessionNameTemplate, err := getSessionNameTemplate()
+	if err != nil {
+		return nil, err
+	}
+	maxRetries, err := getMaxRetries()
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter, err := getRateLimiter()
+	if err != nil {
+		return nil, err
+	}
+	oidcIssuer, err := newOIDCIssuer()
+	if err != nil {
+		return nil, err
+	}
+	credentialService := NewCredentialServiceWithClients(iamClient, stsClient, dockerClient, sess)
+	credentialService.services = services
+	credentialService.newProfileClients = profileClients
+	if sharedSTSClient {
+		credentialService.newRoleSTSClient = func(sess *session.Session, creds *credentials.Credentials, region string) stsiface.STSAPI {
+			return stsClient
+		}
+	}
+	credentialService.roles = roles
+	credentialService.auditLog = auditLog
+	credentialService.requireToken = requireToken
+	credentialService.requireIMDSToken = requireIMDSToken
+	credentialService.authorizationToken = authorizationToken
+	credentialService.cache.maxLifetime = rotationInterval
+	credentialService.roleCache.ttl = roleCacheTTL
+	credentialService.sessionNameTemplate = sessionNameTemplate
+	credentialService.maxRetries = maxRetries
+	credentialService.rateLimiter = rateLimiter
+	if rateLimiter != nil {
+		credentialService.tokenRateLimiter = newRateLimiter(rateLimiter.rate, int(rateLimiter.burst))
+	}
+	credentialService.oidcIssuer = oidcIssuer
+	if rotationInterval > 0 {
+		logrus.Infof("Rotation mode is enabled; credentials expire after at most %s", rotationInterval)
+	}
+	if requireToken {
+		logrus.Infof("Credentials requests require a session token from %s", config.TokenPath)
+	}
+	if authorizationToken != "" {
+		logrus.Info("Credentials requests require the authorization token in the Authorization header")
+	}
+	if oidcIssuer != nil {
+		logrus.Infof("The OIDC provider for %s signs tokens at %s with key %s", oidcIssuer.issuer, config.OIDCTokenPath, oidcIssuer.keyID)
+	}
+	if rateLimiter != nil {
+		logrus.Infof("Credentials requests are limited to %s per second from each client", strconv.FormatFloat(rateLimiter.rate, 'f', -1, 64))
+	}
+	for _, credsRole := range credentialService.getConfigFileRoles() {
+		logrus.Infof("Credentials for %s are available at %s", credsRole.role, getCredentialsRelativeURI(credsRole.role))
+	}
+	return credentialService, nil
 }
 
 // NewCredentialServiceWithClients returns a struct that handles credentials requests with the given clients
-func NewCredentialServiceWithClients(iamClient iamiface.IAMAPI, stsClient stsiface.STSAPI, currentSession *session.Session) *CredentialService {
+func NewCredentialServiceWithClients(iamClient iamiface.IAMAPI, stsClient stsiface.STSAPI, dockerClient docker.Client, currentSession *session.Session) *CredentialService {
 	return &CredentialService{
-		iamClient:      iamClient,
-		stsClient:      stsClient,
-		currentSession: currentSession,
+		iamClient:         iamClient,
+		stsClient:         stsClient,
+		dockerClient:      dockerClient,
+		currentSession:    currentSession,
+		cache:             newCredentialsCache(),
+		roleCache:         newRoleCache(),
+		profileServices:   make(map[string]*CredentialService),
+		newProfileClients: newProfileClients,
+		newRoleSTSClient:  newRoleSTSClient,
+		tokens:            newTokenStore(),
+		tokenRateLimiter:  newRateLimiter(defaultTokenRateLimit, defaultRateLimitBurst),
+	}
+}
+
+// validateServices checks the settings of each Docker Compose service from the config file
+func validateServices(services map[string]configfile.Service) error {
+	for name, serviceConfig := range services {
+		if err := validateService(name, serviceConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateService(name string, serviceConfig configfile.Service) error {
+	if serviceConfig.Duration != 0 && serviceConfig.Duration < minCredentialsDurationInS {
+		return fmt.Errorf("Invalid duration for service %s: the duration must be at least %d seconds, got %d", name, minCredentialsDurationInS, serviceConfig.Duration)
 	}
+	if serviceConfig.Region != "" && !regionPattern.MatchString(serviceConfig.Region) {
+		return fmt.Errorf("Invalid region for service %s: %s is not a region", name, serviceConfig.Region)
+	}
+	return nil
+}
+
+// newProfileClients creates IAM and STS clients with the credentials of the profile;
+// if profileName is empty, the credentials are found the same way as the AWS SDK.
+func newProfileClients(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		// Prompts for the MFA token if the profile has an mfa_serial;
+		// this requires the container to be run interactively
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+	}
+	var sourceCredentials *credentials.Credentials
+	var region string
+	var err error
+	if profileName == "" {
+		sourceCredentials, region, err = newSourceCredentials()
+	} else {
+		sourceCredentials, region, err = newProfileSourceCredentials(profileName)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts.Config.HTTPClient, err = newAWSHTTPClient()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if sourceCredentials != nil {
+		// the SDK can't load some of these profiles, so the shared config is not used
+		opts.SharedConfigState = session.SharedConfigDisable
+		opts.Config.Credentials = sourceCredentials
+		if region != "" {
+			opts.Config.Region = aws.String(region)
+		}
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	iamConfig := &aws.Config{}
+	if endpoint := getEndpointURL(iamEndpointURLVar); endpoint != "" {
+		iamConfig.Endpoint = aws.String(endpoint)
+	}
+	iamClient := iam.New(sess, iamConfig)
+	iamClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	stsConfig := &aws.Config{}
+	if endpoint := getSTSEndpoint(aws.StringValue(sess.Config.Region), useRegionalSTSEndpointsForProfile(profileName)); endpoint != "" {
+		stsConfig.Endpoint = aws.String(endpoint)
+	}
+	stsClient := sts.New(sess, stsConfig)
+	stsClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	maxRetries, err := getMaxRetries()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	retryer := retry.NewRetryer(maxRetries)
+	return retry.NewIAMClient(iamClient, retryer), retry.NewSTSClient(stsClient, retryer), sess, nil
+}
+
+// getMaxRetries returns the number of times throttled requests to STS and IAM are retried
+func getMaxRetries() (int, error) {
+	value := os.Getenv(config.MaxRetriesVar)
+	if value == "" {
+		return retry.DefaultMaxRetries, nil
+	}
+	maxRetries, err := strconv.Atoi(value)
+	if err != nil || maxRetries < 0 {
+		return 0, fmt.Errorf("Invalid %s: expected a number of retries, got %s", config.MaxRetriesVar, value)
+	}
+	return maxRetries, nil
+}
+
+// newChildService returns a service with the given clients, which has the same settings as this service
+func (service *CredentialService) newChildService(iamClient iamiface.IAMAPI, stsClient stsiface.STSAPI, sess *session.Session) *CredentialService {
+	child := NewCredentialServiceWithClients(iamClient, stsClient, service.dockerClient, sess)
+	child.services, child.roles = service.getSettings()
+	child.sessionNameTemplate = service.sessionNameTemplate
+	child.maxRetries = service.maxRetries
+	child.cache.maxLifetime = service.cache.maxLifetime
+	if service.roleCache != nil {
+		child.roleCache.ttl = service.roleCache.ttl
+	}
+	if service.newRoleSTSClient != nil {
+		child.newRoleSTSClient = service.newRoleSTSClient
+	}
+	return child
+}
+
+// getProfileService returns the service which vends credentials sourced from the profile in the request's profile query parameter;
+// if the request has no profile, the service itself is returned, or its replacement if the shared config files were reloaded.
+func (service *CredentialService) getProfileService(r *http.Request) (*CredentialService, error) {
+	profileName := r.URL.Query().Get(profileQueryParameter)
+
+	service.profileLock.Lock()
+	defer service.profileLock.Unlock()
+	if profileName == "" {
+		if service.reloaded != nil {
+			return service.reloaded, nil
+		}
+		return service, nil
+	}
+	if profileService, ok := service.profileServices[profileName]; ok {
+		return profileService, nil
+	}
+
+	logrus.Debugf("Creating clients for profile %s", profileName)
+	iamClient, stsClient, sess, err := service.newProfileClients(profileName)
+	if err != nil {
+		return nil, err
+	}
+	profileService := service.newChildService(iamClient, stsClient, sess)
+	if service.profileServices == nil {
+		service.profileServices = make(map[string]*CredentialService)
+	}
+	service.profileServices[profileName] = profileService
+	return profileService, nil
 }
 
 // SetupRoutes sets up the credentials paths in mux
 func (service *CredentialService) SetupRoutes(router *mux.Router) {
-	router.HandleFunc(config.RoleCredentialsPath, ServeHTTP(service.getRoleHandler()))
-	router.HandleFunc(config.RoleCredentialsPathWithSlash, ServeHTTP(service.getRoleHandler()))
+	router.HandleFunc(config.RoleARNCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRoleARNHandler())))))
+	router.HandleFunc(config.RoleARNCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRoleARNHandler())))))
+
+	router.HandleFunc(config.ContainerRoleCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getContainerRoleHandler())))))
+	router.HandleFunc(config.ContainerRoleCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getContainerRoleHandler())))))
+
+	router.HandleFunc(config.RolePathCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRoleHandler())))))
+	router.HandleFunc(config.RolePathCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRoleHandler())))))
+
+	router.HandleFunc(config.RoleCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRoleHandler())))))
+	router.HandleFunc(config.RoleCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRoleHandler())))))
+
+	router.HandleFunc(config.V2CredentialsIDPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getCredentialsIDHandler())))))
+	router.HandleFunc(config.V2CredentialsIDPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getCredentialsIDHandler())))))
+	router.HandleFunc(config.V2CredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getCredentialsIDListHandler())))))
+	router.HandleFunc(config.V2CredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getCredentialsIDListHandler())))))
+
+	router.HandleFunc(config.TempCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getTemporaryCredentialHandler())))))
+	router.HandleFunc(config.TempCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getTemporaryCredentialHandler())))))
+
+	router.HandleFunc(config.RolesPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRolesListHandler())))))
+	router.HandleFunc(config.RolesPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getRolesListHandler())))))
+	router.HandleFunc(config.WhoAmIPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getWhoAmIHandler())))))
+	router.HandleFunc(config.WhoAmIPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getWhoAmIHandler())))))
 
-	router.HandleFunc(config.TempCredentialsPath, ServeHTTP(service.getTemporaryCredentialHandler()))
-	router.HandleFunc(config.TempCredentialsPathWithSlash, ServeHTTP(service.getTemporaryCredentialHandler()))
+	router.HandleFunc(config.IMDSCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withIMDSToken(service.getIMDSRoleListHandler())))))
+	router.HandleFunc(config.IMDSCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withIMDSToken(service.getIMDSRoleListHandler())))))
+	router.HandleFunc(config.IMDSRoleCredentialsPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withIMDSToken(service.getIMDSRoleHandler())))))
+	router.HandleFunc(config.IMDSRoleCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withIMDSToken(service.getIMDSRoleHandler())))))
+
+	router.HandleFunc(config.PodIdentityCredentialsPath, ServeHTTP(service.withRateLimit(service.withPodIdentityToken(service.withAuthorization(service.withToken(service.getPodIdentityHandler()))))))
+	router.HandleFunc(config.PodIdentityCredentialsPathWithSlash, ServeHTTP(service.withRateLimit(service.withPodIdentityToken(service.withAuthorization(service.withToken(service.getPodIdentityHandler()))))))
+
+	router.HandleFunc(config.TokenPath, ServeHTTP(service.withTokenRateLimit(service.withAuthorization(service.getTokenHandler()))))
+
+	if service.oidcIssuer != nil {
+		// the discovery document and keys are public, as they are when they are published at the issuer URL
+		router.HandleFunc(config.OIDCDiscoveryPath, ServeHTTP(service.oidcIssuer.getDiscoveryHandler()))
+		router.HandleFunc(config.OIDCKeysPath, ServeHTTP(service.oidcIssuer.getKeysHandler()))
+		router.HandleFunc(config.OIDCTokenPath, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getOIDCTokenHandler(service.oidcIssuer))))))
+		router.HandleFunc(config.OIDCTokenPathWithSlash, ServeHTTP(service.withRateLimit(service.withAuthorization(service.withToken(service.getOIDCTokenHandler(service.oidcIssuer))))))
+	}
 }
 
 // GetRoleHandler returns the Task IAM Role handler
@@ -88,48 +498,280 @@ func (service *CredentialService) getRoleHandler() func(w http.ResponseWriter, r
 		logrus.Debug("Received role credentials request")
 
 		vars := mux.Vars(r)
-		roleName := vars["role"]
-		if roleName == "" {
+		role, err := unescapeRole(vars["role"])
+		if err != nil {
+			return err
+		}
+		if role == "" {
 			return HTTPError{
 				Code: http.StatusBadRequest,
 				Err:  fmt.Errorf("Invalid URL path %s; expected '/role/<IAM Role Name>'", r.URL.Path),
 			}
 		}
 
-		response, err := service.getRoleCredentials(roleName)
+		opts, err := getAssumeRoleOptions(r)
+		if err != nil {
+			return err
+		}
+		service.setCallerContainer(r, opts, nil)
+
+		profileService, err := service.getProfileService(r)
 		if err != nil {
 			return err
 		}
 
+		// a role ARN which was URL-encoded twice only becomes an ARN once it is unescaped
+		response, err := profileService.getRoleCredentialsByNameOrARN(role, opts)
+		if err != nil {
+			return err
+		}
+
+		service.auditCredentials(r, response)
 		writeJSONResponse(w, response)
 		return nil
 	}
 }
 
-func (service *CredentialService) getRoleCredentials(roleName string) (*CredentialResponse, error) {
-	logrus.Debugf("Requesting credentials for %s", roleName)
+// getRoleARNHandler returns the handler for roles requested by their full ARN
+func (service *CredentialService) getRoleARNHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received role ARN credentials request")
 
-	output, err := service.iamClient.GetRole(&iam.GetRoleInput{
-		RoleName: aws.String(roleName),
-	})
+		vars := mux.Vars(r)
+		roleARN, err := unescapeRole(vars["roleARN"])
+		if err != nil {
+			return err
+		}
+
+		opts, err := getAssumeRoleOptions(r)
+		if err != nil {
+			return err
+		}
+		service.setCallerContainer(r, opts, nil)
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response, err := profileService.getRoleCredentialsByARN(roleARN, opts)
+		if err != nil {
+			return err
+		}
+
+		service.auditCredentials(r, response)
+		writeJSONResponse(w, response)
+		return nil
+	}
+}
+
+// getContainerRoleHandler returns the handler which vends credentials for the role of the calling container,
+// from either its task role label or the config file
+func (service *CredentialService) getContainerRoleHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received container role credentials request")
+
+		container, role, serviceConfig, err := service.getContainerRole(r.Context(), getCallerIP(r))
+		if err != nil {
+			return err
+		}
+
+		opts, err := getAssumeRoleOptions(r)
+		if err != nil {
+			return err
+		}
+		applyServiceConfig(r, opts, serviceConfig)
+		service.setCallerContainer(r, opts, container)
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response, err := profileService.getRoleCredentialsByNameOrARN(role, opts)
+		if err != nil {
+			return err
+		}
+
+		service.auditContainerCredentials(r, response, container)
+		writeJSONResponse(w, response)
+		return nil
+	}
+}
+
+// getContainerRole returns the container which made the request, its role name or ARN, and the config file settings for its service.
+// The task role label takes precedence over the role in the config file.
+func (service *CredentialService) getContainerRole(ctx context.Context, callerIP string) (*types.Container, string, configfile.Service, error) {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return nil, "", configfile.Service{}, errors.Wrap(err, "Failed to list running containers")
+	}
+	container, err := findContainerByCallerIP(containers, callerIP)
 	if err != nil {
+		return nil, "", configfile.Service{}, err
+	}
+
+	var serviceConfig configfile.Service
+	if serviceName := container.Labels[metadata.ComposeServiceLabel]; serviceName != "" {
+		services, _ := service.getSettings()
+		serviceConfig = services[serviceName]
+	}
+
+	role := container.Labels[taskRoleLabel]
+	if role == "" {
+		role = serviceConfig.Role
+	}
+	if role == "" {
+		return nil, "", configfile.Service{}, HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Container %s does not have the '%s' label, and its service has no role in the config file; set either one to the name or ARN of the role for the container", container.ID, taskRoleLabel),
+		}
+	}
+	return container, role, serviceConfig, nil
+}
+
+// applyServiceConfig uses the config file settings for the service when they are not set in the request
+func applyServiceConfig(r *http.Request, opts *assumeRoleOptions, serviceConfig configfile.Service) {
+	if opts.externalID == "" {
+		opts.externalID = serviceConfig.ExternalID
+	}
+	if serviceConfig.Duration != 0 && r.URL.Query().Get(durationQueryParameter) == "" {
+		opts.durationSeconds = serviceConfig.Duration
+	}
+	if opts.region == "" {
+		opts.region = serviceConfig.Region
+	}
+}
+
+// getRoleCredentialsByNameOrARN gets credentials for a role given by either its name or ARN
+func (service *CredentialService) getRoleCredentialsByNameOrARN(role string, opts *assumeRoleOptions) (*CredentialResponse, error) {
+	if strings.HasPrefix(role, "arn:") {
+		return service.getRoleCredentialsByARN(role, opts)
+	}
+	if opts.accountID != "" {
+		return service.getRoleCredentialsByARN(service.getRoleARNInAccount(role, opts.accountID, opts.region), opts)
+	}
+	return service.getRoleCredentials(role, opts)
+}
+
+// getRoleCredentials gets credentials for a role given by its name, which can have an IAM path such as service/my-role
+func (service *CredentialService) getRoleCredentials(rolePath string, opts *assumeRoleOptions) (*CredentialResponse, error) {
+	path, roleName := splitRolePath(rolePath)
+	// the roles are checked before the cache, since the allowed and denied roles can be reloaded
+	_, roles := service.getSettings()
+	if err := roles.checkName(roleName); err != nil {
 		return nil, err
 	}
+	response, err := service.cache.get(opts.cacheKey("role/"+strings.TrimPrefix(path, "/")+roleName), func() (*CredentialResponse, error) {
+		logrus.Debugf("Requesting credentials for %s%s", path, roleName)
 
-	creds, err := service.stsClient.AssumeRole(&sts.AssumeRoleInput{
-		RoleArn:         output.Role.Arn,
-		DurationSeconds: aws.Int64(temporaryCredentialsDurationInS),
-		RoleSessionName: aws.String(utils.Truncate(fmt.Sprintf("ecs-local-%s", roleName), roleSessionNameLength)),
+		role, err := service.roleCache.get(roleName, func() (*iam.Role, error) {
+			ctx, span := tracing.StartRPC(opts.ctx, "aws-api", "IAM", "GetRole")
+			output, err := service.iamClient.GetRoleWithContext(awsContext(ctx), &iam.GetRoleInput{
+				RoleName: aws.String(roleName),
+			})
+			span.End(err)
+			if err != nil {
+				return nil, err
+			}
+			return output.Role, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// IAM role names are unique regardless of their paths, so the path is only checked
+		if path != "" && aws.StringValue(role.Path) != path {
+			return nil, HTTPError{
+				Code: http.StatusNotFound,
+				Err:  fmt.Errorf("Role %s has the path %s, not %s", roleName, aws.StringValue(role.Path), path),
+			}
+		}
+
+		return service.assumeRole(aws.StringValue(role.Arn), roleName, limitDuration(role, opts))
 	})
+	if err != nil {
+		return nil, err
+	}
+	if err := roles.check(response.RoleArn, roleName); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
 
+// getRoleCredentialsByARN assumes the role directly, without calling iam:GetRole,
+// so that roles in other accounts can be used
+func (service *CredentialService) getRoleCredentialsByARN(roleARN string, opts *assumeRoleOptions) (*CredentialResponse, error) {
+	roleName, err := getRoleNameFromARN(roleARN)
 	if err != nil {
+		return nil, HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  err,
+		}
+	}
+
+	if err := service.checkPartition(roleARN, opts.region); err != nil {
+		return nil, err
+	}
+	if err := checkAccountID(roleARN, opts.accountID); err != nil {
+		return nil, err
+	}
+	_, roles := service.getSettings()
+	if err := roles.check(roleARN, roleName); err != nil {
+		return nil, err
+	}
+
+	return service.cache.get(opts.cacheKey(roleARN), func() (*CredentialResponse, error) {
+		logrus.Debugf("Requesting credentials for %s", roleARN)
+		return service.assumeRole(roleARN, roleName, opts)
+	})
+}
+
+func (service *CredentialService) assumeRole(roleARN string, roleName string, opts *assumeRoleOptions) (*CredentialResponse, error) {
+	_, roles := service.getSettings()
+	if err := roles.check(roleARN, roleName); err != nil {
 		return nil, err
 	}
 
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleARN),
+		DurationSeconds: aws.Int64(credentialsDurationOrDefault(opts.durationSeconds)),
+		RoleSessionName: aws.String(service.getSessionName(roleName, opts)),
+	}
+	if opts.externalID != "" {
+		input.ExternalId = aws.String(opts.externalID)
+	}
+	if opts.sessionPolicy != "" {
+		input.Policy = aws.String(opts.sessionPolicy)
+	}
+	if opts.mfaToken != "" {
+		input.SerialNumber = aws.String(opts.mfaSerial)
+		input.TokenCode = aws.String(opts.mfaToken)
+	}
+
+	var requestOptions []request.Option
+	if len(opts.sessionTags) > 0 {
+		requestOptions = append(requestOptions, sessiontags.WithSessionTags(opts.sessionTags))
+	}
+
+	ctx, span := tracing.StartRPC(opts.ctx, "aws-api", "STS", "AssumeRole")
+	span.SetAttribute("aws.role_arn", roleARN)
+	start := time.Now()
+	creds, err := service.getSTSClient(opts.region).AssumeRoleWithContext(awsContext(ctx), input, requestOptions...)
+	metrics.ObserveSTSRequest("AssumeRole", start, err)
+	span.End(err)
+
+	if err != nil {
+		return nil, getAssumeRoleError(err, roleARN, opts)
+	}
+
 	return &CredentialResponse{
 		AccessKeyID:     aws.StringValue(creds.Credentials.AccessKeyId),
 		SecretAccessKey: aws.StringValue(creds.Credentials.SecretAccessKey),
-		RoleArn:         aws.StringValue(output.Role.Arn),
+		RoleArn:         roleARN,
 		Token:           aws.StringValue(creds.Credentials.SessionToken),
 		Expiration:      creds.Credentials.Expiration.Format(CredentialExpirationTimeFormat),
 	}, nil
@@ -140,17 +782,39 @@ func (service *CredentialService) getTemporaryCredentialHandler() func(w http.Re
 	return func(w http.ResponseWriter, r *http.Request) error {
 		logrus.Debug("Received temporary local credentials request")
 
-		response, err := service.getTemporaryCredentials()
+		durationSeconds, err := getCredentialsDuration(r)
 		if err != nil {
 			return err
 		}
 
+		region, err := getRegionParameter(r)
+		if err != nil {
+			return err
+		}
+		if r.URL.Query().Get(accountIDQueryParameter) != "" {
+			return HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("The '%s' query parameter can only be used with roles; the temporary credentials are always in the account of Local Endpoints' credentials", accountIDQueryParameter),
+			}
+		}
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response, err := profileService.getTemporaryCredentials(r.Context(), durationSeconds, region)
+		if err != nil {
+			return err
+		}
+
+		service.auditCredentials(r, response)
 		writeJSONResponse(w, response)
 		return nil
 	}
 }
 
-func (service *CredentialService) getTemporaryCredentials() (*CredentialResponse, error) {
+func (service *CredentialService) getTemporaryCredentials(ctx context.Context, durationSeconds int64, region string) (*CredentialResponse, error) {
 	// check if the current session already was built on temp creds
 	// because temp creds do not have the power to call GetSessionToken
 	if service.isCurrentSessionTemporary() {
@@ -171,26 +835,33 @@ func (service *CredentialService) getTemporaryCredentials() (*CredentialResponse
 		if err == nil {
 			response.Expiration = expiration.Format(CredentialExpirationTimeFormat)
 		}
-		return &response, nil
+		return service.cache.limitLifetime(&response, time.Now()), nil
 	}
 
 	// current session is not temp creds, so we can call GetSessionToken
-	creds, err := service.stsClient.GetSessionToken(&sts.GetSessionTokenInput{
-		DurationSeconds: aws.Int64(temporaryCredentialsDurationInS),
-	})
+	cacheKey := fmt.Sprintf("creds/%d/%s", credentialsDurationOrDefault(durationSeconds), region)
+	return service.cache.get(cacheKey, func() (*CredentialResponse, error) {
+		ctx, span := tracing.StartRPC(ctx, "aws-api", "STS", "GetSessionToken")
+		start := time.Now()
+		creds, err := service.getSTSClient(region).GetSessionTokenWithContext(awsContext(ctx), &sts.GetSessionTokenInput{
+			DurationSeconds: aws.Int64(credentialsDurationOrDefault(durationSeconds)),
+		})
+		metrics.ObserveSTSRequest("GetSessionToken", start, err)
+		span.End(err)
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			return nil, err
+		}
 
-	response := CredentialResponse{
-		AccessKeyID:     aws.StringValue(creds.Credentials.AccessKeyId),
-		SecretAccessKey: aws.StringValue(creds.Credentials.SecretAccessKey),
-		Token:           aws.StringValue(creds.Credentials.SessionToken),
-		Expiration:      creds.Credentials.Expiration.Format(CredentialExpirationTimeFormat),
-	}
+		response := CredentialResponse{
+			AccessKeyID:     aws.StringValue(creds.Credentials.AccessKeyId),
+			SecretAccessKey: aws.StringValue(creds.Credentials.SecretAccessKey),
+			Token:           aws.StringValue(creds.Credentials.SessionToken),
+			Expiration:      creds.Credentials.Expiration.Format(CredentialExpirationTimeFormat),
+		}
 
-	return &response, nil
+		return &response, nil
+	})
 }
 
 func (service *CredentialService) isCurrentSessionTemporary() bool {
@@ -203,3 +874,199 @@ func (service *CredentialService) isCurrentSessionTemporary() bool {
 	}
 	return false
 }
+
+// getAssumeRoleOptions reads the optional AssumeRole parameters from the request's query string
+func getAssumeRoleOptions(r *http.Request) (*assumeRoleOptions, error) {
+	query := r.URL.Query()
+	opts := &assumeRoleOptions{
+		externalID: query.Get(externalIDQueryParameter),
+		mfaSerial:  query.Get(mfaSerialQueryParameter),
+		mfaToken:   query.Get(mfaTokenQueryParameter),
+		ctx:        r.Context(),
+	}
+	// a serial in the query string takes precedence over the environment variable
+	if opts.mfaSerial == "" {
+		opts.mfaSerial = os.Getenv(config.MFASerialVar)
+	}
+
+	if opts.mfaToken != "" && opts.mfaSerial == "" {
+		return nil, HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("An MFA token was provided without an MFA device serial number; set the '%s' query parameter or the %s environment variable", mfaSerialQueryParameter, config.MFASerialVar),
+		}
+	}
+
+	durationSeconds, err := getCredentialsDuration(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.durationSeconds = durationSeconds
+
+	sessionTags, err := getSessionTags(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.sessionTags = sessionTags
+
+	sessionPolicy, err := getSessionPolicy(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.sessionPolicy = sessionPolicy
+
+	region, err := getRegionParameter(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.region = region
+
+	accountID, err := getAccountIDParameter(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.accountID = accountID
+	return opts, nil
+}
+
+// getSessionPolicy returns the inline session policy from the query string, or else the environment variable
+func getSessionPolicy(r *http.Request) (string, error) {
+	if policy := r.URL.Query().Get(sessionPolicyQueryParameter); policy != "" {
+		if !json.Valid([]byte(policy)) {
+			return "", HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("Invalid '%s' query parameter: the session policy must be a JSON IAM policy document", sessionPolicyQueryParameter),
+			}
+		}
+		return policy, nil
+	}
+
+	policy := os.Getenv(config.SessionPolicyVar)
+	if policy != "" && !json.Valid([]byte(policy)) {
+		return "", fmt.Errorf("Invalid value for %s: the session policy must be a JSON IAM policy document", config.SessionPolicyVar)
+	}
+	return policy, nil
+}
+
+// getSessionTags returns the tags from the environment variable, merged with the tags in the query string;
+// tags in the query string take precedence
+func getSessionTags(r *http.Request) (map[string]string, error) {
+	sessionTags := make(map[string]string)
+	if value := os.Getenv(config.SessionTagsVar); value != "" {
+		tags, err := utils.GetTagsMap(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid value for %s", config.SessionTagsVar)
+		}
+		for key, value := range tags {
+			sessionTags[key] = value
+		}
+	}
+
+	if value := r.URL.Query().Get(sessionTagsQueryParameter); value != "" {
+		tags, err := utils.GetTagsMap(value)
+		if err != nil {
+			return nil, HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  errors.Wrapf(err, "Invalid '%s' query parameter", sessionTagsQueryParameter),
+			}
+		}
+		for key, value := range tags {
+			sessionTags[key] = value
+		}
+	}
+
+	if len(sessionTags) == 0 {
+		return nil, nil
+	}
+	return sessionTags, nil
+}
+
+// getCredentialsDuration returns the requested lifetime of the credentials in seconds,
+// from the query string or else the environment variable; 0 means that the default should be used
+func getCredentialsDuration(r *http.Request) (int64, error) {
+	if value := r.URL.Query().Get(durationQueryParameter); value != "" {
+		duration, err := parseCredentialsDuration(value)
+		if err != nil {
+			return 0, HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  errors.Wrapf(err, "Invalid '%s' query parameter", durationQueryParameter),
+			}
+		}
+		return duration, nil
+	}
+
+	if value := os.Getenv(config.CredentialsDurationVar); value != "" {
+		duration, err := parseCredentialsDuration(value)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Invalid value for %s", config.CredentialsDurationVar)
+		}
+		return duration, nil
+	}
+	return 0, nil
+}
+
+func parseCredentialsDuration(value string) (int64, error) {
+	duration, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number of seconds, got %s", value)
+	}
+	if duration < minCredentialsDurationInS {
+		return 0, fmt.Errorf("the duration must be at least %d seconds, got %d", minCredentialsDurationInS, duration)
+	}
+	return duration, nil
+}
+
+func credentialsDurationOrDefault(durationSeconds int64) int64 {
+	if durationSeconds == 0 {
+		return temporaryCredentialsDurationInS
+	}
+	return durationSeconds
+}
+
+// partitionPattern matches partition names, such as aws, aws-us-gov, and aws-cn
+var partitionPattern = regexp.MustCompile(`^aws(-[a-z]+)*$`)
+
+// Role ARNs have the format arn:<partition>:iam::<account ID>:role/<optional path>/<role name>
+func getRoleNameFromARN(roleARN string) (string, error) {
+	split := strings.SplitN(roleARN, ":", 6)
+	if len(split) != 6 || split[0] != "arn" || !partitionPattern.MatchString(split[1]) || split[2] != "iam" || !strings.HasPrefix(split[5], "role/") {
+		return "", fmt.Errorf("Invalid role ARN %s; expected 'arn:<partition>:iam::<account ID>:role/<IAM Role Name>', where the partition is aws, aws-us-gov, or aws-cn", roleARN)
+	}
+	resource := strings.Split(split[5], "/")
+	return resource[len(resource)-1], nil
+}
+
+// unescapeRole decodes a role name or ARN which is still URL-encoded once the path has been decoded, because the
+// caller encoded it twice, such as arn%253Aaws%253Aiam...; role names and ARNs never contain '%', so nothing else is changed
+func unescapeRole(role string) (string, error) {
+	if !strings.Contains(role, "%") {
+		return role, nil
+	}
+	unescaped, err := url.PathUnescape(role)
+	if err != nil {
+		return "", HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Invalid role %s; expected a role name or a URL-encoded role ARN: %v", role, err),
+		}
+	}
+	return unescaped, nil
+}
+
+// splitRolePath splits a role name with an IAM path, such as service/my-role, into the path, /service/,
+// and the name; the path is empty if there is none
+func splitRolePath(rolePath string) (string, string) {
+	rolePath = strings.Trim(rolePath, "/")
+	i := strings.LastIndex(rolePath, "/")
+	if i < 0 {
+		return "", rolePath
+	}
+	return "/" + rolePath[:i+1], rolePath[i+1:]
+}
+
+// getRoleName returns the name of a role given by its name, its name with an IAM path, or its ARN
+func getRoleName(role string) (string, error) {
+	if strings.HasPrefix(role, "arn:") {
+		return getRoleNameFromARN(role)
+	}
+	_, roleName := splitRolePath(role)
+	return roleName, nil
+}
@@ -14,18 +14,35 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/iam/mock_iamiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/retry"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
 	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,7 +63,7 @@ func TestGetRoleCredentials(t *testing.T) {
 	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
 
 	gomock.InOrder(
-		iamMock.EXPECT().GetRole(gomock.Any()).Do(func(x interface{}) {
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
 			input := x.(*iam.GetRoleInput)
 			assert.Equal(t, roleName, aws.StringValue(input.RoleName), "Expected role name to match")
 		}).Return(&iam.GetRoleOutput{
@@ -54,7 +71,7 @@ func TestGetRoleCredentials(t *testing.T) {
 				Arn: aws.String(roleARN),
 			},
 		}, nil),
-		stsMock.EXPECT().AssumeRole(gomock.Any()).Do(func(x interface{}) {
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
 			input := x.(*sts.AssumeRoleInput)
 			assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
 		}).Return(&sts.AssumeRoleOutput{
@@ -67,7 +84,7 @@ func TestGetRoleCredentials(t *testing.T) {
 		}, nil),
 	)
 
-	response, err := credsService.getRoleCredentials(roleName)
+	response, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
 	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
 	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
 	assert.Equal(t, response.SecretAccessKey, secretKey, "Expected secret key to match")
@@ -77,19 +94,83 @@ func TestGetRoleCredentials(t *testing.T) {
 
 }
 
+func TestGetRoleCredentialsCached(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration := time.Now().Add(time.Hour)
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil).Times(1),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil).Times(1),
+	)
+
+	first, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	// the MFA token is not part of the cache key
+	second, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{mfaToken: "123456"})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	assert.Equal(t, first, second, "Expected cached credentials to be returned")
+}
+
+func TestGetRoleCredentialsRefreshedBeforeExpiry(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	// inside of the refresh window, so the next request should call STS again
+	expiration := time.Now().Add(10 * time.Minute)
+
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn: aws.String(roleARN),
+		},
+	}, nil).Times(2)
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(&sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil).Times(2)
+
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{durationSeconds: 3600})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	_, err = credsService.getRoleCredentials(roleName, &assumeRoleOptions{durationSeconds: 3600})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+}
+
+func TestGetRefreshWindow(t *testing.T) {
+	assert.Equal(t, maxCredentialsRefreshWindow, getRefreshWindow(time.Hour), "Expected the maximum refresh window for long lived credentials")
+	assert.Equal(t, 7*time.Minute+30*time.Second, getRefreshWindow(15*time.Minute), "Expected half of the lifetime for short lived credentials")
+}
+
 func TestGetRoleCredentialsGetRoleError(t *testing.T) {
 	iamMock, stsMock := setupMocks(t)
 
 	credsService := newCredentialServiceInTest(iamMock, stsMock)
 
 	gomock.InOrder(
-		iamMock.EXPECT().GetRole(gomock.Any()).Do(func(x interface{}) {
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
 			input := x.(*iam.GetRoleInput)
 			assert.Equal(t, roleName, aws.StringValue(input.RoleName), "Expected role name to match")
 		}).Return(nil, fmt.Errorf("Some API Error")),
 	)
 
-	_, err := credsService.getRoleCredentials(roleName)
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
 	assert.Error(t, err, "Expected error calling getRoleCredentials")
 
 }
@@ -100,7 +181,7 @@ func TestGetRoleCredentialsSTSError(t *testing.T) {
 	credsService := newCredentialServiceInTest(iamMock, stsMock)
 
 	gomock.InOrder(
-		iamMock.EXPECT().GetRole(gomock.Any()).Do(func(x interface{}) {
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
 			input := x.(*iam.GetRoleInput)
 			assert.Equal(t, roleName, aws.StringValue(input.RoleName), "Expected role name to match")
 		}).Return(&iam.GetRoleOutput{
@@ -108,17 +189,706 @@ func TestGetRoleCredentialsSTSError(t *testing.T) {
 				Arn: aws.String(roleARN),
 			},
 		}, nil),
-		stsMock.EXPECT().AssumeRole(gomock.Any()).Do(func(x interface{}) {
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
 			input := x.(*sts.AssumeRoleInput)
 			assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
 		}).Return(nil, fmt.Errorf("Some API Error")),
 	)
 
-	_, err := credsService.getRoleCredentials(roleName)
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
 	assert.Error(t, err, "Expected error calling getRoleCredentials")
 
 }
 
+func TestGetRoleCredentialsWithExternalID(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+			assert.Equal(t, "my-external-id", aws.StringValue(input.ExternalId), "Expected external ID to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	response, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{externalID: "my-external-id"})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
+}
+
+func TestGetRoleCredentialsWithMFA(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	mfaSerial := "arn:aws:iam::111111111111:mfa/clyde"
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, mfaSerial, aws.StringValue(input.SerialNumber), "Expected MFA serial to match")
+			assert.Equal(t, "123456", aws.StringValue(input.TokenCode), "Expected MFA token to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	response, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{
+		mfaSerial: mfaSerial,
+		mfaToken:  "123456",
+	})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
+}
+
+func TestGetAssumeRoleOptions(t *testing.T) {
+	mfaSerial := "arn:aws:iam::111111111111:mfa/clyde"
+	envSerial := "arn:aws:iam::111111111111:mfa/env"
+
+	var testCases = []struct {
+		query    string
+		env      string
+		expected *assumeRoleOptions
+		isErr    bool
+	}{
+		{"", "", &assumeRoleOptions{}, false},
+		{"externalId=abc", "", &assumeRoleOptions{externalID: "abc"}, false},
+		{"mfaSerial=" + mfaSerial + "&mfaToken=123456", "", &assumeRoleOptions{mfaSerial: mfaSerial, mfaToken: "123456"}, false},
+		{"mfaToken=123456", envSerial, &assumeRoleOptions{mfaSerial: envSerial, mfaToken: "123456"}, false},
+		{"mfaSerial=" + mfaSerial + "&mfaToken=123456", envSerial, &assumeRoleOptions{mfaSerial: mfaSerial, mfaToken: "123456"}, false},
+		{"mfaToken=123456", "", nil, true},
+	}
+
+	for _, testCase := range testCases {
+		os.Setenv(config.MFASerialVar, testCase.env)
+		r := httptest.NewRequest("GET", "/role/clyde?"+testCase.query, nil)
+		actual, err := getAssumeRoleOptions(r)
+		if testCase.isErr {
+			assert.Error(t, err, "Expected error for query %s", testCase.query)
+		} else {
+			assert.NoError(t, err, "Unexpected error for query %s", testCase.query)
+			testCase.expected.ctx = r.Context()
+			assert.Equal(t, testCase.expected, actual, "Expected options to match for query %s", testCase.query)
+		}
+	}
+	os.Unsetenv(config.MFASerialVar)
+}
+
+func TestGetRoleCredentialsWithDuration(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, int64(43200), aws.Int64Value(input.DurationSeconds), "Expected duration to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{
+		durationSeconds: 43200,
+	})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+}
+
+func TestGetCredentialsDuration(t *testing.T) {
+	var testCases = []struct {
+		query    string
+		env      string
+		expected int64
+		isErr    bool
+	}{
+		{"", "", 0, false},
+		{"duration=900", "", 900, false},
+		{"duration=43200", "1800", 43200, false},
+		{"", "1800", 1800, false},
+		{"duration=60", "", 0, true},
+		{"duration=1h", "", 0, true},
+		{"", "cats", 0, true},
+	}
+
+	for _, testCase := range testCases {
+		os.Setenv(config.CredentialsDurationVar, testCase.env)
+		r := httptest.NewRequest("GET", "/creds?"+testCase.query, nil)
+		actual, err := getCredentialsDuration(r)
+		if testCase.isErr {
+			assert.Error(t, err, "Expected error for query %s and env %s", testCase.query, testCase.env)
+		} else {
+			assert.NoError(t, err, "Unexpected error for query %s and env %s", testCase.query, testCase.env)
+			assert.Equal(t, testCase.expected, actual, "Expected duration to match for query %s and env %s", testCase.query, testCase.env)
+		}
+	}
+	os.Unsetenv(config.CredentialsDurationVar)
+}
+
+func TestGetRoleCredentialsWithSessionTags(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	tags := map[string]string{
+		"team": "containers",
+	}
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}, opts ...interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+			assert.Len(t, opts, 1, "Expected the session tags request option")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	response, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{
+		sessionTags: tags,
+	})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
+}
+
+func TestGetSessionTags(t *testing.T) {
+	var testCases = []struct {
+		query    string
+		env      string
+		expected map[string]string
+		isErr    bool
+	}{
+		{"", "", nil, false},
+		{"tags=team=containers", "", map[string]string{"team": "containers"}, false},
+		{"", "team=containers,project=local", map[string]string{"team": "containers", "project": "local"}, false},
+		{"tags=team=ecs", "team=containers,project=local", map[string]string{"team": "ecs", "project": "local"}, false},
+		{"tags=team", "", nil, true},
+		{"", "team", nil, true},
+	}
+
+	for _, testCase := range testCases {
+		os.Setenv(config.SessionTagsVar, testCase.env)
+		r := httptest.NewRequest("GET", "/role/clyde?"+testCase.query, nil)
+		actual, err := getSessionTags(r)
+		if testCase.isErr {
+			assert.Error(t, err, "Expected error for query %s and env %s", testCase.query, testCase.env)
+		} else {
+			assert.NoError(t, err, "Unexpected error for query %s and env %s", testCase.query, testCase.env)
+			assert.Equal(t, testCase.expected, actual, "Expected tags to match for query %s and env %s", testCase.query, testCase.env)
+		}
+	}
+	os.Unsetenv(config.SessionTagsVar)
+}
+
+func TestAssumeRoleOptionsCacheKey(t *testing.T) {
+	first := &assumeRoleOptions{sessionTags: map[string]string{"a": "1", "b": "2"}}
+	second := &assumeRoleOptions{sessionTags: map[string]string{"b": "2", "a": "1"}}
+	third := &assumeRoleOptions{sessionTags: map[string]string{"a": "1"}}
+	assert.Equal(t, first.cacheKey(roleName), second.cacheKey(roleName), "Expected the order of the tags to not matter")
+	assert.NotEqual(t, first.cacheKey(roleName), third.cacheKey(roleName), "Expected different tags to have different keys")
+}
+
+func TestGetRoleCredentialsWithSessionPolicy(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, policy, aws.StringValue(input.Policy), "Expected session policy to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{
+		sessionPolicy: policy,
+	})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+}
+
+func TestGetSessionPolicy(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":[]}`
+	envPolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":"*","Resource":"*"}]}`
+
+	var testCases = []struct {
+		query    string
+		env      string
+		expected string
+		isErr    bool
+	}{
+		{"", "", "", false},
+		{"policy=" + url.QueryEscape(policy), "", policy, false},
+		{"", envPolicy, envPolicy, false},
+		{"policy=" + url.QueryEscape(policy), envPolicy, policy, false},
+		{"policy=cats", "", "", true},
+		{"", "{cats", "", true},
+	}
+
+	for _, testCase := range testCases {
+		os.Setenv(config.SessionPolicyVar, testCase.env)
+		r := httptest.NewRequest("GET", "/role/clyde?"+testCase.query, nil)
+		actual, err := getSessionPolicy(r)
+		if testCase.isErr {
+			assert.Error(t, err, "Expected error for query %s and env %s", testCase.query, testCase.env)
+		} else {
+			assert.NoError(t, err, "Unexpected error for query %s and env %s", testCase.query, testCase.env)
+			assert.Equal(t, testCase.expected, actual, "Expected policy to match for query %s and env %s", testCase.query, testCase.env)
+		}
+	}
+	os.Unsetenv(config.SessionPolicyVar)
+}
+
+func TestGetContainerRole(t *testing.T) {
+	labeled := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "172.17.0.2").WithLabel(taskRoleLabel, roleName).Get()
+	labeledARN := testingutils.BaseDockerContainer("worker", "c2a7").WithNetwork("bridge", "172.17.0.3").WithLabel(taskRoleLabel, roleARN).Get()
+	unlabeled := testingutils.BaseDockerContainer("db", "c3a7").WithNetwork("bridge", "172.17.0.4").Get()
+
+	var testCases = []struct {
+		callerIP string
+		expected string
+	}{
+		{"172.17.0.2", roleName},
+		{"172.17.0.3", roleARN},
+	}
+
+	for _, testCase := range testCases {
+		ctrl := gomock.NewController(t)
+		dockerMock := mock_docker.NewMockClient(ctrl)
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{labeled, labeledARN, unlabeled}, nil)
+
+		credsService := &CredentialService{
+			dockerClient: dockerMock,
+		}
+		_, actual, _, err := credsService.getContainerRole(context.Background(), testCase.callerIP)
+		assert.NoError(t, err, "Unexpected error getting role for %s", testCase.callerIP)
+		assert.Equal(t, testCase.expected, actual, "Expected role to match for %s", testCase.callerIP)
+		ctrl.Finish()
+	}
+}
+
+func TestGetContainerRoleMissingLabel(t *testing.T) {
+	unlabeled := testingutils.BaseDockerContainer("db", "c3a7").WithNetwork("bridge", "172.17.0.4").Get()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{unlabeled}, nil)
+
+	credsService := &CredentialService{
+		dockerClient: dockerMock,
+	}
+	_, _, _, err := credsService.getContainerRole(context.Background(), "172.17.0.4")
+	assert.Error(t, err, "Expected error for a container without the task role label")
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code, "Expected a bad request")
+}
+
+func TestGetContainerRoleUnknownCaller(t *testing.T) {
+	labeled := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "172.17.0.2").WithLabel(taskRoleLabel, roleName).Get()
+
+	// the only running container's role must not be vended to the host, a unix socket client, or another address
+	for _, callerIP := range []string{"172.17.0.1", "127.0.0.1", ""} {
+		ctrl := gomock.NewController(t)
+		dockerMock := mock_docker.NewMockClient(ctrl)
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{labeled}, nil).MinTimes(1)
+
+		credsService := &CredentialService{
+			dockerClient: dockerMock,
+		}
+		_, _, _, err := credsService.getContainerRole(context.Background(), callerIP)
+		assert.Error(t, err, "Expected error for caller %s", callerIP)
+		httpErr, ok := err.(HTTPError)
+		assert.True(t, ok, "Expected an HTTPError for caller %s", callerIP)
+		assert.Equal(t, http.StatusForbidden, httpErr.Code, "Expected HTTP 403 for caller %s", callerIP)
+		assert.Nil(t, credsService.findCallerContainer(context.Background(), callerIP), "Expected no caller container for %s", callerIP)
+		ctrl.Finish()
+	}
+}
+
+func TestGetContainerRoleFromConfigFile(t *testing.T) {
+	app := testingutils.BaseDockerContainer("app", "c1a7").WithComposeProject("project").WithNetwork("bridge", "172.17.0.2").Get()
+	// the label takes precedence over the config file
+	labeled := testingutils.BaseDockerContainer("worker", "c2a7").WithComposeProject("project").WithLabel(taskRoleLabel, roleName).WithNetwork("bridge", "172.17.0.3").Get()
+
+	var testCases = []struct {
+		callerIP string
+		expected string
+	}{
+		{"172.17.0.2", roleARN},
+		{"172.17.0.3", roleName},
+	}
+
+	for _, testCase := range testCases {
+		ctrl := gomock.NewController(t)
+		dockerMock := mock_docker.NewMockClient(ctrl)
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{app, labeled}, nil)
+
+		credsService := &CredentialService{
+			dockerClient: dockerMock,
+			services: map[string]configfile.Service{
+				"ecs-local": configfile.Service{
+					Role:       roleARN,
+					ExternalID: "cats",
+				},
+			},
+		}
+		_, actual, serviceConfig, err := credsService.getContainerRole(context.Background(), testCase.callerIP)
+		assert.NoError(t, err, "Unexpected error getting role for %s", testCase.callerIP)
+		assert.Equal(t, testCase.expected, actual, "Expected role to match for %s", testCase.callerIP)
+		assert.Equal(t, "cats", serviceConfig.ExternalID, "Expected service settings for %s", testCase.callerIP)
+		ctrl.Finish()
+	}
+}
+
+func TestApplyServiceConfig(t *testing.T) {
+	serviceConfig := configfile.Service{
+		ExternalID: "cats",
+		Duration:   900,
+	}
+
+	var testCases = []struct {
+		query              string
+		expectedExternalID string
+		expectedDuration   int64
+	}{
+		{"", "cats", 900},
+		{"externalId=dogs&duration=1800", "dogs", 1800},
+	}
+
+	for _, testCase := range testCases {
+		r := httptest.NewRequest("GET", "/role?"+testCase.query, nil)
+		opts, err := getAssumeRoleOptions(r)
+		assert.NoError(t, err, "Unexpected error for query %s", testCase.query)
+		applyServiceConfig(r, opts, serviceConfig)
+		assert.Equal(t, testCase.expectedExternalID, opts.externalID, "Expected external ID to match for query %s", testCase.query)
+		assert.Equal(t, testCase.expectedDuration, opts.durationSeconds, "Expected duration to match for query %s", testCase.query)
+	}
+}
+
+func TestGetProfileService(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	profileIAMMock, profileSTSMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	calls := 0
+	credsService.newProfileClients = func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+		calls++
+		assert.Equal(t, "dev", profileName, "Expected profile name to match")
+		return profileIAMMock, profileSTSMock, nil, nil
+	}
+
+	r := httptest.NewRequest("GET", "/role/clyde", nil)
+	actual, err := credsService.getProfileService(r)
+	assert.NoError(t, err, "Unexpected error getting service")
+	assert.Equal(t, credsService, actual, "Expected the default service without a profile")
+
+	r = httptest.NewRequest("GET", "/role/clyde?profile=dev", nil)
+	actual, err = credsService.getProfileService(r)
+	assert.NoError(t, err, "Unexpected error getting service for profile")
+	assert.Equal(t, profileIAMMock, actual.iamClient, "Expected the IAM client of the profile")
+	assert.Equal(t, profileSTSMock, actual.stsClient, "Expected the STS client of the profile")
+
+	cached, err := credsService.getProfileService(r)
+	assert.NoError(t, err, "Unexpected error getting service for profile")
+	assert.Equal(t, actual, cached, "Expected the service for the profile to be reused")
+	assert.Equal(t, 1, calls, "Expected clients to be created once for the profile")
+}
+
+func TestGetProfileServiceError(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.newProfileClients = func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+		return nil, nil, nil, fmt.Errorf("Profile %s does not exist", profileName)
+	}
+
+	r := httptest.NewRequest("GET", "/creds?profile=missing", nil)
+	_, err := credsService.getProfileService(r)
+	assert.Error(t, err, "Expected error for a missing profile")
+	assert.Empty(t, credsService.profileServices, "Expected failed profiles not to be saved")
+}
+
+func TestGetRoleCredentialsByARN(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	crossAccountRoleARN := "arn:aws:iam::222222222222:role/clyde_task_role"
+
+	gomock.InOrder(
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, crossAccountRoleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+			assert.Nil(t, input.ExternalId, "Expected no external ID")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	response, err := credsService.getRoleCredentialsByARN(crossAccountRoleARN, &assumeRoleOptions{})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentialsByARN")
+	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
+	assert.Equal(t, response.RoleArn, crossAccountRoleARN, "Expected role ARN to match")
+}
+
+func TestGetRoleCredentialsWithPath(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	expiration := time.Now().Add(time.Hour)
+	pathRoleARN := "arn:aws:iam::111111111111:role/service/" + roleName
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*iam.GetRoleInput)
+			assert.Equal(t, roleName, aws.StringValue(input.RoleName), "Expected the role name without the path")
+		}).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn:  aws.String(pathRoleARN),
+				Path: aws.String("/service/"),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*sts.AssumeRoleInput)
+			assert.Equal(t, pathRoleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/role/service/"+roleName, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected status OK")
+	response := &CredentialResponse{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response), "Unexpected error unmarshalling response")
+	assert.Equal(t, pathRoleARN, response.RoleArn, "Expected role ARN to match")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/role/service/"+roleName+"/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected the cached credentials with a trailing slash")
+
+	_, err := credsService.getRoleCredentials("/service/"+roleName, &assumeRoleOptions{})
+	assert.NoError(t, err, "Expected the cached credentials with a leading slash")
+}
+
+func TestGetRoleCredentialsWithWrongPath(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:  aws.String("arn:aws:iam::111111111111:role/other/" + roleName),
+			Path: aws.String("/other/"),
+		},
+	}, nil)
+
+	_, err := credsService.getRoleCredentials("service/"+roleName, &assumeRoleOptions{})
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTP error")
+	assert.Equal(t, http.StatusNotFound, httpErr.Code, "Expected not found for a role with another path")
+	assert.Contains(t, httpErr.Error(), "/other/", "Expected the error to include the path of the role")
+}
+
+func TestGetServiceLinkedRoleCredentials(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	serviceLinkedRoleARN := "arn:aws:iam::111111111111:role/aws-service-role/ecs.amazonaws.com/AWSServiceRoleForECS"
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			input := x.(*iam.GetRoleInput)
+			assert.Equal(t, "AWSServiceRoleForECS", aws.StringValue(input.RoleName), "Expected the role name without the path")
+		}).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn:  aws.String(serviceLinkedRoleARN),
+				Path: aws.String("/aws-service-role/ecs.amazonaws.com/"),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New("AccessDenied", "Not authorized to perform sts:AssumeRole", nil)),
+	)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/role/aws-service-role/ecs.amazonaws.com/AWSServiceRoleForECS", nil))
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "Expected status forbidden")
+	assert.Contains(t, recorder.Body.String(), "service-linked role", "Expected the error to explain service-linked roles")
+}
+
+func TestGetRoleCredentialsByEncodedARN(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	expiration := time.Now().Add(time.Hour)
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+		input := x.(*sts.AssumeRoleInput)
+		assert.Equal(t, crossAccountRoleARN, aws.StringValue(input.RoleArn), "Expected the decoded role ARN")
+	}).Return(&sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil)
+
+	// arn%3Aaws%3Aiam%3A%3A222222222222%3Arole%2Fother
+	encoded := strings.NewReplacer(":", "%3A", "/", "%2F").Replace(crossAccountRoleARN)
+	// roles requested by ARN are assumed without iam:GetRole, and the credentials are cached for every encoding
+	for _, path := range []string{
+		"/role/" + encoded,
+		"/role/" + url.PathEscape(encoded),
+		"/role/" + crossAccountRoleARN,
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, "Expected status OK for %s", path)
+		response := &CredentialResponse{}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response), "Unexpected error unmarshalling response for %s", path)
+		assert.Equal(t, crossAccountRoleARN, response.RoleArn, "Expected role ARN to match for %s", path)
+	}
+
+	for _, path := range []string{
+		"/role/arn%253Aaws%253Aiam%253A%253A222222222222%253Auser%252Fother",
+		"/role/arn%253Aaws%253Aiam%253A%253A222222222222%253Aother",
+		"/role/arn%25ZZ",
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Expected bad request for %s", path)
+	}
+}
+
+func TestSplitRolePath(t *testing.T) {
+	var testCases = []struct {
+		rolePath string
+		path     string
+		name     string
+	}{
+		{"clyde_task_role", "", "clyde_task_role"},
+		{"service/clyde_task_role", "/service/", "clyde_task_role"},
+		{"/service/clyde_task_role", "/service/", "clyde_task_role"},
+		{"aws-service-role/ecs.amazonaws.com/AWSServiceRoleForECS", "/aws-service-role/ecs.amazonaws.com/", "AWSServiceRoleForECS"},
+	}
+
+	for _, testCase := range testCases {
+		path, name := splitRolePath(testCase.rolePath)
+		assert.Equal(t, testCase.path, path, "Expected the path of %s to match", testCase.rolePath)
+		assert.Equal(t, testCase.name, name, "Expected the name of %s to match", testCase.rolePath)
+	}
+}
+
+func TestGetRoleNameFromARN(t *testing.T) {
+	var testCases = []struct {
+		roleARN  string
+		expected string
+		isErr    bool
+	}{
+		{"arn:aws:iam::111111111111:role/clyde_task_role", "clyde_task_role", false},
+		{"arn:aws:iam::111111111111:role/some/path/clyde_task_role", "clyde_task_role", false},
+		{"arn:aws-cn:iam::111111111111:role/clyde_task_role", "clyde_task_role", false},
+		{"arn:aws-us-gov:iam::111111111111:role/clyde_task_role", "clyde_task_role", false},
+		{"arn:gcp:iam::111111111111:role/clyde_task_role", "", true},
+		{"arn:aws:iam::111111111111:user/clyde", "", true},
+		{"arn:aws:s3:::bucket/clyde", "", true},
+		{"clyde_task_role", "", true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := getRoleNameFromARN(testCase.roleARN)
+		if testCase.isErr {
+			assert.Error(t, err, "Expected error for %s", testCase.roleARN)
+		} else {
+			assert.NoError(t, err, "Unexpected error for %s", testCase.roleARN)
+			assert.Equal(t, testCase.expected, actual, "Expected role name to match")
+		}
+	}
+}
+
 func TestGetTemporaryCredentials(t *testing.T) {
 	iamMock, stsMock := setupMocks(t)
 
@@ -127,7 +897,7 @@ func TestGetTemporaryCredentials(t *testing.T) {
 	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
 
 	gomock.InOrder(
-		stsMock.EXPECT().GetSessionToken(gomock.Any()).Return(&sts.GetSessionTokenOutput{
+		stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetSessionTokenOutput{
 			Credentials: &sts.Credentials{
 				AccessKeyId:     aws.String(accessKey),
 				SecretAccessKey: aws.String(secretKey),
@@ -137,7 +907,7 @@ func TestGetTemporaryCredentials(t *testing.T) {
 		}, nil),
 	)
 
-	response, err := credsService.getTemporaryCredentials()
+	response, err := credsService.getTemporaryCredentials(context.Background(), 0, "")
 	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
 	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
 	assert.Equal(t, response.SecretAccessKey, secretKey, "Expected secret key to match")
@@ -152,10 +922,10 @@ func TestGetTemporaryCredentialsErrorCase(t *testing.T) {
 	credsService := newCredentialServiceInTest(iamMock, stsMock)
 
 	gomock.InOrder(
-		stsMock.EXPECT().GetSessionToken(gomock.Any()).Return(nil, fmt.Errorf("Some API Error")),
+		stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("Some API Error")),
 	)
 
-	_, err := credsService.getTemporaryCredentials()
+	_, err := credsService.getTemporaryCredentials(context.Background(), 0, "")
 	assert.Error(t, err, "Expected error calling getRoleCredentials")
 
 }
@@ -196,7 +966,7 @@ func TestGetTemporaryCredentialsExistingTempCreds(t *testing.T) {
 		currentSession: sess,
 	}
 
-	response, err := credsService.getTemporaryCredentials()
+	response, err := credsService.getTemporaryCredentials(context.Background(), 0, "")
 	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
 	assert.Equal(t, response.AccessKeyID, accessKey, "Expected access key to match")
 	assert.Equal(t, response.SecretAccessKey, secretKey, "Expected secret key to match")
@@ -217,5 +987,24 @@ func newCredentialServiceInTest(iamMock *mock_iamiface.MockIAMAPI, stsMock *mock
 		stsClient:      stsMock,
 		iamClient:      iamMock,
 		currentSession: nil,
+		cache:          newCredentialsCache(),
 	}
 }
+
+func TestGetMaxRetries(t *testing.T) {
+	defer os.Unsetenv(config.MaxRetriesVar)
+
+	os.Unsetenv(config.MaxRetriesVar)
+	maxRetries, err := getMaxRetries()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, retry.DefaultMaxRetries, maxRetries, "Expected the default number of retries")
+
+	os.Setenv(config.MaxRetriesVar, "0")
+	maxRetries, err = getMaxRetries()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0, maxRetries, "Expected retries to be disabled")
+
+	os.Setenv(config.MaxRetriesVar, "-1")
+	_, err = getMaxRetries()
+	assert.Error(t, err, "Expected error for a negative number of retries")
+}
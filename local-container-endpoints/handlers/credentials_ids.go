@@ -0,0 +1,195 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// credentialsIDNamespace is used to derive credentials IDs, so that the ID for a role
+// is the same each time Local Endpoints runs and can be set in a Compose file.
+// Since the namespace is public, anyone who knows the role and the task ARN can derive its ID;
+// IDs identify roles, but they are not secrets.
+var credentialsIDNamespace = uuid.Parse("58934157-bb9f-4b4c-b1dc-b21bb447b77f")
+
+// credentialsRole is a role which can be requested by its credentials ID
+type credentialsRole struct {
+	role          string
+	serviceConfig configfile.Service
+}
+
+// getCredentialsID returns the opaque credentials ID for the role in the local task
+func getCredentialsID(role string) string {
+	taskARN := utils.GetValue(config.DefaultTaskARN, config.TaskARNVar)
+	return uuid.NewSHA1(credentialsIDNamespace, []byte(taskARN+"|"+role)).String()
+}
+
+// getCredentialsRelativeURI returns the value of AWS_CONTAINER_CREDENTIALS_RELATIVE_URI for the role
+func getCredentialsRelativeURI(role string) string {
+	return strings.Replace(config.V2CredentialsIDPath, "{id}", getCredentialsID(role), 1)
+}
+
+// getCredentialsIDHandler returns the handler for the credentials paths used by the ECS Agent, which identify the role by an opaque ID
+func (service *CredentialService) getCredentialsIDHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received credentials ID request")
+
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		credsRole, err := service.findCredentialsRole(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		opts, err := getAssumeRoleOptions(r)
+		if err != nil {
+			return err
+		}
+		applyServiceConfig(r, opts, credsRole.serviceConfig)
+		service.setCallerContainer(r, opts, nil)
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response, err := profileService.getRoleCredentialsByNameOrARN(credsRole.role, opts)
+		if err != nil {
+			return err
+		}
+
+		service.auditCredentials(r, response)
+		writeJSONResponse(w, response)
+		return nil
+	}
+}
+
+// getCredentialsIDListHandler returns a handler which returns the relative URI for the role of the calling container;
+// the URIs of other roles are not listed, so that a container can't discover the roles of other containers
+func (service *CredentialService) getCredentialsIDListHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received credentials ID list request")
+
+		_, role, _, err := service.getContainerRole(r.Context(), getCallerIP(r))
+		if err != nil {
+			return err
+		}
+
+		writeJSONResponse(w, map[string]string{
+			role: getCredentialsRelativeURI(role),
+		})
+		return nil
+	}
+}
+
+// findCredentialsRole returns the role with the given credentials ID;
+// the roles in the config file are checked before those in the labels of running containers.
+func (service *CredentialService) findCredentialsRole(ctx context.Context, id string) (*credentialsRole, error) {
+	for _, credsRole := range service.getConfigFileRoles() {
+		if getCredentialsID(credsRole.role) == id {
+			return &credsRole, nil
+		}
+	}
+
+	labelRoles, err := service.getLabelRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, credsRole := range labelRoles {
+		if getCredentialsID(credsRole.role) == id {
+			return &credsRole, nil
+		}
+	}
+
+	return nil, HTTPError{
+		Code:      http.StatusBadRequest,
+		ErrorCode: v1.ErrInvalidIDInRequest,
+		Err:       fmt.Errorf("Credentials not found for ID %s; the role must be in the config file or in the '%s' label of a running container", id, taskRoleLabel),
+	}
+}
+
+func (service *CredentialService) getCredentialsRoles(ctx context.Context) ([]credentialsRole, error) {
+	labelRoles, err := service.getLabelRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(service.getConfigFileRoles(), labelRoles...), nil
+}
+
+// getConfigFileRoles returns the roles of the services in the config file;
+// if services share a role, the settings of the first service by name are used.
+func (service *CredentialService) getConfigFileRoles() []credentialsRole {
+	services, _ := service.getSettings()
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var credsRoles []credentialsRole
+	seen := make(map[string]bool)
+	for _, name := range names {
+		serviceConfig := services[name]
+		if serviceConfig.Role == "" || seen[serviceConfig.Role] {
+			continue
+		}
+		seen[serviceConfig.Role] = true
+		credsRoles = append(credsRoles, credentialsRole{
+			role:          serviceConfig.Role,
+			serviceConfig: serviceConfig,
+		})
+	}
+	return credsRoles
+}
+
+// getLabelRoles returns the roles in the task role labels of running containers
+func (service *CredentialService) getLabelRoles(ctx context.Context) ([]credentialsRole, error) {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list running containers")
+	}
+
+	var credsRoles []credentialsRole
+	seen := make(map[string]bool)
+	for _, container := range containers {
+		role := container.Labels[taskRoleLabel]
+		if role == "" || seen[role] {
+			continue
+		}
+		seen[role] = true
+		credsRoles = append(credsRoles, credentialsRole{
+			role: role,
+		})
+	}
+	return credsRoles, nil
+}
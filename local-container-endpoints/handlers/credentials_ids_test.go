@@ -0,0 +1,145 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCredentialsID(t *testing.T) {
+	id := getCredentialsID(roleName)
+	assert.NotNil(t, uuid.Parse(id), "Expected credentials ID to be a GUID")
+	assert.Equal(t, id, getCredentialsID(roleName), "Expected credentials ID to be the same for the role")
+	assert.NotEqual(t, id, getCredentialsID(roleARN), "Expected credentials IDs to differ between roles")
+	assert.Equal(t, "/v2/credentials/"+id, getCredentialsRelativeURI(roleName), "Expected relative URI to match")
+
+	os.Setenv(config.TaskARNVar, "arn:aws:ecs:us-west-2:111111111111:task/other-cluster/1234")
+	defer os.Unsetenv(config.TaskARNVar)
+	assert.NotEqual(t, id, getCredentialsID(roleName), "Expected credentials IDs to differ between tasks")
+}
+
+func TestFindCredentialsRole(t *testing.T) {
+	labeled := testingutils.BaseDockerContainer("worker", "c2a7").WithLabel(taskRoleLabel, roleARN).Get()
+	unlabeled := testingutils.BaseDockerContainer("db", "c3a7").Get()
+
+	var testCases = []struct {
+		id                 string
+		expectedRole       string
+		expectedExternalID string
+		listsContainers    bool
+	}{
+		{getCredentialsID(roleName), roleName, "cats", false},
+		{getCredentialsID(roleARN), roleARN, "", true},
+	}
+
+	for _, testCase := range testCases {
+		ctrl := gomock.NewController(t)
+		dockerMock := mock_docker.NewMockClient(ctrl)
+		if testCase.listsContainers {
+			dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{labeled, unlabeled}, nil)
+		}
+
+		credsService := &CredentialService{
+			dockerClient: dockerMock,
+			services: map[string]configfile.Service{
+				"app": configfile.Service{
+					Role:       roleName,
+					ExternalID: "cats",
+				},
+			},
+		}
+		credsRole, err := credsService.findCredentialsRole(context.Background(), testCase.id)
+		assert.NoError(t, err, "Unexpected error finding role for ID %s", testCase.id)
+		assert.Equal(t, testCase.expectedRole, credsRole.role, "Expected role to match for ID %s", testCase.id)
+		assert.Equal(t, testCase.expectedExternalID, credsRole.serviceConfig.ExternalID, "Expected service settings to match for ID %s", testCase.id)
+		ctrl.Finish()
+	}
+}
+
+func TestFindCredentialsRoleNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{}, nil)
+
+	credsService := &CredentialService{
+		dockerClient: dockerMock,
+	}
+	_, err := credsService.findCredentialsRole(context.Background(), getCredentialsID(roleName))
+	assert.Error(t, err, "Expected error for an unknown credentials ID")
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code, "Expected a bad request")
+}
+
+func TestGetCredentialsIDListHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// httptest requests come from 192.0.2.1
+	caller := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "192.0.2.1").WithLabel(taskRoleLabel, roleName).Get()
+	other := testingutils.BaseDockerContainer("worker", "c2a7").WithNetwork("bridge", "192.0.2.2").WithLabel(taskRoleLabel, roleARN).Get()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{caller, other}, nil).Times(2)
+
+	credsService := &CredentialService{
+		dockerClient: dockerMock,
+	}
+	handler := ServeHTTP(credsService.getCredentialsIDListHandler())
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, config.V2CredentialsPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	var uris map[string]string
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &uris), "Unexpected error unmarshalling response")
+	assert.Equal(t, map[string]string{roleName: getCredentialsRelativeURI(roleName)}, uris, "Expected only the URI of the caller's role")
+
+	request := httptest.NewRequest(http.MethodGet, config.V2CredentialsPath, nil)
+	request.RemoteAddr = "192.0.2.99:1234"
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "Expected http status code to be 403 for an unknown caller")
+}
+
+func TestGetConfigFileRoles(t *testing.T) {
+	credsService := &CredentialService{
+		services: map[string]configfile.Service{
+			"b-app": configfile.Service{
+				Role:     roleName,
+				Duration: 1800,
+			},
+			"a-app": configfile.Service{
+				Role:     roleName,
+				Duration: 900,
+			},
+			"db": configfile.Service{},
+		},
+	}
+	credsRoles := credsService.getConfigFileRoles()
+	assert.Len(t, credsRoles, 1, "Expected one role")
+	assert.Equal(t, int64(900), credsRoles[0].serviceConfig.Duration, "Expected the settings of the first service by name")
+}
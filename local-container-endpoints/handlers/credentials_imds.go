@@ -0,0 +1,111 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/docker/docker/api/types"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	imdsSuccessCode     = "Success"
+	imdsCredentialsType = "AWS-HMAC"
+)
+
+// getIMDSRoleListHandler returns the handler which lists the role of the calling container, in the same way as the
+// EC2 instance metadata service lists the role of the instance profile
+func (service *CredentialService) getIMDSRoleListHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received IMDS role list request")
+
+		_, role, _, err := service.getContainerRole(r.Context(), getCallerIP(r))
+		if err != nil {
+			if httpErr, ok := err.(HTTPError); ok {
+				// IMDS returns not found when the instance has no role
+				httpErr.Code = http.StatusNotFound
+				return httpErr
+			}
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(getIMDSRoleName(role)))
+		return nil
+	}
+}
+
+// getIMDSRoleHandler returns the handler which vends credentials in the format of the EC2 instance metadata service.
+// The role of the calling container is used if it has the requested name, so that roles in other accounts and
+// the config file settings work as they do for the "/role" path; otherwise, the role is found by its name.
+func (service *CredentialService) getIMDSRoleHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received IMDS role credentials request")
+
+		roleName := mux.Vars(r)["role"]
+		role := roleName
+		var container *types.Container
+		var serviceConfig configfile.Service
+		if callerContainer, containerRole, containerServiceConfig, err := service.getContainerRole(r.Context(), getCallerIP(r)); err == nil && getIMDSRoleName(containerRole) == roleName {
+			container = callerContainer
+			role = containerRole
+			serviceConfig = containerServiceConfig
+		}
+
+		opts, err := getAssumeRoleOptions(r)
+		if err != nil {
+			return err
+		}
+		applyServiceConfig(r, opts, serviceConfig)
+		service.setCallerContainer(r, opts, container)
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response, err := profileService.getRoleCredentialsByNameOrARN(role, opts)
+		if err != nil {
+			return err
+		}
+
+		service.auditContainerCredentials(r, response, container)
+		writeJSONResponse(w, &IMDSCredentialResponse{
+			Code:            imdsSuccessCode,
+			LastUpdated:     time.Now().UTC().Format(CredentialExpirationTimeFormat),
+			Type:            imdsCredentialsType,
+			AccessKeyID:     response.AccessKeyID,
+			SecretAccessKey: response.SecretAccessKey,
+			Token:           response.Token,
+			Expiration:      response.Expiration,
+		})
+		return nil
+	}
+}
+
+// getIMDSRoleName returns the name of a role given by its name or ARN
+func getIMDSRoleName(role string) string {
+	if strings.HasPrefix(role, "arn:") {
+		if roleName, err := getRoleNameFromARN(role); err == nil {
+			return roleName
+		}
+	}
+	return role
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// getRolesListHandler returns the handler which lists the roles in the config file and the task role labels of running containers,
+// and the profiles in the shared config files; roles which are not allowed to be assumed are left out.
+func (service *CredentialService) getRolesListHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received roles list request")
+
+		roles, err := service.getRoleSummaries(r.Context())
+		if err != nil {
+			return err
+		}
+		profiles, err := getProfileSummaries()
+		if err != nil {
+			return err
+		}
+
+		writeJSONResponse(w, &RolesResponse{
+			Roles:    roles,
+			Profiles: profiles,
+		})
+		return nil
+	}
+}
+
+func (service *CredentialService) getRoleSummaries(ctx context.Context) ([]RoleSummary, error) {
+	summaries := make(map[string]*RoleSummary)
+	getSummary := func(role string) *RoleSummary {
+		summary, ok := summaries[role]
+		if !ok {
+			summary = &RoleSummary{
+				Role:                   role,
+				CredentialsRelativeURI: getCredentialsRelativeURI(role),
+			}
+			summaries[role] = summary
+		}
+		return summary
+	}
+
+	services, _ := service.getSettings()
+	for name, serviceConfig := range services {
+		if serviceConfig.Role != "" {
+			summary := getSummary(serviceConfig.Role)
+			summary.Services = append(summary.Services, name)
+		}
+	}
+
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list running containers")
+	}
+	for i := range containers {
+		if role := containers[i].Labels[taskRoleLabel]; role != "" {
+			summary := getSummary(role)
+			summary.Containers = append(summary.Containers, getContainerName(&containers[i]))
+		}
+	}
+
+	var roles []RoleSummary
+	for role, summary := range summaries {
+		if !service.isRoleAllowed(role) {
+			continue
+		}
+		sort.Strings(summary.Services)
+		sort.Strings(summary.Containers)
+		roles = append(roles, *summary)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Role < roles[j].Role
+	})
+	return roles, nil
+}
+
+// isRoleAllowed checks the role name or ARN against the allowed and denied roles
+func (service *CredentialService) isRoleAllowed(role string) bool {
+	roleName, err := getRoleName(role)
+	if err != nil {
+		return false
+	}
+	_, roles := service.getSettings()
+	return roles.check(role, roleName) == nil
+}
+
+func getProfileSummaries() ([]ProfileSummary, error) {
+	sharedConfig, err := sharedconfig.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the shared config files")
+	}
+
+	currentProfile := sharedconfig.CurrentProfileName()
+	var profiles []ProfileSummary
+	for _, name := range sharedConfig.ProfileNames() {
+		profile, _ := sharedConfig.Profile(name)
+		profiles = append(profiles, ProfileSummary{
+			Name:    name,
+			Current: name == currentProfile,
+			Region:  profile["region"],
+			RoleArn: profile["role_arn"],
+		})
+	}
+	return profiles, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	batchRoleARN = "arn:aws:iam::222222222222:role/batch"
+
+	testRolesSharedConfig = `
+[default]
+region = us-west-2
+
+[profile admin]
+role_arn = arn:aws:iam::111111111111:role/admin
+source_profile = default
+`
+)
+
+func TestGetRolesList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roles")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte(testRolesSharedConfig), 0600), "Unexpected error writing config file")
+	os.Setenv("AWS_CONFIG_FILE", configFile)
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	os.Setenv(config.DeniedRolesVar, "*admin*")
+	defer func() {
+		os.Unsetenv("AWS_CONFIG_FILE")
+		os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+		os.Unsetenv(config.DeniedRolesVar)
+	}()
+
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+	credsService := NewCredentialServiceWithClients(nil, nil, dockerMock, nil)
+	credsService.services = map[string]configfile.Service{
+		"worker": {Role: roleName},
+		"app":    {Role: roleName},
+		"admin":  {Role: "admin"},
+		"db":     {},
+	}
+	credsService.roles, err = newRoleFilter()
+	assert.NoError(t, err, "Unexpected error creating role filter")
+
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{
+		testingutils.BaseDockerContainer("web", "c1a7").WithLabel(taskRoleLabel, roleName).Get(),
+		testingutils.BaseDockerContainer("batch", "b2c8").WithLabel(taskRoleLabel, batchRoleARN).Get(),
+		testingutils.BaseDockerContainer("cache", "d3e9").Get(),
+	}, nil)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", config.RolesPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected status to match")
+
+	var response RolesResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response), "Unexpected error decoding response")
+	assert.Equal(t, []RoleSummary{
+		{
+			Role:                   batchRoleARN,
+			CredentialsRelativeURI: getCredentialsRelativeURI(batchRoleARN),
+			Containers:             []string{"batch"},
+		},
+		{
+			Role:                   roleName,
+			CredentialsRelativeURI: getCredentialsRelativeURI(roleName),
+			Services:               []string{"app", "worker"},
+			Containers:             []string{"web"},
+		},
+	}, response.Roles, "Expected roles to match, without the denied role")
+	assert.Equal(t, []ProfileSummary{
+		{
+			Name:    "admin",
+			RoleArn: "arn:aws:iam::111111111111:role/admin",
+		},
+		{
+			Name:    "default",
+			Current: true,
+			Region:  "us-west-2",
+		},
+	}, response.Profiles, "Expected profiles to match")
+}
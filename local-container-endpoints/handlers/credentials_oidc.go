@@ -0,0 +1,230 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	oidcAudienceQueryParameter = "audience"
+	oidcSubjectQueryParameter  = "subject"
+	// tokens are for sts:AssumeRoleWithWebIdentity unless another audience is requested
+	defaultOIDCAudience = "sts.amazonaws.com"
+	// defaultOIDCSubject is the subject of tokens for callers which are not containers
+	defaultOIDCSubject   = "ecs-local"
+	oidcSigningAlgorithm = "RS256"
+	oidcKeySize          = 2048
+)
+
+// oidcIssuer is a minimal OpenID Connect provider, which signs identity tokens for the calling containers
+type oidcIssuer struct {
+	issuer string
+	key    *rsa.PrivateKey
+	keyID  string
+}
+
+// newOIDCIssuer returns the OIDC provider at the issuer URL in ECS_LOCAL_OIDC_ISSUER, or nil if it is not set
+func newOIDCIssuer() (*oidcIssuer, error) {
+	issuer := os.Getenv(config.OIDCIssuerVar)
+	if issuer == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(issuer)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("Invalid %s: %s is not an issuer URL, such as https://my-bucket.s3.amazonaws.com", config.OIDCIssuerVar, issuer)
+	}
+
+	key, err := loadOIDCSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := getKeyThumbprint(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &oidcIssuer{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		key:    key,
+		keyID:  keyID,
+	}, nil
+}
+
+// loadOIDCSigningKey reads the RSA key in ECS_LOCAL_OIDC_KEY_FILE, in PKCS #1 or PKCS #8 format, or else generates one,
+// which changes each time Local Endpoints starts
+func loadOIDCSigningKey() (*rsa.PrivateKey, error) {
+	path := os.Getenv(config.OIDCKeyFileVar)
+	if path == "" {
+		logrus.Warnf("The OIDC signing key is generated each time Local Endpoints starts; set %s to keep the published keys valid", config.OIDCKeyFileVar)
+		return rsa.GenerateKey(rand.Reader, oidcKeySize)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read %s", config.OIDCKeyFileVar)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Invalid %s: %s is not PEM encoded", config.OIDCKeyFileVar, path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s does not contain an RSA private key", config.OIDCKeyFileVar, path)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s: %s does not contain an RSA private key", config.OIDCKeyFileVar, path)
+	}
+	return key, nil
+}
+
+// getKeyThumbprint returns the RFC 7638 thumbprint of the key, which is its key ID
+func getKeyThumbprint(key *rsa.PublicKey) (string, error) {
+	n, e := getJWKParameters(key)
+	// the members are required to be in lexicographic order, without whitespace
+	data, err := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{e, "RSA", n})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func getJWKParameters(key *rsa.PublicKey) (string, string) {
+	return base64.RawURLEncoding.EncodeToString(key.N.Bytes()), base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+}
+
+// getDiscoveryHandler returns the handler for the discovery document, which STS reads from the issuer URL
+func (issuer *oidcIssuer) getDiscoveryHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		writeJSONResponse(w, &OIDCDiscoveryResponse{
+			Issuer:                           issuer.issuer,
+			JWKSURI:                          issuer.issuer + config.OIDCKeysPath,
+			ResponseTypesSupported:           []string{"id_token"},
+			SubjectTypesSupported:            []string{"public"},
+			IDTokenSigningAlgValuesSupported: []string{oidcSigningAlgorithm},
+			ClaimsSupported:                  []string{"aud", "exp", "iat", "iss", "sub"},
+		})
+		return nil
+	}
+}
+
+// getKeysHandler returns the handler for the JSON Web Key Set with the public signing key
+func (issuer *oidcIssuer) getKeysHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		n, e := getJWKParameters(&issuer.key.PublicKey)
+		writeJSONResponse(w, &JWKSResponse{
+			Keys: []JSONWebKey{
+				{
+					KeyType:   "RSA",
+					Use:       "sig",
+					Algorithm: oidcSigningAlgorithm,
+					KeyID:     issuer.keyID,
+					Modulus:   n,
+					Exponent:  e,
+				},
+			},
+		})
+		return nil
+	}
+}
+
+// getOIDCTokenHandler returns the handler which signs an identity token; its subject is the name of the calling
+// container unless another one is requested, and it expires after the credentials duration
+func (service *CredentialService) getOIDCTokenHandler(issuer *oidcIssuer) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received OIDC token request")
+
+		durationSeconds, err := getCredentialsDuration(r)
+		if err != nil {
+			return err
+		}
+		audience := r.URL.Query().Get(oidcAudienceQueryParameter)
+		if audience == "" {
+			audience = defaultOIDCAudience
+		}
+		subject := r.URL.Query().Get(oidcSubjectQueryParameter)
+		if subject == "" {
+			subject = defaultOIDCSubject
+			if container := service.findCallerContainer(r.Context(), getCallerIP(r)); container != nil {
+				subject = getContainerName(container)
+			}
+		}
+
+		token, err := issuer.signToken(subject, audience, time.Now(), time.Duration(credentialsDurationOrDefault(durationSeconds))*time.Second)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/jwt")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(token))
+		return nil
+	}
+}
+
+// signToken returns a JSON Web Token signed with RS256
+func (issuer *oidcIssuer) signToken(subject, audience string, now time.Time, lifetime time.Duration) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": oidcSigningAlgorithm,
+		"typ": "JWT",
+		"kid": issuer.keyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": issuer.issuer,
+		"sub": subject,
+		"aud": audience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(lifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, issuer.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to sign the OIDC token")
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
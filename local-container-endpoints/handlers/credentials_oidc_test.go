@@ -0,0 +1,210 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const testOIDCIssuer = "https://my-bucket.s3.amazonaws.com"
+
+func newOIDCIssuerInTest(t *testing.T) *oidcIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, oidcKeySize)
+	assert.NoError(t, err, "Unexpected error generating key")
+	keyID, err := getKeyThumbprint(&key.PublicKey)
+	assert.NoError(t, err, "Unexpected error")
+	return &oidcIssuer{
+		issuer: testOIDCIssuer,
+		key:    key,
+		keyID:  keyID,
+	}
+}
+
+func TestNewOIDCIssuer(t *testing.T) {
+	defer os.Unsetenv(config.OIDCIssuerVar)
+	defer os.Unsetenv(config.OIDCKeyFileVar)
+
+	issuer, err := newOIDCIssuer()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Nil(t, issuer, "Expected no OIDC provider by default")
+
+	for _, invalid := range []string{"my-bucket.s3.amazonaws.com", "ftp://example.com", "https://example.com?a=b"} {
+		os.Setenv(config.OIDCIssuerVar, invalid)
+		_, err = newOIDCIssuer()
+		assert.Error(t, err, "Expected error for issuer %s", invalid)
+	}
+
+	dir, err := ioutil.TempDir("", "oidc")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	key, err := rsa.GenerateKey(rand.Reader, oidcKeySize)
+	assert.NoError(t, err, "Unexpected error generating key")
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err, "Unexpected error encoding key")
+	keyFiles := map[string][]byte{
+		"pkcs1.pem": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"pkcs8.pem": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	}
+
+	os.Setenv(config.OIDCIssuerVar, testOIDCIssuer+"/")
+	for name, data := range keyFiles {
+		path := filepath.Join(dir, name)
+		err = ioutil.WriteFile(path, data, 0600)
+		assert.NoError(t, err, "Unexpected error writing key file")
+		os.Setenv(config.OIDCKeyFileVar, path)
+
+		issuer, err = newOIDCIssuer()
+		assert.NoError(t, err, "Unexpected error with key file %s", name)
+		assert.Equal(t, testOIDCIssuer, issuer.issuer, "Expected issuer without the trailing slash")
+		assert.Equal(t, key.N, issuer.key.N, "Expected the key from %s", name)
+	}
+
+	path := filepath.Join(dir, "invalid.pem")
+	err = ioutil.WriteFile(path, []byte("not a key"), 0600)
+	assert.NoError(t, err, "Unexpected error writing key file")
+	os.Setenv(config.OIDCKeyFileVar, path)
+	_, err = newOIDCIssuer()
+	assert.Error(t, err, "Expected error for an invalid key file")
+}
+
+func TestGetKeyThumbprint(t *testing.T) {
+	// the example in RFC 7638
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	assert.NoError(t, err, "Unexpected error decoding modulus")
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	thumbprint, err := getKeyThumbprint(key)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint, "Expected thumbprint to match")
+}
+
+func TestOIDCDiscoveryAndKeys(t *testing.T) {
+	issuer := newOIDCIssuerInTest(t)
+
+	recorder := httptest.NewRecorder()
+	ServeHTTP(issuer.getDiscoveryHandler())(recorder, httptest.NewRequest(http.MethodGet, config.OIDCDiscoveryPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected status code to match")
+	discovery := &OIDCDiscoveryResponse{}
+	err := json.Unmarshal(recorder.Body.Bytes(), discovery)
+	assert.NoError(t, err, "Unexpected error parsing discovery document")
+	assert.Equal(t, testOIDCIssuer, discovery.Issuer, "Expected issuer to match")
+	assert.Equal(t, testOIDCIssuer+"/oidc/keys", discovery.JWKSURI, "Expected JWKS URI to match")
+
+	recorder = httptest.NewRecorder()
+	ServeHTTP(issuer.getKeysHandler())(recorder, httptest.NewRequest(http.MethodGet, config.OIDCKeysPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected status code to match")
+	keys := &JWKSResponse{}
+	err = json.Unmarshal(recorder.Body.Bytes(), keys)
+	assert.NoError(t, err, "Unexpected error parsing keys")
+	assert.Len(t, keys.Keys, 1, "Expected one key")
+	assert.Equal(t, issuer.keyID, keys.Keys[0].KeyID, "Expected key ID to match")
+	assert.Equal(t, "AQAB", keys.Keys[0].Exponent, "Expected exponent to match")
+	modulus, err := base64.RawURLEncoding.DecodeString(keys.Keys[0].Modulus)
+	assert.NoError(t, err, "Unexpected error decoding modulus")
+	assert.Equal(t, issuer.key.N, new(big.Int).SetBytes(modulus), "Expected modulus to match")
+}
+
+func TestGetOIDCTokenHandler(t *testing.T) {
+	issuer := newOIDCIssuerInTest(t)
+	caller := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network1, "192.0.2.1").Get()
+
+	var testCases = []struct {
+		name             string
+		query            string
+		expectedSubject  string
+		expectedAudience string
+		expectedLifetime int64
+	}{
+		{
+			name:             "Defaults",
+			expectedSubject:  containerName1,
+			expectedAudience: defaultOIDCAudience,
+			expectedLifetime: temporaryCredentialsDurationInS,
+		},
+		{
+			name:             "Parameters",
+			query:            "?subject=system:serviceaccount:dev:app&audience=my-app&duration=900",
+			expectedSubject:  "system:serviceaccount:dev:app",
+			expectedAudience: "my-app",
+			expectedLifetime: 900,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			dockerMock := mock_docker.NewMockClient(ctrl)
+			dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{caller}, nil).AnyTimes()
+			service := &CredentialService{dockerClient: dockerMock}
+
+			recorder := httptest.NewRecorder()
+			ServeHTTP(service.getOIDCTokenHandler(issuer))(recorder, httptest.NewRequest(http.MethodGet, config.OIDCTokenPath+test.query, nil))
+			assert.Equal(t, http.StatusOK, recorder.Code, "Expected status code to match")
+
+			parts := strings.Split(recorder.Body.String(), ".")
+			assert.Len(t, parts, 3, "Expected a JSON Web Token")
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			assert.NoError(t, err, "Unexpected error decoding signature")
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			err = rsa.VerifyPKCS1v15(&issuer.key.PublicKey, crypto.SHA256, digest[:], signature)
+			assert.NoError(t, err, "Expected a valid signature")
+
+			header := make(map[string]string)
+			data, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			json.Unmarshal(data, &header)
+			assert.Equal(t, issuer.keyID, header["kid"], "Expected key ID to match")
+			assert.Equal(t, "RS256", header["alg"], "Expected algorithm to match")
+
+			var claims struct {
+				Issuer   string `json:"iss"`
+				Subject  string `json:"sub"`
+				Audience string `json:"aud"`
+				IssuedAt int64  `json:"iat"`
+				Expiry   int64  `json:"exp"`
+			}
+			data, _ = base64.RawURLEncoding.DecodeString(parts[1])
+			err = json.Unmarshal(data, &claims)
+			assert.NoError(t, err, "Unexpected error parsing claims")
+			assert.Equal(t, testOIDCIssuer, claims.Issuer, "Expected issuer to match")
+			assert.Equal(t, test.expectedSubject, claims.Subject, "Expected subject to match")
+			assert.Equal(t, test.expectedAudience, claims.Audience, "Expected audience to match")
+			assert.Equal(t, test.expectedLifetime, claims.Expiry-claims.IssuedAt, "Expected lifetime to match")
+			assert.InDelta(t, time.Now().Unix(), claims.IssuedAt, 5, "Expected the token to be issued now")
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// getPodIdentityHandler returns the handler which vends credentials for the role of the calling container in the
+// format of the EKS Pod Identity Agent, so that code which runs on both ECS and EKS can be tested with either flow
+func (service *CredentialService) getPodIdentityHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received pod identity credentials request")
+
+		container, role, serviceConfig, err := service.getContainerRole(r.Context(), getCallerIP(r))
+		if err != nil {
+			return err
+		}
+
+		opts, err := getAssumeRoleOptions(r)
+		if err != nil {
+			return err
+		}
+		applyServiceConfig(r, opts, serviceConfig)
+		service.setCallerContainer(r, opts, container)
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response, err := profileService.getRoleCredentialsByNameOrARN(role, opts)
+		if err != nil {
+			return err
+		}
+
+		service.auditContainerCredentials(r, response, container)
+		writeJSONResponse(w, &PodIdentityCredentialResponse{
+			AccessKeyID:     response.AccessKeyID,
+			SecretAccessKey: response.SecretAccessKey,
+			Token:           response.Token,
+			AccountID:       getAccountIDFromARN(response.RoleArn),
+			Expiration:      response.Expiration,
+		})
+		return nil
+	}
+}
+
+// withPodIdentityToken wraps a handler so that requests without a service account token in the Authorization header
+// are rejected, as the EKS Pod Identity Agent does; the token itself is only checked if ECS_LOCAL_AUTHORIZATION_TOKEN is set
+func (service *CredentialService) withPodIdentityToken(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Header.Get(authorizationHeader) == "" {
+			return HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("Missing '%s' header; set AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE in the container to the path of a file with a token", authorizationHeader),
+			}
+		}
+		return handler(w, r)
+	}
+}
+
+// ARNs have the format arn:<partition>:<service>:<region>:<account ID>:<resource>
+func getAccountIDFromARN(arn string) string {
+	split := strings.SplitN(arn, ":", 6)
+	if len(split) != 6 || split[0] != "arn" {
+		return ""
+	}
+	return split[4]
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPodIdentityToken(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("ok"))
+		return nil
+	}
+
+	var testCases = []struct {
+		name         string
+		token        string
+		header       string
+		expectedCode int
+	}{
+		{
+			name:         "AnyToken",
+			header:       "service-account-token",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Missing",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Valid",
+			token:        "secret",
+			header:       "secret",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Invalid",
+			token:        "secret",
+			header:       "service-account-token",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			service := &CredentialService{
+				authorizationToken: test.token,
+			}
+			request := httptest.NewRequest(http.MethodGet, config.PodIdentityCredentialsPath, nil)
+			if test.header != "" {
+				request.Header.Set(authorizationHeader, test.header)
+			}
+			recorder := httptest.NewRecorder()
+			ServeHTTP(service.withPodIdentityToken(service.withAuthorization(handler)))(recorder, request)
+			assert.Equal(t, test.expectedCode, recorder.Code, "Expected status code to match")
+		})
+	}
+}
+
+func TestGetAccountIDFromARN(t *testing.T) {
+	assert.Equal(t, "111111111111111", getAccountIDFromARN(roleARN), "Expected account ID to match")
+	assert.Equal(t, "222222222222", getAccountIDFromARN("arn:aws-cn:iam::222222222222:role/path/app"), "Expected account ID to match")
+	assert.Equal(t, "", getAccountIDFromARN(""), "Expected no account ID without an ARN")
+	assert.Equal(t, "", getAccountIDFromARN("clyde_task_role"), "Expected no account ID for a role name")
+}
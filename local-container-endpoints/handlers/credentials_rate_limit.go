@@ -0,0 +1,138 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+)
+
+const (
+	// defaultRateLimitBurst allows a container to make a few requests at once when it starts, such as one from each of its SDK clients
+	defaultRateLimitBurst = 10
+	// rateLimitPruneSize is the number of clients at which the buckets of clients which are no longer limited are removed
+	rateLimitPruneSize = 256
+)
+
+// rateLimiter limits the rate of requests from each client with a token bucket
+type rateLimiter struct {
+	lock    sync.Mutex
+	rate    float64
+	burst   float64
+	clients map[string]*rateLimitBucket
+	now     func() time.Time
+}
+
+type rateLimitBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter which allows the rate of requests per second from each client, or nil if the rate is 0
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: make(map[string]*rateLimitBucket),
+		now:     time.Now,
+	}
+}
+
+// allow returns true if the client can make a request now, or else how long it has to wait
+func (limiter *rateLimiter) allow(client string) (bool, time.Duration) {
+	if limiter == nil {
+		return true, 0
+	}
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	now := limiter.now()
+	bucket, ok := limiter.clients[client]
+	if !ok {
+		if len(limiter.clients) >= rateLimitPruneSize {
+			limiter.prune(now)
+		}
+		bucket = &rateLimitBucket{
+			tokens: limiter.burst,
+			last:   now,
+		}
+		limiter.clients[client] = bucket
+	}
+
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limiter.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune removes the buckets which would be full by now, since those clients are not limited
+func (limiter *rateLimiter) prune(now time.Time) {
+	for client, bucket := range limiter.clients {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.rate >= limiter.burst {
+			delete(limiter.clients, client)
+		}
+	}
+}
+
+// getRateLimiter returns the rate limiter for credentials requests, or nil if there is no rate limit
+func getRateLimiter() (*rateLimiter, error) {
+	value := os.Getenv(config.RateLimitVar)
+	if value == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		return nil, fmt.Errorf("Invalid %s: expected a number of requests per second, got %s", config.RateLimitVar, value)
+	}
+	burst := defaultRateLimitBurst
+	if value := os.Getenv(config.RateLimitBurstVar); value != "" {
+		burst, err = strconv.Atoi(value)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("Invalid %s: expected a number of requests of at least 1, got %s", config.RateLimitBurstVar, value)
+		}
+	}
+	return newRateLimiter(rate, burst), nil
+}
+
+// withRateLimit wraps a credentials handler with the rate limit for the client, which is identified by its IP address
+func (service *CredentialService) withRateLimit(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return service.rateLimiter.limit("credentials", handler)
+}
+
+// limit wraps a handler with the rate limit for the client; requests describes them in the error, such as credentials
+func (limiter *rateLimiter) limit(requests string, handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if ok, wait := limiter.allow(getCallerIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return HTTPError{
+				Code: http.StatusTooManyRequests,
+				Err:  fmt.Errorf("Rate exceeded for %s; %s can be requested %s times per second", getCallerIP(r), requests, strconv.FormatFloat(limiter.rate, 'f', -1, 64)),
+			}
+		}
+		return handler(w, r)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRateLimiter(t *testing.T) {
+	defer os.Unsetenv(config.RateLimitVar)
+	defer os.Unsetenv(config.RateLimitBurstVar)
+
+	limiter, err := getRateLimiter()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Nil(t, limiter, "Expected no rate limit by default")
+
+	os.Setenv(config.RateLimitVar, "0.5")
+	limiter, err = getRateLimiter()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.5, limiter.rate, "Expected rate to match")
+	assert.Equal(t, float64(defaultRateLimitBurst), limiter.burst, "Expected the default burst")
+
+	os.Setenv(config.RateLimitBurstVar, "3")
+	limiter, err = getRateLimiter()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, float64(3), limiter.burst, "Expected burst to match")
+
+	os.Setenv(config.RateLimitVar, "0")
+	limiter, err = getRateLimiter()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Nil(t, limiter, "Expected a rate of 0 to disable the rate limit")
+
+	os.Setenv(config.RateLimitBurstVar, "0")
+	os.Setenv(config.RateLimitVar, "2")
+	_, err = getRateLimiter()
+	assert.Error(t, err, "Expected error for a burst of 0")
+
+	os.Setenv(config.RateLimitVar, "fast")
+	_, err = getRateLimiter()
+	assert.Error(t, err, "Expected error for a rate which is not a number")
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(2, 3)
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.allow("172.17.0.2")
+		assert.True(t, ok, "Expected the burst to be allowed")
+	}
+	ok, wait := limiter.allow("172.17.0.2")
+	assert.False(t, ok, "Expected requests beyond the burst to be limited")
+	assert.Equal(t, 500*time.Millisecond, wait, "Expected to wait for the next token")
+
+	ok, _ = limiter.allow("172.17.0.3")
+	assert.True(t, ok, "Expected other clients to not be limited")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = limiter.allow("172.17.0.2")
+	assert.True(t, ok, "Expected a request to be allowed once a token was added")
+	ok, _ = limiter.allow("172.17.0.2")
+	assert.False(t, ok, "Expected the client to be limited again")
+
+	var nilLimiter *rateLimiter
+	ok, _ = nilLimiter.allow("172.17.0.2")
+	assert.True(t, ok, "Expected no limit without a rate limiter")
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(1, 1)
+	limiter.now = func() time.Time {
+		return now
+	}
+	limiter.allow("limited")
+	for i := 0; len(limiter.clients) < rateLimitPruneSize; i++ {
+		limiter.clients[string(rune('a'+i))] = &rateLimitBucket{tokens: 1, last: now}
+	}
+
+	limiter.allow("new")
+	assert.Len(t, limiter.clients, 2, "Expected only the limited client and the new client to be kept")
+	assert.Contains(t, limiter.clients, "limited", "Expected the limited client to be kept")
+}
+
+func TestWithRateLimit(t *testing.T) {
+	service := &CredentialService{
+		rateLimiter: newRateLimiter(1, 1),
+	}
+	handler := ServeHTTP(service.withRateLimit(func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("ok"))
+		return nil
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, config.TempCredentialsPath, nil)
+	request.RemoteAddr = "172.17.0.2:49152"
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected the first request to be allowed")
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "Expected the second request to be limited")
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"), "Expected the Retry-After header to match")
+	assert.Contains(t, recorder.Body.String(), "TooManyRequests", "Expected the error code in the response")
+}
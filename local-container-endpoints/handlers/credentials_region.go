@@ -0,0 +1,140 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/retry"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/useragent"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+)
+
+const (
+	regionQueryParameter = "region"
+
+	stsRegionalEndpointsVar = "AWS_STS_REGIONAL_ENDPOINTS"
+	stsRegionalEndpointsKey = "sts_regional_endpoints"
+	stsRegionalEndpoints    = "regional"
+
+	chinaPartition = "aws-cn"
+)
+
+// regionPattern matches region names, such as us-west-2 or us-gov-east-1; since the region is used in
+// endpoint host names, other values are rejected
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// useRegionalSTSEndpoints returns true if STS requests should be sent to the endpoint in the client's region,
+// instead of the global endpoint; as in the AWS SDKs, the environment variable takes precedence over the profile.
+// The SDK used by Local Endpoints predates this setting, so the endpoint is set explicitly.
+func useRegionalSTSEndpoints(profile sharedconfig.Section) bool {
+	if value := os.Getenv(stsRegionalEndpointsVar); value != "" {
+		return strings.EqualFold(value, stsRegionalEndpoints)
+	}
+	return strings.EqualFold(profile[stsRegionalEndpointsKey], stsRegionalEndpoints)
+}
+
+// useRegionalSTSEndpointsForProfile is useRegionalSTSEndpoints for a profile in the shared config files;
+// if profileName is empty, the current profile is used.
+func useRegionalSTSEndpointsForProfile(profileName string) bool {
+	if profileName == "" {
+		profileName = sharedconfig.CurrentProfileName()
+	}
+	var profile sharedconfig.Section
+	if config, err := sharedconfig.Load(); err == nil {
+		profile, _ = config.Profile(profileName)
+	}
+	return useRegionalSTSEndpoints(profile)
+}
+
+// getRegionalSTSEndpoint returns the URL of the STS endpoint in the region
+func getRegionalSTSEndpoint(region string) string {
+	dnsSuffix := "amazonaws.com"
+	if utils.GetPartition(region) == chinaPartition {
+		dnsSuffix = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("https://sts.%s.%s", region, dnsSuffix)
+}
+
+// checkPartition returns an error if the role is in a different partition from the region of the STS endpoint which
+// assumes it, such as a role in aws-us-gov with credentials for us-east-1, since AWS would reject the credentials.
+// region is the region of the request; if it is empty, the session's region is used.
+func (service *CredentialService) checkPartition(roleARN, region string) error {
+	// there is no session for mock and static credentials, and a custom endpoint can be in any partition
+	if service.currentSession == nil || getEndpointURL(stsEndpointURLVar) != "" {
+		return nil
+	}
+	if region == "" {
+		region = aws.StringValue(service.currentSession.Config.Region)
+	}
+	rolePartition := strings.SplitN(roleARN, ":", 3)[1]
+	if partition := utils.GetPartition(region); region != "" && partition != rolePartition {
+		return HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Role %s is in the %s partition, but STS is called in %s, which is in the %s partition; set the '%s' query parameter or AWS_REGION to a region of %s", roleARN, rolePartition, region, partition, regionQueryParameter, rolePartition),
+		}
+	}
+	return nil
+}
+
+// getRegionParameter returns the region in the request's region query parameter
+func getRegionParameter(r *http.Request) (string, error) {
+	region := r.URL.Query().Get(regionQueryParameter)
+	if region != "" && !regionPattern.MatchString(region) {
+		return "", HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Invalid '%s' query parameter: %s is not a region", regionQueryParameter, region),
+		}
+	}
+	return region, nil
+}
+
+// getSTSClient returns the client for STS requests in the region; if region is empty, the client for the session's region is used.
+// A region given explicitly always uses the regional endpoint, since the global endpoint is the same for every region,
+// unless a custom STS endpoint is set.
+func (service *CredentialService) getSTSClient(region string) stsiface.STSAPI {
+	if region == "" {
+		return service.stsClient
+	}
+
+	service.stsClientsLock.Lock()
+	defer service.stsClientsLock.Unlock()
+	if stsClient, ok := service.stsClients[region]; ok {
+		return stsClient
+	}
+	if service.currentSession == nil {
+		// the clients were not created from a session, for example for static credentials
+		return service.stsClient
+	}
+
+	client := sts.New(service.currentSession, &aws.Config{
+		Region:   aws.String(region),
+		Endpoint: aws.String(getSTSEndpoint(region, true)),
+	})
+	client.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	stsClient := retry.NewSTSClient(client, retry.NewRetryer(service.maxRetries))
+	if service.stsClients == nil {
+		service.stsClients = make(map[string]stsiface.STSAPI)
+	}
+	service.stsClients[region] = stsClient
+	return stsClient
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRegionalSTSEndpoint(t *testing.T) {
+	assert.Equal(t, "https://sts.us-west-2.amazonaws.com", getRegionalSTSEndpoint("us-west-2"), "Expected endpoint to match")
+	assert.Equal(t, "https://sts.cn-north-1.amazonaws.com.cn", getRegionalSTSEndpoint("cn-north-1"), "Expected China endpoint to match")
+}
+
+func TestGetRegionParameter(t *testing.T) {
+	var testCases = []struct {
+		query    string
+		expected string
+		isError  bool
+	}{
+		{"", "", false},
+		{"?region=us-west-2", "us-west-2", false},
+		{"?region=us-gov-east-1", "us-gov-east-1", false},
+		{"?region=evil.example.com", "", true},
+		{"?region=us-west", "", true},
+	}
+
+	for _, testCase := range testCases {
+		request, _ := http.NewRequest("GET", "/role/"+roleName+testCase.query, nil)
+		region, err := getRegionParameter(request)
+		if testCase.isError {
+			assert.Error(t, err, "Expected error for query %s", testCase.query)
+			httpErr, ok := err.(HTTPError)
+			assert.True(t, ok, "Expected an HTTPError")
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code, "Expected bad request")
+		} else {
+			assert.NoError(t, err, "Unexpected error for query %s", testCase.query)
+			assert.Equal(t, testCase.expected, region, "Expected region to match for query %s", testCase.query)
+		}
+	}
+}
+
+func TestUseRegionalSTSEndpoints(t *testing.T) {
+	defer os.Unsetenv(stsRegionalEndpointsVar)
+	regionalProfile := sharedconfig.Section{stsRegionalEndpointsKey: "regional"}
+	legacyProfile := sharedconfig.Section{stsRegionalEndpointsKey: "legacy"}
+
+	os.Unsetenv(stsRegionalEndpointsVar)
+	assert.False(t, useRegionalSTSEndpoints(nil), "Expected the global endpoint by default")
+	assert.True(t, useRegionalSTSEndpoints(regionalProfile), "Expected the profile to enable regional endpoints")
+	assert.False(t, useRegionalSTSEndpoints(legacyProfile), "Expected the global endpoint for legacy profiles")
+
+	os.Setenv(stsRegionalEndpointsVar, "legacy")
+	assert.False(t, useRegionalSTSEndpoints(regionalProfile), "Expected the environment to take precedence over the profile")
+
+	os.Setenv(stsRegionalEndpointsVar, "Regional")
+	assert.True(t, useRegionalSTSEndpoints(legacyProfile), "Expected the environment to take precedence over the profile")
+}
+
+func TestUseRegionalSTSEndpointsForProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials-region")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(configFile, []byte("[profile regional]\nsts_regional_endpoints = regional\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing config file")
+	os.Setenv("AWS_CONFIG_FILE", configFile)
+	defer os.Unsetenv("AWS_CONFIG_FILE")
+	os.Unsetenv(stsRegionalEndpointsVar)
+
+	assert.True(t, useRegionalSTSEndpointsForProfile("regional"), "Expected regional endpoints for the profile")
+	assert.False(t, useRegionalSTSEndpointsForProfile("missing"), "Expected the global endpoint for a missing profile")
+}
+
+func TestGetRoleCredentialsWithRegion(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	regionalSTSMock := mock_stsiface.NewMockSTSAPI(gomock.NewController(t))
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.stsClients = map[string]stsiface.STSAPI{
+		"eu-west-1": regionalSTSMock,
+	}
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		regionalSTSMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	response, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{
+		region: "eu-west-1",
+	})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	assert.Equal(t, accessKey, response.AccessKeyID, "Expected access key to match")
+}
+
+func TestGetRoleCredentialsByARNInOtherPartition(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.currentSession = session.Must(session.NewSession(&aws.Config{
+		Region: aws.String("us-east-1"),
+	}))
+
+	_, err := credsService.getRoleCredentialsByARN("arn:aws-us-gov:iam::111111111111:role/"+roleName, &assumeRoleOptions{})
+	assert.Error(t, err, "Expected error for a role in another partition")
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code, "Expected bad request")
+	assert.Contains(t, err.Error(), "is in the aws-us-gov partition, but STS is called in us-east-1", "Expected error message to match")
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	credsService.stsClients = map[string]stsiface.STSAPI{
+		"us-gov-west-1": stsMock,
+	}
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(&sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil)
+	response, err := credsService.getRoleCredentialsByARN("arn:aws-us-gov:iam::111111111111:role/"+roleName, &assumeRoleOptions{region: "us-gov-west-1"})
+	assert.NoError(t, err, "Unexpected error with a region in the role's partition")
+	assert.Equal(t, accessKey, response.AccessKeyID, "Expected access key to match")
+}
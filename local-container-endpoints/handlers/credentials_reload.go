@@ -0,0 +1,149 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sso"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/sirupsen/logrus"
+)
+
+// WatchSharedConfigFiles polls the AWS shared config and credentials files, and the SSO token cache,
+// and creates new sessions when they change so that rotated keys and new SSO logins are used.
+// It returns once ctx is done.
+func (service *CredentialService) WatchSharedConfigFiles(ctx context.Context) {
+	interval, _ := time.ParseDuration(config.SharedConfigPollDuration)
+	paths, err := getWatchedPaths()
+	if err != nil {
+		logrus.Warnf("Failed to find the AWS shared config files; they will not be reloaded: %v", err)
+		return
+	}
+
+	state := getPathsState(paths)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		newState := getPathsState(paths)
+		if newState == state {
+			continue
+		}
+		state = newState
+		logrus.Info("The AWS shared config files changed; reloading credentials")
+		if err := service.reload(); err != nil {
+			logrus.Errorf("Failed to reload credentials, the previous credentials will continue to be used: %v", err)
+		}
+	}
+}
+
+// ReloadConfig replaces the settings of the Docker Compose services and the allowed and denied roles,
+// which are read from the environment again; the cached credentials are kept, but are not vended for roles
+// which are no longer allowed
+func (service *CredentialService) ReloadConfig(services map[string]configfile.Service) error {
+	if err := validateServices(services); err != nil {
+		return err
+	}
+	roles, err := newRoleFilter()
+	if err != nil {
+		return err
+	}
+
+	service.profileLock.Lock()
+	defer service.profileLock.Unlock()
+	service.setSettings(services, roles)
+	if service.reloaded != nil {
+		service.reloaded.setSettings(services, roles)
+	}
+	for _, profileService := range service.profileServices {
+		profileService.setSettings(services, roles)
+	}
+	return nil
+}
+
+func (service *CredentialService) getSettings() (map[string]configfile.Service, *roleFilter) {
+	service.settingsLock.RLock()
+	defer service.settingsLock.RUnlock()
+	return service.services, service.roles
+}
+
+func (service *CredentialService) setSettings(services map[string]configfile.Service, roles *roleFilter) {
+	service.settingsLock.Lock()
+	defer service.settingsLock.Unlock()
+	service.services = services
+	service.roles = roles
+}
+
+// reload replaces the default clients and discards the clients for other profiles, along with their cached credentials
+func (service *CredentialService) reload() error {
+	iamClient, stsClient, sess, err := service.newProfileClients("")
+	if err != nil {
+		return err
+	}
+
+	// the child is created with the lock held, so that it has the settings of a concurrent ReloadConfig
+	service.profileLock.Lock()
+	defer service.profileLock.Unlock()
+	service.reloaded = service.newChildService(iamClient, stsClient, sess)
+	service.profileServices = make(map[string]*CredentialService)
+	return nil
+}
+
+func getWatchedPaths() ([]string, error) {
+	paths, err := sharedconfig.Files()
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := sso.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return append(paths, cacheDir), nil
+}
+
+// getPathsState returns a summary of the size and modification time of the files, and of the files in the directories,
+// which changes when any of them are written; paths which do not exist are included, so that creating them is a change.
+func getPathsState(paths []string) string {
+	var state string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			state += fmt.Sprintf("%s:missing;", path)
+			continue
+		}
+		state += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		if !info.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			state += fmt.Sprintf("%s:%d:%d;", file.Name(), file.Size(), file.ModTime().UnixNano())
+		}
+	}
+	return state
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPathsState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials-reload")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	credentialsFile := filepath.Join(dir, "credentials")
+	cacheDir := filepath.Join(dir, "cache")
+	paths := []string{credentialsFile, cacheDir}
+
+	state := getPathsState(paths)
+	assert.Equal(t, state, getPathsState(paths), "Expected state to be the same when nothing changed")
+
+	err = ioutil.WriteFile(credentialsFile, []byte("[default]\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing credentials file")
+	created := getPathsState(paths)
+	assert.NotEqual(t, state, created, "Expected creating a file to change the state")
+
+	err = ioutil.WriteFile(credentialsFile, []byte("[default]\naws_access_key_id = AKID\n"), 0600)
+	assert.NoError(t, err, "Unexpected error writing credentials file")
+	modified := getPathsState(paths)
+	assert.NotEqual(t, created, modified, "Expected modifying a file to change the state")
+
+	err = os.Mkdir(cacheDir, 0700)
+	assert.NoError(t, err, "Unexpected error creating cache dir")
+	withDir := getPathsState(paths)
+	// the modification time of the cache file is set explicitly, since it can be within the same tick as the directory's
+	cacheFile := filepath.Join(cacheDir, "token.json")
+	err = ioutil.WriteFile(cacheFile, []byte("{}"), 0600)
+	assert.NoError(t, err, "Unexpected error writing cache file")
+	err = os.Chtimes(cacheFile, time.Now(), time.Now().Add(time.Hour))
+	assert.NoError(t, err, "Unexpected error setting modification time")
+	assert.NotEqual(t, withDir, getPathsState(paths), "Expected a file in a watched directory to change the state")
+}
+
+func TestReload(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	reloadedIAMMock, reloadedSTSMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.profileServices = map[string]*CredentialService{
+		"dev": newCredentialServiceInTest(iamMock, stsMock),
+	}
+	credsService.newProfileClients = func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+		assert.Equal(t, "", profileName, "Expected the default clients to be reloaded")
+		return reloadedIAMMock, reloadedSTSMock, nil, nil
+	}
+
+	err := credsService.reload()
+	assert.NoError(t, err, "Unexpected error reloading")
+	assert.Empty(t, credsService.profileServices, "Expected the services for other profiles to be discarded")
+
+	actual, err := credsService.getProfileService(httptest.NewRequest("GET", "/creds", nil))
+	assert.NoError(t, err, "Unexpected error getting service")
+	assert.Equal(t, reloadedIAMMock, actual.iamClient, "Expected the reloaded IAM client")
+	assert.Equal(t, reloadedSTSMock, actual.stsClient, "Expected the reloaded STS client")
+}
+
+func TestReloadConfig(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	defer os.Unsetenv(config.DeniedRolesVar)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.profileServices = map[string]*CredentialService{
+		"dev": newCredentialServiceInTest(iamMock, stsMock),
+	}
+	credsService.reloaded = newCredentialServiceInTest(iamMock, stsMock)
+
+	services := map[string]configfile.Service{
+		"app": configfile.Service{
+			Role: "app-role",
+		},
+	}
+	os.Setenv(config.DeniedRolesVar, "*admin*")
+	err := credsService.ReloadConfig(services)
+	assert.NoError(t, err, "Unexpected error reloading config")
+	for _, service := range []*CredentialService{credsService, credsService.reloaded, credsService.profileServices["dev"]} {
+		actualServices, roles := service.getSettings()
+		assert.Equal(t, services, actualServices, "Expected the services to be replaced")
+		assert.Error(t, roles.check("arn:aws:iam::111111111111:role/admin", "admin"), "Expected the denied roles to be replaced")
+	}
+
+	err = credsService.ReloadConfig(map[string]configfile.Service{
+		"app": configfile.Service{
+			Duration: 60,
+		},
+	})
+	assert.Error(t, err, "Expected error for an invalid duration")
+	actualServices, _ := credsService.getSettings()
+	assert.Equal(t, services, actualServices, "Expected the previous services to be kept")
+
+	os.Setenv(config.DeniedRolesVar, "regex:(")
+	err = credsService.ReloadConfig(services)
+	assert.Error(t, err, "Expected error for an invalid pattern")
+}
+
+func TestReloadConfigDeniesCachedRole(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	defer os.Unsetenv(config.DeniedRolesVar)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	expiration := time.Now().Add(time.Hour)
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn: aws.String(roleARN),
+		},
+	}, nil).Times(1)
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(&sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil).Times(2)
+
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+	_, err = credsService.getRoleCredentialsByARN(crossAccountRoleARN, &assumeRoleOptions{})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentialsByARN")
+
+	// the credentials of both roles are cached, but are no longer vended once the roles are denied
+	os.Setenv(config.DeniedRolesVar, "regex:^arn:aws:iam::(111111111111111|222222222222):role/.*")
+	err = credsService.ReloadConfig(nil)
+	assert.NoError(t, err, "Unexpected error reloading config")
+
+	_, err = credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
+	assert.Error(t, err, "Expected error for a cached role which is now denied")
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusForbidden, httpErr.Code, "Expected status code to match")
+
+	_, err = credsService.getRoleCredentialsByARN(crossAccountRoleARN, &assumeRoleOptions{})
+	assert.Error(t, err, "Expected error for a cached role ARN which is now denied")
+	httpErr, ok = err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusForbidden, httpErr.Code, "Expected status code to match")
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+)
+
+// regexPrefix marks patterns in the role lists which are regular expressions instead of globs
+const regexPrefix = "regex:"
+
+// roleFilter restricts the roles which Local Endpoints assumes
+type roleFilter struct {
+	allowed []*regexp.Regexp
+	denied  []*regexp.Regexp
+}
+
+// newRoleFilter returns the filter for the allowed and denied roles in the environment
+func newRoleFilter() (*roleFilter, error) {
+	allowed, err := parseRolePatterns(config.AllowedRolesVar)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := parseRolePatterns(config.DeniedRolesVar)
+	if err != nil {
+		return nil, err
+	}
+	return &roleFilter{
+		allowed: allowed,
+		denied:  denied,
+	}, nil
+}
+
+// parseRolePatterns parses the comma separated list of patterns in the environment variable
+func parseRolePatterns(envVar string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, value := range strings.Split(os.Getenv(envVar), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		pattern, err := compileRolePattern(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid pattern %s in %s", value, envVar)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// compileRolePattern compiles a glob, where '*' matches any characters including '/', or a regular expression with the regex prefix.
+// Both must match the whole role name or ARN.
+func compileRolePattern(value string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(value, regexPrefix) {
+		return regexp.Compile(fmt.Sprintf("^(?:%s)$", strings.TrimPrefix(value, regexPrefix)))
+	}
+	expression := regexp.QuoteMeta(value)
+	expression = strings.Replace(expression, `\*`, ".*", -1)
+	expression = strings.Replace(expression, `\?`, ".", -1)
+	return regexp.Compile("^" + expression + "$")
+}
+
+// check returns a 403 error if the role is denied, or if there is an allow list and the role is not in it.
+// Patterns are matched against both the role name and the role ARN, and the deny list takes precedence.
+func (filter *roleFilter) check(roleARN, roleName string) error {
+	if filter == nil {
+		return nil
+	}
+	if matchesRole(filter.denied, roleARN, roleName) {
+		return HTTPError{
+			Code: http.StatusForbidden,
+			Err:  fmt.Errorf("Role %s is denied by %s", roleARN, config.DeniedRolesVar),
+		}
+	}
+	if len(filter.allowed) > 0 && !matchesRole(filter.allowed, roleARN, roleName) {
+		return HTTPError{
+			Code: http.StatusForbidden,
+			Err:  fmt.Errorf("Role %s is not allowed by %s", roleARN, config.AllowedRolesVar),
+		}
+	}
+	return nil
+}
+
+// checkName checks a role requested by name before its ARN is known, so that iam:GetRole is not called for denied roles;
+// the allowed roles are only checked with the ARN, since their patterns may match it instead of the name
+func (filter *roleFilter) checkName(roleName string) error {
+	if filter == nil || !matchesRole(filter.denied, roleName, roleName) {
+		return nil
+	}
+	return HTTPError{
+		Code: http.StatusForbidden,
+		Err:  fmt.Errorf("Role %s is denied by %s", roleName, config.DeniedRolesVar),
+	}
+}
+
+func matchesRole(patterns []*regexp.Regexp, roleARN, roleName string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(roleARN) || pattern.MatchString(roleName) {
+			return true
+		}
+	}
+	return false
+}
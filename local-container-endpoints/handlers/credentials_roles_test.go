@@ -0,0 +1,92 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleFilter(t *testing.T) {
+	defer os.Unsetenv(config.AllowedRolesVar)
+	defer os.Unsetenv(config.DeniedRolesVar)
+
+	var testCases = []struct {
+		name     string
+		allowed  string
+		denied   string
+		roleARN  string
+		roleName string
+		isError  bool
+	}{
+		{"no lists", "", "", "arn:aws:iam::111111111111:role/admin", "admin", false},
+		{"allowed by name", "dev-*", "", "arn:aws:iam::111111111111:role/dev-app", "dev-app", false},
+		{"allowed by ARN", "arn:aws:iam::111111111111:role/*", "", "arn:aws:iam::111111111111:role/path/app", "app", false},
+		{"not allowed", "dev-*, test-?", "", "arn:aws:iam::111111111111:role/prod-app", "prod-app", true},
+		{"single character glob", "test-?", "", "arn:aws:iam::111111111111:role/test-1", "test-1", false},
+		{"glob must match the whole name", "dev", "", "arn:aws:iam::111111111111:role/dev-app", "dev-app", true},
+		{"denied", "", "*admin*", "arn:aws:iam::111111111111:role/cluster-admin", "cluster-admin", true},
+		{"deny takes precedence", "*", "*admin*", "arn:aws:iam::111111111111:role/admin", "admin", true},
+		{"allowed by regex", `regex:arn:aws:iam::(111111111111|222222222222):role/.+`, "", "arn:aws:iam::222222222222:role/app", "app", false},
+		{"denied by regex", "", `regex:.*:role/prod-[a-z]+`, "arn:aws:iam::111111111111:role/prod-app", "prod-app", true},
+		{"glob special characters are literal", "dev.app", "", "arn:aws:iam::111111111111:role/devxapp", "devxapp", true},
+	}
+
+	for _, testCase := range testCases {
+		os.Setenv(config.AllowedRolesVar, testCase.allowed)
+		os.Setenv(config.DeniedRolesVar, testCase.denied)
+		filter, err := newRoleFilter()
+		assert.NoError(t, err, "Unexpected error creating role filter for %s", testCase.name)
+
+		err = filter.check(testCase.roleARN, testCase.roleName)
+		if testCase.isError {
+			assert.Error(t, err, "Expected error for %s", testCase.name)
+			httpErr, ok := err.(HTTPError)
+			assert.True(t, ok, "Expected an HTTPError for %s", testCase.name)
+			assert.Equal(t, http.StatusForbidden, httpErr.Code, "Expected forbidden for %s", testCase.name)
+		} else {
+			assert.NoError(t, err, "Unexpected error for %s", testCase.name)
+		}
+	}
+}
+
+func TestNewRoleFilterInvalidRegex(t *testing.T) {
+	os.Setenv(config.DeniedRolesVar, "regex:prod-(")
+	defer os.Unsetenv(config.DeniedRolesVar)
+
+	_, err := newRoleFilter()
+	assert.Error(t, err, "Expected error for an invalid regular expression")
+}
+
+func TestGetRoleCredentialsDenied(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	os.Setenv(config.DeniedRolesVar, roleName)
+	defer os.Unsetenv(config.DeniedRolesVar)
+	filter, err := newRoleFilter()
+	assert.NoError(t, err, "Unexpected error creating role filter")
+	credsService.roles = filter
+
+	// neither iam:GetRole nor sts:AssumeRole is called
+	_, err = credsService.getRoleCredentials(roleName, &assumeRoleOptions{})
+	assert.Error(t, err, "Expected error for a denied role")
+
+	_, err = credsService.getRoleCredentialsByARN(roleARN, &assumeRoleOptions{})
+	assert.Error(t, err, "Expected error for a denied role ARN")
+}
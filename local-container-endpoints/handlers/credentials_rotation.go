@@ -0,0 +1,40 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+)
+
+const (
+	// credentials can't be rotated more often than this, to limit the number of requests to STS
+	minRotationInterval = time.Minute
+)
+
+// getRotationInterval returns the max lifetime of credentials in rotation mode, or 0 if rotation mode is not enabled
+func getRotationInterval() (time.Duration, error) {
+	value := os.Getenv(config.RotationIntervalVar)
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < minRotationInterval {
+		return 0, fmt.Errorf("Invalid %s: %s is not a duration of at least %s, such as 3m", config.RotationIntervalVar, value, minRotationInterval)
+	}
+	return interval, nil
+}
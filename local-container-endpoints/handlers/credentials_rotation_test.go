@@ -0,0 +1,93 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRotationInterval(t *testing.T) {
+	defer os.Unsetenv(config.RotationIntervalVar)
+
+	os.Unsetenv(config.RotationIntervalVar)
+	interval, err := getRotationInterval()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, time.Duration(0), interval, "Expected rotation mode to be disabled by default")
+
+	os.Setenv(config.RotationIntervalVar, "3m")
+	interval, err = getRotationInterval()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3*time.Minute, interval, "Expected interval to match")
+
+	os.Setenv(config.RotationIntervalVar, "10s")
+	_, err = getRotationInterval()
+	assert.Error(t, err, "Expected error for an interval which is too short")
+
+	os.Setenv(config.RotationIntervalVar, "180")
+	_, err = getRotationInterval()
+	assert.Error(t, err, "Expected error for an interval without units")
+}
+
+func TestCredentialsCacheRotation(t *testing.T) {
+	cache := newCredentialsCache()
+	cache.maxLifetime = 2 * time.Minute
+
+	fetches := 0
+	fetch := func() (*CredentialResponse, error) {
+		fetches++
+		return &CredentialResponse{
+			AccessKeyID: accessKey,
+			Expiration:  time.Now().Add(time.Hour).Format(CredentialExpirationTimeFormat),
+		}, nil
+	}
+
+	response, err := cache.get("role", fetch)
+	assert.NoError(t, err, "Unexpected error")
+	expiration, err := time.Parse(CredentialExpirationTimeFormat, response.Expiration)
+	assert.NoError(t, err, "Unexpected error parsing expiration")
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), expiration, 2*time.Second, "Expected the lifetime to be limited")
+
+	_, err = cache.get("role", fetch)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1, fetches, "Expected the credentials to be cached")
+
+	assert.WithinDuration(t, time.Now().Add(time.Minute), cache.entries["role"].refreshAt, 2*time.Second, "Expected refresh after half of the limited lifetime")
+	cache.entries["role"].refreshAt = time.Now().Add(-time.Second)
+	_, err = cache.get("role", fetch)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2, fetches, "Expected the credentials to be rotated")
+}
+
+func TestLimitLifetime(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	response := &CredentialResponse{
+		Expiration: now.Add(time.Minute).Format(CredentialExpirationTimeFormat),
+	}
+
+	cache := newCredentialsCache()
+	assert.Equal(t, response, cache.limitLifetime(response, now), "Expected no limit when rotation mode is disabled")
+
+	cache.maxLifetime = 5 * time.Minute
+	assert.Equal(t, response, cache.limitLifetime(response, now), "Expected credentials which expire sooner to be unchanged")
+
+	cache.maxLifetime = 30 * time.Second
+	limited := cache.limitLifetime(response, now)
+	assert.Equal(t, "2019-03-01T12:00:30Z", limited.Expiration, "Expected the expiration to be limited")
+	assert.Equal(t, now.Add(time.Minute).Format(CredentialExpirationTimeFormat), response.Expiration, "Expected the original response to be unchanged")
+}
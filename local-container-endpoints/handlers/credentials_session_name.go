@@ -0,0 +1,122 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSessionNameTemplate = "ecs-local-{role}"
+
+	sessionNameRolePlaceholder      = "{role}"
+	sessionNameContainerPlaceholder = "{container}"
+	sessionNameUserPlaceholder      = "{user}"
+
+	// used in place of values which can't be found, such as the container of a request from the host
+	unknownSessionNameValue = "unknown"
+)
+
+var (
+	// role session names may only contain these characters; others are replaced with '-'
+	invalidSessionNameCharacters = regexp.MustCompile(`[^\w+=,.@-]`)
+	sessionNamePlaceholders      = regexp.MustCompile(`\{[^{}]*\}`)
+)
+
+// getSessionNameTemplate returns the template for role session names, and checks that its placeholders are known
+func getSessionNameTemplate() (string, error) {
+	template := utils.GetValue(defaultSessionNameTemplate, config.SessionNameVar)
+	for _, placeholder := range sessionNamePlaceholders.FindAllString(template, -1) {
+		switch placeholder {
+		case sessionNameRolePlaceholder, sessionNameContainerPlaceholder, sessionNameUserPlaceholder:
+		default:
+			return "", fmt.Errorf("Invalid %s: unknown placeholder %s; expected %s, %s or %s", config.SessionNameVar, placeholder, sessionNameRolePlaceholder, sessionNameContainerPlaceholder, sessionNameUserPlaceholder)
+		}
+	}
+	if rest := sessionNamePlaceholders.ReplaceAllString(template, ""); invalidSessionNameCharacters.MatchString(rest) {
+		return "", fmt.Errorf("Invalid %s: %s may only contain letters, digits, placeholders and the characters +=,.@_-", config.SessionNameVar, template)
+	}
+	return template, nil
+}
+
+// setCallerContainer sets the name of the container which made the request in the options, if the session name template uses it;
+// if container is nil, it is looked up by the request's IP address.
+func (service *CredentialService) setCallerContainer(r *http.Request, opts *assumeRoleOptions, container *types.Container) {
+	if !strings.Contains(service.getSessionNameTemplate(), sessionNameContainerPlaceholder) {
+		return
+	}
+	if container == nil {
+		container = service.findCallerContainer(r.Context(), getCallerIP(r))
+	}
+	if container != nil {
+		opts.container = getContainerName(container)
+	}
+}
+
+// getSessionName returns the role session name from the template
+func (service *CredentialService) getSessionName(roleName string, opts *assumeRoleOptions) string {
+	template := service.getSessionNameTemplate()
+	sessionName := strings.Replace(template, sessionNameRolePlaceholder, roleName, -1)
+	if strings.Contains(sessionName, sessionNameContainerPlaceholder) {
+		sessionName = strings.Replace(sessionName, sessionNameContainerPlaceholder, valueOrUnknown(opts.container), -1)
+	}
+	if strings.Contains(sessionName, sessionNameUserPlaceholder) {
+		sessionName = strings.Replace(sessionName, sessionNameUserPlaceholder, valueOrUnknown(service.getSessionUser(opts.ctx)), -1)
+	}
+	sessionName = invalidSessionNameCharacters.ReplaceAllString(sessionName, "-")
+	return utils.Truncate(sessionName, roleSessionNameLength)
+}
+
+func (service *CredentialService) getSessionNameTemplate() string {
+	if service.sessionNameTemplate == "" {
+		return defaultSessionNameTemplate
+	}
+	return service.sessionNameTemplate
+}
+
+// getSessionUser returns the name of the IAM identity of the base credentials, from sts:GetCallerIdentity;
+// it is only looked up once, since the base credentials of a service don't change.
+func (service *CredentialService) getSessionUser(ctx context.Context) string {
+	service.sessionUserLock.Lock()
+	defer service.sessionUserLock.Unlock()
+	if service.sessionUser != "" {
+		return service.sessionUser
+	}
+
+	identity, err := getCallerIdentity(ctx, service.stsClient)
+	if err != nil {
+		logrus.Warnf("Failed to find the user for the role session name: %s", err)
+		return ""
+	}
+	// arn:aws:iam::111111111111:user/me or arn:aws:sts::111111111111:assumed-role/role/session => me or session
+	split := strings.Split(identity.Arn, "/")
+	service.sessionUser = split[len(split)-1]
+	return service.sessionUser
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return unknownSessionNameValue
+	}
+	return value
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSessionNameTemplate(t *testing.T) {
+	defer os.Unsetenv(config.SessionNameVar)
+
+	os.Unsetenv(config.SessionNameVar)
+	template, err := getSessionNameTemplate()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, defaultSessionNameTemplate, template, "Expected the default template")
+
+	os.Setenv(config.SessionNameVar, "ecs-local-{container}-{role}-{user}")
+	template, err = getSessionNameTemplate()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "ecs-local-{container}-{role}-{user}", template, "Expected template to match")
+
+	os.Setenv(config.SessionNameVar, "ecs-local-{service}")
+	_, err = getSessionNameTemplate()
+	assert.Error(t, err, "Expected error for an unknown placeholder")
+
+	os.Setenv(config.SessionNameVar, "ecs local {role}")
+	_, err = getSessionNameTemplate()
+	assert.Error(t, err, "Expected error for invalid characters")
+}
+
+func TestGetSessionName(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	assert.Equal(t, "ecs-local-"+roleName, credsService.getSessionName(roleName, &assumeRoleOptions{}), "Expected the default session name")
+
+	credsService.sessionNameTemplate = "ecs-local-{container}-{role}-{user}"
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("111111111111"),
+		Arn:     aws.String("arn:aws:iam::111111111111:user/jane.doe"),
+		UserId:  aws.String("AIDAEXAMPLE"),
+	}, nil).Times(1)
+
+	assert.Equal(t, "ecs-local-web-"+roleName+"-jane.doe", credsService.getSessionName(roleName, &assumeRoleOptions{container: "web"}), "Expected session name to match")
+	assert.Equal(t, "ecs-local-unknown-"+roleName+"-jane.doe", credsService.getSessionName(roleName, &assumeRoleOptions{}), "Expected the unknown container")
+
+	long := credsService.getSessionName(strings.Repeat("a", 64), &assumeRoleOptions{container: "my app"})
+	assert.Len(t, long, roleSessionNameLength, "Expected the session name to be truncated")
+	assert.True(t, strings.HasPrefix(long, "ecs-local-my-app-"), "Expected invalid characters to be replaced")
+}
+
+func TestSetCallerContainer(t *testing.T) {
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+	credsService := NewCredentialServiceWithClients(nil, nil, dockerMock, nil)
+
+	request := httptest.NewRequest("GET", config.TempCredentialsPath, nil)
+	request.RemoteAddr = "172.17.0.2:4000"
+
+	// the container is not looked up if the template doesn't use it
+	opts := &assumeRoleOptions{}
+	credsService.setCallerContainer(request, opts, nil)
+	assert.Empty(t, opts.container, "Expected no container")
+
+	credsService.sessionNameTemplate = "ecs-local-{container}-{role}"
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{
+		testingutils.BaseDockerContainer("web", "c1a7").WithNetwork("bridge", "172.17.0.2").Get(),
+		testingutils.BaseDockerContainer("db", "d3e9").WithNetwork("bridge", "172.17.0.3").Get(),
+	}, nil)
+	credsService.setCallerContainer(request, opts, nil)
+	assert.Equal(t, "web", opts.container, "Expected the calling container")
+
+	// a known container is used without listing containers
+	opts = &assumeRoleOptions{}
+	credsService.setCallerContainer(request, opts, &types.Container{Names: []string{"/db"}})
+	assert.Equal(t, "db", opts.container, "Expected the given container")
+}
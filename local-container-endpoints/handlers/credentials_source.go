@@ -0,0 +1,291 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/credentialprocess"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sso"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/useragent"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/webidentity"
+	"github.com/pkg/errors"
+)
+
+const (
+	accessKeyIDKey          = "aws_access_key_id"
+	secretAccessKeyKey      = "aws_secret_access_key"
+	sessionTokenKey         = "aws_session_token"
+	regionKey               = "region"
+	roleARNKey              = "role_arn"
+	sourceProfileKey        = "source_profile"
+	credentialSourceKey     = "credential_source"
+	externalIDKey           = "external_id"
+	mfaSerialKey            = "mfa_serial"
+	durationSecondsKey      = "duration_seconds"
+	roleSessionNameKey      = "role_session_name"
+	credentialProcessKey    = "credential_process"
+	webIdentityTokenFileKey = "web_identity_token_file"
+
+	environmentCredentialSource = "Environment"
+
+	roleARNVar              = "AWS_ROLE_ARN"
+	roleSessionNameVar      = "AWS_ROLE_SESSION_NAME"
+	webIdentityTokenFileVar = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	regionVar               = "AWS_REGION"
+	defaultRegionVar        = "AWS_DEFAULT_REGION"
+
+	// STS has a global endpoint, so any region works if none is configured
+	defaultSTSRegion = "us-east-1"
+)
+
+// newSourceCredentials returns the credentials of the Vault role, or of the current profile if it uses a credential source which
+// is not supported by the AWS SDK, or which does not work in the Local Endpoints image, along with the region to use.
+// Otherwise it returns nil, and the SDK resolves the credentials from its default chain.
+func newSourceCredentials() (*credentials.Credentials, string, error) {
+	// Vault is only used if it is configured, so it takes precedence over the other sources
+	if creds, region, err := newVaultCredentials(); creds != nil || err != nil {
+		return creds, region, err
+	}
+
+	// credentials in the environment take precedence over the shared config files, as they do in the SDK
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_ACCESS_KEY") != "" {
+		return nil, "", nil
+	}
+
+	config, err := sharedconfig.Load()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read the AWS shared config files")
+	}
+	profileName := sharedconfig.CurrentProfileName()
+	region := getRegion(config, profileName)
+
+	if roleARN, tokenFile := os.Getenv(roleARNVar), os.Getenv(webIdentityTokenFileVar); roleARN != "" && tokenFile != "" {
+		profile, _ := config.Profile(profileName)
+		creds, err := newWebIdentityCredentials(roleARN, os.Getenv(roleSessionNameVar), tokenFile, region, useRegionalSTSEndpoints(profile))
+		return creds, region, err
+	}
+
+	profile, ok := config.Profile(profileName)
+	if !ok || !needsResolution(config, profileName, profile) {
+		return nil, "", nil
+	}
+
+	creds, err := resolveProfileCredentials(config, profileName, region, make(map[string]bool))
+	if err != nil {
+		return nil, "", err
+	}
+	return creds, region, nil
+}
+
+// newProfileSourceCredentials returns the credentials of a profile requested by name, along with the region to use.
+// Unlike the default chain, credentials in the environment are not used.
+func newProfileSourceCredentials(profileName string) (*credentials.Credentials, string, error) {
+	config, err := sharedconfig.Load()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read the AWS shared config files")
+	}
+	if _, ok := config.Profile(profileName); !ok {
+		return nil, "", HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Profile %s does not exist in the AWS shared config files", profileName),
+		}
+	}
+
+	region := getRegion(config, profileName)
+	creds, err := resolveProfileCredentials(config, profileName, region, make(map[string]bool))
+	if err != nil {
+		return nil, "", err
+	}
+	return creds, region, nil
+}
+
+// needsResolution returns true if the SDK can't resolve the credentials of the profile by itself
+func needsResolution(config *sharedconfig.Config, profileName string, profile sharedconfig.Section) bool {
+	// the SDK only supports a single assume role hop, from a profile with static credentials
+	if source := profile[sourceProfileKey]; profile[roleARNKey] != "" && source != "" {
+		sourceProfile, _ := config.Profile(source)
+		return source != profileName && (sourceProfile[roleARNKey] != "" || sourceProfile[accessKeyIDKey] == "")
+	}
+	if ssoProfile, err := sso.LoadProfile(config, profileName); err != nil || ssoProfile != nil {
+		return true
+	}
+	if profile[webIdentityTokenFileKey] != "" && profile[roleARNKey] != "" {
+		return true
+	}
+	// the SDK runs the credential process with 'sh -c', but the image has no shell
+	return profile[credentialProcessKey] != "" && profile[accessKeyIDKey] == "" && profile[roleARNKey] == ""
+}
+
+// resolveProfileCredentials returns the credentials of the profile, following source_profile
+// references so that roles can be chained across any number of profiles
+func resolveProfileCredentials(config *sharedconfig.Config, profileName string, region string, visited map[string]bool) (*credentials.Credentials, error) {
+	if visited[profileName] {
+		return nil, fmt.Errorf("profile %s is part of a source_profile cycle", profileName)
+	}
+	visited[profileName] = true
+
+	profile, ok := config.Profile(profileName)
+	if !ok {
+		return nil, fmt.Errorf("profile %s does not exist", profileName)
+	}
+
+	if profile[roleARNKey] != "" && profile[sourceProfileKey] != "" {
+		var source *credentials.Credentials
+		var err error
+		if profile[sourceProfileKey] == profileName {
+			// a profile can assume a role using its own static credentials
+			source, err = staticProfileCredentials(profileName, profile)
+		} else {
+			source, err = resolveProfileCredentials(config, profile[sourceProfileKey], region, visited)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newAssumeRoleCredentials(source, profile, region)
+	}
+
+	if profile[roleARNKey] != "" && profile[credentialSourceKey] != "" {
+		if profile[credentialSourceKey] != environmentCredentialSource {
+			return nil, fmt.Errorf("profile %s uses credential_source %s; only %s is supported in role chains", profileName, profile[credentialSourceKey], environmentCredentialSource)
+		}
+		return newAssumeRoleCredentials(credentials.NewEnvCredentials(), profile, region)
+	}
+
+	ssoProfile, err := sso.LoadProfile(config, profileName)
+	if err != nil {
+		return nil, err
+	}
+	if ssoProfile != nil {
+		httpClient, err := newAWSHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		provider, err := sso.NewProvider(ssoProfile, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		return credentials.NewCredentials(provider), nil
+	}
+
+	if profile[webIdentityTokenFileKey] != "" && profile[roleARNKey] != "" {
+		return newWebIdentityCredentials(profile[roleARNKey], profile[roleSessionNameKey], profile[webIdentityTokenFileKey], region, useRegionalSTSEndpoints(profile))
+	}
+
+	if profile[credentialProcessKey] != "" && profile[accessKeyIDKey] == "" {
+		return credentials.NewCredentials(credentialprocess.NewProvider(profile[credentialProcessKey])), nil
+	}
+
+	return staticProfileCredentials(profileName, profile)
+}
+
+func staticProfileCredentials(profileName string, profile sharedconfig.Section) (*credentials.Credentials, error) {
+	if profile[accessKeyIDKey] == "" || profile[secretAccessKeyKey] == "" {
+		return nil, fmt.Errorf("profile %s does not have any credentials", profileName)
+	}
+	return credentials.NewStaticCredentials(profile[accessKeyIDKey], profile[secretAccessKeyKey], profile[sessionTokenKey]), nil
+}
+
+func newAssumeRoleCredentials(source *credentials.Credentials, profile sharedconfig.Section, region string) (*credentials.Credentials, error) {
+	stsClient, err := newSTSClient(source, region, useRegionalSTSEndpoints(profile))
+	if err != nil {
+		return nil, err
+	}
+	provider := &stscreds.AssumeRoleProvider{
+		Client:          stsClient,
+		RoleARN:         profile[roleARNKey],
+		RoleSessionName: profile[roleSessionNameKey],
+		Duration:        stscreds.DefaultDuration,
+	}
+	if provider.RoleSessionName == "" {
+		provider.RoleSessionName = fmt.Sprintf("ecs-local-endpoints-%d", time.Now().UnixNano())
+	}
+	if externalID := profile[externalIDKey]; externalID != "" {
+		provider.ExternalID = aws.String(externalID)
+	}
+	if mfaSerial := profile[mfaSerialKey]; mfaSerial != "" {
+		provider.SerialNumber = aws.String(mfaSerial)
+		// this requires the container to be run interactively
+		provider.TokenProvider = stscreds.StdinTokenProvider
+	}
+	if value := profile[durationSecondsKey]; value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s for role %s", durationSecondsKey, value, profile[roleARNKey])
+		}
+		provider.Duration = time.Duration(seconds) * time.Second
+	}
+	return credentials.NewCredentials(provider), nil
+}
+
+func newWebIdentityCredentials(roleARN string, roleSessionName string, tokenFile string, region string, regional bool) (*credentials.Credentials, error) {
+	// AssumeRoleWithWebIdentity is not signed, so the client does not need credentials
+	stsClient, err := newSTSClient(credentials.AnonymousCredentials, region, regional)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewCredentials(webidentity.NewProvider(stsClient, roleARN, roleSessionName, tokenFile)), nil
+}
+
+// newSTSClient creates a client without loading the shared config,
+// since the SDK fails to load profiles which chain roles
+func newSTSClient(creds *credentials.Credentials, region string, regional bool) (*sts.STS, error) {
+	if region == "" {
+		region = defaultSTSRegion
+	}
+	httpClient, err := newAWSHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	cfg := aws.Config{
+		Credentials: creds,
+		Region:      aws.String(region),
+		HTTPClient:  httpClient,
+	}
+	if endpoint := getSTSEndpoint(region, regional); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            cfg,
+		SharedConfigState: session.SharedConfigDisable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stsClient := sts.New(sess)
+	stsClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	return stsClient, nil
+}
+
+// getRegion returns the region from the environment, or else from the profile
+func getRegion(config *sharedconfig.Config, profileName string) string {
+	if region := os.Getenv(regionVar); region != "" {
+		return region
+	}
+	if region := os.Getenv(defaultRegionVar); region != "" {
+		return region
+	}
+	profile, _ := config.Profile(profileName)
+	return profile[regionKey]
+}
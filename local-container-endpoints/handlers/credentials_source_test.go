@@ -0,0 +1,300 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/credentialprocess"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSharedConfig = `
+[default]
+region = us-west-2
+
+[profile process]
+credential_process = %s
+
+[profile web]
+role_arn = arn:aws:iam::111111111111:role/ci
+web_identity_token_file = /var/run/secrets/token
+
+[profile sso]
+sso_start_url = https://my-sso-portal.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111111111111
+sso_role_name = Developer
+`
+
+// setupSharedConfig writes a shared config file and points the AWS environment variables at it
+func setupSharedConfig(t *testing.T, profile string) func() {
+	dir, err := ioutil.TempDir("", "credentials-source")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+
+	script := filepath.Join(dir, "creds.sh")
+	err = ioutil.WriteFile(script, []byte(`#!/bin/sh
+echo '{"Version": 1, "AccessKeyId": "AKID", "SecretAccessKey": "SKID"}'
+`), 0700)
+	assert.NoError(t, err, "Unexpected error writing script")
+
+	configFile := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(configFile, []byte(fmt.Sprintf(testSharedConfig, script)), 0600)
+	assert.NoError(t, err, "Unexpected error writing config file")
+
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	os.Setenv("AWS_CONFIG_FILE", configFile)
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	os.Setenv("AWS_PROFILE", profile)
+	return func() {
+		os.Setenv("HOME", home)
+		os.Unsetenv("AWS_CONFIG_FILE")
+		os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+		os.Unsetenv("AWS_PROFILE")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewSourceCredentialsCredentialProcess(t *testing.T) {
+	cleanup := setupSharedConfig(t, "process")
+	defer cleanup()
+
+	creds, _, err := newSourceCredentials()
+	assert.NoError(t, err, "Unexpected error creating source credentials")
+	assert.NotNil(t, creds, "Expected credentials for a credential_process profile")
+
+	value, err := creds.Get()
+	assert.NoError(t, err, "Unexpected error getting credentials")
+	assert.Equal(t, "AKID", value.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, credentialprocess.ProviderName, value.ProviderName, "Expected the credential process provider to be used")
+}
+
+func TestNewSourceCredentialsSSO(t *testing.T) {
+	cleanup := setupSharedConfig(t, "sso")
+	defer cleanup()
+
+	creds, _, err := newSourceCredentials()
+	assert.NoError(t, err, "Unexpected error creating source credentials")
+	assert.NotNil(t, creds, "Expected credentials for an SSO profile")
+
+	// there is no cached SSO token in the temporary home directory
+	_, err = creds.Get()
+	assert.Error(t, err, "Expected error getting credentials without logging in")
+	assert.Contains(t, err.Error(), "aws sso login --profile sso", "Expected the error to explain how to log in")
+}
+
+func TestNewSourceCredentialsDefaultChain(t *testing.T) {
+	cleanup := setupSharedConfig(t, "default")
+	defer cleanup()
+
+	creds, _, err := newSourceCredentials()
+	assert.NoError(t, err, "Unexpected error creating source credentials")
+	assert.Nil(t, creds, "Expected the SDK to resolve credentials for the default profile")
+}
+
+func TestNewSourceCredentialsEnvironment(t *testing.T) {
+	cleanup := setupSharedConfig(t, "process")
+	defer cleanup()
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+
+	creds, _, err := newSourceCredentials()
+	assert.NoError(t, err, "Unexpected error creating source credentials")
+	assert.Nil(t, creds, "Expected environment credentials to take precedence")
+}
+
+func TestNewProfileSourceCredentials(t *testing.T) {
+	cleanup := setupSharedConfig(t, "default")
+	defer cleanup()
+	// a requested profile takes precedence over credentials in the environment
+	os.Setenv("AWS_ACCESS_KEY_ID", "ENVAKID")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+
+	creds, region, err := newProfileSourceCredentials("process")
+	assert.NoError(t, err, "Unexpected error creating profile credentials")
+	value, err := creds.Get()
+	assert.NoError(t, err, "Unexpected error getting profile credentials")
+	assert.Equal(t, "AKID", value.AccessKeyID, "Expected the credentials of the profile")
+	assert.Equal(t, "", region, "Expected no region for the profile")
+}
+
+func TestNewProfileSourceCredentialsMissingProfile(t *testing.T) {
+	cleanup := setupSharedConfig(t, "default")
+	defer cleanup()
+
+	_, _, err := newProfileSourceCredentials("missing")
+	assert.Error(t, err, "Expected error for a missing profile")
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code, "Expected a bad request")
+}
+
+func TestNewSourceCredentialsWebIdentityProfile(t *testing.T) {
+	cleanup := setupSharedConfig(t, "web")
+	defer cleanup()
+
+	creds, _, err := newSourceCredentials()
+	assert.NoError(t, err, "Unexpected error creating source credentials")
+	assert.NotNil(t, creds, "Expected credentials for a web identity profile")
+
+	// the token file does not exist, so credentials are not requested from STS
+	_, err = creds.Get()
+	assert.Error(t, err, "Expected error getting credentials without a token file")
+	assert.Contains(t, err.Error(), "/var/run/secrets/token", "Expected the error to name the token file")
+}
+
+func TestNewSourceCredentialsWebIdentityEnvironment(t *testing.T) {
+	cleanup := setupSharedConfig(t, "process")
+	defer cleanup()
+	os.Setenv("AWS_ROLE_ARN", "arn:aws:iam::111111111111:role/ci")
+	os.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/secrets/env-token")
+	defer os.Unsetenv("AWS_ROLE_ARN")
+	defer os.Unsetenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+
+	creds, _, err := newSourceCredentials()
+	assert.NoError(t, err, "Unexpected error creating source credentials")
+	assert.NotNil(t, creds, "Expected credentials for the web identity environment variables")
+
+	// the environment variables take precedence over the profile
+	_, err = creds.Get()
+	assert.Error(t, err, "Expected error getting credentials without a token file")
+	assert.Contains(t, err.Error(), "/var/run/secrets/env-token", "Expected the error to name the token file")
+}
+
+const testChainConfig = `
+[profile static]
+aws_access_key_id = AKID
+aws_secret_access_key = SKID
+
+[profile single-hop]
+role_arn = arn:aws:iam::111111111111:role/first
+source_profile = static
+
+[profile self]
+role_arn = arn:aws:iam::111111111111:role/self
+source_profile = self
+aws_access_key_id = AKID
+aws_secret_access_key = SKID
+
+[profile multi-hop]
+role_arn = arn:aws:iam::222222222222:role/second
+source_profile = single-hop
+external_id = abc
+duration_seconds = 900
+
+[profile from-environment]
+role_arn = arn:aws:iam::111111111111:role/env
+credential_source = Environment
+
+[profile from-metadata]
+role_arn = arn:aws:iam::111111111111:role/ec2
+credential_source = Ec2InstanceMetadata
+
+[profile chained-metadata]
+role_arn = arn:aws:iam::111111111111:role/chained
+source_profile = from-metadata
+
+[profile cycle-a]
+role_arn = arn:aws:iam::111111111111:role/a
+source_profile = cycle-b
+
+[profile cycle-b]
+role_arn = arn:aws:iam::111111111111:role/b
+source_profile = cycle-a
+
+[profile no-credentials]
+region = us-west-2
+
+[profile chained-no-credentials]
+role_arn = arn:aws:iam::111111111111:role/none
+source_profile = no-credentials
+`
+
+func loadChainConfig(t *testing.T) *sharedconfig.Config {
+	dir, err := ioutil.TempDir("", "credentials-chain")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(configFile, []byte(testChainConfig), 0600)
+	assert.NoError(t, err, "Unexpected error writing config file")
+	config, err := sharedconfig.LoadFiles(configFile)
+	assert.NoError(t, err, "Unexpected error loading config file")
+	return config
+}
+
+func TestNeedsResolution(t *testing.T) {
+	config := loadChainConfig(t)
+
+	var testCases = []struct {
+		profile  string
+		expected bool
+	}{
+		{"static", false},
+		{"single-hop", false},
+		{"self", false},
+		{"from-environment", false},
+		{"multi-hop", true},
+		{"chained-metadata", true},
+		{"chained-no-credentials", true},
+	}
+
+	for _, testCase := range testCases {
+		profile, _ := config.Profile(testCase.profile)
+		assert.Equal(t, testCase.expected, needsResolution(config, testCase.profile, profile), "Expected resolution to match for profile %s", testCase.profile)
+	}
+}
+
+func TestResolveProfileCredentials(t *testing.T) {
+	config := loadChainConfig(t)
+
+	for _, profile := range []string{"static", "single-hop", "self", "multi-hop", "from-environment"} {
+		creds, err := resolveProfileCredentials(config, profile, "us-west-2", make(map[string]bool))
+		assert.NoError(t, err, "Unexpected error resolving profile %s", profile)
+		assert.NotNil(t, creds, "Expected credentials for profile %s", profile)
+	}
+
+	creds, err := resolveProfileCredentials(config, "static", "us-west-2", make(map[string]bool))
+	assert.NoError(t, err, "Unexpected error resolving profile")
+	value, err := creds.Get()
+	assert.NoError(t, err, "Unexpected error getting static credentials")
+	assert.Equal(t, "AKID", value.AccessKeyID, "Expected access key to match")
+}
+
+func TestResolveProfileCredentialsErrors(t *testing.T) {
+	config := loadChainConfig(t)
+
+	var testCases = []struct {
+		profile string
+		message string
+	}{
+		{"cycle-a", "cycle"},
+		{"chained-no-credentials", "does not have any credentials"},
+		{"chained-metadata", "only Environment is supported"},
+		{"missing", "does not exist"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := resolveProfileCredentials(config, testCase.profile, "us-west-2", make(map[string]bool))
+		assert.Error(t, err, "Expected error resolving profile %s", testCase.profile)
+		assert.Contains(t, err.Error(), testCase.message, "Expected error message to match for profile %s", testCase.profile)
+	}
+}
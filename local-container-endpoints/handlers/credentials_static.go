@@ -0,0 +1,126 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/staticcreds"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// getStaticCredentials returns the static credentials from the credentials file or the environment,
+// and false if static credentials are not configured
+func getStaticCredentials() (credentials.Value, bool, error) {
+	if path := os.Getenv(config.StaticCredentialsFileVar); path != "" {
+		value, err := staticcreds.LoadFile(path)
+		return value, err == nil, err
+	}
+
+	accessKeyID := os.Getenv(config.StaticAccessKeyIDVar)
+	if accessKeyID == "" {
+		return credentials.Value{}, false, nil
+	}
+	secretAccessKey := os.Getenv(config.StaticSecretAccessKeyVar)
+	if secretAccessKey == "" {
+		return credentials.Value{}, false, fmt.Errorf("%s must be set along with %s", config.StaticSecretAccessKeyVar, config.StaticAccessKeyIDVar)
+	}
+	return credentials.Value{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    os.Getenv(config.StaticSessionTokenVar),
+		ProviderName:    staticcreds.ProviderName,
+	}, true, nil
+}
+
+// getAccountID returns the account ID for roles and identities which are not obtained from AWS;
+// the default is the account in the task ARN
+func getAccountID() (string, error) {
+	defaultAccountID := ""
+	if split := strings.SplitN(utils.GetValue(config.DefaultTaskARN, config.TaskARNVar), ":", 6); len(split) == 6 {
+		defaultAccountID = split[4]
+	}
+	accountID := utils.GetValue(defaultAccountID, config.AccountIDVar)
+	if !accountIDPattern.MatchString(accountID) {
+		return "", fmt.Errorf("Invalid account ID %s; set %s to a 12 digit account ID", accountID, config.AccountIDVar)
+	}
+	return accountID, nil
+}
+
+// getPartition returns the partition for roles and identities which are not obtained from AWS, which is the partition of
+// the task ARN; the default is the partition of the region, such as aws-us-gov for us-gov-west-1
+func getPartition() string {
+	if split := strings.SplitN(os.Getenv(config.TaskARNVar), ":", 6); len(split) == 6 {
+		return split[1]
+	}
+	return utils.GetPartition(utils.GetRegion())
+}
+
+// newOfflineSTSClient returns the STS client for mock or static credentials, along with the account ID for roles,
+// or nil if credentials are obtained from AWS
+func newOfflineSTSClient() (*staticcreds.STSClient, string, error) {
+	isMock, err := utils.GetBoolValue(config.MockCredentialsVar)
+	if err != nil {
+		return nil, "", err
+	}
+	staticCredentials, isStatic, err := getStaticCredentials()
+	if err != nil {
+		return nil, "", err
+	}
+	if !isMock && !isStatic {
+		return nil, "", nil
+	}
+	if isMock && isStatic {
+		return nil, "", fmt.Errorf("Static credentials can't be used with %s", config.MockCredentialsVar)
+	}
+
+	accountID, err := getAccountID()
+	if err != nil {
+		return nil, "", err
+	}
+	if isStatic {
+		logrus.Infof("Vending static credentials with access key %s; no requests are made to AWS", staticCredentials.AccessKeyID)
+		return staticcreds.NewSTSClient(staticCredentials, getPartition(), accountID), accountID, nil
+	}
+
+	var duration time.Duration
+	if value := os.Getenv(config.MockDurationVar); value != "" {
+		duration, err = time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return nil, "", fmt.Errorf("Invalid %s: %s is not a positive duration, such as 5m", config.MockDurationVar, value)
+		}
+	}
+	logrus.Infof("Vending mock credentials for account %s; no requests are made to AWS", accountID)
+	return staticcreds.NewMockSTSClient(getPartition(), accountID, duration), accountID, nil
+}
+
+// newOfflineProfileClients returns a function which creates clients that vend credentials from the offline STS client for every profile
+func newOfflineProfileClients(stsClient stsiface.STSAPI, accountID string) func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+	iamClient := staticcreds.NewIAMClient(getPartition(), accountID)
+	return func(profileName string) (iamiface.IAMAPI, stsiface.STSAPI, *session.Session, error) {
+		return iamClient, stsClient, nil, nil
+	}
+}
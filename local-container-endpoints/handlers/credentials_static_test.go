@@ -0,0 +1,158 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/staticcreds"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func unsetStaticCredentials() {
+	os.Unsetenv(config.StaticCredentialsFileVar)
+	os.Unsetenv(config.StaticAccessKeyIDVar)
+	os.Unsetenv(config.StaticSecretAccessKeyVar)
+	os.Unsetenv(config.StaticSessionTokenVar)
+}
+
+func TestGetStaticCredentials(t *testing.T) {
+	unsetStaticCredentials()
+	defer unsetStaticCredentials()
+
+	_, isStatic, err := getStaticCredentials()
+	assert.NoError(t, err, "Unexpected error without static credentials")
+	assert.False(t, isStatic, "Expected static credentials to be disabled by default")
+
+	os.Setenv(config.StaticAccessKeyIDVar, accessKey)
+	_, _, err = getStaticCredentials()
+	assert.Error(t, err, "Expected error without a secret key")
+
+	os.Setenv(config.StaticSecretAccessKeyVar, secretKey)
+	value, isStatic, err := getStaticCredentials()
+	assert.NoError(t, err, "Unexpected error getting static credentials")
+	assert.True(t, isStatic, "Expected static credentials")
+	assert.Equal(t, accessKey, value.AccessKeyID, "Expected access key to match")
+	assert.Equal(t, "", value.SessionToken, "Expected no session token")
+
+	dir, err := ioutil.TempDir("", "credentials-static")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "creds.json")
+	err = ioutil.WriteFile(path, []byte(`{"AccessKeyId": "FILEAKID", "SecretAccessKey": "FILESKID"}`), 0600)
+	assert.NoError(t, err, "Unexpected error writing credentials file")
+	os.Setenv(config.StaticCredentialsFileVar, path)
+
+	value, isStatic, err = getStaticCredentials()
+	assert.NoError(t, err, "Unexpected error getting static credentials")
+	assert.True(t, isStatic, "Expected static credentials")
+	assert.Equal(t, "FILEAKID", value.AccessKeyID, "Expected the file to take precedence")
+}
+
+func TestGetAccountID(t *testing.T) {
+	defer os.Unsetenv(config.AccountIDVar)
+	defer os.Unsetenv(config.TaskARNVar)
+
+	accountID, err := getAccountID()
+	assert.NoError(t, err, "Unexpected error getting account ID")
+	assert.Equal(t, "111111111111", accountID, "Expected the account of the default task ARN")
+
+	os.Setenv(config.TaskARNVar, "arn:aws:ecs:us-west-2:333333333333:task/cluster/1234")
+	accountID, err = getAccountID()
+	assert.NoError(t, err, "Unexpected error getting account ID")
+	assert.Equal(t, "333333333333", accountID, "Expected the account of the task ARN")
+
+	os.Setenv(config.AccountIDVar, "222222222222")
+	accountID, err = getAccountID()
+	assert.NoError(t, err, "Unexpected error getting account ID")
+	assert.Equal(t, "222222222222", accountID, "Expected the account ID to take precedence")
+
+	os.Setenv(config.AccountIDVar, "my-account")
+	_, err = getAccountID()
+	assert.Error(t, err, "Expected error for an invalid account ID")
+}
+
+func TestStaticCredentials(t *testing.T) {
+	profileClients := newOfflineProfileClients(staticcreds.NewSTSClient(credentials.Value{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		SessionToken:    sessionToken,
+	}, "aws", "111111111111"), "111111111111")
+	iamClient, stsClient, sess, err := profileClients("")
+	assert.NoError(t, err, "Unexpected error creating static clients")
+
+	credsService := NewCredentialServiceWithClients(iamClient, stsClient, nil, sess)
+	credsService.newProfileClients = profileClients
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	for _, path := range []string{"/role/" + roleName, "/role/" + roleName + "?region=eu-west-1&profile=dev", "/creds"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, "Expected status OK for %s", path)
+
+		response := &CredentialResponse{}
+		err = json.Unmarshal(recorder.Body.Bytes(), response)
+		assert.NoError(t, err, "Unexpected error unmarshalling response for %s", path)
+		assert.Equal(t, accessKey, response.AccessKeyID, "Expected access key to match for %s", path)
+		assert.Equal(t, sessionToken, response.Token, "Expected session token to match for %s", path)
+		assert.NotEmpty(t, response.Expiration, "Expected an expiration for %s", path)
+	}
+}
+
+func TestNewOfflineSTSClient(t *testing.T) {
+	unsetStaticCredentials()
+	defer unsetStaticCredentials()
+	defer os.Unsetenv(config.MockCredentialsVar)
+	defer os.Unsetenv(config.MockDurationVar)
+
+	stsClient, _, err := newOfflineSTSClient()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Nil(t, stsClient, "Expected credentials from AWS by default")
+
+	os.Setenv(config.MockCredentialsVar, "true")
+	os.Setenv(config.MockDurationVar, "2m")
+	stsClient, accountID, err := newOfflineSTSClient()
+	assert.NoError(t, err, "Unexpected error")
+	assert.NotNil(t, stsClient, "Expected a mock STS client")
+	assert.Equal(t, "111111111111", accountID, "Expected the default account ID")
+
+	os.Setenv(config.MockDurationVar, "-1m")
+	_, _, err = newOfflineSTSClient()
+	assert.Error(t, err, "Expected error for a negative duration")
+
+	os.Setenv(config.MockDurationVar, "")
+	os.Setenv(config.StaticAccessKeyIDVar, accessKey)
+	os.Setenv(config.StaticSecretAccessKeyVar, secretKey)
+	_, _, err = newOfflineSTSClient()
+	assert.Error(t, err, "Expected error for static credentials in mock mode")
+
+	os.Setenv(config.MockCredentialsVar, "false")
+	stsClient, _, err = newOfflineSTSClient()
+	assert.NoError(t, err, "Unexpected error")
+	assert.NotNil(t, stsClient, "Expected a static STS client")
+
+	os.Setenv(config.MockCredentialsVar, "maybe")
+	_, _, err = newOfflineSTSClient()
+	assert.Error(t, err, "Expected error for an invalid value")
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The token headers and the maximum TTL are the same as in IMDSv2
+const (
+	tokenHeader        = "X-aws-ec2-metadata-token"
+	tokenTTLHeader     = "X-aws-ec2-metadata-token-ttl-seconds"
+	forwardedForHeader = "X-Forwarded-For"
+	maxTokenTTLInS     = 21600
+
+	imdsTokensOptional = "optional"
+	imdsTokensRequired = "required"
+	tokenLengthInBytes = 32
+
+	// maxLiveTokens limits the number of tokens which have not expired; once it is reached,
+	// the token which expires soonest is removed, and clients request a new one when theirs is rejected
+	maxLiveTokens = 1000
+	// defaultTokenRateLimit is the number of tokens per second which each client can request,
+	// if there is no rate limit for credentials requests; SDKs reuse their token until it expires
+	defaultTokenRateLimit = 1
+)
+
+// tokenStore holds the session tokens issued by the token path, which callers must present on credentials requests
+type tokenStore struct {
+	lock   sync.Mutex
+	tokens map[string]time.Time
+	now    func() time.Time
+}
+
+func newTokenStore() *tokenStore {
+	return &tokenStore{
+		tokens: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// isTokenRequired returns true if credentials requests must present a session token
+func isTokenRequired() (bool, error) {
+	return utils.GetBoolValue(config.RequireTokenVar)
+}
+
+// isIMDSTokenRequired returns true if requests to the instance metadata paths must present a session token,
+// which is the same as setting HttpTokens to required on an EC2 instance
+func isIMDSTokenRequired() (bool, error) {
+	switch value := os.Getenv(config.IMDSTokensVar); value {
+	case "", imdsTokensOptional:
+		return false, nil
+	case imdsTokensRequired:
+		return true, nil
+	default:
+		return false, fmt.Errorf("Invalid %s: expected '%s' or '%s', got '%s'", config.IMDSTokensVar, imdsTokensOptional, imdsTokensRequired, value)
+	}
+}
+
+// issue returns a new token which expires after the TTL
+func (store *tokenStore) issue(ttl time.Duration) (string, error) {
+	data := make([]byte, tokenLengthInBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", errors.Wrap(err, "Failed to generate a session token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	now := store.now()
+	var soonest string
+	for existing, expiration := range store.tokens {
+		if !now.Before(expiration) {
+			delete(store.tokens, existing)
+		} else if soonest == "" || expiration.Before(store.tokens[soonest]) {
+			soonest = existing
+		}
+	}
+	if len(store.tokens) >= maxLiveTokens {
+		delete(store.tokens, soonest)
+	}
+	store.tokens[token] = now.Add(ttl)
+	return token, nil
+}
+
+// isValid returns true if the token was issued and has not expired
+func (store *tokenStore) isValid(token string) bool {
+	if store == nil {
+		return false
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	expiration, ok := store.tokens[token]
+	return ok && store.now().Before(expiration)
+}
+
+// getTokenHandler returns the handler which issues session tokens, in the same way as IMDSv2
+func (service *CredentialService) getTokenHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received token request")
+
+		if r.Method != http.MethodPut {
+			return HTTPError{
+				Code: http.StatusMethodNotAllowed,
+				Err:  fmt.Errorf("Invalid method %s; session tokens must be requested with PUT", r.Method),
+			}
+		}
+		// requests which were forwarded by a proxy are rejected, as in IMDSv2
+		if r.Header.Get(forwardedForHeader) != "" {
+			return HTTPError{
+				Code: http.StatusForbidden,
+				Err:  fmt.Errorf("Session tokens can't be requested through a proxy"),
+			}
+		}
+		ttl, err := strconv.Atoi(r.Header.Get(tokenTTLHeader))
+		if err != nil || ttl < 1 || ttl > maxTokenTTLInS {
+			return HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("Invalid '%s' header: the TTL must be between 1 and %d seconds", tokenTTLHeader, maxTokenTTLInS),
+			}
+		}
+
+		token, err := service.tokens.issue(time.Duration(ttl) * time.Second)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set(tokenTTLHeader, strconv.Itoa(ttl))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(token))
+		return nil
+	}
+}
+
+// withTokenRateLimit wraps the token handler with the rate limit for session tokens, which is separate from the
+// limit for credentials requests, so that requesting a token does not use up a client's credentials requests
+func (service *CredentialService) withTokenRateLimit(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return service.tokenRateLimiter.limit("session tokens", handler)
+}
+
+// withToken wraps a credentials handler so that requests without a valid session token are rejected, if tokens are required
+func (service *CredentialService) withToken(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return requireSessionToken(service.requireToken, service.tokens, handler)
+}
+
+// requireSessionToken wraps a handler so that requests without a token issued by the store are rejected, if required is set
+func requireSessionToken(required bool, tokens *tokenStore, handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if required && !tokens.isValid(r.Header.Get(tokenHeader)) {
+			return HTTPError{
+				Code: http.StatusUnauthorized,
+				Err:  fmt.Errorf("Missing or expired session token; request a token with PUT %s, and set it in the '%s' header", config.TokenPath, tokenHeader),
+			}
+		}
+		return handler(w, r)
+	}
+}
+
+// withIMDSToken wraps an instance metadata handler with the token rules of IMDSv2: a token is required if tokens are required
+// for the instance metadata paths or for all credentials requests, and a token which is presented must always be valid
+func (service *CredentialService) withIMDSToken(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		token := r.Header.Get(tokenHeader)
+		if token == "" && !service.requireToken && !service.requireIMDSToken {
+			return handler(w, r)
+		}
+		if !service.tokens.isValid(token) {
+			return HTTPError{
+				Code: http.StatusUnauthorized,
+				Err:  fmt.Errorf("Missing or expired session token; request a token with PUT %s, and set it in the '%s' header", config.TokenPath, tokenHeader),
+			}
+		}
+		return handler(w, r)
+	}
+}
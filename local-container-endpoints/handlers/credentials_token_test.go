@@ -0,0 +1,246 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenStore(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newTokenStore()
+	store.now = func() time.Time {
+		return now
+	}
+
+	token, err := store.issue(time.Minute)
+	assert.NoError(t, err, "Unexpected error issuing token")
+	other, err := store.issue(time.Hour)
+	assert.NoError(t, err, "Unexpected error issuing token")
+	assert.NotEqual(t, token, other, "Expected tokens to be unique")
+
+	assert.True(t, store.isValid(token), "Expected token to be valid")
+	assert.False(t, store.isValid("forged"), "Expected an unknown token to be invalid")
+	assert.False(t, store.isValid(""), "Expected an empty token to be invalid")
+
+	now = now.Add(2 * time.Minute)
+	assert.False(t, store.isValid(token), "Expected token to expire")
+	assert.True(t, store.isValid(other), "Expected token to be valid until its TTL")
+
+	_, err = store.issue(time.Minute)
+	assert.NoError(t, err, "Unexpected error issuing token")
+	assert.Len(t, store.tokens, 2, "Expected expired tokens to be removed")
+}
+
+func TestTokenStoreLimit(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newTokenStore()
+	store.now = func() time.Time {
+		return now
+	}
+
+	soonest, err := store.issue(time.Minute)
+	assert.NoError(t, err, "Unexpected error issuing token")
+	for i := 1; i < maxLiveTokens; i++ {
+		_, err := store.issue(time.Hour)
+		assert.NoError(t, err, "Unexpected error issuing token")
+	}
+	assert.Len(t, store.tokens, maxLiveTokens, "Expected every token to be kept")
+
+	token, err := store.issue(time.Hour)
+	assert.NoError(t, err, "Unexpected error issuing token")
+	assert.Len(t, store.tokens, maxLiveTokens, "Expected the number of tokens to be limited")
+	assert.True(t, store.isValid(token), "Expected the new token to be valid")
+	assert.False(t, store.isValid(soonest), "Expected the token which expires soonest to be removed")
+}
+
+func TestTokenHandlerRateLimit(t *testing.T) {
+	credsService := NewCredentialServiceWithClients(nil, nil, nil, nil)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	requestToken := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest("PUT", config.TokenPath, nil)
+		request.Header.Set(tokenTTLHeader, "21600")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	for i := 0; i < defaultRateLimitBurst; i++ {
+		assert.Equal(t, http.StatusOK, requestToken().Code, "Expected a token within the burst")
+	}
+	recorder := requestToken()
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "Expected the token requests to be limited")
+	assert.NotEmpty(t, recorder.Header().Get("Retry-After"), "Expected a Retry-After header")
+}
+
+func TestIsTokenRequired(t *testing.T) {
+	defer os.Unsetenv(config.RequireTokenVar)
+
+	os.Unsetenv(config.RequireTokenVar)
+	required, err := isTokenRequired()
+	assert.NoError(t, err, "Unexpected error")
+	assert.False(t, required, "Expected tokens to be optional by default")
+
+	os.Setenv(config.RequireTokenVar, "true")
+	required, err = isTokenRequired()
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, required, "Expected tokens to be required")
+
+	os.Setenv(config.RequireTokenVar, "sometimes")
+	_, err = isTokenRequired()
+	assert.Error(t, err, "Expected error for an invalid value")
+}
+
+func TestGetTokenHandlerErrors(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected int
+	}{
+		{"GET", "GET", map[string]string{tokenTTLHeader: "60"}, http.StatusMethodNotAllowed},
+		{"missing TTL", "PUT", nil, http.StatusBadRequest},
+		{"TTL too long", "PUT", map[string]string{tokenTTLHeader: "21601"}, http.StatusBadRequest},
+		{"invalid TTL", "PUT", map[string]string{tokenTTLHeader: "1m"}, http.StatusBadRequest},
+		{"proxied", "PUT", map[string]string{tokenTTLHeader: "60", forwardedForHeader: "10.0.0.1"}, http.StatusForbidden},
+	}
+
+	credsService := NewCredentialServiceWithClients(nil, nil, nil, nil)
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	for _, testCase := range testCases {
+		request := httptest.NewRequest(testCase.method, config.TokenPath, nil)
+		for key, value := range testCase.headers {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, testCase.expected, recorder.Code, "Expected status to match for %s", testCase.name)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := NewCredentialServiceWithClients(iamMock, stsMock, nil, nil)
+	credsService.requireToken = true
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetSessionTokenOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(accessKey),
+			SecretAccessKey: aws.String(secretKey),
+			SessionToken:    aws.String(sessionToken),
+			Expiration:      &expiration,
+		},
+	}, nil)
+
+	// without a token
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", config.TempCredentialsPath, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "Expected unauthorized without a token")
+
+	// with an invalid token
+	request := httptest.NewRequest("GET", config.TempCredentialsPath, nil)
+	request.Header.Set(tokenHeader, "forged")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "Expected unauthorized with an invalid token")
+
+	// request a token
+	request = httptest.NewRequest("PUT", config.TokenPath, nil)
+	request.Header.Set(tokenTTLHeader, "60")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected a token")
+	assert.Equal(t, "60", recorder.Header().Get(tokenTTLHeader), "Expected the TTL header to match")
+	token := recorder.Body.String()
+	assert.NotEmpty(t, token, "Expected a token")
+
+	// with the token
+	request = httptest.NewRequest("GET", config.TempCredentialsPath, nil)
+	request.Header.Set(tokenHeader, token)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected credentials with a valid token")
+}
+
+func TestIsIMDSTokenRequired(t *testing.T) {
+	defer os.Unsetenv(config.IMDSTokensVar)
+
+	os.Unsetenv(config.IMDSTokensVar)
+	required, err := isIMDSTokenRequired()
+	assert.NoError(t, err, "Unexpected error")
+	assert.False(t, required, "Expected tokens to be optional by default")
+
+	os.Setenv(config.IMDSTokensVar, "optional")
+	required, err = isIMDSTokenRequired()
+	assert.NoError(t, err, "Unexpected error")
+	assert.False(t, required, "Expected tokens to be optional")
+
+	os.Setenv(config.IMDSTokensVar, "required")
+	required, err = isIMDSTokenRequired()
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, required, "Expected tokens to be required")
+
+	os.Setenv(config.IMDSTokensVar, "true")
+	_, err = isIMDSTokenRequired()
+	assert.Error(t, err, "Expected error for an invalid value")
+}
+
+func TestWithIMDSToken(t *testing.T) {
+	credsService := NewCredentialServiceWithClients(nil, nil, nil, nil)
+	token, err := credsService.tokens.issue(time.Minute)
+	assert.NoError(t, err, "Unexpected error issuing token")
+
+	handler := credsService.withIMDSToken(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+	serve := func(token string) error {
+		request := httptest.NewRequest("GET", config.IMDSCredentialsPathWithSlash, nil)
+		if token != "" {
+			request.Header.Set(tokenHeader, token)
+		}
+		return handler(httptest.NewRecorder(), request)
+	}
+
+	// tokens are optional
+	assert.NoError(t, serve(""), "Expected no error without a token")
+	assert.NoError(t, serve(token), "Expected no error with a valid token")
+	err = serve("forged")
+	assert.Error(t, err, "Expected an invalid token to be rejected")
+	assert.Equal(t, http.StatusUnauthorized, err.(HTTPError).Code, "Expected unauthorized with an invalid token")
+
+	// tokens are required on the instance metadata paths
+	credsService.requireIMDSToken = true
+	err = serve("")
+	assert.Error(t, err, "Expected a missing token to be rejected")
+	assert.Equal(t, http.StatusUnauthorized, err.(HTTPError).Code, "Expected unauthorized without a token")
+	assert.NoError(t, serve(token), "Expected no error with a valid token")
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/vault"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+)
+
+// the settings which the Vault CLI uses
+const (
+	vaultAddrVar      = "VAULT_ADDR"
+	vaultTokenVar     = "VAULT_TOKEN"
+	vaultNamespaceVar = "VAULT_NAMESPACE"
+	vaultCACertVar    = "VAULT_CACERT"
+	vaultTokenFile    = ".vault-token"
+)
+
+// newVaultCredentials returns the credentials of the Vault role in ECS_LOCAL_VAULT_ROLE, along with the region to use,
+// or nil if it is not set
+func newVaultCredentials() (*credentials.Credentials, string, error) {
+	if os.Getenv(config.VaultRoleVar) == "" {
+		return nil, "", nil
+	}
+	options, err := getVaultOptions()
+	if err != nil {
+		return nil, "", err
+	}
+	region := os.Getenv(regionVar)
+	if region == "" {
+		region = os.Getenv(defaultRegionVar)
+	}
+	return credentials.NewCredentials(vault.NewProvider(options)), region, nil
+}
+
+func getVaultOptions() (vault.Options, error) {
+	address := os.Getenv(vaultAddrVar)
+	if address == "" {
+		return vault.Options{}, fmt.Errorf("Invalid %s: %s must be set to the address of the Vault server", config.VaultRoleVar, vaultAddrVar)
+	}
+	if u, err := url.Parse(address); err != nil || u.Scheme == "" || u.Host == "" {
+		return vault.Options{}, fmt.Errorf("Invalid %s: %s is not a URL, such as https://vault.example.com:8200", vaultAddrVar, address)
+	}
+
+	httpClient, err := newAWSHTTPClient()
+	if err != nil {
+		return vault.Options{}, err
+	}
+	if path := os.Getenv(vaultCACertVar); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return vault.Options{}, errors.Wrapf(err, "Failed to read %s", vaultCACertVar)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return vault.Options{}, fmt.Errorf("Invalid %s: %s does not contain a PEM encoded certificate", vaultCACertVar, path)
+		}
+		httpClient.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	options := vault.Options{
+		Address:    address,
+		Token:      os.Getenv(vaultTokenVar),
+		Namespace:  os.Getenv(vaultNamespaceVar),
+		Mount:      os.Getenv(config.VaultMountVar),
+		Role:       os.Getenv(config.VaultRoleVar),
+		RoleARN:    os.Getenv(config.VaultRoleARNVar),
+		TTL:        os.Getenv(config.VaultTTLVar),
+		HTTPClient: httpClient,
+	}
+	if options.Token == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return vault.Options{}, fmt.Errorf("Invalid %s: %s is not set, and the home directory with the token of 'vault login' was not found", config.VaultRoleVar, vaultTokenVar)
+		}
+		options.TokenFile = filepath.Join(home, vaultTokenFile)
+	}
+	return options, nil
+}
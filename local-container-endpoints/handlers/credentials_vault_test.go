@@ -0,0 +1,73 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVaultCredentials(t *testing.T) {
+	defer os.Unsetenv(config.VaultRoleVar)
+	defer os.Unsetenv(vaultAddrVar)
+	defer os.Unsetenv(vaultTokenVar)
+	defer os.Unsetenv(regionVar)
+
+	creds, _, err := newVaultCredentials()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Nil(t, creds, "Expected no credentials when Vault is not configured")
+
+	os.Setenv(config.VaultRoleVar, "developer")
+	_, _, err = newVaultCredentials()
+	assert.Error(t, err, "Expected error without VAULT_ADDR")
+
+	os.Setenv(vaultAddrVar, "vault:8200")
+	_, _, err = newVaultCredentials()
+	assert.Error(t, err, "Expected error for an address which is not a URL")
+
+	os.Setenv(vaultAddrVar, "http://127.0.0.1:8200")
+	os.Setenv(vaultTokenVar, "s.token")
+	os.Setenv(regionVar, "eu-west-1")
+	creds, region, err := newVaultCredentials()
+	assert.NoError(t, err, "Unexpected error")
+	assert.NotNil(t, creds, "Expected Vault credentials")
+	assert.Equal(t, "eu-west-1", region, "Expected region to match")
+}
+
+func TestGetVaultOptions(t *testing.T) {
+	defer os.Unsetenv(config.VaultRoleVar)
+	defer os.Unsetenv(config.VaultMountVar)
+	defer os.Unsetenv(vaultAddrVar)
+	defer os.Unsetenv(vaultTokenVar)
+	defer os.Unsetenv(vaultCACertVar)
+
+	os.Setenv(config.VaultRoleVar, "developer")
+	os.Setenv(config.VaultMountVar, "aws-dev")
+	os.Setenv(vaultAddrVar, "https://vault.example.com:8200")
+	os.Unsetenv(vaultTokenVar)
+
+	options, err := getVaultOptions()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "developer", options.Role, "Expected role to match")
+	assert.Equal(t, "aws-dev", options.Mount, "Expected mount to match")
+	assert.Equal(t, "", options.Token, "Expected no token")
+	assert.Contains(t, options.TokenFile, vaultTokenFile, "Expected the token file of the Vault CLI")
+
+	os.Setenv(vaultCACertVar, "/does/not/exist.pem")
+	_, err = getVaultOptions()
+	assert.Error(t, err, "Expected error for a missing CA certificate")
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/useragent"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metrics"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+const roleQueryParameter = "role"
+
+// getWhoAmIHandler returns the handler which reports the identity of the base credentials,
+// and of the role in the role query parameter if it is given, from sts:GetCallerIdentity
+func (service *CredentialService) getWhoAmIHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		logrus.Debug("Received whoami request")
+
+		region, err := getRegionParameter(r)
+		if err != nil {
+			return err
+		}
+
+		profileService, err := service.getProfileService(r)
+		if err != nil {
+			return err
+		}
+
+		response := &WhoAmIResponse{}
+		response.Base, err = getCallerIdentity(r.Context(), profileService.getSTSClient(region))
+		if err != nil {
+			return err
+		}
+
+		if role := r.URL.Query().Get(roleQueryParameter); role != "" {
+			opts, err := getAssumeRoleOptions(r)
+			if err != nil {
+				return err
+			}
+			profileService.setCallerContainer(r, opts, nil)
+			creds, err := profileService.getRoleCredentialsByNameOrARN(role, opts)
+			if err != nil {
+				return err
+			}
+			roleCredentials := credentials.NewStaticCredentials(creds.AccessKeyID, creds.SecretAccessKey, creds.Token)
+			response.Role, err = getCallerIdentity(r.Context(), profileService.newRoleSTSClient(profileService.currentSession, roleCredentials, region))
+			if err != nil {
+				return err
+			}
+		}
+
+		writeJSONResponse(w, response)
+		return nil
+	}
+}
+
+func getCallerIdentity(ctx context.Context, stsClient stsiface.STSAPI) (*CallerIdentity, error) {
+	ctx, span := tracing.StartRPC(ctx, "aws-api", "STS", "GetCallerIdentity")
+	start := time.Now()
+	output, err := stsClient.GetCallerIdentityWithContext(awsContext(ctx), &sts.GetCallerIdentityInput{})
+	metrics.ObserveSTSRequest("GetCallerIdentity", start, err)
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	return &CallerIdentity{
+		Account: aws.StringValue(output.Account),
+		Arn:     aws.StringValue(output.Arn),
+		UserID:  aws.StringValue(output.UserId),
+	}, nil
+}
+
+// newRoleSTSClient creates an STS client which uses the credentials of a role instead of the session's credentials
+func newRoleSTSClient(sess *session.Session, creds *credentials.Credentials, region string) stsiface.STSAPI {
+	cfg := &aws.Config{
+		Credentials: creds,
+	}
+	if region != "" {
+		cfg.Region = aws.String(region)
+	}
+	if endpoint := getSTSEndpoint(region, region != ""); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	stsClient := sts.New(sess, cfg)
+	stsClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	return stsClient
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhoAmIWithRole(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	roleSTSMock := mock_stsiface.NewMockSTSAPI(gomock.NewController(t))
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+	credsService.newRoleSTSClient = func(sess *session.Session, creds *credentials.Credentials, region string) stsiface.STSAPI {
+		value, err := creds.Get()
+		assert.NoError(t, err, "Unexpected error getting role credentials")
+		assert.Equal(t, accessKey, value.AccessKeyID, "Expected the role's access key")
+		assert.Equal(t, "eu-west-1", region, "Expected region to match")
+		return roleSTSMock
+	}
+	credsService.stsClients = map[string]stsiface.STSAPI{
+		"eu-west-1": stsMock,
+	}
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+			Account: aws.String("111111111111"),
+			Arn:     aws.String("arn:aws:iam::111111111111:user/clyde"),
+			UserId:  aws.String("AIDACLYDE"),
+		}, nil),
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+		roleSTSMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+			Account: aws.String("111111111111"),
+			Arn:     aws.String("arn:aws:sts::111111111111:assumed-role/" + roleName + "/ecs-local-" + roleName),
+			UserId:  aws.String("AROACLYDE:ecs-local-" + roleName),
+		}, nil),
+	)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	request := httptest.NewRequest("GET", "/whoami?role="+roleName+"&region=eu-west-1", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected status OK")
+
+	response := &WhoAmIResponse{}
+	err := json.Unmarshal(recorder.Body.Bytes(), response)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Equal(t, "arn:aws:iam::111111111111:user/clyde", response.Base.Arn, "Expected base identity to match")
+	if assert.NotNil(t, response.Role, "Expected a role identity") {
+		assert.Equal(t, "arn:aws:sts::111111111111:assumed-role/"+roleName+"/ecs-local-"+roleName, response.Role.Arn, "Expected role identity to match")
+	}
+}
+
+func TestWhoAmIError(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("expired token"))
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	request := httptest.NewRequest("GET", "/whoami", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "Expected an internal server error")
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDebugBindAddress is the address of the debug endpoints unless bind addresses are set, since heap dumps can
+// contain credentials
+const defaultDebugBindAddress = "127.0.0.1"
+
+// ListenDebug returns the listeners for the debug endpoints, or nil if the debug port is not set.
+// They are served on their own port, so that they are not exposed to containers along with the credentials.
+func ListenDebug() ([]net.Listener, error) {
+	port := os.Getenv(config.DebugPortVar)
+	if port == "" {
+		return nil, nil
+	}
+	hosts := getBindAddresses(config.BindAddressVar)
+	if len(hosts) == 0 {
+		hosts = []string{defaultDebugBindAddress}
+	}
+	listeners, err := listenTCP(port, hosts, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to listen at the debug port")
+	}
+	logrus.Infof("Serving pprof debug endpoints at port %s", port)
+	return listeners, nil
+}
+
+// DebugHandler returns the handler for the pprof debug endpoints
+func DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
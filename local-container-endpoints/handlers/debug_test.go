@@ -0,0 +1,52 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenDebug(t *testing.T) {
+	defer os.Unsetenv(config.DebugPortVar)
+
+	listeners, err := ListenDebug()
+	assert.NoError(t, err, "Unexpected error listening at debug port")
+	assert.Empty(t, listeners, "Expected no listener when the debug port is not set")
+
+	os.Setenv(config.DebugPortVar, "0")
+	listeners, err = ListenDebug()
+	assert.NoError(t, err, "Unexpected error listening at debug port")
+	assert.Len(t, listeners, 1, "Expected a listener when the debug port is set")
+	host, _, _ := net.SplitHostPort(listeners[0].Addr().String())
+	assert.Equal(t, "127.0.0.1", host, "Expected the debug endpoints to only be served at the loopback address by default")
+	closeListeners(listeners)
+
+	os.Setenv(config.DebugPortVar, "port")
+	_, err = ListenDebug()
+	assert.Error(t, err, "Expected error for an invalid debug port")
+}
+
+func TestDebugHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	assert.Contains(t, recorder.Body.String(), "goroutine profile", "Expected the goroutine profile")
+}
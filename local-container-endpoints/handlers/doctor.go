@@ -0,0 +1,299 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/containerd"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/kubernetes"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxClockSkew is the difference from the AWS clock at which requests fail, since their signatures have expired
+	maxClockSkew = 5 * time.Minute
+	// clockSkewWarning is the difference from the AWS clock at which the doctor command warns
+	clockSkewWarning = time.Minute
+)
+
+// DoctorStatus is the result of a check of the doctor command
+type DoctorStatus string
+
+// The results of the checks
+const (
+	DoctorPassed  DoctorStatus = "OK"
+	DoctorWarning DoctorStatus = "WARN"
+	DoctorFailed  DoctorStatus = "FAIL"
+	DoctorSkipped DoctorStatus = "SKIP"
+)
+
+// DoctorCheck is one of the checks of the doctor command
+type DoctorCheck struct {
+	Name   string
+	Status DoctorStatus
+	// Detail is what was found, or the error if the check failed
+	Detail string
+}
+
+// doctor runs the checks of Local Endpoints' environment
+type doctor struct {
+	service      *CredentialService
+	dockerClient docker.Client
+	httpClient   *http.Client
+	// pingURL is the ping path at the address of Local Endpoints on the credentials network
+	pingURL string
+	// clockURL is requested to compare the local clock with the Date header of AWS; it is empty if no requests are made to AWS
+	clockURL string
+	checks   []DoctorCheck
+}
+
+// RunDoctor checks that Local Endpoints works in this environment: that Docker can be reached, credentials can be found,
+// the roles of the config file and of running containers can be assumed, the credentials address can be reached,
+// and the clock agrees with AWS. Unlike ValidateConfig, it makes requests to Docker and AWS.
+func RunDoctor(ctx context.Context, services map[string]configfile.Service) []DoctorCheck {
+	d := &doctor{
+		pingURL: fmt.Sprintf("http://%s%s", config.EndpointsIP, config.PingPath),
+	}
+
+	var err error
+	if kubernetes.IsSidecar() {
+		d.dockerClient, err = kubernetes.NewPodClient()
+	} else if containerd.IsEnabled() {
+		d.dockerClient, err = containerd.NewNerdctlClient()
+	} else {
+		d.dockerClient, err = docker.NewDockerClient()
+	}
+	if err != nil {
+		d.fail("Docker", errors.Wrap(err, "Failed to create Docker client"))
+		d.skip("AWS credentials", "Skipped, since the Docker client could not be created")
+		return d.checks
+	}
+
+	d.service, err = NewCustomCredentialService(services, Clients{Docker: d.dockerClient})
+	if err == nil {
+		d.httpClient, err = newAWSHTTPClient()
+	}
+	if err != nil {
+		d.checkDocker(ctx)
+		d.fail("AWS credentials", err)
+		return d.checks
+	}
+	// there is no session for mock and static credentials
+	if sess := d.service.currentSession; sess != nil {
+		d.clockURL = getSTSEndpoint(aws.StringValue(sess.Config.Region), useRegionalSTSEndpointsForProfile(""))
+		if d.clockURL == "" {
+			d.clockURL = sess.ClientConfig(sts.EndpointsID).Endpoint
+		}
+	}
+	return d.run(ctx)
+}
+
+func (d *doctor) run(ctx context.Context) []DoctorCheck {
+	reachedDocker := d.checkDocker(ctx)
+	if d.checkCredentials(ctx) {
+		d.checkRoles(ctx, reachedDocker)
+	}
+	d.checkEndpoint(ctx)
+	d.checkClock(ctx)
+	return d.checks
+}
+
+func (d *doctor) add(name string, status DoctorStatus, detail string) {
+	d.checks = append(d.checks, DoctorCheck{
+		Name:   name,
+		Status: status,
+		Detail: detail,
+	})
+}
+
+func (d *doctor) pass(name, detail string) {
+	d.add(name, DoctorPassed, detail)
+}
+
+func (d *doctor) warn(name, detail string) {
+	d.add(name, DoctorWarning, detail)
+}
+
+func (d *doctor) fail(name string, err error) {
+	d.add(name, DoctorFailed, err.Error())
+}
+
+func (d *doctor) skip(name, detail string) {
+	d.add(name, DoctorSkipped, detail)
+}
+
+// checkDocker lists the running containers, which is how callers are identified; it returns whether Docker was reached
+func (d *doctor) checkDocker(ctx context.Context) bool {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := d.dockerClient.ContainerList(ctx)
+	if err != nil {
+		d.fail("Docker", errors.Wrap(err, "Failed to list running containers"))
+		return false
+	}
+	d.pass("Docker", fmt.Sprintf("Found %d running containers", len(containers)))
+	return true
+}
+
+// checkCredentials finds the identity of the base credentials with sts:GetCallerIdentity; it returns whether they work
+func (d *doctor) checkCredentials(ctx context.Context) bool {
+	identity, err := getCallerIdentity(ctx, d.service.stsClient)
+	if err != nil {
+		d.fail("AWS credentials", errors.Wrap(err, "Failed to call sts:GetCallerIdentity"))
+		return false
+	}
+	d.pass("AWS credentials", fmt.Sprintf("Using %s", identity.Arn))
+	return true
+}
+
+// checkRoles calls iam:GetRole and sts:AssumeRole for the roles which are vended without a role in the request;
+// the roles in the labels of running containers are only checked if Docker was reached
+func (d *doctor) checkRoles(ctx context.Context, reachedDocker bool) {
+	roles := d.service.getConfigFileRoles()
+	if reachedDocker {
+		var err error
+		roles, err = d.service.getCredentialsRoles(ctx)
+		if err != nil {
+			d.fail("Roles", err)
+			return
+		}
+	}
+	if len(roles) == 0 {
+		d.skip("Roles", fmt.Sprintf("No roles are set in the config file or in the '%s' label of a running container", taskRoleLabel))
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, credsRole := range roles {
+		if seen[credsRole.role] {
+			continue
+		}
+		seen[credsRole.role] = true
+		d.checkRole(ctx, credsRole.role)
+	}
+}
+
+func (d *doctor) checkRole(ctx context.Context, role string) {
+	roleARN := role
+	roleName, err := getRoleName(role)
+	if err != nil {
+		d.fail("Role "+role, err)
+		return
+	}
+	if !strings.HasPrefix(role, "arn:") {
+		// role ARNs are assumed without iam:GetRole, so that roles in other accounts can be used
+		output, err := d.service.iamClient.GetRoleWithContext(awsContext(ctx), &iam.GetRoleInput{
+			RoleName: aws.String(roleName),
+		})
+		if err != nil {
+			d.fail("iam:GetRole "+role, err)
+			return
+		}
+		roleARN = aws.StringValue(output.Role.Arn)
+		d.pass("iam:GetRole "+role, fmt.Sprintf("Found %s", roleARN))
+	}
+
+	creds, err := d.service.assumeRole(roleARN, roleName, &assumeRoleOptions{ctx: ctx})
+	if err != nil {
+		d.fail("sts:AssumeRole "+role, err)
+		return
+	}
+	d.pass("sts:AssumeRole "+role, fmt.Sprintf("Got credentials which expire at %s", creds.Expiration))
+}
+
+// checkEndpoint requests the ping path at the credentials address, which is only reachable from containers on the
+// credentials network, or from the host if it has routes to the network
+func (d *doctor) checkEndpoint(ctx context.Context) {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequest(http.MethodGet, d.pingURL, nil)
+	if err != nil {
+		d.fail("Credentials address", err)
+		return
+	}
+	// the SDKs request the credentials address without a proxy
+	client := &http.Client{Transport: &http.Transport{}}
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		d.warn("Credentials address", fmt.Sprintf("Failed to reach %s: %v; run the doctor command in a container on the same network as your containers to check the address from there", d.pingURL, err))
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		d.fail("Credentials address", fmt.Errorf("%s responded with HTTP %d", d.pingURL, response.StatusCode))
+		return
+	}
+	d.pass("Credentials address", fmt.Sprintf("Reached Local Endpoints at %s", d.pingURL))
+}
+
+// checkClock compares the local clock with the Date header of an AWS response, since requests signed with a clock
+// that is more than 5 minutes off are rejected
+func (d *doctor) checkClock(ctx context.Context) {
+	if d.clockURL == "" {
+		d.skip("Clock", "Skipped, since no requests are made to AWS")
+		return
+	}
+	request, err := http.NewRequest(http.MethodGet, d.clockURL, nil)
+	if err != nil {
+		d.fail("Clock", err)
+		return
+	}
+	start := time.Now()
+	response, err := d.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		d.fail("Clock", errors.Wrapf(err, "Failed to request %s", d.clockURL))
+		return
+	}
+	response.Body.Close()
+	awsTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		d.fail("Clock", fmt.Errorf("%s did not respond with a valid Date header", d.clockURL))
+		return
+	}
+
+	// the Date header is truncated to the second, so it is compared with the local time halfway through the request,
+	// and the skew is only reported in whole seconds
+	localTime := start.Add(time.Since(start) / 2)
+	skew := localTime.Sub(awsTime.Add(time.Second / 2)).Round(time.Second)
+	switch absSkew := absDuration(skew); {
+	case absSkew >= maxClockSkew:
+		d.fail("Clock", fmt.Errorf("The local clock is %s off from AWS; requests to AWS fail once it is %s off", absSkew, maxClockSkew))
+	case absSkew >= clockSkewWarning:
+		d.warn("Clock", fmt.Sprintf("The local clock is %s off from AWS; requests to AWS fail once it is %s off", absSkew, maxClockSkew))
+	default:
+		d.pass("Clock", fmt.Sprintf("The local clock is %s off from AWS", absSkew))
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
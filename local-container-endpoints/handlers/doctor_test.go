@@ -0,0 +1,195 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/iam/mock_iamiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const crossAccountRoleARN = "arn:aws:iam::222222222222:role/other"
+
+// newClockServer responds with a Date header which is skew behind the local clock
+func newClockServer(skew time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-skew).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusFound)
+	}))
+}
+
+func newDoctorInTest(ctrl *gomock.Controller, pingURL, clockURL string) (*doctor, *mock_iamiface.MockIAMAPI, *mock_stsiface.MockSTSAPI, *mock_docker.MockClient) {
+	iamMock := mock_iamiface.NewMockIAMAPI(ctrl)
+	stsMock := mock_stsiface.NewMockSTSAPI(ctrl)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	service := newCredentialServiceInTest(iamMock, stsMock)
+	service.dockerClient = dockerMock
+	service.services = map[string]configfile.Service{
+		"app":    {Role: roleName},
+		"worker": {Role: crossAccountRoleARN},
+	}
+	return &doctor{
+		service:      service,
+		dockerClient: dockerMock,
+		httpClient:   http.DefaultClient,
+		pingURL:      pingURL,
+		clockURL:     clockURL,
+	}, iamMock, stsMock, dockerMock
+}
+
+func getDoctorStatuses(checks []DoctorCheck) map[string]DoctorStatus {
+	statuses := make(map[string]DoctorStatus)
+	for _, check := range checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestDoctor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	endpoints := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer endpoints.Close()
+	clock := newClockServer(0)
+	defer clock.Close()
+
+	d, iamMock, stsMock, dockerMock := newDoctorInTest(ctrl, endpoints.URL+"/ping", clock.URL)
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+	labelled := testingutils.BaseDockerContainer(containerName1, longID1).WithLabel(taskRoleLabel, "label-role").Get()
+
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{labelled}, nil).Times(2)
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Arn: aws.String("arn:aws:iam::111111111111:user/clyde"),
+	}, nil)
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx aws.Context, input *iam.GetRoleInput, opts ...interface{}) (*iam.GetRoleOutput, error) {
+		return &iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String("arn:aws:iam::111111111111:role/" + aws.StringValue(input.RoleName)),
+			},
+		}, nil
+	}).Times(2)
+	var assumedRoles []string
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx aws.Context, input *sts.AssumeRoleInput, opts ...interface{}) (*sts.AssumeRoleOutput, error) {
+		assumedRoles = append(assumedRoles, aws.StringValue(input.RoleArn))
+		return &sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil
+	}).Times(3)
+
+	checks := d.run(context.Background())
+	assert.Equal(t, []DoctorCheck{
+		{Name: "Docker", Status: DoctorPassed, Detail: "Found 1 running containers"},
+		{Name: "AWS credentials", Status: DoctorPassed, Detail: "Using arn:aws:iam::111111111111:user/clyde"},
+		{Name: "iam:GetRole " + roleName, Status: DoctorPassed, Detail: "Found arn:aws:iam::111111111111:role/" + roleName},
+		{Name: "sts:AssumeRole " + roleName, Status: DoctorPassed, Detail: "Got credentials which expire at " + expirationTimeString},
+		{Name: "sts:AssumeRole " + crossAccountRoleARN, Status: DoctorPassed, Detail: "Got credentials which expire at " + expirationTimeString},
+		{Name: "iam:GetRole label-role", Status: DoctorPassed, Detail: "Found arn:aws:iam::111111111111:role/label-role"},
+		{Name: "sts:AssumeRole label-role", Status: DoctorPassed, Detail: "Got credentials which expire at " + expirationTimeString},
+		{Name: "Credentials address", Status: DoctorPassed, Detail: "Reached Local Endpoints at " + endpoints.URL + "/ping"},
+	}, checks[:len(checks)-1], "Expected every check to pass")
+	// the Date header is truncated to the second
+	clockCheck := checks[len(checks)-1]
+	assert.Equal(t, DoctorPassed, clockCheck.Status, "Expected the clock check to pass")
+	assert.Regexp(t, `^The local clock is [01]s off from AWS$`, clockCheck.Detail, "Expected the clock skew")
+	assert.Equal(t, []string{"arn:aws:iam::111111111111:role/" + roleName, crossAccountRoleARN, "arn:aws:iam::111111111111:role/label-role"}, assumedRoles, "Expected each role to be assumed")
+}
+
+func TestDoctorFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// nothing listens at the address once the server is closed
+	endpoints := httptest.NewServer(http.NotFoundHandler())
+	endpoints.Close()
+	clock := newClockServer(10 * time.Minute)
+	defer clock.Close()
+
+	d, _, stsMock, dockerMock := newDoctorInTest(ctrl, endpoints.URL+"/ping", clock.URL)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return(nil, errors.New("permission denied"))
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("ExpiredToken"))
+
+	checks := d.run(context.Background())
+	assert.Equal(t, map[string]DoctorStatus{
+		"Docker":              DoctorFailed,
+		"AWS credentials":     DoctorFailed,
+		"Credentials address": DoctorWarning,
+		"Clock":               DoctorFailed,
+	}, getDoctorStatuses(checks), "Expected the roles not to be checked without credentials")
+	assert.Regexp(t, `^The local clock is 10m[01]s off from AWS; requests to AWS fail once it is 5m0s off$`, checks[3].Detail, "Expected the clock skew")
+}
+
+func TestDoctorRoleFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	endpoints := httptest.NewServer(http.NotFoundHandler())
+	defer endpoints.Close()
+	clock := newClockServer(-2 * time.Minute)
+	defer clock.Close()
+
+	d, iamMock, stsMock, dockerMock := newDoctorInTest(ctrl, endpoints.URL+"/ping", clock.URL)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return(nil, nil).Times(2)
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Arn: aws.String("arn:aws:iam::111111111111:user/clyde"),
+	}, nil)
+	iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("AccessDenied"))
+	stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("AccessDenied"))
+
+	checks := d.run(context.Background())
+	assert.Equal(t, map[string]DoctorStatus{
+		"Docker":                                DoctorPassed,
+		"AWS credentials":                       DoctorPassed,
+		"iam:GetRole " + roleName:               DoctorFailed,
+		"sts:AssumeRole " + crossAccountRoleARN: DoctorFailed,
+		"Credentials address":                   DoctorFailed,
+		"Clock":                                 DoctorWarning,
+	}, getDoctorStatuses(checks), "Expected the role and clock checks to fail")
+}
+
+func TestDoctorWithoutRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d, _, stsMock, dockerMock := newDoctorInTest(ctrl, "", "")
+	d.service.services = nil
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return(nil, errors.New("permission denied"))
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Arn: aws.String("arn:aws:iam::111111111111:user/clyde"),
+	}, nil)
+
+	checks := d.run(context.Background())
+	statuses := getDoctorStatuses(checks)
+	assert.Equal(t, DoctorSkipped, statuses["Roles"], "Expected the roles to be skipped")
+	assert.Equal(t, DoctorSkipped, statuses["Clock"], "Expected the clock to be skipped without requests to AWS")
+}
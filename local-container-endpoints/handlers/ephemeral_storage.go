@@ -0,0 +1,38 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+)
+
+// Fargate tasks can have between 20 GiB and 200 GiB of ephemeral storage
+const maxEphemeralStorageSize = 200
+
+// getEphemeralStorageSize returns the size in GiB of the local 'task's ephemeral storage
+func getEphemeralStorageSize() (int64, error) {
+	value := os.Getenv(config.EphemeralStorageSizeVar)
+	if value == "" {
+		return config.DefaultEphemeralStorageSize, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < config.DefaultEphemeralStorageSize || size > maxEphemeralStorageSize {
+		return 0, fmt.Errorf("Invalid %s: %s is not a number of GiB between %d and %d", config.EphemeralStorageSizeVar, value, config.DefaultEphemeralStorageSize, maxEphemeralStorageSize)
+	}
+	return size, nil
+}
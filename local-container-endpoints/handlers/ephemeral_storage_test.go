@@ -0,0 +1,44 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEphemeralStorageSize(t *testing.T) {
+	defer os.Unsetenv(config.EphemeralStorageSizeVar)
+
+	os.Unsetenv(config.EphemeralStorageSizeVar)
+	size, err := getEphemeralStorageSize()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int64(20), size, "Expected the Fargate default size")
+
+	os.Setenv(config.EphemeralStorageSizeVar, "100")
+	size, err = getEphemeralStorageSize()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int64(100), size, "Expected size to match")
+
+	os.Setenv(config.EphemeralStorageSizeVar, "10")
+	_, err = getEphemeralStorageSize()
+	assert.Error(t, err, "Expected error for a size which is too small")
+
+	os.Setenv(config.EphemeralStorageSizeVar, "20GiB")
+	_, err = getEphemeralStorageSize()
+	assert.Error(t, err, "Expected error for a size with units")
+}
@@ -27,9 +27,12 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/iam/mock_iamiface"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/handlers"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -37,6 +40,7 @@ import (
 const (
 	roleName             = "clyde_task_role"
 	roleARN              = "arn:aws:iam::111111111111111:role/clyde_task_role"
+	crossAccountRoleARN  = "arn:aws:iam::222222222222:role/some/path/clyde_task_role"
 	secretKey            = "SKID"
 	accessKey            = "AKID"
 	sessionToken         = "token"
@@ -51,14 +55,14 @@ func TestGetRoleCredentials(t *testing.T) {
 	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
 
 	gomock.InOrder(
-		iamMock.EXPECT().GetRole(gomock.Any()).Do(func(input *iam.GetRoleInput) {
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *iam.GetRoleInput) {
 			assert.Equal(t, roleName, aws.StringValue(input.RoleName), "Expected role name to match")
 		}).Return(&iam.GetRoleOutput{
 			Role: &iam.Role{
 				Arn: aws.String(roleARN),
 			},
 		}, nil),
-		stsMock.EXPECT().AssumeRole(gomock.Any()).Do(func(input *sts.AssumeRoleInput) {
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *sts.AssumeRoleInput) {
 			assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
 		}).Return(&sts.AssumeRoleOutput{
 			Credentials: &sts.Credentials{
@@ -91,6 +95,65 @@ func TestGetRoleCredentials(t *testing.T) {
 	assert.Equal(t, creds.RoleArn, roleARN, "Expected role ARN to match")
 }
 
+func TestGetRoleCredentialsByARN(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *sts.AssumeRoleInput) {
+			assert.Equal(t, crossAccountRoleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+			assert.Equal(t, "ecs-local-"+roleName, aws.StringValue(input.RoleSessionName), "Expected role session name to match")
+			assert.Equal(t, "my-external-id", aws.StringValue(input.ExternalId), "Expected external ID to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/role/%s?externalId=my-external-id", ts.URL, crossAccountRoleARN))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	creds := &handlers.CredentialResponse{}
+	err = json.Unmarshal(response, creds)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Equal(t, creds.AccessKeyID, accessKey, "Expected access key to match")
+	assert.Equal(t, creds.SecretAccessKey, secretKey, "Expected secret key to match")
+	assert.Equal(t, creds.Token, sessionToken, "Expected session token to match")
+	assert.Equal(t, creds.Expiration, expirationTimeString, "Expected expiration to match")
+	assert.Equal(t, creds.RoleArn, crossAccountRoleARN, "Expected role ARN to match")
+}
+
+func TestGetRoleCredentialsByInvalidARN(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/role/arn:aws:iam::222222222222:user/clyde", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "Expected a bad request")
+}
+
 func TestGetTemporaryCredentials(t *testing.T) {
 	iamMock, stsMock := setupMocks(t)
 
@@ -99,7 +162,7 @@ func TestGetTemporaryCredentials(t *testing.T) {
 	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
 
 	gomock.InOrder(
-		stsMock.EXPECT().GetSessionToken(gomock.Any()).Return(&sts.GetSessionTokenOutput{
+		stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetSessionTokenOutput{
 			Credentials: &sts.Credentials{
 				AccessKeyId:     aws.String(accessKey),
 				SecretAccessKey: aws.String(secretKey),
@@ -131,6 +194,263 @@ func TestGetTemporaryCredentials(t *testing.T) {
 
 }
 
+func TestGetTemporaryCredentialsWithDuration(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *sts.GetSessionTokenInput) {
+			assert.Equal(t, int64(1800), aws.Int64Value(input.DurationSeconds), "Expected duration to match")
+		}).Return(&sts.GetSessionTokenOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/creds?duration=1800", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "Expected the request to succeed")
+
+	res, err = http.Get(fmt.Sprintf("%s/creds?duration=60", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "Expected a bad request for a duration below the minimum")
+}
+
+func TestGetContainerRoleCredentials(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+
+	credsService := handlers.NewCredentialServiceWithClients(iamMock, stsMock, dockerMock, nil)
+
+	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
+
+	// the test server receives requests from the loopback address
+	caller := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "127.0.0.1").WithLabel("ecs-local.task-role", roleName).Get()
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{caller}, nil),
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *iam.GetRoleInput) {
+			assert.Equal(t, roleName, aws.StringValue(input.RoleName), "Expected role name to match")
+		}).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *sts.AssumeRoleInput) {
+			assert.Equal(t, roleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/role", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualCredentials := &handlers.CredentialResponse{}
+	err = json.Unmarshal(response, actualCredentials)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	assert.Equal(t, roleARN, actualCredentials.RoleArn, "Expected RoleArn to match")
+	assert.Equal(t, accessKey, actualCredentials.AccessKeyID, "Expected AccessKeyID to match")
+}
+
+func TestGetIMDSCredentials(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+
+	credsService := handlers.NewCredentialServiceWithClients(iamMock, stsMock, dockerMock, nil)
+
+	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
+
+	// the test server receives requests from the loopback address
+	caller := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "127.0.0.1").WithLabel("ecs-local.task-role", crossAccountRoleARN).Get()
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{caller}, nil),
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{caller}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *sts.AssumeRoleInput) {
+			assert.Equal(t, crossAccountRoleARN, aws.StringValue(input.RoleArn), "Expected the container's role ARN")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// list the role, as the SDKs do before requesting credentials
+	res, err := http.Get(fmt.Sprintf("%s/latest/meta-data/iam/security-credentials/", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+	assert.Equal(t, roleName, string(response), "Expected the name of the container's role")
+
+	res, err = http.Get(fmt.Sprintf("%s/latest/meta-data/iam/security-credentials/%s", ts.URL, roleName))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualCredentials := &handlers.IMDSCredentialResponse{}
+	err = json.Unmarshal(response, actualCredentials)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Equal(t, "Success", actualCredentials.Code, "Expected Code to match")
+	assert.Equal(t, "AWS-HMAC", actualCredentials.Type, "Expected Type to match")
+	assert.Equal(t, accessKey, actualCredentials.AccessKeyID, "Expected AccessKeyID to match")
+	assert.Equal(t, secretKey, actualCredentials.SecretAccessKey, "Expected SecretAccessKey to match")
+	assert.Equal(t, sessionToken, actualCredentials.Token, "Expected Token to match")
+	assert.Equal(t, expirationTimeString, actualCredentials.Expiration, "Expected Expiration to match")
+	assert.NotEmpty(t, actualCredentials.LastUpdated, "Expected LastUpdated to be set")
+}
+
+func TestGetIMDSCredentialsWithoutRole(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+
+	credsService := handlers.NewCredentialServiceWithClients(iamMock, stsMock, dockerMock, nil)
+
+	caller := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "127.0.0.1").Get()
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{caller}, nil)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/latest/meta-data/iam/security-credentials/", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "Expected not found for a container without a role")
+}
+
+func TestGetCredentialsByID(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+	dockerMock := mock_docker.NewMockClient(gomock.NewController(t))
+
+	credsService := handlers.NewCredentialServiceWithClients(iamMock, stsMock, dockerMock, nil)
+
+	expiration, _ := time.Parse(handlers.CredentialExpirationTimeFormat, expirationTimeString)
+
+	// requests from the test server come from 127.0.0.1, so the list has the role of the app
+	app := testingutils.BaseDockerContainer("app", "c1a7").WithNetwork("bridge", "127.0.0.1").WithLabel("ecs-local.task-role", crossAccountRoleARN).Get()
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{app}, nil),
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{app}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx aws.Context, input *sts.AssumeRoleInput) {
+			assert.Equal(t, crossAccountRoleARN, aws.StringValue(input.RoleArn), "Expected role ARN to match")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+		// the caller is looked up for the audit log
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{app}, nil),
+	)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// list the relative URI of the caller's role
+	res, err := http.Get(fmt.Sprintf("%s/v2/credentials", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	relativeURIs := make(map[string]string)
+	err = json.Unmarshal(response, &relativeURIs)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	relativeURI := relativeURIs[crossAccountRoleARN]
+	assert.Regexp(t, "^/v2/credentials/[0-9a-f-]{36}$", relativeURI, "Expected an ECS Agent style relative URI")
+
+	// get credentials from the relative URI
+	res, err = http.Get(ts.URL + relativeURI)
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualCredentials := &handlers.CredentialResponse{}
+	err = json.Unmarshal(response, actualCredentials)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Equal(t, crossAccountRoleARN, actualCredentials.RoleArn, "Expected RoleArn to match")
+	assert.Equal(t, accessKey, actualCredentials.AccessKeyID, "Expected AccessKeyID to match")
+}
+
+func TestWhoAmI(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	stsMock.EXPECT().GetCallerIdentityWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("111111111111"),
+		Arn:     aws.String("arn:aws:iam::111111111111:user/clyde"),
+		UserId:  aws.String("AIDACLYDE"),
+	}, nil)
+
+	router := mux.NewRouter()
+	credsService.SetupRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/whoami", ts.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	whoami := &handlers.WhoAmIResponse{}
+	err = json.Unmarshal(response, whoami)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Equal(t, &handlers.CallerIdentity{
+		Account: "111111111111",
+		Arn:     "arn:aws:iam::111111111111:user/clyde",
+		UserID:  "AIDACLYDE",
+	}, whoami.Base, "Expected base identity to match")
+	assert.Nil(t, whoami.Role, "Expected no role identity")
+}
+
 func setupMocks(t *testing.T) (*mock_iamiface.MockIAMAPI, *mock_stsiface.MockSTSAPI) {
 	ctrl := gomock.NewController(t)
 	iamMock := mock_iamiface.NewMockIAMAPI(ctrl)
@@ -139,5 +459,5 @@ func setupMocks(t *testing.T) (*mock_iamiface.MockIAMAPI, *mock_stsiface.MockSTS
 }
 
 func newCredentialServiceInTest(iamMock *mock_iamiface.MockIAMAPI, stsMock *mock_stsiface.MockSTSAPI) *handlers.CredentialService {
-	return handlers.NewCredentialServiceWithClients(iamMock, stsMock, nil)
+	return handlers.NewCredentialServiceWithClients(iamMock, stsMock, nil, nil)
 }
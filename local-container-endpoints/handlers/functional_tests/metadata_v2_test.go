@@ -107,6 +107,10 @@ func TestV2Handler_TaskMetadata(t *testing.T) {
 		},
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -195,6 +199,10 @@ func TestV2Handler_TaskMetadata_TrailingSlash(t *testing.T) {
 		},
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -256,6 +264,35 @@ func TestV2Handler_TaskMetadata_DockerAPIError(t *testing.T) {
 	assert.True(t, strings.Contains(response.Status, strconv.Itoa(http.StatusInternalServerError)), "Expected http response status to be internal server error")
 }
 
+// Tests Path: /v2/metadata from a caller which is not a container
+func TestV2Handler_TaskMetadata_UnknownCaller(t *testing.T) {
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{container1, endpointsContainer}, nil)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV2Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// the request comes from the host, which is not in any local 'task'
+	res, err := http.Get(fmt.Sprintf("%s/v2/metadata", testServer.URL))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "Expected http response status to be 404 not found")
+	assert.NotContains(t, string(response), longID1, "Expected no containers in the response")
+}
+
 // Tests Path: /v2/metadata/<container ID>
 func TestV2Handler_ContainerMetadata(t *testing.T) {
 	// Docker API Containers
@@ -304,6 +341,52 @@ func TestV2Handler_ContainerMetadata(t *testing.T) {
 	assert.Equal(t, &expectedMetadata, actualMetadata, "Expected container metadata response to match")
 }
 
+// Tests Path: /v2/metadata/<short container ID>
+func TestV2Handler_ContainerMetadata_ShortID(t *testing.T) {
+	// Docker API Containers
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+
+	// Metadata response
+	expectedMetadata := testingutils.BaseMetadataContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+	)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV2Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// make a request to the testing server
+	res, err := http.Get(fmt.Sprintf("%s/v2/metadata/%s", testServer.URL, shortID1))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualMetadata := &v2.ContainerResponse{}
+	err = json.Unmarshal(response, actualMetadata)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	assert.Equal(t, &expectedMetadata, actualMetadata, "Expected container metadata response to match")
+}
+
 func TestV2Handler_TaskMetadata_InvalidURL(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
@@ -416,7 +499,7 @@ func TestV2Handler_ContainerStats(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := &types.Stats{}
+	actualStats := &types.StatsJSON{}
 	err = json.Unmarshal(response, actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -464,7 +547,7 @@ func TestV2Handler_ContainerStats_TrailingSlash(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := &types.Stats{}
+	actualStats := &types.StatsJSON{}
 	err = json.Unmarshal(response, actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -486,6 +569,10 @@ func TestV2Handler_TaskStats(t *testing.T) {
 		endpointsContainer,
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -494,7 +581,7 @@ func TestV2Handler_TaskStats(t *testing.T) {
 	container3Stats := getMockStats()
 	endpointsStats := getMockStats()
 
-	expectedStats := map[string]types.Stats{
+	expectedStats := map[string]types.StatsJSON{
 		longID1:         *container1Stats,
 		longID2:         *container2Stats,
 		longID3:         *container3Stats,
@@ -523,7 +610,7 @@ func TestV2Handler_TaskStats(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := make(map[string]types.Stats)
+	actualStats := make(map[string]types.StatsJSON)
 	err = json.Unmarshal(response, &actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -545,6 +632,10 @@ func TestV2Handler_TaskStats_TrailingSlash(t *testing.T) {
 		endpointsContainer,
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -553,7 +644,7 @@ func TestV2Handler_TaskStats_TrailingSlash(t *testing.T) {
 	container3Stats := getMockStats()
 	endpointsStats := getMockStats()
 
-	expectedStats := map[string]types.Stats{
+	expectedStats := map[string]types.StatsJSON{
 		longID1:         *container1Stats,
 		longID2:         *container2Stats,
 		longID3:         *container3Stats,
@@ -582,7 +673,7 @@ func TestV2Handler_TaskStats_TrailingSlash(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := make(map[string]types.Stats)
+	actualStats := make(map[string]types.StatsJSON)
 	err = json.Unmarshal(response, &actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -604,6 +695,10 @@ func TestV2Handler_TaskStats_DockerAPIError(t *testing.T) {
 		endpointsContainer,
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
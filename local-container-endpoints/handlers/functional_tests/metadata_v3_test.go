@@ -297,7 +297,7 @@ func TestV3Handler_ContainerStats(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := &types.Stats{}
+	actualStats := &types.StatsJSON{}
 	err = json.Unmarshal(response, actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -344,7 +344,7 @@ func TestV3Handler_ContainerStats_TrailingSlash(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := &types.Stats{}
+	actualStats := &types.StatsJSON{}
 	err = json.Unmarshal(response, actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -366,6 +366,10 @@ func TestV3Handler_TaskStats(t *testing.T) {
 		endpointsContainer,
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -374,7 +378,7 @@ func TestV3Handler_TaskStats(t *testing.T) {
 	container3Stats := getMockStats()
 	endpointsStats := getMockStats()
 
-	expectedStats := map[string]types.Stats{
+	expectedStats := map[string]types.StatsJSON{
 		longID1:         *container1Stats,
 		longID2:         *container2Stats,
 		longID3:         *container3Stats,
@@ -403,7 +407,7 @@ func TestV3Handler_TaskStats(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := make(map[string]types.Stats)
+	actualStats := make(map[string]types.StatsJSON)
 	err = json.Unmarshal(response, &actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -424,6 +428,10 @@ func TestV3Handler_TaskStats_TrailingSlash(t *testing.T) {
 		endpointsContainer,
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -432,7 +440,7 @@ func TestV3Handler_TaskStats_TrailingSlash(t *testing.T) {
 	container3Stats := getMockStats()
 	endpointsStats := getMockStats()
 
-	expectedStats := map[string]types.Stats{
+	expectedStats := map[string]types.StatsJSON{
 		longID1:         *container1Stats,
 		longID2:         *container2Stats,
 		longID3:         *container3Stats,
@@ -461,7 +469,7 @@ func TestV3Handler_TaskStats_TrailingSlash(t *testing.T) {
 	res.Body.Close()
 	assert.NoError(t, err, "Unexpected error reading HTTP response")
 
-	actualStats := make(map[string]types.Stats)
+	actualStats := make(map[string]types.StatsJSON)
 	err = json.Unmarshal(response, &actualStats)
 	assert.NoError(t, err, "Unexpected error unmarshalling response")
 
@@ -482,6 +490,10 @@ func TestV3Handler_TaskStats_DockerAPIError(t *testing.T) {
 		endpointsContainer,
 	}
 
+	// the requests come from the host, whose local 'task' is all running containers
+	os.Setenv(config.UnknownCallerAllContainersVar, "true")
+	defer os.Unsetenv(config.UnknownCallerAllContainersVar)
+
 	ctrl := gomock.NewController(t)
 	dockerMock := mock_docker.NewMockClient(ctrl)
 
@@ -509,3 +521,96 @@ func TestV3Handler_TaskStats_DockerAPIError(t *testing.T) {
 	assert.NoError(t, err, "Unexpected error making HTTP Request")
 	assert.True(t, strings.Contains(response.Status, strconv.Itoa(http.StatusInternalServerError)), "Expected http response status to be internal server error")
 }
+
+// Tests Path: /v3/<container ID>
+func TestV3Handler_ContainerMetadata_ContainerID(t *testing.T) {
+	// Docker API Containers
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+
+	// Metadata response
+	expectedMetadata := testingutils.BaseMetadataContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+	)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV3Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// make a request to the testing server
+	res, err := http.Get(fmt.Sprintf("%s/v3/%s", testServer.URL, shortID1))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualMetadata := &v2.ContainerResponse{}
+	err = json.Unmarshal(response, actualMetadata)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	assertContainerTimes(t, &expectedMetadata, actualMetadata)
+	assert.Equal(t, &expectedMetadata, actualMetadata, "Expected container metadata response to match")
+}
+
+// Tests Path: /v3/<container ID>/stats
+func TestV3Handler_ContainerStats_ContainerID(t *testing.T) {
+	// Docker API Containers
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	expectedStats := getMockStats()
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+		dockerMock.EXPECT().ContainerStats(gomock.Any(), longID1).Return(expectedStats, nil),
+	)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV3Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// make a request to the testing server
+	res, err := http.Get(fmt.Sprintf("%s/v3/%s/stats/", testServer.URL, shortID1))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualStats := &types.StatsJSON{}
+	err = json.Unmarshal(response, actualStats)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	assert.Equal(t, expectedStats, actualStats, "Expected container stats response to match")
+}
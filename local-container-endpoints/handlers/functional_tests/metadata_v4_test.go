@@ -0,0 +1,331 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package functionaltests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/handlers"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests Path: /v4/containers/<container ID>
+func TestV4Handler_ContainerMetadata(t *testing.T) {
+	// Docker API Containers
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+
+	// Metadata response
+	expectedMetadata := testingutils.BaseMetadataContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+		dockerMock.EXPECT().ContainerInspect(gomock.Any(), longID1).Return(getMockContainerJSON("json-file"), nil),
+	)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV4Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// make a request to the testing server
+	res, err := http.Get(fmt.Sprintf("%s/v4/containers/%s", testServer.URL, longID1))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualMetadata := &metadata.V4ContainerResponse{}
+	err = json.Unmarshal(response, actualMetadata)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	// the V4 networks shadow the V2 networks in the response
+	expectedMetadata.Networks = nil
+	assertContainerTimes(t, &expectedMetadata, actualMetadata.ContainerResponse)
+	assert.Equal(t, &expectedMetadata, actualMetadata.ContainerResponse, "Expected container metadata response to match")
+	assert.Equal(t, "json-file", actualMetadata.LogDriver, "Expected log driver to match")
+	assert.Len(t, actualMetadata.Networks, 1, "Expected one network in the response")
+	assert.Equal(t, network2, actualMetadata.Networks[0].NetworkMode, "Expected network mode to match")
+	assert.Equal(t, 0, *actualMetadata.Networks[0].AttachmentIndex, "Expected attachment index to match")
+	assert.NotEmpty(t, actualMetadata.ContainerARN, "Expected container ARN in the response")
+}
+
+// Tests Path: /v4/<container ID>/task
+func TestV4Handler_TaskMetadata(t *testing.T) {
+	// Docker API Containers
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil)
+	dockerMock.EXPECT().ContainerInspectWithSize(gomock.Any(), longID2).Return(getMockContainerJSON("awslogs"), nil)
+	dockerMock.EXPECT().ContainerInspectWithSize(gomock.Any(), endpointsLongID).Return(getMockContainerJSON("json-file"), nil)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV4Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// make a request to the testing server
+	res, err := http.Get(fmt.Sprintf("%s/v4/%s/task", testServer.URL, longID2))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualMetadata := &metadata.V4TaskResponse{}
+	err = json.Unmarshal(response, actualMetadata)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	assert.Equal(t, config.DefaultClusterName, actualMetadata.Cluster, "Expected Cluster to match")
+	assert.Equal(t, "arn:aws:ecs:us-west-2:111111111111:task/ecs-local-cluster/e14298f5-b033-5935-9a4e-d380c7a38ecd", actualMetadata.TaskARN, "Expected TaskARN for the compose project")
+	assert.Len(t, actualMetadata.Containers, 2, "Expected only the containers in the compose project")
+	for _, container := range actualMetadata.Containers {
+		assert.NotEmpty(t, container.LogDriver, "Expected log driver for %s", container.Name)
+	}
+}
+
+// Tests Path: /v4/<container ID>/taskWithTags
+func TestV4Handler_TaskMetadataWithTags(t *testing.T) {
+	// Docker API Containers
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithComposeProject(projectName).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).WithLabel("ecs-local.task-tag.team", "containers").Get()
+
+	dockerAPIResponse := []types.Container{
+		container2,
+		endpointsContainer,
+	}
+
+	os.Setenv(config.ContainerInstanceTagsVar, "containerInstance=tags")
+	os.Setenv(config.TaskTagsVar, "task=tags")
+	defer os.Clearenv()
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil).Times(2)
+	dockerMock.EXPECT().ContainerInspectWithSize(gomock.Any(), longID2).Return(getMockContainerJSON("awslogs"), nil).Times(2)
+	dockerMock.EXPECT().ContainerInspectWithSize(gomock.Any(), endpointsLongID).Return(getMockContainerJSON("json-file"), nil).Times(2)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV4Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	// make a request to the testing server
+	res, err := http.Get(fmt.Sprintf("%s/v4/%s/taskWithTags", testServer.URL, longID2))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualMetadata := &metadata.V4TaskResponse{}
+	err = json.Unmarshal(response, actualMetadata)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+
+	assert.Equal(t, map[string]string{"task": "tags", "team": "containers"}, actualMetadata.TaskTags, "Expected Task Tags to match")
+	assert.Equal(t, map[string]string{"containerInstance": "tags"}, actualMetadata.ContainerInstanceTags, "Expected Container Instance Tags to match")
+	assert.Len(t, actualMetadata.Containers, 2, "Expected only the containers in the compose project")
+
+	// the task path doesn't include the tags
+	res, err = http.Get(fmt.Sprintf("%s/v4/%s/task", testServer.URL, longID2))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	response, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+	actualMetadata = &metadata.V4TaskResponse{}
+	err = json.Unmarshal(response, actualMetadata)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Nil(t, actualMetadata.TaskTags, "Expected no Task Tags")
+	assert.Nil(t, actualMetadata.ContainerInstanceTags, "Expected no Container Instance Tags")
+}
+
+func getMockContainerJSON(logDriver string) *types.ContainerJSON {
+	return &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			HostConfig: &container.HostConfig{
+				LogConfig: container.LogConfig{
+					Type: logDriver,
+				},
+			},
+		},
+	}
+}
+
+// Tests Path: /v4/containers/<container ID>/stats
+func TestV4Handler_ContainerStats(t *testing.T) {
+	// Docker API Containers
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+		container2,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	readTime := time.Now().UTC()
+	firstStats := getMockStats()
+	firstStats.Read = readTime
+	secondStats := getMockStats()
+	secondStats.Read = readTime.Add(time.Second)
+	secondStats.Networks["eth0"] = types.NetworkStats{
+		RxBytes: firstStats.Networks["eth0"].RxBytes + 200,
+		TxBytes: firstStats.Networks["eth0"].TxBytes + 100,
+	}
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+		dockerMock.EXPECT().ContainerStats(gomock.Any(), longID1).Return(firstStats, nil),
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+		dockerMock.EXPECT().ContainerStats(gomock.Any(), longID1).Return(secondStats, nil),
+	)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV4Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	var actualStats *metadata.V4StatsResponse
+	// the network rates are computed from the previous request's sample
+	for range []int{1, 2} {
+		res, err := http.Get(fmt.Sprintf("%s/v4/containers/%s/stats", testServer.URL, longID1))
+		assert.NoError(t, err, "Unexpected error making HTTP Request")
+		response, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.NoError(t, err, "Unexpected error reading HTTP response")
+
+		actualStats = &metadata.V4StatsResponse{}
+		err = json.Unmarshal(response, actualStats)
+		assert.NoError(t, err, "Unexpected error unmarshalling response")
+	}
+
+	assert.Equal(t, secondStats.Networks, actualStats.Networks, "Expected network stats to match")
+	assert.Equal(t, float64(200), actualStats.NetworkRateStats.RxBytesPerSecond, "Expected rx rate to match")
+	assert.Equal(t, float64(100), actualStats.NetworkRateStats.TxBytesPerSecond, "Expected tx rate to match")
+}
+
+// Tests Path: /v4/containers/<container ID>/stats?stream=true
+func TestV4Handler_ContainerStatsStream(t *testing.T) {
+	// Docker API Containers
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+
+	dockerAPIResponse := []types.Container{
+		container1,
+	}
+
+	ctrl := gomock.NewController(t)
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	readTime := time.Now().UTC()
+	firstStats := getMockStats()
+	firstStats.Read = readTime
+	secondStats := getMockStats()
+	secondStats.Read = readTime.Add(time.Second)
+	secondStats.Networks["eth0"] = types.NetworkStats{
+		RxBytes: firstStats.Networks["eth0"].RxBytes + 200,
+		TxBytes: firstStats.Networks["eth0"].TxBytes + 100,
+	}
+
+	gomock.InOrder(
+		dockerMock.EXPECT().ContainerList(gomock.Any()).Return(dockerAPIResponse, nil),
+		dockerMock.EXPECT().ContainerStatsStream(gomock.Any(), longID1, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, longContainerID string, statsChan chan<- *types.StatsJSON) error {
+				statsChan <- firstStats
+				statsChan <- secondStats
+				return nil
+			}),
+	)
+
+	metadataService, err := handlers.NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating new metadata service")
+
+	// create a testing server
+	router := mux.NewRouter()
+	metadataService.SetupV4Routes(router)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/v4/containers/%s/stats?stream=true", testServer.URL, longID1))
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	var actualStats []metadata.V4StatsResponse
+	for decoder.More() {
+		stats := metadata.V4StatsResponse{}
+		err = decoder.Decode(&stats)
+		assert.NoError(t, err, "Unexpected error decoding response")
+		actualStats = append(actualStats, stats)
+	}
+
+	assert.Len(t, actualStats, 2, "Expected one response per stats sample")
+	assert.Equal(t, &metadata.NetworkRateStats{}, actualStats[0].NetworkRateStats, "Expected no rates for the first sample")
+	assert.Equal(t, float64(200), actualStats[1].NetworkRateStats.RxBytesPerSecond, "Expected rx rate to match")
+	assert.Equal(t, float64(100), actualStats[1].NetworkRateStats.TxBytesPerSecond, "Expected tx rate to match")
+}
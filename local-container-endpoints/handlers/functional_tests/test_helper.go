@@ -16,14 +16,44 @@ package functionaltests
 
 import (
 	"math/rand"
+	"testing"
+	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v2"
 	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
 )
 
-func getMockStats() *types.Stats {
-	return &types.Stats{
-		CPUStats: types.CPUStats{
-			SystemUsage: uint64(rand.Intn(10000)),
+func getMockStats() *types.StatsJSON {
+	return &types.StatsJSON{
+		Stats: types.Stats{
+			CPUStats: types.CPUStats{
+				SystemUsage: uint64(rand.Intn(10000)),
+			},
 		},
+		Networks: map[string]types.NetworkStats{
+			"eth0": types.NetworkStats{
+				RxBytes: uint64(rand.Intn(10000)),
+				TxBytes: uint64(rand.Intn(10000)),
+			},
+		},
+	}
+}
+
+// assertContainerTimes checks that the timestamps of the container responses are the same instants, and then sets the
+// timestamps of actual to those of expected; a time read from JSON is only in the local time zone if it has the local offset
+func assertContainerTimes(t *testing.T, expected, actual *v2.ContainerResponse) {
+	for _, times := range [][2]**time.Time{
+		{&expected.CreatedAt, &actual.CreatedAt},
+		{&expected.StartedAt, &actual.StartedAt},
+		{&expected.FinishedAt, &actual.FinishedAt},
+	} {
+		expectedTime, actualTime := *times[0], *times[1]
+		if expectedTime == nil || actualTime == nil {
+			assert.Equal(t, expectedTime, actualTime, "Expected timestamps to match")
+			continue
+		}
+		assert.True(t, expectedTime.Equal(*actualTime), "Expected timestamp %s to match %s", actualTime, expectedTime)
+		*times[1] = expectedTime
 	}
 }
@@ -0,0 +1,109 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/version"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const healthyStatus = "OK"
+
+// SetupHealthRoutes sets up the health check and version paths, which respond without making requests to AWS or Docker
+func SetupHealthRoutes(router *mux.Router) {
+	router.HandleFunc(config.HealthPath, ServeHTTP(getHealthHandler()))
+	router.HandleFunc(config.HealthPathWithSlash, ServeHTTP(getHealthHandler()))
+	router.HandleFunc(config.PingPath, ServeHTTP(getHealthHandler()))
+	router.HandleFunc(config.PingPathWithSlash, ServeHTTP(getHealthHandler()))
+
+	router.HandleFunc(config.VersionPath, ServeHTTP(getVersionHandler()))
+	router.HandleFunc(config.VersionPathWithSlash, ServeHTTP(getVersionHandler()))
+}
+
+func getHealthHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		writeJSONResponse(w, HealthResponse{
+			Status: healthyStatus,
+		})
+		return nil
+	}
+}
+
+func getVersionHandler() func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		writeJSONResponse(w, VersionResponse{
+			Version:                   version.Version,
+			AgentVersionCompatibility: version.AgentVersionCompatibility,
+			GitShortHash:              version.GitShortHash,
+			GitDirty:                  version.GitDirty,
+		})
+		return nil
+	}
+}
+
+// CheckHealth requests the ping path from the endpoints running with the same environment, such as in the same container,
+// and returns an error unless they respond with HTTP 200
+func CheckHealth() error {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	transport := &http.Transport{
+		// the certificate is for the address which other containers use, so it is not verified for the local request
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	scheme := "http"
+	host := getHealthCheckHost()
+	if socketPath := os.Getenv(config.SocketPathVar); socketPath != "" && os.Getenv(config.PortVar) == "" {
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	} else if os.Getenv(config.TLSCertFileVar) != "" {
+		scheme = "https"
+	}
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+
+	response, err := client.Get(fmt.Sprintf("%s://%s%s", scheme, host, config.PingPath))
+	if err != nil {
+		return errors.Wrap(err, "Health check failed")
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Health check failed: %s responded with HTTP %d", config.PingPath, response.StatusCode)
+	}
+	return nil
+}
+
+// getHealthCheckHost returns the address of the port for the health check, at the first bind address if it is a specific interface
+func getHealthCheckHost() string {
+	host := "localhost"
+	if hosts := getBindAddresses(config.BindAddressVar); len(hosts) > 0 {
+		if ip := net.ParseIP(hosts[0]); ip == nil || !ip.IsUnspecified() {
+			host = hosts[0]
+		}
+	}
+	return net.JoinHostPort(host, utils.GetValue(config.DefaultPort, config.PortVar))
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/version"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	SetupHealthRoutes(router)
+
+	for _, path := range []string{config.HealthPath, config.HealthPathWithSlash, config.PingPath, config.PingPathWithSlash} {
+		t.Run(path, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, path, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+			response := &HealthResponse{}
+			err := json.Unmarshal(recorder.Body.Bytes(), response)
+			assert.NoError(t, err, "Unexpected error unmarshalling response")
+			assert.Equal(t, healthyStatus, response.Status, "Expected status to match")
+		})
+	}
+}
+
+func TestVersionRoute(t *testing.T) {
+	router := mux.NewRouter()
+	SetupHealthRoutes(router)
+
+	request := httptest.NewRequest(http.MethodGet, config.VersionPath, nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	response := &VersionResponse{}
+	err := json.Unmarshal(recorder.Body.Bytes(), response)
+	assert.NoError(t, err, "Unexpected error unmarshalling response")
+	assert.Equal(t, version.Version, response.Version, "Expected version to match")
+	assert.Equal(t, version.AgentVersionCompatibility, response.AgentVersionCompatibility, "Expected agent version to match")
+	assert.Equal(t, version.GitShortHash, response.GitShortHash, "Expected git hash to match")
+	assert.Equal(t, version.GitDirty, response.GitDirty, "Expected git dirty to match")
+}
+
+func TestCheckHealth(t *testing.T) {
+	defer os.Unsetenv(config.PortVar)
+	defer os.Unsetenv(config.BindAddressVar)
+	defer os.Unsetenv(config.SocketPathVar)
+
+	router := mux.NewRouter()
+	SetupHealthRoutes(router)
+	server := httptest.NewServer(router)
+	port := strconv.Itoa(server.Listener.Addr().(*net.TCPAddr).Port)
+	os.Setenv(config.PortVar, port)
+	os.Setenv(config.BindAddressVar, "127.0.0.1")
+	assert.NoError(t, CheckHealth(), "Expected the health check to pass")
+
+	server.Close()
+	assert.Error(t, CheckHealth(), "Expected the health check to fail once the server is stopped")
+
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "endpoints.sock")
+	listener, err := ListenUnix(socketPath)
+	assert.NoError(t, err, "Unexpected error listening")
+	defer listener.Close()
+	go http.Serve(listener, router)
+
+	os.Unsetenv(config.PortVar)
+	os.Setenv(config.SocketPathVar, socketPath)
+	assert.NoError(t, CheckHealth(), "Expected the health check to pass over the unix socket")
+}
+
+func TestGetHealthCheckHost(t *testing.T) {
+	defer os.Unsetenv(config.PortVar)
+	defer os.Unsetenv(config.BindAddressVar)
+
+	assert.Equal(t, "localhost:80", getHealthCheckHost(), "Expected the default port on localhost")
+
+	os.Setenv(config.PortVar, "8080")
+	os.Setenv(config.BindAddressVar, "0.0.0.0")
+	assert.Equal(t, "localhost:8080", getHealthCheckHost(), "Expected localhost for all interfaces")
+
+	os.Setenv(config.BindAddressVar, "::1, 127.0.0.1")
+	assert.Equal(t, "[::1]:8080", getHealthCheckHost(), "Expected the first bind address")
+}
@@ -16,10 +16,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"net"
 	"net/http"
 
-	"github.com/sirupsen/logrus"
+	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
 )
 
 // Error wraps built-in error and adds a status code
@@ -32,6 +32,8 @@ type Error interface {
 type HTTPError struct {
 	Code int
 	Err  error
+	// ErrorCode is the code in the JSON error response; by default, it is derived from the status code
+	ErrorCode string
 }
 
 // Error satisfies the error interface.
@@ -49,18 +51,9 @@ func ServeHTTP(handler func(w http.ResponseWriter, r *http.Request) error) func(
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := handler(w, r)
 		if err != nil {
-			switch e := err.(type) {
-			case Error:
-				// Return the specific error code and error message
-				logrus.Errorf("HTTP %d - %s", e.Status(), err)
-				http.Error(w, e.Error(), e.Status())
-			default:
-				// default to HTTP 500 for all other errors
-				logrus.Errorf("HTTP 500 - %s", err)
-				// Internal Server Error: <actual error message>
-				http.Error(w, fmt.Sprintf("%s: %s", http.StatusText(http.StatusInternalServerError), err.Error()),
-					http.StatusInternalServerError)
-			}
+			errorMessage := getErrorMessage(err)
+			requestLog(r).Errorf("HTTP %d - %s", errorMessage.HTTPErrorCode, err)
+			writeJSONErrorResponse(w, errorMessage)
 		}
 	}
 }
@@ -70,3 +63,19 @@ func writeJSONResponse(w http.ResponseWriter, response interface{}) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// writeJSONErrorResponse writes the error in the same format as the ECS Agent
+func writeJSONErrorResponse(w http.ResponseWriter, errorMessage *handlersutils.ErrorMessage) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorMessage.HTTPErrorCode)
+	json.NewEncoder(w).Encode(errorMessage)
+}
+
+// getCallerIP returns the IP address which the request came from, or an empty string if it can't be found
+func getCallerIP(r *http.Request) string {
+	callerIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	return callerIP
+}
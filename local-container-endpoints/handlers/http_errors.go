@@ -0,0 +1,83 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+// awsErrorStatusCodes maps the codes of AWS errors to the status code returned to the caller,
+// so that the SDKs in containers retry the same errors as they would from the ECS Agent
+var awsErrorStatusCodes = map[string]int{
+	"AccessDenied":              http.StatusForbidden,
+	"AccessDeniedException":     http.StatusForbidden,
+	"NoSuchEntity":              http.StatusNotFound,
+	"ParameterNotFound":         http.StatusNotFound,
+	"RegionDisabledException":   http.StatusForbidden,
+	"RequestLimitExceeded":      http.StatusTooManyRequests,
+	"ResourceNotFoundException": http.StatusNotFound,
+	"Throttling":                http.StatusTooManyRequests,
+	"ThrottlingException":       http.StatusTooManyRequests,
+}
+
+// getErrorMessage returns the ECS Agent error response for the error.
+// HTTP errors keep their status code, AWS errors are mapped from their code or the status code of the AWS response,
+// and all other errors are internal server errors.
+func getErrorMessage(err error) *handlersutils.ErrorMessage {
+	if e, ok := err.(Error); ok {
+		errorCode := getErrorCodeForStatus(e.Status())
+		if httpErr, ok := err.(HTTPError); ok && httpErr.ErrorCode != "" {
+			errorCode = httpErr.ErrorCode
+		}
+		return &handlersutils.ErrorMessage{
+			Code:          errorCode,
+			Message:       err.Error(),
+			HTTPErrorCode: e.Status(),
+		}
+	}
+
+	if awsErr, ok := errors.Cause(err).(awserr.Error); ok {
+		return &handlersutils.ErrorMessage{
+			Code:          awsErr.Code(),
+			Message:       err.Error(),
+			HTTPErrorCode: getStatusForAWSError(awsErr),
+		}
+	}
+
+	return &handlersutils.ErrorMessage{
+		Code:          getErrorCodeForStatus(http.StatusInternalServerError),
+		Message:       err.Error(),
+		HTTPErrorCode: http.StatusInternalServerError,
+	}
+}
+
+func getStatusForAWSError(awsErr awserr.Error) int {
+	if status, ok := awsErrorStatusCodes[awsErr.Code()]; ok {
+		return status
+	}
+	if requestFailure, ok := awsErr.(awserr.RequestFailure); ok && requestFailure.StatusCode() >= http.StatusBadRequest {
+		return requestFailure.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// Error codes are derived from the status text, such as InternalServerError, which is the code used by the ECS Agent
+func getErrorCodeForStatus(status int) string {
+	return strings.Replace(http.StatusText(status), " ", "", -1)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetErrorMessage(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		err            error
+		expectedCode   string
+		expectedStatus int
+	}{
+		{"HTTP error", HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("bad")}, "BadRequest", http.StatusBadRequest},
+		{"HTTP error with code", HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("bad"), ErrorCode: "InvalidIdInRequest"}, "InvalidIdInRequest", http.StatusBadRequest},
+		{"throttling", awserr.New("Throttling", "Rate exceeded", nil), "Throttling", http.StatusTooManyRequests},
+		{"wrapped AWS error", errors.Wrap(awserr.New("AccessDenied", "Not authorized", nil), "Failed"), "AccessDenied", http.StatusForbidden},
+		{"AWS response", awserr.NewRequestFailure(awserr.New("ValidationError", "Invalid", nil), http.StatusBadRequest, "1234"), "ValidationError", http.StatusBadRequest},
+		{"AWS client error", awserr.New("NoCredentialProviders", "no valid providers in chain", nil), "NoCredentialProviders", http.StatusInternalServerError},
+		{"other error", fmt.Errorf("Some error"), "InternalServerError", http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		errorMessage := getErrorMessage(testCase.err)
+		assert.Equal(t, testCase.expectedCode, errorMessage.Code, "Expected code to match for %s", testCase.name)
+		assert.Equal(t, testCase.expectedStatus, errorMessage.HTTPErrorCode, "Expected status to match for %s", testCase.name)
+		assert.Equal(t, testCase.err.Error(), errorMessage.Message, "Expected message to match for %s", testCase.name)
+	}
+}
+
+func TestServeHTTPError(t *testing.T) {
+	handler := ServeHTTP(func(w http.ResponseWriter, r *http.Request) error {
+		return HTTPError{
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("Container not found"),
+		}
+	})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/role", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Expected status to match")
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"), "Expected a JSON response")
+
+	var errorMessage handlersutils.ErrorMessage
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &errorMessage), "Unexpected error decoding response")
+	assert.Equal(t, handlersutils.ErrorMessage{
+		Code:          "NotFound",
+		Message:       "Container not found",
+		HTTPErrorCode: http.StatusNotFound,
+	}, errorMessage, "Expected error response to match")
+}
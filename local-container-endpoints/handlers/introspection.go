@@ -0,0 +1,118 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dockerIDQueryParameter = "dockerid"
+	taskARNQueryParameter  = "taskarn"
+)
+
+// ListenIntrospection returns the listeners for the ECS Agent introspection API, or nil if the introspection port is not set.
+// Older tools such as log routers and monitoring agents discover the tasks on an instance with it.
+func ListenIntrospection() ([]net.Listener, error) {
+	port := os.Getenv(config.IntrospectionPortVar)
+	if port == "" {
+		return nil, nil
+	}
+	listeners, err := listenTCP(port, getBindAddresses(config.BindAddressVar), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to listen at the introspection port")
+	}
+	logrus.Infof("Serving the ECS Agent introspection API at port %s", port)
+	return listeners, nil
+}
+
+// SetupIntrospectionRoutes sets up the routes of the ECS Agent introspection API
+func (service *MetadataService) SetupIntrospectionRoutes(router *mux.Router) {
+	router.HandleFunc(config.IntrospectionMetadataPath, ServeHTTP(introspectionMetadataHandler))
+	router.HandleFunc(config.IntrospectionMetadataPathWithSlash, ServeHTTP(introspectionMetadataHandler))
+	router.HandleFunc(config.IntrospectionTasksPath, ServeHTTP(service.introspectionTasksHandler))
+	router.HandleFunc(config.IntrospectionTasksPathWithSlash, ServeHTTP(service.introspectionTasksHandler))
+}
+
+func introspectionMetadataHandler(w http.ResponseWriter, r *http.Request) error {
+	writeJSONResponse(w, metadata.GetIntrospectionMetadata())
+	return nil
+}
+
+// introspectionTasksHandler lists the local 'tasks', or returns one task if the 'dockerid' or 'taskarn' query parameter is set
+func (service *MetadataService) introspectionTasksHandler(w http.ResponseWriter, r *http.Request) error {
+	dockerID := r.URL.Query().Get(dockerIDQueryParameter)
+	taskARN := r.URL.Query().Get(taskARNQueryParameter)
+	if dockerID != "" && taskARN != "" {
+		return HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Only one of the '%s' and '%s' query parameters can be set", dockerIDQueryParameter, taskARNQueryParameter),
+		}
+	}
+
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Failed to list running containers")
+	}
+	response := metadata.GetIntrospectionTasks(containers)
+	if dockerID == "" && taskARN == "" {
+		writeJSONResponse(w, response)
+		return nil
+	}
+
+	task := findIntrospectionTask(response.Tasks, dockerID, taskARN)
+	if task == nil {
+		return HTTPError{
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("No task found for %s", dockerID+taskARN),
+		}
+	}
+	writeJSONResponse(w, task)
+	return nil
+}
+
+// findIntrospectionTask returns the task with the ARN, or the task of the container whose ID starts with dockerID
+func findIntrospectionTask(tasks []*v1.TaskResponse, dockerID, taskARN string) *v1.TaskResponse {
+	for _, task := range tasks {
+		if taskARN != "" && task.Arn == taskARN {
+			return task
+		}
+		if dockerID == "" {
+			continue
+		}
+		for _, container := range task.Containers {
+			if strings.HasPrefix(container.DockerID, dockerID) {
+				return task
+			}
+		}
+	}
+	return nil
+}
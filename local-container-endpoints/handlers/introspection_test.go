@@ -0,0 +1,119 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenIntrospection(t *testing.T) {
+	defer os.Unsetenv(config.IntrospectionPortVar)
+
+	listeners, err := ListenIntrospection()
+	assert.NoError(t, err, "Unexpected error listening at introspection port")
+	assert.Empty(t, listeners, "Expected no listener when the introspection port is not set")
+
+	os.Setenv(config.IntrospectionPortVar, "0")
+	listeners, err = ListenIntrospection()
+	assert.NoError(t, err, "Unexpected error listening at introspection port")
+	assert.Len(t, listeners, 1, "Expected a listener when the introspection port is set")
+	closeListeners(listeners)
+
+	os.Setenv(config.IntrospectionPortVar, "port")
+	_, err = ListenIntrospection()
+	assert.Error(t, err, "Expected error for an invalid introspection port")
+}
+
+func TestIntrospectionMetadata(t *testing.T) {
+	router := mux.NewRouter()
+	(&MetadataService{}).SetupIntrospectionRoutes(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.IntrospectionMetadataPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+
+	response := &v1.MetadataResponse{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response), "Unexpected error unmarshalling response")
+	assert.Equal(t, config.DefaultClusterName, response.Cluster, "Expected cluster to match")
+	assert.NotNil(t, response.ContainerInstanceArn, "Expected container instance ARN")
+}
+
+func TestIntrospectionTasks(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network1, ipAddress1).WithComposeProject(projectName).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName).Get()
+	container3 := testingutils.BaseDockerContainer(containerName3, longID3).WithNetwork(network2, ipAddress3).WithComposeProject(projectName2).Get()
+
+	var testCases = []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedTasks int
+		expectedIDs   []string
+	}{
+		{"all tasks", "", http.StatusOK, 2, nil},
+		{"by docker ID", "?dockerid=" + longID3[:12], http.StatusOK, 0, []string{longID3}},
+		{"by task ARN", "?taskarn=arn:aws:ecs:us-west-2:111111111111:task/ecs-local-cluster/e14298f5-b033-5935-9a4e-d380c7a38ecd", http.StatusOK, 0, []string{longID1, longID2}},
+		{"unknown docker ID", "?dockerid=abc123", http.StatusNotFound, 0, nil},
+		{"both parameters", "?dockerid=abc123&taskarn=arn", http.StatusBadRequest, 0, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			dockerMock := mock_docker.NewMockClient(ctrl)
+			if testCase.expectedCode != http.StatusBadRequest {
+				dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{container1, container2, container3}, nil)
+			}
+			service, err := NewMetadataServiceWithClient(dockerMock)
+			assert.NoError(t, err, "Unexpected error creating metadata service")
+			router := mux.NewRouter()
+			service.SetupIntrospectionRoutes(router)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.IntrospectionTasksPath+testCase.query, nil))
+			assert.Equal(t, testCase.expectedCode, recorder.Code, "Expected http status code to match")
+			if testCase.expectedCode != http.StatusOK {
+				return
+			}
+
+			if testCase.expectedIDs == nil {
+				response := &v1.TasksResponse{}
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response), "Unexpected error unmarshalling response")
+				assert.Len(t, response.Tasks, testCase.expectedTasks, "Expected a task for each compose project")
+				return
+			}
+			task := &v1.TaskResponse{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), task), "Unexpected error unmarshalling response")
+			var ids []string
+			for _, container := range task.Containers {
+				ids = append(ids, container.DockerID)
+			}
+			assert.ElementsMatch(t, testCase.expectedIDs, ids, "Expected the containers of the task")
+		})
+	}
+}
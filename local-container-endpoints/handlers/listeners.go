@@ -0,0 +1,195 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// like the TCP port, the socket can be used by any local process or container which it is mounted into
+const socketFileMode = 0666
+
+// Listen returns the listeners for the endpoints: a unix socket if its path is set,
+// and the TCP port, unless only the socket is configured. The port serves HTTPS if a certificate is set.
+// The credentials API is served at these listeners too, unless it has its own port.
+// If the process was socket activated by systemd, the sockets it passed are used instead.
+func Listen() ([]net.Listener, error) {
+	tlsConfig, err := getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if isSocketActivated() {
+		listeners, _, err := getActivatedListeners(tlsConfig)
+		return listeners, err
+	}
+
+	var listeners []net.Listener
+	socketPath := os.Getenv(config.SocketPathVar)
+	if socketPath != "" {
+		listener, err := ListenUnix(socketPath)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Listening at unix socket %s", socketPath)
+		listeners = append(listeners, listener)
+	}
+
+	if socketPath == "" || os.Getenv(config.PortVar) != "" {
+		port := utils.GetValue(config.DefaultPort, config.PortVar)
+		hosts := getBindAddresses(config.BindAddressVar)
+		if os.Getenv(config.CredentialsPortVar) == "" {
+			hosts = withIMDSIPv6Address(hosts)
+		}
+		tcpListeners, err := listenTCP(port, hosts, tlsConfig)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, tcpListeners...)
+	}
+	return listeners, nil
+}
+
+// ListenCredentials returns the listeners for the credentials API if it has its own port, or the sockets named credentials
+// from systemd, or nil if it is served with the metadata API
+func ListenCredentials() ([]net.Listener, error) {
+	tlsConfig, err := getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if isSocketActivated() {
+		_, credentialsListeners, err := getActivatedListeners(tlsConfig)
+		return credentialsListeners, err
+	}
+	port := os.Getenv(config.CredentialsPortVar)
+	if port == "" {
+		return nil, nil
+	}
+	hosts := getBindAddresses(config.CredentialsBindAddressVar)
+	if len(hosts) == 0 {
+		hosts = getBindAddresses(config.BindAddressVar)
+	}
+	logrus.Infof("Serving the credentials API at port %s", port)
+	return listenTCP(port, withIMDSIPv6Address(hosts), tlsConfig)
+}
+
+// listenTCP listens at the port on each of the hosts, or on all interfaces if there are none, with HTTPS if tlsConfig is set.
+// Listening on all interfaces includes both IPv4 and IPv6, where the host supports it.
+func listenTCP(port string, hosts []string, tlsConfig *tls.Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, address := range getListenAddresses(port, hosts) {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, errors.Wrapf(err, "Failed to listen at %s", address)
+		}
+		if tlsConfig != nil {
+			listener = tls.NewListener(listener, tlsConfig)
+			logrus.Infof("Listening at %s with HTTPS", listener.Addr())
+		} else {
+			logrus.Infof("Listening at %s", listener.Addr())
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// ListenUnix listens at the unix socket; a socket left behind at the path by a previous run is removed
+func ListenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, errors.Wrapf(err, "Failed to remove the old unix socket %s", path)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to listen at unix socket %s", path)
+	}
+	if err := os.Chmod(path, socketFileMode); err != nil {
+		listener.Close()
+		return nil, errors.Wrapf(err, "Failed to set the permissions of unix socket %s", path)
+	}
+	return listener, nil
+}
+
+// getListenAddresses returns the addresses to listen at for the port on each of the hosts, or on all interfaces if there are none
+func getListenAddresses(port string, hosts []string) []string {
+	var addresses []string
+	for _, host := range hosts {
+		addresses = append(addresses, net.JoinHostPort(host, port))
+	}
+	if len(addresses) == 0 {
+		return []string{net.JoinHostPort("", port)}
+	}
+	return addresses
+}
+
+// getBindAddresses returns the comma separated addresses in the environment variable
+func getBindAddresses(envVar string) []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv(envVar), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// withIMDSIPv6Address adds the IPv6 address of the instance metadata service to the hosts which serve credentials,
+// if it is enabled and other hosts are set, since otherwise all interfaces are used
+func withIMDSIPv6Address(hosts []string) []string {
+	if len(hosts) > 0 && isIMDSIPv6Enabled() {
+		return append(hosts, config.IMDSIPv6Address)
+	}
+	return hosts
+}
+
+func isIMDSIPv6Enabled() bool {
+	return strings.ToLower(os.Getenv(config.IMDSIPv6Var)) == "true"
+}
+
+// getTLSConfig returns the TLS config with the certificate and key files, or nil if they are not set
+func getTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv(config.TLSCertFileVar)
+	keyFile := os.Getenv(config.TLSKeyFileVar)
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("Both %s and %s must be set to serve HTTPS", config.TLSCertFileVar, config.TLSKeyFileVar)
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to load the TLS certificate %s", certFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
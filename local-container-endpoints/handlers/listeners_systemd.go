@@ -0,0 +1,96 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// listenFDsStart is the first file descriptor passed by systemd, after stdin, stdout and stderr
+	listenFDsStart = 3
+	// credentialsSocketName is the FileDescriptorName of the sockets which serve the credentials API
+	credentialsSocketName = "credentials"
+)
+
+// the sockets passed by systemd can only be used once, so they are shared by Listen and ListenCredentials
+var activatedSockets struct {
+	once                 sync.Once
+	listeners            []net.Listener
+	credentialsListeners []net.Listener
+	err                  error
+}
+
+// getActivatedListeners returns the sockets passed by systemd socket activation, with HTTPS for TCP sockets if tlsConfig is set;
+// the sockets named credentials serve the credentials API, and the others serve the metadata API. Both are empty if the process
+// was not socket activated.
+func getActivatedListeners(tlsConfig *tls.Config) ([]net.Listener, []net.Listener, error) {
+	activatedSockets.once.Do(func() {
+		activatedSockets.listeners, activatedSockets.credentialsListeners, activatedSockets.err = listenActivatedSockets(listenFDsStart, tlsConfig)
+	})
+	return activatedSockets.listeners, activatedSockets.credentialsListeners, activatedSockets.err
+}
+
+// isSocketActivated returns true if systemd passed sockets to this process; like sd_listen_fds, they are ignored unless
+// LISTEN_PID is this process, so that a child process which inherits the variables does not take over the sockets
+func isSocketActivated() bool {
+	return os.Getenv(config.ListenFDsVar) != "" && os.Getenv(config.ListenPIDVar) == strconv.Itoa(os.Getpid())
+}
+
+// listenActivatedSockets creates listeners for the file descriptors from firstFD onwards, in the same way as sd_listen_fds
+func listenActivatedSockets(firstFD int, tlsConfig *tls.Config) ([]net.Listener, []net.Listener, error) {
+	value := os.Getenv(config.ListenFDsVar)
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 1 {
+		return nil, nil, fmt.Errorf("Invalid %s: expected a number of sockets, got %s", config.ListenFDsVar, value)
+	}
+	names := strings.Split(os.Getenv(config.ListenFDNamesVar), ":")
+
+	var listeners, credentialsListeners []net.Listener
+	for i := 0; i < count; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(firstFD+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			closeListeners(listeners)
+			closeListeners(credentialsListeners)
+			return nil, nil, errors.Wrapf(err, "Failed to listen at the socket %d passed by systemd", firstFD+i)
+		}
+		if tlsConfig != nil && listener.Addr().Network() == "tcp" {
+			listener = tls.NewListener(listener, tlsConfig)
+		}
+		if name == credentialsSocketName {
+			logrus.Infof("Serving the credentials API at %s from systemd", listener.Addr())
+			credentialsListeners = append(credentialsListeners, listener)
+		} else {
+			logrus.Infof("Listening at %s from systemd", listener.Addr())
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners, credentialsListeners, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSocketFDInTest returns the file descriptor of a new TCP socket, as if it was passed by systemd
+func newSocketFDInTest(t *testing.T) (int, net.Addr) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "Unexpected error listening")
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	assert.NoError(t, err, "Unexpected error getting the socket file")
+	return int(file.Fd()), listener.Addr()
+}
+
+func TestIsSocketActivated(t *testing.T) {
+	defer os.Unsetenv(config.ListenFDsVar)
+	defer os.Unsetenv(config.ListenPIDVar)
+
+	assert.False(t, isSocketActivated(), "Expected no socket activation by default")
+
+	os.Setenv(config.ListenFDsVar, "1")
+	assert.False(t, isSocketActivated(), "Expected no socket activation without LISTEN_PID")
+
+	os.Setenv(config.ListenPIDVar, strconv.Itoa(os.Getpid()))
+	assert.True(t, isSocketActivated(), "Expected socket activation for this process")
+
+	os.Setenv(config.ListenPIDVar, strconv.Itoa(os.Getpid()+1))
+	assert.False(t, isSocketActivated(), "Expected the sockets of another process to be ignored")
+}
+
+func TestListenActivatedSockets(t *testing.T) {
+	defer os.Unsetenv(config.ListenFDsVar)
+	defer os.Unsetenv(config.ListenFDNamesVar)
+	os.Setenv(config.ListenFDsVar, "1")
+
+	fd, addr := newSocketFDInTest(t)
+	listeners, credentialsListeners, err := listenActivatedSockets(fd, nil)
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 1, "Expected the socket to serve the metadata API")
+	assert.Len(t, credentialsListeners, 0, "Expected no credentials sockets")
+	assert.Equal(t, addr.String(), listeners[0].Addr().String(), "Expected the address of the socket")
+	closeListeners(listeners)
+
+	os.Setenv(config.ListenFDNamesVar, credentialsSocketName)
+	fd, _ = newSocketFDInTest(t)
+	listeners, credentialsListeners, err = listenActivatedSockets(fd, nil)
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 0, "Expected no metadata sockets")
+	assert.Len(t, credentialsListeners, 1, "Expected the named socket to serve the credentials API")
+	closeListeners(credentialsListeners)
+
+	os.Setenv(config.ListenFDsVar, "none")
+	_, _, err = listenActivatedSockets(listenFDsStart, nil)
+	assert.Error(t, err, "Expected error for an invalid LISTEN_FDS")
+}
@@ -0,0 +1,294 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "endpoints.sock")
+
+	// a socket left behind by a previous run is replaced
+	old, err := net.Listen("unix", path)
+	assert.NoError(t, err, "Unexpected error creating old socket")
+	if unixListener, ok := old.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+	old.Close()
+
+	listener, err := ListenUnix(path)
+	assert.NoError(t, err, "Unexpected error listening at unix socket")
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err, "Unexpected error reading socket")
+	assert.Equal(t, os.FileMode(socketFileMode), info.Mode().Perm(), "Expected socket permissions to match")
+
+	server := http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		},
+	}
+	res, err := client.Get("http://localhost" + config.TempCredentialsPath)
+	assert.NoError(t, err, "Unexpected error making HTTP Request")
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading response")
+	assert.Equal(t, "ok", string(body), "Expected response to match")
+}
+
+func TestListenUnixNotASocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "endpoints.sock")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600), "Unexpected error writing file")
+
+	_, err = ListenUnix(path)
+	assert.Error(t, err, "Expected error for a file which is not a socket")
+	_, err = os.Stat(path)
+	assert.NoError(t, err, "Expected the file to not be removed")
+}
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(config.SocketPathVar)
+	defer os.Unsetenv(config.PortVar)
+
+	// only the socket
+	os.Setenv(config.SocketPathVar, filepath.Join(dir, "endpoints.sock"))
+	os.Unsetenv(config.PortVar)
+	listeners, err := Listen()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 1, "Expected only the unix socket")
+	assert.Equal(t, "unix", listeners[0].Addr().Network(), "Expected a unix socket")
+	closeListeners(listeners)
+
+	// the socket, and the port which is set explicitly
+	os.Setenv(config.PortVar, "0")
+	listeners, err = Listen()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 2, "Expected the unix socket and the port")
+	assert.Equal(t, "tcp", listeners[1].Addr().Network(), "Expected a TCP listener")
+	closeListeners(listeners)
+}
+
+func TestListenTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	certFile, keyFile, certificate := writeCertificateInTest(t, dir)
+	defer os.Unsetenv(config.PortVar)
+	defer os.Unsetenv(config.TLSCertFileVar)
+	defer os.Unsetenv(config.TLSKeyFileVar)
+
+	os.Setenv(config.PortVar, "0")
+	os.Setenv(config.TLSCertFileVar, certFile)
+	os.Setenv(config.TLSKeyFileVar, keyFile)
+	listeners, err := Listen()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 1, "Expected only the port")
+
+	server := http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}),
+	}
+	go server.Serve(listeners[0])
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: roots,
+			},
+		},
+	}
+	port := listeners[0].Addr().(*net.TCPAddr).Port
+	res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d%s", port, config.TempCredentialsPath))
+	assert.NoError(t, err, "Unexpected error making HTTPS Request")
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(t, err, "Unexpected error reading response")
+	assert.Equal(t, "ok", string(body), "Expected response to match")
+}
+
+func TestListenTLSMissingKey(t *testing.T) {
+	defer os.Unsetenv(config.TLSCertFileVar)
+
+	os.Setenv(config.TLSCertFileVar, "/tmp/cert.pem")
+	_, err := Listen()
+	assert.Error(t, err, "Expected error when only the certificate is set")
+}
+
+// writeCertificateInTest writes a self-signed certificate for 127.0.0.1 and its key to the directory
+func writeCertificateInTest(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err, "Unexpected error generating key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ecs-local-endpoints"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err, "Unexpected error creating certificate")
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err, "Unexpected error parsing certificate")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err, "Unexpected error marshalling key")
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	assert.NoError(t, err, "Unexpected error writing certificate")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	assert.NoError(t, err, "Unexpected error writing key")
+	return certFile, keyFile, certificate
+}
+
+func TestListenBindAddress(t *testing.T) {
+	defer os.Unsetenv(config.PortVar)
+	defer os.Unsetenv(config.BindAddressVar)
+
+	os.Setenv(config.PortVar, "0")
+	os.Setenv(config.BindAddressVar, "127.0.0.1")
+	listeners, err := Listen()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 1, "Expected only the port")
+	assert.Equal(t, "127.0.0.1", listeners[0].Addr().(*net.TCPAddr).IP.String(), "Expected to listen at the bind address")
+	closeListeners(listeners)
+
+	os.Setenv(config.BindAddressVar, "192.0.2.1")
+	_, err = Listen()
+	assert.Error(t, err, "Expected error for an address which is not on this machine")
+}
+
+func TestGetListenAddresses(t *testing.T) {
+	defer os.Unsetenv(config.BindAddressVar)
+	defer os.Unsetenv(config.IMDSIPv6Var)
+
+	var testCases = []struct {
+		bindAddress string
+		imdsIPv6    string
+		expected    []string
+	}{
+		{"", "", []string{":80"}},
+		{"", "true", []string{":80"}},
+		{"169.254.170.2", "", []string{"169.254.170.2:80"}},
+		{"fd00::2", "", []string{"[fd00::2]:80"}},
+		{"169.254.170.2, fd00::2", "", []string{"169.254.170.2:80", "[fd00::2]:80"}},
+		{"169.254.170.2", "true", []string{"169.254.170.2:80", "[fd00:ec2::254]:80"}},
+	}
+
+	for _, testCase := range testCases {
+		os.Setenv(config.BindAddressVar, testCase.bindAddress)
+		os.Setenv(config.IMDSIPv6Var, testCase.imdsIPv6)
+		hosts := withIMDSIPv6Address(getBindAddresses(config.BindAddressVar))
+		assert.Equal(t, testCase.expected, getListenAddresses("80", hosts), "Expected addresses to match for %q", testCase.bindAddress)
+	}
+}
+
+func TestListenDualStack(t *testing.T) {
+	defer os.Unsetenv(config.PortVar)
+	defer os.Unsetenv(config.BindAddressVar)
+
+	if listener, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("IPv6 is not supported on this host")
+	} else {
+		listener.Close()
+	}
+
+	os.Setenv(config.PortVar, "0")
+	os.Setenv(config.BindAddressVar, "127.0.0.1,::1")
+	listeners, err := Listen()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 2, "Expected a listener for each address")
+	if len(listeners) == 2 {
+		assert.Equal(t, "127.0.0.1", listeners[0].Addr().(*net.TCPAddr).IP.String(), "Expected to listen at the IPv4 address")
+		assert.Equal(t, "::1", listeners[1].Addr().(*net.TCPAddr).IP.String(), "Expected to listen at the IPv6 address")
+	}
+	closeListeners(listeners)
+}
+
+func TestListenCredentials(t *testing.T) {
+	defer os.Unsetenv(config.PortVar)
+	defer os.Unsetenv(config.CredentialsPortVar)
+	defer os.Unsetenv(config.BindAddressVar)
+	defer os.Unsetenv(config.CredentialsBindAddressVar)
+	defer os.Unsetenv(config.IMDSIPv6Var)
+
+	listeners, err := ListenCredentials()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Empty(t, listeners, "Expected no listeners when the credentials port is not set")
+
+	// the credentials bind address overrides the bind address
+	os.Setenv(config.CredentialsPortVar, "0")
+	os.Setenv(config.BindAddressVar, "192.0.2.1")
+	os.Setenv(config.CredentialsBindAddressVar, "127.0.0.1")
+	listeners, err = ListenCredentials()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 1, "Expected a listener for the credentials bind address")
+	if len(listeners) == 1 {
+		assert.Equal(t, "127.0.0.1", listeners[0].Addr().(*net.TCPAddr).IP.String(), "Expected to listen at the credentials bind address")
+	}
+	closeListeners(listeners)
+
+	// the IMDS address is only added to the credentials listeners
+	os.Setenv(config.PortVar, "0")
+	os.Setenv(config.BindAddressVar, "127.0.0.1")
+	os.Setenv(config.IMDSIPv6Var, "true")
+	listeners, err = Listen()
+	assert.NoError(t, err, "Unexpected error listening")
+	assert.Len(t, listeners, 1, "Expected only the bind address for the metadata API")
+	closeListeners(listeners)
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	textLogFormat = "text"
+	jsonLogFormat = "json"
+)
+
+type requestLogFieldsKey struct{}
+
+// SetupLogFormat sets the format of the standard log from the log format environment variable
+func SetupLogFormat() error {
+	switch format := strings.ToLower(os.Getenv(config.LogFormatVar)); format {
+	case "", textLogFormat:
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case jsonLogFormat:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("Invalid value for %s: %s; expected '%s' or '%s'", config.LogFormatVar, format, textLogFormat, jsonLogFormat)
+	}
+	return nil
+}
+
+// SetupLogLevel sets the level of the standard log from the log level environment variable
+func SetupLogLevel() error {
+	value := os.Getenv(config.LogLevelVar)
+	if value == "" {
+		logrus.SetLevel(logrus.InfoLevel)
+		return nil
+	}
+	level, err := logrus.ParseLevel(value)
+	if err != nil {
+		return fmt.Errorf("Invalid value for %s: %s", config.LogLevelVar, err)
+	}
+	logrus.SetLevel(level)
+	return nil
+}
+
+// LogRequests is middleware which logs each request once it has been handled, along with its status and latency;
+// handlers can add fields to the entry with setRequestLogFields
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		fields := logrus.Fields{
+			"method":    r.Method,
+			"path":      r.URL.Path,
+			"caller_ip": getCallerIP(r),
+		}
+		if requestID := tracing.RequestID(r.Context()); requestID != "" {
+			fields["request_id"] = requestID
+		}
+		if traceID := tracing.TraceID(r.Context()); traceID != "" {
+			fields["trace_id"] = traceID
+		}
+		recorder := &statusRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogFieldsKey{}, fields)))
+
+		fields["status"] = recorder.status
+		fields["latency_ms"] = float64(time.Since(start)) / float64(time.Millisecond)
+		logrus.WithFields(fields).Info("Handled request")
+	})
+}
+
+// setRequestLogFields adds the fields to the request's log entry
+func setRequestLogFields(r *http.Request, fields logrus.Fields) {
+	requestFields, ok := r.Context().Value(requestLogFieldsKey{}).(logrus.Fields)
+	if !ok {
+		return
+	}
+	for key, value := range fields {
+		requestFields[key] = value
+	}
+}
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	// wroteHeader is whether the response has started
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.wroteHeader = true
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(b []byte) (int, error) {
+	recorder.wroteHeader = true
+	return recorder.ResponseWriter.Write(b)
+}
+
+// Flush allows container stats to be streamed through the recorder
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
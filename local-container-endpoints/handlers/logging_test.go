@@ -0,0 +1,111 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupLogFormat(t *testing.T) {
+	defer os.Unsetenv(config.LogFormatVar)
+	defer logrus.SetFormatter(&logrus.TextFormatter{})
+
+	os.Setenv(config.LogFormatVar, "JSON")
+	err := SetupLogFormat()
+	assert.NoError(t, err, "Unexpected error setting up the log format")
+	assert.IsType(t, &logrus.JSONFormatter{}, logrus.StandardLogger().Formatter, "Expected the JSON formatter")
+
+	os.Setenv(config.LogFormatVar, "text")
+	err = SetupLogFormat()
+	assert.NoError(t, err, "Unexpected error setting up the log format")
+	assert.IsType(t, &logrus.TextFormatter{}, logrus.StandardLogger().Formatter, "Expected the text formatter")
+
+	os.Setenv(config.LogFormatVar, "xml")
+	err = SetupLogFormat()
+	assert.Error(t, err, "Expected an error for an invalid log format")
+}
+
+func TestSetupLogLevel(t *testing.T) {
+	defer os.Unsetenv(config.LogLevelVar)
+	defer logrus.SetLevel(logrus.InfoLevel)
+
+	os.Setenv(config.LogLevelVar, "debug")
+	err := SetupLogLevel()
+	assert.NoError(t, err, "Unexpected error setting up the log level")
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel(), "Expected the debug level")
+
+	os.Setenv(config.LogLevelVar, "")
+	err = SetupLogLevel()
+	assert.NoError(t, err, "Unexpected error setting up the log level")
+	assert.Equal(t, logrus.InfoLevel, logrus.GetLevel(), "Expected the info level by default")
+
+	os.Setenv(config.LogLevelVar, "loud")
+	err = SetupLogLevel()
+	assert.Error(t, err, "Expected an error for an invalid log level")
+}
+
+func TestLogRequests(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logrus.SetOutput(buffer)
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	defer logrus.SetOutput(os.Stderr)
+	defer logrus.SetFormatter(&logrus.TextFormatter{})
+
+	handler := LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setRequestLogFields(r, logrus.Fields{
+			"role_arn":       "arn:aws:iam::111111111111:role/my-role",
+			"container_name": "my-app",
+		})
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok, "Expected the response writer to support flushing")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, config.ContainerRoleCredentialsPath, nil)
+	request.RemoteAddr = "172.17.0.2:40000"
+	request = request.WithContext(tracing.WithRequestID(request.Context(), "abc"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Expected http status code to be 404")
+
+	entry := make(map[string]interface{})
+	err := json.Unmarshal(buffer.Bytes(), &entry)
+	assert.NoError(t, err, "Unexpected error unmarshalling log entry")
+	assert.Equal(t, "Handled request", entry["msg"], "Expected message to match")
+	assert.Equal(t, http.MethodGet, entry["method"], "Expected method to match")
+	assert.Equal(t, config.ContainerRoleCredentialsPath, entry["path"], "Expected path to match")
+	assert.Equal(t, "172.17.0.2", entry["caller_ip"], "Expected caller IP to match")
+	assert.Equal(t, "abc", entry["request_id"], "Expected request ID to match")
+	assert.Equal(t, float64(http.StatusNotFound), entry["status"], "Expected status to match")
+	assert.Equal(t, "arn:aws:iam::111111111111:role/my-role", entry["role_arn"], "Expected role ARN to match")
+	assert.Equal(t, "my-app", entry["container_name"], "Expected container name to match")
+	assert.Contains(t, entry, "latency_ms", "Expected latency to be logged")
+}
+
+func TestSetRequestLogFieldsWithoutMiddleware(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, config.TempCredentialsPath, nil)
+	setRequestLogFields(request, logrus.Fields{
+		"role_arn": "arn:aws:iam::111111111111:role/my-role",
+	})
+}
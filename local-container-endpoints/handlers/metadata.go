@@ -15,57 +15,150 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/kubernetes"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	composeProjectNameLabel = "com.docker.compose.project"
-)
-
 const (
 	requestTypeContainerMetadata = iota + 1
 	requestTypeContainerStats
 	requestTypeTaskMetadata
 	requestTypeTaskStats
+	requestTypeV4ContainerMetadata
+	requestTypeV4TaskMetadata
+	requestTypeV4ContainerStats
+	requestTypeV4TaskStats
+	requestTypeV4TaskMetadataWithTags
 )
 
-func (service *MetadataService) containerStatsResponse(w http.ResponseWriter, identifier string, callerIP string) error {
+func (service *MetadataService) containerStatsResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
+	_, stats, err := service.getContainerStats(ctx, identifier, callerIP)
+	if err != nil {
+		return err
+	}
+
+	writeJSONResponse(w, stats)
+	return nil
+}
+
+func (service *MetadataService) v4ContainerStatsResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
+	containerID, stats, err := service.getContainerStats(ctx, identifier, callerIP)
+	if err != nil {
+		return err
+	}
+
+	response := metadata.GetV4Stats(stats, service.statsHistory.swap(containerID, stats))
+
+	writeJSONResponse(w, response)
+	return nil
+}
+
+func isContainerStatsRequest(requestType int) bool {
+	return requestType == requestTypeContainerStats || requestType == requestTypeV4ContainerStats
+}
+
+// streamContainerStatsResponse writes each sample from the Docker stats stream as it arrives,
+// until the stream ends or the client disconnects
+func (service *MetadataService) streamContainerStatsResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string, v4 bool) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("Streaming is not supported by the HTTP response writer")
+	}
+
 	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	containers, err := service.dockerClient.ContainerList(ctx)
+	containers, err := service.dockerClient.ContainerList(listCtx)
 	if err != nil {
 		return errors.Wrap(err, "failed to list running containers")
 	}
-
 	container, err := findContainer(containers, identifier, callerIP)
 	if err != nil {
 		return err
 	}
 
+	statsChan := make(chan *types.StatsJSON)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- service.dockerClient.ContainerStatsStream(ctx, container.ID, statsChan)
+	}()
+
+	encoder := json.NewEncoder(w)
+	var previous *types.StatsJSON
+	streaming := false
+	for {
+		select {
+		case stats := <-statsChan:
+			if !streaming {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				streaming = true
+			}
+			var response interface{} = stats
+			if v4 {
+				response = metadata.GetV4Stats(stats, previous)
+				previous = stats
+			}
+			if err := encoder.Encode(response); err != nil {
+				// the client has gone away; the stream stops once the request context is cancelled
+				logrus.Debugf("Failed to write stats for container %s: %s", container.ID, err)
+			}
+			flusher.Flush()
+		case err := <-errChan:
+			if err == nil {
+				return nil
+			}
+			if !streaming {
+				return errors.Wrap(err, "failed to stream container stats")
+			}
+			// the response has already started, so the error can't be returned to the client
+			logrus.Errorf("Stats stream for container %s ended: %s", container.ID, err)
+			return nil
+		}
+	}
+}
+
+// getContainerStats returns the ID of the container the request is for, and its stats
+func (service *MetadataService) getContainerStats(ctx context.Context, identifier string, callerIP string) (string, *types.StatsJSON, error) {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to list running containers")
+	}
+
+	container, err := findContainer(containers, identifier, callerIP)
+	if err != nil {
+		return "", nil, err
+	}
+
 	stats, err := service.dockerClient.ContainerStats(ctx, container.ID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get container stats")
+		return "", nil, errors.Wrap(err, "failed to get container stats")
 	}
 
-	writeJSONResponse(w, stats)
-	return nil
+	return container.ID, stats, nil
 }
 
-func (service *MetadataService) containerMetadataResponse(w http.ResponseWriter, identifier string, callerIP string) error {
+func (service *MetadataService) containerMetadataResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
 	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	containers, err := service.dockerClient.ContainerList(ctx)
@@ -78,38 +171,150 @@ func (service *MetadataService) containerMetadataResponse(w http.ResponseWriter,
 	}
 
 	response := metadata.GetContainerMetadata(container)
+	metadata.ApplyContainerDefinition(service.taskDefinition, response)
 
 	writeJSONResponse(w, response)
 	return nil
 }
 
-func (service *MetadataService) taskMetadataResponse(w http.ResponseWriter, identifier string, callerIP string) error {
+func (service *MetadataService) taskMetadataResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
 	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	containers, err := service.dockerClient.ContainerList(ctx)
 	if err != nil {
 		return err
 	}
-	taskContainers := getTaskContainers(containers, identifier, callerIP)
+	taskContainers, err := getTaskContainers(containers, identifier, callerIP, service.unknownCallerAllContainers)
+	if err != nil {
+		return err
+	}
 
-	response := metadata.GetTaskMetadata(taskContainers, service.containerInstanceTags, service.taskTags)
+	response := metadata.GetTaskMetadata(taskContainers, nil, nil)
+	metadata.AddComposeServices(service.composeProject, response)
+	metadata.ApplyTaskDefinition(service.taskDefinition, response)
 
 	writeJSONResponse(w, response)
 	return nil
 }
 
-func (service *MetadataService) taskStatsResponse(w http.ResponseWriter, identifier string, callerIP string) error {
+func (service *MetadataService) v4ContainerMetadataResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
 	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Failed to list running containers")
+	}
+	container, err := findContainer(containers, identifier, callerIP)
+	if err != nil {
+		return err
+	}
+
+	containerDetails, err := service.dockerClient.ContainerInspect(ctx, container.ID)
 	if err != nil {
 		return err
 	}
-	response := make(map[string]types.Stats)
+
+	response := metadata.GetV4ContainerMetadata(container, containerDetails)
+	metadata.ApplyV4ContainerDefinition(service.taskDefinition, response)
+	metadata.ApplyV4FirelensConfiguration(service.taskDefinition, service.firelensConfigurations, response)
+
+	writeJSONResponse(w, response)
+	return nil
+}
+
+// v4TaskMetadataResponse writes the V4 task metadata, which includes the tags if withTags is set, as with the taskWithTags path
+func (service *MetadataService) v4TaskMetadataResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string, withTags bool) error {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return err
+	}
+	taskContainers, err := getTaskContainers(containers, identifier, callerIP, service.unknownCallerAllContainers)
+	if err != nil {
+		return err
+	}
+
+	containerDetails := make(map[string]*types.ContainerJSON)
+	for _, container := range taskContainers {
+		// the size of the writable layer is the ephemeral storage utilized by the task
+		details, err := service.dockerClient.ContainerInspectWithSize(ctx, container.ID)
+		if err != nil {
+			return err
+		}
+		containerDetails[container.ID] = details
+	}
+
+	var containerInstanceTags, taskTags map[string]string
+	if withTags {
+		containerInstanceTags, taskTags, err = getTags(taskContainers)
+		if err != nil {
+			return err
+		}
+	}
+
+	ephemeralStorageSize, err := getEphemeralStorageSize()
+	if err != nil {
+		return err
+	}
+
+	response := metadata.GetV4TaskMetadata(taskContainers, containerDetails, containerInstanceTags, taskTags, ephemeralStorageSize)
+	metadata.AddV4ComposeServices(service.composeProject, response)
+	metadata.ApplyV4TaskDefinition(service.taskDefinition, response)
+	for i := range response.Containers {
+		metadata.ApplyV4FirelensConfiguration(service.taskDefinition, service.firelensConfigurations, &response.Containers[i])
+	}
+
+	writeJSONResponse(w, response)
+	return nil
+}
+
+func (service *MetadataService) taskStatsResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
+	response, err := service.getTaskStats(ctx, identifier, callerIP)
+	if err != nil {
+		return err
+	}
+
+	writeJSONResponse(w, response)
+	return nil
+}
+
+func (service *MetadataService) v4TaskStatsResponse(ctx context.Context, w http.ResponseWriter, identifier string, callerIP string) error {
+	stats, err := service.getTaskStats(ctx, identifier, callerIP)
+	if err != nil {
+		return err
+	}
+
+	response := make(map[string]*metadata.V4StatsResponse)
+	for containerID, containerStats := range stats {
+		response[containerID] = metadata.GetV4Stats(containerStats, service.statsHistory.swap(containerID, containerStats))
+	}
+
+	writeJSONResponse(w, response)
+	return nil
+}
+
+// getTaskStats returns the stats for each container in the caller's local 'task', keyed by container ID
+func (service *MetadataService) getTaskStats(ctx context.Context, identifier string, callerIP string) (map[string]*types.StatsJSON, error) {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers, err = getTaskContainers(containers, identifier, callerIP, service.unknownCallerAllContainers)
+	if err != nil {
+		return nil, err
+	}
+	response := make(map[string]*types.StatsJSON)
 
 	statsChan := make(chan dockerStats, len(containers))
 
@@ -120,7 +325,7 @@ func (service *MetadataService) taskStatsResponse(w http.ResponseWriter, identif
 	for range containers {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, ctx.Err()
 		case stats := <-statsChan:
 			if stats.err != nil {
 				// cancel the context
@@ -131,20 +336,19 @@ func (service *MetadataService) taskStatsResponse(w http.ResponseWriter, identif
 				// Also calling cancel() ends the context,
 				// so none of the Docker API requests can get stuck.
 				// This also applies for the above case where we return ctx.Err().
-				return stats.err
+				return nil, stats.err
 			}
-			response[stats.containerID] = *stats.stats
+			response[stats.containerID] = stats.stats
 		}
 	}
 
-	writeJSONResponse(w, response)
-	return nil
+	return response, nil
 }
 
 // simple struct that () sends over a channel
 type dockerStats struct {
 	containerID string
-	stats       *types.Stats
+	stats       *types.StatsJSON
 	err         error
 }
 
@@ -161,30 +365,38 @@ func (service *MetadataService) getContainerStatsWithChannel(ctx context.Context
 }
 
 // A Local 'Task' is defined as all containers in the same Docker Compose Project as the caller container
-// OR all containers running on this machine if the user is not using Compose
-func getTaskContainers(allContainers []types.Container, identifier string, callerIP string) []types.Container {
-	callerContainer, err := findContainer(allContainers, identifier, callerIP)
+// OR just the caller container if it is not in a Compose Project, since each ECS Task only sees itself.
+// If the caller container can not be found, the request is rejected with HTTP 404, unless allContainers is set,
+// in which case all containers running on this machine are used.
+func getTaskContainers(dockerContainers []types.Container, identifier string, callerIP string, allContainers bool) ([]types.Container, error) {
+	callerContainer, err := findContainer(dockerContainers, identifier, callerIP)
 	if err != nil {
-		logrus.Warn(err)
-		logrus.Info("Will use all containers to represent one 'local task'")
-		return allContainers
+		if allContainers {
+			logrus.Warn(err)
+			logrus.Info("Will use all containers to represent one 'local task'")
+			return dockerContainers, nil
+		}
+		return nil, HTTPError{
+			Code: http.StatusNotFound,
+			Err:  errors.Wrapf(err, "Failed to find the local 'task' of the caller; request task metadata from a container, or set %s=true to use all running containers", config.UnknownCallerAllContainersVar),
+		}
 	}
 
-	projectName := callerContainer.Labels[composeProjectNameLabel]
+	projectName := callerContainer.Labels[metadata.ComposeProjectLabel]
 
 	if projectName == "" {
-		logrus.Info("Will use all containers to represent one 'local task': The container which made the request is not in a Docker Compose Project")
-		return allContainers
+		logrus.Debugf("Using only container %s as the 'local task': The container which made the request is not in a Docker Compose Project", callerContainer.ID)
+		return []types.Container{*callerContainer}, nil
 	}
 
-	return filterByComposeProject(allContainers, projectName)
+	return filterByComposeProject(dockerContainers, projectName), nil
 }
 
 func filterByComposeProject(dockerContainers []types.Container, projectName string) []types.Container {
 	var filteredContainers []types.Container
 
 	for _, container := range dockerContainers {
-		if container.Labels[composeProjectNameLabel] == projectName {
+		if container.Labels[metadata.ComposeProjectLabel] == projectName {
 			filteredContainers = append(filteredContainers, container)
 		}
 	}
@@ -230,6 +442,54 @@ func findContainer(dockerContainers []types.Container, identifier string, caller
 	return nil, fmt.Errorf("Failed to find the container which the request came from. Narrowed down search to %d containers", len(filteredList))
 }
 
+// findContainerByCallerIP returns the only container which has the caller's IP address in one of its networks.
+// Unlike findContainer, it never falls back to a container with a different address, since credentials and secrets
+// must only be given to the container which made the request; requests from the host, a unix socket or an unknown
+// address are rejected with HTTP 403. In a pod, the containers share its network namespace, so a request from a
+// loopback address came from one of them.
+func findContainerByCallerIP(dockerContainers []types.Container, callerIP string) (*types.Container, error) {
+	ip := net.ParseIP(callerIP)
+	if ip == nil {
+		return nil, HTTPError{
+			Code: http.StatusForbidden,
+			Err:  fmt.Errorf("Failed to find the container which the request came from: the request has no caller IP address"),
+		}
+	}
+	sharesNetworkNamespace := kubernetes.IsSidecar() && ip.IsLoopback()
+
+	var matches []types.Container
+	for _, container := range dockerContainers {
+		if sharesNetworkNamespace || containerHasCallerIP(container, callerIP) {
+			matches = append(matches, container)
+		}
+	}
+	if len(matches) == 1 {
+		return &matches[0], nil
+	}
+	if len(matches) == 0 {
+		return nil, HTTPError{
+			Code: http.StatusForbidden,
+			Err:  fmt.Errorf("Failed to find the container which the request came from: no running container has the IP address %s", callerIP),
+		}
+	}
+	return nil, HTTPError{
+		Code: http.StatusForbidden,
+		Err:  fmt.Errorf("Failed to find the container which the request came from: %d running containers have the IP address %s", len(matches), callerIP),
+	}
+}
+
+func containerHasCallerIP(container types.Container, callerIP string) bool {
+	if container.NetworkSettings == nil {
+		return false
+	}
+	for _, settings := range container.NetworkSettings.Networks {
+		if settings != nil && hasCallerIP(settings, callerIP) {
+			return true
+		}
+	}
+	return false
+}
+
 func filterContainersByIdentifier(dockerContainers []types.Container, identifier string) []types.Container {
 	var filteredList []types.Container
 	for _, container := range dockerContainers {
@@ -258,7 +518,7 @@ func filterContainersByRequestIP(dockerContainers []types.Container, callerIP st
 			continue
 		}
 		for _, settings := range container.NetworkSettings.Networks {
-			if settings != nil && settings.IPAddress == callerIP {
+			if settings != nil && hasCallerIP(settings, callerIP) {
 				filteredList = append(filteredList, container)
 			}
 		}
@@ -271,6 +531,21 @@ func filterContainersByRequestIP(dockerContainers []types.Container, callerIP st
 	return dockerContainers
 }
 
+// hasCallerIP returns true if the caller's IPv4 or IPv6 address is the address of the container in the network;
+// the addresses are compared as IPs, so that an IPv4-mapped IPv6 caller matches the IPv4 address
+func hasCallerIP(settings *network.EndpointSettings, callerIP string) bool {
+	ip := net.ParseIP(callerIP)
+	if ip == nil {
+		return false
+	}
+	for _, address := range []string{settings.IPAddress, settings.GlobalIPv6Address} {
+		if address != "" && ip.Equal(net.ParseIP(address)) {
+			return true
+		}
+	}
+	return false
+}
+
 // filter the list by the networks which the endpoints container is in
 func filterContainersByMyNetworks(filteredContainerList []types.Container, allContainers []types.Container, callerIP string) []types.Container {
 	// find endpoints containers
@@ -304,7 +579,7 @@ func filterContainersByMyNetworks(filteredContainerList []types.Container, allCo
 			continue
 		}
 		for network, settings := range container.NetworkSettings.Networks {
-			if settings != nil && networkMatches(network, settings.Aliases, networksToSearch) && settings.IPAddress == callerIP {
+			if settings != nil && networkMatches(network, settings.Aliases, networksToSearch) && hasCallerIP(settings, callerIP) {
 				// This container is in one of the right networks and has the caller IP in that network
 				finalList = append(finalList, container)
 			}
@@ -14,20 +14,43 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/composefile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metrics"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
 	"github.com/gorilla/mux"
 )
 
 // MetadataService vends docker metadata to containers
 type MetadataService struct {
-	dockerClient          docker.Client
-	containerInstanceTags map[string]string
-	taskTags              map[string]string
+	dockerClient   docker.Client
+	statsHistory   *statsHistory
+	taskProtection *taskProtectionStates
+	// taskDefinition shapes the metadata of every local 'task', and is nil if it is not set
+	taskDefinition *ecs.TaskDefinition
+	// firelensConfigurations are the FireLens configurations of the container definitions, keyed by container name
+	firelensConfigurations map[string]*metadata.FirelensConfiguration
+	// composeProject adds the services which are not running to task metadata, and is nil if it is not set
+	composeProject *composefile.Project
+	// secrets gets the secrets of the task definition
+	secrets secretsClient
+	// authorizationToken, rateLimiter and the session tokens protect the secrets like credentials
+	authorizationToken string
+	rateLimiter        *rateLimiter
+	requireToken       bool
+	// unknownCallerAllContainers makes all running containers the local 'task' of callers which are not a container
+	unknownCallerAllContainers bool
+	// tokens are the session tokens of the credentials service, which issues them at the token path
+	tokens *tokenStore
 }
 
 // NewMetadataService returns a struct that handles metadata requests
@@ -41,30 +64,59 @@ func NewMetadataService() (*MetadataService, error) {
 
 // NewMetadataServiceWithClient returns a struct that handles metadata requests using the given Docker Client
 func NewMetadataServiceWithClient(dockerClient docker.Client) (*MetadataService, error) {
+	taskDefinition, firelensConfigurations, err := loadTaskDefinition()
+	if err != nil {
+		return nil, err
+	}
+	composeProject, err := loadComposeProject()
+	if err != nil {
+		return nil, err
+	}
+	authorizationToken, err := getAuthorizationToken()
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter, err := getRateLimiter()
+	if err != nil {
+		return nil, err
+	}
+	requireToken, err := isTokenRequired()
+	if err != nil {
+		return nil, err
+	}
+	unknownCallerAllContainers, err := utils.GetBoolValue(config.UnknownCallerAllContainersVar)
+	if err != nil {
+		return nil, err
+	}
+	// ECS gets the secrets of a task with its execution role
+	var executionRoleARN string
+	if taskDefinition != nil {
+		executionRoleARN = aws.StringValue(taskDefinition.ExecutionRoleArn)
+	}
 	metadata := &MetadataService{
-		dockerClient: dockerClient,
+		dockerClient:               dockerClient,
+		statsHistory:               newStatsHistory(),
+		taskProtection:             newTaskProtectionStates(),
+		taskDefinition:             taskDefinition,
+		firelensConfigurations:     firelensConfigurations,
+		composeProject:             composeProject,
+		secrets:                    newAWSSecretsClient(executionRoleARN),
+		authorizationToken:         authorizationToken,
+		rateLimiter:                rateLimiter,
+		requireToken:               requireToken,
+		unknownCallerAllContainers: unknownCallerAllContainers,
+		tokens:                     newTokenStore(),
 	}
 
-	// TODO: re-enable tagging when supporting the new V2 and V3 metdata with Tags paths
-	// if ciTagVal := os.Getenv(config.ContainerInstanceTagsVar); ciTagVal != "" {
-	// 	tags, err := utils.GetTagsMap(ciTagVal)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// 	metadata.containerInstanceTags = tags
-	// }
-	//
-	// if taskTagVal := os.Getenv(config.TaskTagsVar); taskTagVal != "" {
-	// 	tags, err := utils.GetTagsMap(taskTagVal)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// 	metadata.taskTags = tags
-	// }
-
 	return metadata, nil
 }
 
+// UseSessionTokens accepts the session tokens issued by the credentials service on the secrets path, so that
+// ECS_LOCAL_REQUIRE_TOKEN protects secrets as well as credentials
+func (service *MetadataService) UseSessionTokens(credentials *CredentialService) {
+	service.tokens = credentials.tokens
+}
+
 // SetupV2Routes sets up the V2 Metadata routes
 func (service *MetadataService) SetupV2Routes(router *mux.Router) {
 	router.HandleFunc(config.V2TaskMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeTaskMetadata)))
@@ -101,6 +153,65 @@ func (service *MetadataService) SetupV3Routes(router *mux.Router) {
 	router.HandleFunc(config.V3TaskStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeTaskStats)))
 	router.HandleFunc(config.V3TaskStatsPathWithIdentifier, ServeHTTP(service.getMetadataHandler(requestTypeTaskStats)))
 	router.HandleFunc(config.V3TaskStatsPathWithIdentifierAndSlash, ServeHTTP(service.getMetadataHandler(requestTypeTaskStats)))
+
+	// ECS style paths, where the container ID directly follows the version.
+	// These must be registered after the static paths above, so that /v3/task and /v3/stats take precedence.
+	router.HandleFunc(config.V3ContainerIDMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeContainerMetadata)))
+	router.HandleFunc(config.V3ContainerIDMetadataPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeContainerMetadata)))
+
+	router.HandleFunc(config.V3ContainerIDStatsPath, ServeHTTP(service.getMetadataHandler(requestTypeContainerStats)))
+	router.HandleFunc(config.V3ContainerIDStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeContainerStats)))
+
+	router.HandleFunc(config.V3ContainerIDTaskMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeTaskMetadata)))
+	router.HandleFunc(config.V3ContainerIDTaskMetadataPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeTaskMetadata)))
+
+	router.HandleFunc(config.V3ContainerIDTaskStatsPath, ServeHTTP(service.getMetadataHandler(requestTypeTaskStats)))
+	router.HandleFunc(config.V3ContainerIDTaskStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeTaskStats)))
+}
+
+// SetupV4Routes sets up the V4 Metadata routes
+func (service *MetadataService) SetupV4Routes(router *mux.Router) {
+	router.HandleFunc(config.V4ContainerMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerMetadata)))
+	router.HandleFunc(config.V4ContainerMetadataPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerMetadata)))
+	router.HandleFunc(config.V4ContainerMetadataPathWithIdentifier, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerMetadata)))
+	router.HandleFunc(config.V4ContainerMetadataPathWithIdentifierAndSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerMetadata)))
+
+	router.HandleFunc(config.V4ContainerStatsPath, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerStats)))
+	router.HandleFunc(config.V4ContainerStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerStats)))
+	router.HandleFunc(config.V4ContainerStatsPathWithIdentifier, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerStats)))
+	router.HandleFunc(config.V4ContainerStatsPathWithIdentifierAndSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerStats)))
+
+	router.HandleFunc(config.V4TaskMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadata)))
+	router.HandleFunc(config.V4TaskMetadataPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadata)))
+	router.HandleFunc(config.V4TaskMetadataPathWithIdentifier, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadata)))
+	router.HandleFunc(config.V4TaskMetadataPathWithIdentifierWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadata)))
+
+	router.HandleFunc(config.V4TaskWithTagsPath, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadataWithTags)))
+	router.HandleFunc(config.V4TaskWithTagsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadataWithTags)))
+	router.HandleFunc(config.V4TaskWithTagsPathWithIdentifier, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadataWithTags)))
+	router.HandleFunc(config.V4TaskWithTagsPathWithIdentifierWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadataWithTags)))
+
+	router.HandleFunc(config.V4TaskStatsPath, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskStats)))
+	router.HandleFunc(config.V4TaskStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskStats)))
+	router.HandleFunc(config.V4TaskStatsPathWithIdentifier, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskStats)))
+	router.HandleFunc(config.V4TaskStatsPathWithIdentifierAndSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskStats)))
+
+	// ECS style paths, where the container ID directly follows the version.
+	// These must be registered after the static paths above, so that /v4/task and /v4/stats take precedence.
+	router.HandleFunc(config.V4ContainerIDMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerMetadata)))
+	router.HandleFunc(config.V4ContainerIDMetadataPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerMetadata)))
+
+	router.HandleFunc(config.V4ContainerIDStatsPath, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerStats)))
+	router.HandleFunc(config.V4ContainerIDStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4ContainerStats)))
+
+	router.HandleFunc(config.V4ContainerIDTaskMetadataPath, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadata)))
+	router.HandleFunc(config.V4ContainerIDTaskMetadataPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadata)))
+
+	router.HandleFunc(config.V4ContainerIDTaskWithTagsPath, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadataWithTags)))
+	router.HandleFunc(config.V4ContainerIDTaskWithTagsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskMetadataWithTags)))
+
+	router.HandleFunc(config.V4ContainerIDTaskStatsPath, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskStats)))
+	router.HandleFunc(config.V4ContainerIDTaskStatsPathWithSlash, ServeHTTP(service.getMetadataHandler(requestTypeV4TaskStats)))
 }
 
 // getMetadataHandler returns a metadata handler given a requestType
@@ -111,22 +222,40 @@ func (service *MetadataService) getMetadataHandler(requestType int) func(w http.
 			// Failed to get the callerIP
 			callerIP = ""
 		}
+		if route := mux.CurrentRoute(r); route != nil {
+			if path, err := route.GetPathTemplate(); err == nil {
+				metrics.MetadataRequest(path)
+			}
+		}
 		vars := mux.Vars(r)
 		identifier := vars["identifier"]
-		return service.handleRequest(requestType, w, identifier, callerIP)
+		if isContainerStatsRequest(requestType) && r.URL.Query().Get("stream") == "true" {
+			return service.streamContainerStatsResponse(r.Context(), w, identifier, callerIP, requestType == requestTypeV4ContainerStats)
+		}
+		return service.handleRequest(r.Context(), requestType, w, identifier, callerIP)
 	}
 }
 
-func (service *MetadataService) handleRequest(requestType int, w http.ResponseWriter, identifier string, callerIP string) error {
+func (service *MetadataService) handleRequest(ctx context.Context, requestType int, w http.ResponseWriter, identifier string, callerIP string) error {
 	switch requestType {
 	case requestTypeTaskMetadata:
-		return service.taskMetadataResponse(w, identifier, callerIP)
+		return service.taskMetadataResponse(ctx, w, identifier, callerIP)
 	case requestTypeTaskStats:
-		return service.taskStatsResponse(w, identifier, callerIP)
+		return service.taskStatsResponse(ctx, w, identifier, callerIP)
 	case requestTypeContainerStats:
-		return service.containerStatsResponse(w, identifier, callerIP)
+		return service.containerStatsResponse(ctx, w, identifier, callerIP)
 	case requestTypeContainerMetadata:
-		return service.containerMetadataResponse(w, identifier, callerIP)
+		return service.containerMetadataResponse(ctx, w, identifier, callerIP)
+	case requestTypeV4TaskMetadata:
+		return service.v4TaskMetadataResponse(ctx, w, identifier, callerIP, false)
+	case requestTypeV4TaskMetadataWithTags:
+		return service.v4TaskMetadataResponse(ctx, w, identifier, callerIP, true)
+	case requestTypeV4ContainerMetadata:
+		return service.v4ContainerMetadataResponse(ctx, w, identifier, callerIP)
+	case requestTypeV4TaskStats:
+		return service.v4TaskStatsResponse(ctx, w, identifier, callerIP)
+	case requestTypeV4ContainerStats:
+		return service.v4ContainerStatsResponse(ctx, w, identifier, callerIP)
 	}
 
 	// This should never run, but explicitly returning an error here helps make it easy to find bugs
@@ -0,0 +1,67 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"strings"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// taskTagLabelPrefix is the prefix of the container labels which set the tags of the container's task, such as ecs-local.task-tag.team=containers
+const taskTagLabelPrefix = "ecs-local.task-tag."
+
+// getTags returns the container instance tags and the task tags for the taskWithTags paths, which are nil if there are none.
+// The tags are read from the environment on each request; the task tags can also be set in the labels of the task's containers,
+// which take precedence.
+func getTags(taskContainers []types.Container) (containerInstanceTags map[string]string, taskTags map[string]string, err error) {
+	containerInstanceTags, err = getTagsFromEnv(config.ContainerInstanceTagsVar)
+	if err != nil {
+		return nil, nil, err
+	}
+	taskTags, err = getTagsFromEnv(config.TaskTagsVar)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, container := range taskContainers {
+		for label, value := range container.Labels {
+			if !strings.HasPrefix(label, taskTagLabelPrefix) || len(label) == len(taskTagLabelPrefix) {
+				continue
+			}
+			if taskTags == nil {
+				taskTags = make(map[string]string)
+			}
+			taskTags[strings.TrimPrefix(label, taskTagLabelPrefix)] = value
+		}
+	}
+	return containerInstanceTags, taskTags, nil
+}
+
+// getTagsFromEnv parses the tags in envVar, which are written as key1=value1,key2=value2
+func getTagsFromEnv(envVar string) (map[string]string, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return nil, nil
+	}
+	tags, err := utils.GetTagsMap(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid %s", envVar)
+	}
+	return tags, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTags(t *testing.T) {
+	os.Setenv(config.ContainerInstanceTagsVar, "mitchell=webb,thats=numberwang")
+	os.Setenv(config.TaskTagsVar, "hello=goodbye,get=back,come=together")
+	defer os.Clearenv()
+
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithLabel(taskTagLabelPrefix+"get", "up").Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithLabel(taskTagLabelPrefix+"team", "containers").WithLabel(taskTagLabelPrefix, "empty").Get()
+
+	expectedCITags := map[string]string{
+		"mitchell": "webb",
+		"thats":    "numberwang",
+	}
+	expectedTaskTags := map[string]string{
+		"hello": "goodbye",
+		"get":   "up",
+		"come":  "together",
+		"team":  "containers",
+	}
+
+	containerInstanceTags, taskTags, err := getTags([]types.Container{container1, container2})
+	assert.NoError(t, err, "Unexpected error getting tags")
+	assert.Equal(t, expectedCITags, containerInstanceTags, "Expected container instance tags to match")
+	assert.Equal(t, expectedTaskTags, taskTags, "Expected the labels to take precedence over the task tags")
+}
+
+func TestGetTagsNotSet(t *testing.T) {
+	containerInstanceTags, taskTags, err := getTags(nil)
+	assert.NoError(t, err, "Unexpected error getting tags")
+	assert.Nil(t, containerInstanceTags, "Expected no container instance tags")
+	assert.Nil(t, taskTags, "Expected no task tags")
+}
+
+func TestGetTagsInvalid(t *testing.T) {
+	os.Setenv(config.TaskTagsVar, "hello")
+	defer os.Unsetenv(config.TaskTagsVar)
+
+	_, _, err := getTags(nil)
+	assert.Error(t, err, "Expected error for invalid task tags")
+}
@@ -14,8 +14,11 @@
 package handlers
 
 import (
+	"net/http"
+	"os"
 	"testing"
 
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
 	"github.com/docker/docker/api/types"
 	"github.com/stretchr/testify/assert"
@@ -165,6 +168,22 @@ func TestFindContainerWithCallerIP(t *testing.T) {
 
 }
 
+func TestFindContainerWithCallerIPv6(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork("ipv6", ipAddress1).WithIPv6Address("ipv6", "fd00:dead:beef::2").Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork("ipv6", ipAddress2).WithIPv6Address("ipv6", "fd00:dead:beef::3").Get()
+	container3 := testingutils.BaseDockerContainer(containerName3, longID3).WithNetwork("bridge", ipAddress3).Get()
+
+	containers := []types.Container{
+		container1,
+		container2,
+		container3,
+	}
+
+	actual, err := findContainer(containers, "", "fd00:dead:beef::3")
+	assert.NoError(t, err, "Unexpected error from findContainer")
+	assert.Equal(t, &container2, actual, "Expected findContainer to find the container with the IPv6 address")
+}
+
 func TestFindContainerWithCallerIPAndNetworks(t *testing.T) {
 	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).Get()
 	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).Get()
@@ -184,6 +203,30 @@ func TestFindContainerWithCallerIPAndNetworks(t *testing.T) {
 
 }
 
+func TestFindContainerWithCallerIPv6AndNetworks(t *testing.T) {
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).WithIPv6Address(network1, "fd00:dead:beef::1").Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithIPv6Address(network2, "fd00:dead:beef::2").Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithIPv6Address(network1, "fd00:dead:beef:0::3").Get()
+	container3 := testingutils.BaseDockerContainer(containerName3, longID3).WithNetwork(network1, ipAddress3).WithIPv6Address(network1, "fd00:dead:beef::2").Get()
+
+	containers := []types.Container{
+		container1,
+		container2,
+		container3,
+		endpointsContainer,
+	}
+
+	// container 1 has the address in a network which Local Endpoints is not in
+	actual, err := findContainer(containers, "", "fd00:dead:beef::2")
+	assert.NoError(t, err, "Unexpected error from findContainer")
+	assert.Equal(t, &container3, actual, "Expected findContainer to find the container with the IPv6 address in a valid network")
+
+	// the addresses are compared as IPs, not as strings
+	actual, err = findContainer(containers, "", "fd00:dead:beef:0:0:0:0:3")
+	assert.NoError(t, err, "Unexpected error from findContainer")
+	assert.Equal(t, &container2, actual, "Expected findContainer to find the container with the same IPv6 address")
+}
+
 func TestFindContainerWithCallerIPAndNetworksFailure(t *testing.T) {
 	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork("bridge", ipAddress).Get()
 	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).Get()
@@ -289,7 +332,8 @@ func TestGetTaskContainers(t *testing.T) {
 		endpointsContainer,
 	}
 
-	result := getTaskContainers(containers, "", ipAddress1)
+	result, err := getTaskContainers(containers, "", ipAddress1, false)
+	assert.NoError(t, err, "Unexpected error")
 
 	assert.ElementsMatch(t, expected, result, "Expected containers returned by getTaskContainers to be from the correct compose project")
 
@@ -319,7 +363,7 @@ func TestGetTaskContainersNilTest(t *testing.T) {
 		endpointsContainer,
 	}
 
-	getTaskContainers(containers, containerName3, ipAddress1)
+	getTaskContainers(containers, containerName3, ipAddress1, false)
 }
 
 func TestGetTaskContainersOneContainerReturned(t *testing.T) {
@@ -340,30 +384,95 @@ func TestGetTaskContainersOneContainerReturned(t *testing.T) {
 		container3,
 	}
 
-	result := getTaskContainers(containers, containerName3, ipAddress1)
+	result, err := getTaskContainers(containers, containerName3, ipAddress1, false)
+	assert.NoError(t, err, "Unexpected error")
 
 	assert.ElementsMatch(t, expected, result, "Expected containers returned by getTaskContainers to be from the correct compose project")
 
 }
 
-// TODO: re-enable test once metadata with Tags field is added
-// func TestNewMetadataServiceWithTags(t *testing.T) {
-// 	os.Setenv(config.ContainerInstanceTagsVar, "mitchell=webb,thats=numberwang")
-// 	os.Setenv(config.TaskTagsVar, "hello=goodbye,get=back,come=together")
-// 	defer os.Clearenv()
-//
-// 	expectedCITags := map[string]string{
-// 		"mitchell": "webb",
-// 		"thats":    "numberwang",
-// 	}
-// 	expectedTaskTags := map[string]string{
-// 		"hello": "goodbye",
-// 		"get":   "back",
-// 		"come":  "together",
-// 	}
-//
-// 	service, err := NewMetadataService()
-// 	assert.NoError(t, err, "Unexpected error calling NewMetadataService")
-// 	assert.Equal(t, expectedCITags, service.containerInstanceTags, "Expected container instance tags to match")
-// 	assert.Equal(t, expectedTaskTags, service.taskTags, "Expected task tags to match")
-// }
+func TestGetTaskContainersCallerNotInComposeProject(t *testing.T) {
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).Get()
+	container3 := testingutils.BaseDockerContainer(containerName3, longID3).WithNetwork(network1, ipAddress1).Get()
+
+	containers := []types.Container{
+		container3,
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	expected := []types.Container{
+		container3,
+	}
+
+	result, err := getTaskContainers(containers, containerName3, ipAddress1, false)
+	assert.NoError(t, err, "Unexpected error")
+
+	assert.ElementsMatch(t, expected, result, "Expected only the caller container to be returned by getTaskContainers")
+}
+
+func TestGetTaskContainersCallerNotFound(t *testing.T) {
+	endpointsContainer := testingutils.BaseDockerContainer("endpoints", endpointsLongID).WithNetwork(network1, ipAddress).Get()
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network2, ipAddress1).WithComposeProject(projectName2).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).Get()
+
+	containers := []types.Container{
+		container1,
+		container2,
+		endpointsContainer,
+	}
+
+	_, err := getTaskContainers(containers, "", "127.0.0.1", false)
+	assert.Error(t, err, "Expected error when the caller is not found")
+	httpErr, ok := err.(HTTPError)
+	assert.True(t, ok, "Expected an HTTPError")
+	assert.Equal(t, http.StatusNotFound, httpErr.Code, "Expected HTTP 404 when the caller is not found")
+
+	result, err := getTaskContainers(containers, "", "127.0.0.1", true)
+	assert.NoError(t, err, "Unexpected error")
+	assert.ElementsMatch(t, containers, result, "Expected all containers to be returned by getTaskContainers when the caller is not found, if enabled")
+}
+
+func TestFindContainerByCallerIP(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network1, ipAddress1).WithIPv6Address(network1, "fd00:dead:beef::2").Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network2, ipAddress2).Get()
+
+	actual, err := findContainerByCallerIP([]types.Container{container1, container2}, ipAddress2)
+	assert.NoError(t, err, "Unexpected error from findContainerByCallerIP")
+	assert.Equal(t, &container2, actual, "Expected the container with the caller IP")
+
+	actual, err = findContainerByCallerIP([]types.Container{container1, container2}, "fd00:dead:beef:0:0:0:0:2")
+	assert.NoError(t, err, "Unexpected error from findContainerByCallerIP")
+	assert.Equal(t, &container1, actual, "Expected the container with the caller IPv6 address")
+
+	// unlike findContainer, the only running container is not used for a caller with another address
+	for _, callerIP := range []string{"172.17.0.1", "127.0.0.1", ""} {
+		_, err = findContainerByCallerIP([]types.Container{container1}, callerIP)
+		assert.Error(t, err, "Expected error for caller %s", callerIP)
+		httpErr, ok := err.(HTTPError)
+		assert.True(t, ok, "Expected an HTTP error for caller %s", callerIP)
+		assert.Equal(t, http.StatusForbidden, httpErr.Code, "Expected HTTP 403 for caller %s", callerIP)
+	}
+
+	container3 := testingutils.BaseDockerContainer(containerName3, longID3).WithNetwork(network2, ipAddress2).Get()
+	_, err = findContainerByCallerIP([]types.Container{container1, container2, container3}, ipAddress2)
+	assert.Error(t, err, "Expected error when more than one container has the caller IP")
+}
+
+func TestFindContainerByCallerIPInPod(t *testing.T) {
+	os.Setenv(config.PodNameVar, "my-app-7d4b9c")
+	defer os.Unsetenv(config.PodNameVar)
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork("pod", ipAddress1).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork("pod", ipAddress1).Get()
+
+	// the containers of a pod reach Local Endpoints at localhost
+	actual, err := findContainerByCallerIP([]types.Container{container1}, "127.0.0.1")
+	assert.NoError(t, err, "Unexpected error from findContainerByCallerIP")
+	assert.Equal(t, &container1, actual, "Expected the only container of the pod")
+
+	_, err = findContainerByCallerIP([]types.Container{container1, container2}, "::1")
+	assert.Error(t, err, "Expected error when the pod has more than one container")
+}
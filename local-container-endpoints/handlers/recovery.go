@@ -0,0 +1,62 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// RecoverPanics is middleware which recovers from a panic in a handler, so that a bug hit by one request
+// doesn't end the process; the stack trace is logged, and the client gets an HTTP 500 JSON error with only the request ID.
+// If the response has already started, the connection is aborted instead, so that the client can tell it is incomplete.
+func RecoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			requestLog(r).WithFields(logrus.Fields{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}).Errorf("Recovered from a panic while handling the request: %v\n%s", recovered, debug.Stack())
+			if recorder.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			// the panic value can contain internal state, so it is only logged
+			err := fmt.Errorf("Internal server error")
+			if requestID := tracing.RequestID(r.Context()); requestID != "" {
+				err = fmt.Errorf("Internal server error; request ID %s", requestID)
+			}
+			writeJSONErrorResponse(w, getErrorMessage(HTTPError{
+				Code: http.StatusInternalServerError,
+				Err:  err,
+			}))
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
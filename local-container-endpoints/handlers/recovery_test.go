@@ -0,0 +1,69 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	handler := AssignRequestIDs(RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var services map[string]*CredentialService
+		services["default"].requireToken = true
+	})))
+
+	request := httptest.NewRequest(http.MethodGet, config.ContainerRoleCredentialsPath, nil)
+	request.Header.Set(requestIDHeader, "my-request")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "Expected http status code to be 500")
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"), "Expected a JSON response")
+
+	errorMessage := &handlersutils.ErrorMessage{}
+	err := json.Unmarshal(recorder.Body.Bytes(), errorMessage)
+	assert.NoError(t, err, "Unexpected error unmarshalling the response")
+	assert.Equal(t, "InternalServerError", errorMessage.Code, "Expected the error code to match")
+	assert.Equal(t, "Internal server error; request ID my-request", errorMessage.Message, "Expected only the request ID in the message")
+	assert.NotContains(t, recorder.Body.String(), "nil pointer dereference", "Expected the panic not to be in the response")
+}
+
+func TestRecoverPanicsAfterResponseStarted(t *testing.T) {
+	handler := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{"))
+		panic("stream failed")
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, config.V3TaskStatsPath, nil))
+	}, "Expected the connection to be aborted")
+}
+
+func TestRecoverPanicsWithoutPanic(t *testing.T) {
+	handler := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok, "Expected the response writer to support flushing")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.ContainerRoleCredentialsPath, nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code, "Expected the handler's status code")
+}
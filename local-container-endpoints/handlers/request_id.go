@@ -0,0 +1,75 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// request IDs from callers are only used if they are short and can't break the format of the log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// AssignRequestIDs is middleware which gives each request an ID, which is returned in the x-request-id header,
+// and is in the request's log entries and spans, and in the context of the AWS requests made for it.
+// If the caller sent an x-request-id header, its ID is used, so that the caller's own logs can be correlated.
+func AssignRequestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = tracing.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(tracing.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// requestLog returns an entry of the standard log with the ID of the request
+func requestLog(r *http.Request) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if requestID := tracing.RequestID(r.Context()); requestID != "" {
+		entry = entry.WithField("request_id", requestID)
+	}
+	return entry
+}
+
+// awsContext returns the context for the AWS requests made for a request. It has the request's values, such as its ID
+// and span, but it is not cancelled with the request, since the credentials obtained are shared through the cache.
+func awsContext(ctx context.Context) aws.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignRequestIDs(t *testing.T) {
+	var requestID string
+	handler := AssignRequestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = tracing.RequestID(r.Context())
+	}))
+
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"my-client.request:42", "my-client.request:42"},
+		{"has spaces\n", ""},
+	}
+	for _, testCase := range testCases {
+		request := httptest.NewRequest(http.MethodGet, config.ContainerRoleCredentialsPath, nil)
+		if testCase.header != "" {
+			request.Header.Set("x-request-id", testCase.header)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		assert.NotEmpty(t, requestID, "Expected a request ID for header %q", testCase.header)
+		assert.Equal(t, requestID, recorder.Header().Get("x-request-id"), "Expected the request ID in the response for header %q", testCase.header)
+		if testCase.expected != "" {
+			assert.Equal(t, testCase.expected, requestID, "Expected the caller's request ID")
+		} else {
+			assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", requestID, "Expected a generated request ID for header %q", testCase.header)
+		}
+	}
+}
+
+func TestAWSContext(t *testing.T) {
+	parent, cancel := context.WithTimeout(tracing.WithRequestID(context.Background(), "abc"), time.Minute)
+	ctx := awsContext(parent)
+	cancel()
+
+	assert.Error(t, parent.Err(), "Expected the request's context to be cancelled")
+	assert.NoError(t, ctx.Err(), "Expected the AWS context not to be cancelled with the request")
+	_, hasDeadline := ctx.Deadline()
+	assert.False(t, hasDeadline, "Expected the AWS context not to have the request's deadline")
+	assert.Equal(t, "abc", tracing.RequestID(ctx), "Expected the AWS context to have the request ID")
+}
+
+func TestGetRoleCredentialsWithRequestID(t *testing.T) {
+	iamMock, stsMock := setupMocks(t)
+
+	credsService := newCredentialServiceInTest(iamMock, stsMock)
+
+	expiration, _ := time.Parse(CredentialExpirationTimeFormat, expirationTimeString)
+
+	gomock.InOrder(
+		iamMock.EXPECT().GetRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			assert.Equal(t, "abc", tracing.RequestID(ctx.(context.Context)), "Expected the request ID in the context of iam:GetRole")
+		}).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn: aws.String(roleARN),
+			},
+		}, nil),
+		stsMock.EXPECT().AssumeRoleWithContext(gomock.Any(), gomock.Any()).Do(func(ctx, x interface{}) {
+			assert.Equal(t, "abc", tracing.RequestID(ctx.(context.Context)), "Expected the request ID in the context of sts:AssumeRole")
+		}).Return(&sts.AssumeRoleOutput{
+			Credentials: &sts.Credentials{
+				AccessKeyId:     aws.String(accessKey),
+				SecretAccessKey: aws.String(secretKey),
+				SessionToken:    aws.String(sessionToken),
+				Expiration:      &expiration,
+			},
+		}, nil),
+	)
+
+	_, err := credsService.getRoleCredentials(roleName, &assumeRoleOptions{
+		ctx: tracing.WithRequestID(context.Background(), "abc"),
+	})
+	assert.NoError(t, err, "Unexpected error calling getRoleCredentials")
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/useragent"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// secretsClient gets the values of the secrets which container definitions reference
+type secretsClient interface {
+	// getParameter returns the decrypted value of an SSM parameter, given by its name or ARN
+	getParameter(ctx context.Context, name string) (string, error)
+	// getSecretValue returns the string of a Secrets Manager secret, at the version stage or ID if either is set
+	getSecretValue(ctx context.Context, secretID, versionStage, versionID string) (string, error)
+}
+
+// secretsManagerReference is a secret in the valueFrom of an ECS secret, which is the ARN of a Secrets Manager
+// secret with an optional JSON key, version stage, and version ID: arn:...:secret:name-AbCdEf:json-key:version-stage:version-id
+type secretsManagerReference struct {
+	secretARN    string
+	jsonKey      string
+	versionStage string
+	versionID    string
+}
+
+// parseSecretsManagerReference returns the Secrets Manager secret which the valueFrom refers to,
+// or false if it refers to an SSM parameter
+func parseSecretsManagerReference(valueFrom string) (*secretsManagerReference, bool) {
+	resourceARN, err := arn.Parse(valueFrom)
+	if err != nil || resourceARN.Service != secretsmanager.ServiceName {
+		return nil, false
+	}
+	// the resource is secret:name-AbCdEf, followed by the optional fields
+	fields := strings.Split(valueFrom, ":")
+	if len(fields) < 7 {
+		return &secretsManagerReference{
+			secretARN: valueFrom,
+		}, true
+	}
+	reference := &secretsManagerReference{
+		secretARN: strings.Join(fields[:7], ":"),
+	}
+	optional := append(fields[7:], "", "", "")
+	reference.jsonKey, reference.versionStage, reference.versionID = optional[0], optional[1], optional[2]
+	return reference, true
+}
+
+// getSecret returns the value of an ECS secret, from either SSM Parameter Store or Secrets Manager
+func getSecret(ctx context.Context, client secretsClient, valueFrom string) (string, error) {
+	reference, ok := parseSecretsManagerReference(valueFrom)
+	if !ok {
+		return client.getParameter(ctx, valueFrom)
+	}
+	value, err := client.getSecretValue(ctx, reference.secretARN, reference.versionStage, reference.versionID)
+	if err != nil || reference.jsonKey == "" {
+		return value, err
+	}
+
+	var keys map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &keys); err != nil {
+		return "", errors.Wrapf(err, "Failed to read key %s, since the secret is not a JSON object", reference.jsonKey)
+	}
+	keyValue, ok := keys[reference.jsonKey]
+	if !ok {
+		return "", HTTPError{
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("The secret has no key %s", reference.jsonKey),
+		}
+	}
+	if stringValue, ok := keyValue.(string); ok {
+		return stringValue, nil
+	}
+	// other values are returned as JSON, such as 42 or {"nested":true}
+	data, err := json.Marshal(keyValue)
+	return string(data), err
+}
+
+// awsSecretsClient gets secrets with the task execution role, like ECS, or else with the credentials which roles are
+// assumed with; the session is created on the first request, so that Local Endpoints can start without credentials
+// if secrets are not used
+type awsSecretsClient struct {
+	lock sync.Mutex
+	sess *session.Session
+	// executionRoleARN is the execution role of the task definition, if it has one
+	executionRoleARN string
+	// executionRoleCredentials are assumed from the session's credentials, and are nil without an execution role
+	executionRoleCredentials *credentials.Credentials
+	ssmClients               map[string]*ssm.SSM
+	secretsManagerClients    map[string]*secretsmanager.SecretsManager
+}
+
+func newAWSSecretsClient(executionRoleARN string) *awsSecretsClient {
+	return &awsSecretsClient{
+		executionRoleARN:      executionRoleARN,
+		ssmClients:            make(map[string]*ssm.SSM),
+		secretsManagerClients: make(map[string]*secretsmanager.SecretsManager),
+	}
+}
+
+func (client *awsSecretsClient) getParameter(ctx context.Context, name string) (string, error) {
+	ssmClient, err := client.getSSMClient(getARNRegion(name))
+	if err != nil {
+		return "", err
+	}
+	output, err := ssmClient.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+func (client *awsSecretsClient) getSecretValue(ctx context.Context, secretID, versionStage, versionID string) (string, error) {
+	secretsManagerClient, err := client.getSecretsManagerClient(getARNRegion(secretID))
+	if err != nil {
+		return "", err
+	}
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	}
+	if versionStage != "" {
+		input.VersionStage = aws.String(versionStage)
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	output, err := secretsManagerClient.GetSecretValueWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString == nil {
+		return "", fmt.Errorf("The secret is binary, and only secret strings can be environment variables")
+	}
+	return aws.StringValue(output.SecretString), nil
+}
+
+// getSSMClient returns the SSM client for the region, or for the region of the credentials if it is empty
+func (client *awsSecretsClient) getSSMClient(region string) (*ssm.SSM, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if ssmClient, ok := client.ssmClients[region]; ok {
+		return ssmClient, nil
+	}
+	clientConfig, err := client.getClientConfig(region, ssmEndpointURLVar)
+	if err != nil {
+		return nil, err
+	}
+	ssmClient := ssm.New(client.sess, clientConfig)
+	ssmClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	client.ssmClients[region] = ssmClient
+	return ssmClient, nil
+}
+
+// getSecretsManagerClient returns the Secrets Manager client for the region, or for the region of the credentials if it is empty
+func (client *awsSecretsClient) getSecretsManagerClient(region string) (*secretsmanager.SecretsManager, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if secretsManagerClient, ok := client.secretsManagerClients[region]; ok {
+		return secretsManagerClient, nil
+	}
+	clientConfig, err := client.getClientConfig(region, secretsManagerEndpointURLVar)
+	if err != nil {
+		return nil, err
+	}
+	secretsManagerClient := secretsmanager.New(client.sess, clientConfig)
+	secretsManagerClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	client.secretsManagerClients[region] = secretsManagerClient
+	return secretsManagerClient, nil
+}
+
+// getClientConfig creates the session if it does not exist yet, and returns the config of a client in the region;
+// the lock must be held
+func (client *awsSecretsClient) getClientConfig(region, endpointURLVar string) (*aws.Config, error) {
+	if client.sess == nil {
+		_, stsClient, sess, err := newProfileClients("")
+		if err != nil {
+			return nil, err
+		}
+		client.sess = sess
+		if client.executionRoleARN != "" {
+			client.executionRoleCredentials = stscreds.NewCredentialsWithClient(stsClient, client.executionRoleARN, func(provider *stscreds.AssumeRoleProvider) {
+				provider.RoleSessionName = fmt.Sprintf("ecs-local-endpoints-%d", time.Now().UnixNano())
+			})
+		}
+	}
+	clientConfig := &aws.Config{
+		Credentials: client.executionRoleCredentials,
+	}
+	if region != "" {
+		clientConfig.Region = aws.String(region)
+	}
+	if endpoint := getEndpointURL(endpointURLVar); endpoint != "" {
+		clientConfig.Endpoint = aws.String(endpoint)
+	}
+	return clientConfig, nil
+}
+
+// getARNRegion returns the region of an ARN, or an empty string if the value is not an ARN
+func getARNRegion(value string) string {
+	resourceARN, err := arn.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return resourceARN.Region
+}
+
+// SetupSecretsRoutes sets up the route which returns the secrets of the caller's container definition;
+// like credentials, it requires the authorization token and a session token, and is rate limited, if they are set
+func (service *MetadataService) SetupSecretsRoutes(router *mux.Router) {
+	handler := ServeHTTP(service.rateLimiter.limit("secrets", requireAuthorization(service.authorizationToken, service.withToken(service.secretsHandler))))
+	router.HandleFunc(config.SecretsPath, handler)
+	router.HandleFunc(config.SecretsPathWithSlash, handler)
+}
+
+// withToken wraps a handler so that requests without a valid session token are rejected, if tokens are required;
+// the tokens are read for each request, since the credentials service shares them after the routes are set up
+func (service *MetadataService) withToken(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return requireSessionToken(service.requireToken, service.tokens, handler)(w, r)
+	}
+}
+
+// secretsHandler returns the values of the secrets in the container definition of the caller, which ECS
+// injects as environment variables; they are JSON by default, or shell commands which export them with ?format=env.
+// Secrets are read from SSM Parameter Store, or from Secrets Manager if valueFrom is the ARN of a secret.
+func (service *MetadataService) secretsHandler(w http.ResponseWriter, r *http.Request) error {
+	logrus.Debug("Received secrets request")
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "env" {
+		return HTTPError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("Invalid format %s; expected json or env", format),
+		}
+	}
+	if service.taskDefinition == nil {
+		return HTTPError{
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("No task definition is set with %s, so there are no secrets", config.TaskDefinitionVar),
+		}
+	}
+
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Failed to list running containers")
+	}
+	container, err := findContainerByCallerIP(containers, getCallerIP(r))
+	if err != nil {
+		return err
+	}
+	containerDefinition := metadata.FindContainerDefinition(service.taskDefinition, container)
+	if containerDefinition == nil {
+		return HTTPError{
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("No container definition in the task definition matches the Compose service or the name of the container"),
+		}
+	}
+
+	secrets := make(map[string]string)
+	for _, secret := range containerDefinition.Secrets {
+		if secret == nil {
+			continue
+		}
+		name, valueFrom := aws.StringValue(secret.Name), aws.StringValue(secret.ValueFrom)
+		value, err := getSecret(ctx, service.secrets, valueFrom)
+		// HTTP errors keep their status code
+		if httpErr, ok := err.(HTTPError); ok {
+			httpErr.Err = errors.Wrapf(httpErr.Err, "Failed to get secret %s from %s", name, valueFrom)
+			return httpErr
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Failed to get secret %s from %s", name, valueFrom)
+		}
+		secrets[name] = value
+	}
+	logrus.Debugf("Returning %d secrets of container definition %s", len(secrets), aws.StringValue(containerDefinition.Name))
+
+	if format == "env" {
+		writeEnvResponse(w, secrets)
+		return nil
+	}
+	writeJSONResponse(w, secrets)
+	return nil
+}
+
+// writeEnvResponse writes the variables as shell commands which export them, such as export NAME='value'
+func writeEnvResponse(w http.ResponseWriter, variables map[string]string) {
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	for _, name := range names {
+		value := strings.Replace(variables[name], "'", `'"'"'`, -1)
+		fmt.Fprintf(w, "export %s='%s'\n", name, value)
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecretARN = "arn:aws:secretsmanager:us-west-2:111111111111:secret:my-app/db-AbCdEf"
+
+// fakeSecretsClient returns the values of SSM parameters and Secrets Manager secrets from memory;
+// secrets are keyed by their ARN, and by their ARN and version stage, such as arn@AWSPREVIOUS
+type fakeSecretsClient struct {
+	parameters map[string]string
+	secrets    map[string]string
+}
+
+func (client *fakeSecretsClient) getParameter(ctx context.Context, name string) (string, error) {
+	value, ok := client.parameters[name]
+	if !ok {
+		return "", awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("Parameter %s not found", name), nil)
+	}
+	return value, nil
+}
+
+func (client *fakeSecretsClient) getSecretValue(ctx context.Context, secretID, versionStage, versionID string) (string, error) {
+	key := secretID
+	if versionStage != "" {
+		key += "@" + versionStage
+	}
+	value, ok := client.secrets[key]
+	if !ok {
+		return "", awserr.New(secretsmanager.ErrCodeResourceNotFoundException, fmt.Sprintf("Secret %s not found", key), nil)
+	}
+	return value, nil
+}
+
+func newSecretsTestService(t *testing.T, ctrl *gomock.Controller, secrets []*ecs.Secret) *mux.Router {
+	router := mux.NewRouter()
+	newSecretsTestMetadataService(t, ctrl, secrets).SetupSecretsRoutes(router)
+	return router
+}
+
+func newSecretsTestMetadataService(t *testing.T, ctrl *gomock.Controller, secrets []*ecs.Secret) *MetadataService {
+	// httptest requests come from 192.0.2.1, which is the IP of the container
+	container := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network1, "192.0.2.1").WithComposeProject(projectName).Get()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{container}, nil).AnyTimes()
+
+	service, err := NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating metadata service")
+	service.taskDefinition = &ecs.TaskDefinition{
+		Family: aws.String("my-app"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				// named after the Compose service of the test container
+				Name:    aws.String("ecs-local"),
+				Secrets: secrets,
+			},
+		},
+	}
+	service.secrets = &fakeSecretsClient{
+		parameters: map[string]string{
+			"/my-app/db-password": "it's a secret",
+			"arn:aws:ssm:eu-west-1:111111111111:parameter/my-app/api-key": "key",
+		},
+		secrets: map[string]string{
+			testSecretARN:                  `{"username":"admin","password":"hunter2","port":5432}`,
+			testSecretARN + "@AWSPREVIOUS": `{"username":"admin","password":"old"}`,
+		},
+	}
+	return service
+}
+
+func TestSecretsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	router := newSecretsTestService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("DB_PASSWORD"),
+			ValueFrom: aws.String("/my-app/db-password"),
+		},
+		{
+			Name:      aws.String("API_KEY"),
+			ValueFrom: aws.String("arn:aws:ssm:eu-west-1:111111111111:parameter/my-app/api-key"),
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	var secrets map[string]string
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &secrets), "Unexpected error unmarshalling response")
+	assert.Equal(t, map[string]string{
+		"DB_PASSWORD": "it's a secret",
+		"API_KEY":     "key",
+	}, secrets, "Expected the secrets of the container definition")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath+"?format=env", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	assert.Equal(t, "export API_KEY='key'\nexport DB_PASSWORD='it'\"'\"'s a secret'\n", recorder.Body.String(), "Expected shell commands which export the secrets")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath+"?format=xml", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Expected http status code to be 400 for an invalid format")
+}
+
+func TestSecretsHandlerParameterNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	router := newSecretsTestService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("MISSING"),
+			ValueFrom: aws.String("/my-app/missing"),
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Expected http status code to be 404")
+	assert.Contains(t, recorder.Body.String(), "MISSING", "Expected the name of the secret in the error")
+}
+
+func TestSecretsHandlerWithoutTaskDefinition(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+
+	service, err := NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating metadata service")
+	router := mux.NewRouter()
+	service.SetupSecretsRoutes(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Expected http status code to be 404")
+}
+
+func TestSecretsHandlerSecretsManager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	router := newSecretsTestService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("DB_SECRET"),
+			ValueFrom: aws.String(testSecretARN),
+		},
+		{
+			Name:      aws.String("DB_PASSWORD"),
+			ValueFrom: aws.String(testSecretARN + ":password::"),
+		},
+		{
+			Name:      aws.String("DB_PORT"),
+			ValueFrom: aws.String(testSecretARN + ":port::"),
+		},
+		{
+			Name:      aws.String("OLD_PASSWORD"),
+			ValueFrom: aws.String(testSecretARN + ":password:AWSPREVIOUS:"),
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	var secrets map[string]string
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &secrets), "Unexpected error unmarshalling response")
+	assert.Equal(t, map[string]string{
+		"DB_SECRET":    `{"username":"admin","password":"hunter2","port":5432}`,
+		"DB_PASSWORD":  "hunter2",
+		"DB_PORT":      "5432",
+		"OLD_PASSWORD": "old",
+	}, secrets, "Expected the secrets and their keys")
+}
+
+func TestSecretsHandlerMissingKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	router := newSecretsTestService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("DB_HOST"),
+			ValueFrom: aws.String(testSecretARN + ":host::"),
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Expected http status code to be 404")
+	assert.Contains(t, recorder.Body.String(), "DB_HOST", "Expected the name of the secret in the error")
+}
+
+func TestParseSecretsManagerReference(t *testing.T) {
+	reference, ok := parseSecretsManagerReference(testSecretARN + ":password:AWSCURRENT:01234567-89ab")
+	assert.True(t, ok, "Expected a Secrets Manager secret")
+	assert.Equal(t, &secretsManagerReference{
+		secretARN:    testSecretARN,
+		jsonKey:      "password",
+		versionStage: "AWSCURRENT",
+		versionID:    "01234567-89ab",
+	}, reference, "Expected the fields of the reference")
+
+	reference, ok = parseSecretsManagerReference(testSecretARN)
+	assert.True(t, ok, "Expected a Secrets Manager secret")
+	assert.Equal(t, &secretsManagerReference{secretARN: testSecretARN}, reference, "Expected only the secret ARN")
+
+	_, ok = parseSecretsManagerReference("arn:aws:ssm:us-west-2:111111111111:parameter/my-app/api-key")
+	assert.False(t, ok, "Expected an SSM parameter ARN not to be a Secrets Manager secret")
+	_, ok = parseSecretsManagerReference("/my-app/db-password")
+	assert.False(t, ok, "Expected an SSM parameter name not to be a Secrets Manager secret")
+}
+
+func TestGetARNRegion(t *testing.T) {
+	assert.Equal(t, "eu-west-1", getARNRegion("arn:aws:ssm:eu-west-1:111111111111:parameter/my-app/api-key"), "Expected the region of the ARN")
+	assert.Equal(t, "", getARNRegion("/my-app/db-password"), "Expected no region for a parameter name")
+}
+
+func TestSecretsHandlerAuthorizationAndRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	os.Setenv(config.AuthorizationTokenVar, "my-token")
+	defer os.Unsetenv(config.AuthorizationTokenVar)
+	os.Setenv(config.RateLimitVar, "0.01")
+	defer os.Unsetenv(config.RateLimitVar)
+	os.Setenv(config.RateLimitBurstVar, "2")
+	defer os.Unsetenv(config.RateLimitBurstVar)
+	router := newSecretsTestService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("DB_PASSWORD"),
+			ValueFrom: aws.String("/my-app/db-password"),
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "Expected http status code to be 401 without the token")
+	assert.NotContains(t, recorder.Body.String(), "it's a secret", "Expected no secrets without the token")
+
+	request := httptest.NewRequest(http.MethodGet, config.SecretsPath, nil)
+	request.Header.Set(authorizationHeader, "my-token")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200 with the token")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "Expected http status code to be 429 once the burst is used up")
+}
+
+func TestSecretsHandlerUnknownCaller(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	router := newSecretsTestService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("DB_PASSWORD"),
+			ValueFrom: aws.String("/my-app/db-password"),
+		},
+	})
+
+	// the only running container's secrets must not be returned to another address, such as the host
+	request := httptest.NewRequest(http.MethodGet, config.SecretsPath, nil)
+	request.RemoteAddr = "192.0.2.99:1234"
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "Expected http status code to be 403 for an unknown caller")
+	assert.NotContains(t, recorder.Body.String(), "it's a secret", "Expected no secrets for an unknown caller")
+}
+
+func TestSecretsHandlerSessionToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	os.Setenv(config.RequireTokenVar, "true")
+	defer os.Unsetenv(config.RequireTokenVar)
+	service := newSecretsTestMetadataService(t, ctrl, []*ecs.Secret{
+		{
+			Name:      aws.String("DB_PASSWORD"),
+			ValueFrom: aws.String("/my-app/db-password"),
+		},
+	})
+	router := mux.NewRouter()
+	service.SetupSecretsRoutes(router)
+	// the credentials service issues the tokens, and shares them once it is created
+	credentials := &CredentialService{tokens: newTokenStore()}
+	service.UseSessionTokens(credentials)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, config.SecretsPath, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "Expected http status code to be 401 without a session token")
+	assert.NotContains(t, recorder.Body.String(), "it's a secret", "Expected no secrets without a session token")
+
+	token, err := credentials.tokens.issue(time.Minute)
+	assert.NoError(t, err, "Unexpected error issuing a token")
+	request := httptest.NewRequest(http.MethodGet, config.SecretsPath, nil)
+	request.Header.Set(tokenHeader, token)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200 with a session token")
+}
+
+// newSecretsAWSServer serves sts:AssumeRole, which returns credentials with the access key of the execution role,
+// and ssm:GetParameter, which is denied to the execution role
+func newSecretsAWSServer(executionRoleAccessKey string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "" {
+			r.ParseForm()
+			if r.Form.Get("Action") != "AssumeRole" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>%s</AccessKeyId>`+
+				`<SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>`+
+				`<Expiration>%s</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`,
+				executionRoleAccessKey, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			return
+		}
+		if strings.Contains(r.Header.Get(authorizationHeader), "Credential="+executionRoleAccessKey+"/") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"The execution role is not authorized to perform ssm:GetParameter"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Parameter":{"Name":"/my-app/db-password","Value":"it's a secret"}}`)
+	}))
+}
+
+func TestAWSSecretsClientExecutionRole(t *testing.T) {
+	executionRoleAccessKey := "ASIAEXECUTIONROLE"
+	server := newSecretsAWSServer(executionRoleAccessKey)
+	defer server.Close()
+	for name, value := range map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIABASECREDENTIALS",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_REGION":            "us-west-2",
+		endpointURLVar:          server.URL,
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	// the base credentials are used without an execution role
+	value, err := newAWSSecretsClient("").getParameter(context.Background(), "/my-app/db-password")
+	assert.NoError(t, err, "Unexpected error getting the parameter with the base credentials")
+	assert.Equal(t, "it's a secret", value, "Expected the value of the parameter")
+
+	// with an execution role, which is not allowed to read the parameter, the request is denied like in ECS
+	_, err = newAWSSecretsClient("arn:aws:iam::111111111111:role/ecsTaskExecutionRole").getParameter(context.Background(), "/my-app/db-password")
+	assert.Error(t, err, "Expected the execution role to be denied")
+	assert.Equal(t, http.StatusForbidden, getErrorMessage(err).HTTPErrorCode, "Expected http status code to be 403")
+}
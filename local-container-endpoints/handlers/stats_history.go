@@ -0,0 +1,57 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"sync"
+
+	"github.com/docker/docker/api/types"
+)
+
+// statsHistory stores the last stats samples obtained for each container,
+// so that rates can be computed between subsequent stats requests
+type statsHistory struct {
+	lock    sync.Mutex
+	samples map[string]statsSamples
+}
+
+// statsSamples are the latest sample of a container and the one before it
+type statsSamples struct {
+	latest   *types.StatsJSON
+	previous *types.StatsJSON
+}
+
+func newStatsHistory() *statsHistory {
+	return &statsHistory{
+		samples: make(map[string]statsSamples),
+	}
+}
+
+// swap records the current sample for a container and returns the previous one, or nil if there isn't one.
+// The same sample can be returned again for requests made shortly after each other, since docker stats are cached;
+// it is then compared with the sample before it, so that the rates stay the same.
+func (history *statsHistory) swap(containerID string, current *types.StatsJSON) *types.StatsJSON {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	samples := history.samples[containerID]
+	if samples.latest != nil && current.Read.Equal(samples.latest.Read) {
+		return samples.previous
+	}
+	history.samples[containerID] = statsSamples{
+		latest:   current,
+		previous: samples.latest,
+	}
+	return samples.latest
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsHistorySwap(t *testing.T) {
+	history := newStatsHistory()
+	now := time.Now()
+	first := &types.StatsJSON{Stats: types.Stats{Read: now}}
+	second := &types.StatsJSON{Stats: types.Stats{Read: now.Add(time.Second)}}
+
+	assert.Nil(t, history.swap(longID1, first), "Expected no previous sample")
+	assert.Nil(t, history.swap(longID1, first), "Expected no previous sample for the same sample")
+	assert.Equal(t, first, history.swap(longID1, second), "Expected the previous sample")
+	// a cached sample is compared with the same sample as before
+	assert.Equal(t, first, history.swap(longID1, second), "Expected the sample before the cached one")
+	assert.Nil(t, history.swap(longID2, first), "Expected each container to have its own samples")
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/useragent"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// taskDefinitionPattern matches the family of a task definition with an optional revision, or a task definition ARN
+var taskDefinitionPattern = regexp.MustCompile(`^([a-zA-Z0-9_-]{1,255}(:[0-9]+)?|arn:aws[a-z-]*:ecs:[a-z0-9-]+:[0-9]{12}:task-definition/[a-zA-Z0-9_-]{1,255}:[0-9]+)$`)
+
+// loadTaskDefinition returns the task definition which the local 'task' is set to run, or nil if there is none,
+// and the FireLens configurations of its containers; ECS_LOCAL_TASK_DEFINITION is the path of a JSON file,
+// or else a task definition which is obtained from ECS
+func loadTaskDefinition() (*ecs.TaskDefinition, map[string]*metadata.FirelensConfiguration, error) {
+	value := os.Getenv(config.TaskDefinitionVar)
+	if value == "" {
+		return nil, nil, nil
+	}
+	var taskDefinition *ecs.TaskDefinition
+	var firelensConfigurations map[string]*metadata.FirelensConfiguration
+	var err error
+	if isTaskDefinitionFile(value) {
+		taskDefinition, firelensConfigurations, err = readTaskDefinitionFile(value)
+	} else {
+		taskDefinition, firelensConfigurations, err = describeTaskDefinition(value)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	logrus.Infof("Using task definition %s:%d in metadata", aws.StringValue(taskDefinition.Family), aws.Int64Value(taskDefinition.Revision))
+	return taskDefinition, firelensConfigurations, nil
+}
+
+// validateTaskDefinition checks the task definition file, or that the task definition which is obtained from ECS
+// is a family and revision or an ARN, without making any requests to ECS
+func validateTaskDefinition() error {
+	value := os.Getenv(config.TaskDefinitionVar)
+	if value == "" {
+		return nil
+	}
+	if isTaskDefinitionFile(value) {
+		_, _, err := readTaskDefinitionFile(value)
+		return err
+	}
+	if !taskDefinitionPattern.MatchString(value) {
+		return fmt.Errorf("Invalid %s: %s is not a file, a family and revision, or a task definition ARN", config.TaskDefinitionVar, value)
+	}
+	return nil
+}
+
+// isTaskDefinitionFile returns true if the value is the path of a file, rather than a task definition in ECS
+func isTaskDefinitionFile(value string) bool {
+	if _, err := os.Stat(value); err == nil {
+		return true
+	}
+	return !taskDefinitionPattern.MatchString(value)
+}
+
+// readTaskDefinitionFile reads a task definition from a JSON file, which can be the output of
+// aws ecs describe-task-definition, or the task definition itself, as it is given to register-task-definition
+func readTaskDefinitionFile(path string) (*ecs.TaskDefinition, map[string]*metadata.FirelensConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Failed to read task definition file %s", path)
+	}
+	var output struct {
+		TaskDefinition *ecs.TaskDefinition `json:"taskDefinition"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, nil, errors.Wrapf(err, "Failed to parse task definition file %s", path)
+	}
+	taskDefinition := output.TaskDefinition
+	if taskDefinition == nil {
+		taskDefinition = &ecs.TaskDefinition{}
+		if err := json.Unmarshal(data, taskDefinition); err != nil {
+			return nil, nil, errors.Wrapf(err, "Failed to parse task definition file %s", path)
+		}
+	}
+	if aws.StringValue(taskDefinition.Family) == "" {
+		return nil, nil, fmt.Errorf("Invalid task definition file %s: the family is not set", path)
+	}
+	return taskDefinition, readFirelensConfigurations(data), nil
+}
+
+// readFirelensConfigurations returns the FireLens configurations of the container definitions, keyed by
+// container name, from the JSON of a task definition; the ECS client is older than FireLens, so it drops them
+func readFirelensConfigurations(data []byte) map[string]*metadata.FirelensConfiguration {
+	type firelensContainerDefinition struct {
+		Name                  string                          `json:"name"`
+		FirelensConfiguration *metadata.FirelensConfiguration `json:"firelensConfiguration"`
+	}
+	var output struct {
+		TaskDefinition *struct {
+			ContainerDefinitions []firelensContainerDefinition `json:"containerDefinitions"`
+		} `json:"taskDefinition"`
+		ContainerDefinitions []firelensContainerDefinition `json:"containerDefinitions"`
+	}
+	// the task definition has already been parsed, so the JSON is valid
+	json.Unmarshal(data, &output)
+	containerDefinitions := output.ContainerDefinitions
+	if output.TaskDefinition != nil {
+		containerDefinitions = output.TaskDefinition.ContainerDefinitions
+	}
+
+	firelensConfigurations := make(map[string]*metadata.FirelensConfiguration)
+	for _, containerDefinition := range containerDefinitions {
+		if containerDefinition.FirelensConfiguration != nil {
+			firelensConfigurations[containerDefinition.Name] = containerDefinition.FirelensConfiguration
+		}
+	}
+	return firelensConfigurations
+}
+
+// describeTaskDefinition obtains a task definition from ECS, with the credentials which roles are assumed with
+func describeTaskDefinition(taskDefinition string) (*ecs.TaskDefinition, map[string]*metadata.FirelensConfiguration, error) {
+	_, _, sess, err := newProfileClients("")
+	if err != nil {
+		return nil, nil, err
+	}
+	ecsConfig := &aws.Config{}
+	if endpoint := getEndpointURL(ecsEndpointURLVar); endpoint != "" {
+		ecsConfig.Endpoint = aws.String(endpoint)
+	}
+	ecsClient := ecs.New(sess, ecsConfig)
+	ecsClient.Handlers.Build.PushBackNamed(useragent.CustomUserAgentHandler())
+	req, output := ecsClient.DescribeTaskDefinitionRequest(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinition),
+	})
+	// the response is kept, to read the fields which the ECS client does not have
+	var body []byte
+	req.Handlers.Unmarshal.PushFront(func(r *request.Request) {
+		body, r.Error = ioutil.ReadAll(r.HTTPResponse.Body)
+		r.HTTPResponse.Body = ioutil.NopCloser(bytes.NewReader(body))
+	})
+	if err := req.Send(); err != nil {
+		return nil, nil, errors.Wrapf(err, "Failed to describe task definition %s", taskDefinition)
+	}
+	return output.TaskDefinition, readFirelensConfigurations(body), nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+const describeTaskDefinitionOutput = `{
+    "taskDefinition": {
+        "taskDefinitionArn": "arn:aws:ecs:us-west-2:111111111111:task-definition/my-app:7",
+        "containerDefinitions": [
+            {
+                "name": "app",
+                "image": "my-app:latest",
+                "cpu": 256,
+                "memory": 512,
+                "portMappings": [
+                    {
+                        "containerPort": 80,
+                        "hostPort": 80,
+                        "protocol": "tcp"
+                    }
+                ],
+                "essential": true,
+                "environment": [],
+                "logConfiguration": {
+                    "logDriver": "awslogs",
+                    "options": {
+                        "awslogs-group": "/ecs/my-app"
+                    }
+                }
+            }
+        ],
+        "family": "my-app",
+        "revision": 7,
+        "status": "ACTIVE",
+        "cpu": "256",
+        "memory": "512"
+    }
+}`
+
+func TestReadTaskDefinitionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "task-definition")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	describeOutputFile := filepath.Join(dir, "describe.json")
+	err = ioutil.WriteFile(describeOutputFile, []byte(describeTaskDefinitionOutput), 0644)
+	assert.NoError(t, err, "Unexpected error writing file")
+	taskDefinition, _, err := readTaskDefinitionFile(describeOutputFile)
+	assert.NoError(t, err, "Unexpected error reading the describe-task-definition output")
+	assert.Equal(t, "my-app", aws.StringValue(taskDefinition.Family), "Expected family to match")
+	assert.Equal(t, int64(7), aws.Int64Value(taskDefinition.Revision), "Expected revision to match")
+	assert.Len(t, taskDefinition.ContainerDefinitions, 1, "Expected one container definition")
+	assert.Equal(t, "app", aws.StringValue(taskDefinition.ContainerDefinitions[0].Name), "Expected container name to match")
+	assert.Equal(t, int64(80), aws.Int64Value(taskDefinition.ContainerDefinitions[0].PortMappings[0].ContainerPort), "Expected container port to match")
+	assert.Equal(t, "awslogs", aws.StringValue(taskDefinition.ContainerDefinitions[0].LogConfiguration.LogDriver), "Expected log driver to match")
+
+	registerInputFile := filepath.Join(dir, "register.json")
+	err = ioutil.WriteFile(registerInputFile, []byte(`{"family": "my-app", "containerDefinitions": [{"name": "app", "image": "my-app:latest"}]}`), 0644)
+	assert.NoError(t, err, "Unexpected error writing file")
+	taskDefinition, _, err = readTaskDefinitionFile(registerInputFile)
+	assert.NoError(t, err, "Unexpected error reading the task definition")
+	assert.Equal(t, "my-app", aws.StringValue(taskDefinition.Family), "Expected family to match")
+	assert.Nil(t, taskDefinition.Revision, "Expected no revision")
+
+	invalidFile := filepath.Join(dir, "invalid.json")
+	err = ioutil.WriteFile(invalidFile, []byte(`{"containerDefinitions": []}`), 0644)
+	assert.NoError(t, err, "Unexpected error writing file")
+	_, _, err = readTaskDefinitionFile(invalidFile)
+	assert.Error(t, err, "Expected error for a task definition without a family")
+
+	_, _, err = readTaskDefinitionFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err, "Expected error for a file which does not exist")
+}
+
+func TestReadFirelensConfigurations(t *testing.T) {
+	containerDefinitions := `"containerDefinitions": [
+            {
+                "name": "log_router",
+                "image": "amazon/aws-for-fluent-bit:stable",
+                "firelensConfiguration": {
+                    "type": "fluentbit",
+                    "options": {
+                        "enable-ecs-log-metadata": "true"
+                    }
+                }
+            },
+            {
+                "name": "app",
+                "logConfiguration": {
+                    "logDriver": "awsfirelens",
+                    "options": {
+                        "Name": "cloudwatch"
+                    }
+                }
+            }
+        ]`
+	expected := map[string]*metadata.FirelensConfiguration{
+		"log_router": {
+			Type: "fluentbit",
+			Options: map[string]string{
+				"enable-ecs-log-metadata": "true",
+			},
+		},
+	}
+	assert.Equal(t, expected, readFirelensConfigurations([]byte(`{"taskDefinition": {`+containerDefinitions+`}}`)), "Expected the configuration in the describe-task-definition output")
+	assert.Equal(t, expected, readFirelensConfigurations([]byte(`{"family": "my-app", `+containerDefinitions+`}`)), "Expected the configuration in the task definition")
+	assert.Empty(t, readFirelensConfigurations([]byte(describeTaskDefinitionOutput)), "Expected no configurations in a task definition without FireLens")
+}
+
+func TestValidateTaskDefinition(t *testing.T) {
+	defer os.Unsetenv(config.TaskDefinitionVar)
+
+	os.Unsetenv(config.TaskDefinitionVar)
+	assert.NoError(t, validateTaskDefinition(), "Unexpected error without a task definition")
+
+	for _, value := range []string{"my-app", "my-app:7", "arn:aws:ecs:us-west-2:111111111111:task-definition/my-app:7"} {
+		os.Setenv(config.TaskDefinitionVar, value)
+		assert.NoError(t, validateTaskDefinition(), "Unexpected error for %s", value)
+		assert.False(t, isTaskDefinitionFile(value), "Expected %s to be obtained from ECS", value)
+	}
+
+	os.Setenv(config.TaskDefinitionVar, "/config/task-definition.json")
+	assert.True(t, isTaskDefinitionFile("/config/task-definition.json"), "Expected a path to be a file")
+	assert.Error(t, validateTaskDefinition(), "Expected error for a file which does not exist")
+}
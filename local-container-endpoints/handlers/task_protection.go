@@ -0,0 +1,181 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// the protection lasts for 2 hours unless ExpiresInMinutes is set, and for at most 48 hours, as in ECS
+	defaultProtectionMinutes = 120
+	maxProtectionMinutes     = 2880
+)
+
+// taskProtectionRequest is the body of a request to update the scale-in protection of the caller's task
+type taskProtectionRequest struct {
+	ProtectionEnabled *bool
+	ExpiresInMinutes  *int
+}
+
+// taskProtectionResponse is the schema of the task protection responses of the ECS Agent
+type taskProtectionResponse struct {
+	Protection taskProtection `json:"protection"`
+}
+
+type taskProtection struct {
+	ExpirationDate    *time.Time `json:"ExpirationDate,omitempty"`
+	ProtectionEnabled bool       `json:"ProtectionEnabled"`
+	TaskARN           string     `json:"TaskArn"`
+}
+
+// taskProtectionStates holds the scale-in protection of each local 'task' in memory, keyed by task ARN;
+// a task is protected until its protection expires
+type taskProtectionStates struct {
+	lock        sync.Mutex
+	expirations map[string]time.Time
+	now         func() time.Time
+}
+
+func newTaskProtectionStates() *taskProtectionStates {
+	return &taskProtectionStates{
+		expirations: make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// get returns the protection of the task
+func (states *taskProtectionStates) get(taskARN string) taskProtection {
+	states.lock.Lock()
+	defer states.lock.Unlock()
+
+	protection := taskProtection{
+		TaskARN: taskARN,
+	}
+	expiration, ok := states.expirations[taskARN]
+	if !ok {
+		return protection
+	}
+	if !states.now().Before(expiration) {
+		delete(states.expirations, taskARN)
+		return protection
+	}
+	protection.ProtectionEnabled = true
+	protection.ExpirationDate = &expiration
+	return protection
+}
+
+// set protects the task for the duration, or removes its protection if enabled is false
+func (states *taskProtectionStates) set(taskARN string, enabled bool, duration time.Duration) taskProtection {
+	states.lock.Lock()
+	if enabled {
+		states.expirations[taskARN] = states.now().Add(duration).UTC()
+	} else {
+		delete(states.expirations, taskARN)
+	}
+	states.lock.Unlock()
+	return states.get(taskARN)
+}
+
+// SetupTaskProtectionRoutes sets up the routes of the ECS task scale-in protection API, which is served at $ECS_AGENT_URI/task-protection/v1/state
+func (service *MetadataService) SetupTaskProtectionRoutes(router *mux.Router) {
+	router.HandleFunc(config.TaskProtectionPath, ServeHTTP(service.taskProtectionHandler))
+	router.HandleFunc(config.TaskProtectionPathWithSlash, ServeHTTP(service.taskProtectionHandler))
+	router.HandleFunc(config.AgentAPITaskProtectionPath, ServeHTTP(service.taskProtectionHandler))
+	router.HandleFunc(config.AgentAPITaskProtectionPathWithSlash, ServeHTTP(service.taskProtectionHandler))
+}
+
+// taskProtectionHandler returns the protection of the caller's task with GET, and updates it with PUT
+func (service *MetadataService) taskProtectionHandler(w http.ResponseWriter, r *http.Request) error {
+	logrus.Debug("Received task protection request")
+
+	var request taskProtectionRequest
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  errors.Wrap(err, "Invalid task protection request"),
+			}
+		}
+		if request.ProtectionEnabled == nil {
+			return HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("Invalid task protection request: ProtectionEnabled is required"),
+			}
+		}
+		if request.ExpiresInMinutes != nil && (*request.ExpiresInMinutes < 1 || *request.ExpiresInMinutes > maxProtectionMinutes) {
+			return HTTPError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("Invalid task protection request: ExpiresInMinutes must be between 1 and %d", maxProtectionMinutes),
+			}
+		}
+	default:
+		return HTTPError{
+			Code: http.StatusMethodNotAllowed,
+			Err:  fmt.Errorf("Invalid method %s; the task protection state is read with GET and updated with PUT", r.Method),
+		}
+	}
+
+	taskARN, err := service.getCallerTaskARN(r.Context(), getCallerIP(r))
+	if err != nil {
+		return err
+	}
+
+	var protection taskProtection
+	if r.Method == http.MethodGet {
+		protection = service.taskProtection.get(taskARN)
+	} else {
+		minutes := defaultProtectionMinutes
+		if request.ExpiresInMinutes != nil {
+			minutes = *request.ExpiresInMinutes
+		}
+		protection = service.taskProtection.set(taskARN, *request.ProtectionEnabled, time.Duration(minutes)*time.Minute)
+		logrus.Infof("Set task protection of %s to %t", taskARN, protection.ProtectionEnabled)
+	}
+
+	writeJSONResponse(w, &taskProtectionResponse{
+		Protection: protection,
+	})
+	return nil
+}
+
+// getCallerTaskARN returns the ARN of the local 'task' of the container which made the request
+func (service *MetadataService) getCallerTaskARN(ctx context.Context, callerIP string) (string, error) {
+	timeout, _ := time.ParseDuration(config.HTTPTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, err := service.dockerClient.ContainerList(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to list running containers")
+	}
+	taskContainers, err := getTaskContainers(containers, "", callerIP, service.unknownCallerAllContainers)
+	if err != nil {
+		return "", err
+	}
+	return metadata.GetTaskARN(taskContainers), nil
+}
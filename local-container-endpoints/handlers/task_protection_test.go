@@ -0,0 +1,112 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskProtectionStatesExpire(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	states := newTaskProtectionStates()
+	states.now = func() time.Time { return now }
+
+	protection := states.set("task", true, time.Hour)
+	assert.True(t, protection.ProtectionEnabled, "Expected the task to be protected")
+	assert.Equal(t, now.Add(time.Hour), *protection.ExpirationDate, "Expected the protection to expire after the duration")
+	assert.False(t, states.get("other-task").ProtectionEnabled, "Expected other tasks not to be protected")
+
+	now = now.Add(time.Hour)
+	protection = states.get("task")
+	assert.False(t, protection.ProtectionEnabled, "Expected the protection to have expired")
+	assert.Nil(t, protection.ExpirationDate, "Expected no expiration once the protection has expired")
+
+	states.set("task", true, time.Hour)
+	assert.False(t, states.set("task", false, time.Hour).ProtectionEnabled, "Expected the protection to be removed")
+}
+
+func TestTaskProtectionHandler(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName1, longID1).WithNetwork(network1, "192.0.2.1").WithComposeProject(projectName).Get()
+	container2 := testingutils.BaseDockerContainer(containerName2, longID2).WithNetwork(network1, ipAddress2).WithComposeProject(projectName2).Get()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{container1, container2}, nil).AnyTimes()
+
+	service, err := NewMetadataServiceWithClient(dockerMock)
+	assert.NoError(t, err, "Unexpected error creating metadata service")
+	router := mux.NewRouter()
+	service.SetupTaskProtectionRoutes(router)
+
+	// httptest requests come from 192.0.2.1, which is the IP of container1
+	send := func(method, path, body string) (*httptest.ResponseRecorder, *taskProtectionResponse) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		response := &taskProtectionResponse{}
+		if recorder.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response), "Unexpected error unmarshalling response")
+		}
+		return recorder, response
+	}
+
+	recorder, response := send(http.MethodGet, config.TaskProtectionPath, "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	assert.False(t, response.Protection.ProtectionEnabled, "Expected the task not to be protected")
+	assert.Equal(t, "arn:aws:ecs:us-west-2:111111111111:task/ecs-local-cluster/e14298f5-b033-5935-9a4e-d380c7a38ecd", response.Protection.TaskARN, "Expected the ARN of the caller's task")
+
+	recorder, response = send(http.MethodPut, "/api/"+longID1+config.TaskProtectionPath, `{"ProtectionEnabled":true,"ExpiresInMinutes":60}`)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	assert.True(t, response.Protection.ProtectionEnabled, "Expected the task to be protected")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *response.Protection.ExpirationDate, time.Minute, "Expected the protection to expire in 60 minutes")
+
+	recorder, response = send(http.MethodGet, config.TaskProtectionPath, "")
+	assert.True(t, response.Protection.ProtectionEnabled, "Expected the task to still be protected")
+
+	recorder, response = send(http.MethodPut, config.TaskProtectionPath, `{"ProtectionEnabled":false}`)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	assert.False(t, response.Protection.ProtectionEnabled, "Expected the protection to be removed")
+	assert.Nil(t, response.Protection.ExpirationDate, "Expected no expiration without protection")
+
+	var errorCases = []struct {
+		name         string
+		method       string
+		body         string
+		expectedCode int
+	}{
+		{"invalid json", http.MethodPut, `{"ProtectionEnabled":`, http.StatusBadRequest},
+		{"missing ProtectionEnabled", http.MethodPut, `{"ExpiresInMinutes":60}`, http.StatusBadRequest},
+		{"expiration too long", http.MethodPut, `{"ProtectionEnabled":true,"ExpiresInMinutes":2881}`, http.StatusBadRequest},
+		{"invalid method", http.MethodPost, `{"ProtectionEnabled":true}`, http.StatusMethodNotAllowed},
+	}
+	for _, testCase := range errorCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder, _ := send(testCase.method, config.TaskProtectionPath, testCase.body)
+			assert.Equal(t, testCase.expectedCode, recorder.Code, "Expected http status code to match")
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/proxy"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+)
+
+// ServerTimeouts are the timeouts of the HTTP servers; they are 0 if disabled
+type ServerTimeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Idle  time.Duration
+}
+
+// GetServerTimeouts returns the timeouts of the HTTP servers from the environment variables
+func GetServerTimeouts() (*ServerTimeouts, error) {
+	read, err := getTimeout(config.ReadTimeoutVar, config.DefaultReadTimeoutDuration)
+	if err != nil {
+		return nil, err
+	}
+	write, err := getTimeout(config.WriteTimeoutVar, "0")
+	if err != nil {
+		return nil, err
+	}
+	idle, err := getTimeout(config.IdleTimeoutVar, config.DefaultIdleTimeoutDuration)
+	if err != nil {
+		return nil, err
+	}
+	return &ServerTimeouts{
+		Read:  read,
+		Write: write,
+		Idle:  idle,
+	}, nil
+}
+
+// Apply sets the timeouts on the server
+func (timeouts *ServerTimeouts) Apply(server *http.Server) {
+	server.ReadTimeout = timeouts.Read
+	server.ReadHeaderTimeout = timeouts.Read
+	server.WriteTimeout = timeouts.Write
+	server.IdleTimeout = timeouts.Idle
+}
+
+// newAWSHTTPClient returns the HTTP client for requests to AWS, so that a request which hangs fails after the timeout;
+// requests go through the outbound proxy, if one is set
+func newAWSHTTPClient() (*http.Client, error) {
+	timeout, err := getTimeout(config.AWSTimeoutVar, config.DefaultAWSTimeoutDuration)
+	if err != nil {
+		return nil, err
+	}
+	proxySettings, err := proxy.FromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	proxySettings.Apply(transport)
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// GetDockerCacheTTL returns how long the results of docker stats and inspect are cached; 0 disables the cache
+func GetDockerCacheTTL() (time.Duration, error) {
+	value := utils.GetValue(config.DefaultDockerCacheTTLDuration, config.DockerCacheTTLVar)
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("Invalid %s: %s is not a duration, such as 5s, or 0 to disable the cache", config.DockerCacheTTLVar, value)
+	}
+	return ttl, nil
+}
+
+func getTimeout(envVar, defaultDuration string) (time.Duration, error) {
+	value := utils.GetValue(defaultDuration, envVar)
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("Invalid %s: %s is not a duration, such as 30s", envVar, value)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetServerTimeouts(t *testing.T) {
+	defer os.Unsetenv(config.ReadTimeoutVar)
+	defer os.Unsetenv(config.WriteTimeoutVar)
+	defer os.Unsetenv(config.IdleTimeoutVar)
+
+	timeouts, err := GetServerTimeouts()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, &ServerTimeouts{Read: 30 * time.Second, Idle: 2 * time.Minute}, timeouts, "Expected the default timeouts")
+
+	os.Setenv(config.ReadTimeoutVar, "0")
+	os.Setenv(config.WriteTimeoutVar, "1m")
+	os.Setenv(config.IdleTimeoutVar, "10s")
+	timeouts, err = GetServerTimeouts()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, &ServerTimeouts{Write: time.Minute, Idle: 10 * time.Second}, timeouts, "Expected the timeouts to match")
+
+	server := &http.Server{}
+	timeouts.Apply(server)
+	assert.Equal(t, time.Duration(0), server.ReadTimeout, "Expected the read timeout to be disabled")
+	assert.Equal(t, time.Minute, server.WriteTimeout, "Expected the write timeout to match")
+	assert.Equal(t, 10*time.Second, server.IdleTimeout, "Expected the idle timeout to match")
+
+	os.Setenv(config.WriteTimeoutVar, "60")
+	_, err = GetServerTimeouts()
+	assert.Error(t, err, "Expected error for a timeout without units")
+}
+
+func TestNewAWSHTTPClient(t *testing.T) {
+	defer os.Unsetenv(config.AWSTimeoutVar)
+
+	client, err := newAWSHTTPClient()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 30*time.Second, client.Timeout, "Expected the default timeout")
+
+	os.Setenv(config.AWSTimeoutVar, "-1s")
+	_, err = newAWSHTTPClient()
+	assert.Error(t, err, "Expected error for a negative timeout")
+
+	// a request which hangs fails after the timeout
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	os.Setenv(config.AWSTimeoutVar, "50ms")
+	client, err = newAWSHTTPClient()
+	assert.NoError(t, err, "Unexpected error")
+	_, err = client.Get(server.URL)
+	assert.Error(t, err, "Expected the request to time out")
+}
+
+func TestNewAWSHTTPClientWithProxy(t *testing.T) {
+	defer os.Unsetenv(config.ProxyVar)
+	os.Setenv(config.ProxyVar, "http://proxy.example.com:3128")
+
+	client, err := newAWSHTTPClient()
+	assert.NoError(t, err, "Unexpected error")
+	req, _ := http.NewRequest(http.MethodPost, "https://sts.amazonaws.com/", nil)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "http://proxy.example.com:3128", proxyURL.String(), "Expected requests to AWS to go through the proxy")
+
+	os.Setenv(config.ProxyVar, "ftp://proxy.example.com")
+	_, err = newAWSHTTPClient()
+	assert.Error(t, err, "Expected error for an invalid proxy")
+}
+
+func TestGetDockerCacheTTL(t *testing.T) {
+	defer os.Unsetenv(config.DockerCacheTTLVar)
+
+	ttl, err := GetDockerCacheTTL()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, time.Second, ttl, "Expected the default TTL")
+
+	os.Setenv(config.DockerCacheTTLVar, "0")
+	ttl, err = GetDockerCacheTTL()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, time.Duration(0), ttl, "Expected the cache to be disabled")
+
+	os.Setenv(config.DockerCacheTTLVar, "5")
+	_, err = GetDockerCacheTTL()
+	assert.Error(t, err, "Expected error for a TTL without units")
+	assert.Contains(t, err.Error(), "0 to disable the cache", "Expected the error to explain how to disable the cache")
+}
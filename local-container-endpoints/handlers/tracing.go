@@ -0,0 +1,53 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+)
+
+// TraceRequests is middleware which records a server span for each request; it continues the trace of the
+// traceparent header if the caller sent one, so the spans of the AWS and Docker calls made for it are children
+func TraceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := tracing.Extract(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path, tracing.Server)
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+		span.SetAttribute("client.address", getCallerIP(r))
+		if requestID := tracing.RequestID(r.Context()); requestID != "" {
+			span.SetAttribute("http.response.header.x-request-id", requestID)
+		}
+		recorder := &statusRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.response.status_code", recorder.status)
+		var err error
+		if recorder.status >= http.StatusInternalServerError {
+			err = errors.New(http.StatusText(recorder.status))
+		}
+		span.End(err)
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Status       struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+func TestTraceRequests(t *testing.T) {
+	var lock sync.Mutex
+	var spans []exportedSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		assert.NoError(t, err, "Unexpected error decoding the export request")
+		lock.Lock()
+		defer lock.Unlock()
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	os.Setenv(config.OTLPTracesEndpointVar, collector.URL+"/v1/traces")
+	defer os.Unsetenv(config.OTLPTracesEndpointVar)
+	err := tracing.Setup()
+	assert.NoError(t, err, "Unexpected error setting up tracing")
+
+	handler := TraceRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.StartRPC(r.Context(), "aws-api", "STS", "AssumeRole")
+		span.End(nil)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	request := httptest.NewRequest(http.MethodGet, config.ContainerRoleCredentialsPath, nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "Expected http status code to be 500")
+
+	tracing.Shutdown(context.Background())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Len(t, spans, 2, "Expected the server and client spans")
+	if len(spans) != 2 {
+		return
+	}
+	client, server := spans[0], spans[1]
+	assert.Equal(t, "GET "+config.ContainerRoleCredentialsPath, server.Name, "Expected the span name to have the method and path")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID, "Expected the trace of the traceparent header")
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID, "Expected the parent of the traceparent header")
+	assert.Equal(t, 2, server.Status.Code, "Expected the server span to be failed")
+	assert.Equal(t, server.SpanID, client.ParentSpanID, "Expected the client span to be a child of the server span")
+	assert.Equal(t, "STS.AssumeRole", client.Name, "Expected the client span name")
+}
+
+func TestTraceRequestsDisabled(t *testing.T) {
+	handler := TraceRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, tracing.TraceID(r.Context()), "Expected no trace when tracing is disabled")
+		_, ok := w.(*statusRecorder)
+		assert.False(t, ok, "Expected the response writer to be passed through")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, config.ContainerRoleCredentialsPath, nil))
+}
@@ -21,3 +21,101 @@ type CredentialResponse struct {
 	SecretAccessKey string
 	Token           string
 }
+
+// WhoAmIResponse is used to marshal the JSON response for the whoami path
+type WhoAmIResponse struct {
+	// Base is the identity of the credentials given to Local Endpoints
+	Base *CallerIdentity
+	// Role is the identity of the requested role's credentials, if a role was requested
+	Role *CallerIdentity `json:",omitempty"`
+}
+
+// CallerIdentity is the result of sts:GetCallerIdentity
+type CallerIdentity struct {
+	Account string
+	Arn     string
+	UserID  string `json:"UserId"`
+}
+
+// IMDSCredentialResponse is used to marshal the JSON response for credentials in the format of the EC2 instance metadata service
+type IMDSCredentialResponse struct {
+	Code            string
+	LastUpdated     string
+	Type            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+// PodIdentityCredentialResponse is used to marshal the JSON response for the EKS Pod Identity credentials path
+type PodIdentityCredentialResponse struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	AccountID       string `json:"AccountId"`
+	Expiration      string
+}
+
+// OIDCDiscoveryResponse is used to marshal the OpenID Connect discovery document of the OIDC provider
+type OIDCDiscoveryResponse struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// JWKSResponse is used to marshal the JSON Web Key Set of the OIDC provider
+type JWKSResponse struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey is the public part of an RSA signing key
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// RolesResponse is used to marshal the JSON response for the roles path
+type RolesResponse struct {
+	Roles    []RoleSummary
+	Profiles []ProfileSummary
+}
+
+// RoleSummary describes a role from the config file or the task role label of running containers
+type RoleSummary struct {
+	Role                   string
+	CredentialsRelativeURI string
+	// Services are the services in the config file with the role
+	Services []string `json:",omitempty"`
+	// Containers are the running containers with the role in their task role label
+	Containers []string `json:",omitempty"`
+}
+
+// ProfileSummary describes a profile in the shared config files
+type ProfileSummary struct {
+	Name string
+	// Current is true for the profile which is used when a request has no profile query parameter
+	Current bool   `json:",omitempty"`
+	Region  string `json:",omitempty"`
+	RoleArn string `json:",omitempty"`
+}
+
+// HealthResponse is used to marshal the JSON response for the health paths
+type HealthResponse struct {
+	Status string
+}
+
+// VersionResponse is used to marshal the JSON response for the version path
+type VersionResponse struct {
+	Version                   string
+	AgentVersionCompatibility string
+	GitShortHash              string
+	GitDirty                  bool
+}
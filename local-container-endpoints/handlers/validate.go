@@ -0,0 +1,185 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/containerd"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/kubernetes"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sharedconfig"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/pkg/errors"
+)
+
+// ValidateConfig checks the services from the config file, the settings in the environment, and the AWS profile which
+// credentials are sourced from, and returns an error for each problem. No requests are made to AWS or Docker.
+func ValidateConfig(services map[string]configfile.Service) []error {
+	var problems []error
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	disableCredentials, err := utils.GetBoolValue(config.DisableCredentialsVar)
+	check(err)
+	disableMetadata, err := utils.GetBoolValue(config.DisableMetadataVar)
+	check(err)
+	_, err = utils.GetBoolValue(config.DisableDockerEventsVar)
+	check(err)
+	if disableCredentials && disableMetadata {
+		check(fmt.Errorf("The credentials and metadata APIs can't both be disabled"))
+	}
+	_, err = GetServerTimeouts()
+	check(err)
+	_, err = getTLSConfig()
+	check(err)
+
+	roles, err := newRoleFilter()
+	check(err)
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check(validateService(name, services[name]))
+		check(validateServiceRole(name, services[name].Role, roles))
+	}
+
+	check(validateEndpointURLs())
+	if os.Getenv(config.VaultRoleVar) != "" {
+		_, err = getVaultOptions()
+		check(err)
+	}
+	createNetwork, err := utils.GetBoolValue(config.CreateNetworkVar)
+	check(err)
+	if kubernetes.IsSidecar() {
+		_, err = kubernetes.NewPodClient()
+		check(err)
+		if createNetwork {
+			check(fmt.Errorf("%s can't be used in Kubernetes, where the pod has its own network", config.CreateNetworkVar))
+		}
+	} else if containerd.IsEnabled() {
+		_, err = containerd.NewNerdctlClient()
+		check(err)
+		if createNetwork {
+			check(fmt.Errorf("%s can't be used with containerd; create the network with nerdctl network create", config.CreateNetworkVar))
+		}
+	} else {
+		check(docker.ValidateDockerHost())
+	}
+	_, err = isTokenRequired()
+	check(err)
+	_, err = isIMDSTokenRequired()
+	check(err)
+	_, err = getAuthorizationToken()
+	check(err)
+	_, err = getRotationInterval()
+	check(err)
+	_, err = getRoleCacheTTL()
+	check(err)
+	_, err = GetDockerCacheTTL()
+	check(err)
+	_, err = getSessionNameTemplate()
+	check(err)
+	_, err = getMaxRetries()
+	check(err)
+	_, err = getRateLimiter()
+	check(err)
+	_, err = newOIDCIssuer()
+	check(err)
+	_, err = newAWSHTTPClient()
+	check(err)
+
+	// the settings which are read on each request are checked with a request that has no query parameters
+	request := &http.Request{URL: &url.URL{}}
+	_, err = getCredentialsDuration(request)
+	check(err)
+	_, err = getSessionTags(request)
+	check(err)
+	_, err = getSessionPolicy(request)
+	check(err)
+	_, _, err = getTags(nil)
+	check(err)
+	_, err = getEphemeralStorageSize()
+	check(err)
+	check(validateTaskDefinition())
+	_, err = loadComposeProject()
+	check(err)
+
+	offlineSTSClient, _, err := newOfflineSTSClient()
+	check(err)
+	if err == nil && offlineSTSClient == nil {
+		check(validateCurrentProfile())
+	}
+	return problems
+}
+
+// validateServiceRole checks that the role of a service is a valid role name or ARN, which can be assumed
+func validateServiceRole(name, role string, roles *roleFilter) error {
+	if role == "" {
+		return nil
+	}
+	roleName, err := getRoleName(role)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid role for service %s", name)
+	}
+	if err := roles.check(role, roleName); err != nil {
+		return errors.Wrapf(err, "Invalid role for service %s", name)
+	}
+	return nil
+}
+
+// validateCurrentProfile checks that the profile which credentials are sourced from exists in the AWS shared config files,
+// along with the profiles in its source_profile chain. The default profile doesn't need to exist, and no profile is used
+// if there are credentials in the environment.
+func validateCurrentProfile() error {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_ACCESS_KEY") != "" {
+		return nil
+	}
+	sharedConfig, err := sharedconfig.Load()
+	if err != nil {
+		return errors.Wrap(err, "Failed to read the AWS shared config files")
+	}
+	profileName := sharedconfig.CurrentProfileName()
+	if _, ok := sharedConfig.Profile(profileName); !ok && profileName == sharedconfig.DefaultProfile {
+		return nil
+	}
+	return validateProfileReferences(sharedConfig, profileName, make(map[string]bool))
+}
+
+func validateProfileReferences(sharedConfig *sharedconfig.Config, profileName string, visited map[string]bool) error {
+	if visited[profileName] {
+		return fmt.Errorf("Profile %s is part of a source_profile cycle", profileName)
+	}
+	visited[profileName] = true
+
+	profile, ok := sharedConfig.Profile(profileName)
+	if !ok {
+		return fmt.Errorf("Profile %s does not exist in the AWS shared config files", profileName)
+	}
+	if source := profile[sourceProfileKey]; profile[roleARNKey] != "" && source != "" && source != profileName {
+		return validateProfileReferences(sharedConfig, source, visited)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	defer os.Unsetenv(config.MockCredentialsVar)
+	defer os.Unsetenv(config.DeniedRolesVar)
+	defer os.Unsetenv(config.RotationIntervalVar)
+	os.Setenv(config.MockCredentialsVar, "true")
+
+	services := map[string]configfile.Service{
+		"app": configfile.Service{
+			Role:     "app-role",
+			Duration: 900,
+		},
+		"worker": configfile.Service{
+			Role: "arn:aws:iam::111111111111:role/worker",
+		},
+	}
+	assert.Empty(t, ValidateConfig(services), "Expected no problems")
+
+	os.Setenv(config.DeniedRolesVar, "app-*")
+	os.Setenv(config.RotationIntervalVar, "soon")
+	services["worker"] = configfile.Service{
+		Role:     "arn:aws:iam::111111111111:user/worker",
+		Duration: 60,
+	}
+	problems := ValidateConfig(services)
+	assert.Len(t, problems, 4, "Expected a problem for the denied role, the role ARN, the duration, and the rotation interval")
+}
+
+func TestValidateProfileReferences(t *testing.T) {
+	sharedConfig := loadChainConfig(t)
+
+	var testCases = []struct {
+		profile     string
+		expectError bool
+	}{
+		{profile: "static"},
+		{profile: "self"},
+		{profile: "multi-hop"},
+		{profile: "from-environment"},
+		{profile: "cycle-a", expectError: true},
+		{profile: "missing", expectError: true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.profile, func(t *testing.T) {
+			err := validateProfileReferences(sharedConfig, test.profile, make(map[string]bool))
+			if test.expectError {
+				assert.Error(t, err, "Expected error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"sort"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/composefile"
+)
+
+// AddComposeServices adds a PENDING container to task metadata for each service of the Compose project which
+// has no running container, as ECS reports the containers of a task which have not started yet, and orders
+// the containers like the services. The task must be the local 'task' of the project; project can be nil.
+func AddComposeServices(project *composefile.Project, response *v2.TaskResponse) {
+	if !isProjectTask(project, response.Containers) {
+		return
+	}
+	for _, service := range getPendingServices(project, response.Containers) {
+		response.Containers = append(response.Containers, *newPendingContainerResponse(project, service))
+	}
+	order := getServiceOrder(project)
+	sort.SliceStable(response.Containers, func(i, j int) bool {
+		return order(response.Containers[i].Labels) < order(response.Containers[j].Labels)
+	})
+}
+
+// AddV4ComposeServices adds a PENDING container to V4 task metadata for each service of the Compose project
+// which has no running container, and orders the containers like the services
+func AddV4ComposeServices(project *composefile.Project, response *V4TaskResponse) {
+	var containers []v2.ContainerResponse
+	for _, container := range response.Containers {
+		containers = append(containers, *container.ContainerResponse)
+	}
+	if !isProjectTask(project, containers) {
+		return
+	}
+	for _, service := range getPendingServices(project, containers) {
+		response.Containers = append(response.Containers, V4ContainerResponse{
+			ContainerResponse: newPendingContainerResponse(project, service),
+		})
+	}
+	order := getServiceOrder(project)
+	sort.SliceStable(response.Containers, func(i, j int) bool {
+		return order(response.Containers[i].Labels) < order(response.Containers[j].Labels)
+	})
+}
+
+// isProjectTask returns true if the containers are the local 'task' of the Compose project
+func isProjectTask(project *composefile.Project, containers []v2.ContainerResponse) bool {
+	if project == nil || len(containers) == 0 {
+		return false
+	}
+	for _, container := range containers {
+		if container.Labels[ComposeProjectLabel] != project.Name {
+			return false
+		}
+	}
+	return true
+}
+
+func getPendingServices(project *composefile.Project, containers []v2.ContainerResponse) []*composefile.Service {
+	running := make(map[string]bool)
+	for _, container := range containers {
+		running[container.Labels[ComposeServiceLabel]] = true
+	}
+	var pending []*composefile.Service
+	for _, service := range project.Services {
+		if !running[service.Name] {
+			pending = append(pending, service)
+		}
+	}
+	return pending
+}
+
+// getServiceOrder returns a function which gives the position of a container's service in the project;
+// containers which are not from a service of the project are last
+func getServiceOrder(project *composefile.Project) func(labels map[string]string) int {
+	positions := make(map[string]int)
+	for i, service := range project.Services {
+		positions[service.Name] = i
+	}
+	return func(labels map[string]string) int {
+		if position, ok := positions[labels[ComposeServiceLabel]]; ok {
+			return position
+		}
+		return len(project.Services)
+	}
+}
+
+func newPendingContainerResponse(project *composefile.Project, service *composefile.Service) *v2.ContainerResponse {
+	response := newLocalContainerResponse()
+	response.KnownStatus = ecs.DesiredStatusPending
+	response.Name = service.Name
+	response.DockerName = service.ContainerName
+	response.Image = service.Image
+	response.Labels = map[string]string{
+		ComposeProjectLabel: project.Name,
+		ComposeServiceLabel: service.Name,
+	}
+	for _, port := range service.Ports {
+		response.Ports = append(response.Ports, v1.PortResponse{
+			ContainerPort: port.Target,
+			HostPort:      port.Published,
+			Protocol:      port.Protocol,
+		})
+	}
+	return response
+}
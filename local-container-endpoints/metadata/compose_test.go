@@ -0,0 +1,98 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/composefile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestComposeProject() *composefile.Project {
+	return &composefile.Project{
+		Name: projectName,
+		Services: []*composefile.Service{
+			{
+				Name:          "db",
+				Image:         "postgres:11",
+				ContainerName: "my-db",
+				Ports: []composefile.Port{
+					{
+						Target:    5432,
+						Published: 5432,
+						Protocol:  "tcp",
+					},
+				},
+			},
+			{
+				// the Compose service of the test containers
+				Name:      "ecs-local",
+				Image:     "amazon/amazon-ecs-local-container-endpoints",
+				DependsOn: []string{"db"},
+			},
+		},
+	}
+}
+
+func TestAddV4ComposeServices(t *testing.T) {
+	app := testingutils.BaseDockerContainer("project_ecs-local_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject(projectName).Get()
+
+	response := GetV4TaskMetadata([]types.Container{app}, nil, nil, nil, 20)
+	AddV4ComposeServices(newTestComposeProject(), response)
+
+	assert.Len(t, response.Containers, 2, "Expected a container for each service")
+	db := response.Containers[0]
+	assert.Equal(t, "db", db.Name, "Expected the dependency to be first")
+	assert.Equal(t, "my-db", db.DockerName, "Expected the container name of the service")
+	assert.Equal(t, "postgres:11", db.Image, "Expected the image of the service")
+	assert.Equal(t, ecs.DesiredStatusPending, db.KnownStatus, "Expected the service which is not running to be pending")
+	assert.Equal(t, []v1.PortResponse{
+		{
+			ContainerPort: 5432,
+			HostPort:      5432,
+			Protocol:      "tcp",
+		},
+	}, db.Ports, "Expected the ports of the service")
+	assert.Equal(t, "project_ecs-local_1", response.Containers[1].DockerName, "Expected the running container to be second")
+	assert.Equal(t, "RUNNING", response.Containers[1].KnownStatus, "Expected the running container to be unchanged")
+}
+
+func TestAddComposeServices(t *testing.T) {
+	app := testingutils.BaseDockerContainer("project_ecs-local_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject(projectName).Get()
+
+	response := GetTaskMetadata([]types.Container{app}, nil, nil)
+	AddComposeServices(newTestComposeProject(), response)
+
+	assert.Len(t, response.Containers, 2, "Expected a container for each service")
+	assert.Equal(t, "db", response.Containers[0].Name, "Expected the dependency to be first")
+	assert.Equal(t, ecs.DesiredStatusPending, response.Containers[0].KnownStatus, "Expected the service which is not running to be pending")
+	assert.Equal(t, "project_ecs-local_1", response.Containers[1].DockerName, "Expected the running container to be second")
+}
+
+func TestAddComposeServicesOtherTask(t *testing.T) {
+	app := testingutils.BaseDockerContainer("project_ecs-local_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject("other-project").Get()
+	expected := GetTaskMetadata([]types.Container{app}, nil, nil)
+	actual := GetTaskMetadata([]types.Container{app}, nil, nil)
+
+	AddComposeServices(newTestComposeProject(), actual)
+	assert.Equal(t, expected, actual, "Expected the metadata of a task which is not the Compose project to be unchanged")
+
+	AddComposeServices(nil, actual)
+	assert.Equal(t, expected, actual, "Expected the metadata to be unchanged without a Compose project")
+}
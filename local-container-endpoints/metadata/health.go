@@ -0,0 +1,81 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"strings"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	"github.com/docker/docker/api/types"
+)
+
+// getHealthFromStatus returns the health of a container from the status in the docker container list, such as 'Up 2 minutes (healthy)';
+// it is nil if the container has no healthcheck
+func getHealthFromStatus(status string) *apicontainer.HealthStatus {
+	switch {
+	case strings.HasSuffix(status, "(healthy)"):
+		return &apicontainer.HealthStatus{Status: apicontainerstatus.ContainerHealthy}
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return &apicontainer.HealthStatus{Status: apicontainerstatus.ContainerUnhealthy}
+	case strings.HasSuffix(status, "(health: starting)"):
+		return &apicontainer.HealthStatus{Status: apicontainerstatus.ContainerHealthUnknown}
+	}
+	return nil
+}
+
+// convertHealth returns the ECS health of a container from its docker healthcheck state, which is nil if the container has no healthcheck.
+// The output and exit code are those of the last check. Docker doesn't record when the status changed, so the status is considered
+// to have changed at the first of the last checks which had the same result.
+func convertHealth(health *types.Health) *apicontainer.HealthStatus {
+	if health == nil || health.Status == "" || health.Status == types.NoHealthcheck {
+		return nil
+	}
+
+	ecsHealth := &apicontainer.HealthStatus{}
+	switch health.Status {
+	case types.Healthy:
+		ecsHealth.Status = apicontainerstatus.ContainerHealthy
+	case types.Unhealthy:
+		ecsHealth.Status = apicontainerstatus.ContainerUnhealthy
+	default:
+		ecsHealth.Status = apicontainerstatus.ContainerHealthUnknown
+	}
+
+	var results []*types.HealthcheckResult
+	for _, result := range health.Log {
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	if len(results) == 0 {
+		return ecsHealth
+	}
+
+	last := results[len(results)-1]
+	ecsHealth.Output = last.Output
+	ecsHealth.ExitCode = last.ExitCode
+
+	if ecsHealth.Status != apicontainerstatus.ContainerHealthUnknown {
+		var since time.Time
+		for i := len(results) - 1; i >= 0 && (results[i].ExitCode == 0) == (last.ExitCode == 0); i-- {
+			since = results[i].End
+		}
+		if !since.IsZero() {
+			ecsHealth.Since = &since
+		}
+	}
+	return ecsHealth
+}
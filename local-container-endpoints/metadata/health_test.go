@@ -0,0 +1,62 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHealthFromStatus(t *testing.T) {
+	assert.Equal(t, apicontainerstatus.ContainerHealthy, getHealthFromStatus("Up 2 minutes (healthy)").Status, "Expected healthy status")
+	assert.Equal(t, apicontainerstatus.ContainerUnhealthy, getHealthFromStatus("Up 2 minutes (unhealthy)").Status, "Expected unhealthy status")
+	assert.Equal(t, apicontainerstatus.ContainerHealthUnknown, getHealthFromStatus("Up 3 seconds (health: starting)").Status, "Expected unknown status while starting")
+	assert.Nil(t, getHealthFromStatus("Up 2 minutes"), "Expected no health without a healthcheck")
+}
+
+func TestConvertHealth(t *testing.T) {
+	checkTime := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	health := &types.Health{
+		Status: types.Unhealthy,
+		Log: []*types.HealthcheckResult{
+			&types.HealthcheckResult{End: checkTime, ExitCode: 0, Output: "ok"},
+			&types.HealthcheckResult{End: checkTime.Add(10 * time.Second), ExitCode: 1, Output: "connection refused"},
+			&types.HealthcheckResult{End: checkTime.Add(20 * time.Second), ExitCode: 1, Output: "timed out"},
+		},
+	}
+
+	actual := convertHealth(health)
+	assert.Equal(t, apicontainerstatus.ContainerUnhealthy, actual.Status, "Expected unhealthy status")
+	assert.Equal(t, "timed out", actual.Output, "Expected the output of the last check")
+	assert.Equal(t, 1, actual.ExitCode, "Expected the exit code of the last check")
+	assert.Equal(t, checkTime.Add(10*time.Second), *actual.Since, "Expected the status to change at the first failing check")
+
+	body, err := json.Marshal(actual)
+	assert.NoError(t, err, "Unexpected error marshalling health")
+	assert.Contains(t, string(body), `"status":"UNHEALTHY"`, "Expected the ECS health status in the response")
+}
+
+func TestConvertHealthStarting(t *testing.T) {
+	actual := convertHealth(&types.Health{Status: types.Starting})
+	assert.Equal(t, apicontainerstatus.ContainerHealthUnknown, actual.Status, "Expected unknown status while starting")
+	assert.Nil(t, actual.Since, "Expected no status change time while starting")
+
+	assert.Nil(t, convertHealth(&types.Health{Status: types.NoHealthcheck}), "Expected no health without a healthcheck")
+	assert.Nil(t, convertHealth(nil), "Expected no health without a healthcheck")
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/version"
+	"github.com/docker/docker/api/types"
+)
+
+// containerInstanceID is the ID of the mock container instance which runs the local 'tasks'
+const containerInstanceID = "4b6d45ea-a4b4-4269-9d04-3af6ddfdc597"
+
+// GetIntrospectionMetadata returns the response of the ECS Agent introspection metadata path, for the mock container instance
+func GetIntrospectionMetadata() *v1.MetadataResponse {
+	containerInstanceARN := getContainerInstanceARN()
+	return &v1.MetadataResponse{
+		Cluster:              utils.GetValue(config.DefaultClusterName, config.ClusterARNVar),
+		ContainerInstanceArn: &containerInstanceARN,
+		Version:              fmt.Sprintf("Amazon ECS Agent - v%s (%s %s)", version.AgentVersionCompatibility, version.AppName, version.Version),
+	}
+}
+
+// GetIntrospectionTasks returns the response of the ECS Agent introspection tasks path; each Compose project is a separate task,
+// and the containers which are not in a project are one task with the default task ARN
+func GetIntrospectionTasks(dockerContainers []types.Container) *v1.TasksResponse {
+	response := &v1.TasksResponse{
+		Tasks: []*v1.TaskResponse{},
+	}
+	tasks := make(map[string]*v1.TaskResponse)
+	for _, container := range dockerContainers {
+		taskARN := getTaskARN(container.Labels[ComposeProjectLabel])
+		task, ok := tasks[taskARN]
+		if !ok {
+			task = &v1.TaskResponse{
+				Arn:           taskARN,
+				DesiredStatus: ecs.DesiredStatusRunning,
+				KnownStatus:   ecs.DesiredStatusRunning,
+				Family:        utils.GetValue(config.DefaultTDFamily, config.TDFamilyVar),
+				Version:       utils.GetValue(config.DefaultTDRevision, config.TDRevisionVar),
+				Containers:    []v1.ContainerResponse{},
+			}
+			tasks[taskARN] = task
+			response.Tasks = append(response.Tasks, task)
+		}
+		task.Containers = append(task.Containers, v1.ContainerResponse{
+			DockerID:   container.ID,
+			DockerName: getContainerName(&container),
+			Name:       getContainerName(&container),
+			Ports:      convertPorts(container.Ports),
+			Networks:   convertNetworks(container.NetworkSettings),
+			Volumes:    convertVolumes(container.Mounts),
+		})
+	}
+	return response
+}
+
+// getContainerInstanceARN returns the ARN of the mock container instance, in the region and account of the task ARNs:
+// arn:aws:ecs:us-west-2:111111111111:container-instance/<cluster>/<container instance ID>
+func getContainerInstanceARN() string {
+	split := strings.SplitN(getTaskARN(""), ":", 6)
+	if len(split) != 6 {
+		return ""
+	}
+	return fmt.Sprintf("%s:container-instance/%s/%s", strings.Join(split[:5], ":"), getClusterName(), containerInstanceID)
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetIntrospectionTasks(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName, containerID).WithComposeProject(projectName).WithNetwork("bridge", ipAddress).Get()
+	container2 := testingutils.BaseDockerContainer("other", "other-id").WithComposeProject(projectName).WithNetwork("bridge", ipAddress).Get()
+	container3 := testingutils.BaseDockerContainer("another", "another-id").WithNetwork("bridge", ipAddress).Get()
+
+	actual := GetIntrospectionTasks([]types.Container{container1, container3, container2})
+	assert.Len(t, actual.Tasks, 2, "Expected a task for the compose project and one for the other containers")
+	assert.Equal(t, getTaskARN(projectName), actual.Tasks[0].Arn, "Expected the task ARN of the compose project")
+	assert.Len(t, actual.Tasks[0].Containers, 2, "Expected the containers in the compose project")
+	assert.Equal(t, containerName, actual.Tasks[0].Containers[0].Name, "Expected container name to match")
+	assert.Equal(t, []string{ipAddress}, actual.Tasks[0].Containers[0].Networks[0].IPv4Addresses, "Expected IP address to match")
+	assert.Equal(t, config.DefaultTaskARN, actual.Tasks[1].Arn, "Expected the default task ARN for containers outside of Compose")
+	assert.Equal(t, "another-id", actual.Tasks[1].Containers[0].DockerID, "Expected docker ID to match")
+	assert.Equal(t, config.DefaultTDFamily, actual.Tasks[1].Family, "Expected family to match")
+
+	assert.Empty(t, GetIntrospectionTasks(nil).Tasks, "Expected no tasks without containers")
+}
+
+func TestGetIntrospectionMetadata(t *testing.T) {
+	os.Setenv(config.ClusterARNVar, cluster)
+	os.Setenv(config.AccountIDVar, "222222222222")
+	defer os.Clearenv()
+
+	actual := GetIntrospectionMetadata()
+	assert.Equal(t, cluster, actual.Cluster, "Expected cluster to match")
+	assert.Equal(t, "arn:aws:ecs:us-west-2:222222222222:container-instance/meow-cluster/4b6d45ea-a4b4-4269-9d04-3af6ddfdc597", *actual.ContainerInstanceArn, "Expected container instance ARN to match")
+	assert.Contains(t, actual.Version, "Amazon ECS Agent - v", "Expected the agent version")
+}
@@ -15,6 +15,8 @@
 package metadata
 
 import (
+	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -25,11 +27,23 @@ import (
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
 	"github.com/docker/docker/api/types"
+	"github.com/pborman/uuid"
 )
 
+const (
+	// ComposeProjectLabel is the label which Docker Compose sets to the name of the container's project
+	ComposeProjectLabel = "com.docker.compose.project"
+	// ComposeServiceLabel is the label which Docker Compose sets to the name of the container's service
+	ComposeServiceLabel = "com.docker.compose.service"
+)
+
+// taskIDNamespace is used to derive the task ID for each Compose project, so that a project's
+// task ARN is the same each time Local Endpoints runs
+var taskIDNamespace = uuid.Parse("0c6bc3d5-8f5e-4d0c-9c37-1a8a44d7a2f1")
+
 // GetTaskMetadata returns the task metadata for the given containers
 func GetTaskMetadata(dockerContainers []types.Container, containerInstanceTags, taskTags map[string]string) *v2.TaskResponse {
-	response := newLocalTaskResponse(containerInstanceTags, taskTags)
+	response := newLocalTaskResponse(GetTaskARN(dockerContainers), containerInstanceTags, taskTags)
 	ecsContainers := response.Containers
 	for _, container := range dockerContainers {
 		ecsContainer := GetContainerMetadata(&container)
@@ -56,6 +70,7 @@ func GetContainerMetadata(dockerContainer *types.Container) *v2.ContainerRespons
 	response.StartedAt = response.CreatedAt
 	response.Networks = convertNetworks(dockerContainer.NetworkSettings)
 	response.Volumes = convertVolumes(dockerContainer.Mounts)
+	response.Health = getHealthFromStatus(dockerContainer.Status)
 
 	return response
 }
@@ -68,10 +83,10 @@ func newLocalContainerResponse() *v2.ContainerResponse {
 	}
 }
 
-func newLocalTaskResponse(containerInstanceTags, taskTags map[string]string) *v2.TaskResponse {
+func newLocalTaskResponse(taskARN string, containerInstanceTags, taskTags map[string]string) *v2.TaskResponse {
 	return &v2.TaskResponse{
 		Cluster:               utils.GetValue(config.DefaultClusterName, config.ClusterARNVar),
-		TaskARN:               utils.GetValue(config.DefaultTaskARN, config.TaskARNVar),
+		TaskARN:               taskARN,
 		Family:                utils.GetValue(config.DefaultTDFamily, config.TDFamilyVar),
 		Revision:              utils.GetValue(config.DefaultTDRevision, config.TDRevisionVar),
 		DesiredStatus:         ecs.DesiredStatusRunning,
@@ -81,6 +96,54 @@ func newLocalTaskResponse(containerInstanceTags, taskTags map[string]string) *v2
 	}
 }
 
+// GetTaskARN returns the ARN of the local 'task' which is made up of the containers
+func GetTaskARN(dockerContainers []types.Container) string {
+	return getTaskARN(getComposeProject(dockerContainers))
+}
+
+// getTaskARN returns the ARN of the local 'task' for a Compose project. Each project is a separate task,
+// with the task ID derived from the project name, in the cluster and account which are set for the metadata,
+// and in the region of Local Endpoints and its partition, such as aws-us-gov. TASK_ARN overrides the ARN for all tasks.
+func getTaskARN(projectName string) string {
+	if taskARN := os.Getenv(config.TaskARNVar); taskARN != "" {
+		return taskARN
+	}
+	// arn:aws:ecs:us-west-2:111111111111:task/ecs-local-cluster/37e873f6-37b4-42a7-af47-eac7275c6152
+	split := strings.SplitN(config.DefaultTaskARN, ":", 6)
+	taskID := split[5][strings.LastIndex(split[5], "/")+1:]
+	if projectName != "" {
+		taskID = uuid.NewSHA1(taskIDNamespace, []byte(projectName)).String()
+	}
+	if region := utils.GetRegion(); region != "" {
+		split[1] = utils.GetPartition(region)
+		split[3] = region
+	}
+	split[4] = utils.GetValue(split[4], config.AccountIDVar)
+	split[5] = fmt.Sprintf("task/%s/%s", getClusterName(), taskID)
+	return strings.Join(split, ":")
+}
+
+// getClusterName returns the name of the cluster in CLUSTER_ARN, which can be a name or an ARN
+func getClusterName() string {
+	cluster := utils.GetValue(config.DefaultClusterName, config.ClusterARNVar)
+	return cluster[strings.LastIndex(cluster, "/")+1:]
+}
+
+// getComposeProject returns the Compose project which all of the containers are in,
+// or an empty string if they are not all in the same project
+func getComposeProject(dockerContainers []types.Container) string {
+	if len(dockerContainers) == 0 {
+		return ""
+	}
+	projectName := dockerContainers[0].Labels[ComposeProjectLabel]
+	for _, container := range dockerContainers[1:] {
+		if container.Labels[ComposeProjectLabel] != projectName {
+			return ""
+		}
+	}
+	return projectName
+}
+
 func convertVolumes(mounts []types.MountPoint) []v1.VolumeResponse {
 	var ecsVolumes []v1.VolumeResponse
 	for _, mount := range mounts {
@@ -94,11 +157,19 @@ func convertVolumes(mounts []types.MountPoint) []v1.VolumeResponse {
 }
 
 func convertNetworks(dockerNetworkSettings *types.SummaryNetworkSettings) []containermetadata.Network {
+	if dockerNetworkSettings == nil {
+		return nil
+	}
+
 	var ecsNetworks []containermetadata.Network
 	for netMode, netSettings := range dockerNetworkSettings.Networks {
 		ecsNet := containermetadata.Network{
 			NetworkMode: netMode,
 		}
+		if netSettings == nil {
+			ecsNetworks = append(ecsNetworks, ecsNet)
+			continue
+		}
 		if netSettings.IPAddress != "" {
 			ecsNet.IPv4Addresses = []string{
 				netSettings.IPAddress,
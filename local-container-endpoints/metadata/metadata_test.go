@@ -22,6 +22,8 @@ import (
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,7 +54,7 @@ func TestnewLocalTaskResponseWithEnvVars(t *testing.T) {
 	os.Setenv(config.TDRevisionVar, revision)
 	defer os.Clearenv()
 
-	actual := newLocalTaskResponse(nil, nil)
+	actual := newLocalTaskResponse(getTaskARN(projectName), nil, nil)
 	assert.Equal(t, expected, actual, "Expected TaskResponse to match")
 }
 
@@ -78,7 +80,7 @@ func TestGetTaskMetadata(t *testing.T) {
 		TaskTags:              taskTags,
 		ContainerInstanceTags: containerInstanceTags,
 		Cluster:               config.DefaultClusterName,
-		TaskARN:               config.DefaultTaskARN,
+		TaskARN:               getTaskARN(projectName),
 		Family:                config.DefaultTDFamily,
 		Revision:              config.DefaultTDRevision,
 		DesiredStatus:         ecs.DesiredStatusRunning,
@@ -91,3 +93,234 @@ func TestGetTaskMetadata(t *testing.T) {
 	actual := GetTaskMetadata([]types.Container{dockerContainer}, containerInstanceTags, taskTags)
 	assert.Equal(t, expected, actual, "Expected task response to match")
 }
+
+func TestGetTaskARN(t *testing.T) {
+	projectARN := getTaskARN(projectName)
+	assert.Equal(t, "arn:aws:ecs:us-west-2:111111111111:task/ecs-local-cluster/404a599e-0923-5a86-9f81-8681ac776dde", projectARN, "Expected task ARN to be derived from the project name")
+	assert.Equal(t, projectARN, getTaskARN(projectName), "Expected task ARN to be stable for a project")
+	assert.NotEqual(t, projectARN, getTaskARN("other-project"), "Expected each project to have its own task ARN")
+	assert.Equal(t, config.DefaultTaskARN, getTaskARN(""), "Expected default task ARN for containers outside of Compose")
+
+	os.Setenv(config.TaskARNVar, taskARN)
+	defer os.Unsetenv(config.TaskARNVar)
+	assert.Equal(t, taskARN, getTaskARN(projectName), "Expected TASK_ARN to override the task ARN")
+}
+
+func TestGetTaskARNWithMetadataSettings(t *testing.T) {
+	os.Setenv(config.ClusterARNVar, "arn:aws:ecs:us-west-2:222222222222:cluster/"+cluster)
+	os.Setenv(config.AccountIDVar, "222222222222")
+	defer os.Clearenv()
+
+	assert.Equal(t, "arn:aws:ecs:us-west-2:222222222222:task/meow-cluster/404a599e-0923-5a86-9f81-8681ac776dde", getTaskARN(projectName), "Expected task ARN in the cluster and account")
+	assert.Equal(t, "arn:aws:ecs:us-west-2:222222222222:task/meow-cluster/37e873f6-37b4-42a7-af47-eac7275c6152", getTaskARN(""), "Expected default task ID in the cluster and account")
+}
+
+func TestGetTaskARNInRegion(t *testing.T) {
+	defer os.Unsetenv("AWS_REGION")
+
+	os.Setenv("AWS_REGION", "us-gov-west-1")
+	assert.Equal(t, "arn:aws-us-gov:ecs:us-gov-west-1:111111111111:task/ecs-local-cluster/404a599e-0923-5a86-9f81-8681ac776dde", getTaskARN(projectName), "Expected task ARN in the GovCloud partition")
+	assert.Equal(t, "arn:aws-us-gov:ecs:us-gov-west-1:111111111111:container-instance/ecs-local-cluster/"+containerInstanceID, getContainerInstanceARN(), "Expected container instance ARN in the GovCloud partition")
+
+	os.Setenv("AWS_REGION", "cn-north-1")
+	assert.Equal(t, "arn:aws-cn:ecs:cn-north-1:111111111111:task/ecs-local-cluster/404a599e-0923-5a86-9f81-8681ac776dde", getTaskARN(projectName), "Expected task ARN in the China partition")
+}
+
+func TestGetV4TaskMetadataServiceName(t *testing.T) {
+	dockerContainer := testingutils.BaseDockerContainer(containerName, containerID).WithComposeProject(projectName).WithNetwork("bridge", ipAddress).Get()
+
+	actual := GetV4TaskMetadata([]types.Container{dockerContainer}, nil, nil, nil, config.DefaultEphemeralStorageSize)
+	assert.Empty(t, actual.ServiceName, "Expected no service name by default")
+
+	os.Setenv(config.ServiceNameMetadataVar, "meow-service")
+	defer os.Unsetenv(config.ServiceNameMetadataVar)
+	actual = GetV4TaskMetadata([]types.Container{dockerContainer}, nil, nil, nil, config.DefaultEphemeralStorageSize)
+	assert.Equal(t, "meow-service", actual.ServiceName, "Expected service name to match")
+}
+
+func TestGetComposeProject(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName, containerID).WithComposeProject(projectName).Get()
+	container2 := testingutils.BaseDockerContainer("other", "other-id").WithComposeProject(projectName).Get()
+	container3 := testingutils.BaseDockerContainer("another", "another-id").WithComposeProject("other-project").Get()
+
+	assert.Equal(t, projectName, getComposeProject([]types.Container{container1, container2}), "Expected the project of the containers")
+	assert.Equal(t, "", getComposeProject([]types.Container{container1, container3}), "Expected no project for containers in different projects")
+	assert.Equal(t, "", getComposeProject(nil), "Expected no project for no containers")
+}
+
+func TestGetV4ContainerMetadata(t *testing.T) {
+	dockerContainer := testingutils.BaseDockerContainer(containerName, containerID).
+		WithComposeProject(projectName).
+		WithNetwork("bridge", ipAddress).
+		Get()
+	dockerContainer.NetworkSettings.Networks["bridge"].IPPrefixLen = 16
+	dockerContainer.NetworkSettings.Networks["bridge"].MacAddress = "02:42:ac:11:00:02"
+
+	containerDetails := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			HostConfig: &container.HostConfig{
+				LogConfig: container.LogConfig{
+					Type: "awslogs",
+					Config: map[string]string{
+						"awslogs-group": "cats",
+					},
+				},
+			},
+		},
+	}
+
+	expectedContainer := testingutils.BaseMetadataContainer(containerName, containerID).
+		WithComposeProject(projectName).
+		WithNetwork("bridge", ipAddress).
+		Get()
+
+	actual := GetV4ContainerMetadata(&dockerContainer, containerDetails)
+	assert.Equal(t, expectedContainer, *actual.ContainerResponse, "Expected V2 portion of the container response to match")
+	assert.Equal(t, "arn:aws:ecs:us-west-2:111111111111:container/"+containerID, actual.ContainerARN, "Expected container ARN to match")
+	assert.Equal(t, "awslogs", actual.LogDriver, "Expected log driver to match")
+	assert.Equal(t, map[string]string{"awslogs-group": "cats"}, actual.LogOptions, "Expected log options to match")
+	assert.Len(t, actual.Networks, 1, "Expected one network")
+	assert.Equal(t, 0, *actual.Networks[0].AttachmentIndex, "Expected attachment index to match")
+	assert.Equal(t, []string{ipAddress}, actual.Networks[0].IPv4Addresses, "Expected IP address to match")
+	assert.Equal(t, "127.0.0.0/16", actual.Networks[0].IPv4SubnetCIDRBlock, "Expected subnet CIDR to match")
+	assert.Equal(t, "02:42:ac:11:00:02", actual.Networks[0].MACAddress, "Expected MAC address to match")
+}
+
+func TestGetV4ContainerMetadataWithoutDetails(t *testing.T) {
+	dockerContainer := testingutils.BaseDockerContainer(containerName, containerID).
+		WithNetwork("bridge", ipAddress).
+		Get()
+
+	actual := GetV4ContainerMetadata(&dockerContainer, nil)
+	assert.Empty(t, actual.LogDriver, "Expected log driver to be empty")
+	assert.Empty(t, actual.LogOptions, "Expected log options to be empty")
+	assert.Empty(t, actual.Networks[0].IPv4SubnetCIDRBlock, "Expected subnet CIDR to be empty")
+}
+
+func TestGetV4ContainerMetadataNetworksFromDetails(t *testing.T) {
+	dockerContainer := testingutils.BaseDockerContainer(containerName, containerID).
+		WithNetwork("bridge", "").
+		Get()
+
+	containerDetails := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"bridge": &network.EndpointSettings{
+					IPAddress:   ipAddress,
+					IPPrefixLen: 16,
+					MacAddress:  "02:42:ac:11:00:02",
+				},
+				"app-network": &network.EndpointSettings{
+					IPAddress: "172.18.0.5",
+				},
+			},
+		},
+	}
+
+	actual := GetV4ContainerMetadata(&dockerContainer, containerDetails)
+	assert.Len(t, actual.Networks, 2, "Expected the networks from docker inspect")
+	assert.Equal(t, "app-network", actual.Networks[0].NetworkMode, "Expected networks to be sorted by name")
+	assert.Equal(t, []string{"172.18.0.5"}, actual.Networks[0].IPv4Addresses, "Expected IP address to match")
+	assert.Equal(t, "bridge", actual.Networks[1].NetworkMode, "Expected network mode to match")
+	assert.Equal(t, []string{ipAddress}, actual.Networks[1].IPv4Addresses, "Expected IP address from docker inspect")
+	assert.Equal(t, "02:42:ac:11:00:02", actual.Networks[1].MACAddress, "Expected MAC address from docker inspect")
+}
+
+func TestGetContainerMetadataWithoutNetworkSettings(t *testing.T) {
+	dockerContainer := testingutils.BaseDockerContainer(containerName, containerID).Get()
+	dockerContainer.NetworkSettings = nil
+
+	assert.Empty(t, GetContainerMetadata(&dockerContainer).Networks, "Expected no networks")
+
+	dockerContainer.NetworkSettings = &types.SummaryNetworkSettings{
+		Networks: map[string]*network.EndpointSettings{
+			"host": nil,
+		},
+	}
+	actual := GetContainerMetadata(&dockerContainer)
+	assert.Len(t, actual.Networks, 1, "Expected the network without settings")
+	assert.Equal(t, "host", actual.Networks[0].NetworkMode, "Expected network mode to match")
+}
+
+func TestGetV4TaskMetadataLimits(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName, containerID).WithNetwork("bridge", ipAddress).Get()
+	container2 := testingutils.BaseDockerContainer("other", "other-id").WithNetwork("bridge", ipAddress).Get()
+	containerDetails := map[string]*types.ContainerJSON{
+		containerID: &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				HostConfig: &container.HostConfig{
+					Resources: container.Resources{
+						NanoCPUs: 500000000,
+						Memory:   512 * 1024 * 1024,
+					},
+				},
+			},
+		},
+		"other-id": &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				HostConfig: &container.HostConfig{
+					Resources: container.Resources{
+						CPUShares: 256,
+						Memory:    256 * 1024 * 1024,
+					},
+				},
+			},
+		},
+	}
+
+	actual := GetV4TaskMetadata([]types.Container{container1, container2}, containerDetails, nil, nil, config.DefaultEphemeralStorageSize)
+	assert.Equal(t, 512.0, *actual.Containers[0].Limits.CPU, "Expected CPU units from the number of CPUs")
+	assert.Equal(t, int64(512), *actual.Containers[0].Limits.Memory, "Expected memory in MiB")
+	assert.Equal(t, 256.0, *actual.Containers[1].Limits.CPU, "Expected CPU units from the CPU shares")
+	assert.Equal(t, int64(256), *actual.Containers[1].Limits.Memory, "Expected memory in MiB")
+	assert.Equal(t, 0.75, *actual.Limits.CPU, "Expected task vCPUs to be the total of the containers")
+	assert.Equal(t, int64(768), *actual.Limits.Memory, "Expected task memory to be the total of the containers")
+}
+
+func TestGetV4TaskMetadataWithoutLimits(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName, containerID).WithNetwork("bridge", ipAddress).Get()
+	container2 := testingutils.BaseDockerContainer("other", "other-id").WithNetwork("bridge", ipAddress).Get()
+	containerDetails := map[string]*types.ContainerJSON{
+		containerID: &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				HostConfig: &container.HostConfig{
+					Resources: container.Resources{
+						Memory: 512 * 1024 * 1024,
+					},
+				},
+			},
+		},
+	}
+
+	actual := GetV4TaskMetadata([]types.Container{container1, container2}, containerDetails, nil, nil, config.DefaultEphemeralStorageSize)
+	assert.Nil(t, actual.Containers[0].Limits.CPU, "Expected no CPU limit")
+	assert.Equal(t, int64(512), *actual.Containers[0].Limits.Memory, "Expected memory in MiB")
+	assert.Nil(t, actual.Limits, "Expected no task limits when a container is not limited")
+}
+
+func TestGetV4TaskMetadataEphemeralStorage(t *testing.T) {
+	container1 := testingutils.BaseDockerContainer(containerName, containerID).WithNetwork("bridge", ipAddress).Get()
+	container2 := testingutils.BaseDockerContainer("other", "other-id").WithNetwork("bridge", ipAddress).Get()
+	container3 := testingutils.BaseDockerContainer("no-size", "no-size-id").WithNetwork("bridge", ipAddress).Get()
+	size1 := int64(200 * 1024 * 1024)
+	size2 := int64(61*1024*1024 + 1000)
+	containerDetails := map[string]*types.ContainerJSON{
+		containerID: &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				SizeRw: &size1,
+			},
+		},
+		"other-id": &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				SizeRw: &size2,
+			},
+		},
+	}
+
+	actual := GetV4TaskMetadata([]types.Container{container1, container2, container3}, containerDetails, nil, nil, 30)
+	assert.Equal(t, &EphemeralStorageMetrics{Utilized: 261, Reserved: 30720}, actual.EphemeralStorageMetrics, "Expected the total of the writable layers in MiB")
+
+	actual = GetV4TaskMetadata([]types.Container{container3}, containerDetails, nil, nil, 30)
+	assert.Nil(t, actual.EphemeralStorageMetrics, "Expected no ephemeral storage metrics without the size of any container")
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"github.com/docker/docker/api/types"
+)
+
+// NetworkRateStats is the rate of network traffic for a container
+type NetworkRateStats struct {
+	RxBytesPerSecond float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSecond float64 `json:"tx_bytes_per_sec"`
+}
+
+// V4StatsResponse is the schema for the V4 stats response, which adds network rates to the docker stats
+type V4StatsResponse struct {
+	*types.StatsJSON
+	NetworkRateStats *NetworkRateStats `json:"network_rate_stats,omitempty"`
+}
+
+// GetV4Stats creates a V4 stats response from the current docker stats sample for a container
+// previous is the last sample obtained for the container, and is used to compute network rates; it can be nil
+func GetV4Stats(current, previous *types.StatsJSON) *V4StatsResponse {
+	return &V4StatsResponse{
+		StatsJSON:        current,
+		NetworkRateStats: getNetworkRateStats(current, previous),
+	}
+}
+
+func getNetworkRateStats(current, previous *types.StatsJSON) *NetworkRateStats {
+	rateStats := &NetworkRateStats{}
+	if previous == nil {
+		return rateStats
+	}
+
+	seconds := current.Read.Sub(previous.Read).Seconds()
+	if seconds <= 0 {
+		return rateStats
+	}
+
+	currentRx, currentTx := sumNetworkBytes(current.Networks)
+	previousRx, previousTx := sumNetworkBytes(previous.Networks)
+
+	// counters are reset if the container restarts
+	if currentRx >= previousRx {
+		rateStats.RxBytesPerSecond = float64(currentRx-previousRx) / seconds
+	}
+	if currentTx >= previousTx {
+		rateStats.TxBytesPerSecond = float64(currentTx-previousTx) / seconds
+	}
+	return rateStats
+}
+
+func sumNetworkBytes(networks map[string]types.NetworkStats) (rxBytes uint64, txBytes uint64) {
+	for _, network := range networks {
+		rxBytes += network.RxBytes
+		txBytes += network.TxBytes
+	}
+	return rxBytes, txBytes
+}
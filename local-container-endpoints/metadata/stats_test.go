@@ -0,0 +1,84 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetV4StatsNetworkRates(t *testing.T) {
+	readTime := time.Now()
+	previous := &types.StatsJSON{
+		Stats: types.Stats{
+			Read: readTime,
+		},
+		Networks: map[string]types.NetworkStats{
+			"eth0": types.NetworkStats{RxBytes: 1000, TxBytes: 500},
+			"eth1": types.NetworkStats{RxBytes: 1000, TxBytes: 500},
+		},
+	}
+	current := &types.StatsJSON{
+		Stats: types.Stats{
+			Read: readTime.Add(2 * time.Second),
+		},
+		Networks: map[string]types.NetworkStats{
+			"eth0": types.NetworkStats{RxBytes: 3000, TxBytes: 1500},
+			"eth1": types.NetworkStats{RxBytes: 1000, TxBytes: 500},
+		},
+	}
+
+	actual := GetV4Stats(current, previous)
+	assert.Equal(t, current, actual.StatsJSON, "Expected docker stats to be included in the response")
+	assert.Equal(t, float64(1000), actual.NetworkRateStats.RxBytesPerSecond, "Expected rx rate to match")
+	assert.Equal(t, float64(500), actual.NetworkRateStats.TxBytesPerSecond, "Expected tx rate to match")
+}
+
+func TestGetV4StatsNoPreviousSample(t *testing.T) {
+	current := &types.StatsJSON{
+		Networks: map[string]types.NetworkStats{
+			"eth0": types.NetworkStats{RxBytes: 3000, TxBytes: 1500},
+		},
+	}
+
+	actual := GetV4Stats(current, nil)
+	assert.Equal(t, &NetworkRateStats{}, actual.NetworkRateStats, "Expected rates to be zero")
+}
+
+func TestGetV4StatsCountersReset(t *testing.T) {
+	readTime := time.Now()
+	previous := &types.StatsJSON{
+		Stats: types.Stats{
+			Read: readTime,
+		},
+		Networks: map[string]types.NetworkStats{
+			"eth0": types.NetworkStats{RxBytes: 3000, TxBytes: 1500},
+		},
+	}
+	current := &types.StatsJSON{
+		Stats: types.Stats{
+			Read: readTime.Add(time.Second),
+		},
+		Networks: map[string]types.NetworkStats{
+			"eth0": types.NetworkStats{RxBytes: 100, TxBytes: 1600},
+		},
+	}
+
+	actual := GetV4Stats(current, previous)
+	assert.Equal(t, float64(0), actual.NetworkRateStats.RxBytesPerSecond, "Expected rx rate to be zero after a reset")
+	assert.Equal(t, float64(100), actual.NetworkRateStats.TxBytesPerSecond, "Expected tx rate to match")
+}
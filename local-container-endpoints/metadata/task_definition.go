@@ -0,0 +1,192 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v2"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/docker/docker/api/types"
+)
+
+// ApplyTaskDefinition sets the family, revision, and container values of task metadata from the task definition
+// which the local 'task' runs; taskDefinition can be nil
+func ApplyTaskDefinition(taskDefinition *ecs.TaskDefinition, response *v2.TaskResponse) {
+	if taskDefinition == nil {
+		return
+	}
+	applyTaskFamily(taskDefinition, response)
+	for i := range response.Containers {
+		ApplyContainerDefinition(taskDefinition, &response.Containers[i])
+	}
+}
+
+// ApplyV4TaskDefinition sets the values of V4 task metadata from the task definition; taskDefinition can be nil.
+// The task limits are the task size, or else the totals of the container limits.
+func ApplyV4TaskDefinition(taskDefinition *ecs.TaskDefinition, response *V4TaskResponse) {
+	if taskDefinition == nil {
+		return
+	}
+	applyTaskFamily(taskDefinition, response.TaskResponse)
+	for i := range response.Containers {
+		ApplyV4ContainerDefinition(taskDefinition, &response.Containers[i])
+	}
+	response.Limits = getTaskLimits(response.Containers)
+
+	cpu, hasCPU := parseTaskCPU(aws.StringValue(taskDefinition.Cpu))
+	memory, hasMemory := parseTaskMemory(aws.StringValue(taskDefinition.Memory))
+	if !hasCPU && !hasMemory {
+		return
+	}
+	if response.Limits == nil {
+		response.Limits = &v2.LimitsResponse{}
+	}
+	if hasCPU {
+		response.Limits.CPU = &cpu
+	}
+	if hasMemory {
+		response.Limits.Memory = &memory
+	}
+}
+
+// ApplyContainerDefinition sets the name, limits, and ports of container metadata from the definition of the container
+// in the task definition, and returns the definition; it returns nil if the container is not in the task definition
+func ApplyContainerDefinition(taskDefinition *ecs.TaskDefinition, response *v2.ContainerResponse) *ecs.ContainerDefinition {
+	containerDefinition := findContainerDefinition(taskDefinition, response)
+	if containerDefinition == nil {
+		return nil
+	}
+	response.Name = aws.StringValue(containerDefinition.Name)
+	if cpu := aws.Int64Value(containerDefinition.Cpu); cpu > 0 {
+		cpuUnits := float64(cpu)
+		response.Limits.CPU = &cpuUnits
+	}
+	if memory := aws.Int64Value(containerDefinition.Memory); memory > 0 {
+		response.Limits.Memory = &memory
+	}
+	// the ports which are published by docker are the ones which can be reached, so they take precedence
+	if len(response.Ports) == 0 {
+		response.Ports = convertPortMappings(containerDefinition.PortMappings)
+	}
+	return containerDefinition
+}
+
+// ApplyV4ContainerDefinition sets the values of V4 container metadata from the definition of the container,
+// including its log configuration
+func ApplyV4ContainerDefinition(taskDefinition *ecs.TaskDefinition, response *V4ContainerResponse) {
+	if taskDefinition == nil {
+		return
+	}
+	containerDefinition := ApplyContainerDefinition(taskDefinition, response.ContainerResponse)
+	if containerDefinition == nil || containerDefinition.LogConfiguration == nil {
+		return
+	}
+	response.LogDriver = aws.StringValue(containerDefinition.LogConfiguration.LogDriver)
+	response.LogOptions = aws.StringValueMap(containerDefinition.LogConfiguration.Options)
+}
+
+// ApplyV4FirelensConfiguration sets the FireLens configuration of V4 container metadata, if the container is
+// the log router of the task definition; firelensConfigurations are keyed by the name of the container definition
+func ApplyV4FirelensConfiguration(taskDefinition *ecs.TaskDefinition, firelensConfigurations map[string]*FirelensConfiguration, response *V4ContainerResponse) {
+	containerDefinition := findContainerDefinition(taskDefinition, response.ContainerResponse)
+	if containerDefinition == nil {
+		return
+	}
+	response.FirelensConfiguration = firelensConfigurations[aws.StringValue(containerDefinition.Name)]
+}
+
+func applyTaskFamily(taskDefinition *ecs.TaskDefinition, response *v2.TaskResponse) {
+	if family := aws.StringValue(taskDefinition.Family); family != "" {
+		response.Family = family
+	}
+	if revision := aws.Int64Value(taskDefinition.Revision); revision > 0 {
+		response.Revision = strconv.FormatInt(revision, 10)
+	}
+}
+
+// FindContainerDefinition returns the container definition of a container, or nil if there is none
+func FindContainerDefinition(taskDefinition *ecs.TaskDefinition, dockerContainer *types.Container) *ecs.ContainerDefinition {
+	return findContainerDefinition(taskDefinition, GetContainerMetadata(dockerContainer))
+}
+
+// findContainerDefinition returns the container definition which is named after the container's Compose service,
+// or else after the container
+func findContainerDefinition(taskDefinition *ecs.TaskDefinition, response *v2.ContainerResponse) *ecs.ContainerDefinition {
+	if taskDefinition == nil {
+		return nil
+	}
+	names := []string{response.Labels[ComposeServiceLabel], response.Name}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		for _, containerDefinition := range taskDefinition.ContainerDefinitions {
+			if containerDefinition != nil && aws.StringValue(containerDefinition.Name) == name {
+				return containerDefinition
+			}
+		}
+	}
+	return nil
+}
+
+func convertPortMappings(portMappings []*ecs.PortMapping) []v1.PortResponse {
+	var ecsPorts []v1.PortResponse
+	for _, portMapping := range portMappings {
+		if portMapping == nil {
+			continue
+		}
+		protocol := aws.StringValue(portMapping.Protocol)
+		if protocol == "" {
+			protocol = ecs.TransportProtocolTcp
+		}
+		ecsPorts = append(ecsPorts, v1.PortResponse{
+			ContainerPort: uint16(aws.Int64Value(portMapping.ContainerPort)),
+			HostPort:      uint16(aws.Int64Value(portMapping.HostPort)),
+			Protocol:      protocol,
+		})
+	}
+	return ecsPorts
+}
+
+// parseTaskCPU returns the vCPUs of a task size, which can be given in CPU units, such as 256, or in vCPUs, such as 0.25 vCPU
+func parseTaskCPU(cpu string) (float64, bool) {
+	cpu = strings.TrimSpace(strings.ToLower(cpu))
+	if cpu == "" {
+		return 0, false
+	}
+	if strings.HasSuffix(cpu, "vcpu") {
+		vCPUs, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(cpu, "vcpu")), 64)
+		return vCPUs, err == nil && vCPUs > 0
+	}
+	units, err := strconv.ParseFloat(cpu, 64)
+	return units / cpuUnitsPerCPU, err == nil && units > 0
+}
+
+// parseTaskMemory returns the MiB of a task size, which can be given in MiB, such as 512, or in GB, such as 1 GB
+func parseTaskMemory(memory string) (int64, bool) {
+	memory = strings.TrimSpace(strings.ToLower(memory))
+	if memory == "" {
+		return 0, false
+	}
+	if strings.HasSuffix(memory, "gb") {
+		gb, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(memory, "gb")), 64)
+		return int64(gb * 1024), err == nil && gb > 0
+	}
+	mib, err := strconv.ParseInt(memory, 10, 64)
+	return mib, err == nil && mib > 0
+}
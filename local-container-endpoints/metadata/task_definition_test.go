@@ -0,0 +1,173 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/testingutils"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTaskDefinition() *ecs.TaskDefinition {
+	return &ecs.TaskDefinition{
+		Family:   aws.String("my-app"),
+		Revision: aws.Int64(7),
+		Cpu:      aws.String("512"),
+		Memory:   aws.String("1 GB"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				// named after the Compose service of the test containers
+				Name:   aws.String("ecs-local"),
+				Cpu:    aws.Int64(256),
+				Memory: aws.Int64(512),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options: map[string]*string{
+						"awslogs-group": aws.String("/ecs/my-app"),
+					},
+				},
+			},
+			{
+				Name: aws.String("sidecar"),
+				PortMappings: []*ecs.PortMapping{
+					{
+						ContainerPort: aws.Int64(8080),
+						HostPort:      aws.Int64(8080),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestApplyV4TaskDefinition(t *testing.T) {
+	app := testingutils.BaseDockerContainer("project_ecs-local_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject(projectName).Get()
+	sidecar := testingutils.BaseDockerContainer("sidecar", "sidecar-id").WithNetwork("bridge", ipAddress).Get()
+	sidecar.Ports = nil
+	other := testingutils.BaseDockerContainer("other", "other-id").WithNetwork("bridge", ipAddress).Get()
+
+	response := GetV4TaskMetadata([]types.Container{app, sidecar, other}, nil, nil, nil, 20)
+	ApplyV4TaskDefinition(newTestTaskDefinition(), response)
+
+	assert.Equal(t, "my-app", response.Family, "Expected the family of the task definition")
+	assert.Equal(t, "7", response.Revision, "Expected the revision of the task definition")
+	assert.Equal(t, 0.5, *response.Limits.CPU, "Expected the task CPU in vCPUs")
+	assert.Equal(t, int64(1024), *response.Limits.Memory, "Expected the task memory in MiB")
+
+	appResponse := response.Containers[0]
+	assert.Equal(t, "ecs-local", appResponse.Name, "Expected the name of the container definition for the Compose service")
+	assert.Equal(t, "project_ecs-local_1", appResponse.DockerName, "Expected the docker name to be unchanged")
+	assert.Equal(t, 256.0, *appResponse.Limits.CPU, "Expected the CPU of the container definition")
+	assert.Equal(t, int64(512), *appResponse.Limits.Memory, "Expected the memory of the container definition")
+	assert.Equal(t, "awslogs", appResponse.LogDriver, "Expected the log driver of the container definition")
+	assert.Equal(t, map[string]string{"awslogs-group": "/ecs/my-app"}, appResponse.LogOptions, "Expected the log options of the container definition")
+
+	sidecarResponse := response.Containers[1]
+	assert.Equal(t, "sidecar", sidecarResponse.Name, "Expected the name of the container definition")
+	assert.Equal(t, []v1.PortResponse{
+		{
+			ContainerPort: 8080,
+			HostPort:      8080,
+			Protocol:      "tcp",
+		},
+	}, sidecarResponse.Ports, "Expected the port mappings of the container definition")
+
+	otherResponse := response.Containers[2]
+	assert.Equal(t, "other", otherResponse.Name, "Expected the name of a container which is not in the task definition to be unchanged")
+	assert.Len(t, otherResponse.Ports, 1, "Expected the ports from docker")
+}
+
+func TestApplyV4TaskDefinitionWithoutTaskSize(t *testing.T) {
+	app := testingutils.BaseDockerContainer("project_ecs-local_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject(projectName).Get()
+	taskDefinition := newTestTaskDefinition()
+	taskDefinition.Cpu = nil
+	taskDefinition.Memory = nil
+
+	response := GetV4TaskMetadata([]types.Container{app}, nil, nil, nil, 20)
+	ApplyV4TaskDefinition(taskDefinition, response)
+	assert.Equal(t, 0.25, *response.Limits.CPU, "Expected the task CPU to be the total of the container definitions")
+	assert.Equal(t, int64(512), *response.Limits.Memory, "Expected the task memory to be the total of the container definitions")
+}
+
+func TestApplyV4FirelensConfiguration(t *testing.T) {
+	router := testingutils.BaseDockerContainer("project_log_router_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject(projectName).Get()
+	app := testingutils.BaseDockerContainer("app", "app-id").WithNetwork("bridge", ipAddress).Get()
+	taskDefinition := newTestTaskDefinition()
+	taskDefinition.ContainerDefinitions = append(taskDefinition.ContainerDefinitions, &ecs.ContainerDefinition{
+		Name: aws.String("app"),
+		LogConfiguration: &ecs.LogConfiguration{
+			LogDriver: aws.String("awsfirelens"),
+			Options: map[string]*string{
+				"Name": aws.String("cloudwatch"),
+			},
+		},
+	})
+	firelensConfigurations := map[string]*FirelensConfiguration{
+		// the container definition of the Compose service of the test containers
+		"ecs-local": {
+			Type: "fluentbit",
+		},
+	}
+
+	response := GetV4TaskMetadata([]types.Container{router, app}, nil, nil, nil, 20)
+	ApplyV4TaskDefinition(taskDefinition, response)
+	for i := range response.Containers {
+		ApplyV4FirelensConfiguration(taskDefinition, firelensConfigurations, &response.Containers[i])
+	}
+
+	assert.Equal(t, &FirelensConfiguration{Type: "fluentbit"}, response.Containers[0].FirelensConfiguration, "Expected the FireLens configuration of the log router")
+	assert.Nil(t, response.Containers[1].FirelensConfiguration, "Expected no FireLens configuration for the application")
+	assert.Equal(t, "awsfirelens", response.Containers[1].LogDriver, "Expected the application to log to FireLens")
+	assert.Equal(t, map[string]string{"Name": "cloudwatch"}, response.Containers[1].LogOptions, "Expected the options of the FireLens output")
+}
+
+func TestApplyTaskDefinitionNil(t *testing.T) {
+	app := testingutils.BaseDockerContainer("project_ecs-local_1", containerID).WithNetwork("bridge", ipAddress).WithComposeProject(projectName).Get()
+	expected := GetTaskMetadata([]types.Container{app}, nil, nil)
+	actual := GetTaskMetadata([]types.Container{app}, nil, nil)
+
+	ApplyTaskDefinition(nil, actual)
+	assert.Equal(t, expected, actual, "Expected the metadata to be unchanged without a task definition")
+}
+
+func TestParseTaskSize(t *testing.T) {
+	testCases := []struct {
+		cpu         string
+		expectedCPU float64
+		memory      string
+		expectedMiB int64
+	}{
+		{cpu: "256", expectedCPU: 0.25, memory: "512", expectedMiB: 512},
+		{cpu: "1 vCPU", expectedCPU: 1, memory: "2 GB", expectedMiB: 2048},
+		{cpu: "0.5vcpu", expectedCPU: 0.5, memory: "0.5GB", expectedMiB: 512},
+	}
+	for _, testCase := range testCases {
+		cpu, ok := parseTaskCPU(testCase.cpu)
+		assert.True(t, ok, "Expected CPU %s to be parsed", testCase.cpu)
+		assert.Equal(t, testCase.expectedCPU, cpu, "Expected vCPUs for %s", testCase.cpu)
+		memory, ok := parseTaskMemory(testCase.memory)
+		assert.True(t, ok, "Expected memory %s to be parsed", testCase.memory)
+		assert.Equal(t, testCase.expectedMiB, memory, "Expected MiB for %s", testCase.memory)
+	}
+
+	_, ok := parseTaskCPU("")
+	assert.False(t, ok, "Expected no CPU")
+	_, ok = parseTaskMemory("lots")
+	assert.False(t, ok, "Expected invalid memory")
+}
@@ -0,0 +1,252 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/containermetadata"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v2"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// cpuUnitsPerCPU is the number of ECS CPU units in one vCPU
+const cpuUnitsPerCPU = 1024
+
+const bytesPerMiB = 1024 * 1024
+
+// V4TaskResponse is the schema for the V4 task metadata response
+type V4TaskResponse struct {
+	*v2.TaskResponse
+	ServiceName             string                   `json:"ServiceName,omitempty"`
+	EphemeralStorageMetrics *EphemeralStorageMetrics `json:"EphemeralStorageMetrics,omitempty"`
+	Containers              []V4ContainerResponse    `json:"Containers,omitempty"`
+}
+
+// EphemeralStorageMetrics is the usage of the task's ephemeral storage in MiB, as reported by Fargate
+type EphemeralStorageMetrics struct {
+	Utilized int64 `json:"Utilized"`
+	Reserved int64 `json:"Reserved"`
+}
+
+// V4ContainerResponse is the schema for the V4 container metadata response
+type V4ContainerResponse struct {
+	*v2.ContainerResponse
+	ContainerARN string            `json:"ContainerARN,omitempty"`
+	LogDriver    string            `json:"LogDriver,omitempty"`
+	LogOptions   map[string]string `json:"LogOptions,omitempty"`
+	Networks     []V4Network       `json:"Networks,omitempty"`
+	// FirelensConfiguration is set for the log router container of a task which uses FireLens
+	FirelensConfiguration *FirelensConfiguration `json:"FirelensConfiguration,omitempty"`
+}
+
+// FirelensConfiguration is the FireLens configuration of a log router container, as in its container definition
+type FirelensConfiguration struct {
+	Type    string            `json:"Type"`
+	Options map[string]string `json:"Options,omitempty"`
+}
+
+// V4Network adds the network interface properties present in V4 metadata
+type V4Network struct {
+	containermetadata.Network
+	AttachmentIndex          *int   `json:"AttachmentIndex,omitempty"`
+	MACAddress               string `json:"MACAddress,omitempty"`
+	IPv4SubnetCIDRBlock      string `json:"IPv4SubnetCIDRBlock,omitempty"`
+	SubnetGatewayIPv4Address string `json:"SubnetGatewayIpv4Address,omitempty"`
+}
+
+// GetV4TaskMetadata returns the V4 task metadata for the given containers
+// containerDetails maps container IDs to their docker inspect output, which may be missing for any container
+// ephemeralStorageSize is the size in GiB of the task's ephemeral storage
+func GetV4TaskMetadata(dockerContainers []types.Container, containerDetails map[string]*types.ContainerJSON, containerInstanceTags, taskTags map[string]string, ephemeralStorageSize int64) *V4TaskResponse {
+	response := &V4TaskResponse{
+		TaskResponse: newLocalTaskResponse(GetTaskARN(dockerContainers), containerInstanceTags, taskTags),
+		ServiceName:  os.Getenv(config.ServiceNameMetadataVar),
+	}
+	for _, container := range dockerContainers {
+		ecsContainer := GetV4ContainerMetadata(&container, containerDetails[container.ID])
+		response.Containers = append(response.Containers, *ecsContainer)
+	}
+	response.Limits = getTaskLimits(response.Containers)
+	response.EphemeralStorageMetrics = getEphemeralStorageMetrics(dockerContainers, containerDetails, ephemeralStorageSize)
+	return response
+}
+
+// getEphemeralStorageMetrics returns the total size of the containers' writable layers as the utilized ephemeral storage;
+// it is nil if docker inspect did not return the size of any container
+func getEphemeralStorageMetrics(dockerContainers []types.Container, containerDetails map[string]*types.ContainerJSON, ephemeralStorageSize int64) *EphemeralStorageMetrics {
+	var utilized int64
+	hasSize := false
+	for _, container := range dockerContainers {
+		details := containerDetails[container.ID]
+		if details == nil || details.ContainerJSONBase == nil || details.SizeRw == nil {
+			continue
+		}
+		utilized += *details.SizeRw
+		hasSize = true
+	}
+	if !hasSize {
+		return nil
+	}
+	return &EphemeralStorageMetrics{
+		Utilized: utilized / bytesPerMiB,
+		Reserved: ephemeralStorageSize * 1024,
+	}
+}
+
+// GetV4ContainerMetadata creates a V4 container metadata response using info from the docker API
+// containerDetails is the output of docker inspect for the container, and can be nil
+func GetV4ContainerMetadata(dockerContainer *types.Container, containerDetails *types.ContainerJSON) *V4ContainerResponse {
+	response := &V4ContainerResponse{
+		ContainerResponse: GetContainerMetadata(dockerContainer),
+		ContainerARN:      getContainerARN(getTaskARN(dockerContainer.Labels[ComposeProjectLabel]), dockerContainer.ID),
+	}
+
+	// docker inspect has the current settings of every network, which the container list may be missing
+	if containerDetails != nil && containerDetails.NetworkSettings != nil && len(containerDetails.NetworkSettings.Networks) > 0 {
+		response.Networks = convertV4Networks(containerDetails.NetworkSettings.Networks)
+	} else if dockerContainer.NetworkSettings != nil {
+		response.Networks = convertV4Networks(dockerContainer.NetworkSettings.Networks)
+	}
+
+	if containerDetails != nil && containerDetails.ContainerJSONBase != nil && containerDetails.HostConfig != nil {
+		response.LogDriver = containerDetails.HostConfig.LogConfig.Type
+		response.LogOptions = containerDetails.HostConfig.LogConfig.Config
+		response.Limits = getContainerLimits(containerDetails.HostConfig)
+	}
+	// the healthcheck state from docker inspect has the output of the checks
+	if containerDetails != nil && containerDetails.ContainerJSONBase != nil && containerDetails.State != nil && containerDetails.State.Health != nil {
+		response.Health = convertHealth(containerDetails.State.Health)
+	}
+
+	return response
+}
+
+// getContainerLimits returns the CPU units and the memory in MiB which the container is limited to, like the cpu and memory
+// of an ECS container definition; CPU is taken from the number of CPUs (docker run --cpus), or else from the CPU shares
+func getContainerLimits(hostConfig *container.HostConfig) v2.LimitsResponse {
+	var limits v2.LimitsResponse
+	if hostConfig.NanoCPUs > 0 {
+		cpu := float64(hostConfig.NanoCPUs) * cpuUnitsPerCPU / 1e9
+		limits.CPU = &cpu
+	} else if hostConfig.CPUShares > 0 {
+		cpu := float64(hostConfig.CPUShares)
+		limits.CPU = &cpu
+	}
+	if hostConfig.Memory > 0 {
+		memory := hostConfig.Memory / bytesPerMiB
+		limits.Memory = &memory
+	}
+	return limits
+}
+
+// getTaskLimits returns the vCPUs and the memory in MiB of the task, which are the totals of its containers' limits;
+// each is nil unless every container is limited
+func getTaskLimits(containers []V4ContainerResponse) *v2.LimitsResponse {
+	if len(containers) == 0 {
+		return nil
+	}
+	var cpu float64
+	var memory int64
+	hasCPU, hasMemory := true, true
+	for _, container := range containers {
+		if container.Limits.CPU != nil {
+			cpu += *container.Limits.CPU / cpuUnitsPerCPU
+		} else {
+			hasCPU = false
+		}
+		if container.Limits.Memory != nil {
+			memory += *container.Limits.Memory
+		} else {
+			hasMemory = false
+		}
+	}
+	if !hasCPU && !hasMemory {
+		return nil
+	}
+	limits := &v2.LimitsResponse{}
+	if hasCPU {
+		limits.CPU = &cpu
+	}
+	if hasMemory {
+		limits.Memory = &memory
+	}
+	return limits
+}
+
+func convertV4Networks(dockerNetworks map[string]*network.EndpointSettings) []V4Network {
+	// sort the network names so that the attachment index is stable across requests
+	var networkNames []string
+	for netMode := range dockerNetworks {
+		networkNames = append(networkNames, netMode)
+	}
+	sort.Strings(networkNames)
+
+	var ecsNetworks []V4Network
+	for i, netMode := range networkNames {
+		attachmentIndex := i
+		ecsNet := V4Network{
+			Network: containermetadata.Network{
+				NetworkMode: netMode,
+			},
+			AttachmentIndex: &attachmentIndex,
+		}
+		netSettings := dockerNetworks[netMode]
+		if netSettings != nil {
+			if netSettings.IPAddress != "" {
+				ecsNet.IPv4Addresses = []string{
+					netSettings.IPAddress,
+				}
+				ecsNet.IPv4SubnetCIDRBlock = getSubnetCIDRBlock(netSettings.IPAddress, netSettings.IPPrefixLen)
+			}
+			if netSettings.GlobalIPv6Address != "" {
+				ecsNet.IPv6Addresses = []string{
+					netSettings.GlobalIPv6Address,
+				}
+			}
+			ecsNet.MACAddress = netSettings.MacAddress
+			ecsNet.SubnetGatewayIPv4Address = netSettings.Gateway
+		}
+		ecsNetworks = append(ecsNetworks, ecsNet)
+	}
+	return ecsNetworks
+}
+
+func getSubnetCIDRBlock(ipAddress string, prefixLength int) string {
+	if prefixLength == 0 {
+		return ""
+	}
+	_, subnet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", ipAddress, prefixLength))
+	if err != nil {
+		return ""
+	}
+	return subnet.String()
+}
+
+// Container ARNs are synthesized from the task ARN, since local containers don't have one:
+// arn:aws:ecs:us-west-2:111111111111:task/cluster/task-id => arn:aws:ecs:us-west-2:111111111111:container/<container ID>
+func getContainerARN(taskARN, containerID string) string {
+	split := strings.SplitN(taskARN, ":", 6)
+	if len(split) != 6 {
+		return ""
+	}
+	return fmt.Sprintf("%s:container/%s", strings.Join(split[:5], ":"), containerID)
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics contains the Prometheus metrics of Local Endpoints
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ecs_local"
+
+// Caches which report hits and misses
+const (
+	CredentialsCache = "credentials"
+	RoleCache        = "role"
+)
+
+var (
+	credentialsVended = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "credentials_vended_total",
+		Help:      "Number of times credentials were vended, by role ARN",
+	}, []string{"role_arn"})
+
+	stsRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sts_request_duration_seconds",
+		Help:      "Latency of STS requests, including retries, by operation and result",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	metadataRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "metadata_requests_total",
+		Help:      "Number of task metadata and stats requests, by path",
+	}, []string{"path"})
+
+	dockerAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "docker_api_errors_total",
+		Help:      "Number of failed Docker API requests, by operation",
+	}, []string{"operation"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of cache lookups, by cache and result (hit, miss, or shared for lookups which waited for the request of a miss)",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(credentialsVended, stsRequestDuration, metadataRequests, dockerAPIErrors, cacheRequests)
+}
+
+// Handler returns the HTTP handler for the metrics, in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// CredentialsVended counts credentials vended for the role
+func CredentialsVended(roleARN string) {
+	credentialsVended.WithLabelValues(roleARN).Inc()
+}
+
+// ObserveSTSRequest records the latency of an STS request which started at start
+func ObserveSTSRequest(operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	stsRequestDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// MetadataRequest counts a metadata request; path is the route's path template, so that the number of label values is bounded
+func MetadataRequest(path string) {
+	metadataRequests.WithLabelValues(path).Inc()
+}
+
+// DockerAPIError counts a failed Docker API request
+func DockerAPIError(operation string) {
+	dockerAPIErrors.WithLabelValues(operation).Inc()
+}
+
+// CacheLookup counts a hit or a miss in the cache
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// CacheSharedFetch counts a lookup which waited for the request made for a concurrent miss
+func CacheSharedFetch(cache string) {
+	cacheRequests.WithLabelValues(cache, "shared").Inc()
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	CredentialsVended("arn:aws:iam::111111111111:role/my-role")
+	CredentialsVended("arn:aws:iam::111111111111:role/my-role")
+	ObserveSTSRequest("AssumeRole", time.Now(), nil)
+	ObserveSTSRequest("AssumeRole", time.Now(), fmt.Errorf("Access Denied"))
+	MetadataRequest("/v3")
+	DockerAPIError("ContainerList")
+	CacheLookup(CredentialsCache, true)
+	CacheLookup(CredentialsCache, false)
+	CacheLookup(RoleCache, false)
+	CacheSharedFetch(CredentialsCache)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Expected http status code to be 200")
+	body, err := ioutil.ReadAll(recorder.Body)
+	assert.NoError(t, err, "Unexpected error reading response")
+
+	for _, expected := range []string{
+		`ecs_local_credentials_vended_total{role_arn="arn:aws:iam::111111111111:role/my-role"} 2`,
+		`ecs_local_sts_request_duration_seconds_count{operation="AssumeRole",result="success"} 1`,
+		`ecs_local_sts_request_duration_seconds_count{operation="AssumeRole",result="error"} 1`,
+		`ecs_local_metadata_requests_total{path="/v3"} 1`,
+		`ecs_local_docker_api_errors_total{operation="ContainerList"} 1`,
+		`ecs_local_cache_requests_total{cache="credentials",result="hit"} 1`,
+		`ecs_local_cache_requests_total{cache="credentials",result="miss"} 1`,
+		`ecs_local_cache_requests_total{cache="role",result="miss"} 1`,
+		`ecs_local_cache_requests_total{cache="credentials",result="shared"} 1`,
+	} {
+		assert.Contains(t, string(body), expected, "Expected metrics to contain the sample")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package testutils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/handlers"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func getJSON(t *testing.T, url string, response interface{}) int {
+	resp, err := http.Get(url)
+	assert.NoError(t, err, "Unexpected error")
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err, "Unexpected error")
+	assert.NoError(t, json.Unmarshal(body, response), "Expected a JSON response")
+	return resp.StatusCode
+}
+
+func TestServerDefaultCredentials(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	sdkConfig := defaults.Config()
+	provider := endpointcreds.NewProviderClient(*sdkConfig, defaults.Handlers(), server.CredentialsURL())
+	value, err := provider.Retrieve()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, AccessKeyID, value.AccessKeyID)
+	assert.Equal(t, SecretAccessKey, value.SecretAccessKey)
+	assert.Equal(t, SessionToken, value.SessionToken)
+	assert.False(t, provider.IsExpired(), "Expected the credentials to be valid")
+
+	var response handlers.CredentialResponse
+	assert.Equal(t, http.StatusOK, getJSON(t, server.URL+"/role/any-role", &response))
+	assert.Equal(t, RoleARN, response.RoleArn, "Expected the default credentials for any role")
+	assert.Equal(t, []string{"/creds", "/role/any-role"}, server.Requests())
+}
+
+func TestServerCannedCredentials(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	credentials := DefaultCredentials()
+	credentials.AccessKeyID = "ASIAOTHER"
+	credentials.RoleArn = "arn:aws:iam::111111111111:role/other"
+	server.SetRoleCredentials("other", credentials)
+	server.SetError("/role/denied", http.StatusForbidden, "AccessDenied", "Role denied is not allowed")
+
+	var response handlers.CredentialResponse
+	assert.Equal(t, http.StatusOK, getJSON(t, server.URL+"/role/other", &response))
+	assert.Equal(t, credentials, response, "Expected the canned credentials of the role")
+	assert.Equal(t, http.StatusOK, getJSON(t, server.URL+"/creds", &response))
+	assert.Equal(t, AccessKeyID, response.AccessKeyID, "Expected the default credentials at /creds")
+
+	var errorResponse map[string]interface{}
+	assert.Equal(t, http.StatusForbidden, getJSON(t, server.URL+"/role/denied", &errorResponse))
+	assert.Equal(t, "AccessDenied", errorResponse["code"])
+	assert.Equal(t, "Role denied is not allowed", errorResponse["message"])
+
+	provider := endpointcreds.NewProviderClient(*defaults.Config().WithMaxRetries(0), defaults.Handlers(), server.URL+"/role/denied")
+	_, err := provider.Retrieve()
+	assert.Error(t, err, "Expected the SDK to fail to get credentials")
+}
+
+func TestServerMetadata(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	env := server.Env()
+
+	var task metadata.V4TaskResponse
+	assert.Equal(t, http.StatusOK, getJSON(t, env["ECS_CONTAINER_METADATA_URI_V4"]+"/task", &task))
+	assert.Equal(t, DefaultTaskMetadata().TaskARN, task.TaskARN)
+	assert.Len(t, task.Containers, 1, "Expected one container")
+
+	var container metadata.V4ContainerResponse
+	assert.Equal(t, http.StatusOK, getJSON(t, env["ECS_CONTAINER_METADATA_URI"], &container))
+	assert.Equal(t, ContainerName, container.Name)
+
+	task.Family = "my-app"
+	server.SetTaskMetadata(&task)
+	assert.Equal(t, http.StatusOK, getJSON(t, env["ECS_CONTAINER_METADATA_URI"]+"/task/", &task))
+	assert.Equal(t, "my-app", task.Family, "Expected the canned task metadata")
+
+	var errorResponse map[string]interface{}
+	assert.Equal(t, http.StatusNotFound, getJSON(t, env["ECS_CONTAINER_METADATA_URI_V4"]+"/stats", &errorResponse))
+	server.SetStats(&metadata.V4StatsResponse{NetworkRateStats: &metadata.NetworkRateStats{RxBytesPerSecond: 10}})
+	var stats map[string]metadata.V4StatsResponse
+	assert.Equal(t, http.StatusOK, getJSON(t, env["ECS_CONTAINER_METADATA_URI_V4"]+"/task/stats", &stats))
+	assert.Equal(t, float64(10), stats[ContainerID].NetworkRateStats.RxBytesPerSecond)
+}
+
+func TestServerRawResponse(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	server.SetResponse("/creds", Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       `{"AccessKeyId": "AKIA`,
+	})
+	provider := endpointcreds.NewProviderClient(*defaults.Config().WithMaxRetries(0), defaults.Handlers(), server.CredentialsURL())
+	_, err := provider.Retrieve()
+	assert.Error(t, err, "Expected the SDK to fail to parse a truncated response")
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package server runs the credentials and metadata endpoints, so that they can be embedded in other programs, such as test frameworks
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/containerd"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/kubernetes"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/handlers"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Options configures a Server; the settings which are not in Options are read from the environment, in the same way as the container
+type Options struct {
+	// Services are the settings for each Docker Compose service from the config file
+	Services map[string]configfile.Service
+	// Clients replace the AWS and Docker clients which are created from the environment
+	Clients handlers.Clients
+	// Listeners replace the listeners at ECS_LOCAL_METADATA_PORT, and CredentialsListeners replace the listeners at ECS_LOCAL_CREDENTIALS_PORT;
+	// they are used as they are, so TLS settings don't apply to them
+	Listeners            []net.Listener
+	CredentialsListeners []net.Listener
+}
+
+// Server serves the endpoints at its listeners
+type Server struct {
+	servers            []*http.Server
+	listeners          [][]net.Listener
+	debugServer        *http.Server
+	debugListeners     []net.Listener
+	credentialsService *handlers.CredentialService
+	watchSharedConfig  bool
+	errors             chan error
+	// dockerWatcher keeps the model of the containers up to date, if the Docker client is not given
+	dockerWatcher *docker.WatchedClient
+	// introspectionServer serves the ECS Agent introspection API at its own listeners, if the introspection port is set
+	introspectionServer    *http.Server
+	introspectionListeners []net.Listener
+
+	lock   sync.Mutex
+	cancel context.CancelFunc
+}
+
+// New creates the services and listeners for the endpoints; they are served once Start is called
+func New(opts Options) (*Server, error) {
+	disableCredentials, err := utils.GetBoolValue(config.DisableCredentialsVar)
+	if err != nil {
+		return nil, err
+	}
+	disableMetadata, err := utils.GetBoolValue(config.DisableMetadataVar)
+	if err != nil {
+		return nil, err
+	}
+	if disableCredentials && disableMetadata {
+		return nil, fmt.Errorf("The credentials and metadata APIs can't both be disabled")
+	}
+	timeouts, err := handlers.GetServerTimeouts()
+	if err != nil {
+		return nil, err
+	}
+	disableDockerEvents, err := utils.GetBoolValue(config.DisableDockerEventsVar)
+	if err != nil {
+		return nil, err
+	}
+	dockerCacheTTL, err := handlers.GetDockerCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	// the metadata and credentials services share one model of the containers
+	clients := opts.Clients
+	var dockerWatcher *docker.WatchedClient
+	if clients.Docker == nil && kubernetes.IsSidecar() {
+		podClient, err := kubernetes.NewPodClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create Kubernetes pod client")
+		}
+		clients.Docker = podClient
+	}
+	if clients.Docker == nil && containerd.IsEnabled() {
+		nerdctlClient, err := containerd.NewNerdctlClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create containerd client")
+		}
+		clients.Docker = nerdctlClient
+	}
+	if clients.Docker == nil && !disableDockerEvents {
+		dockerWatcher, err = docker.NewWatchedClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create Docker client")
+		}
+		clients.Docker = dockerWatcher
+	}
+
+	router := mux.NewRouter()
+	handlers.SetupHealthRoutes(router)
+	handlers.SetupMetricsRoutes(router)
+
+	var metadataService *handlers.MetadataService
+	if !disableMetadata {
+		metadataService, err = newMetadataService(clients, dockerCacheTTL)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create Metadata Service")
+		}
+		metadataService.SetupV2Routes(router)
+		metadataService.SetupV3Routes(router)
+		metadataService.SetupV4Routes(router)
+		metadataService.SetupTaskProtectionRoutes(router)
+		metadataService.SetupSecretsRoutes(router)
+	}
+
+	var credentialsService *handlers.CredentialService
+	if !disableCredentials {
+		credentialsService, err = handlers.NewCustomCredentialService(opts.Services, clients)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create Credentials Service")
+		}
+		if metadataService != nil {
+			metadataService.UseSessionTokens(credentialsService)
+		}
+	}
+
+	server := &Server{
+		credentialsService: credentialsService,
+		watchSharedConfig:  opts.Clients.STS == nil,
+		dockerWatcher:      dockerWatcher,
+	}
+	if err = server.listen(opts, metadataService != nil); err != nil {
+		server.closeListeners()
+		return nil, err
+	}
+	count := len(server.introspectionListeners)
+	for _, listeners := range server.listeners {
+		count += len(listeners)
+	}
+	server.errors = make(chan error, count)
+
+	server.servers = []*http.Server{newHTTPServer(router, timeouts)}
+	// the credentials API has its own router if it is served at its own listeners
+	credentialsRouter := router
+	if len(server.listeners) > 1 {
+		credentialsRouter = mux.NewRouter()
+		handlers.SetupHealthRoutes(credentialsRouter)
+		server.servers = append(server.servers, newHTTPServer(credentialsRouter, timeouts))
+	}
+	if credentialsService != nil {
+		credentialsService.SetupRoutes(credentialsRouter)
+	}
+	if len(server.debugListeners) > 0 {
+		server.debugServer = &http.Server{
+			Handler: handlers.DebugHandler(),
+		}
+	}
+	if len(server.introspectionListeners) > 0 {
+		introspectionRouter := mux.NewRouter()
+		metadataService.SetupIntrospectionRoutes(introspectionRouter)
+		server.introspectionServer = newHTTPServer(introspectionRouter, timeouts)
+	}
+	return server, nil
+}
+
+// newMetadataService creates the metadata service, whose docker stats and inspect calls are cached for the TTL
+func newMetadataService(clients handlers.Clients, dockerCacheTTL time.Duration) (*handlers.MetadataService, error) {
+	dockerClient := clients.Docker
+	if dockerClient == nil {
+		var err error
+		if dockerClient, err = docker.NewDockerClient(); err != nil {
+			return nil, err
+		}
+	}
+	if dockerCacheTTL > 0 {
+		dockerClient = docker.NewCachedClient(dockerClient, dockerCacheTTL)
+	}
+	return handlers.NewMetadataServiceWithClient(dockerClient)
+}
+
+func newHTTPServer(router *mux.Router, timeouts *handlers.ServerTimeouts) *http.Server {
+	server := &http.Server{
+		Handler: handlers.AssignRequestIDs(handlers.TraceRequests(handlers.LogRequests(handlers.RecoverPanics(router)))),
+	}
+	timeouts.Apply(server)
+	return server
+}
+
+// listen opens the listeners from the options, or else from the environment; the introspection API is only served with the metadata API
+func (server *Server) listen(opts Options, introspection bool) error {
+	listeners := opts.Listeners
+	if listeners == nil {
+		var err error
+		listeners, err = handlers.Listen()
+		if err != nil {
+			return errors.Wrap(err, "Failed to start HTTP Server")
+		}
+	}
+	server.listeners = [][]net.Listener{listeners}
+
+	credentialsListeners := opts.CredentialsListeners
+	if credentialsListeners == nil {
+		var err error
+		credentialsListeners, err = handlers.ListenCredentials()
+		if err != nil {
+			return errors.Wrap(err, "Failed to start HTTP Server for credentials")
+		}
+	}
+	if len(credentialsListeners) > 0 {
+		server.listeners = append(server.listeners, credentialsListeners)
+	}
+
+	debugListeners, err := handlers.ListenDebug()
+	if err != nil {
+		return errors.Wrap(err, "Failed to start debug server")
+	}
+	server.debugListeners = debugListeners
+
+	if introspection {
+		introspectionListeners, err := handlers.ListenIntrospection()
+		if err != nil {
+			return errors.Wrap(err, "Failed to start HTTP Server for the introspection API")
+		}
+		server.introspectionListeners = introspectionListeners
+	}
+	return nil
+}
+
+func (server *Server) closeListeners() {
+	for _, listeners := range append(server.listeners, server.debugListeners, server.introspectionListeners) {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}
+}
+
+// Addrs returns the addresses of the listeners for the metadata API, followed by those of the credentials API if it has its own listeners
+func (server *Server) Addrs() []net.Addr {
+	var addrs []net.Addr
+	for _, listeners := range server.listeners {
+		for _, listener := range listeners {
+			addrs = append(addrs, listener.Addr())
+		}
+	}
+	return addrs
+}
+
+// allServers returns the HTTP servers along with the listeners of each, including the introspection server if there is one
+func (server *Server) allServers() ([]*http.Server, [][]net.Listener) {
+	servers := append([]*http.Server{}, server.servers...)
+	listeners := append([][]net.Listener{}, server.listeners...)
+	if server.introspectionServer != nil {
+		servers = append(servers, server.introspectionServer)
+		listeners = append(listeners, server.introspectionListeners)
+	}
+	return servers, listeners
+}
+
+// Start serves the endpoints in the background until Shutdown is called.
+// Requests are canceled once ctx is done, so that streaming requests such as container stats end.
+func (server *Server) Start(ctx context.Context) error {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if server.cancel != nil {
+		return fmt.Errorf("The server was already started")
+	}
+	baseContext, cancel := context.WithCancel(ctx)
+	server.cancel = cancel
+
+	servers, listeners := server.allServers()
+	for i, httpServer := range servers {
+		httpServer.BaseContext = func(net.Listener) context.Context {
+			return baseContext
+		}
+		for _, listener := range listeners[i] {
+			go func(httpServer *http.Server, listener net.Listener) {
+				if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+					server.errors <- err
+				}
+			}(httpServer, listener)
+		}
+	}
+
+	if server.debugServer != nil {
+		for _, listener := range server.debugListeners {
+			go func(listener net.Listener) {
+				if err := server.debugServer.Serve(listener); err != http.ErrServerClosed {
+					logrus.Warn("Debug server exited with error: ", err)
+				}
+			}(listener)
+		}
+	}
+	if server.credentialsService != nil && server.watchSharedConfig {
+		go server.credentialsService.WatchSharedConfigFiles(baseContext)
+	}
+	if server.dockerWatcher != nil {
+		go server.dockerWatcher.Watch(baseContext)
+	}
+	return nil
+}
+
+// Reload replaces the settings of the Docker Compose services, and reads the allowed and denied roles from the environment again;
+// the metadata settings in the environment are read on each request, so they don't need to be reloaded
+func (server *Server) Reload(services map[string]configfile.Service) error {
+	if server.credentialsService == nil {
+		return nil
+	}
+	return server.credentialsService.ReloadConfig(services)
+}
+
+// Errors returns a channel which receives the error when the server stops serving at one of its listeners, before Shutdown is called
+func (server *Server) Errors() <-chan error {
+	return server.errors
+}
+
+// Shutdown cancels the requests which are in progress and waits for them to end, until ctx is done
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.lock.Lock()
+	cancel := server.cancel
+	server.lock.Unlock()
+	if cancel == nil {
+		server.closeListeners()
+		return nil
+	}
+
+	cancel()
+	if server.debugServer != nil {
+		server.debugServer.Close()
+	}
+	var shutdownErr error
+	servers, _ := server.allServers()
+	for _, httpServer := range servers {
+		if err := httpServer.Shutdown(ctx); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	return shutdownErr
+}
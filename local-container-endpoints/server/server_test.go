@@ -0,0 +1,112 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker/mock_docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/iam/mock_iamiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/sts/mock_stsiface"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/handlers"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	stsMock := mock_stsiface.NewMockSTSAPI(ctrl)
+	expiration := time.Now().Add(time.Hour).UTC()
+	stsMock.EXPECT().GetSessionTokenWithContext(gomock.Any(), gomock.Any()).Return(&sts.GetSessionTokenOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("TESTAKID"),
+			SecretAccessKey: aws.String("SKID"),
+			SessionToken:    aws.String("TOKEN"),
+			Expiration:      &expiration,
+		},
+	}, nil)
+
+	dockerMock := mock_docker.NewMockClient(ctrl)
+	dockerMock.EXPECT().ContainerList(gomock.Any()).Return([]types.Container{}, nil).AnyTimes()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "Unexpected error listening")
+	server, err := New(Options{
+		Clients: handlers.Clients{
+			IAM:    mock_iamiface.NewMockIAMAPI(ctrl),
+			STS:    stsMock,
+			Docker: dockerMock,
+		},
+		Listeners:            []net.Listener{listener},
+		CredentialsListeners: []net.Listener{},
+	})
+	assert.NoError(t, err, "Unexpected error creating server")
+	assert.Equal(t, []net.Addr{listener.Addr()}, server.Addrs(), "Expected addresses to match")
+	assert.NoError(t, server.Start(context.Background()), "Unexpected error starting server")
+	assert.Error(t, server.Start(context.Background()), "Expected error starting the server twice")
+
+	url := fmt.Sprintf("http://%s", listener.Addr())
+	response, err := http.Get(url + config.HealthPath)
+	assert.NoError(t, err, "Unexpected error making request")
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode, "Expected status code to match")
+
+	response, err = http.Get(url + config.TempCredentialsPath)
+	assert.NoError(t, err, "Unexpected error making request")
+	var credentials handlers.CredentialResponse
+	err = json.NewDecoder(response.Body).Decode(&credentials)
+	response.Body.Close()
+	assert.NoError(t, err, "Unexpected error decoding response")
+	assert.Equal(t, "TESTAKID", credentials.AccessKeyID, "Expected access key to match")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx), "Unexpected error shutting down")
+	_, err = http.Get(url + config.HealthPath)
+	assert.Error(t, err, "Expected requests to fail after shutdown")
+}
+
+func TestNewErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, err := New(Options{
+		Clients: handlers.Clients{
+			STS:    mock_stsiface.NewMockSTSAPI(ctrl),
+			Docker: mock_docker.NewMockClient(ctrl),
+		},
+		Listeners:            []net.Listener{},
+		CredentialsListeners: []net.Listener{},
+	})
+	assert.Error(t, err, "Expected error for an STS client without an IAM client")
+
+	os.Setenv(config.DisableCredentialsVar, "true")
+	os.Setenv(config.DisableMetadataVar, "true")
+	defer os.Unsetenv(config.DisableCredentialsVar)
+	defer os.Unsetenv(config.DisableMetadataVar)
+	_, err = New(Options{})
+	assert.Error(t, err, "Expected error when both APIs are disabled")
+}
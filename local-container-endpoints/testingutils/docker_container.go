@@ -103,6 +103,21 @@ func (apiContainer *DockerContainer) WithNetwork(networkName, ipAddress string)
 	return apiContainer
 }
 
+// WithIPv6Address sets the IPv6 address of the container in a Docker Network added with WithNetwork, and returns the container for chaining
+func (apiContainer *DockerContainer) WithIPv6Address(networkName, ipv6Address string) *DockerContainer {
+	apiContainer.container.NetworkSettings.Networks[networkName].GlobalIPv6Address = ipv6Address
+	return apiContainer
+}
+
+// WithLabel adds a label and returns the container for chaining
+func (apiContainer *DockerContainer) WithLabel(key, value string) *DockerContainer {
+	if apiContainer.container.Labels == nil {
+		apiContainer.container.Labels = make(map[string]string)
+	}
+	apiContainer.container.Labels[key] = value
+	return apiContainer
+}
+
 // Get returns the underlying types.Container
 func (apiContainer *DockerContainer) Get() types.Container {
 	return apiContainer.container
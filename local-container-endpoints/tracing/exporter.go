@@ -0,0 +1,323 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/version"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultServiceName = "ecs-local-container-endpoints"
+	scopeName          = "github.com/awslabs/amazon-ecs-local-container-endpoints"
+
+	queueSize      = 2048
+	maxBatchSize   = 512
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+
+	statusCodeError = 2
+)
+
+// exporter sends ended spans to an OTLP/HTTP endpoint in batches
+type exporter struct {
+	endpoint string
+	headers  map[string]string
+	resource resource
+	client   *http.Client
+	spans    chan *Span
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Setup starts exporting spans if an OTLP endpoint is set in the environment; otherwise, tracing stays disabled
+func Setup() error {
+	endpointVar := config.OTLPTracesEndpointVar
+	endpoint := os.Getenv(endpointVar)
+	if endpoint == "" {
+		endpointVar = config.OTLPEndpointVar
+		endpoint = os.Getenv(endpointVar)
+		if endpoint == "" {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Host == "" {
+		return fmt.Errorf("Invalid value for %s: %s; expected an http or https URL", endpointVar, endpoint)
+	}
+
+	headers, err := parseHeaders(os.Getenv(config.OTLPHeadersVar))
+	if err != nil {
+		return err
+	}
+
+	e := &exporter{
+		endpoint: endpointURL.String(),
+		headers:  headers,
+		resource: resource{
+			Attributes: []keyValue{
+				stringAttribute("service.name", utils.GetValue(defaultServiceName, config.ServiceNameVar)),
+				stringAttribute("service.version", version.Version),
+			},
+		},
+		client: &http.Client{
+			Timeout: exportTimeout,
+		},
+		spans: make(chan *Span, queueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+
+	activeLock.Lock()
+	previous := active
+	active = e
+	activeLock.Unlock()
+	if previous != nil {
+		previous.shutdown(context.Background())
+	}
+	logrus.Infof("Exporting traces to %s", e.endpoint)
+	return nil
+}
+
+// Shutdown stops tracing, and exports the spans which have ended but not been sent yet
+func Shutdown(ctx context.Context) {
+	activeLock.Lock()
+	e := active
+	active = nil
+	activeLock.Unlock()
+	if e != nil {
+		e.shutdown(ctx)
+	}
+}
+
+// parseHeaders parses headers in the OTLP format: a comma separated list of key=value pairs with URL encoded values
+func parseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		split := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(split[0])
+		if len(split) != 2 || key == "" {
+			return nil, fmt.Errorf("Invalid value for %s: expected key=value pairs", config.OTLPHeadersVar)
+		}
+		headerValue, err := url.QueryUnescape(strings.TrimSpace(split[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for %s: %s", config.OTLPHeadersVar, err)
+		}
+		headers[key] = headerValue
+	}
+	return headers, nil
+}
+
+func (e *exporter) enqueue(span *Span) {
+	select {
+	case <-e.stop:
+	case e.spans <- span:
+	default:
+		logrus.Debugf("Dropped span %s: the export queue is full", span.name)
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				e.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = nil
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					e.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *exporter) shutdown(ctx context.Context) {
+	close(e.stop)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		logrus.Warn("Timed out exporting the remaining spans")
+	}
+}
+
+func (e *exporter) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]spanData, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, span.data())
+	}
+	body, err := json.Marshal(&exportRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: e.resource,
+				ScopeSpans: []scopeSpans{
+					{
+						Scope: scope{
+							Name:    scopeName,
+							Version: version.Version,
+						},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		logrus.Warnf("Failed to encode spans: %s", err)
+		return
+	}
+
+	request, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		logrus.Warnf("Failed to export spans: %s", err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := e.client.Do(request)
+	if err != nil {
+		logrus.Warnf("Failed to export spans: %s", err)
+		return
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		logrus.Warnf("Failed to export spans: %s responded with %s", e.endpoint, response.Status)
+	}
+}
+
+func (span *Span) data() spanData {
+	data := spanData{
+		TraceID:           hex.EncodeToString(span.context.traceID[:]),
+		SpanID:            hex.EncodeToString(span.context.spanID[:]),
+		Name:              span.name,
+		Kind:              int(span.kind),
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        span.attributes,
+	}
+	if span.parentID != ([8]byte{}) {
+		data.ParentSpanID = hex.EncodeToString(span.parentID[:])
+	}
+	if span.err != nil {
+		data.Status = status{
+			Code:    statusCodeError,
+			Message: span.err.Error(),
+		}
+	}
+	return data
+}
+
+func stringAttribute(key, value string) keyValue {
+	return keyValue{
+		Key: key,
+		Value: anyValue{
+			StringValue: &value,
+		},
+	}
+}
+
+// The OTLP/JSON schema of an export request
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type spanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
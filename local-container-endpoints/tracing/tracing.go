@@ -0,0 +1,169 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package tracing records OpenTelemetry spans of requests and of the AWS and Docker calls made to serve them
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanKind is the OpenTelemetry kind of a span
+type SpanKind int
+
+// Span kinds, which have the OTLP values
+const (
+	Internal SpanKind = 1
+	Server   SpanKind = 2
+	Client   SpanKind = 3
+)
+
+var (
+	activeLock sync.RWMutex
+	active     *exporter
+)
+
+type spanContextKey struct{}
+
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// Span is an operation in a trace; it is not safe for concurrent use.
+// A nil Span is valid and records nothing, which is what Start returns when tracing is disabled.
+type Span struct {
+	context    spanContext
+	parentID   [8]byte
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	attributes []keyValue
+	err        error
+	exporter   *exporter
+}
+
+// Start creates a span which is a child of the span in ctx, if any, and returns a context containing it
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e := getExporter()
+	if e == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+		exporter: e,
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		span.context.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.context.traceID[:])
+	}
+	rand.Read(span.context.spanID[:])
+	return context.WithValue(ctx, spanContextKey{}, span.context), span
+}
+
+// StartRPC creates a client span for a call to an API, such as STS AssumeRole
+func StartRPC(ctx context.Context, system, service, method string) (context.Context, *Span) {
+	ctx, span := Start(ctx, service+"."+method, Client)
+	span.SetAttribute("rpc.system", system)
+	span.SetAttribute("rpc.service", service)
+	span.SetAttribute("rpc.method", method)
+	return ctx, span
+}
+
+// SetAttribute adds an attribute to the span; value can be a string, int or bool
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+	attribute := keyValue{Key: key}
+	switch v := value.(type) {
+	case string:
+		attribute.Value.StringValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		attribute.Value.IntValue = &s
+	case bool:
+		attribute.Value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		attribute.Value.StringValue = &s
+	}
+	span.attributes = append(span.attributes, attribute)
+}
+
+// End completes the span and queues it for export; err marks the span as failed if it is not nil
+func (span *Span) End(err error) {
+	if span == nil {
+		return
+	}
+	span.end = time.Now()
+	span.err = err
+	span.exporter.enqueue(span)
+}
+
+// Extract returns a context whose spans continue the trace in a W3C traceparent header, if it is valid
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var parent spanContext
+	if _, err := hex.Decode(parent.traceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(parent.spanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	if parent.traceID == ([16]byte{}) || parent.spanID == ([8]byte{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, parent)
+}
+
+// TraceID returns the ID of the trace in ctx, or an empty string if there is none
+func TraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if sc, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		return hex.EncodeToString(sc.traceID[:])
+	}
+	return ""
+}
+
+// Enabled returns whether spans are being exported
+func Enabled() bool {
+	return getExporter() != nil
+}
+
+func getExporter() *exporter {
+	activeLock.RLock()
+	defer activeLock.RUnlock()
+	return active
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// otlpReceiver records the spans exported to it
+type otlpReceiver struct {
+	lock    sync.Mutex
+	headers http.Header
+	spans   []spanData
+}
+
+func (receiver *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request exportRequest
+	if r.URL.Path != "/v1/traces" || json.NewDecoder(r.Body).Decode(&request) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	receiver.headers = r.Header
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			receiver.spans = append(receiver.spans, scopeSpans.Spans...)
+		}
+	}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	os.Unsetenv(config.OTLPEndpointVar)
+	os.Unsetenv(config.OTLPTracesEndpointVar)
+
+	err := Setup()
+	assert.NoError(t, err, "Unexpected error setting up tracing")
+	assert.False(t, Enabled(), "Expected tracing to be disabled")
+
+	ctx, span := Start(context.Background(), "test", Internal)
+	assert.Nil(t, span, "Expected no span when tracing is disabled")
+	assert.Empty(t, TraceID(ctx), "Expected no trace ID when tracing is disabled")
+	span.SetAttribute("key", "value")
+	span.End(nil)
+}
+
+func TestSetupInvalidEndpoint(t *testing.T) {
+	os.Setenv(config.OTLPTracesEndpointVar, "localhost:4318")
+	defer os.Unsetenv(config.OTLPTracesEndpointVar)
+
+	err := Setup()
+	assert.Error(t, err, "Expected error for an endpoint without a scheme")
+	assert.False(t, Enabled(), "Expected tracing to be disabled")
+}
+
+func TestExportSpans(t *testing.T) {
+	receiver := &otlpReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	os.Setenv(config.OTLPEndpointVar, server.URL+"/")
+	os.Setenv(config.OTLPHeadersVar, "x-api-key=secret%20key")
+	defer os.Unsetenv(config.OTLPEndpointVar)
+	defer os.Unsetenv(config.OTLPHeadersVar)
+
+	err := Setup()
+	assert.NoError(t, err, "Unexpected error setting up tracing")
+	assert.True(t, Enabled(), "Expected tracing to be enabled")
+
+	ctx := Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, parent := Start(ctx, "GET /creds", Server)
+	parent.SetAttribute("http.response.status_code", 200)
+	_, child := StartRPC(ctx, "aws-api", "STS", "AssumeRole")
+	child.End(errors.New("AccessDenied"))
+	parent.End(nil)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", TraceID(ctx), "Expected the trace to be continued")
+
+	Shutdown(context.Background())
+	assert.False(t, Enabled(), "Expected tracing to be disabled after shutdown")
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	assert.Equal(t, "secret key", receiver.headers.Get("x-api-key"), "Expected the configured header")
+	assert.Len(t, receiver.spans, 2, "Expected both spans to be exported")
+	if len(receiver.spans) != 2 {
+		return
+	}
+	childData, parentData := receiver.spans[0], receiver.spans[1]
+
+	assert.Equal(t, "GET /creds", parentData.Name, "Unexpected span name")
+	assert.Equal(t, int(Server), parentData.Kind, "Unexpected span kind")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parentData.TraceID, "Expected the trace ID of the traceparent")
+	assert.Equal(t, "00f067aa0ba902b7", parentData.ParentSpanID, "Expected the span ID of the traceparent as the parent")
+	assert.Equal(t, "200", *parentData.Attributes[0].Value.IntValue, "Unexpected status code attribute")
+	assert.Zero(t, parentData.Status.Code, "Expected the parent span to be successful")
+
+	assert.Equal(t, "STS.AssumeRole", childData.Name, "Unexpected span name")
+	assert.Equal(t, int(Client), childData.Kind, "Unexpected span kind")
+	assert.Equal(t, parentData.TraceID, childData.TraceID, "Expected the child to be in the same trace")
+	assert.Equal(t, parentData.SpanID, childData.ParentSpanID, "Expected the child span's parent to be the server span")
+	assert.Equal(t, statusCodeError, childData.Status.Code, "Expected the child span to be failed")
+	assert.Equal(t, "AccessDenied", childData.Status.Message, "Unexpected status message")
+}
+
+func TestExtractInvalidTraceparent(t *testing.T) {
+	testCases := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzf067aa0ba902b7-01",
+	}
+	for _, traceparent := range testCases {
+		ctx := Extract(context.Background(), traceparent)
+		assert.Empty(t, TraceID(ctx), "Expected no trace for %q", traceparent)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("api-key=abc, tenant = dev%2Ftest,")
+	assert.NoError(t, err, "Unexpected error parsing headers")
+	assert.Equal(t, map[string]string{"api-key": "abc", "tenant": "dev/test"}, headers, "Unexpected headers")
+
+	_, err = parseHeaders("api-key")
+	assert.Error(t, err, "Expected error for a header without a value")
+}
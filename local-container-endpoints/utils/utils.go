@@ -17,9 +17,26 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 )
 
+// DefaultPartition is the partition of the commercial regions, and of regions which are not known to be in another partition
+const DefaultPartition = "aws"
+
+// the partitions of regions which the SDK does not know, by the prefix of their names
+var partitionPrefixes = []struct {
+	prefix    string
+	partition string
+}{
+	{"cn-", "aws-cn"},
+	{"us-gov-", "aws-us-gov"},
+	{"us-isob-", "aws-iso-b"},
+	{"us-iso-", "aws-iso"},
+}
+
 // Truncate truncates a string
 func Truncate(s string, length int) string {
 	if len(s) > length {
@@ -51,3 +68,34 @@ func GetValue(defaultVal, envVar string) string {
 
 	return defaultVal
 }
+
+// GetBoolValue returns the boolean value of the envVar, which is false if it is not set
+func GetBoolValue(envVar string) (bool, error) {
+	val := os.Getenv(envVar)
+	if val == "" {
+		return false, nil
+	}
+	result, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s: %s is not true or false", envVar, val)
+	}
+	return result, nil
+}
+
+// GetRegion returns the region in AWS_REGION or AWS_DEFAULT_REGION, or an empty string if neither is set
+func GetRegion() string {
+	return GetValue(os.Getenv("AWS_DEFAULT_REGION"), "AWS_REGION")
+}
+
+// GetPartition returns the partition of the region, such as aws-us-gov for us-gov-west-1 or aws-cn for cn-north-1
+func GetPartition(region string) string {
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return partition.ID()
+	}
+	for _, partitionPrefix := range partitionPrefixes {
+		if strings.HasPrefix(region, partitionPrefix.prefix) {
+			return partitionPrefix.partition
+		}
+	}
+	return DefaultPartition
+}
@@ -0,0 +1,198 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package localendpoints starts Local Endpoints with testcontainers, so that integration tests can give application
+// containers credentials and metadata in the same way as ECS, with one function call:
+//
+//	endpoints, err := localendpoints.Run(ctx, localendpoints.Options{Role: "my-role"})
+//	...
+//	defer endpoints.Terminate(ctx)
+//	request := testcontainers.GenericContainerRequest{
+//		ContainerRequest: testcontainers.ContainerRequest{Image: "my-app"},
+//		Started:          true,
+//	}
+//	endpoints.Customize(&request)
+//	app, err := testcontainers.GenericContainer(ctx, request)
+package localendpoints
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+	dockernetwork "github.com/docker/docker/api/types/network"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	// DefaultImage is the Local Endpoints image which is started unless another one is given
+	DefaultImage = "amazon/amazon-ecs-local-container-endpoints"
+	// IPAddress is the address of Local Endpoints in its network, which is the one that ECS uses
+	IPAddress = "169.254.170.2"
+
+	networkSubnet  = "169.254.170.0/24"
+	networkGateway = "169.254.170.1"
+	port           = "80/tcp"
+	healthPath     = "/healthz"
+	awsConfigPath  = "/home/.aws/"
+	dockerSocket   = "/var/run/docker.sock"
+)
+
+// Options are the settings of Local Endpoints
+type Options struct {
+	// Image is the Local Endpoints image; it defaults to DefaultImage
+	Image string
+	// Profile is the AWS profile which Local Endpoints sources credentials from; it defaults to "default"
+	Profile string
+	// Region is set as the AWS_DEFAULT_REGION and AWS_REGION of application containers, if it is not empty
+	Region string
+	// Role is the name or ARN of the role which application containers get credentials for; if it is empty, they get
+	// the temporary credentials of the profile
+	Role string
+	// AWSConfigDir is the directory with the shared config and credentials files; it defaults to $HOME/.aws
+	AWSConfigDir string
+	// Env adds environment variables to Local Endpoints, such as ECS_LOCAL_TASK_DEFINITION
+	Env map[string]string
+}
+
+// Endpoints is a running Local Endpoints container and the network in which application containers can reach it
+type Endpoints struct {
+	testcontainers.Container
+	// Network is the network in which Local Endpoints has IPAddress; it is removed by Terminate
+	Network *testcontainers.DockerNetwork
+
+	options Options
+}
+
+// Run creates the network and starts Local Endpoints in it. Only one can run at a time on a Docker host, since
+// the subnet of the network is always the same.
+func Run(ctx context.Context, options Options) (*Endpoints, error) {
+	if options.Image == "" {
+		options.Image = DefaultImage
+	}
+	if options.Profile == "" {
+		options.Profile = "default"
+	}
+	if options.AWSConfigDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to find the AWS config directory: %v", err)
+		}
+		options.AWSConfigDir = filepath.Join(home, ".aws")
+	}
+
+	endpointsNetwork, err := network.New(ctx, network.WithIPAM(&dockernetwork.IPAM{
+		Config: []dockernetwork.IPAMConfig{
+			{
+				Subnet:  networkSubnet,
+				Gateway: networkGateway,
+			},
+		},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the network for Local Endpoints: %v", err)
+	}
+
+	env := map[string]string{
+		"AWS_PROFILE": options.Profile,
+	}
+	for key, value := range options.Env {
+		env[key] = value
+	}
+	request := testcontainers.ContainerRequest{
+		Image:        options.Image,
+		Env:          env,
+		ExposedPorts: []string{port},
+		Networks:     []string{endpointsNetwork.Name},
+		HostConfigModifier: func(hostConfig *container.HostConfig) {
+			hostConfig.Binds = append(hostConfig.Binds,
+				dockerSocket+":"+dockerSocket,
+				options.AWSConfigDir+":"+awsConfigPath+":ro",
+			)
+		},
+		WaitingFor: wait.ForHTTP(healthPath).WithPort(port),
+	}
+	genericRequest := testcontainers.GenericContainerRequest{
+		ContainerRequest: request,
+		Started:          true,
+	}
+	// Local Endpoints has the address which the SDKs in application containers expect
+	testcontainers.WithEndpointSettingsModifier(func(settings map[string]*dockernetwork.EndpointSettings) {
+		if settings[endpointsNetwork.Name] == nil {
+			settings[endpointsNetwork.Name] = &dockernetwork.EndpointSettings{}
+		}
+		settings[endpointsNetwork.Name].IPAMConfig = &dockernetwork.EndpointIPAMConfig{
+			IPv4Address: IPAddress,
+		}
+	}).Customize(&genericRequest)
+	endpointsContainer, err := testcontainers.GenericContainer(ctx, genericRequest)
+	if err != nil {
+		if endpointsContainer != nil {
+			endpointsContainer.Terminate(ctx)
+		}
+		endpointsNetwork.Remove(ctx)
+		return nil, fmt.Errorf("Failed to start Local Endpoints: %v", err)
+	}
+
+	return &Endpoints{
+		Container: endpointsContainer,
+		Network:   endpointsNetwork,
+		options:   options,
+	}, nil
+}
+
+// Env returns the environment variables which give an application container credentials and metadata from Local Endpoints
+func (endpoints *Endpoints) Env() map[string]string {
+	credentialsPath := "/creds"
+	if endpoints.options.Role != "" {
+		credentialsPath = "/role/" + endpoints.options.Role
+	}
+	env := map[string]string{
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": credentialsPath,
+		"ECS_CONTAINER_METADATA_URI":             "http://" + IPAddress + "/v3",
+		"ECS_CONTAINER_METADATA_URI_V4":          "http://" + IPAddress + "/v4",
+	}
+	if endpoints.options.Region != "" {
+		env["AWS_DEFAULT_REGION"] = endpoints.options.Region
+		env["AWS_REGION"] = endpoints.options.Region
+	}
+	return env
+}
+
+// Customize adds an application container to the network of Local Endpoints and sets its environment variables,
+// which are not overwritten if the request already has them; Endpoints can also be passed as an option to the Run
+// functions of testcontainers modules
+func (endpoints *Endpoints) Customize(request *testcontainers.GenericContainerRequest) error {
+	request.Networks = append(request.Networks, endpoints.Network.Name)
+	if request.Env == nil {
+		request.Env = make(map[string]string)
+	}
+	for key, value := range endpoints.Env() {
+		if _, ok := request.Env[key]; !ok {
+			request.Env[key] = value
+		}
+	}
+	return nil
+}
+
+// Terminate stops Local Endpoints and removes its network; application containers in the network must be terminated first
+func (endpoints *Endpoints) Terminate(ctx context.Context) error {
+	if err := endpoints.Container.Terminate(ctx); err != nil {
+		return err
+	}
+	return endpoints.Network.Remove(ctx)
+}
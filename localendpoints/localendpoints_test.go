@@ -0,0 +1,80 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localendpoints
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestEnv(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		options  Options
+		expected map[string]string
+	}{
+		{
+			name: "TemporaryCredentials",
+			expected: map[string]string{
+				"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/creds",
+				"ECS_CONTAINER_METADATA_URI":             "http://169.254.170.2/v3",
+				"ECS_CONTAINER_METADATA_URI_V4":          "http://169.254.170.2/v4",
+			},
+		},
+		{
+			name: "RoleAndRegion",
+			options: Options{
+				Role:   "my-role",
+				Region: "us-west-2",
+			},
+			expected: map[string]string{
+				"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/role/my-role",
+				"ECS_CONTAINER_METADATA_URI":             "http://169.254.170.2/v3",
+				"ECS_CONTAINER_METADATA_URI_V4":          "http://169.254.170.2/v4",
+				"AWS_DEFAULT_REGION":                     "us-west-2",
+				"AWS_REGION":                             "us-west-2",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			endpoints := &Endpoints{options: test.options}
+			assert.Equal(t, test.expected, endpoints.Env(), "Expected environment variables to match")
+		})
+	}
+}
+
+func TestCustomize(t *testing.T) {
+	endpoints := &Endpoints{
+		Network: &testcontainers.DockerNetwork{Name: "endpoints-network"},
+		options: Options{Role: "my-role"},
+	}
+	request := &testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Networks: []string{"app-network"},
+			Env: map[string]string{
+				"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/role/other-role",
+			},
+		},
+	}
+
+	err := endpoints.Customize(request)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []string{"app-network", "endpoints-network"}, request.Networks, "Expected networks to match")
+	assert.Equal(t, "/role/other-role", request.Env["AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"], "Expected the request's variable to be kept")
+	assert.Equal(t, "http://169.254.170.2/v4", request.Env["ECS_CONTAINER_METADATA_URI_V4"], "Expected metadata variable to be added")
+}
@@ -14,43 +14,306 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/docker"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/clients/proxy"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/composefile"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/config/configfile"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/handlers"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/server"
+	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/tracing"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/utils"
 	"github.com/awslabs/amazon-ecs-local-container-endpoints/local-container-endpoints/version"
-	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	mock := flag.Bool("mock", false, "Vend mock credentials without making requests to AWS; the same as "+config.MockCredentialsVar+"=true")
+	logLevel := flag.String("log-level", "", "Set the log level, such as debug; the same as "+config.LogLevelVar)
+	printVersion := flag.Bool("version", false, "Print the version and exit")
+	flag.Parse()
+	if *printVersion {
+		fmt.Println(version.String())
+		return
+	}
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(args))
+	}
+	if *mock {
+		os.Setenv(config.MockCredentialsVar, "true")
+	}
+	if *logLevel != "" {
+		os.Setenv(config.LogLevelVar, *logLevel)
+	}
+
+	if err := handlers.SetupLogFormat(); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := handlers.SetupLogLevel(); err != nil {
+		logrus.Fatal(err)
+	}
+
 	logrus.Info(version.String())
 	logrus.Info("Running...")
-	credentialsService, err := handlers.NewCredentialService()
+	endpointsConfig, err := configfile.Load()
+	if err != nil {
+		logrus.Fatal("Failed to load config file: ", err)
+	}
+	if err = endpointsConfig.SetEnvironment(); err != nil {
+		logrus.Fatal("Failed to apply config file: ", err)
+	}
+	proxySettings, err := proxy.FromEnvironment()
 	if err != nil {
-		logrus.Fatal("Failed to create Credentials Service: ", err)
+		logrus.Fatal(err)
+	}
+	if proxySettings.Enabled() {
+		logrus.Infof("Requests to AWS and to remote Docker engines go through the proxy: %s", proxySettings)
+	}
+	if err = tracing.Setup(); err != nil {
+		logrus.Fatal(err)
+	}
+	if err = joinNetwork(); err != nil {
+		logrus.Fatal(err)
+	}
+
+	endpoints, err := server.New(server.Options{
+		Services: endpointsConfig.Services,
+	})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if err = endpoints.Start(context.Background()); err != nil {
+		logrus.Fatal(err)
 	}
 
-	metadataService, err := handlers.NewMetadataService()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for running := true; running; {
+		select {
+		case err = <-endpoints.Errors():
+			logrus.Fatal("HTTP Server exited with error: ", err)
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				endpointsConfig = reloadConfig(endpoints, endpointsConfig)
+				continue
+			}
+			logrus.Infof("Received %s, shutting down", sig)
+			running = false
+		}
+	}
+
+	timeout, _ := time.ParseDuration(config.ShutdownTimeoutDuration)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err = endpoints.Shutdown(ctx); err != nil {
+		logrus.Warn("Requests were still in progress when the HTTP Server shut down: ", err)
+	}
+	tracing.Shutdown(ctx)
+}
+
+// reloadConfig loads the config file again and applies it to the endpoints; the previous config is kept if the file is invalid
+func reloadConfig(endpoints *server.Server, previous *configfile.Config) *configfile.Config {
+	logrus.Info("Received SIGHUP, reloading the config file")
+	endpointsConfig, err := configfile.Load()
 	if err != nil {
-		logrus.Fatal("Failed to create Metadata Service: ", err)
+		logrus.Error("Failed to reload config file, the previous config will continue to be used: ", err)
+		return previous
 	}
+	if err = endpointsConfig.ReplaceEnvironment(previous); err == nil {
+		err = endpoints.Reload(endpointsConfig.Services)
+	}
+	if err != nil {
+		logrus.Error("Failed to apply config file, the previous config will continue to be used: ", err)
+		previous.ReplaceEnvironment(endpointsConfig)
+		return previous
+	}
+	logrus.Info("Reloaded the config file")
+	return endpointsConfig
+}
 
-	port := utils.GetValue(config.DefaultPort, config.PortVar)
+// runCommand runs a subcommand, and returns the exit code
+func runCommand(args []string) int {
+	if len(args) == 1 && args[0] == "healthcheck" {
+		return checkHealth()
+	}
+	if len(args) == 1 && args[0] == "doctor" {
+		return runDoctor()
+	}
+	if len(args) >= 2 && len(args) <= 3 && args[0] == "network" && args[1] == "create" {
+		return createNetwork(args[2:])
+	}
+	if args[0] == "init" {
+		return initCompose(args[1:])
+	}
+	if len(args) < 2 || len(args) > 3 || args[0] != "config" || args[1] != "validate" {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\nUsage:\n  local-container-endpoints config validate [config file]\n  local-container-endpoints doctor\n  local-container-endpoints healthcheck\n  local-container-endpoints init [options] [compose file...]\n  local-container-endpoints network create [container]\n", strings.Join(args, " "))
+		return 2
+	}
+	if len(args) == 3 {
+		os.Setenv(config.ConfigFileVar, args[2])
+	}
+	return validateConfig()
+}
 
-	router := mux.NewRouter()
-	metadataService.SetupV2Routes(router)
-	metadataService.SetupV3Routes(router)
-	credentialsService.SetupRoutes(router)
+// initCompose prints or writes the Compose override file which sets up the services of a project to use Local Endpoints
+func initCompose(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	profile := flags.String("profile", "default", "The AWS profile which Local Endpoints sources credentials from")
+	region := flags.String("region", "us-east-1", "The AWS_DEFAULT_REGION of the services")
+	role := flags.String("role", "", "The name or ARN of the role which the services get credentials for; the default is the credentials of the profile")
+	xrayDaemon := flags.Bool("xray", false, "Add an X-Ray daemon service, which the services send segments to")
+	xrayDaemonAddress := flags.String("xray-daemon-address", "", "The AWS_XRAY_DAEMON_ADDRESS of the services, such as host.docker.internal:2000; the default is the X-Ray daemon service with -xray")
+	output := flags.String("o", "", "The path to write the override file to, such as docker-compose.override.yml; the default is to print it")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:\n  local-container-endpoints init [options] [compose file...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		path, err := composefile.FindFile(".")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		paths = []string{path}
+	}
+	project, err := composefile.Load(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	override, err := project.Override(composefile.OverrideOptions{
+		Profile:           *profile,
+		Region:            *region,
+		Role:              *role,
+		XRayDaemon:        *xrayDaemon,
+		XRayDaemonAddress: *xrayDaemonAddress,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(override)
+		return 0
+	}
+	// an existing override file may have been written by hand, so it is not replaced
+	file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		_, err = file.Write(override)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Wrote %s, which sets up the services to use Local Endpoints\n", *output)
+	return 0
+}
+
+// checkHealth requests the ping path of the endpoints in this container, for the HEALTHCHECK of the image
+func checkHealth() int {
+	if err := handlers.CheckHealth(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// validateConfig checks the config file and the settings in the environment, and prints each problem that it finds
+func validateConfig() int {
+	// only warnings are logged, so that the output is just the result
+	logrus.SetLevel(logrus.WarnLevel)
+	endpointsConfig, err := configfile.Load()
+	if err == nil {
+		err = endpointsConfig.SetEnvironment()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	problems := handlers.ValidateConfig(endpointsConfig.Services)
+	if len(problems) == 0 {
+		fmt.Println("The config is valid")
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, "The config is invalid:")
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "  - %v\n", problem)
+	}
+	return 1
+}
+
+// runDoctor checks that Local Endpoints works in this environment, and prints a report of each check
+func runDoctor() int {
+	// only warnings are logged, so that the output is just the report
+	logrus.SetLevel(logrus.WarnLevel)
+	endpointsConfig, err := configfile.Load()
+	if err == nil {
+		err = endpointsConfig.SetEnvironment()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	exitCode := 0
+	for _, check := range handlers.RunDoctor(context.Background(), endpointsConfig.Services) {
+		fmt.Printf("[%-4s] %s: %s\n", check.Status, check.Name, check.Detail)
+		if check.Status == handlers.DoctorFailed {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// joinNetwork connects this container to the credentials network on startup, if it is enabled
+func joinNetwork() error {
+	createNetwork, err := utils.GetBoolValue(config.CreateNetworkVar)
+	if err != nil || !createNetwork {
+		return err
+	}
+	containerID, err := docker.CurrentContainerID()
+	if err != nil {
+		return err
+	}
+	_, err = docker.SetupNetwork(context.Background(), utils.GetValue(config.DefaultNetworkName, config.NetworkNameVar), containerID)
+	return err
+}
 
-	server := http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: router,
+// createNetwork creates the credentials network if it doesn't exist, and connects the given container to it
+// at the credentials address; the container defaults to the one this command runs in
+func createNetwork(args []string) int {
+	var containerID string
+	var err error
+	if len(args) == 1 {
+		containerID = args[0]
+	} else if containerID, err = docker.CurrentContainerID(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	err = server.ListenAndServe()
+	name, err := docker.SetupNetwork(context.Background(), utils.GetValue(config.DefaultNetworkName, config.NetworkNameVar), containerID)
 	if err != nil {
-		logrus.Fatal("HTTP Server exited with error: ", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	fmt.Printf("Local Endpoints is at %s in network %s; run your containers with --network %s\n", config.EndpointsIP, name, name)
+	return 0
 }
@@ -29,4 +29,9 @@ go run gen/version-gen.go
 
 cd "${ROOT}"
 
-GOOS=$TARGET_GOOS CGO_ENABLED=0 GO111MODULE=on go build -mod=vendor -installsuffix cgo -a -ldflags '-s' -o $1/local-container-endpoints ./
+BINARY_NAME=local-container-endpoints
+if [ "$TARGET_GOOS" == "windows" ]; then
+	BINARY_NAME=local-container-endpoints.exe
+fi
+
+GOOS=$TARGET_GOOS CGO_ENABLED=0 GO111MODULE=on go build -mod=vendor -installsuffix cgo -a -ldflags '-s' -o $1/$BINARY_NAME ./